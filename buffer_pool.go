@@ -2,6 +2,7 @@ package quic
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/nxenon/xquic-go/internal/protocol"
 )
@@ -13,6 +14,15 @@ type packetBuffer struct {
 	// It doesn't support concurrent use.
 	// It is > 1 when used for coalesced packet.
 	refCount int
+
+	// parent is set for the segments of a datagram coalesced by GRO.
+	// Data then points into the buffer of the coalesced datagram, which is put back
+	// into the pool once all of its segments have been released.
+	parent *packetBuffer
+	// segments counts the segments that still use Data.
+	// It is only used for the buffers of datagrams coalesced by GRO. Since the segments
+	// might be handled by different connections, it is updated atomically.
+	segments atomic.Int32
 }
 
 // Split increases the refCount.
@@ -55,6 +65,16 @@ func (b *packetBuffer) Len() protocol.ByteCount { return protocol.ByteCount(len(
 func (b *packetBuffer) Cap() protocol.ByteCount { return protocol.ByteCount(cap(b.Data)) }
 
 func (b *packetBuffer) putBack() {
+	if b.parent != nil {
+		parent := b.parent
+		b.parent = nil
+		b.Data = nil
+		segmentBufferPool.Put(b)
+		if parent.segments.Add(-1) == 0 {
+			parent.putBack()
+		}
+		return
+	}
 	if cap(b.Data) == protocol.MaxPacketBufferSize {
 		bufferPool.Put(b)
 		return
@@ -63,10 +83,14 @@ func (b *packetBuffer) putBack() {
 		largeBufferPool.Put(b)
 		return
 	}
+	if cap(b.Data) == protocol.MaxGROBufferSize {
+		groBufferPool.Put(b)
+		return
+	}
 	panic("putPacketBuffer called with packet of wrong size!")
 }
 
-var bufferPool, largeBufferPool sync.Pool
+var bufferPool, largeBufferPool, groBufferPool, segmentBufferPool sync.Pool
 
 func getPacketBuffer() *packetBuffer {
 	buf := bufferPool.Get().(*packetBuffer)
@@ -82,6 +106,33 @@ func getLargePacketBuffer() *packetBuffer {
 	return buf
 }
 
+// getGROBuffer gets a buffer that is large enough to receive a datagram coalesced by GRO.
+func getGROBuffer() *packetBuffer {
+	buf := groBufferPool.Get().(*packetBuffer)
+	buf.refCount = 1
+	buf.Data = buf.Data[:0]
+	return buf
+}
+
+// getSegmentBuffer gets a buffer for a segment of a datagram coalesced by GRO.
+// The data of the segment is not copied: it references the buffer of the coalesced datagram.
+// The caller is responsible for setting the number of segments on the parent.
+func getSegmentBuffer(parent *packetBuffer, data []byte) *packetBuffer {
+	buf := segmentBufferPool.Get().(*packetBuffer)
+	buf.refCount = 1
+	buf.parent = parent
+	buf.Data = data
+	return buf
+}
+
+// getPacketBufferForSize gets a packet buffer that is large enough for a packet of the given size.
+func getPacketBufferForSize(size protocol.ByteCount) *packetBuffer {
+	if size > protocol.MaxPacketBufferSize {
+		return getLargePacketBuffer()
+	}
+	return getPacketBuffer()
+}
+
 func init() {
 	bufferPool.New = func() any {
 		return &packetBuffer{Data: make([]byte, 0, protocol.MaxPacketBufferSize)}
@@ -89,4 +140,8 @@ func init() {
 	largeBufferPool.New = func() any {
 		return &packetBuffer{Data: make([]byte, 0, protocol.MaxLargePacketBufferSize)}
 	}
+	groBufferPool.New = func() any {
+		return &packetBuffer{Data: make([]byte, 0, protocol.MaxGROBufferSize)}
+	}
+	segmentBufferPool.New = func() any { return &packetBuffer{} }
 }
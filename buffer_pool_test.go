@@ -15,6 +15,12 @@ var _ = Describe("Buffer Pool", func() {
 		Expect(buf2.Data).To(HaveCap(protocol.MaxLargePacketBufferSize))
 	})
 
+	It("returns buffers large enough for the packet size", func() {
+		Expect(getPacketBufferForSize(protocol.MaxPacketBufferSize).Data).To(HaveCap(protocol.MaxPacketBufferSize))
+		Expect(getPacketBufferForSize(protocol.MaxPacketBufferSize + 1).Data).To(HaveCap(protocol.MaxLargePacketBufferSize))
+		Expect(getPacketBufferForSize(9000).Data).To(HaveCap(protocol.MaxLargePacketBufferSize))
+	})
+
 	It("releases buffers", func() {
 		buf1 := getPacketBuffer()
 		buf1.Release()
@@ -56,4 +62,21 @@ var _ = Describe("Buffer Pool", func() {
 		buf.Decrement()
 		Expect(func() { buf.Decrement() }).To(Panic())
 	})
+
+	It("releases the buffer of a coalesced datagram once all segments have been released", func() {
+		parent := getGROBuffer()
+		Expect(parent.Data).To(HaveCap(protocol.MaxGROBufferSize))
+		parent.Data = parent.Data[:300]
+		parent.segments.Store(2)
+		seg1 := getSegmentBuffer(parent, parent.Data[:200])
+		seg2 := getSegmentBuffer(parent, parent.Data[200:])
+		seg1.Split()
+		seg1.Decrement()
+		seg1.MaybeRelease()
+		Expect(parent.segments.Load()).To(BeEquivalentTo(2))
+		seg1.Release()
+		Expect(parent.segments.Load()).To(BeEquivalentTo(1))
+		seg2.Release()
+		Expect(parent.segments.Load()).To(BeZero())
+	})
 })
@@ -40,6 +40,24 @@ func validateConfig(config *Config) error {
 	if config.MaxConnectionReceiveWindow > quicvarint.Max {
 		config.MaxConnectionReceiveWindow = quicvarint.Max
 	}
+	if config.MaxPacketSize != 0 && (config.MaxPacketSize < protocol.MinInitialPacketSize || config.MaxPacketSize > protocol.MaxLargePacketBufferSize) {
+		return fmt.Errorf("invalid max packet size: %d", config.MaxPacketSize)
+	}
+	if config.InitialPacketSize != 0 {
+		if config.InitialPacketSize < protocol.MinInitialPacketSize {
+			return fmt.Errorf("invalid initial packet size: %d", config.InitialPacketSize)
+		}
+		maxPacketSize := config.MaxPacketSize
+		if maxPacketSize == 0 {
+			maxPacketSize = protocol.MaxPacketBufferSize
+		}
+		if config.InitialPacketSize > maxPacketSize {
+			return fmt.Errorf("initial packet size (%d) larger than the max packet size (%d)", config.InitialPacketSize, maxPacketSize)
+		}
+	}
+	if err := validateDSCP(config.DSCP); err != nil {
+		return err
+	}
 	// check that all QUIC versions are actually supported
 	for _, v := range config.Versions {
 		if !protocol.IsValidVersion(v) {
@@ -99,6 +117,10 @@ func populateConfig(config *Config) *Config {
 	} else if maxIncomingStreams < 0 {
 		maxIncomingStreams = 0
 	}
+	maxPacketSize := config.MaxPacketSize
+	if maxPacketSize == 0 {
+		maxPacketSize = protocol.MaxPacketBufferSize
+	}
 	maxIncomingUniStreams := config.MaxIncomingUniStreams
 	if maxIncomingUniStreams == 0 {
 		maxIncomingUniStreams = protocol.DefaultMaxIncomingUniStreams
@@ -123,7 +145,13 @@ func populateConfig(config *Config) *Config {
 		TokenStore:                     config.TokenStore,
 		EnableDatagrams:                config.EnableDatagrams,
 		DisablePathMTUDiscovery:        config.DisablePathMTUDiscovery,
+		InitialPacketSize:              config.InitialPacketSize,
+		MaxPacketSize:                  maxPacketSize,
+		DSCP:                           config.DSCP,
 		Allow0RTT:                      config.Allow0RTT,
 		Tracer:                         config.Tracer,
+		clock:                          config.clock,
+		workerPool:                     config.workerPool,
+		memoryBudget:                   config.memoryBudget,
 	}
 }
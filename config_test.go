@@ -51,6 +51,22 @@ var _ = Describe("Config", func() {
 			Expect(conf.MaxStreamReceiveWindow).To(BeEquivalentTo(uint64(quicvarint.Max)))
 			Expect(conf.MaxConnectionReceiveWindow).To(BeEquivalentTo(uint64(quicvarint.Max)))
 		})
+
+		It("validates the packet sizes", func() {
+			Expect(validateConfig(&Config{InitialPacketSize: 1300, MaxPacketSize: 9000})).To(Succeed())
+			Expect(validateConfig(&Config{InitialPacketSize: 1452})).To(Succeed())
+			Expect(validateConfig(&Config{MaxPacketSize: 1199})).To(MatchError("invalid max packet size: 1199"))
+			Expect(validateConfig(&Config{MaxPacketSize: 20481})).To(MatchError("invalid max packet size: 20481"))
+			Expect(validateConfig(&Config{InitialPacketSize: 1199})).To(MatchError("invalid initial packet size: 1199"))
+			Expect(validateConfig(&Config{InitialPacketSize: 1453})).To(MatchError("initial packet size (1453) larger than the max packet size (1452)"))
+			Expect(validateConfig(&Config{InitialPacketSize: 1400, MaxPacketSize: 1300})).To(MatchError("initial packet size (1400) larger than the max packet size (1300)"))
+		})
+
+		It("validates the DSCP", func() {
+			Expect(validateConfig(&Config{DSCP: 46})).To(Succeed())
+			Expect(validateConfig(&Config{DSCP: 63})).To(Succeed())
+			Expect(validateConfig(&Config{DSCP: 64})).To(MatchError("invalid DSCP: 64"))
+		})
 	})
 
 	configWithNonZeroNonFunctionFields := func() *Config {
@@ -102,6 +118,12 @@ var _ = Describe("Config", func() {
 				f.Set(reflect.ValueOf(true))
 			case "DisablePathMTUDiscovery":
 				f.Set(reflect.ValueOf(true))
+			case "InitialPacketSize":
+				f.Set(reflect.ValueOf(uint16(1300)))
+			case "MaxPacketSize":
+				f.Set(reflect.ValueOf(uint16(9000)))
+			case "DSCP":
+				f.Set(reflect.ValueOf(uint8(46)))
 			case "Allow0RTT":
 				f.Set(reflect.ValueOf(true))
 			default:
@@ -184,6 +206,8 @@ var _ = Describe("Config", func() {
 			Expect(c.MaxIncomingStreams).To(BeEquivalentTo(protocol.DefaultMaxIncomingStreams))
 			Expect(c.MaxIncomingUniStreams).To(BeEquivalentTo(protocol.DefaultMaxIncomingUniStreams))
 			Expect(c.DisablePathMTUDiscovery).To(BeFalse())
+			Expect(c.InitialPacketSize).To(BeZero())
+			Expect(c.MaxPacketSize).To(BeEquivalentTo(protocol.MaxPacketBufferSize))
 			Expect(c.GetConfigForClient).To(BeNil())
 		})
 
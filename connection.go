@@ -139,6 +139,7 @@ type connection struct {
 	connIDGenerator *connIDGenerator
 
 	rttStats *utils.RTTStats
+	clock    utils.Clock
 
 	cryptoStreamManager   *cryptoStreamManager
 	sentPacketHandler     ackhandler.SentPacketHandler
@@ -147,8 +148,9 @@ type connection struct {
 	framer                framer
 	windowUpdateQueue     *windowUpdateQueue
 	connFlowController    flowcontrol.ConnectionFlowController
-	tokenStoreKey         string                    // only set for the client
-	tokenGenerator        *handshake.TokenGenerator // only set for the server
+	memoryAccount         *flowcontrol.MemoryAccount // only set if the Transport uses a memory budget
+	tokenStoreKey         string                     // only set for the client
+	tokenGenerator        *handshake.TokenGenerator  // only set for the server
 
 	unpacker      unpacker
 	frameParser   wire.FrameParser
@@ -160,8 +162,16 @@ type connection struct {
 	oneRTTStream        cryptoStream // only set for the server
 	cryptoStreamHandler cryptoStreamHandler
 
-	receivedPackets  chan receivedPacket
-	sendingScheduled chan struct{}
+	receivedPackets    chan receivedPacket
+	sendingScheduled   chan struct{}
+	sendQueueAvailable <-chan struct{}
+	// a packet that was split off a GSO batch, since it needs a different DSCP marking,
+	// but that couldn't be sent since the send queue was full
+	unsentPacket queueEntry
+
+	// only used if the connection is driven by a worker pool
+	poolEntry      workerPoolEntry
+	runLoopStarted bool
 
 	closeOnce sync.Once
 	// closeChan is used to notify the run loop that it should terminate
@@ -204,9 +214,14 @@ type connection struct {
 
 	datagramQueue *datagramQueue
 
+	// the DSCP that packets are marked with, can be changed using SetDSCP
+	dscp atomic.Uint32
+
 	connStateMutex sync.Mutex
 	connState      ConnectionState
 
+	stats connectionStats
+
 	logID  string
 	tracer *logging.ConnectionTracer
 	logger utils.Logger
@@ -273,17 +288,26 @@ var newConnection = func(
 	)
 	s.preSetup()
 	s.ctx, s.ctxCancel = context.WithCancelCause(context.WithValue(context.Background(), ConnectionTracingKey, tracingID))
+	initialPacketSize := getInitialPacketSize(s.conn.RemoteAddr(), s.config)
+	s.mtuDiscoverer = newMTUDiscoverer(
+		s.rttStats,
+		s.clock,
+		initialPacketSize,
+		func(size protocol.ByteCount) { s.sentPacketHandler.SetMaxDatagramSize(size) },
+		s.tracer,
+	)
 	s.sentPacketHandler, s.receivedPacketHandler = ackhandler.NewAckHandler(
 		0,
-		getMaxPacketSize(s.conn.RemoteAddr()),
+		initialPacketSize,
 		s.rttStats,
+		s.clock,
 		clientAddressValidated,
 		s.conn.capabilities().ECN,
 		s.perspective,
+		s.mtuDiscoverer,
 		s.tracer,
 		s.logger,
 	)
-	s.mtuDiscoverer = newMTUDiscoverer(s.rttStats, getMaxPacketSize(s.conn.RemoteAddr()), s.sentPacketHandler.SetMaxDatagramSize)
 	params := &wire.TransportParameters{
 		InitialMaxStreamDataBidiLocal:   protocol.ByteCount(s.config.InitialStreamReceiveWindow),
 		InitialMaxStreamDataBidiRemote:  protocol.ByteCount(s.config.InitialStreamReceiveWindow),
@@ -294,6 +318,7 @@ var newConnection = func(
 		MaxUniStreamNum:                 protocol.StreamNum(s.config.MaxIncomingUniStreams),
 		MaxAckDelay:                     protocol.MaxAckDelayInclGranularity,
 		AckDelayExponent:                protocol.AckDelayExponent,
+		MaxUDPPayloadSize:               protocol.ByteCount(s.config.MaxPacketSize),
 		DisableActiveMigration:          true,
 		StatelessResetToken:             &statelessResetToken,
 		OriginalDestinationConnectionID: origDestConnID,
@@ -382,17 +407,26 @@ var newClientConnection = func(
 	)
 	s.preSetup()
 	s.ctx, s.ctxCancel = context.WithCancelCause(context.WithValue(context.Background(), ConnectionTracingKey, tracingID))
+	initialPacketSize := getInitialPacketSize(s.conn.RemoteAddr(), s.config)
+	s.mtuDiscoverer = newMTUDiscoverer(
+		s.rttStats,
+		s.clock,
+		initialPacketSize,
+		func(size protocol.ByteCount) { s.sentPacketHandler.SetMaxDatagramSize(size) },
+		s.tracer,
+	)
 	s.sentPacketHandler, s.receivedPacketHandler = ackhandler.NewAckHandler(
 		initialPacketNumber,
-		getMaxPacketSize(s.conn.RemoteAddr()),
+		initialPacketSize,
 		s.rttStats,
+		s.clock,
 		false, // has no effect
 		s.conn.capabilities().ECN,
 		s.perspective,
+		s.mtuDiscoverer,
 		s.tracer,
 		s.logger,
 	)
-	s.mtuDiscoverer = newMTUDiscoverer(s.rttStats, getMaxPacketSize(s.conn.RemoteAddr()), s.sentPacketHandler.SetMaxDatagramSize)
 	oneRTTStream := newCryptoStream()
 	params := &wire.TransportParameters{
 		InitialMaxStreamDataBidiRemote: protocol.ByteCount(s.config.InitialStreamReceiveWindow),
@@ -404,6 +438,7 @@ var newClientConnection = func(
 		MaxUniStreamNum:                protocol.StreamNum(s.config.MaxIncomingUniStreams),
 		MaxAckDelay:                    protocol.MaxAckDelayInclGranularity,
 		AckDelayExponent:               protocol.AckDelayExponent,
+		MaxUDPPayloadSize:              protocol.ByteCount(s.config.MaxPacketSize),
 		DisableActiveMigration:         true,
 		// For interoperability with quic-go versions before May 2023, this value must be set to a value
 		// different from protocol.DefaultActiveConnectionIDLimit.
@@ -451,10 +486,23 @@ var newClientConnection = func(
 func (s *connection) preSetup() {
 	s.initialStream = newCryptoStream()
 	s.handshakeStream = newCryptoStream()
-	s.sendQueue = newSendQueue(s.conn)
+	if s.config.workerPool != nil {
+		s.poolEntry.pool = s.config.workerPool
+		s.poolEntry.conn = s
+		s.sendQueue = newSyncSender(s.conn, s.destroyImpl)
+	} else {
+		s.sendQueue = newSendQueue(s.conn)
+	}
 	s.retransmissionQueue = newRetransmissionQueue()
 	s.frameParser = wire.NewFrameParser(s.config.EnableDatagrams)
 	s.rttStats = &utils.RTTStats{}
+	s.clock = s.config.clock
+	if s.clock == nil {
+		s.clock = utils.DefaultClock{}
+	}
+	if s.config.memoryBudget != nil {
+		s.memoryAccount = s.config.memoryBudget.NewAccount()
+	}
 	s.connFlowController = flowcontrol.NewConnectionFlowController(
 		protocol.ByteCount(s.config.InitialConnectionReceiveWindow),
 		protocol.ByteCount(s.config.MaxConnectionReceiveWindow),
@@ -465,6 +513,7 @@ func (s *connection) preSetup() {
 			}
 			return s.config.AllowConnectionWindowIncrease(s, uint64(size))
 		},
+		s.memoryAccount,
 		s.rttStats,
 		s.logger,
 	)
@@ -482,12 +531,13 @@ func (s *connection) preSetup() {
 	s.sendingScheduled = make(chan struct{}, 1)
 	s.handshakeCtx, s.handshakeCtxCancel = context.WithCancel(context.Background())
 
-	now := time.Now()
+	now := s.clock.Now()
 	s.lastPacketReceivedTime = now
 	s.creationTime = now
 
 	s.windowUpdateQueue = newWindowUpdateQueue(s.streamsMap, s.connFlowController, s.framer.QueueControlFrame)
 	s.datagramQueue = newDatagramQueue(s.scheduleSending, s.logger)
+	s.dscp.Store(uint32(s.config.DSCP))
 	s.connState.Version = s.version
 }
 
@@ -495,15 +545,13 @@ func (s *connection) preSetup() {
 func (s *connection) run() error {
 	var closeErr closeError
 	defer func() {
+		s.releaseMemory()
 		s.ctxCancel(closeErr.err)
 	}()
 
-	s.timer = *newTimer()
+	s.timer = *newTimer(s.clock)
 
-	if err := s.cryptoStreamHandler.StartHandshake(); err != nil {
-		return err
-	}
-	if err := s.handleHandshakeEvents(); err != nil {
+	if err := s.startRunLoop(); err != nil {
 		return err
 	}
 	go func() {
@@ -512,44 +560,123 @@ func (s *connection) run() error {
 		}
 	}()
 
+	for {
+		var closed bool
+		if closeErr, closed, _ = s.runLoopIteration(true); closed {
+			break
+		}
+	}
+	s.stopRunLoop(&closeErr)
+	return closeErr.err
+}
+
+// runInWorkerPool starts running the connection on the worker pool.
+// It returns immediately.
+// The connection must have been created with a Config that has a worker pool set.
+func (s *connection) runInWorkerPool() {
+	s.poolEntry.pool.add(&s.poolEntry)
+}
+
+// runStep is called by the worker pool when the connection is scheduled.
+// It processes all pending events, but never blocks.
+func (s *connection) runStep() (done bool) {
+	if !s.runLoopStarted {
+		s.runLoopStarted = true
+		s.timer = *newTimer(&workerPoolClock{Clock: s.clock, entry: &s.poolEntry})
+		if err := s.startRunLoop(); err != nil {
+			s.releaseMemory()
+			s.ctxCancel(err)
+			return true
+		}
+	}
+
+	for i := 0; i < maxPoolConnSteps; i++ {
+		closeErr, closed, idle := s.runLoopIteration(false)
+		if closed {
+			s.stopRunLoop(&closeErr)
+			s.releaseMemory()
+			s.ctxCancel(closeErr.err)
+			return true
+		}
+		if idle {
+			return false
+		}
+	}
+	// Yield to other connections, and continue processing events when we're scheduled again.
+	s.poolEntry.schedule()
+	return false
+}
+
+// releaseMemory returns the memory reserved for the receive window to the Transport's memory budget.
+func (s *connection) releaseMemory() {
+	if s.memoryAccount != nil {
+		s.memoryAccount.Close()
+	}
+}
+
+// notifyWorkerPool schedules the connection on the worker pool, if the connection is driven by one.
+// It needs to be called for every event that the run loop waits for.
+func (s *connection) notifyWorkerPool() {
+	if s.poolEntry.pool != nil {
+		s.poolEntry.schedule()
+	}
+}
+
+func (s *connection) startRunLoop() error {
+	if err := s.cryptoStreamHandler.StartHandshake(); err != nil {
+		return err
+	}
+	if err := s.handleHandshakeEvents(); err != nil {
+		return err
+	}
 	if s.perspective == protocol.PerspectiveClient {
 		s.scheduleSending() // so the ClientHello actually gets sent
 	}
+	return nil
+}
 
-	var sendQueueAvailable <-chan struct{}
+// checkCloseRequested checks if the connection was requested to close.
+func (s *connection) checkCloseRequested() (closeError, bool) {
+	select {
+	case closeErr := <-s.closeChan:
+		return closeErr, true
+	default:
+		return closeError{}, false
+	}
+}
 
-runLoop:
-	for {
-		// Close immediately if requested
-		select {
-		case closeErr = <-s.closeChan:
-			break runLoop
-		default:
-		}
-
-		s.maybeResetTimer()
-
-		var processedUndecryptablePacket bool
-		if len(s.undecryptablePacketsToProcess) > 0 {
-			queue := s.undecryptablePacketsToProcess
-			s.undecryptablePacketsToProcess = nil
-			for _, p := range queue {
-				if processed := s.handlePacketImpl(p); processed {
-					processedUndecryptablePacket = true
-				}
-				// Don't set timers and send packets if the packet made us close the connection.
-				select {
-				case closeErr = <-s.closeChan:
-					break runLoop
-				default:
-				}
+// runLoopIteration waits for the next event and processes it.
+// If wait is false, it doesn't block if no event occurred, and returns idle instead.
+func (s *connection) runLoopIteration(wait bool) (closeErr closeError, closed, idle bool) {
+	// Close immediately if requested
+	if closeErr, closed = s.checkCloseRequested(); closed {
+		return closeErr, true, false
+	}
+
+	s.maybeResetTimer()
+
+	var processedUndecryptablePacket bool
+	if len(s.undecryptablePacketsToProcess) > 0 {
+		queue := s.undecryptablePacketsToProcess
+		s.undecryptablePacketsToProcess = nil
+		for _, p := range queue {
+			if processed := s.handlePacketImpl(p); processed {
+				processedUndecryptablePacket = true
+			}
+			// Don't set timers and send packets if the packet made us close the connection.
+			if closeErr, closed = s.checkCloseRequested(); closed {
+				return closeErr, true, false
 			}
 		}
-		// If we processed any undecryptable packets, jump to the resetting of the timers directly.
-		if !processedUndecryptablePacket {
+	}
+	// If we processed any undecryptable packets, jump to the resetting of the timers directly.
+	if !processedUndecryptablePacket {
+		var firstPacket receivedPacket
+		var gotPacket bool
+		if wait {
 			select {
 			case closeErr = <-s.closeChan:
-				break runLoop
+				return closeErr, true, false
 			case <-s.timer.Chan():
 				s.timer.SetRead()
 				// We do all the interesting stuff after the switch statement, so
@@ -557,91 +684,106 @@ runLoop:
 			case <-s.sendingScheduled:
 				// We do all the interesting stuff after the switch statement, so
 				// nothing to see here.
-			case <-sendQueueAvailable:
-			case firstPacket := <-s.receivedPackets:
-				wasProcessed := s.handlePacketImpl(firstPacket)
-				// Don't set timers and send packets if the packet made us close the connection.
-				select {
-				case closeErr = <-s.closeChan:
-					break runLoop
-				default:
-				}
-				if s.handshakeComplete {
-					// Now process all packets in the receivedPackets channel.
-					// Limit the number of packets to the length of the receivedPackets channel,
-					// so we eventually get a chance to send out an ACK when receiving a lot of packets.
-					numPackets := len(s.receivedPackets)
-				receiveLoop:
-					for i := 0; i < numPackets; i++ {
-						select {
-						case p := <-s.receivedPackets:
-							if processed := s.handlePacketImpl(p); processed {
-								wasProcessed = true
-							}
-							select {
-							case closeErr = <-s.closeChan:
-								break runLoop
-							default:
-							}
-						default:
-							break receiveLoop
+			case <-s.sendQueueAvailable:
+			case firstPacket = <-s.receivedPackets:
+				gotPacket = true
+			}
+		} else {
+			select {
+			case closeErr = <-s.closeChan:
+				return closeErr, true, false
+			case <-s.timer.Chan():
+				s.timer.SetRead()
+			case <-s.sendingScheduled:
+			case <-s.sendQueueAvailable:
+			case firstPacket = <-s.receivedPackets:
+				gotPacket = true
+			default:
+				return closeError{}, false, true
+			}
+		}
+		if gotPacket {
+			wasProcessed := s.handlePacketImpl(firstPacket)
+			// Don't set timers and send packets if the packet made us close the connection.
+			if closeErr, closed = s.checkCloseRequested(); closed {
+				return closeErr, true, false
+			}
+			if s.handshakeComplete {
+				// Now process all packets in the receivedPackets channel.
+				// Limit the number of packets to the length of the receivedPackets channel,
+				// so we eventually get a chance to send out an ACK when receiving a lot of packets.
+				numPackets := len(s.receivedPackets)
+			receiveLoop:
+				for i := 0; i < numPackets; i++ {
+					select {
+					case p := <-s.receivedPackets:
+						if processed := s.handlePacketImpl(p); processed {
+							wasProcessed = true
 						}
+						if closeErr, closed = s.checkCloseRequested(); closed {
+							return closeErr, true, false
+						}
+					default:
+						break receiveLoop
 					}
 				}
-				// Only reset the timers if this packet was actually processed.
-				// This avoids modifying any state when handling undecryptable packets,
-				// which could be injected by an attacker.
-				if !wasProcessed {
-					continue
-				}
 			}
-		}
-
-		now := time.Now()
-		if timeout := s.sentPacketHandler.GetLossDetectionTimeout(); !timeout.IsZero() && timeout.Before(now) {
-			// This could cause packets to be retransmitted.
-			// Check it before trying to send packets.
-			if err := s.sentPacketHandler.OnLossDetectionTimeout(); err != nil {
-				s.closeLocal(err)
-			}
-		}
-
-		if keepAliveTime := s.nextKeepAliveTime(); !keepAliveTime.IsZero() && !now.Before(keepAliveTime) {
-			// send a PING frame since there is no activity in the connection
-			s.logger.Debugf("Sending a keep-alive PING to keep the connection alive.")
-			s.framer.QueueControlFrame(&wire.PingFrame{})
-			s.keepAlivePingSent = true
-		} else if !s.handshakeComplete && now.Sub(s.creationTime) >= s.config.handshakeTimeout() {
-			s.destroyImpl(qerr.ErrHandshakeTimeout)
-			continue
-		} else {
-			idleTimeoutStartTime := s.idleTimeoutStartTime()
-			if (!s.handshakeComplete && now.Sub(idleTimeoutStartTime) >= s.config.HandshakeIdleTimeout) ||
-				(s.handshakeComplete && now.After(s.nextIdleTimeoutTime())) {
-				s.destroyImpl(qerr.ErrIdleTimeout)
-				continue
+			// Only reset the timers if this packet was actually processed.
+			// This avoids modifying any state when handling undecryptable packets,
+			// which could be injected by an attacker.
+			if !wasProcessed {
+				return closeError{}, false, false
 			}
 		}
+	}
 
-		if s.sendQueue.WouldBlock() {
-			// The send queue is still busy sending out packets.
-			// Wait until there's space to enqueue new packets.
-			sendQueueAvailable = s.sendQueue.Available()
-			continue
-		}
-		if err := s.triggerSending(now); err != nil {
+	now := s.clock.Now()
+	if timeout := s.sentPacketHandler.GetLossDetectionTimeout(); !timeout.IsZero() && timeout.Before(now) {
+		// This could cause packets to be retransmitted.
+		// Check it before trying to send packets.
+		if err := s.sentPacketHandler.OnLossDetectionTimeout(); err != nil {
 			s.closeLocal(err)
 		}
-		if s.sendQueue.WouldBlock() {
-			sendQueueAvailable = s.sendQueue.Available()
-		} else {
-			sendQueueAvailable = nil
+	}
+
+	if keepAliveTime := s.nextKeepAliveTime(); !keepAliveTime.IsZero() && !now.Before(keepAliveTime) {
+		// send a PING frame since there is no activity in the connection
+		s.logger.Debugf("Sending a keep-alive PING to keep the connection alive.")
+		s.framer.QueueControlFrame(&wire.PingFrame{})
+		s.keepAlivePingSent = true
+	} else if !s.handshakeComplete && now.Sub(s.creationTime) >= s.config.handshakeTimeout() {
+		s.destroyImpl(qerr.ErrHandshakeTimeout)
+		return closeError{}, false, false
+	} else {
+		idleTimeoutStartTime := s.idleTimeoutStartTime()
+		if (!s.handshakeComplete && now.Sub(idleTimeoutStartTime) >= s.config.HandshakeIdleTimeout) ||
+			(s.handshakeComplete && now.After(s.nextIdleTimeoutTime())) {
+			s.destroyImpl(qerr.ErrIdleTimeout)
+			return closeError{}, false, false
 		}
 	}
 
+	if s.sendQueue.WouldBlock() {
+		// The send queue is still busy sending out packets.
+		// Wait until there's space to enqueue new packets.
+		s.sendQueueAvailable = s.sendQueue.Available()
+		return closeError{}, false, false
+	}
+	if err := s.triggerSending(now); err != nil {
+		s.closeLocal(err)
+	}
+	if s.sendQueue.WouldBlock() {
+		s.sendQueueAvailable = s.sendQueue.Available()
+	} else {
+		s.sendQueueAvailable = nil
+	}
+	return closeError{}, false, false
+}
+
+func (s *connection) stopRunLoop(closeErr *closeError) {
 	s.cryptoStreamHandler.Close()
 	s.sendQueue.Close() // close the send queue before sending the CONNECTION_CLOSE
-	s.handleCloseError(&closeErr)
+	s.handleCloseError(closeErr)
 	if s.tracer != nil && s.tracer.Close != nil {
 		if e := (&errCloseForRecreating{}); !errors.As(closeErr.err, &e) {
 			s.tracer.Close()
@@ -649,7 +791,6 @@ runLoop:
 	}
 	s.logger.Infof("Connection %s closed.", s.logID)
 	s.timer.Stop()
-	return closeErr.err
 }
 
 // blocks until the early connection can be used
@@ -780,13 +921,14 @@ func (s *connection) handleHandshakeConfirmed() error {
 		if maxPacketSize == 0 {
 			maxPacketSize = protocol.MaxByteCount
 		}
-		s.mtuDiscoverer.Start(min(maxPacketSize, protocol.MaxPacketBufferSize))
+		s.mtuDiscoverer.Start(min(maxPacketSize, protocol.ByteCount(s.config.MaxPacketSize)))
 	}
 	return nil
 }
 
 func (s *connection) handlePacketImpl(rp receivedPacket) bool {
 	s.sentPacketHandler.ReceivedBytes(rp.Size())
+	s.stats.bytesReceived.Add(uint64(rp.Size()))
 
 	if wire.IsVersionNegotiationPacket(rp.data) {
 		s.handleVersionNegotiationPacket(rp)
@@ -858,6 +1000,7 @@ func (s *connection) handlePacketImpl(rp receivedPacket) bool {
 			p.data = packetData
 
 			if wasProcessed := s.handleLongHeaderPacket(p, hdr); wasProcessed {
+				s.stats.packetsReceived.Add(1)
 				processed = true
 			}
 			data = rest
@@ -866,6 +1009,9 @@ func (s *connection) handlePacketImpl(rp receivedPacket) bool {
 				p.buffer.Split()
 			}
 			processed = s.handleShortHeaderPacket(p, destConnID)
+			if processed {
+				s.stats.packetsReceived.Add(1)
+			}
 			break
 		}
 	}
@@ -1345,10 +1491,12 @@ func (s *connection) handlePacket(p receivedPacket) {
 	// the channel size, protocol.MaxConnUnprocessedPackets
 	select {
 	case s.receivedPackets <- p:
+		s.notifyWorkerPool()
 	default:
 		if s.tracer != nil && s.tracer.DroppedPacket != nil {
 			s.tracer.DroppedPacket(logging.PacketTypeNotDetermined, protocol.InvalidPacketNumber, p.Size(), logging.PacketDropDOSPrevention)
 		}
+		p.buffer.Release()
 	}
 }
 
@@ -1511,6 +1659,7 @@ func (s *connection) handleAckFrame(frame *wire.AckFrame, encLevel protocol.Encr
 	if err != nil {
 		return err
 	}
+	s.stats.updateRTT(s.rttStats)
 	if !acked1RTTPacket {
 		return nil
 	}
@@ -1545,6 +1694,7 @@ func (s *connection) closeLocal(e error) {
 			s.logger.Errorf("Closing connection with error: %s", e)
 		}
 		s.closeChan <- closeError{err: e, immediate: false, remote: false}
+		s.notifyWorkerPool()
 	})
 }
 
@@ -1562,6 +1712,7 @@ func (s *connection) destroyImpl(e error) {
 			s.logger.Errorf("Destroying connection with error: %s", e)
 		}
 		s.closeChan <- closeError{err: e, immediate: true, remote: false}
+		s.notifyWorkerPool()
 	})
 }
 
@@ -1569,6 +1720,7 @@ func (s *connection) closeRemote(e error) {
 	s.closeOnce.Do(func() {
 		s.logger.Errorf("Peer closed connection with error: %s", e)
 		s.closeChan <- closeError{err: e, immediate: true, remote: true}
+		s.notifyWorkerPool()
 	})
 }
 
@@ -1773,6 +1925,13 @@ func (s *connection) applyTransportParameters() {
 
 func (s *connection) triggerSending(now time.Time) error {
 	s.pacingDeadline = time.Time{}
+	if p := s.unsentPacket; p.buf != nil {
+		s.unsentPacket = queueEntry{}
+		s.sendQueue.Send(p.buf, p.gsoSize, p.ecn, p.dscp)
+		if s.sendQueue.WouldBlock() {
+			return nil
+		}
+	}
 
 	sendMode := s.sentPacketHandler.SendMode(now)
 	//nolint:exhaustive // No need to handle pacing limited here.
@@ -1842,7 +2001,7 @@ func (s *connection) sendPackets(now time.Time) error {
 		ecn := s.sentPacketHandler.ECNMode(true)
 		s.logShortHeaderPacket(p.DestConnID, p.Ack, p.Frames, p.StreamFrames, p.PacketNumber, p.PacketNumberLen, p.KeyPhase, ecn, buf.Len(), false)
 		s.registerPackedShortHeaderPacket(p, ecn, now)
-		s.sendQueue.Send(buf, 0, ecn)
+		s.sendQueue.Send(buf, 0, ecn, s.dscpFor(p.DSCP))
 		// This is kind of a hack. We need to trigger sending again somehow.
 		s.pacingDeadline = deadlineSendImmediately
 		return nil
@@ -1882,9 +2041,11 @@ func (s *connection) sendPackets(now time.Time) error {
 
 func (s *connection) sendPacketsWithoutGSO(now time.Time) error {
 	for {
-		buf := getPacketBuffer()
+		maxSize := s.mtuDiscoverer.CurrentSize()
+		buf := getPacketBufferForSize(maxSize)
 		ecn := s.sentPacketHandler.ECNMode(true)
-		if _, err := s.appendOneShortHeaderPacket(buf, s.mtuDiscoverer.CurrentSize(), ecn, now); err != nil {
+		_, dscp, err := s.appendOneShortHeaderPacket(buf, maxSize, ecn, now)
+		if err != nil {
 			if err == errNothingToPack {
 				buf.Release()
 				return nil
@@ -1892,7 +2053,7 @@ func (s *connection) sendPacketsWithoutGSO(now time.Time) error {
 			return err
 		}
 
-		s.sendQueue.Send(buf, 0, ecn)
+		s.sendQueue.Send(buf, 0, ecn, dscp)
 
 		if s.sendQueue.WouldBlock() {
 			return nil
@@ -1918,9 +2079,10 @@ func (s *connection) sendPacketsWithGSO(now time.Time) error {
 	maxSize := s.mtuDiscoverer.CurrentSize()
 
 	ecn := s.sentPacketHandler.ECNMode(true)
+	var dscp uint8
 	for {
 		var dontSendMore bool
-		size, err := s.appendOneShortHeaderPacket(buf, maxSize, ecn, now)
+		size, packetDSCP, err := s.appendOneShortHeaderPacket(buf, maxSize, ecn, now)
 		if err != nil {
 			if err != errNothingToPack {
 				return err
@@ -1930,6 +2092,23 @@ func (s *connection) sendPacketsWithGSO(now time.Time) error {
 				return nil
 			}
 			dontSendMore = true
+		} else if buf.Len() == size {
+			// The first packet determines the DSCP marking of the batch.
+			dscp = packetDSCP
+		} else if packetDSCP != dscp {
+			// This packet needs a different DSCP marking than the packets before it.
+			// Send out the batch without it, and start a new batch with it.
+			next := getLargePacketBuffer()
+			next.Data = append(next.Data, buf.Data[buf.Len()-size:]...)
+			buf.Data = buf.Data[:buf.Len()-size]
+			s.sendQueue.Send(buf, uint16(maxSize), ecn, dscp)
+			buf = next
+			dscp = packetDSCP
+			if s.sendQueue.WouldBlock() {
+				// The packet is sent the next time sending is triggered.
+				s.unsentPacket = queueEntry{buf: buf, gsoSize: uint16(maxSize), ecn: ecn, dscp: dscp}
+				return nil
+			}
 		}
 
 		if !dontSendMore {
@@ -1954,7 +2133,7 @@ func (s *connection) sendPacketsWithGSO(now time.Time) error {
 			continue
 		}
 
-		s.sendQueue.Send(buf, uint16(maxSize), ecn)
+		s.sendQueue.Send(buf, uint16(maxSize), ecn, dscp)
 
 		if dontSendMore {
 			return nil
@@ -2004,7 +2183,7 @@ func (s *connection) maybeSendAckOnlyPacket(now time.Time) error {
 	}
 	s.logShortHeaderPacket(p.DestConnID, p.Ack, p.Frames, p.StreamFrames, p.PacketNumber, p.PacketNumberLen, p.KeyPhase, ecn, buf.Len(), false)
 	s.registerPackedShortHeaderPacket(p, ecn, now)
-	s.sendQueue.Send(buf, 0, ecn)
+	s.sendQueue.Send(buf, 0, ecn, s.dscpFor(p.DSCP))
 	return nil
 }
 
@@ -2040,17 +2219,18 @@ func (s *connection) sendProbePacket(encLevel protocol.EncryptionLevel, now time
 }
 
 // appendOneShortHeaderPacket appends a new packet to the given packetBuffer.
+// It returns the size of the packet, and the DSCP it needs to be marked with.
 // If there was nothing to pack, the returned size is 0.
-func (s *connection) appendOneShortHeaderPacket(buf *packetBuffer, maxSize protocol.ByteCount, ecn protocol.ECN, now time.Time) (protocol.ByteCount, error) {
+func (s *connection) appendOneShortHeaderPacket(buf *packetBuffer, maxSize protocol.ByteCount, ecn protocol.ECN, now time.Time) (protocol.ByteCount, uint8, error) {
 	startLen := buf.Len()
 	p, err := s.packer.AppendPacket(buf, maxSize, s.version)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	size := buf.Len() - startLen
 	s.logShortHeaderPacket(p.DestConnID, p.Ack, p.Frames, p.StreamFrames, p.PacketNumber, p.PacketNumberLen, p.KeyPhase, ecn, size, false)
 	s.registerPackedShortHeaderPacket(p, ecn, now)
-	return size, nil
+	return size, s.dscpFor(p.DSCP), nil
 }
 
 func (s *connection) registerPackedShortHeaderPacket(p shortHeaderPacket, ecn protocol.ECN, now time.Time) {
@@ -2063,6 +2243,7 @@ func (s *connection) registerPackedShortHeaderPacket(p shortHeaderPacket, ecn pr
 		largestAcked = p.Ack.LargestAcked()
 	}
 	s.sentPacketHandler.SentPacket(now, p.PacketNumber, largestAcked, p.StreamFrames, p.Frames, protocol.Encryption1RTT, ecn, p.Length, p.IsPathMTUProbePacket)
+	s.stats.sentPacket(p.Length)
 	s.connIDManager.SentPacket()
 }
 
@@ -2077,6 +2258,7 @@ func (s *connection) sendPackedCoalescedPacket(packet *coalescedPacket, ecn prot
 			largestAcked = p.ack.LargestAcked()
 		}
 		s.sentPacketHandler.SentPacket(now, p.header.PacketNumber, largestAcked, p.streamFrames, p.frames, p.EncryptionLevel(), ecn, p.length, false)
+		s.stats.sentPacket(p.length)
 		if s.perspective == protocol.PerspectiveClient && p.EncryptionLevel() == protocol.EncryptionHandshake {
 			// On the client side, Initial keys are dropped as soon as the first Handshake packet is sent.
 			// See Section 4.9.1 of RFC 9001.
@@ -2094,9 +2276,14 @@ func (s *connection) sendPackedCoalescedPacket(packet *coalescedPacket, ecn prot
 			largestAcked = p.Ack.LargestAcked()
 		}
 		s.sentPacketHandler.SentPacket(now, p.PacketNumber, largestAcked, p.StreamFrames, p.Frames, protocol.Encryption1RTT, ecn, p.Length, p.IsPathMTUProbePacket)
+		s.stats.sentPacket(p.Length)
 	}
 	s.connIDManager.SentPacket()
-	s.sendQueue.Send(packet.buffer, 0, ecn)
+	class := dscpClassDefault
+	if packet.IsOnlyShortHeaderPacket() {
+		class = packet.shortHdrPacket.DSCP
+	}
+	s.sendQueue.Send(packet.buffer, 0, ecn, s.dscpFor(class))
 	return nil
 }
 
@@ -2120,7 +2307,7 @@ func (s *connection) sendConnectionClose(e error) ([]byte, error) {
 	}
 	ecn := s.sentPacketHandler.ECNMode(packet.IsOnlyShortHeaderPacket())
 	s.logCoalescedPacket(packet, ecn)
-	return packet.buffer.Data, s.conn.Write(packet.buffer.Data, 0, ecn)
+	return packet.buffer.Data, s.conn.Write(packet.buffer.Data, 0, ecn, s.dscpFor(dscpClassDefault))
 }
 
 func (s *connection) logLongHeaderPacket(p *longHeaderPacket, ecn protocol.ECN) {
@@ -2298,6 +2485,7 @@ func (s *connection) scheduleSending() {
 	case s.sendingScheduled <- struct{}{}:
 	default:
 	}
+	s.notifyWorkerPool()
 }
 
 // tryQueueingUndecryptablePacket queues a packet for which we're missing the decryption keys.
@@ -2306,7 +2494,15 @@ func (s *connection) tryQueueingUndecryptablePacket(p receivedPacket, pt logging
 	if s.handshakeComplete {
 		panic("shouldn't queue undecryptable packets after handshake completion")
 	}
-	if len(s.undecryptablePackets)+1 > protocol.MaxUndecryptablePackets {
+	maxUndecryptablePackets := protocol.MaxUndecryptablePackets
+	underMemoryPressure := s.config.memoryBudget != nil && s.config.memoryBudget.UnderPressure()
+	if underMemoryPressure {
+		maxUndecryptablePackets = protocol.MaxUndecryptablePacketsUnderMemoryPressure
+	}
+	if len(s.undecryptablePackets)+1 > maxUndecryptablePackets {
+		if underMemoryPressure && len(s.undecryptablePackets)+1 <= protocol.MaxUndecryptablePackets {
+			s.config.memoryBudget.DroppedPacket()
+		}
 		if s.tracer != nil && s.tracer.DroppedPacket != nil {
 			s.tracer.DroppedPacket(pt, protocol.InvalidPacketNumber, p.Size(), logging.PacketDropDOSPrevention)
 		}
@@ -2340,7 +2536,13 @@ func (s *connection) onHasStreamData(id protocol.StreamID) {
 	s.scheduleSending()
 }
 
+func (s *connection) onStreamPriorityChanged(id protocol.StreamID, prio streamPriority) {
+	s.framer.SetStreamPriority(id, prio)
+	s.scheduleSending()
+}
+
 func (s *connection) onStreamCompleted(id protocol.StreamID) {
+	s.framer.RemoveStream(id)
 	if err := s.streamsMap.DeleteStream(id); err != nil {
 		s.closeLocal(err)
 	}
@@ -2351,15 +2553,33 @@ func (s *connection) SendDatagram(p []byte) error {
 		return errors.New("datagram support disabled")
 	}
 
-	f := &wire.DatagramFrame{DataLenPresent: true}
+	f := wire.GetDatagramFrame()
+	f.DataLenPresent = true
 	if protocol.ByteCount(len(p)) > f.MaxDataLen(s.peerParams.MaxDatagramFrameSize, s.version) {
+		f.PutBack()
 		return &DatagramTooLargeError{
 			PeerMaxDatagramFrameSize: int64(s.peerParams.MaxDatagramFrameSize),
 		}
 	}
-	f.Data = make([]byte, len(p))
-	copy(f.Data, p)
-	return s.datagramQueue.Add(f)
+	f.Data = append(f.Data, p...)
+	if err := s.datagramQueue.Add(f); err != nil {
+		f.PutBack()
+		return err
+	}
+	return nil
+}
+
+func (s *connection) SetDSCP(dscp uint8) error {
+	if err := validateDSCP(dscp); err != nil {
+		return err
+	}
+	s.dscp.Store(uint32(dscp))
+	return nil
+}
+
+// dscpFor returns the DSCP that a packet of the given class is marked with.
+func (s *connection) dscpFor(c dscpClass) uint8 {
+	return c.resolve(uint8(s.dscp.Load()))
 }
 
 func (s *connection) ReceiveDatagram(ctx context.Context) ([]byte, error) {
@@ -2377,6 +2597,10 @@ func (s *connection) RemoteAddr() net.Addr {
 	return s.conn.RemoteAddr()
 }
 
+func (s *connection) connectionStats() ConnectionStats {
+	return s.stats.snapshot()
+}
+
 func (s *connection) getPerspective() protocol.Perspective {
 	return s.perspective
 }
@@ -0,0 +1,138 @@
+package quic
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/nxenon/xquic-go/internal/protocol"
+	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/logging"
+)
+
+// ConnectionStatus is the status of a QUIC connection.
+type ConnectionStatus uint8
+
+const (
+	// ConnectionStatusHandshaking means that the handshake is still in progress.
+	ConnectionStatusHandshaking ConnectionStatus = iota
+	// ConnectionStatusEstablished means that the handshake has completed.
+	ConnectionStatusEstablished
+	// ConnectionStatusClosed means that the connection is being closed.
+	ConnectionStatusClosed
+)
+
+func (s ConnectionStatus) String() string {
+	switch s {
+	case ConnectionStatusHandshaking:
+		return "handshaking"
+	case ConnectionStatusEstablished:
+		return "established"
+	case ConnectionStatusClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectionStats is a snapshot of statistics collected for a QUIC connection.
+type ConnectionStats struct {
+	// MinRTT, LatestRTT and SmoothedRTT are the RTT estimates, as defined in Section 5 of RFC 9002.
+	// They are 0 until the first RTT sample has been obtained.
+	MinRTT      time.Duration
+	LatestRTT   time.Duration
+	SmoothedRTT time.Duration
+
+	// BytesSent and PacketsSent count the QUIC packets sent on the connection.
+	BytesSent   uint64
+	PacketsSent uint64
+	// BytesReceived counts all bytes received for this connection.
+	// PacketsReceived only counts the QUIC packets that were successfully processed.
+	BytesReceived   uint64
+	PacketsReceived uint64
+}
+
+// ConnectionInfo is a snapshot of a connection handled by a Transport.
+type ConnectionInfo struct {
+	// Connection is the connection itself.
+	// It can be used to close the connection.
+	Connection Connection
+
+	Perspective logging.Perspective
+	// ConnectionIDs are the connection IDs that the Transport currently routes to this connection.
+	// This includes connection IDs that were retired recently.
+	ConnectionIDs []ConnectionID
+	LocalAddr     net.Addr
+	RemoteAddr    net.Addr
+	Status        ConnectionStatus
+	Version       VersionNumber
+	// ALPN is the application protocol negotiated in the TLS handshake.
+	// It is empty until the handshake completes.
+	ALPN  string
+	Stats ConnectionStats
+}
+
+// connectionStats is updated by the run loop of the connection,
+// and can be read concurrently.
+type connectionStats struct {
+	minRTT      atomic.Int64
+	latestRTT   atomic.Int64
+	smoothedRTT atomic.Int64
+
+	bytesSent       atomic.Uint64
+	packetsSent     atomic.Uint64
+	bytesReceived   atomic.Uint64
+	packetsReceived atomic.Uint64
+}
+
+func (s *connectionStats) sentPacket(size protocol.ByteCount) {
+	s.bytesSent.Add(uint64(size))
+	s.packetsSent.Add(1)
+}
+
+func (s *connectionStats) updateRTT(rttStats *utils.RTTStats) {
+	s.minRTT.Store(int64(rttStats.MinRTT()))
+	s.latestRTT.Store(int64(rttStats.LatestRTT()))
+	s.smoothedRTT.Store(int64(rttStats.SmoothedRTT()))
+}
+
+func (s *connectionStats) snapshot() ConnectionStats {
+	return ConnectionStats{
+		MinRTT:          time.Duration(s.minRTT.Load()),
+		LatestRTT:       time.Duration(s.latestRTT.Load()),
+		SmoothedRTT:     time.Duration(s.smoothedRTT.Load()),
+		BytesSent:       s.bytesSent.Load(),
+		PacketsSent:     s.packetsSent.Load(),
+		BytesReceived:   s.bytesReceived.Load(),
+		PacketsReceived: s.packetsReceived.Load(),
+	}
+}
+
+func newConnectionInfo(conn quicConn, connIDs []ConnectionID) ConnectionInfo {
+	sort.Slice(connIDs, func(i, j int) bool { return bytes.Compare(connIDs[i].Bytes(), connIDs[j].Bytes()) < 0 })
+	status := ConnectionStatusHandshaking
+	select {
+	case <-conn.Context().Done():
+		status = ConnectionStatusClosed
+	default:
+		select {
+		case <-conn.HandshakeComplete():
+			status = ConnectionStatusEstablished
+		default:
+		}
+	}
+	state := conn.ConnectionState()
+	return ConnectionInfo{
+		Connection:    conn,
+		Perspective:   conn.getPerspective(),
+		ConnectionIDs: connIDs,
+		LocalAddr:     conn.LocalAddr(),
+		RemoteAddr:    conn.RemoteAddr(),
+		Status:        status,
+		Version:       state.Version,
+		ALPN:          state.TLS.NegotiatedProtocol,
+		Stats:         conn.connectionStats(),
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 
+	"github.com/nxenon/xquic-go/internal/ackhandler"
 	"github.com/nxenon/xquic-go/internal/utils"
 	"github.com/nxenon/xquic-go/internal/utils/ringbuffer"
 	"github.com/nxenon/xquic-go/internal/wire"
@@ -89,6 +90,15 @@ func (h *datagramQueue) Pop() {
 	}
 }
 
+// datagramFrameHandler returns DATAGRAM frames to the pool once the packet they were sent in
+// was acknowledged or declared lost. DATAGRAM frames are never retransmitted.
+type datagramFrameHandler struct{}
+
+var _ ackhandler.FrameHandler = datagramFrameHandler{}
+
+func (datagramFrameHandler) OnAcked(f wire.Frame) { f.(*wire.DatagramFrame).PutBack() }
+func (datagramFrameHandler) OnLost(f wire.Frame)  { f.(*wire.DatagramFrame).PutBack() }
+
 // HandleDatagramFrame handles a received DATAGRAM frame.
 func (h *datagramQueue) HandleDatagramFrame(f *wire.DatagramFrame) {
 	data := make([]byte, len(f.Data))
@@ -0,0 +1,32 @@
+package quic
+
+import "fmt"
+
+// maxDSCP is the largest Differentiated Services Code Point.
+// The DSCP occupies the upper 6 bits of the IPv4 TOS / IPv6 Traffic Class field.
+const maxDSCP = 63
+
+func validateDSCP(dscp uint8) error {
+	if dscp > maxDSCP {
+		return fmt.Errorf("invalid DSCP: %d", dscp)
+	}
+	return nil
+}
+
+// A dscpClass is the DSCP that the data of a stream is sent with.
+// Data of streams with a different class is never sent in the same packet.
+// The zero value is the class of streams that don't have a DSCP set:
+// packets carrying their data are marked with the DSCP of the connection.
+type dscpClass uint8
+
+const dscpClassDefault dscpClass = 0
+
+func newDSCPClass(dscp uint8) dscpClass { return dscpClass(dscp + 1) }
+
+// resolve returns the DSCP that a packet of this class is marked with.
+func (c dscpClass) resolve(connDSCP uint8) uint8 {
+	if c == dscpClassDefault {
+		return connDSCP
+	}
+	return uint8(c - 1)
+}
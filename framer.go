@@ -18,7 +18,10 @@ type framer interface {
 	AppendControlFrames([]ackhandler.Frame, protocol.ByteCount, protocol.VersionNumber) ([]ackhandler.Frame, protocol.ByteCount)
 
 	AddActiveStream(protocol.StreamID)
-	AppendStreamFrames([]ackhandler.StreamFrame, protocol.ByteCount, protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount)
+	SetStreamPriority(protocol.StreamID, streamPriority)
+	// RemoveStream is called when a stream is completed.
+	RemoveStream(protocol.StreamID)
+	AppendStreamFrames([]ackhandler.StreamFrame, protocol.ByteCount, protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount, dscpClass)
 
 	Handle0RTTRejection() error
 }
@@ -30,8 +33,14 @@ type framerI struct {
 
 	streamGetter streamGetter
 
-	activeStreams map[protocol.StreamID]struct{}
-	streamQueue   ringbuffer.RingBuffer[protocol.StreamID]
+	// activeStreams maps active streams to the urgency of the queue they're scheduled in
+	activeStreams map[protocol.StreamID]uint8
+	streamQueues  [maxUrgency + 1]ringbuffer.RingBuffer[protocol.StreamID]
+	// priorities of the streams that don't use the default priority
+	priorities map[protocol.StreamID]streamPriority
+	// streams skipped by AppendStreamFrames, since they have a different DSCP class.
+	// Only used in AppendStreamFrames, it's a field to avoid allocating.
+	skippedStreams []protocol.StreamID
 
 	controlFrameMutex sync.Mutex
 	controlFrames     []wire.Frame
@@ -43,13 +52,14 @@ var _ framer = &framerI{}
 func newFramer(streamGetter streamGetter) framer {
 	return &framerI{
 		streamGetter:  streamGetter,
-		activeStreams: make(map[protocol.StreamID]struct{}),
+		activeStreams: make(map[protocol.StreamID]uint8),
+		priorities:    make(map[protocol.StreamID]streamPriority),
 	}
 }
 
 func (f *framerI) HasData() bool {
 	f.mutex.Lock()
-	hasData := !f.streamQueue.Empty()
+	hasData := len(f.activeStreams) > 0
 	f.mutex.Unlock()
 	if hasData {
 		return true
@@ -108,50 +118,111 @@ func (f *framerI) AppendControlFrames(frames []ackhandler.Frame, maxLen protocol
 func (f *framerI) AddActiveStream(id protocol.StreamID) {
 	f.mutex.Lock()
 	if _, ok := f.activeStreams[id]; !ok {
-		f.streamQueue.PushBack(id)
-		f.activeStreams[id] = struct{}{}
+		urgency := f.priorities[id].urgency()
+		f.streamQueues[urgency].PushBack(id)
+		f.activeStreams[id] = urgency
 	}
 	f.mutex.Unlock()
 }
 
-func (f *framerI) AppendStreamFrames(frames []ackhandler.StreamFrame, maxLen protocol.ByteCount, v protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount) {
+func (f *framerI) SetStreamPriority(id protocol.StreamID, prio streamPriority) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if prio == streamPriorityDefault {
+		delete(f.priorities, id)
+	} else {
+		f.priorities[id] = prio
+	}
+	oldUrgency, ok := f.activeStreams[id]
+	if !ok || oldUrgency == prio.urgency() {
+		return
+	}
+	// Move the stream to the queue for its new urgency.
+	// This is O(n), but priority changes are rare.
+	queue := &f.streamQueues[oldUrgency]
+	for i, n := 0, queue.Len(); i < n; i++ {
+		if sid := queue.PopFront(); sid != id {
+			queue.PushBack(sid)
+		}
+	}
+	f.streamQueues[prio.urgency()].PushBack(id)
+	f.activeStreams[id] = prio.urgency()
+}
+
+func (f *framerI) RemoveStream(id protocol.StreamID) {
+	f.mutex.Lock()
+	delete(f.priorities, id)
+	f.mutex.Unlock()
+}
+
+// AppendStreamFrames appends STREAM frames of streams that have the same DSCP class.
+// The class is determined by the first stream that frames are appended for,
+// streams of a different class are skipped, and keep their position in the queue.
+// Data of streams with a lower urgency is sent first. Streams of the same urgency
+// take turns if they're incremental, and are sent one after the other if they're not.
+func (f *framerI) AppendStreamFrames(frames []ackhandler.StreamFrame, maxLen protocol.ByteCount, v protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount, dscpClass) {
 	startLen := len(frames)
 	var length protocol.ByteCount
+	class := dscpClassDefault
 	f.mutex.Lock()
-	// pop STREAM frames, until less than MinStreamFrameSize bytes are left in the packet
-	numActiveStreams := f.streamQueue.Len()
-	for i := 0; i < numActiveStreams; i++ {
-		if protocol.MinStreamFrameSize+length > maxLen {
-			break
-		}
-		id := f.streamQueue.PopFront()
-		// This should never return an error. Better check it anyway.
-		// The stream will only be in the streamQueue, if it enqueued itself there.
-		str, err := f.streamGetter.GetOrOpenSendStream(id)
-		// The stream can be nil if it completed after it said it had data.
-		if str == nil || err != nil {
-			delete(f.activeStreams, id)
-			continue
+	for urgency := range f.streamQueues {
+		queue := &f.streamQueues[urgency]
+		// pop STREAM frames, until less than MinStreamFrameSize bytes are left in the packet
+		numActiveStreams := queue.Len()
+		for i := 0; i < numActiveStreams; i++ {
+			if protocol.MinStreamFrameSize+length > maxLen {
+				break
+			}
+			id := queue.PopFront()
+			// This should never return an error. Better check it anyway.
+			// The stream will only be in a streamQueue, if it enqueued itself there.
+			str, err := f.streamGetter.GetOrOpenSendStream(id)
+			// The stream can be nil if it completed after it said it had data.
+			if str == nil || err != nil {
+				delete(f.activeStreams, id)
+				continue
+			}
+			strClass := str.dscpClass()
+			if len(frames) > startLen && strClass != class {
+				// Data of this stream needs to be sent in a packet with a different DSCP marking.
+				f.skippedStreams = append(f.skippedStreams, id)
+				continue
+			}
+			remainingLen := maxLen - length
+			// For the last STREAM frame, we'll remove the DataLen field later.
+			// Therefore, we can pretend to have more bytes available when popping
+			// the STREAM frame (which will always have the DataLen set).
+			remainingLen += quicvarint.Len(uint64(remainingLen))
+			frame, ok, hasMoreData := str.popStreamFrame(remainingLen, v)
+			if hasMoreData {
+				if f.priorities[id].incremental() { // put the stream back in the queue (at the end)
+					queue.PushBack(id)
+				} else { // keep sending this stream until it's done
+					queue.PushFront(id)
+				}
+			} else { // no more data to send. Stream is not active
+				delete(f.activeStreams, id)
+			}
+			// The frame can be "nil"
+			// * if the receiveStream was canceled after it said it had data
+			// * the remaining size doesn't allow us to add another STREAM frame
+			if !ok {
+				continue
+			}
+			frames = append(frames, frame)
+			length += frame.Frame.Length(v)
+			class = strClass
 		}
-		remainingLen := maxLen - length
-		// For the last STREAM frame, we'll remove the DataLen field later.
-		// Therefore, we can pretend to have more bytes available when popping
-		// the STREAM frame (which will always have the DataLen set).
-		remainingLen += quicvarint.Len(uint64(remainingLen))
-		frame, ok, hasMoreData := str.popStreamFrame(remainingLen, v)
-		if hasMoreData { // put the stream back in the queue (at the end)
-			f.streamQueue.PushBack(id)
-		} else { // no more data to send. Stream is not active
-			delete(f.activeStreams, id)
+		// Put the skipped streams back at the front of the queue, in their original order.
+		// Otherwise, non-incremental streams wouldn't be sent one after the other any more.
+		for i := len(f.skippedStreams) - 1; i >= 0; i-- {
+			queue.PushFront(f.skippedStreams[i])
 		}
-		// The frame can be "nil"
-		// * if the receiveStream was canceled after it said it had data
-		// * the remaining size doesn't allow us to add another STREAM frame
-		if !ok {
-			continue
+		f.skippedStreams = f.skippedStreams[:0]
+		if protocol.MinStreamFrameSize+length > maxLen {
+			break
 		}
-		frames = append(frames, frame)
-		length += frame.Frame.Length(v)
 	}
 	f.mutex.Unlock()
 	if len(frames) > startLen {
@@ -160,7 +231,7 @@ func (f *framerI) AppendStreamFrames(frames []ackhandler.StreamFrame, maxLen pro
 		frames[len(frames)-1].Frame.DataLenPresent = false
 		length += frames[len(frames)-1].Frame.Length(v) - l
 	}
-	return frames, length
+	return frames, length, class
 }
 
 func (f *framerI) Handle0RTTRejection() error {
@@ -168,10 +239,15 @@ func (f *framerI) Handle0RTTRejection() error {
 	defer f.mutex.Unlock()
 
 	f.controlFrameMutex.Lock()
-	f.streamQueue.Clear()
+	for i := range f.streamQueues {
+		f.streamQueues[i].Clear()
+	}
 	for id := range f.activeStreams {
 		delete(f.activeStreams, id)
 	}
+	for id := range f.priorities {
+		delete(f.priorities, id)
+	}
 	var j int
 	for i, frame := range f.controlFrames {
 		switch frame.(type) {
@@ -31,8 +31,10 @@ var _ = Describe("Framer", func() {
 		streamGetter = NewMockStreamGetter(mockCtrl)
 		stream1 = NewMockSendStreamI(mockCtrl)
 		stream1.EXPECT().StreamID().Return(protocol.StreamID(5)).AnyTimes()
+		stream1.EXPECT().dscpClass().AnyTimes()
 		stream2 = NewMockSendStreamI(mockCtrl)
 		stream2.EXPECT().StreamID().Return(protocol.StreamID(6)).AnyTimes()
+		stream2.EXPECT().dscpClass().AnyTimes()
 		framer = newFramer(streamGetter)
 	})
 
@@ -175,7 +177,7 @@ var _ = Describe("Framer", func() {
 			}
 			stream1.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f}, true, false)
 			framer.AddActiveStream(id1)
-			fs, length := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
+			fs, length, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
 			Expect(fs).To(HaveLen(1))
 			Expect(fs[0].Frame.DataLenPresent).To(BeFalse())
 			Expect(length).To(Equal(f.Length(version)))
@@ -190,11 +192,11 @@ var _ = Describe("Framer", func() {
 			f2 := &wire.StreamFrame{StreamID: id1, Data: []byte("bar")}
 			stream1.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f1}, true, true)
 			stream1.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f2}, true, false)
-			frames, _ := framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+			frames, _, _ := framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].Frame).To(Equal(f1))
 			Expect(framer.HasData()).To(BeTrue())
-			frames, _ = framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+			frames, _, _ = framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].Frame).To(Equal(f2))
 			Expect(framer.HasData()).To(BeFalse())
@@ -211,7 +213,7 @@ var _ = Describe("Framer", func() {
 			framer.AddActiveStream(id1)
 			f0 := ackhandler.StreamFrame{Frame: &wire.StreamFrame{StreamID: 9999}}
 			frames := []ackhandler.StreamFrame{f0}
-			fs, length := framer.AppendStreamFrames(frames, 1000, protocol.Version1)
+			fs, length, _ := framer.AppendStreamFrames(frames, 1000, protocol.Version1)
 			Expect(fs).To(HaveLen(2))
 			Expect(fs[0]).To(Equal(f0))
 			Expect(fs[1].Frame.Data).To(Equal([]byte("foobar")))
@@ -230,7 +232,7 @@ var _ = Describe("Framer", func() {
 			stream2.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f}, true, false)
 			framer.AddActiveStream(id1)
 			framer.AddActiveStream(id2)
-			frames, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
+			frames, _, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].Frame).To(Equal(f))
 		})
@@ -247,7 +249,7 @@ var _ = Describe("Framer", func() {
 			stream2.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f}, true, false)
 			framer.AddActiveStream(id1)
 			framer.AddActiveStream(id2)
-			frames, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
+			frames, _, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].Frame).To(Equal(f))
 		})
@@ -259,14 +261,14 @@ var _ = Describe("Framer", func() {
 			stream1.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f1}, true, true)
 			stream1.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f2}, true, false)
 			framer.AddActiveStream(id1) // only add it once
-			frames, _ := framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+			frames, _, _ := framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].Frame).To(Equal(f1))
-			frames, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+			frames, _, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].Frame).To(Equal(f2))
 			// no further calls to popStreamFrame, after popStreamFrame said there's no more data
-			frames, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+			frames, _, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
 			Expect(frames).To(BeNil())
 		})
 
@@ -282,15 +284,15 @@ var _ = Describe("Framer", func() {
 			framer.AddActiveStream(id1) // only add it once
 			framer.AddActiveStream(id2)
 			// first a frame from stream 1
-			frames, _ := framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+			frames, _, _ := framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].Frame).To(Equal(f11))
 			// then a frame from stream 2
-			frames, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+			frames, _, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].Frame).To(Equal(f2))
 			// then another frame from stream 1
-			frames, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+			frames, _, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].Frame).To(Equal(f12))
 		})
@@ -305,7 +307,7 @@ var _ = Describe("Framer", func() {
 			stream2.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f2}, true, true)
 			framer.AddActiveStream(id1)
 			framer.AddActiveStream(id2)
-			frames, length := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
+			frames, length, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
 			Expect(frames).To(HaveLen(2))
 			Expect(frames[0].Frame).To(Equal(f1))
 			Expect(frames[1].Frame).To(Equal(f2))
@@ -321,7 +323,7 @@ var _ = Describe("Framer", func() {
 			stream2.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f2}, true, false)
 			framer.AddActiveStream(id2)
 			framer.AddActiveStream(id1)
-			frames, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
+			frames, _, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
 			Expect(frames).To(HaveLen(2))
 			Expect(frames[0].Frame).To(Equal(f2))
 			Expect(frames[1].Frame).To(Equal(f1))
@@ -333,12 +335,12 @@ var _ = Describe("Framer", func() {
 			stream1.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f}, true, false) // only one call to this function
 			framer.AddActiveStream(id1)
 			framer.AddActiveStream(id1)
-			frames, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
+			frames, _, _ := framer.AppendStreamFrames(nil, 1000, protocol.Version1)
 			Expect(frames).To(HaveLen(1))
 		})
 
 		It("does not pop empty frames", func() {
-			fs, length := framer.AppendStreamFrames(nil, 500, protocol.Version1)
+			fs, length, _ := framer.AppendStreamFrames(nil, 500, protocol.Version1)
 			Expect(fs).To(BeEmpty())
 			Expect(length).To(BeZero())
 		})
@@ -356,7 +358,7 @@ var _ = Describe("Framer", func() {
 					return ackhandler.StreamFrame{Frame: f}, true, false
 				})
 				framer.AddActiveStream(id1)
-				frames, _ := framer.AppendStreamFrames(nil, i, protocol.Version1)
+				frames, _, _ := framer.AppendStreamFrames(nil, i, protocol.Version1)
 				Expect(frames).To(HaveLen(1))
 				f := frames[0].Frame
 				Expect(f.DataLenPresent).To(BeFalse())
@@ -387,7 +389,7 @@ var _ = Describe("Framer", func() {
 				})
 				framer.AddActiveStream(id1)
 				framer.AddActiveStream(id2)
-				frames, _ := framer.AppendStreamFrames(nil, i, protocol.Version1)
+				frames, _, _ := framer.AppendStreamFrames(nil, i, protocol.Version1)
 				Expect(frames).To(HaveLen(2))
 				f1 := frames[0].Frame
 				f2 := frames[1].Frame
@@ -420,16 +422,179 @@ var _ = Describe("Framer", func() {
 			}
 			stream1.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f}, true, false)
 			framer.AddActiveStream(id1)
-			fs, length := framer.AppendStreamFrames(nil, 500, protocol.Version1)
+			fs, length, _ := framer.AppendStreamFrames(nil, 500, protocol.Version1)
 			Expect(fs).To(HaveLen(1))
 			Expect(fs[0].Frame).To(Equal(f))
 			Expect(length).To(Equal(f.Length(version)))
 		})
 
+		It("only appends STREAM frames of streams with the same DSCP class", func() {
+			id3 := protocol.StreamID(42)
+			stream3 := NewMockSendStreamI(mockCtrl)
+			stream3.EXPECT().dscpClass().Return(newDSCPClass(46)).AnyTimes()
+			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil).AnyTimes()
+			streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).AnyTimes()
+			streamGetter.EXPECT().GetOrOpenSendStream(id3).Return(stream3, nil).AnyTimes()
+			f1 := &wire.StreamFrame{StreamID: id1, Data: []byte("foo")}
+			f2 := &wire.StreamFrame{StreamID: id2, Data: []byte("bar")}
+			f3 := &wire.StreamFrame{StreamID: id3, Data: []byte("baz")}
+			f4 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+			stream1.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f1}, true, true)
+			stream2.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f2}, true, false)
+			stream3.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f3}, true, false)
+			framer.AddActiveStream(id1)
+			framer.AddActiveStream(id3)
+			framer.AddActiveStream(id2)
+			fs, _, class := framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+			Expect(fs).To(HaveLen(2))
+			Expect(fs[0].Frame).To(Equal(f1))
+			Expect(fs[1].Frame).To(Equal(f2))
+			Expect(class).To(Equal(dscpClassDefault))
+			// the stream that was skipped is still active, and kept its position in the queue
+			fs, _, class = framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+			Expect(fs).To(HaveLen(1))
+			Expect(fs[0].Frame).To(Equal(f3))
+			Expect(class).To(Equal(newDSCPClass(46)))
+			stream1.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f4}, true, false)
+			fs, _, class = framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+			Expect(fs).To(HaveLen(1))
+			Expect(fs[0].Frame).To(Equal(f4))
+			Expect(class).To(Equal(dscpClassDefault))
+			Expect(framer.HasData()).To(BeFalse())
+		})
+
+		Context("priorities", func() {
+			var stream3 *MockSendStreamI
+			const id3 = protocol.StreamID(42)
+
+			BeforeEach(func() {
+				stream3 = NewMockSendStreamI(mockCtrl)
+				stream3.EXPECT().dscpClass().AnyTimes()
+				streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil).AnyTimes()
+				streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).AnyTimes()
+				streamGetter.EXPECT().GetOrOpenSendStream(id3).Return(stream3, nil).AnyTimes()
+			})
+
+			expectPop := func(str *MockSendStreamI, id protocol.StreamID, hasMore bool) *wire.StreamFrame {
+				f := &wire.StreamFrame{StreamID: id, Data: []byte("foobar")}
+				str.EXPECT().popStreamFrame(gomock.Any(), protocol.Version1).Return(ackhandler.StreamFrame{Frame: f}, true, hasMore)
+				return f
+			}
+
+			It("sends data of more urgent streams first", func() {
+				framer.SetStreamPriority(id2, newStreamPriority(StreamPriority{Urgency: 1, Incremental: true}))
+				framer.SetStreamPriority(id3, newStreamPriority(StreamPriority{Urgency: 7, Incremental: true}))
+				framer.AddActiveStream(id3)
+				framer.AddActiveStream(id1) // default urgency
+				framer.AddActiveStream(id2)
+				f2 := expectPop(stream2, id2, false)
+				f1 := expectPop(stream1, id1, false)
+				f3 := expectPop(stream3, id3, false)
+				frames, _, _ := framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+				Expect(frames).To(HaveLen(3))
+				Expect(frames[0].Frame).To(Equal(f2))
+				Expect(frames[1].Frame).To(Equal(f1))
+				Expect(frames[2].Frame).To(Equal(f3))
+			})
+
+			It("doesn't send data of less urgent streams while more urgent streams have data", func() {
+				framer.SetStreamPriority(id1, newStreamPriority(StreamPriority{Urgency: 0, Incremental: true}))
+				framer.AddActiveStream(id2)
+				framer.AddActiveStream(id1)
+				f11 := expectPop(stream1, id1, true)
+				frames, _, _ := framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+				Expect(frames).To(HaveLen(1))
+				Expect(frames[0].Frame).To(Equal(f11))
+				f12 := expectPop(stream1, id1, false)
+				frames, _, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+				Expect(frames).To(HaveLen(1))
+				Expect(frames[0].Frame).To(Equal(f12))
+				f2 := expectPop(stream2, id2, false)
+				frames, _, _ = framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+				Expect(frames).To(HaveLen(1))
+				Expect(frames[0].Frame).To(Equal(f2))
+				Expect(framer.HasData()).To(BeFalse())
+			})
+
+			It("sends non-incremental streams one after the other", func() {
+				framer.SetStreamPriority(id1, newStreamPriority(StreamPriority{Urgency: 3}))
+				framer.SetStreamPriority(id2, newStreamPriority(StreamPriority{Urgency: 3}))
+				framer.AddActiveStream(id1)
+				framer.AddActiveStream(id2)
+				for i := 0; i < 3; i++ {
+					f := expectPop(stream1, id1, i < 2)
+					frames, _, _ := framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+					Expect(frames).To(HaveLen(1))
+					Expect(frames[0].Frame).To(Equal(f))
+				}
+				f2 := expectPop(stream2, id2, false)
+				frames, _, _ := framer.AppendStreamFrames(nil, protocol.MinStreamFrameSize, protocol.Version1)
+				Expect(frames).To(HaveLen(1))
+				Expect(frames[0].Frame).To(Equal(f2))
+			})
+
+			It("keeps the order of non-incremental streams when skipping streams of a different DSCP class", func() {
+				const id4 = protocol.StreamID(1337)
+				stream4 := NewMockSendStreamI(mockCtrl)
+				stream4.EXPECT().dscpClass().Return(newDSCPClass(46)).AnyTimes()
+				streamGetter.EXPECT().GetOrOpenSendStream(id4).Return(stream4, nil).AnyTimes()
+				for _, id := range []protocol.StreamID{id1, id4, id2} {
+					framer.SetStreamPriority(id, newStreamPriority(StreamPriority{Urgency: 3}))
+					framer.AddActiveStream(id)
+				}
+				f1 := expectPop(stream1, id1, false)
+				f21 := expectPop(stream2, id2, true)
+				frames, _, class := framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+				Expect(frames).To(HaveLen(2))
+				Expect(frames[0].Frame).To(Equal(f1))
+				Expect(frames[1].Frame).To(Equal(f21))
+				Expect(class).To(Equal(dscpClassDefault))
+				// stream 4 was skipped, but it still needs to be completed before stream 2
+				f4 := expectPop(stream4, id4, false)
+				frames, _, class = framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+				Expect(frames).To(HaveLen(1))
+				Expect(frames[0].Frame).To(Equal(f4))
+				Expect(class).To(Equal(newDSCPClass(46)))
+				f22 := expectPop(stream2, id2, false)
+				frames, _, _ = framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+				Expect(frames).To(HaveLen(1))
+				Expect(frames[0].Frame).To(Equal(f22))
+				Expect(framer.HasData()).To(BeFalse())
+			})
+
+			It("reschedules active streams when their priority changes", func() {
+				framer.AddActiveStream(id1)
+				framer.AddActiveStream(id2)
+				framer.AddActiveStream(id3)
+				framer.SetStreamPriority(id3, newStreamPriority(StreamPriority{Urgency: 0, Incremental: true}))
+				f3 := expectPop(stream3, id3, false)
+				f1 := expectPop(stream1, id1, false)
+				f2 := expectPop(stream2, id2, false)
+				frames, _, _ := framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+				Expect(frames).To(HaveLen(3))
+				Expect(frames[0].Frame).To(Equal(f3))
+				Expect(frames[1].Frame).To(Equal(f1))
+				Expect(frames[2].Frame).To(Equal(f2))
+			})
+
+			It("forgets the priority of completed streams", func() {
+				framer.SetStreamPriority(id3, newStreamPriority(StreamPriority{Urgency: 0, Incremental: true}))
+				framer.RemoveStream(id3)
+				framer.AddActiveStream(id1)
+				framer.AddActiveStream(id3)
+				f1 := expectPop(stream1, id1, false)
+				f3 := expectPop(stream3, id3, false)
+				frames, _, _ := framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+				Expect(frames).To(HaveLen(2))
+				Expect(frames[0].Frame).To(Equal(f1))
+				Expect(frames[1].Frame).To(Equal(f3))
+			})
+		})
+
 		It("drops all STREAM frames when 0-RTT is rejected", func() {
 			framer.AddActiveStream(id1)
 			Expect(framer.Handle0RTTRejection()).To(Succeed())
-			fs, length := framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
+			fs, length, _ := framer.AppendStreamFrames(nil, protocol.MaxByteCount, protocol.Version1)
 			Expect(fs).To(BeEmpty())
 			Expect(length).To(BeZero())
 		})
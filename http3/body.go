@@ -75,6 +75,10 @@ func (r *body) Close() error {
 type hijackableBody struct {
 	body
 	conn quic.Connection // only needed to implement Hijacker
+	// set if HTTP datagrams are enabled, used by RoundTripper.ExtendedConnect
+	datagrams *datagramRouter
+	// set if WebTransport is enabled, used by RoundTripper.DialWebTransport
+	webTransport *webTransportManager
 
 	// only set for the http.Response
 	// The channel is closed when the user is done with this response:
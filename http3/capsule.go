@@ -15,7 +15,7 @@ type exactReader struct {
 
 func (r *exactReader) Read(b []byte) (int, error) {
 	n, err := r.R.Read(b)
-	if r.R.N > 0 {
+	if err == io.EOF && r.R.N > 0 {
 		return n, io.ErrUnexpectedEOF
 	}
 	return n, err
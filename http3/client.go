@@ -8,6 +8,8 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -16,6 +18,7 @@ import (
 	"github.com/nxenon/xquic-go"
 	"github.com/nxenon/xquic-go/internal/protocol"
 	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/qlog"
 	"github.com/nxenon/xquic-go/quicvarint"
 
 	"github.com/quic-go/qpack"
@@ -28,6 +31,9 @@ const MethodGet0RTT = "GET_0RTT"
 const (
 	defaultUserAgent              = "quic-go HTTP/3"
 	defaultMaxResponseHeaderBytes = 10 * 1 << 20 // 10 MB
+	// max1xxResponses is the maximum number of informational responses accepted for a request,
+	// unless the request's httptrace.ClientTrace handles them in Got1xxResponse.
+	max1xxResponses = 5
 )
 
 var defaultQuicConfig = &quic.Config{
@@ -39,15 +45,43 @@ type dialFunc func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *qu
 
 var dialAddr dialFunc = quic.DialAddrEarly
 
+// ErrExtendedConnectNotSupported is returned when sending an Extended CONNECT request (RFC 9220)
+// to a server that didn't enable SETTINGS_ENABLE_CONNECT_PROTOCOL.
+var ErrExtendedConnectNotSupported = errors.New("http3: server doesn't support Extended CONNECT")
+
 type roundTripperOpts struct {
-	DisableCompression bool
-	EnableDatagram     bool
-	MaxHeaderBytes     int64
-	AdditionalSettings map[uint64]uint64
-	StreamHijacker     func(FrameType, quic.Connection, quic.Stream, error) (hijacked bool, err error)
-	UniStreamHijacker  func(StreamType, quic.Connection, quic.ReceiveStream, error) (hijacked bool)
+	DisableCompression  bool
+	EnableDatagram      bool
+	MaxHeaderBytes      int64
+	AdditionalSettings  map[uint64]uint64
+	StreamHijacker      func(FrameType, quic.Connection, quic.Stream, error) (hijacked bool, err error)
+	UniStreamHijacker   func(StreamType, quic.Connection, quic.ReceiveStream, error) (hijacked bool)
+	EnableWebTransport  bool
+	WebTransportConfig  *WebTransportConfig
+	PushHandler         func(*PushPromise) bool
+	MaxConcurrentPushes int
+	QPACKConfig         *QPACKConfig
+
+	// onGoAway is called when the server sends its first GOAWAY frame,
+	// so that the RoundTripper stops scheduling new requests on this connection.
+	onGoAway func()
+}
+
+// errGoAway is returned when trying to send a request on a connection that the server is shutting down.
+var errGoAway = errors.New("http3: server is shutting down the connection (received GOAWAY)")
+
+// requestNotProcessedError is returned when the server didn't process a request.
+// This is the case for requests on streams with a stream ID higher than the server's GOAWAY frame allows,
+// for requests rejected with H3_REQUEST_REJECTED, and for requests rejected together with 0-RTT.
+// Such requests can be retried on a new connection.
+type requestNotProcessedError struct {
+	err  error
+	sent bool // was (part of) the request sent on the wire?
 }
 
+func (e *requestNotProcessedError) Error() string { return e.err.Error() }
+func (e *requestNotProcessedError) Unwrap() error { return e.err }
+
 // client is a HTTP3 client doing requests
 type client struct {
 	tlsConf *tls.Config
@@ -58,13 +92,30 @@ type client struct {
 	dialer       dialFunc
 	handshakeErr error
 
-	requestWriter *requestWriter
+	requestWriter *requestWriter // set when dialing
 
-	decoder *qpack.Decoder
+	decoder *qpackDecoder // set when dialing
+	encoder *qpackEncoder // set when dialing
 
 	hostname string
 	conn     atomic.Pointer[quic.EarlyConnection]
 
+	// closed when the server's SETTINGS frame was received
+	receivedSettings     chan struct{}
+	receivedSettingsOnce sync.Once
+	settings             *settingsFrame // set before receivedSettings is closed
+
+	datagrams    *datagramRouter      // set when dialing, if HTTP datagrams are enabled
+	webTransport *webTransportManager // set when dialing, if WebTransport is enabled
+	pushes       *clientPushes        // set when dialing, if server push is enabled
+
+	goAwayMutex    sync.Mutex
+	receivedGoAway bool
+	goAwayID       uint64        // stream ID of the last GOAWAY frame
+	goAwayChanged  chan struct{} // closed (and replaced) when a GOAWAY frame is received
+	activeRequests int           // number of requests in flight, used to close the connection after a GOAWAY frame
+
+	tracer *qlog.HTTP3Tracer // set when dialing, may be nil
 	logger utils.Logger
 }
 
@@ -84,7 +135,11 @@ func newClient(hostname string, tlsConf *tls.Config, opts *roundTripperOpts, con
 	if conf.MaxIncomingStreams == 0 {
 		conf.MaxIncomingStreams = -1 // don't allow any bidirectional streams
 	}
-	conf.EnableDatagrams = opts.EnableDatagram
+	conf.EnableDatagrams = opts.EnableDatagram || opts.EnableWebTransport
+	if opts.EnableWebTransport && conf.MaxIncomingStreams <= 0 {
+		// the server opens bidirectional streams for WebTransport
+		conf.MaxIncomingStreams = protocol.DefaultMaxIncomingStreams
+	}
 	logger := utils.DefaultLogger.WithPrefix("h3 client")
 
 	if tlsConf == nil {
@@ -104,14 +159,15 @@ func newClient(hostname string, tlsConf *tls.Config, opts *roundTripperOpts, con
 	tlsConf.NextProtos = []string{versionToALPN(conf.Versions[0])}
 
 	return &client{
-		hostname:      authorityAddr("https", hostname),
-		tlsConf:       tlsConf,
-		requestWriter: newRequestWriter(logger),
-		decoder:       qpack.NewDecoder(func(hf qpack.HeaderField) {}),
-		config:        conf,
-		opts:          opts,
-		dialer:        dialer,
-		logger:        logger,
+		hostname: authorityAddr("https", hostname),
+		tlsConf:  tlsConf,
+		config:   conf,
+		opts:     opts,
+		dialer:   dialer,
+		logger:   logger,
+
+		receivedSettings: make(chan struct{}),
+		goAwayChanged:    make(chan struct{}),
 	}, nil
 }
 
@@ -127,6 +183,18 @@ func (c *client) dial(ctx context.Context) error {
 		return err
 	}
 	c.conn.Store(&conn)
+	// HTTP/3 events are written into the qlog of the QUIC connection (if any)
+	c.tracer = qlog.HTTP3TracerFromContext(conn.Context())
+	c.decoder = newQPACKDecoder(conn, c.opts.QPACKConfig.maxTableCapacity(), c.opts.QPACKConfig.maxBlockedStreams(), c.tracer)
+	c.encoder = newQPACKEncoder(conn, c.opts.QPACKConfig.maxEncoderTableCapacity(), c.tracer)
+	c.requestWriter = newRequestWriter(c.encoder, c.logger)
+	if c.opts.PushHandler != nil {
+		c.pushes = newClientPushes(conn, c.hostname, c.opts.PushHandler, c.opts.MaxConcurrentPushes, c.decoder, c.tracer, func(req *http.Request, str quic.Stream, done chan<- struct{}) (*http.Response, requestError) {
+			return c.readResponse(req, conn, str, newStream(str, c.tracer, func() {
+				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeFrameUnexpected), "")
+			}), false, done)
+		})
+	}
 
 	// send the SETTINGs frame, using 0-RTT data, if possible
 	go func() {
@@ -136,7 +204,14 @@ func (c *client) dial(ctx context.Context) error {
 		}
 	}()
 
-	if c.opts.StreamHijacker != nil {
+	if c.opts.EnableDatagram || c.opts.EnableWebTransport {
+		c.datagrams = newDatagramRouter(conn)
+		go c.datagrams.run()
+	}
+	if c.opts.EnableWebTransport {
+		c.webTransport = newWebTransportManager(conn, c.opts.WebTransportConfig, c.logger)
+	}
+	if c.opts.StreamHijacker != nil || c.webTransport != nil {
 		go c.handleBidirectionalStreams(conn)
 	}
 	go c.handleUnidirectionalStreams(conn)
@@ -152,9 +227,24 @@ func (c *client) setupConn(conn quic.EarlyConnection) error {
 	b := make([]byte, 0, 64)
 	b = quicvarint.Append(b, streamTypeControlStream)
 	// send the SETTINGS frame
-	b = (&settingsFrame{Datagram: c.opts.EnableDatagram, Other: c.opts.AdditionalSettings}).Append(b)
-	_, err = str.Write(b)
-	return err
+	sf := &settingsFrame{
+		Datagram:              c.opts.EnableDatagram || c.opts.EnableWebTransport,
+		WebTransport:          c.opts.EnableWebTransport,
+		QPACKMaxTableCapacity: c.decoder.maxTableCapacity,
+		QPACKBlockedStreams:   c.decoder.maxBlockedStreams,
+		Other:                 c.opts.AdditionalSettings,
+	}
+	b = sf.Append(b)
+	if _, err := str.Write(b); err != nil {
+		return err
+	}
+	if c.tracer != nil {
+		traceControlStream(c.tracer, str.StreamID(), sf)
+	}
+	if c.pushes != nil {
+		return c.pushes.setControlStream(str)
+	}
+	return nil
 }
 
 func (c *client) handleBidirectionalStreams(conn quic.EarlyConnection) {
@@ -166,6 +256,17 @@ func (c *client) handleBidirectionalStreams(conn quic.EarlyConnection) {
 		}
 		go func(str quic.Stream) {
 			_, err := parseNextFrame(str, func(ft FrameType, e error) (processed bool, err error) {
+				if c.webTransport != nil && e == nil && ft == frameTypeWebTransportStream {
+					if err := c.webTransport.handleStream(str); err != nil {
+						c.logger.Debugf("reading the session ID on stream %d failed: %s", str.StreamID(), err)
+						str.CancelRead(quic.StreamErrorCode(ErrCodeGeneralProtocolError))
+						str.CancelWrite(quic.StreamErrorCode(ErrCodeGeneralProtocolError))
+					}
+					return true, nil
+				}
+				if c.opts.StreamHijacker == nil {
+					return false, nil
+				}
 				return c.opts.StreamHijacker(ft, conn, str, e)
 			})
 			if err == errHijacked {
@@ -180,6 +281,7 @@ func (c *client) handleBidirectionalStreams(conn quic.EarlyConnection) {
 }
 
 func (c *client) handleUnidirectionalStreams(conn quic.EarlyConnection) {
+	var rcvdQPACKEncoderStr, rcvdQPACKDecoderStr atomic.Bool
 	for {
 		str, err := conn.AcceptUniStream(context.Background())
 		if err != nil {
@@ -196,18 +298,45 @@ func (c *client) handleUnidirectionalStreams(conn quic.EarlyConnection) {
 				c.logger.Debugf("reading stream type on stream %d failed: %s", str.StreamID(), err)
 				return
 			}
+			if c.tracer != nil {
+				c.tracer.StreamTypeSet(false, str.StreamID(), streamType)
+			}
 			// We're only interested in the control stream here.
 			switch streamType {
 			case streamTypeControlStream:
-			case streamTypeQPACKEncoderStream, streamTypeQPACKDecoderStream:
-				// Our QPACK implementation doesn't use the dynamic table yet.
-				// TODO: check that only one stream of each type is opened.
+			case streamTypeQPACKEncoderStream:
+				if isFirst := rcvdQPACKEncoderStr.CompareAndSwap(false, true); !isFirst {
+					conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeStreamCreationError), "duplicate QPACK encoder stream")
+					return
+				}
+				if rerr := c.decoder.handleEncoderStream(str); rerr.connErr != 0 {
+					conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), rerr.err.Error())
+				}
+				return
+			case streamTypeQPACKDecoderStream:
+				if isFirst := rcvdQPACKDecoderStr.CompareAndSwap(false, true); !isFirst {
+					conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeStreamCreationError), "duplicate QPACK decoder stream")
+					return
+				}
+				if rerr := c.encoder.handleDecoderStream(str); rerr.connErr != 0 {
+					conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), rerr.err.Error())
+				}
 				return
 			case streamTypePushStream:
-				// We never increased the Push ID, so we don't expect any push streams.
-				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), "")
+				if c.pushes == nil {
+					// We never increased the Push ID, so we don't expect any push streams.
+					conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), "")
+					return
+				}
+				if rerr := c.pushes.handleStream(str); rerr.connErr != 0 {
+					conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), rerr.err.Error())
+				}
 				return
 			default:
+				if c.webTransport != nil && streamType == streamTypeWebTransportStream {
+					c.webTransport.handleUniStream(str)
+					return
+				}
 				if c.opts.UniStreamHijacker != nil && c.opts.UniStreamHijacker(StreamType(streamType), conn, str, nil) {
 					return
 				}
@@ -224,19 +353,132 @@ func (c *client) handleUnidirectionalStreams(conn quic.EarlyConnection) {
 				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeMissingSettings), "")
 				return
 			}
-			if !sf.Datagram {
-				return
+			if c.tracer != nil {
+				traceReceivedSettings(c.tracer, str.StreamID(), sf)
 			}
+			c.encoder.handleSettings(sf)
+			c.receivedSettingsOnce.Do(func() {
+				c.settings = sf
+				close(c.receivedSettings)
+			})
 			// If datagram support was enabled on our side as well as on the server side,
 			// we can expect it to have been negotiated both on the transport and on the HTTP/3 layer.
 			// Note: ConnectionState() will block until the handshake is complete (relevant when using 0-RTT).
-			if c.opts.EnableDatagram && !conn.ConnectionState().SupportsDatagrams {
+			if sf.Datagram && c.opts.EnableDatagram && !conn.ConnectionState().SupportsDatagrams {
 				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeSettingsError), "missing QUIC Datagram support")
+				return
 			}
+			c.handleControlStream(conn, str)
 		}(str)
 	}
 }
 
+// handleControlStream handles the frames sent on the server's control stream after the SETTINGS frame.
+func (c *client) handleControlStream(conn quic.EarlyConnection, str quic.ReceiveStream) {
+	for {
+		f, err := parseNextFrame(str, nil)
+		if err != nil {
+			return
+		}
+		switch f := f.(type) {
+		case *cancelPushFrame:
+			if c.tracer != nil {
+				c.tracer.FrameParsed(str.StreamID(), uint64(quicvarint.Len(f.PushID)), &qlog.HTTP3CancelPushFrame{PushID: f.PushID})
+			}
+			if c.pushes == nil {
+				// We never increased the Push ID, so the server can't cancel any pushes.
+				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), "received CANCEL_PUSH, but server push is disabled")
+				return
+			}
+			if err := c.pushes.handleCancelPush(f.PushID); err != nil {
+				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), err.Error())
+				return
+			}
+		case *goAwayFrame:
+			if c.tracer != nil {
+				c.tracer.FrameParsed(str.StreamID(), uint64(quicvarint.Len(f.StreamID)), &qlog.HTTP3GoAwayFrame{ID: f.StreamID})
+			}
+			if err := c.handleGoAway(f.StreamID); err != nil {
+				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), err.Error())
+				return
+			}
+		default:
+			conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeFrameUnexpected), fmt.Sprintf("unexpected frame on the control stream: %T", f))
+			return
+		}
+	}
+}
+
+// handleGoAway handles a GOAWAY frame sent by the server.
+// Requests on streams with stream IDs equal to or greater than id won't be processed by the server.
+func (c *client) handleGoAway(id uint64) error {
+	// GOAWAY frames sent by the server carry the ID of a client-initiated bidirectional stream.
+	if id%4 != 0 {
+		return fmt.Errorf("GOAWAY frame for invalid stream ID %d", id)
+	}
+	c.goAwayMutex.Lock()
+	if c.receivedGoAway && id > c.goAwayID {
+		c.goAwayMutex.Unlock()
+		return fmt.Errorf("GOAWAY stream ID increased from %d to %d", c.goAwayID, id)
+	}
+	first := !c.receivedGoAway
+	c.receivedGoAway = true
+	c.goAwayID = id
+	close(c.goAwayChanged)
+	c.goAwayChanged = make(chan struct{})
+	idle := first && c.activeRequests == 0
+	c.goAwayMutex.Unlock()
+
+	if first && c.opts.onGoAway != nil {
+		c.opts.onGoAway()
+	}
+	// No new requests will be sent on this connection.
+	// The server waits for the client to close the connection once all requests have completed.
+	if idle {
+		c.Close()
+	}
+	return nil
+}
+
+// startRequest registers a new request.
+// It returns false if a GOAWAY frame was received, in which case the request must not be sent on this connection.
+func (c *client) startRequest() bool {
+	c.goAwayMutex.Lock()
+	defer c.goAwayMutex.Unlock()
+	if c.receivedGoAway {
+		return false
+	}
+	c.activeRequests++
+	return true
+}
+
+// requestDone is called when a request has completed.
+// After a GOAWAY frame was received, the connection is closed when the last request completes.
+func (c *client) requestDone() {
+	c.goAwayMutex.Lock()
+	c.activeRequests--
+	idle := c.receivedGoAway && c.activeRequests == 0
+	c.goAwayMutex.Unlock()
+	if idle {
+		c.Close()
+	}
+}
+
+// goAwayState returns the stream ID of the last GOAWAY frame received (if any),
+// and a channel that is closed when the next GOAWAY frame is received.
+func (c *client) goAwayState() (id uint64, received bool, changed <-chan struct{}) {
+	c.goAwayMutex.Lock()
+	defer c.goAwayMutex.Unlock()
+	return c.goAwayID, c.receivedGoAway, c.goAwayChanged
+}
+
+// isUnprocessed says if a request sent on this stream won't be processed by the server,
+// according to the GOAWAY frames received so far.
+func (c *client) isUnprocessed(str quic.Stream) bool {
+	goAwayID, received, _ := c.goAwayState()
+	return received && uint64(str.StreamID()) >= goAwayID
+}
+
 func (c *client) Close() error {
 	conn := c.conn.Load()
 	if conn == nil {
@@ -267,6 +509,11 @@ func (c *client) roundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Respon
 		return nil, fmt.Errorf("http3 client BUG: RoundTripOpt called for the wrong client (expected %s, got %s)", c.hostname, req.Host)
 	}
 
+	trace := httptrace.ContextClientTrace(req.Context())
+	traceGetConn(trace, c.hostname)
+	// Only the first request dials the connection, all other requests reuse it.
+	// Requests that were started while the connection was being dialed don't count as reusing it.
+	reused := c.conn.Load() != nil
 	c.dialOnce.Do(func() {
 		c.handshakeErr = c.dial(req.Context())
 	})
@@ -288,24 +535,53 @@ func (c *client) roundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Respon
 			return nil, req.Context().Err()
 		}
 	}
+	traceGotConn(trace, conn, reused)
 
+	// Extended CONNECT requests must not be sent before the server enabled them in its SETTINGS.
+	// See section 3 of RFC 9220.
+	if isExtendedConnect(req) {
+		if err := c.waitForExtendedConnect(req, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	if !c.startRequest() {
+		return nil, &requestNotProcessedError{err: errGoAway}
+	}
 	str, err := conn.OpenStreamSync(req.Context())
 	if err != nil {
+		c.requestDone()
+		if errors.Is(err, quic.Err0RTTRejected) {
+			return nil, &requestNotProcessedError{err: err}
+		}
 		return nil, err
 	}
 
 	// Request Cancellation:
 	// This go routine keeps running even after RoundTripOpt() returns.
 	// It is shut down when the application is done processing the body.
+	// The request is also canceled if the server announces (using a GOAWAY frame)
+	// that it won't process it.
 	reqDone := make(chan struct{})
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
-		select {
-		case <-req.Context().Done():
-			str.CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled))
-			str.CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled))
-		case <-reqDone:
+		for {
+			_, _, goAwayChanged := c.goAwayState()
+			if c.isUnprocessed(str) {
+				str.CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled))
+				str.CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled))
+				return
+			}
+			select {
+			case <-req.Context().Done():
+				str.CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled))
+				str.CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled))
+				return
+			case <-reqDone:
+				return
+			case <-goAwayChanged:
+			}
 		}
 	}()
 
@@ -317,6 +593,7 @@ func (c *client) roundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Respon
 	if rerr.err != nil { // if any error occurred
 		close(reqDone)
 		<-done
+		c.requestDone()
 		if rerr.streamErr != 0 { // if it was a stream error
 			str.CancelWrite(quic.StreamErrorCode(rerr.streamErr))
 		}
@@ -327,15 +604,55 @@ func (c *client) roundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Respon
 			}
 			conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), reason)
 		}
+		if c.isUnprocessed(str) || isRequestRejected(rerr.err) || errors.Is(rerr.err, quic.Err0RTTRejected) {
+			return nil, &requestNotProcessedError{err: maybeReplaceError(rerr.err), sent: true}
+		}
 		return nil, maybeReplaceError(rerr.err)
 	}
 	if opt.DontCloseRequestStream {
 		close(reqDone)
 		<-done
+		// The stream is still used after the response was received, e.g. by a WebTransport session.
+		go func() {
+			<-str.Context().Done()
+			c.requestDone()
+		}()
+	} else {
+		go func() {
+			<-done
+			c.requestDone()
+		}()
 	}
 	return rsp, maybeReplaceError(rerr.err)
 }
 
+// isRequestRejected says if the server rejected the request using the H3_REQUEST_REJECTED error code.
+func isRequestRejected(err error) bool {
+	var strErr *quic.StreamError
+	return errors.As(err, &strErr) && strErr.Remote && ErrCode(strErr.ErrorCode) == ErrCodeRequestRejected
+}
+
+// waitForExtendedConnect blocks until the server's SETTINGS frame was received.
+// It returns an error if the server didn't enable Extended CONNECT,
+// or, for WebTransport requests, if the server didn't enable WebTransport.
+func (c *client) waitForExtendedConnect(req *http.Request, conn quic.EarlyConnection) error {
+	ctx := req.Context()
+	select {
+	case <-c.receivedSettings:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-conn.Context().Done():
+		return context.Cause(conn.Context())
+	}
+	if !c.settings.ExtendedConnect {
+		return ErrExtendedConnectNotSupported
+	}
+	if req.Proto == webTransportProtocol && !c.settings.WebTransport {
+		return ErrWebTransportNotSupported
+	}
+	return nil
+}
+
 // cancelingReader reads from the io.Reader.
 // It cancels writing on the stream if any error other than io.EOF occurs.
 type cancelingReader struct {
@@ -377,18 +694,28 @@ func (c *client) sendRequestBody(str Stream, body io.ReadCloser, contentLength i
 
 func (c *client) doRequest(req *http.Request, conn quic.EarlyConnection, str quic.Stream, opt RoundTripOpt, reqDone chan<- struct{}) (*http.Response, requestError) {
 	var requestGzip bool
-	if !c.opts.DisableCompression && req.Method != "HEAD" && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+	// CONNECT requests establish a tunnel, transparently decompressing the data would corrupt it.
+	if !c.opts.DisableCompression && req.Method != "HEAD" && req.Method != http.MethodConnect && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
 		requestGzip = true
 	}
-	if err := c.requestWriter.WriteRequestHeader(str, req, requestGzip); err != nil {
+	trace := httptrace.ContextClientTrace(req.Context())
+	if err := c.requestWriter.WriteRequestHeader(str, req, requestGzip, c.tracer); err != nil {
+		traceWroteRequest(trace, err)
 		return nil, newStreamError(ErrCodeInternalError, err)
 	}
+	traceWroteHeaders(trace)
 
-	if req.Body == nil && !opt.DontCloseRequestStream {
-		str.Close()
+	if req.Body == nil {
+		traceWroteRequest(trace, nil)
+		if !opt.DontCloseRequestStream {
+			str.Close()
+		}
 	}
 
-	hstr := newStream(str, func() { conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeFrameUnexpected), "") })
+	hstr := newStream(str, c.tracer, func() { conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeFrameUnexpected), "") })
+	hstr.onPushPromise = func(f *pushPromiseFrame) error {
+		return abortOnError(conn, str, c.handlePushPromise(req.Context(), str, f))
+	}
 	if req.Body != nil {
 		// send the request body asynchronously
 		go func() {
@@ -398,43 +725,85 @@ func (c *client) doRequest(req *http.Request, conn quic.EarlyConnection, str qui
 			if req.ContentLength > 0 {
 				contentLength = req.ContentLength
 			}
-			if err := c.sendRequestBody(hstr, req.Body, contentLength); err != nil {
+			err := c.sendRequestBody(hstr, req.Body, contentLength)
+			if err != nil {
 				c.logger.Errorf("Error writing request: %s", err)
 			}
+			traceWroteRequest(trace, err)
 			if !opt.DontCloseRequestStream {
 				hstr.Close()
 			}
 		}()
 	}
 
-	frame, err := parseNextFrame(str, nil)
-	if err != nil {
-		return nil, newStreamError(ErrCodeFrameError, err)
-	}
-	hf, ok := frame.(*headersFrame)
-	if !ok {
-		return nil, newConnError(ErrCodeFrameUnexpected, errors.New("expected first frame to be a HEADERS frame"))
-	}
-	if hf.Length > c.maxHeaderBytes() {
-		return nil, newStreamError(ErrCodeFrameError, fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, c.maxHeaderBytes()))
-	}
-	headerBlock := make([]byte, hf.Length)
-	if _, err := io.ReadFull(str, headerBlock); err != nil {
-		return nil, newStreamError(ErrCodeRequestIncomplete, err)
-	}
-	hfs, err := c.decoder.DecodeFull(headerBlock)
-	if err != nil {
-		// TODO: use the right error code
-		return nil, newConnError(ErrCodeGeneralProtocolError, err)
+	if trace != nil && trace.GotFirstResponseByte != nil {
+		// Peek blocks until the first byte of the response is received.
+		// Errors are handled when the response is read.
+		if _, err := str.Peek(); err == nil {
+			trace.GotFirstResponseByte()
+		}
 	}
+	return c.readResponse(req, conn, str, hstr, requestGzip, reqDone)
+}
 
-	res, err := responseFromHeaders(hfs)
-	if err != nil {
-		return nil, newStreamError(ErrCodeMessageError, err)
+// readResponse reads the response from a request stream or a push stream.
+// On request streams, PUSH_PROMISE frames are handled by the stream's onPushPromise callback.
+// Informational (1xx) responses are reported to the httptrace.ClientTrace of the request.
+func (c *client) readResponse(req *http.Request, conn quic.EarlyConnection, str quic.Stream, hstr *stream, requestGzip bool, reqDone chan<- struct{}) (*http.Response, requestError) {
+	trace := httptrace.ContextClientTrace(req.Context())
+	var res *http.Response
+	var num1xx int
+	for {
+		hfs, rerr := c.readHeaders(req, str, hstr)
+		if rerr.err != nil {
+			return nil, rerr
+		}
+		var err error
+		res, err = responseFromHeaders(hfs)
+		if err != nil {
+			return nil, newStreamError(ErrCodeMessageError, err)
+		}
+		if res.StatusCode < 100 || res.StatusCode >= 200 {
+			break
+		}
+		// HTTP/3 doesn't support the 101 status code, see section 4.5 of RFC 9114.
+		if res.StatusCode == http.StatusSwitchingProtocols {
+			return nil, newStreamError(ErrCodeMessageError, errors.New("received 101 Switching Protocols"))
+		}
+		if res.StatusCode == http.StatusContinue {
+			traceGot100Continue(trace)
+		}
+		// Limit the number of informational responses, unless the application handles them.
+		// This is what the standard library does.
+		if trace == nil || trace.Got1xxResponse == nil {
+			num1xx++
+			if num1xx > max1xxResponses {
+				return nil, newStreamError(ErrCodeExcessiveLoad, errors.New("too many 1xx informational responses"))
+			}
+			continue
+		}
+		if err := trace.Got1xxResponse(res.StatusCode, textproto.MIMEHeader(res.Header)); err != nil {
+			return nil, newStreamError(ErrCodeRequestCanceled, err)
+		}
 	}
 	connState := conn.ConnectionState().TLS
 	res.TLS = &connState
 	res.Request = req
+	// The trailers declared in the Trailer header are filled in once they're received after the body.
+	res.Trailer = declaredTrailers(res.Header)
+	hstr.onTrailers = func(f *headersFrame) error {
+		trailer, rerr := c.readTrailers(req.Context(), str, f)
+		if rerr.err != nil {
+			return abortOnError(conn, str, rerr)
+		}
+		if res.Trailer == nil {
+			res.Trailer = make(http.Header, len(trailer))
+		}
+		for k, v := range trailer {
+			res.Trailer[k] = v
+		}
+		return nil
+	}
 	// Check that the server doesn't send more data in DATA frames than indicated by the Content-Length header (if set).
 	// See section 4.1.2 of RFC 9114.
 	var httpStr Stream
@@ -444,13 +813,14 @@ func (c *client) doRequest(req *http.Request, conn quic.EarlyConnection, str qui
 		httpStr = hstr
 	}
 	respBody := newResponseBody(httpStr, conn, reqDone)
+	respBody.datagrams = c.datagrams
+	respBody.webTransport = c.webTransport
 
 	// Rules for when to set Content-Length are defined in https://tools.ietf.org/html/rfc7230#section-3.3.2.
 	_, hasTransferEncoding := res.Header["Transfer-Encoding"]
-	isInformational := res.StatusCode >= 100 && res.StatusCode < 200
 	isNoContent := res.StatusCode == http.StatusNoContent
 	isSuccessfulConnect := req.Method == http.MethodConnect && res.StatusCode >= 200 && res.StatusCode < 300
-	if !hasTransferEncoding && !isInformational && !isNoContent && !isSuccessfulConnect {
+	if !hasTransferEncoding && !isNoContent && !isSuccessfulConnect {
 		res.ContentLength = -1
 		if clens, ok := res.Header["Content-Length"]; ok && len(clens) == 1 {
 			if clen64, err := strconv.ParseInt(clens[0], 10, 64); err == nil {
@@ -472,6 +842,101 @@ func (c *client) doRequest(req *http.Request, conn quic.EarlyConnection, str qui
 	return res, requestError{}
 }
 
+// readHeaders reads and decodes the next header section of a response.
+func (c *client) readHeaders(req *http.Request, str quic.Stream, hstr *stream) ([]qpack.HeaderField, requestError) {
+	var hf *headersFrame
+	for hf == nil {
+		frame, err := parseNextFrame(str, nil)
+		if err != nil {
+			return nil, newStreamError(ErrCodeFrameError, err)
+		}
+		switch f := frame.(type) {
+		case *headersFrame:
+			hf = f
+		case *pushPromiseFrame:
+			if hstr.onPushPromise == nil {
+				return nil, newConnError(ErrCodeFrameUnexpected, errors.New("received PUSH_PROMISE frame on a push stream"))
+			}
+			if rerr := c.handlePushPromise(req.Context(), str, f); rerr.err != nil {
+				return nil, rerr
+			}
+		default:
+			return nil, newConnError(ErrCodeFrameUnexpected, errors.New("expected first frame to be a HEADERS frame"))
+		}
+	}
+	hfs, rerr := c.decodeHeaders(req.Context(), str, hf)
+	if rerr.err != nil {
+		return nil, rerr
+	}
+	if c.tracer != nil {
+		c.tracer.FrameParsed(str.StreamID(), hf.Length, &qlog.HTTP3HeadersFrame{HeaderFields: qlogHeaderFields(hfs)})
+	}
+	return hfs, requestError{}
+}
+
+// readTrailers reads and decodes the trailers sent after the response body.
+func (c *client) readTrailers(ctx context.Context, str quic.Stream, f *headersFrame) (http.Header, requestError) {
+	hfs, rerr := c.decodeHeaders(ctx, str, f)
+	if rerr.err != nil {
+		return nil, rerr
+	}
+	if c.tracer != nil {
+		c.tracer.FrameParsed(str.StreamID(), f.Length, &qlog.HTTP3HeadersFrame{HeaderFields: qlogHeaderFields(hfs)})
+	}
+	trailer, err := parseTrailers(hfs)
+	if err != nil {
+		return nil, newStreamError(ErrCodeMessageError, err)
+	}
+	return trailer, requestError{}
+}
+
+// decodeHeaders reads the payload of a HEADERS frame and decodes the header fields.
+func (c *client) decodeHeaders(ctx context.Context, str quic.Stream, hf *headersFrame) ([]qpack.HeaderField, requestError) {
+	if hf.Length > c.maxHeaderBytes() {
+		return nil, newStreamError(ErrCodeFrameError, fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, c.maxHeaderBytes()))
+	}
+	headerBlock := make([]byte, hf.Length)
+	if _, err := io.ReadFull(str, headerBlock); err != nil {
+		return nil, newStreamError(ErrCodeRequestIncomplete, err)
+	}
+	hfs, err := c.decoder.decode(ctx, str.StreamID(), headerBlock)
+	if err != nil {
+		if err == errQPACKTooManyBlockedStreams || ctx.Err() == nil {
+			return nil, newConnError(ErrCodeQPACKDecompressionFailed, err)
+		}
+		return nil, newStreamError(ErrCodeRequestCanceled, err)
+	}
+	return hfs, requestError{}
+}
+
+// abortOnError resets the stream or closes the connection, depending on the error.
+// It is used for errors that occur while the response body is read.
+func abortOnError(conn quic.Connection, str quic.Stream, rerr requestError) error {
+	if rerr.streamErr != 0 {
+		str.CancelRead(quic.StreamErrorCode(rerr.streamErr))
+	}
+	if rerr.connErr != 0 {
+		conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), rerr.err.Error())
+	}
+	return rerr.err
+}
+
+// handlePushPromise reads the header fields of a PUSH_PROMISE frame received on a request stream.
+func (c *client) handlePushPromise(ctx context.Context, str quic.Stream, f *pushPromiseFrame) requestError {
+	if c.pushes == nil {
+		// We never sent a MAX_PUSH_ID frame, so the server isn't allowed to push.
+		return newConnError(ErrCodeIDError, errors.New("received PUSH_PROMISE, but server push is disabled"))
+	}
+	if f.Length > c.maxHeaderBytes() {
+		return newStreamError(ErrCodeFrameError, fmt.Errorf("PUSH_PROMISE frame too large: %d bytes (max: %d)", f.Length, c.maxHeaderBytes()))
+	}
+	headerBlock := make([]byte, f.Length)
+	if _, err := io.ReadFull(str, headerBlock); err != nil {
+		return newStreamError(ErrCodeRequestIncomplete, err)
+	}
+	return c.pushes.handlePromise(ctx, str.StreamID(), f.PushID, headerBlock)
+}
+
 func (c *client) HandshakeComplete() bool {
 	conn := c.conn.Load()
 	if conn == nil {
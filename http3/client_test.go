@@ -8,7 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"sync"
 	"time"
 
@@ -219,6 +222,7 @@ var _ = Describe("Client", func() {
 				return len(b), nil
 			})
 			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 			conn.EXPECT().OpenUniStream().Return(controlStr, nil)
 			conn.EXPECT().HandshakeComplete().Return(handshakeChan)
 			conn.EXPECT().OpenStreamSync(gomock.Any()).Return(nil, errors.New("done"))
@@ -346,6 +350,7 @@ var _ = Describe("Client", func() {
 				return len(b), nil
 			})
 			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 			conn.EXPECT().OpenUniStream().Return(controlStr, nil)
 			conn.EXPECT().HandshakeComplete().Return(handshakeChan)
 			conn.EXPECT().OpenStreamSync(gomock.Any()).Return(nil, errors.New("done"))
@@ -453,6 +458,7 @@ var _ = Describe("Client", func() {
 				return len(b), nil
 			})
 			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 			conn.EXPECT().OpenUniStream().Return(controlStr, nil)
 			conn.EXPECT().HandshakeComplete().Return(handshakeChan)
 			conn.EXPECT().OpenStreamSync(gomock.Any()).Return(nil, errors.New("done"))
@@ -487,6 +493,81 @@ var _ = Describe("Client", func() {
 			time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to conn.CloseWithError
 		})
 
+		Context("Extended CONNECT", func() {
+			var connectReq *http.Request
+
+			BeforeEach(func() {
+				var err error
+				connectReq, err = http.NewRequest(http.MethodConnect, "https://quic.clemente.io:1337/chat", nil)
+				Expect(err).ToNot(HaveOccurred())
+				connectReq.Proto = "websocket"
+			})
+
+			acceptControlStream := func(sf *settingsFrame) {
+				b := quicvarint.Append(nil, streamTypeControlStream)
+				b = sf.Append(b)
+				r := bytes.NewReader(b)
+				controlStr := mockquic.NewMockStream(mockCtrl)
+				controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return controlStr, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+			}
+
+			It("sends Extended CONNECT requests after the server enabled them", func() {
+				acceptControlStream(&settingsFrame{ExtendedConnect: true})
+				_, err := cl.RoundTripOpt(connectReq, RoundTripOpt{})
+				Expect(err).To(MatchError("done"))
+				Expect(cl.settings.ExtendedConnect).To(BeTrue())
+			})
+
+			It("refuses to send Extended CONNECT requests if the server didn't enable them", func() {
+				acceptControlStream(&settingsFrame{})
+				_, err := cl.RoundTripOpt(connectReq, RoundTripOpt{})
+				Expect(err).To(MatchError(ErrExtendedConnectNotSupported))
+				// regular requests can still be sent
+				req.Method = MethodGet0RTT
+				_, err = cl.RoundTripOpt(req, RoundTripOpt{})
+				Expect(err).To(MatchError("done"))
+			})
+
+			It("refuses to send WebTransport requests if the server didn't enable WebTransport", func() {
+				acceptControlStream(&settingsFrame{ExtendedConnect: true})
+				connectReq.Proto = webTransportProtocol
+				_, err := cl.RoundTripOpt(connectReq, RoundTripOpt{})
+				Expect(err).To(MatchError(ErrWebTransportNotSupported))
+				// Extended CONNECT requests for other protocols can still be sent
+				connectReq.Proto = "websocket"
+				conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+				_, err = cl.RoundTripOpt(connectReq, RoundTripOpt{})
+				Expect(err).To(MatchError("done"))
+			})
+
+			It("cancels waiting for the SETTINGS frame when the request is canceled", func() {
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				ctx, cancel := context.WithCancel(context.Background())
+				errChan := make(chan error, 1)
+				go func() {
+					_, err := cl.RoundTripOpt(connectReq.WithContext(ctx), RoundTripOpt{})
+					errChan <- err
+				}()
+				Consistently(errChan, scaleDuration(20*time.Millisecond)).ShouldNot(Receive())
+				cancel()
+				Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+				// consume the OpenStreamSync call
+				req.Method = MethodGet0RTT
+				_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+				Expect(err).To(MatchError("done"))
+			})
+		})
+
 		for _, t := range []uint64{streamTypeQPACKEncoderStream, streamTypeQPACKDecoderStream} {
 			streamType := t
 			name := "encoder"
@@ -494,10 +575,35 @@ var _ = Describe("Client", func() {
 				name = "decoder"
 			}
 
-			It(fmt.Sprintf("ignores the QPACK %s streams", name), func() {
+			It(fmt.Sprintf("accepts the QPACK %s stream", name), func() {
+				buf := bytes.NewBuffer(quicvarint.Append(nil, streamType))
+				str := mockquic.NewMockStream(mockCtrl)
+				block := make(chan struct{}) // the QPACK stream stays open
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					if buf.Len() == 0 {
+						<-block
+					}
+					return buf.Read(b)
+				}).AnyTimes()
+
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return str, nil
+				})
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+				Expect(err).To(MatchError("done"))
+				time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to str.CancelRead or conn.CloseWithError
+			})
+
+			It(fmt.Sprintf("closes the connection when the QPACK %s stream is closed", name), func() {
 				buf := bytes.NewBuffer(quicvarint.Append(nil, streamType))
 				str := mockquic.NewMockStream(mockCtrl)
 				str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				closed := make(chan struct{})
+				conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeClosedCriticalStream), gomock.Any()).Do(func(quic.ApplicationErrorCode, string) error { close(closed); return nil })
 
 				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
 					return str, nil
@@ -508,7 +614,7 @@ var _ = Describe("Client", func() {
 				})
 				_, err := cl.RoundTripOpt(req, RoundTripOpt{})
 				Expect(err).To(MatchError("done"))
-				time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to str.CancelRead
+				Eventually(closed).Should(BeClosed())
 			})
 		}
 
@@ -554,6 +660,64 @@ var _ = Describe("Client", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("handles GOAWAY frames", func() {
+			var goAwayCalled int
+			cl.opts.onGoAway = func() { goAwayCalled++ }
+			b := quicvarint.Append(nil, streamTypeControlStream)
+			b = (&settingsFrame{}).Append(b)
+			b = (&goAwayFrame{StreamID: 8}).Append(b)
+			b = (&goAwayFrame{StreamID: 4}).Append(b)
+			b = (&goAwayFrame{StreamID: 12}).Append(b)
+			r := bytes.NewReader(b)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				return controlStr, nil
+			})
+			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			})
+			// there are no requests in flight, so the connection is closed right away
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeNoError), "")
+			done := make(chan struct{})
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), "GOAWAY stream ID increased from 4 to 12").Do(func(quic.ApplicationErrorCode, string) error {
+				close(done)
+				return nil
+			})
+			_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			Expect(err).To(MatchError("done"))
+			Eventually(done).Should(BeClosed())
+			Expect(goAwayCalled).To(Equal(1))
+			id, received, _ := cl.goAwayState()
+			Expect(received).To(BeTrue())
+			Expect(id).To(BeEquivalentTo(4))
+		})
+
+		It("errors when a GOAWAY frame contains an invalid stream ID", func() {
+			b := quicvarint.Append(nil, streamTypeControlStream)
+			b = (&settingsFrame{}).Append(b)
+			b = (&goAwayFrame{StreamID: 3}).Append(b)
+			r := bytes.NewReader(b)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				return controlStr, nil
+			})
+			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			})
+			done := make(chan struct{})
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), "GOAWAY frame for invalid stream ID 3").Do(func(quic.ApplicationErrorCode, string) error {
+				close(done)
+				return nil
+			})
+			_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			Expect(err).To(MatchError("done"))
+			Eventually(done).Should(BeClosed())
+		})
+
 		It("errors when parsing the frame on the control stream fails", func() {
 			b := quicvarint.Append(nil, streamTypeControlStream)
 			b = (&settingsFrame{}).Append(b)
@@ -600,6 +764,7 @@ var _ = Describe("Client", func() {
 
 		It("errors when the server advertises datagram support (and we enabled support for it)", func() {
 			cl.opts.EnableDatagram = true
+			conn.EXPECT().ReceiveDatagram(gomock.Any()).Return(nil, errors.New("done"))
 			b := quicvarint.Append(nil, streamTypeControlStream)
 			b = (&settingsFrame{Datagram: true}).Append(b)
 			r := bytes.NewReader(b)
@@ -655,8 +820,9 @@ var _ = Describe("Client", func() {
 		getResponse := func(status int) []byte {
 			buf := &bytes.Buffer{}
 			rstr := mockquic.NewMockStream(mockCtrl)
+			rstr.EXPECT().StreamID().AnyTimes()
 			rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
-			rw := newResponseWriter(rstr, nil, utils.DefaultLogger)
+			rw := newResponseWriter(rstr, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
 			rw.WriteHeader(status)
 			rw.Flush()
 			return buf.Bytes()
@@ -675,7 +841,9 @@ var _ = Describe("Client", func() {
 				return len(b), nil
 			}) // SETTINGS frame
 			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().AnyTimes()
 			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 			conn.EXPECT().OpenUniStream().Return(controlStr, nil)
 			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
 				<-testDone
@@ -703,6 +871,76 @@ var _ = Describe("Client", func() {
 			Expect(err).To(MatchError(testErr))
 		})
 
+		It("doesn't send requests after receiving a GOAWAY frame", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+			conn.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).MaxTimes(1)
+			// dial the connection
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(nil, errors.New("done"))
+			_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			Expect(err).To(MatchError("done"))
+
+			Expect(cl.handleGoAway(100)).To(Succeed())
+			conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+			_, err = cl.RoundTripOpt(req, RoundTripOpt{})
+			Expect(err).To(MatchError(errGoAway))
+			var nerr *requestNotProcessedError
+			Expect(errors.As(err, &nerr)).To(BeTrue())
+			Expect(nerr.sent).To(BeFalse())
+		})
+
+		It("cancels requests that the server won't process, according to its GOAWAY frame", func() {
+			str = mockquic.NewMockStream(mockCtrl) // replace the stream, since it needs to return a specific stream ID
+			conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().StreamID().Return(quic.StreamID(8)).AnyTimes()
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			canceled := make(chan struct{})
+			gomock.InOrder(
+				str.EXPECT().CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled)),
+				str.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled)).Do(func(quic.StreamErrorCode) { close(canceled) }),
+			)
+			str.EXPECT().CancelWrite(gomock.Any()).MaxTimes(1)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+				// a GOAWAY frame for a lower stream ID has no effect
+				Expect(cl.handleGoAway(12)).To(Succeed())
+				Expect(cl.handleGoAway(8)).To(Succeed())
+				<-canceled
+				return 0, &quic.StreamError{StreamID: 8, ErrorCode: quic.StreamErrorCode(ErrCodeRequestCanceled)}
+			})
+			// the connection is closed once the last request has completed
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeNoError), "")
+			_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			var nerr *requestNotProcessedError
+			Expect(errors.As(err, &nerr)).To(BeTrue())
+			Expect(nerr.sent).To(BeTrue())
+			Expect(nerr.err).To(MatchError(&Error{ErrorCode: ErrCodeRequestCanceled}))
+		})
+
+		It("marks requests rejected by the server as not processed", func() {
+			conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().CancelWrite(gomock.Any())
+			str.EXPECT().Read(gomock.Any()).Return(0, &quic.StreamError{StreamID: 4, ErrorCode: quic.StreamErrorCode(ErrCodeRequestRejected), Remote: true})
+			_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			var nerr *requestNotProcessedError
+			Expect(errors.As(err, &nerr)).To(BeTrue())
+			Expect(nerr.sent).To(BeTrue())
+			Expect(nerr.err).To(MatchError(&Error{ErrorCode: ErrCodeRequestRejected, Remote: true}))
+		})
+
+		It("marks requests as not processed when 0-RTT is rejected", func() {
+			req.Method = MethodGet0RTT
+			conn.EXPECT().OpenStreamSync(context.Background()).Return(nil, quic.Err0RTTRejected)
+			_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			Expect(err).To(MatchError(quic.Err0RTTRejected))
+			var nerr *requestNotProcessedError
+			Expect(errors.As(err, &nerr)).To(BeTrue())
+			Expect(nerr.sent).To(BeFalse())
+		})
+
 		It("performs a 0-RTT request", func() {
 			testErr := errors.New("stream open error")
 			req.Method = MethodGet0RTT
@@ -747,11 +985,300 @@ var _ = Describe("Client", func() {
 			)
 			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
 			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			// The request is in flight until the stream is closed.
+			strCtx, closeStr := context.WithCancel(context.Background())
+			str.EXPECT().Context().Return(strCtx).AnyTimes()
 			rsp, err := cl.RoundTripOpt(req, RoundTripOpt{DontCloseRequestStream: true})
 			Expect(err).ToNot(HaveOccurred())
 			Expect(rsp.Proto).To(Equal("HTTP/3.0"))
 			Expect(rsp.ProtoMajor).To(Equal(3))
 			Expect(rsp.StatusCode).To(Equal(418))
+			Consistently(func() int {
+				cl.goAwayMutex.Lock()
+				defer cl.goAwayMutex.Unlock()
+				return cl.activeRequests
+			}, scaleDuration(20*time.Millisecond)).Should(Equal(1))
+			closeStr()
+			Eventually(func() int {
+				cl.goAwayMutex.Lock()
+				defer cl.goAwayMutex.Unlock()
+				return cl.activeRequests
+			}).Should(BeZero())
+		})
+
+		It("reports informational responses to the client trace", func() {
+			buf := &bytes.Buffer{}
+			rstr := mockquic.NewMockStream(mockCtrl)
+			rstr.EXPECT().StreamID().AnyTimes()
+			rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
+			rw := newResponseWriter(rstr, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
+			rw.Header().Add("Link", "</style.css>; rel=preload; as=style")
+			rw.WriteHeader(http.StatusEarlyHints)
+			rw.WriteHeader(http.StatusTeapot)
+			rw.Flush()
+
+			gomock.InOrder(
+				conn.EXPECT().HandshakeComplete().Return(handshakeChan),
+				conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil),
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{}),
+			)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			var codes []int
+			var headers []textproto.MIMEHeader
+			req = req.WithContext(httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+					codes = append(codes, code)
+					headers = append(headers, header)
+					return nil
+				},
+			}))
+			rsp, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.StatusCode).To(Equal(http.StatusTeapot))
+			Expect(codes).To(Equal([]int{http.StatusEarlyHints}))
+			Expect(headers[0].Get("Link")).To(Equal("</style.css>; rel=preload; as=style"))
+		})
+
+		It("errors when receiving too many informational responses", func() {
+			buf := &bytes.Buffer{}
+			rstr := mockquic.NewMockStream(mockCtrl)
+			rstr.EXPECT().StreamID().AnyTimes()
+			rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
+			rw := newResponseWriter(rstr, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
+			for i := 0; i <= max1xxResponses; i++ {
+				rw.WriteHeader(http.StatusEarlyHints)
+			}
+			rw.WriteHeader(http.StatusOK)
+			rw.Flush()
+
+			conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+			conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(ErrCodeExcessiveLoad))
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			Expect(err).To(MatchError("too many 1xx informational responses"))
+		})
+
+		It("reads trailers", func() {
+			buf := &bytes.Buffer{}
+			rstr := mockquic.NewMockStream(mockCtrl)
+			rstr.EXPECT().StreamID().AnyTimes()
+			rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
+			rw := newResponseWriter(rstr, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
+			rw.Header().Set("Trailer", "Grpc-Status")
+			rw.Write([]byte("foobar"))
+			rw.Header().Set("Grpc-Status", "0")
+			rw.Header().Set(http.TrailerPrefix+"Grpc-Message", "ok")
+			rw.finish()
+
+			gomock.InOrder(
+				conn.EXPECT().HandshakeComplete().Return(handshakeChan),
+				conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil),
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{}),
+			)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			rsp, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Header).ToNot(HaveKey("Trailer"))
+			Expect(rsp.Trailer).To(Equal(http.Header{"Grpc-Status": nil}))
+			data, err := io.ReadAll(rsp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal("foobar"))
+			Expect(rsp.Trailer).To(Equal(http.Header{
+				"Grpc-Status":  []string{"0"},
+				"Grpc-Message": []string{"ok"},
+			}))
+		})
+
+		It("closes the stream when the trailers are malformed", func() {
+			buf := &bytes.Buffer{}
+			rstr := mockquic.NewMockStream(mockCtrl)
+			rstr.EXPECT().StreamID().AnyTimes()
+			rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
+			rw := newResponseWriter(rstr, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
+			rw.Write([]byte("foobar"))
+			rw.Flush()
+			Expect(rw.writeHeaderFields([]qpack.HeaderField{{Name: ":status", Value: "200"}})).To(Succeed())
+
+			gomock.InOrder(
+				conn.EXPECT().HandshakeComplete().Return(handshakeChan),
+				conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil),
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{}),
+			)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeMessageError))
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			rsp, err := cl.RoundTripOpt(req, RoundTripOpt{})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.ReadAll(rsp.Body)
+			Expect(err).To(MatchError("received pseudo header in trailer: :status"))
+		})
+
+		Context("tracing", func() {
+			var events []string
+
+			newTrace := func() *httptrace.ClientTrace {
+				events = nil
+				return &httptrace.ClientTrace{
+					GetConn: func(hostPort string) { events = append(events, "GetConn "+hostPort) },
+					GotConn: func(info httptrace.GotConnInfo) {
+						events = append(events, fmt.Sprintf("GotConn %t %s", info.Reused, info.Conn.RemoteAddr()))
+					},
+					TLSHandshakeStart: func() { events = append(events, "TLSHandshakeStart") },
+					TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+						events = append(events, fmt.Sprintf("TLSHandshakeDone %v", err))
+					},
+					WroteHeaders: func() { events = append(events, "WroteHeaders") },
+					WroteRequest: func(info httptrace.WroteRequestInfo) {
+						events = append(events, fmt.Sprintf("WroteRequest %v", info.Err))
+					},
+					GotFirstResponseByte: func() { events = append(events, "GotFirstResponseByte") },
+					Got100Continue:       func() { events = append(events, "Got100Continue") },
+				}
+			}
+
+			BeforeEach(func() {
+				conn.EXPECT().RemoteAddr().Return(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}).AnyTimes()
+			})
+
+			It("calls the hooks", func() {
+				rspBuf := bytes.NewBuffer(getResponse(418))
+				conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+				conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+				str.EXPECT().Close()
+				str.EXPECT().Peek().DoAndReturn(func() ([]byte, error) { return rspBuf.Bytes(), nil })
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				req = req.WithContext(httptrace.WithClientTrace(context.Background(), newTrace()))
+				_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(events).To(Equal([]string{
+					"GetConn quic.clemente.io:1337",
+					"GotConn false 192.168.0.1:1337",
+					"WroteHeaders",
+					"WroteRequest <nil>",
+					"GotFirstResponseByte",
+				}))
+
+				// The second request reuses the connection.
+				str = mockquic.NewMockStream(mockCtrl)
+				str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+				rspBuf = bytes.NewBuffer(getResponse(418))
+				conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+				conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+				str.EXPECT().Close()
+				str.EXPECT().Peek().DoAndReturn(func() ([]byte, error) { return rspBuf.Bytes(), nil })
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				req = req.WithContext(httptrace.WithClientTrace(context.Background(), newTrace()))
+				_, err = cl.RoundTripOpt(req, RoundTripOpt{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(events).To(Equal([]string{
+					"GetConn quic.clemente.io:1337",
+					"GotConn true 192.168.0.1:1337",
+					"WroteHeaders",
+					"WroteRequest <nil>",
+					"GotFirstResponseByte",
+				}))
+			})
+
+			It("reports when the request body was written", func() {
+				testErr := errors.New("read error")
+				req.Body = io.NopCloser(&errReader{err: testErr})
+				rspBuf := bytes.NewBuffer(getResponse(418))
+				conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+				conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+				str.EXPECT().Close().MaxTimes(1)
+				str.EXPECT().CancelWrite(gomock.Any()).MaxTimes(1)
+				written := make(chan struct{})
+				str.EXPECT().Peek().DoAndReturn(func() ([]byte, error) {
+					<-written
+					return rspBuf.Bytes(), nil
+				})
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+				trace := newTrace()
+				trace.WroteRequest = func(info httptrace.WroteRequestInfo) {
+					events = append(events, fmt.Sprintf("WroteRequest %v", info.Err))
+					close(written)
+				}
+				req = req.WithContext(httptrace.WithClientTrace(context.Background(), trace))
+				_, err := cl.RoundTripOpt(req, RoundTripOpt{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(events).To(ContainElement("WroteRequest read error"))
+			})
+
+			It("reports 100 Continue responses", func() {
+				buf := &bytes.Buffer{}
+				rstr := mockquic.NewMockStream(mockCtrl)
+				rstr.EXPECT().StreamID().AnyTimes()
+				rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
+				rw := newResponseWriter(rstr, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
+				rw.WriteHeader(http.StatusContinue)
+				rw.WriteHeader(http.StatusOK)
+				rw.Flush()
+
+				conn.EXPECT().HandshakeComplete().Return(handshakeChan)
+				conn.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+				str.EXPECT().Close()
+				str.EXPECT().Peek().DoAndReturn(func() ([]byte, error) { return buf.Bytes(), nil })
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				req = req.WithContext(httptrace.WithClientTrace(context.Background(), newTrace()))
+				rsp, err := cl.RoundTripOpt(req, RoundTripOpt{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+				Expect(events).To(ContainElement("Got100Continue"))
+			})
+
+			It("doesn't report requests that waited for the connection to be dialed as reused", func() {
+				dialing := make(chan struct{})
+				unblockDial := make(chan struct{})
+				dialAddr = func(context.Context, string, *tls.Config, *quic.Config) (quic.EarlyConnection, error) {
+					close(dialing)
+					<-unblockDial
+					return conn, nil
+				}
+				conn.EXPECT().HandshakeComplete().Return(handshakeChan).Times(3)
+				conn.EXPECT().OpenStreamSync(gomock.Any()).Return(nil, errors.New("test done")).Times(3)
+				gotConn := make(chan bool, 3)
+				trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) { gotConn <- info.Reused }}
+				roundTrip := func() {
+					_, err := cl.RoundTripOpt(req.WithContext(httptrace.WithClientTrace(context.Background(), trace)), RoundTripOpt{})
+					Expect(err).To(MatchError("test done"))
+				}
+
+				var wg sync.WaitGroup
+				wg.Add(2)
+				for i := 0; i < 2; i++ {
+					go func() {
+						defer GinkgoRecover()
+						defer wg.Done()
+						roundTrip()
+					}()
+				}
+				Eventually(dialing).Should(BeClosed())
+				time.Sleep(scaleDuration(10 * time.Millisecond)) // wait for the second request to wait for the dial
+				close(unblockDial)
+				wg.Wait()
+				Expect(gotConn).To(Receive(BeFalse()))
+				Expect(gotConn).To(Receive(BeFalse()))
+
+				// requests started after the connection was dialed reuse it
+				roundTrip()
+				Expect(gotConn).To(Receive(BeTrue()))
+			})
 		})
 
 		Context("requests containing a Body", func() {
@@ -993,8 +1520,9 @@ var _ = Describe("Client", func() {
 				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
 				buf := &bytes.Buffer{}
 				rstr := mockquic.NewMockStream(mockCtrl)
+				rstr.EXPECT().StreamID().AnyTimes()
 				rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
-				rw := newResponseWriter(rstr, nil, utils.DefaultLogger)
+				rw := newResponseWriter(rstr, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
 				rw.Header().Set("Content-Encoding", "gzip")
 				gz := gzip.NewWriter(rw)
 				gz.Write([]byte("gzipped response"))
@@ -1019,8 +1547,9 @@ var _ = Describe("Client", func() {
 				conn.EXPECT().ConnectionState().Return(quic.ConnectionState{})
 				buf := &bytes.Buffer{}
 				rstr := mockquic.NewMockStream(mockCtrl)
+				rstr.EXPECT().StreamID().AnyTimes()
 				rstr.EXPECT().Write(gomock.Any()).Do(buf.Write).AnyTimes()
-				rw := newResponseWriter(rstr, nil, utils.DefaultLogger)
+				rw := newResponseWriter(rstr, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
 				rw.Write([]byte("not gzipped"))
 				rw.Flush()
 				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
@@ -1,19 +1,30 @@
 package http3
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"sync"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/quicvarint"
 )
 
 const maxQuarterStreamID = 1<<60 - 1
 
-const streamDatagramQueueLen = 32
+// Datagrams received for a request stream are dropped
+// if more than streamDatagramQueueLen datagrams, or more than streamDatagramQueueBytes bytes, are queued.
+const (
+	streamDatagramQueueLen   = 32
+	streamDatagramQueueBytes = 32 << 10
+)
 
 type datagrammer struct {
 	sendDatagram func([]byte) error
 
-	hasData chan struct{}
-	queue   [][]byte // TODO: use a ring buffer
+	hasData     chan struct{}
+	queue       [][]byte // TODO: use a ring buffer
+	queuedBytes int
 
 	mx         sync.Mutex
 	sendErr    error
@@ -67,10 +78,11 @@ func (d *datagrammer) enqueue(data []byte) {
 	if d.receiveErr != nil {
 		return
 	}
-	if len(d.queue) >= streamDatagramQueueLen {
+	if len(d.queue) >= streamDatagramQueueLen || d.queuedBytes+len(data) > streamDatagramQueueBytes {
 		return
 	}
 	d.queue = append(d.queue, data)
+	d.queuedBytes += len(data)
 	d.signalHasData()
 }
 
@@ -80,6 +92,7 @@ start:
 	if len(d.queue) >= 1 {
 		data := d.queue[0]
 		d.queue = d.queue[1:]
+		d.queuedBytes -= len(data)
 		d.mx.Unlock()
 		return data, nil
 	}
@@ -96,3 +109,75 @@ start:
 	}
 	goto start
 }
+
+// A datagramRouter passes the HTTP datagrams received on a connection to the request stream they are associated with.
+// Datagrams are associated with a request stream by their quarter stream ID, see section 2.1 of RFC 9297.
+type datagramRouter struct {
+	conn quic.Connection
+
+	mutex   sync.Mutex
+	streams map[quic.StreamID]*datagrammer
+}
+
+func newDatagramRouter(conn quic.Connection) *datagramRouter {
+	return &datagramRouter{
+		conn:    conn,
+		streams: make(map[quic.StreamID]*datagrammer),
+	}
+}
+
+// add starts routing datagrams to a request stream.
+// The returned datagrammer sends datagrams associated with this stream.
+func (r *datagramRouter) add(id quic.StreamID) *datagrammer {
+	d := newDatagrammer(func(b []byte) error {
+		data := make([]byte, 0, int(quicvarint.Len(uint64(id/4)))+len(b))
+		data = quicvarint.Append(data, uint64(id/4))
+		data = append(data, b...)
+		return r.conn.SendDatagram(data)
+	})
+	r.mutex.Lock()
+	r.streams[id] = d
+	r.mutex.Unlock()
+	return d
+}
+
+func (r *datagramRouter) remove(id quic.StreamID) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.streams, id)
+}
+
+// handleDatagram passes a datagram to its request stream.
+// Datagrams for unknown streams are dropped.
+func (r *datagramRouter) handleDatagram(data []byte) error {
+	br := bytes.NewReader(data)
+	quarterStreamID, err := quicvarint.Read(br)
+	if err != nil {
+		return err
+	}
+	if quarterStreamID > maxQuarterStreamID {
+		return fmt.Errorf("invalid quarter stream ID: %d", quarterStreamID)
+	}
+	r.mutex.Lock()
+	d, ok := r.streams[quic.StreamID(4*quarterStreamID)]
+	r.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	d.enqueue(data[len(data)-br.Len():])
+	return nil
+}
+
+// run receives datagrams until the connection is closed.
+func (r *datagramRouter) run() {
+	for {
+		data, err := r.conn.ReceiveDatagram(context.Background())
+		if err != nil {
+			return
+		}
+		if err := r.handleDatagram(data); err != nil {
+			r.conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeDatagramError), err.Error())
+			return
+		}
+	}
+}
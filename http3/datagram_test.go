@@ -5,6 +5,12 @@ import (
 	"errors"
 	"time"
 
+	"github.com/nxenon/xquic-go"
+	mockquic "github.com/nxenon/xquic-go/internal/mocks/quic"
+	"github.com/nxenon/xquic-go/quicvarint"
+
+	"go.uber.org/mock/gomock"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -34,6 +40,24 @@ var _ = Describe("Datagrams", func() {
 		Expect(err).To(MatchError(context.Canceled))
 	})
 
+	It("limits the number of bytes queued", func() {
+		dg := newDatagrammer(nil)
+		dg.enqueue(make([]byte, streamDatagramQueueBytes-10))
+		dg.enqueue(make([]byte, 11)) // dropped
+		dg.enqueue(make([]byte, 10))
+		data, err := dg.Receive(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(HaveLen(streamDatagramQueueBytes - 10))
+		data, err = dg.Receive(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(HaveLen(10))
+		// the queue is empty again, so large datagrams can be queued
+		dg.enqueue(make([]byte, streamDatagramQueueBytes))
+		data, err = dg.Receive(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(HaveLen(streamDatagramQueueBytes))
+	})
+
 	It("blocks until a new datagram is received", func() {
 		dg := newDatagrammer(nil)
 		done := make(chan struct{})
@@ -73,4 +97,50 @@ var _ = Describe("Datagrams", func() {
 		Expect(dg.Send([]byte("foobar"))).To(MatchError(testErr))
 		Expect(sent).To(Equal([]byte("foobar")))
 	})
+
+	Context("routing", func() {
+		It("routes datagrams to their request stream", func() {
+			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			r := newDatagramRouter(conn)
+			d4 := r.add(4)
+			d8 := r.add(8)
+			Expect(r.handleDatagram(append(quicvarint.Append(nil, 2), []byte("foo")...))).To(Succeed())
+			Expect(r.handleDatagram(append(quicvarint.Append(nil, 1), []byte("bar")...))).To(Succeed())
+			// datagrams for unknown streams are dropped
+			Expect(r.handleDatagram(append(quicvarint.Append(nil, 42), []byte("baz")...))).To(Succeed())
+			data, err := d4.Receive(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("bar")))
+			data, err = d8.Receive(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foo")))
+
+			r.remove(4)
+			Expect(r.handleDatagram(append(quicvarint.Append(nil, 1), []byte("bar")...))).To(Succeed())
+			ctx, cancel := context.WithTimeout(context.Background(), scaleDuration(10*time.Millisecond))
+			defer cancel()
+			_, err = d4.Receive(ctx)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		})
+
+		It("prefixes sent datagrams with the quarter stream ID", func() {
+			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			r := newDatagramRouter(conn)
+			conn.EXPECT().SendDatagram(append(quicvarint.Append(nil, 100), []byte("foobar")...))
+			Expect(r.add(400).Send([]byte("foobar"))).To(Succeed())
+		})
+
+		It("closes the connection when receiving a datagram with an invalid quarter stream ID", func() {
+			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			r := newDatagramRouter(conn)
+			conn.EXPECT().ReceiveDatagram(gomock.Any()).Return(quicvarint.Append(nil, maxQuarterStreamID+1), nil)
+			done := make(chan struct{})
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeDatagramError), gomock.Any()).Do(func(quic.ApplicationErrorCode, string) error {
+				close(done)
+				return nil
+			})
+			go r.run()
+			Eventually(done).Should(BeClosed())
+		})
+	})
 })
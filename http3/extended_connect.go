@@ -0,0 +1,137 @@
+package http3
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+)
+
+// An ExtendedConnectStream is the stream of an Extended CONNECT request (RFC 9220) that was accepted by the server.
+// It exposes the stream as a bidirectional byte stream, on top of which protocols like WebSocket can run their framing.
+// All data is sent and received in HTTP/3 DATA frames.
+// If HTTP datagrams are enabled, datagrams associated with the stream can be sent and received as well.
+//
+// On the client side, it is returned by RoundTripper.ExtendedConnect.
+// On the server side, it is returned by AcceptExtendedConnect.
+type ExtendedConnectStream struct {
+	str                   Stream
+	localAddr, remoteAddr net.Addr
+
+	router             *datagramRouter
+	datagrams          *datagrammer // nil if HTTP datagrams are not enabled
+	closeDatagramsOnce sync.Once
+}
+
+var _ net.Conn = &ExtendedConnectStream{}
+
+var errDatagramsNotEnabled = errors.New("http3: HTTP datagrams not enabled")
+
+func newExtendedConnectStream(str Stream, localAddr, remoteAddr net.Addr, router *datagramRouter) *ExtendedConnectStream {
+	s := &ExtendedConnectStream{
+		str:        str,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		router:     router,
+	}
+	if router != nil {
+		s.datagrams = router.add(str.StreamID())
+	}
+	return s
+}
+
+// AcceptExtendedConnect accepts an Extended CONNECT request from inside an http.Handler.
+// Unless the handler already called WriteHeader, a 200 response is sent.
+// The response headers are flushed, and the handler takes over the stream:
+// it is not closed when the handler returns, and it's the caller's responsibility to close it.
+func AcceptExtendedConnect(w http.ResponseWriter, r *http.Request) (*ExtendedConnectStream, error) {
+	if !isExtendedConnect(r) {
+		return nil, errors.New("http3: not an Extended CONNECT request")
+	}
+	streamer, ok := r.Body.(HTTPStreamer)
+	if !ok {
+		return nil, errors.New("http3: request body doesn't allow taking over the stream")
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("http3: response writer doesn't implement http.Flusher")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	localAddr, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	remoteAddr, _ := r.Context().Value(RemoteAddrContextKey).(net.Addr)
+	router, _ := r.Context().Value(datagramRouterContextKey).(*datagramRouter)
+	return newExtendedConnectStream(streamer.HTTPStream(), localAddr, remoteAddr, router), nil
+}
+
+// StreamID returns the ID of the underlying QUIC stream.
+func (s *ExtendedConnectStream) StreamID() quic.StreamID {
+	return s.str.StreamID()
+}
+
+func (s *ExtendedConnectStream) Read(b []byte) (int, error) {
+	n, err := s.str.Read(b)
+	return n, maybeReplaceError(err)
+}
+
+func (s *ExtendedConnectStream) Write(b []byte) (int, error) {
+	n, err := s.str.Write(b)
+	return n, maybeReplaceError(err)
+}
+
+// CloseWrite closes the send direction of the stream.
+// The peer reads an io.EOF after it has read all data sent so far.
+func (s *ExtendedConnectStream) CloseWrite() error {
+	return s.str.Close()
+}
+
+// Close closes the send direction of the stream, and stops reading from it.
+// Datagrams can't be sent or received anymore.
+func (s *ExtendedConnectStream) Close() error {
+	s.closeDatagrams(net.ErrClosed)
+	s.str.CancelRead(quic.StreamErrorCode(ErrCodeNoError))
+	return s.str.Close()
+}
+
+// SendDatagram sends an HTTP datagram (RFC 9297) associated with the stream.
+// It requires HTTP datagrams to be enabled, and the peer to support them.
+func (s *ExtendedConnectStream) SendDatagram(b []byte) error {
+	if s.datagrams == nil {
+		return errDatagramsNotEnabled
+	}
+	return s.datagrams.Send(b)
+}
+
+// ReceiveDatagram receives the next HTTP datagram (RFC 9297) associated with the stream.
+// Datagrams received before the stream was accepted are dropped.
+func (s *ExtendedConnectStream) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	if s.datagrams == nil {
+		return nil, errDatagramsNotEnabled
+	}
+	return s.datagrams.Receive(ctx)
+}
+
+// closeDatagrams stops routing datagrams to this stream.
+// Sending and receiving datagrams returns err from now on.
+// Only the first call has an effect.
+func (s *ExtendedConnectStream) closeDatagrams(err error) {
+	if s.datagrams == nil {
+		return
+	}
+	s.closeDatagramsOnce.Do(func() {
+		s.datagrams.SetSendError(err)
+		s.datagrams.SetReceiveError(err)
+		s.router.remove(s.str.StreamID())
+	})
+}
+
+func (s *ExtendedConnectStream) LocalAddr() net.Addr  { return s.localAddr }
+func (s *ExtendedConnectStream) RemoteAddr() net.Addr { return s.remoteAddr }
+
+func (s *ExtendedConnectStream) SetDeadline(t time.Time) error      { return s.str.SetDeadline(t) }
+func (s *ExtendedConnectStream) SetReadDeadline(t time.Time) error  { return s.str.SetReadDeadline(t) }
+func (s *ExtendedConnectStream) SetWriteDeadline(t time.Time) error { return s.str.SetWriteDeadline(t) }
@@ -56,12 +56,30 @@ func parseNextFrame(r io.Reader, unknownFrameHandler unknownFrameHandlerFunc) (f
 			return &dataFrame{Length: l}, nil
 		case 0x1:
 			return &headersFrame{Length: l}, nil
+		case 0x3:
+			id, err := parseVarIntPayload(qr, l)
+			if err != nil {
+				return nil, err
+			}
+			return &cancelPushFrame{PushID: id}, nil
 		case 0x4:
 			return parseSettingsFrame(r, l)
-		case 0x3: // CANCEL_PUSH
-		case 0x5: // PUSH_PROMISE
-		case 0x7: // GOAWAY
-		case 0xd: // MAX_PUSH_ID
+		case 0x5:
+			return parsePushPromiseFrame(qr, l)
+		case 0x7:
+			id, err := parseVarIntPayload(qr, l)
+			if err != nil {
+				return nil, err
+			}
+			return &goAwayFrame{StreamID: id}, nil
+		case 0xf0700, 0xf0701:
+			return parsePriorityUpdateFrame(qr, l, t == 0xf0701)
+		case 0xd:
+			id, err := parseVarIntPayload(qr, l)
+			if err != nil {
+				return nil, err
+			}
+			return &maxPushIDFrame{PushID: id}, nil
 		}
 		// skip over unknown frames
 		if _, err := io.CopyN(io.Discard, qr, int64(l)); err != nil {
@@ -88,11 +106,103 @@ func (f *headersFrame) Append(b []byte) []byte {
 	return quicvarint.Append(b, f.Length)
 }
 
-const settingDatagram = 0x33
+// parseVarIntPayload parses the payload of a CANCEL_PUSH, GOAWAY or MAX_PUSH_ID frame,
+// which consists of a single variable-length integer.
+func parseVarIntPayload(r quicvarint.Reader, l uint64) (uint64, error) {
+	if l == 0 || l > 8 {
+		return 0, fmt.Errorf("unexpected length for a frame carrying a single varint: %d", l)
+	}
+	lr := io.LimitReader(r, int64(l))
+	id, err := quicvarint.Read(quicvarint.NewReader(lr))
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	if uint64(quicvarint.Len(id)) != l {
+		return 0, fmt.Errorf("unexpected length for a frame carrying a single varint: %d", l)
+	}
+	return id, nil
+}
+
+type cancelPushFrame struct {
+	PushID uint64
+}
+
+func (f *cancelPushFrame) Append(b []byte) []byte {
+	b = quicvarint.Append(b, 0x3)
+	b = quicvarint.Append(b, uint64(quicvarint.Len(f.PushID)))
+	return quicvarint.Append(b, f.PushID)
+}
+
+// A goAwayFrame carries a stream ID when sent by the server, and a push ID when sent by the client.
+type goAwayFrame struct {
+	StreamID uint64
+}
+
+func (f *goAwayFrame) Append(b []byte) []byte {
+	b = quicvarint.Append(b, 0x7)
+	b = quicvarint.Append(b, uint64(quicvarint.Len(f.StreamID)))
+	return quicvarint.Append(b, f.StreamID)
+}
+
+type maxPushIDFrame struct {
+	PushID uint64
+}
+
+func (f *maxPushIDFrame) Append(b []byte) []byte {
+	b = quicvarint.Append(b, 0xd)
+	b = quicvarint.Append(b, uint64(quicvarint.Len(f.PushID)))
+	return quicvarint.Append(b, f.PushID)
+}
+
+// A pushPromiseFrame is a PUSH_PROMISE frame.
+// Length is the length of the encoded field section that follows the push ID.
+type pushPromiseFrame struct {
+	PushID uint64
+	Length uint64
+}
+
+func parsePushPromiseFrame(r quicvarint.Reader, l uint64) (*pushPromiseFrame, error) {
+	lr := io.LimitReader(r, int64(l))
+	id, err := quicvarint.Read(quicvarint.NewReader(lr))
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("PUSH_PROMISE frame too short: %d bytes", l)
+		}
+		return nil, err
+	}
+	return &pushPromiseFrame{PushID: id, Length: l - uint64(quicvarint.Len(id))}, nil
+}
+
+func (f *pushPromiseFrame) Append(b []byte) []byte {
+	b = quicvarint.Append(b, 0x5)
+	b = quicvarint.Append(b, uint64(quicvarint.Len(f.PushID))+f.Length)
+	return quicvarint.Append(b, f.PushID)
+}
+
+const (
+	// SETTINGS_QPACK_MAX_TABLE_CAPACITY, see section 5 of RFC 9204
+	settingQPACKMaxTableCapacity = 0x1
+	// SETTINGS_QPACK_BLOCKED_STREAMS, see section 5 of RFC 9204
+	settingQPACKBlockedStreams = 0x7
+	// SETTINGS_ENABLE_CONNECT_PROTOCOL, see section 3 of RFC 9220
+	settingExtendedConnect = 0x8
+	// SETTINGS_H3_DATAGRAM, see section 2.1.1 of RFC 9297
+	settingDatagram = 0x33
+	// SETTINGS_ENABLE_WEBTRANSPORT, see section 3.1 of draft-ietf-webtrans-http3-02
+	settingEnableWebTransport = 0x2b603742
+)
 
 type settingsFrame struct {
-	Datagram bool
-	Other    map[uint64]uint64 // all settings that we don't explicitly recognize
+	Datagram        bool // HTTP Datagrams, RFC 9297
+	ExtendedConnect bool // Extended CONNECT, RFC 9220
+	WebTransport    bool // WebTransport, draft-ietf-webtrans-http3
+	// QPACK dynamic table settings, RFC 9204
+	QPACKMaxTableCapacity uint64
+	QPACKBlockedStreams   uint64
+	Other                 map[uint64]uint64 // all settings that we don't explicitly recognize
 }
 
 func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
@@ -108,7 +218,7 @@ func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 	}
 	frame := &settingsFrame{}
 	b := bytes.NewReader(buf)
-	var readDatagram bool
+	var readDatagram, readExtendedConnect, readWebTransport, readQPACKMaxTableCapacity, readQPACKBlockedStreams bool
 	for b.Len() > 0 {
 		id, err := quicvarint.Read(b)
 		if err != nil { // should not happen. We allocated the whole frame already.
@@ -120,6 +230,27 @@ func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 		}
 
 		switch id {
+		case settingQPACKMaxTableCapacity:
+			if readQPACKMaxTableCapacity {
+				return nil, fmt.Errorf("duplicate setting: %d", id)
+			}
+			readQPACKMaxTableCapacity = true
+			frame.QPACKMaxTableCapacity = val
+		case settingQPACKBlockedStreams:
+			if readQPACKBlockedStreams {
+				return nil, fmt.Errorf("duplicate setting: %d", id)
+			}
+			readQPACKBlockedStreams = true
+			frame.QPACKBlockedStreams = val
+		case settingExtendedConnect:
+			if readExtendedConnect {
+				return nil, fmt.Errorf("duplicate setting: %d", id)
+			}
+			readExtendedConnect = true
+			if val != 0 && val != 1 {
+				return nil, fmt.Errorf("invalid value for SETTINGS_ENABLE_CONNECT_PROTOCOL: %d", val)
+			}
+			frame.ExtendedConnect = val == 1
 		case settingDatagram:
 			if readDatagram {
 				return nil, fmt.Errorf("duplicate setting: %d", id)
@@ -129,6 +260,15 @@ func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 				return nil, fmt.Errorf("invalid value for H3_DATAGRAM: %d", val)
 			}
 			frame.Datagram = val == 1
+		case settingEnableWebTransport:
+			if readWebTransport {
+				return nil, fmt.Errorf("duplicate setting: %d", id)
+			}
+			readWebTransport = true
+			if val != 0 && val != 1 {
+				return nil, fmt.Errorf("invalid value for SETTINGS_ENABLE_WEBTRANSPORT: %d", val)
+			}
+			frame.WebTransport = val == 1
 		default:
 			if _, ok := frame.Other[id]; ok {
 				return nil, fmt.Errorf("duplicate setting: %d", id)
@@ -142,8 +282,8 @@ func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 	return frame, nil
 }
 
-func (f *settingsFrame) Append(b []byte) []byte {
-	b = quicvarint.Append(b, 0x4)
+// length returns the length of the frame payload
+func (f *settingsFrame) length() protocol.ByteCount {
 	var l protocol.ByteCount
 	for id, val := range f.Other {
 		l += quicvarint.Len(id) + quicvarint.Len(val)
@@ -151,14 +291,89 @@ func (f *settingsFrame) Append(b []byte) []byte {
 	if f.Datagram {
 		l += quicvarint.Len(settingDatagram) + quicvarint.Len(1)
 	}
-	b = quicvarint.Append(b, uint64(l))
+	if f.ExtendedConnect {
+		l += quicvarint.Len(settingExtendedConnect) + quicvarint.Len(1)
+	}
+	if f.WebTransport {
+		l += quicvarint.Len(settingEnableWebTransport) + quicvarint.Len(1)
+	}
+	if f.QPACKMaxTableCapacity > 0 {
+		l += quicvarint.Len(settingQPACKMaxTableCapacity) + quicvarint.Len(f.QPACKMaxTableCapacity)
+	}
+	if f.QPACKBlockedStreams > 0 {
+		l += quicvarint.Len(settingQPACKBlockedStreams) + quicvarint.Len(f.QPACKBlockedStreams)
+	}
+	return l
+}
+
+func (f *settingsFrame) Append(b []byte) []byte {
+	b = quicvarint.Append(b, 0x4)
+	b = quicvarint.Append(b, uint64(f.length()))
 	if f.Datagram {
 		b = quicvarint.Append(b, settingDatagram)
 		b = quicvarint.Append(b, 1)
 	}
+	if f.ExtendedConnect {
+		b = quicvarint.Append(b, settingExtendedConnect)
+		b = quicvarint.Append(b, 1)
+	}
+	if f.WebTransport {
+		b = quicvarint.Append(b, settingEnableWebTransport)
+		b = quicvarint.Append(b, 1)
+	}
+	if f.QPACKMaxTableCapacity > 0 {
+		b = quicvarint.Append(b, settingQPACKMaxTableCapacity)
+		b = quicvarint.Append(b, f.QPACKMaxTableCapacity)
+	}
+	if f.QPACKBlockedStreams > 0 {
+		b = quicvarint.Append(b, settingQPACKBlockedStreams)
+		b = quicvarint.Append(b, f.QPACKBlockedStreams)
+	}
 	for id, val := range f.Other {
 		b = quicvarint.Append(b, id)
 		b = quicvarint.Append(b, val)
 	}
 	return b
 }
+
+// maxPriorityUpdateFrameLen is the maximum length of a PRIORITY_UPDATE frame that we accept.
+// Priority Field Values are short, there's no reason to send long PRIORITY_UPDATE frames.
+const maxPriorityUpdateFrameLen = 1024
+
+// A priorityUpdateFrame is a PRIORITY_UPDATE frame, see section 7 of RFC 9218.
+// ID is the stream ID of a request stream, or a push ID.
+type priorityUpdateFrame struct {
+	IsPush             bool
+	ID                 uint64
+	PriorityFieldValue string
+}
+
+func parsePriorityUpdateFrame(r io.Reader, l uint64, isPush bool) (*priorityUpdateFrame, error) {
+	if l > maxPriorityUpdateFrameLen {
+		return nil, fmt.Errorf("PRIORITY_UPDATE frame too large: %d bytes", l)
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	br := bytes.NewReader(b)
+	id, err := quicvarint.Read(br)
+	if err != nil {
+		return nil, fmt.Errorf("PRIORITY_UPDATE frame too short: %d bytes", l)
+	}
+	return &priorityUpdateFrame{IsPush: isPush, ID: id, PriorityFieldValue: string(b[len(b)-br.Len():])}, nil
+}
+
+func (f *priorityUpdateFrame) Append(b []byte) []byte {
+	if f.IsPush {
+		b = quicvarint.Append(b, 0xf0701)
+	} else {
+		b = quicvarint.Append(b, 0xf0700)
+	}
+	b = quicvarint.Append(b, uint64(quicvarint.Len(f.ID))+uint64(len(f.PriorityFieldValue)))
+	b = quicvarint.Append(b, f.ID)
+	return append(b, f.PriorityFieldValue...)
+}
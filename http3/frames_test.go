@@ -69,6 +69,108 @@ var _ = Describe("Frames", func() {
 		})
 	})
 
+	Context("CANCEL_PUSH, GOAWAY and MAX_PUSH_ID frames", func() {
+		It("writes and parses CANCEL_PUSH frames", func() {
+			b := (&cancelPushFrame{PushID: 0x1337}).Append(nil)
+			frame, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&cancelPushFrame{PushID: 0x1337}))
+		})
+
+		It("writes and parses GOAWAY frames", func() {
+			b := (&goAwayFrame{StreamID: 100}).Append(nil)
+			frame, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&goAwayFrame{StreamID: 100}))
+		})
+
+		It("writes and parses MAX_PUSH_ID frames", func() {
+			b := (&maxPushIDFrame{PushID: 0xdeadbeef}).Append(nil)
+			frame, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&maxPushIDFrame{PushID: 0xdeadbeef}))
+		})
+
+		It("rejects frames with trailing data", func() {
+			b := quicvarint.Append(nil, 0xd) // type byte
+			b = quicvarint.Append(b, 2)
+			b = quicvarint.Append(b, 1)
+			b = append(b, 0)
+			_, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).To(MatchError("unexpected length for a frame carrying a single varint: 2"))
+		})
+
+		It("rejects empty frames", func() {
+			b := quicvarint.Append(nil, 0x3) // type byte
+			b = quicvarint.Append(b, 0)
+			_, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).To(MatchError("unexpected length for a frame carrying a single varint: 0"))
+		})
+
+		It("errors on EOF", func() {
+			b := (&maxPushIDFrame{PushID: 0xdeadbeef}).Append(nil)
+			_, err := parseNextFrame(bytes.NewReader(b[:len(b)-1]), nil)
+			Expect(err).To(MatchError(io.EOF))
+		})
+	})
+
+	Context("PRIORITY_UPDATE frames", func() {
+		It("writes and parses PRIORITY_UPDATE frames for request streams", func() {
+			b := (&priorityUpdateFrame{ID: 8, PriorityFieldValue: "u=1, i"}).Append(nil)
+			frame, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&priorityUpdateFrame{ID: 8, PriorityFieldValue: "u=1, i"}))
+		})
+
+		It("writes and parses PRIORITY_UPDATE frames for pushes", func() {
+			b := (&priorityUpdateFrame{IsPush: true, ID: 1337}).Append(nil)
+			frame, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&priorityUpdateFrame{IsPush: true, ID: 1337, PriorityFieldValue: ""}))
+		})
+
+		It("rejects frames that are too large", func() {
+			b := quicvarint.Append(nil, 0xf0700)
+			b = quicvarint.Append(b, maxPriorityUpdateFrameLen+1)
+			_, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).To(MatchError(fmt.Sprintf("PRIORITY_UPDATE frame too large: %d bytes", maxPriorityUpdateFrameLen+1)))
+		})
+
+		It("rejects frames without an ID", func() {
+			b := quicvarint.Append(nil, 0xf0700)
+			b = quicvarint.Append(b, 0)
+			_, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).To(MatchError("PRIORITY_UPDATE frame too short: 0 bytes"))
+		})
+
+		It("errors on EOF", func() {
+			b := (&priorityUpdateFrame{ID: 4, PriorityFieldValue: "u=2"}).Append(nil)
+			_, err := parseNextFrame(bytes.NewReader(b[:len(b)-1]), nil)
+			Expect(err).To(MatchError(io.EOF))
+		})
+	})
+
+	Context("PUSH_PROMISE frames", func() {
+		It("writes and parses", func() {
+			b := (&pushPromiseFrame{PushID: 0x1337, Length: 6}).Append(nil)
+			b = append(b, []byte("foobar")...)
+			r := bytes.NewReader(b)
+			frame, err := parseNextFrame(r, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&pushPromiseFrame{PushID: 0x1337, Length: 6}))
+			// the header block is not consumed
+			Expect(r.Len()).To(Equal(6))
+		})
+
+		It("rejects frames too short to contain a push ID", func() {
+			b := quicvarint.Append(nil, 0x5) // type byte
+			b = quicvarint.Append(b, 1)
+			b = append(b, 0x40) // the first byte of a 2 byte varint
+			_, err := parseNextFrame(bytes.NewReader(b), nil)
+			Expect(err).To(MatchError("PUSH_PROMISE frame too short: 1 bytes"))
+		})
+	})
+
 	Context("SETTINGS frames", func() {
 		It("parses", func() {
 			settings := quicvarint.Append(nil, 13)
@@ -100,7 +202,7 @@ var _ = Describe("Frames", func() {
 
 		It("writes", func() {
 			sf := &settingsFrame{Other: map[uint64]uint64{
-				1:  2,
+				2:  3,
 				99: 999,
 				13: 37,
 			}}
@@ -109,6 +211,37 @@ var _ = Describe("Frames", func() {
 			Expect(frame).To(Equal(sf))
 		})
 
+		Context("QPACK settings", func() {
+			It("writes and parses the QPACK settings", func() {
+				sf := &settingsFrame{QPACKMaxTableCapacity: 4096, QPACKBlockedStreams: 100}
+				b := sf.Append(nil)
+				Expect(b).To(HaveLen(int(sf.length()) + 2))
+				frame, err := parseNextFrame(bytes.NewReader(b), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame).To(Equal(sf))
+			})
+
+			It("doesn't write QPACK settings with a value of 0", func() {
+				Expect((&settingsFrame{}).Append(nil)).To(Equal([]byte{0x4, 0}))
+			})
+
+			for _, s := range []uint64{settingQPACKMaxTableCapacity, settingQPACKBlockedStreams} {
+				setting := s
+
+				It(fmt.Sprintf("rejects duplicate %#x settings", setting), func() {
+					settings := quicvarint.Append(nil, setting)
+					settings = quicvarint.Append(settings, 1)
+					settings = quicvarint.Append(settings, setting)
+					settings = quicvarint.Append(settings, 2)
+					data := quicvarint.Append(nil, 4) // type byte
+					data = quicvarint.Append(data, uint64(len(settings)))
+					data = append(data, settings...)
+					_, err := parseNextFrame(bytes.NewReader(data), nil)
+					Expect(err).To(MatchError(fmt.Sprintf("duplicate setting: %d", setting)))
+				})
+			}
+		})
+
 		It("errors on EOF", func() {
 			sf := &settingsFrame{Other: map[uint64]uint64{
 				13:         37,
@@ -170,6 +303,84 @@ var _ = Describe("Frames", func() {
 				Expect(frame).To(Equal(sf))
 			})
 		})
+
+		Context("SETTINGS_ENABLE_CONNECT_PROTOCOL", func() {
+			It("reads the SETTINGS_ENABLE_CONNECT_PROTOCOL value", func() {
+				settings := quicvarint.Append(nil, settingExtendedConnect)
+				settings = quicvarint.Append(settings, 1)
+				data := quicvarint.Append(nil, 4) // type byte
+				data = quicvarint.Append(data, uint64(len(settings)))
+				data = append(data, settings...)
+				f, err := parseNextFrame(bytes.NewReader(data), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(BeAssignableToTypeOf(&settingsFrame{}))
+				sf := f.(*settingsFrame)
+				Expect(sf.ExtendedConnect).To(BeTrue())
+				Expect(sf.Other).To(BeEmpty())
+			})
+
+			It("rejects duplicate SETTINGS_ENABLE_CONNECT_PROTOCOL entries", func() {
+				settings := quicvarint.Append(nil, settingExtendedConnect)
+				settings = quicvarint.Append(settings, 1)
+				settings = quicvarint.Append(settings, settingExtendedConnect)
+				settings = quicvarint.Append(settings, 0)
+				data := quicvarint.Append(nil, 4) // type byte
+				data = quicvarint.Append(data, uint64(len(settings)))
+				data = append(data, settings...)
+				_, err := parseNextFrame(bytes.NewReader(data), nil)
+				Expect(err).To(MatchError(fmt.Sprintf("duplicate setting: %d", settingExtendedConnect)))
+			})
+
+			It("rejects invalid values for the SETTINGS_ENABLE_CONNECT_PROTOCOL entry", func() {
+				settings := quicvarint.Append(nil, settingExtendedConnect)
+				settings = quicvarint.Append(settings, 2)
+				data := quicvarint.Append(nil, 4) // type byte
+				data = quicvarint.Append(data, uint64(len(settings)))
+				data = append(data, settings...)
+				_, err := parseNextFrame(bytes.NewReader(data), nil)
+				Expect(err).To(MatchError("invalid value for SETTINGS_ENABLE_CONNECT_PROTOCOL: 2"))
+			})
+
+			It("writes the SETTINGS_ENABLE_CONNECT_PROTOCOL setting", func() {
+				sf := &settingsFrame{ExtendedConnect: true, Datagram: true}
+				frame, err := parseNextFrame(bytes.NewReader(sf.Append(nil)), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame).To(Equal(sf))
+			})
+		})
+
+		Context("SETTINGS_ENABLE_WEBTRANSPORT", func() {
+			It("reads the SETTINGS_ENABLE_WEBTRANSPORT value", func() {
+				settings := quicvarint.Append(nil, settingEnableWebTransport)
+				settings = quicvarint.Append(settings, 1)
+				data := quicvarint.Append(nil, 4) // type byte
+				data = quicvarint.Append(data, uint64(len(settings)))
+				data = append(data, settings...)
+				f, err := parseNextFrame(bytes.NewReader(data), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(BeAssignableToTypeOf(&settingsFrame{}))
+				sf := f.(*settingsFrame)
+				Expect(sf.WebTransport).To(BeTrue())
+				Expect(sf.Other).To(BeEmpty())
+			})
+
+			It("rejects invalid values for the SETTINGS_ENABLE_WEBTRANSPORT entry", func() {
+				settings := quicvarint.Append(nil, settingEnableWebTransport)
+				settings = quicvarint.Append(settings, 42)
+				data := quicvarint.Append(nil, 4) // type byte
+				data = quicvarint.Append(data, uint64(len(settings)))
+				data = append(data, settings...)
+				_, err := parseNextFrame(bytes.NewReader(data), nil)
+				Expect(err).To(MatchError("invalid value for SETTINGS_ENABLE_WEBTRANSPORT: 42"))
+			})
+
+			It("writes the SETTINGS_ENABLE_WEBTRANSPORT setting", func() {
+				sf := &settingsFrame{WebTransport: true, ExtendedConnect: true, Datagram: true}
+				frame, err := parseNextFrame(bytes.NewReader(sf.Append(nil)), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame).To(Equal(sf))
+			})
+		})
 	})
 
 	Context("hijacking", func() {
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"strconv"
 	"strings"
@@ -112,8 +113,11 @@ func requestFromHeaders(headerFields []qpack.HeaderField) (*http.Request, error)
 	}
 
 	isConnect := hdr.Method == http.MethodConnect
-	// Extended CONNECT, see https://datatracker.ietf.org/doc/html/rfc8441#section-4
+	// Extended CONNECT, see section 4 of RFC 8441 and section 3 of RFC 9220
 	isExtendedConnected := isConnect && hdr.Protocol != ""
+	if hdr.Protocol != "" && !isConnect {
+		return nil, errors.New(":protocol is only allowed for the CONNECT method")
+	}
 	if isExtendedConnected {
 		if hdr.Scheme == "" || hdr.Path == "" || hdr.Authority == "" {
 			return nil, errors.New("extended CONNECT: :scheme, :path and :authority must not be empty")
@@ -128,7 +132,7 @@ func requestFromHeaders(headerFields []qpack.HeaderField) (*http.Request, error)
 
 	var u *url.URL
 	var requestURI string
-	var protocol string
+	protocol := "HTTP/3.0"
 
 	if isConnect {
 		u = &url.URL{}
@@ -137,18 +141,18 @@ func requestFromHeaders(headerFields []qpack.HeaderField) (*http.Request, error)
 			if err != nil {
 				return nil, err
 			}
+			// For Extended CONNECT requests, the protocol is exposed in the Proto field.
+			protocol = hdr.Protocol
 		} else {
 			u.Path = hdr.Path
 		}
 		u.Scheme = hdr.Scheme
 		u.Host = hdr.Authority
 		requestURI = hdr.Authority
-		protocol = hdr.Protocol
 	} else {
-		protocol = "HTTP/3.0"
 		u, err = url.ParseRequestURI(hdr.Path)
 		if err != nil {
-			return nil, fmt.Errorf("invalid content length: %w", err)
+			return nil, fmt.Errorf("invalid :path: %w", err)
 		}
 		requestURI = hdr.Path
 	}
@@ -167,6 +171,14 @@ func requestFromHeaders(headerFields []qpack.HeaderField) (*http.Request, error)
 	}, nil
 }
 
+// isExtendedConnect says if req is an Extended CONNECT request, see RFC 9220.
+// On the server side, requestFromHeaders sets the Proto field to the value of the :protocol pseudo header.
+// On the client side, the Proto field is used to set the :protocol pseudo header,
+// unless it is the default value set by http.NewRequest.
+func isExtendedConnect(req *http.Request) bool {
+	return req.Method == http.MethodConnect && req.Proto != "" && req.Proto != "HTTP/1.1" && req.Proto != "HTTP/3.0"
+}
+
 func hostnameFromRequest(req *http.Request) string {
 	if req.URL != nil {
 		return req.URL.Host
@@ -196,3 +208,44 @@ func responseFromHeaders(headerFields []qpack.HeaderField) (*http.Response, erro
 	rsp.Status = hdr.Status + " " + http.StatusText(status)
 	return rsp, nil
 }
+
+// declaredTrailers returns the trailers declared in the Trailer header, with nil values,
+// and removes the Trailer header. It returns nil if no trailers were declared.
+func declaredTrailers(header http.Header) http.Header {
+	vals, ok := header["Trailer"]
+	if !ok {
+		return nil
+	}
+	trailer := make(http.Header)
+	for _, v := range vals {
+		for _, k := range strings.Split(v, ",") {
+			if k = textproto.TrimString(k); k != "" {
+				trailer[http.CanonicalHeaderKey(k)] = nil
+			}
+		}
+	}
+	delete(header, "Trailer")
+	return trailer
+}
+
+// parseTrailers parses the header fields of a trailer section, see section 4.1 of RFC 9114.
+// Trailers must not contain pseudo header fields.
+func parseTrailers(headers []qpack.HeaderField) (http.Header, error) {
+	h := make(http.Header, len(headers))
+	for _, field := range headers {
+		if field.IsPseudo() {
+			return nil, fmt.Errorf("received pseudo header in trailer: %s", field.Name)
+		}
+		if strings.ToLower(field.Name) != field.Name {
+			return nil, fmt.Errorf("trailer field is not lower-case: %s", field.Name)
+		}
+		if !httpguts.ValidHeaderFieldName(field.Name) {
+			return nil, fmt.Errorf("invalid trailer field name: %q", field.Name)
+		}
+		if !httpguts.ValidHeaderFieldValue(field.Value) {
+			return nil, fmt.Errorf("invalid trailer field value for %s: %q", field.Name, field.Value)
+		}
+		h.Add(field.Name, field.Value)
+	}
+	return h, nil
+}
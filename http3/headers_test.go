@@ -221,7 +221,9 @@ var _ = Describe("Request", func() {
 			req, err := requestFromHeaders(headers)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(req.Method).To(Equal(http.MethodConnect))
+			Expect(req.Proto).To(Equal("HTTP/3.0"))
 			Expect(req.RequestURI).To(Equal("quic.clemente.io"))
+			Expect(isExtendedConnect(req)).To(BeFalse())
 		})
 
 		It("errors with missing authority in CONNECT method", func() {
@@ -258,6 +260,19 @@ var _ = Describe("Request", func() {
 			Expect(req.Proto).To(Equal("webtransport"))
 			Expect(req.URL.String()).To(Equal("ftp://quic.clemente.io/foo?val=1337"))
 			Expect(req.URL.Query().Get("val")).To(Equal("1337"))
+			Expect(isExtendedConnect(req)).To(BeTrue())
+		})
+
+		It("errors when the :protocol pseudo header is used with a method other than CONNECT", func() {
+			headers := []qpack.HeaderField{
+				{Name: ":protocol", Value: "websocket"},
+				{Name: ":scheme", Value: "https"},
+				{Name: ":method", Value: http.MethodGet},
+				{Name: ":authority", Value: "quic.clemente.io"},
+				{Name: ":path", Value: "/chat"},
+			}
+			_, err := requestFromHeaders(headers)
+			Expect(err).To(MatchError(":protocol is only allowed for the CONNECT method"))
 		})
 
 		It("errors with missing scheme", func() {
@@ -355,3 +370,33 @@ var _ = Describe("Response", func() {
 		Expect(err).To(MatchError("invalid response pseudo header: :method"))
 	})
 })
+
+var _ = Describe("Trailers", func() {
+	It("parses trailers", func() {
+		trailer, err := parseTrailers([]qpack.HeaderField{
+			{Name: "grpc-status", Value: "0"},
+			{Name: "foo", Value: "bar"},
+			{Name: "foo", Value: "baz"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(trailer).To(Equal(http.Header{
+			"Grpc-Status": []string{"0"},
+			"Foo":         []string{"bar", "baz"},
+		}))
+	})
+
+	It("rejects pseudo header fields", func() {
+		_, err := parseTrailers([]qpack.HeaderField{{Name: ":status", Value: "200"}})
+		Expect(err).To(MatchError("received pseudo header in trailer: :status"))
+	})
+
+	It("rejects upper-case field names", func() {
+		_, err := parseTrailers([]qpack.HeaderField{{Name: "Foo", Value: "bar"}})
+		Expect(err).To(MatchError("trailer field is not lower-case: Foo"))
+	})
+
+	It("rejects invalid field values", func() {
+		_, err := parseTrailers([]qpack.HeaderField{{Name: "foo", Value: "bar\r\n"}})
+		Expect(err).To(MatchError(`invalid trailer field value for foo: "bar\r\n"`))
+	})
+})
@@ -3,8 +3,10 @@ package http3
 import (
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/qlog"
 )
 
 // A Stream is a HTTP/3 stream.
@@ -18,15 +20,26 @@ type stream struct {
 
 	buf []byte
 
+	tracer *qlog.HTTP3Tracer // may be nil
+
 	onFrameError          func()
 	bytesRemainingInFrame uint64
+
+	// onPushPromise is called for PUSH_PROMISE frames received on request streams, on the client side.
+	// It reads the payload of the frame. If nil, PUSH_PROMISE frames are unexpected.
+	onPushPromise func(*pushPromiseFrame) error
+	// onTrailers is called for a HEADERS frame received after the header section.
+	// It reads the payload of the frame. If nil, HEADERS frames are skipped.
+	onTrailers       func(*headersFrame) error
+	receivedTrailers bool
 }
 
 var _ Stream = &stream{}
 
-func newStream(str quic.Stream, onFrameError func()) *stream {
+func newStream(str quic.Stream, tracer *qlog.HTTP3Tracer, onFrameError func()) *stream {
 	return &stream{
 		Stream:       str,
+		tracer:       tracer,
 		onFrameError: onFrameError,
 		buf:          make([]byte, 0, 16),
 	}
@@ -34,25 +47,8 @@ func newStream(str quic.Stream, onFrameError func()) *stream {
 
 func (s *stream) Read(b []byte) (int, error) {
 	if s.bytesRemainingInFrame == 0 {
-	parseLoop:
-		for {
-			frame, err := parseNextFrame(s.Stream, nil)
-			if err != nil {
-				return 0, err
-			}
-			switch f := frame.(type) {
-			case *headersFrame:
-				// skip HEADERS frames
-				continue
-			case *dataFrame:
-				s.bytesRemainingInFrame = f.Length
-				break parseLoop
-			default:
-				s.onFrameError()
-				// parseNextFrame skips over unknown frame types
-				// Therefore, this condition is only entered when we parsed another known frame type.
-				return 0, fmt.Errorf("peer sent an unexpected frame: %T", f)
-			}
+		if err := s.parseNextDataFrame(); err != nil {
+			return 0, err
 		}
 	}
 
@@ -67,19 +63,129 @@ func (s *stream) Read(b []byte) (int, error) {
 	return n, err
 }
 
+// parseNextDataFrame parses frames until it finds the next DATA frame.
+func (s *stream) parseNextDataFrame() error {
+	for {
+		frame, err := parseNextFrame(s.Stream, nil)
+		if err != nil {
+			return err
+		}
+		switch f := frame.(type) {
+		case *headersFrame:
+			if s.onTrailers == nil {
+				// skip HEADERS frames
+				if s.tracer != nil {
+					s.tracer.FrameParsed(s.StreamID(), f.Length, &qlog.HTTP3HeadersFrame{})
+				}
+				if _, err := io.CopyN(io.Discard, s.Stream, int64(f.Length)); err != nil {
+					return err
+				}
+				continue
+			}
+			// The trailer section is the last frame on the stream, see section 4.1 of RFC 9114.
+			if s.receivedTrailers {
+				s.onFrameError()
+				return errors.New("peer sent a HEADERS frame after the trailers")
+			}
+			s.receivedTrailers = true
+			// onTrailers decodes the trailers, and logs the HEADERS frame
+			if err := s.onTrailers(f); err != nil {
+				return err
+			}
+			continue
+		case *dataFrame:
+			if s.tracer != nil {
+				s.tracer.FrameParsed(s.StreamID(), f.Length, &qlog.HTTP3DataFrame{})
+			}
+			if s.receivedTrailers {
+				s.onFrameError()
+				return errors.New("peer sent a DATA frame after the trailers")
+			}
+			s.bytesRemainingInFrame = f.Length
+			return nil
+		case *pushPromiseFrame:
+			if s.onPushPromise == nil {
+				s.onFrameError()
+				return fmt.Errorf("peer sent an unexpected frame: %T", f)
+			}
+			if err := s.onPushPromise(f); err != nil {
+				return err
+			}
+			continue
+		default:
+			s.onFrameError()
+			// parseNextFrame skips over unknown frame types
+			// Therefore, this condition is only entered when we parsed another known frame type.
+			return fmt.Errorf("peer sent an unexpected frame: %T", f)
+		}
+	}
+}
+
+// Peek returns the payload of DATA frames without copying it.
+func (s *stream) Peek() ([]byte, error) {
+	for s.bytesRemainingInFrame == 0 {
+		if err := s.parseNextDataFrame(); err != nil {
+			return nil, err
+		}
+	}
+	b, err := s.Stream.Peek()
+	if uint64(len(b)) > s.bytesRemainingInFrame {
+		b = b[:s.bytesRemainingInFrame]
+	}
+	return b, err
+}
+
+func (s *stream) Consume(n int) error {
+	if n < 0 || uint64(n) > s.bytesRemainingInFrame {
+		return fmt.Errorf("cannot consume %d bytes, only %d bytes remaining in DATA frame", n, s.bytesRemainingInFrame)
+	}
+	if err := s.Stream.Consume(n); err != nil {
+		return err
+	}
+	s.bytesRemainingInFrame -= uint64(n)
+	return nil
+}
+
 func (s *stream) hasMoreData() bool {
 	return s.bytesRemainingInFrame > 0
 }
 
 func (s *stream) Write(b []byte) (int, error) {
-	s.buf = s.buf[:0]
-	s.buf = (&dataFrame{Length: uint64(len(b))}).Append(s.buf)
-	if _, err := s.Stream.Write(s.buf); err != nil {
+	if err := s.writeDataFrameHeader(len(b)); err != nil {
 		return 0, err
 	}
 	return s.Stream.Write(b)
 }
 
+// WriteBuffer writes b in a DATA frame, without copying it.
+func (s *stream) WriteBuffer(b []byte, release func()) (int, error) {
+	if err := s.writeDataFrameHeader(len(b)); err != nil {
+		if release != nil {
+			release()
+		}
+		return 0, err
+	}
+	return s.Stream.WriteBuffer(b, release)
+}
+
+// ReadFrom writes the data read from r in DATA frames.
+// It must be implemented, since the ReadFrom method of the QUIC stream would write the data without framing it.
+func (s *stream) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{s}, r)
+}
+
+func (s *stream) writeDataFrameHeader(l int) error {
+	s.buf = s.buf[:0]
+	s.buf = (&dataFrame{Length: uint64(l)}).Append(s.buf)
+	if _, err := s.Stream.Write(s.buf); err != nil {
+		return err
+	}
+	if s.tracer != nil {
+		s.tracer.FrameCreated(s.StreamID(), uint64(l), &qlog.HTTP3DataFrame{})
+	}
+	return nil
+}
+
 var errTooMuchData = errors.New("peer sent too much data")
 
 type lengthLimitedStream struct {
@@ -121,3 +227,25 @@ func (s *lengthLimitedStream) Read(b []byte) (int, error) {
 	}
 	return n, err
 }
+
+func (s *lengthLimitedStream) Peek() ([]byte, error) {
+	if err := s.checkContentLengthViolation(); err != nil {
+		return nil, err
+	}
+	b, err := s.stream.Peek()
+	if remaining := s.contentLength - s.read; int64(len(b)) > remaining {
+		b = b[:remaining]
+		if len(b) == 0 {
+			return nil, s.checkContentLengthViolation()
+		}
+	}
+	return b, err
+}
+
+func (s *lengthLimitedStream) Consume(n int) error {
+	if err := s.stream.Consume(n); err != nil {
+		return err
+	}
+	s.read += int64(n)
+	return s.checkContentLengthViolation()
+}
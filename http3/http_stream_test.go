@@ -34,7 +34,7 @@ var _ = Describe("Stream", func() {
 			qstr = mockquic.NewMockStream(mockCtrl)
 			qstr.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
 			qstr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
-			str = newStream(qstr, errorCb)
+			str = newStream(qstr, nil, errorCb)
 		})
 
 		It("reads DATA frames in a single run", func() {
@@ -108,6 +108,52 @@ var _ = Describe("Stream", func() {
 			Expect(r).To(Equal([]byte("foobar")))
 		})
 
+		It("passes trailers to the callback", func() {
+			var trailers []byte
+			str.(*stream).onTrailers = func(f *headersFrame) error {
+				trailers = make([]byte, f.Length)
+				_, err := io.ReadFull(buf, trailers)
+				return err
+			}
+			b := getDataFrame([]byte("foobar"))
+			b = (&headersFrame{Length: 3}).Append(b)
+			b = append(b, []byte("baz")...)
+			buf.Write(b)
+			data, err := io.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+			Expect(trailers).To(Equal([]byte("baz")))
+		})
+
+		It("errors on DATA frames after the trailers", func() {
+			str.(*stream).onTrailers = func(f *headersFrame) error {
+				_, err := io.CopyN(io.Discard, buf, int64(f.Length))
+				return err
+			}
+			b := (&headersFrame{Length: 3}).Append(nil)
+			b = append(b, []byte("baz")...)
+			b = append(b, getDataFrame([]byte("foobar"))...)
+			buf.Write(b)
+			_, err := str.Read([]byte{0})
+			Expect(err).To(MatchError("peer sent a DATA frame after the trailers"))
+			Expect(errorCbCalled).To(BeTrue())
+		})
+
+		It("errors on HEADERS frames after the trailers", func() {
+			str.(*stream).onTrailers = func(f *headersFrame) error {
+				_, err := io.CopyN(io.Discard, buf, int64(f.Length))
+				return err
+			}
+			b := (&headersFrame{Length: 3}).Append(nil)
+			b = append(b, []byte("baz")...)
+			b = (&headersFrame{Length: 3}).Append(b)
+			b = append(b, []byte("baz")...)
+			buf.Write(b)
+			_, err := str.Read([]byte{0})
+			Expect(err).To(MatchError("peer sent a HEADERS frame after the trailers"))
+			Expect(errorCbCalled).To(BeTrue())
+		})
+
 		It("errors when it can't parse the frame", func() {
 			buf.Write([]byte("invalid"))
 			_, err := str.Read([]byte{0})
@@ -128,7 +174,7 @@ var _ = Describe("Stream", func() {
 			buf := &bytes.Buffer{}
 			qstr := mockquic.NewMockStream(mockCtrl)
 			qstr.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
-			str := newStream(qstr, nil)
+			str := newStream(qstr, nil, nil)
 			str.Write([]byte("foo"))
 			str.Write([]byte("foobar"))
 
@@ -163,7 +209,7 @@ var _ = Describe("length-limited streams", func() {
 		qstr = mockquic.NewMockStream(mockCtrl)
 		qstr.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
 		qstr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
-		str = newStream(qstr, func() { Fail("didn't expect error callback to be called") })
+		str = newStream(qstr, nil, func() { Fail("didn't expect error callback to be called") })
 	})
 
 	It("reads all frames", func() {
@@ -0,0 +1,444 @@
+package http3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/qlog"
+)
+
+const maxUrgency = 7
+
+// maxPendingPriorityUpdates is the maximum number of PRIORITY_UPDATE frames we store
+// for request streams that the client hasn't opened yet.
+const maxPendingPriorityUpdates = 100
+
+// A Priority is the priority of a response, as defined by the Extensible Prioritization Scheme for HTTP (RFC 9218).
+type Priority struct {
+	// Urgency is the urgency of the response, from 0 (most urgent) to 7 (least urgent).
+	Urgency uint8
+	// Incremental says if the client can make use of partial responses.
+	// Data of incremental responses with the same urgency is interleaved,
+	// non-incremental responses are sent one after the other.
+	Incremental bool
+}
+
+// DefaultPriority is the priority of requests that don't carry any priority signals.
+var DefaultPriority = Priority{Urgency: 3}
+
+// String returns the Priority Field Value, see section 5 of RFC 9218.
+// Parameters that have their default value are omitted.
+func (p Priority) String() string {
+	var params []string
+	if p.Urgency != DefaultPriority.Urgency {
+		params = append(params, "u="+strconv.Itoa(int(p.Urgency)))
+	}
+	if p.Incremental {
+		params = append(params, "i")
+	}
+	return strings.Join(params, ", ")
+}
+
+// A Prioritizer changes the priority of a response.
+// The http.ResponseWriter passed to handlers implements this interface.
+//
+// Once the handler set the priority, the server ignores further priority updates sent by the client.
+type Prioritizer interface {
+	SetPriority(Priority) error
+}
+
+// priorityContextKey is used to pass the priority state of a request to the handler.
+var priorityContextKey = &contextKey{"priority"}
+
+// PriorityFromContext returns the priority of the request handled, as signaled by the client
+// in the priority header field and PRIORITY_UPDATE frames, or as set by the handler.
+// The priority can change while the request is being handled.
+func PriorityFromContext(ctx context.Context) (Priority, bool) {
+	p, ok := ctx.Value(priorityContextKey).(*priorityState)
+	if !ok {
+		return Priority{}, false
+	}
+	return p.get(), true
+}
+
+// priorityState is the priority of a request or a push.
+type priorityState struct {
+	tracer *qlog.HTTP3Tracer // may be nil
+
+	mutex        sync.Mutex
+	str          quic.SendStream // nil for pushes until the push stream is opened
+	priority     Priority
+	setByHandler bool
+}
+
+func newPriorityState(prio Priority, tracer *qlog.HTTP3Tracer) *priorityState {
+	return &priorityState{priority: prio, tracer: tracer}
+}
+
+func (p *priorityState) get() Priority {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.priority
+}
+
+// setStream sets the stream that the response is sent on, and applies the priority to it.
+func (p *priorityState) setStream(str quic.SendStream) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.str = str
+	p.apply()
+}
+
+// update updates the priority.
+// Updates by the client are ignored once the handler set the priority.
+func (p *priorityState) update(prio Priority, byHandler bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.setByHandler && !byHandler {
+		return
+	}
+	p.setByHandler = p.setByHandler || byHandler
+	old := p.priority
+	p.priority = prio
+	if p.str == nil {
+		return
+	}
+	if p.tracer != nil && old != prio {
+		p.tracer.PriorityUpdated(p.str.StreamID(), old.String(), prio.String())
+	}
+	p.apply()
+}
+
+// apply sets the priority of the QUIC stream. It must be called with the mutex held.
+func (p *priorityState) apply() {
+	p.str.SetPriority(quic.StreamPriority{Urgency: p.priority.Urgency, Incremental: p.priority.Incremental})
+}
+
+// requestPriorities keeps track of the priorities of the requests on a server connection.
+type requestPriorities struct {
+	tracer *qlog.HTTP3Tracer // may be nil
+
+	mutex        sync.Mutex
+	nextStreamID quic.StreamID // the client opened all request streams with lower stream IDs
+	requests     map[quic.StreamID]*priorityState
+	// PRIORITY_UPDATE frames for request streams that weren't opened yet
+	pending map[quic.StreamID]string
+}
+
+func newRequestPriorities(tracer *qlog.HTTP3Tracer) *requestPriorities {
+	return &requestPriorities{
+		tracer:   tracer,
+		requests: make(map[quic.StreamID]*priorityState),
+		pending:  make(map[quic.StreamID]string),
+	}
+}
+
+// newRequest applies the priority signaled in the priority header field to a new request stream.
+// A PRIORITY_UPDATE frame received before the request overrides the header field.
+func (p *requestPriorities) newRequest(str quic.SendStream, headerValues []string) *priorityState {
+	id := str.StreamID()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	prio := parsePriority(strings.Join(headerValues, ","))
+	if fieldValue, ok := p.pending[id]; ok {
+		prio = parsePriority(fieldValue)
+		delete(p.pending, id)
+	}
+	if id >= p.nextStreamID {
+		p.nextStreamID = id + 4
+	}
+	ps := newPriorityState(prio, p.tracer)
+	ps.setStream(str)
+	p.requests[id] = ps
+	return ps
+}
+
+func (p *requestPriorities) remove(id quic.StreamID) {
+	p.mutex.Lock()
+	delete(p.requests, id)
+	p.mutex.Unlock()
+}
+
+// handlePriorityUpdate handles a PRIORITY_UPDATE frame for a request stream.
+func (p *requestPriorities) handlePriorityUpdate(id uint64, fieldValue string) error {
+	if id%4 != 0 {
+		return fmt.Errorf("received PRIORITY_UPDATE for invalid stream ID %d", id)
+	}
+	p.mutex.Lock()
+	ps, ok := p.requests[quic.StreamID(id)]
+	if !ok {
+		// The client might send the PRIORITY_UPDATE frame before opening the request stream.
+		// If the request was already completed, there's nothing to do.
+		if quic.StreamID(id) >= p.nextStreamID && len(p.pending) < maxPendingPriorityUpdates {
+			p.pending[quic.StreamID(id)] = fieldValue
+		}
+		p.mutex.Unlock()
+		return nil
+	}
+	p.mutex.Unlock()
+	ps.update(parsePriority(fieldValue), false)
+	return nil
+}
+
+// parsePriority parses a Priority Field Value, see section 4 of RFC 9218.
+// The field value is a Structured Fields Dictionary (RFC 8941).
+// Unknown parameters, and parameters with invalid values are ignored.
+// If the field value can't be parsed, the default priority is returned.
+func parsePriority(s string) Priority {
+	prio := DefaultPriority
+	u, i, ok := parsePriorityDictionary(s)
+	if !ok {
+		return prio
+	}
+	if u, ok := u.(int64); ok && u >= 0 && u <= maxUrgency {
+		prio.Urgency = uint8(u)
+	}
+	if i, ok := i.(bool); ok {
+		prio.Incremental = i
+	}
+	return prio
+}
+
+// parsePriorityDictionary parses a Structured Fields Dictionary,
+// and returns the values of the last members with the keys "u" and "i" (nil if there's no such member).
+func parsePriorityDictionary(s string) (u, i any, ok bool) {
+	p := sfParser{s: s}
+	p.skipSP()
+	if p.done() {
+		return nil, nil, true
+	}
+	for {
+		key, ok := p.parseKey()
+		if !ok {
+			return nil, nil, false
+		}
+		var val any = true
+		if p.consume('=') {
+			if p.peek() == '(' {
+				ok = p.parseInnerList()
+				val = nil
+			} else {
+				val, ok = p.parseBareItem()
+			}
+			if !ok {
+				return nil, nil, false
+			}
+		}
+		if !p.parseParameters() {
+			return nil, nil, false
+		}
+		switch key {
+		case "u":
+			u = val
+		case "i":
+			i = val
+		}
+		p.skipOWS()
+		if p.done() {
+			return u, i, true
+		}
+		if !p.consume(',') {
+			return nil, nil, false
+		}
+		p.skipOWS()
+		if p.done() { // trailing comma
+			return nil, nil, false
+		}
+	}
+}
+
+// sfParser parses the parts of RFC 8941 Structured Fields needed for the Priority Field Value.
+type sfParser struct {
+	s   string
+	pos int
+}
+
+func (p *sfParser) done() bool { return p.pos >= len(p.s) }
+
+func (p *sfParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *sfParser) consume(c byte) bool {
+	if p.done() || p.s[p.pos] != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *sfParser) skipSP() {
+	for p.consume(' ') {
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for p.consume(' ') || p.consume('\t') {
+	}
+}
+
+func isLCAlpha(c byte) bool { return c >= 'a' && c <= 'z' }
+func isAlpha(c byte) bool   { return isLCAlpha(c) || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool   { return c >= '0' && c <= '9' }
+
+func isTChar(c byte) bool {
+	return isAlpha(c) || isDigit(c) || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+func (p *sfParser) parseKey() (string, bool) {
+	start := p.pos
+	if c := p.peek(); !isLCAlpha(c) && c != '*' {
+		return "", false
+	}
+	for p.pos++; !p.done(); p.pos++ {
+		c := p.s[p.pos]
+		if !isLCAlpha(c) && !isDigit(c) && strings.IndexByte("_-.*", c) < 0 {
+			break
+		}
+	}
+	return p.s[start:p.pos], true
+}
+
+// parseBareItem parses a bare item.
+// Integers are returned as int64, booleans as bool. For all other types, the value is nil.
+func (p *sfParser) parseBareItem() (any, bool) {
+	c := p.peek()
+	switch {
+	case c == '-' || isDigit(c):
+		return p.parseNumber()
+	case c == '"':
+		return nil, p.parseString()
+	case c == ':':
+		return nil, p.parseByteSequence()
+	case c == '?':
+		p.pos++
+		if p.consume('0') {
+			return false, true
+		}
+		if p.consume('1') {
+			return true, true
+		}
+		return nil, false
+	case isAlpha(c) || c == '*':
+		for p.pos++; !p.done(); p.pos++ {
+			if c := p.s[p.pos]; !isTChar(c) && c != ':' && c != '/' {
+				break
+			}
+		}
+		return nil, true
+	default:
+		return nil, false
+	}
+}
+
+// parseNumber parses an integer or a decimal. Decimals are returned as nil.
+func (p *sfParser) parseNumber() (any, bool) {
+	start := p.pos
+	p.consume('-')
+	digitsStart := p.pos
+	for !p.done() && isDigit(p.s[p.pos]) {
+		p.pos++
+	}
+	numDigits := p.pos - digitsStart
+	if numDigits == 0 {
+		return nil, false
+	}
+	if p.consume('.') {
+		fracStart := p.pos
+		for !p.done() && isDigit(p.s[p.pos]) {
+			p.pos++
+		}
+		if numFrac := p.pos - fracStart; numDigits > 12 || numFrac == 0 || numFrac > 3 {
+			return nil, false
+		}
+		return nil, true
+	}
+	if numDigits > 15 {
+		return nil, false
+	}
+	n, err := strconv.ParseInt(p.s[start:p.pos], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return n, true
+}
+
+func (p *sfParser) parseString() bool {
+	p.pos++ // opening DQUOTE
+	for !p.done() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '"':
+			return true
+		case c == '\\':
+			if next := p.peek(); next != '"' && next != '\\' {
+				return false
+			}
+			p.pos++
+		case c < 0x20 || c > 0x7e:
+			return false
+		}
+	}
+	return false
+}
+
+func (p *sfParser) parseByteSequence() bool {
+	p.pos++ // opening colon
+	for !p.done() {
+		c := p.s[p.pos]
+		p.pos++
+		if c == ':' {
+			return true
+		}
+		if !isAlpha(c) && !isDigit(c) && c != '+' && c != '/' && c != '=' {
+			return false
+		}
+	}
+	return false
+}
+
+func (p *sfParser) parseInnerList() bool {
+	p.pos++ // opening parenthesis
+	for !p.done() {
+		p.skipSP()
+		if p.consume(')') {
+			return p.parseParameters()
+		}
+		if _, ok := p.parseBareItem(); !ok {
+			return false
+		}
+		if !p.parseParameters() {
+			return false
+		}
+		if c := p.peek(); c != ' ' && c != ')' {
+			return false
+		}
+	}
+	return false
+}
+
+func (p *sfParser) parseParameters() bool {
+	for p.consume(';') {
+		p.skipSP()
+		if _, ok := p.parseKey(); !ok {
+			return false
+		}
+		if p.consume('=') {
+			if _, ok := p.parseBareItem(); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
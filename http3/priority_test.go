@@ -0,0 +1,230 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/nxenon/xquic-go"
+	mockquic "github.com/nxenon/xquic-go/internal/mocks/quic"
+	"github.com/nxenon/xquic-go/internal/testdata"
+	"github.com/nxenon/xquic-go/internal/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("Priorities", func() {
+	Context("parsing the Priority Field Value", func() {
+		It("uses the default priority for empty field values", func() {
+			Expect(parsePriority("")).To(Equal(DefaultPriority))
+			Expect(parsePriority("   ")).To(Equal(DefaultPriority))
+		})
+
+		It("parses the urgency and the incremental flag", func() {
+			Expect(parsePriority("u=5")).To(Equal(Priority{Urgency: 5}))
+			Expect(parsePriority("i")).To(Equal(Priority{Urgency: 3, Incremental: true}))
+			Expect(parsePriority("u=0, i")).To(Equal(Priority{Urgency: 0, Incremental: true}))
+			Expect(parsePriority("i=?1,u=7")).To(Equal(Priority{Urgency: 7, Incremental: true}))
+			Expect(parsePriority("u=1, i=?0")).To(Equal(Priority{Urgency: 1}))
+			Expect(parsePriority(" u=2 ,\ti ")).To(Equal(Priority{Urgency: 2, Incremental: true}))
+		})
+
+		It("uses the last value if a key is repeated", func() {
+			Expect(parsePriority("u=1, u=2")).To(Equal(Priority{Urgency: 2}))
+			Expect(parsePriority("i, i=?0")).To(Equal(Priority{Urgency: 3}))
+		})
+
+		It("ignores unknown parameters and parameters with invalid values", func() {
+			Expect(parsePriority("u=8, i")).To(Equal(Priority{Urgency: 3, Incremental: true}))
+			Expect(parsePriority("u=-1")).To(Equal(DefaultPriority))
+			Expect(parsePriority("u=1.5")).To(Equal(DefaultPriority))
+			Expect(parsePriority("u=high, i=1")).To(Equal(DefaultPriority))
+			Expect(parsePriority("u=?1")).To(Equal(DefaultPriority))
+			Expect(parsePriority(`u=1;foo=bar, foo="a\"b", bar=:aGVsbG8=:, baz=(1 "two" ?0);p, i`)).To(Equal(Priority{Urgency: 1, Incremental: true}))
+		})
+
+		It("ignores field values that are not a valid dictionary", func() {
+			Expect(parsePriority("U=1")).To(Equal(DefaultPriority))
+			Expect(parsePriority("u=1,")).To(Equal(DefaultPriority))
+			Expect(parsePriority("u=1 i")).To(Equal(DefaultPriority))
+			Expect(parsePriority(`u=1, foo="bar`)).To(Equal(DefaultPriority))
+			Expect(parsePriority("u=1, foo=(1 2")).To(Equal(DefaultPriority))
+			Expect(parsePriority("u=1234567890123456")).To(Equal(DefaultPriority))
+		})
+
+		It("serializes the priority", func() {
+			Expect(DefaultPriority.String()).To(BeEmpty())
+			Expect(Priority{Urgency: 1, Incremental: true}.String()).To(Equal("u=1, i"))
+			Expect(Priority{Urgency: 3, Incremental: true}.String()).To(Equal("i"))
+			for u := uint8(0); u <= maxUrgency; u++ {
+				for _, i := range []bool{true, false} {
+					p := Priority{Urgency: u, Incremental: i}
+					Expect(parsePriority(p.String())).To(Equal(p))
+				}
+			}
+		})
+	})
+
+	Context("request priorities", func() {
+		var priorities *requestPriorities
+
+		BeforeEach(func() {
+			priorities = newRequestPriorities(nil)
+		})
+
+		newStream := func(id quic.StreamID) *mockquic.MockStream {
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(id).AnyTimes()
+			return str
+		}
+
+		It("applies the priority from the header field", func() {
+			str := newStream(0)
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 1, Incremental: true})
+			p := priorities.newRequest(str, []string{"u=1", "i"})
+			Expect(p.get()).To(Equal(Priority{Urgency: 1, Incremental: true}))
+		})
+
+		It("uses the default priority if the request doesn't carry a priority header field", func() {
+			str := newStream(0)
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 3})
+			Expect(priorities.newRequest(str, nil).get()).To(Equal(DefaultPriority))
+		})
+
+		It("handles PRIORITY_UPDATE frames", func() {
+			str := newStream(4)
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 3})
+			p := priorities.newRequest(str, nil)
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 6})
+			Expect(priorities.handlePriorityUpdate(4, "u=6")).To(Succeed())
+			Expect(p.get()).To(Equal(Priority{Urgency: 6}))
+		})
+
+		It("applies PRIORITY_UPDATE frames received before the request", func() {
+			Expect(priorities.handlePriorityUpdate(8, "u=0")).To(Succeed())
+			str := newStream(8)
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 0})
+			Expect(priorities.newRequest(str, []string{"u=5"}).get()).To(Equal(Priority{Urgency: 0}))
+			Expect(priorities.pending).To(BeEmpty())
+		})
+
+		It("ignores PRIORITY_UPDATE frames for completed requests", func() {
+			str := newStream(4)
+			str.EXPECT().SetPriority(gomock.Any())
+			priorities.newRequest(str, nil)
+			priorities.remove(4)
+			Expect(priorities.handlePriorityUpdate(4, "u=1")).To(Succeed())
+			Expect(priorities.handlePriorityUpdate(0, "u=1")).To(Succeed())
+			Expect(priorities.pending).To(BeEmpty())
+		})
+
+		It("limits the number of PRIORITY_UPDATE frames for requests that weren't opened yet", func() {
+			for i := 0; i < 2*maxPendingPriorityUpdates; i++ {
+				Expect(priorities.handlePriorityUpdate(uint64(4*i), "u=1")).To(Succeed())
+			}
+			Expect(priorities.pending).To(HaveLen(maxPendingPriorityUpdates))
+		})
+
+		It("rejects PRIORITY_UPDATE frames for invalid stream IDs", func() {
+			Expect(priorities.handlePriorityUpdate(2, "u=1")).To(MatchError("received PRIORITY_UPDATE for invalid stream ID 2"))
+		})
+
+		It("ignores priority updates by the client once the handler set the priority", func() {
+			str := newStream(0)
+			str.EXPECT().SetPriority(gomock.Any())
+			p := priorities.newRequest(str, nil)
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 0, Incremental: true})
+			p.update(Priority{Urgency: 0, Incremental: true}, true)
+			Expect(priorities.handlePriorityUpdate(0, "u=7")).To(Succeed())
+			Expect(p.get()).To(Equal(Priority{Urgency: 0, Incremental: true}))
+		})
+
+		It("closes the connection when receiving a PRIORITY_UPDATE for an invalid stream ID on the control stream", func() {
+			s := &Server{}
+			b := (&priorityUpdateFrame{ID: 1, PriorityFieldValue: "u=1"}).Append(nil)
+			str := mockquic.NewMockStream(mockCtrl)
+			r := bytes.NewReader(b)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), "received PRIORITY_UPDATE for invalid stream ID 1")
+			s.handleControlStream(conn, str, nil, newServerPushes(), priorities)
+		})
+	})
+
+	It("exposes the priority on the context", func() {
+		_, ok := PriorityFromContext(context.Background())
+		Expect(ok).To(BeFalse())
+		ctx := context.WithValue(context.Background(), priorityContextKey, newPriorityState(Priority{Urgency: 2}, nil))
+		p, ok := PriorityFromContext(ctx)
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(Priority{Urgency: 2}))
+	})
+
+	Context("setting the priority from the handler", func() {
+		It("sets the priority", func() {
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().AnyTimes()
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 3})
+			rw := newResponseWriter(str, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
+			rw.priority = newRequestPriorities(nil).newRequest(str, nil)
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 7})
+			Expect(rw.SetPriority(Priority{Urgency: 7})).To(Succeed())
+			Expect(rw.priority.get()).To(Equal(Priority{Urgency: 7}))
+		})
+
+		It("rejects invalid urgencies", func() {
+			str := mockquic.NewMockStream(mockCtrl)
+			rw := newResponseWriter(str, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
+			rw.priority = newPriorityState(DefaultPriority, nil)
+			Expect(rw.SetPriority(Priority{Urgency: 8})).To(MatchError("http3: invalid urgency: 8"))
+		})
+
+		It("errors if the response can't be prioritized", func() {
+			rw := newResponseWriter(mockquic.NewMockStream(mockCtrl), nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
+			Expect(rw.SetPriority(Priority{Urgency: 1})).To(MatchError(http.ErrNotSupported))
+		})
+	})
+
+	It("passes the priority to the handler", func() {
+		s := &Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PriorityFromContext(r.Context())
+			if !ok {
+				panic("no priority")
+			}
+			if err := w.(Prioritizer).SetPriority(Priority{Urgency: p.Urgency + 1}); err != nil {
+				panic(err)
+			}
+			newPrio, _ := PriorityFromContext(r.Context())
+			fmt.Fprintf(w, "%s | %s", p, newPrio)
+		})}
+		ln, err := quic.ListenAddrEarly("localhost:0", ConfigureTLSConfig(testdata.GetTLSConfig()), nil)
+		Expect(err).ToNot(HaveOccurred())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			s.ServeListener(ln)
+		}()
+		defer func() {
+			Expect(s.Close()).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		}()
+
+		rt := &RoundTripper{TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()}}
+		defer rt.Close()
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost:%d/", ln.Addr().(*net.UDPAddr).Port), nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Priority", "u=1, i")
+		rsp, err := rt.RoundTrip(req)
+		Expect(err).ToNot(HaveOccurred())
+		body, err := io.ReadAll(rsp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal("u=1, i | u=2"))
+	})
+})
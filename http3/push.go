@@ -0,0 +1,607 @@
+package http3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/qlog"
+	"github.com/nxenon/xquic-go/quicvarint"
+
+	"github.com/quic-go/qpack"
+)
+
+// defaultMaxConcurrentPushes is used if RoundTripper.MaxConcurrentPushes is not set.
+const defaultMaxConcurrentPushes = 100
+
+// ErrPushLimitReached is returned by Push when the server has used up all push IDs the client allowed.
+// More pushes are possible once the client has processed some of the pushed responses.
+var ErrPushLimitReached = errors.New("http3: push limit reached")
+
+var errPushCancelled = errors.New("http3: push cancelled")
+
+// newPushRequest creates the request for a push, see http.Pusher.
+func newPushRequest(req *http.Request, target string, opts *http.PushOptions) (*http.Request, error) {
+	if opts == nil {
+		opts = &http.PushOptions{}
+	}
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	// Only safe and cacheable methods can be pushed, see section 4.6 of RFC 9114.
+	if method != http.MethodGet && method != http.MethodHead {
+		return nil, fmt.Errorf("http3: cannot push a %s request", method)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		if !strings.HasPrefix(target, "/") {
+			return nil, fmt.Errorf("http3: push target must be an absolute path or an https URL: %q", target)
+		}
+		u.Scheme = "https"
+		u.Host = req.Host
+	} else if u.Scheme != "https" {
+		return nil, fmt.Errorf("http3: cannot push URL with scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("http3: push target has no host")
+	}
+	header := http.Header{}
+	for k, vv := range opts.Header {
+		if strings.HasPrefix(k, ":") {
+			return nil, fmt.Errorf("http3: cannot push pseudo header %q", k)
+		}
+		// These headers don't make sense for a request without a body (http2 rejects them as well).
+		switch strings.ToLower(k) {
+		case "content-length", "content-encoding", "trailer", "te", "expect", "host":
+			return nil, fmt.Errorf("http3: cannot push header %q", k)
+		}
+		header[k] = append([]string(nil), vv...)
+	}
+	return &http.Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/3.0",
+		ProtoMajor: 3,
+		Header:     header,
+		Body:       http.NoBody,
+		Host:       u.Host,
+		RequestURI: u.RequestURI(),
+		RemoteAddr: req.RemoteAddr,
+		TLS:        req.TLS,
+	}, nil
+}
+
+// pushPromiseHeaderFields returns the header fields of the PUSH_PROMISE frame for a push request.
+func pushPromiseHeaderFields(req *http.Request) []qpack.HeaderField {
+	hfs := make([]qpack.HeaderField, 0, 4+len(req.Header))
+	hfs = append(hfs,
+		qpack.HeaderField{Name: ":method", Value: req.Method},
+		qpack.HeaderField{Name: ":scheme", Value: req.URL.Scheme},
+		qpack.HeaderField{Name: ":authority", Value: req.Host},
+		qpack.HeaderField{Name: ":path", Value: req.URL.RequestURI()},
+	)
+	for k, vv := range req.Header {
+		for _, v := range vv {
+			hfs = append(hfs, qpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	return hfs
+}
+
+// sendOnlyStream is a push stream, used by the responseWriter of a pushed response.
+type sendOnlyStream struct {
+	quic.SendStream
+}
+
+var _ quic.Stream = sendOnlyStream{}
+
+func (s sendOnlyStream) Read([]byte) (int, error)        { return 0, io.EOF }
+func (s sendOnlyStream) CancelRead(quic.StreamErrorCode) {}
+func (s sendOnlyStream) SetReadDeadline(time.Time) error { return nil }
+func (s sendOnlyStream) Peek() ([]byte, error)           { return nil, io.EOF }
+func (s sendOnlyStream) SetDeadline(t time.Time) error   { return s.SetWriteDeadline(t) }
+func (s sendOnlyStream) Consume(n int) error {
+	if n != 0 {
+		return errors.New("http3: cannot consume from a push stream")
+	}
+	return nil
+}
+
+// receiveOnlyStream is a push stream, read by the client.
+type receiveOnlyStream struct {
+	quic.ReceiveStream
+}
+
+var _ quic.Stream = receiveOnlyStream{}
+
+var errReceiveOnlyStream = errors.New("http3: cannot write to a push stream")
+
+func (s receiveOnlyStream) Write([]byte) (int, error) { return 0, errReceiveOnlyStream }
+func (s receiveOnlyStream) WriteBuffer(_ []byte, release func()) (int, error) {
+	if release != nil {
+		release()
+	}
+	return 0, errReceiveOnlyStream
+}
+func (s receiveOnlyStream) ReadFrom(io.Reader) (int64, error)     { return 0, errReceiveOnlyStream }
+func (s receiveOnlyStream) Close() error                          { return nil }
+func (s receiveOnlyStream) CancelWrite(quic.StreamErrorCode)      {}
+func (s receiveOnlyStream) SetWriteDeadline(time.Time) error      { return nil }
+func (s receiveOnlyStream) SetDSCP(uint8) error                   { return nil }
+func (s receiveOnlyStream) SetPriority(quic.StreamPriority) error { return nil }
+func (s receiveOnlyStream) SetDeadline(t time.Time) error         { return s.SetReadDeadline(t) }
+
+// Context returns a context that is already cancelled, since there's no send direction.
+func (s receiveOnlyStream) Context() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// serverPushes keeps track of the pushes on a server connection.
+type serverPushes struct {
+	mutex        sync.Mutex
+	hasMaxPushID bool
+	maxPushID    uint64
+	nextPushID   uint64
+	// set when the client sent a GOAWAY frame: it won't accept any pushes with push IDs >= goAwayID
+	receivedGoAway bool
+	goAwayID       uint64
+	pushes         map[uint64]*serverPush // pushes that are in progress
+}
+
+type serverPush struct {
+	id        uint64
+	cancelled bool
+	str       quic.SendStream // set once the push stream is opened
+	priority  *priorityState
+}
+
+func newServerPushes() *serverPushes {
+	return &serverPushes{pushes: make(map[uint64]*serverPush)}
+}
+
+func (p *serverPushes) handleMaxPushID(id uint64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.hasMaxPushID && id < p.maxPushID {
+		return fmt.Errorf("MAX_PUSH_ID decreased from %d to %d", p.maxPushID, id)
+	}
+	p.hasMaxPushID = true
+	p.maxPushID = id
+	return nil
+}
+
+func (p *serverPushes) handleCancelPush(id uint64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.hasMaxPushID || id >= p.nextPushID {
+		return fmt.Errorf("received CANCEL_PUSH for push ID %d, which was never promised", id)
+	}
+	push, ok := p.pushes[id]
+	if !ok { // the push was already completed
+		return nil
+	}
+	push.cancelled = true
+	if push.str != nil {
+		push.str.CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled))
+	}
+	return nil
+}
+
+func (p *serverPushes) handleGoAway(id uint64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.receivedGoAway && id > p.goAwayID {
+		return fmt.Errorf("GOAWAY push ID increased from %d to %d", p.goAwayID, id)
+	}
+	p.receivedGoAway = true
+	p.goAwayID = id
+	return nil
+}
+
+// handlePriorityUpdate handles a PRIORITY_UPDATE frame for a push.
+// Updates for pushes that were not promised yet, or that are already completed, are ignored.
+func (p *serverPushes) handlePriorityUpdate(id uint64, fieldValue string) error {
+	p.mutex.Lock()
+	if !p.hasMaxPushID || id > p.maxPushID {
+		p.mutex.Unlock()
+		return fmt.Errorf("received PRIORITY_UPDATE for push ID %d, which exceeds the maximum push ID", id)
+	}
+	push, ok := p.pushes[id]
+	p.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	push.priority.update(parsePriority(fieldValue), false)
+	return nil
+}
+
+// newPush allocates the next push ID.
+func (p *serverPushes) newPush() (*serverPush, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.hasMaxPushID || (p.receivedGoAway && p.nextPushID >= p.goAwayID) {
+		return nil, http.ErrNotSupported
+	}
+	if p.nextPushID > p.maxPushID {
+		return nil, ErrPushLimitReached
+	}
+	push := &serverPush{id: p.nextPushID, priority: newPriorityState(DefaultPriority, nil)}
+	p.pushes[push.id] = push
+	p.nextPushID++
+	return push, nil
+}
+
+// setStream sets the push stream. It returns false if the push was cancelled.
+func (p *serverPushes) setStream(push *serverPush, str quic.SendStream) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if push.cancelled {
+		return false
+	}
+	push.str = str
+	push.priority.setStream(str)
+	return true
+}
+
+func (p *serverPushes) remove(push *serverPush) {
+	p.mutex.Lock()
+	delete(p.pushes, push.id)
+	p.mutex.Unlock()
+}
+
+// A PushPromise is a response promised by the server using HTTP/3 server push, see section 4.6 of RFC 9114.
+type PushPromise struct {
+	// Request is the promised request.
+	Request *http.Request
+
+	id     uint64
+	pushes *clientPushes
+
+	headerFields []qpack.HeaderField // the header fields of the PUSH_PROMISE frame, nil until it is received
+	str          quic.ReceiveStream
+	arrived      chan struct{} // closed when the push stream is received, or when the push is cancelled
+	cancelled    bool
+	claimed      bool // Response was called
+	done         bool
+}
+
+// Response waits for the push stream and returns the pushed response.
+// Closing the body of the response completes the push, allowing the server to push more responses.
+// It can only be called once.
+func (p *PushPromise) Response(ctx context.Context) (*http.Response, error) {
+	select {
+	case <-p.arrived:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.pushes.conn.Context().Done():
+		return nil, context.Cause(p.pushes.conn.Context())
+	}
+	p.pushes.mutex.Lock()
+	if p.cancelled {
+		p.pushes.mutex.Unlock()
+		return nil, errPushCancelled
+	}
+	if p.claimed {
+		p.pushes.mutex.Unlock()
+		return nil, errors.New("http3: push response already claimed")
+	}
+	p.claimed = true
+	str := p.str
+	p.pushes.mutex.Unlock()
+
+	if p.pushes.tracer != nil {
+		p.pushes.tracer.PushResolved(p.id, str.StreamID(), true)
+	}
+	done := make(chan struct{})
+	rsp, rerr := p.pushes.readResponse(p.Request, receiveOnlyStream{str}, done)
+	if rerr.err != nil {
+		if rerr.streamErr != 0 {
+			str.CancelRead(quic.StreamErrorCode(rerr.streamErr))
+		}
+		if rerr.connErr != 0 {
+			p.pushes.conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), rerr.err.Error())
+		}
+		p.pushes.complete(p)
+		return nil, maybeReplaceError(rerr.err)
+	}
+	go func() {
+		select {
+		case <-done:
+		case <-p.pushes.conn.Context().Done():
+		}
+		p.pushes.complete(p)
+	}()
+	return rsp, nil
+}
+
+// Cancel cancels the push.
+// If the push stream was already received, reading from it is aborted, otherwise a CANCEL_PUSH frame is sent.
+func (p *PushPromise) Cancel() {
+	p.pushes.cancel(p)
+}
+
+// clientPushes keeps track of the pushes on a client connection.
+//
+// The server may only use push IDs up to the maximum push ID sent in the MAX_PUSH_ID frame.
+// The maximum is only increased once pushes are completed (i.e. cancelled or read),
+// such that there are never more than maxConcurrent pushes in flight.
+type clientPushes struct {
+	conn          quic.EarlyConnection
+	handler       func(*PushPromise) bool
+	maxConcurrent uint64
+	hostname      string
+	decoder       *qpackDecoder
+	// readResponse reads the response from a push stream
+	readResponse func(req *http.Request, str quic.Stream, done chan<- struct{}) (*http.Response, requestError)
+
+	tracer *qlog.HTTP3Tracer // may be nil
+
+	mutex     sync.Mutex
+	control   quic.SendStream // nil until the control stream is opened
+	pending   []frame         // frames to send once the control stream is opened
+	lowestID  uint64          // all pushes with lower push IDs are completed
+	maxPushID uint64          // the push ID sent in the last MAX_PUSH_ID frame
+	pushes    map[uint64]*PushPromise
+}
+
+func newClientPushes(conn quic.EarlyConnection, hostname string, handler func(*PushPromise) bool, maxConcurrent int, decoder *qpackDecoder, tracer *qlog.HTTP3Tracer, readResponse func(*http.Request, quic.Stream, chan<- struct{}) (*http.Response, requestError)) *clientPushes {
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrentPushes
+	}
+	return &clientPushes{
+		conn:          conn,
+		hostname:      hostname,
+		handler:       handler,
+		maxConcurrent: uint64(maxConcurrent),
+		decoder:       decoder,
+		readResponse:  readResponse,
+		tracer:        tracer,
+		maxPushID:     uint64(maxConcurrent) - 1,
+		pending:       []frame{&maxPushIDFrame{PushID: uint64(maxConcurrent) - 1}},
+		pushes:        make(map[uint64]*PushPromise),
+	}
+}
+
+// setControlStream sets our control stream, after the SETTINGS frame was sent on it.
+func (p *clientPushes) setControlStream(str quic.SendStream) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.control = str
+	for _, f := range p.pending {
+		if err := p.writeFrame(f); err != nil {
+			return err
+		}
+	}
+	p.pending = nil
+	return nil
+}
+
+// writeFrame writes a MAX_PUSH_ID or CANCEL_PUSH frame on the control stream.
+// It must be called with the mutex held.
+func (p *clientPushes) writeFrame(f frame) error {
+	if p.control == nil {
+		p.pending = append(p.pending, f)
+		return nil
+	}
+	var b []byte
+	var pushID uint64
+	var qf qlog.HTTP3Frame
+	switch f := f.(type) {
+	case *maxPushIDFrame:
+		b = f.Append(nil)
+		pushID = f.PushID
+		qf = &qlog.HTTP3MaxPushIDFrame{PushID: f.PushID}
+	case *cancelPushFrame:
+		b = f.Append(nil)
+		pushID = f.PushID
+		qf = &qlog.HTTP3CancelPushFrame{PushID: f.PushID}
+	}
+	_, err := p.control.Write(b)
+	if p.tracer != nil {
+		p.tracer.FrameCreated(p.control.StreamID(), uint64(quicvarint.Len(pushID)), qf)
+	}
+	return err
+}
+
+// getOrCreate returns the push for a push ID.
+// It returns nil if the push was already completed.
+// It must be called with the mutex held.
+func (p *clientPushes) getOrCreate(id uint64) (*PushPromise, requestError) {
+	if id > p.maxPushID {
+		return nil, newConnError(ErrCodeIDError, fmt.Errorf("push ID %d exceeds the maximum push ID %d", id, p.maxPushID))
+	}
+	if id < p.lowestID {
+		return nil, requestError{}
+	}
+	if push, ok := p.pushes[id]; ok {
+		if push.done {
+			return nil, requestError{}
+		}
+		return push, requestError{}
+	}
+	push := &PushPromise{id: id, pushes: p, arrived: make(chan struct{})}
+	p.pushes[id] = push
+	return push, requestError{}
+}
+
+// handlePromise handles a PUSH_PROMISE frame received on a request stream.
+// The header block is decoded using ctx, which is cancelled when the request is done.
+func (p *clientPushes) handlePromise(ctx context.Context, streamID quic.StreamID, id uint64, headerBlock []byte) requestError {
+	// Decode the header block before checking the push ID:
+	// The header block might reference the dynamic table, and needs to be acknowledged.
+	hfs, err := p.decoder.decode(ctx, streamID, headerBlock)
+	if err != nil {
+		if err == errQPACKTooManyBlockedStreams || ctx.Err() == nil {
+			return newConnError(ErrCodeQPACKDecompressionFailed, err)
+		}
+		return newStreamError(ErrCodeRequestIncomplete, err)
+	}
+
+	p.mutex.Lock()
+	push, rerr := p.getOrCreate(id)
+	if rerr.err != nil || push == nil {
+		p.mutex.Unlock()
+		return rerr
+	}
+	// The same push can be promised on multiple request streams, see section 4.6 of RFC 9114.
+	if push.headerFields != nil {
+		p.mutex.Unlock()
+		if !slices.Equal(push.headerFields, hfs) {
+			return newConnError(ErrCodeGeneralProtocolError, fmt.Errorf("received inconsistent PUSH_PROMISE frames for push ID %d", id))
+		}
+		return requestError{}
+	}
+	push.headerFields = hfs
+	p.mutex.Unlock()
+
+	if p.tracer != nil {
+		p.tracer.FrameParsed(streamID, uint64(quicvarint.Len(id))+uint64(len(headerBlock)), &qlog.HTTP3PushPromiseFrame{PushID: id, HeaderFields: qlogHeaderFields(hfs)})
+	}
+	req, err := requestFromHeaders(hfs)
+	if err == nil && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		err = fmt.Errorf("cannot push a %s request", req.Method)
+	}
+	if err != nil {
+		p.cancel(push)
+		return newStreamError(ErrCodeMessageError, fmt.Errorf("malformed PUSH_PROMISE: %w", err))
+	}
+	for _, hf := range hfs {
+		if hf.Name == ":scheme" {
+			req.URL.Scheme = hf.Value
+		}
+	}
+	// The server must be authoritative for the pushed URL, see section 4.6 of RFC 9114.
+	// We only accept pushes for the origin of this connection.
+	if req.URL.Scheme != "https" || authorityAddr("https", req.Host) != p.hostname {
+		p.cancel(push)
+		return requestError{}
+	}
+	req.URL.Host = req.Host
+	req.Body = http.NoBody
+	push.Request = req
+	go func() {
+		if !p.handler(push) {
+			p.cancel(push)
+		}
+	}()
+	return requestError{}
+}
+
+// handleStream handles a push stream, after the stream type was read.
+func (p *clientPushes) handleStream(str quic.ReceiveStream) requestError {
+	id, err := quicvarint.Read(quicvarint.NewReader(str))
+	if err != nil {
+		return requestError{err: err}
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	push, rerr := p.getOrCreate(id)
+	if rerr.err != nil {
+		return rerr
+	}
+	if push == nil || push.cancelled {
+		str.CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled))
+		return requestError{}
+	}
+	if push.str != nil {
+		return newConnError(ErrCodeIDError, fmt.Errorf("received a second push stream for push ID %d", id))
+	}
+	push.str = str
+	close(push.arrived)
+	return requestError{}
+}
+
+// handleCancelPush handles a CANCEL_PUSH frame received on the server's control stream.
+func (p *clientPushes) handleCancelPush(id uint64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	push, rerr := p.getOrCreate(id)
+	if rerr.err != nil {
+		return rerr.err
+	}
+	if push == nil || push.cancelled {
+		return nil
+	}
+	push.cancelled = true
+	if push.str == nil {
+		close(push.arrived)
+	}
+	if !push.claimed {
+		p.completeLocked(push)
+	}
+	return nil
+}
+
+func (p *clientPushes) cancel(push *PushPromise) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if push.done || push.cancelled {
+		return
+	}
+	push.cancelled = true
+	if push.str != nil {
+		push.str.CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled))
+		if p.tracer != nil && !push.claimed {
+			p.tracer.PushResolved(push.id, push.str.StreamID(), false)
+		}
+	} else {
+		close(push.arrived)
+		if err := p.writeFrame(&cancelPushFrame{PushID: push.id}); err != nil {
+			p.conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeClosedCriticalStream), "")
+		}
+	}
+	p.completeLocked(push)
+}
+
+func (p *clientPushes) complete(push *PushPromise) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.completeLocked(push)
+}
+
+// completeLocked marks the push as completed.
+// Once all pushes with lower push IDs are completed as well, the server is allowed to use more push IDs.
+// It must be called with the mutex held.
+func (p *clientPushes) completeLocked(push *PushPromise) {
+	if push.done {
+		return
+	}
+	push.done = true
+	for {
+		push, ok := p.pushes[p.lowestID]
+		if !ok || !push.done {
+			break
+		}
+		delete(p.pushes, p.lowestID)
+		p.lowestID++
+	}
+	if maxPushID := p.lowestID + p.maxConcurrent - 1; maxPushID > p.maxPushID {
+		p.maxPushID = maxPushID
+		if err := p.writeFrame(&maxPushIDFrame{PushID: maxPushID}); err != nil {
+			p.conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeClosedCriticalStream), "")
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	mockquic "github.com/nxenon/xquic-go/internal/mocks/quic"
+	"github.com/nxenon/xquic-go/internal/testdata"
+	"github.com/nxenon/xquic-go/quicvarint"
+
+	"github.com/quic-go/qpack"
+	"go.uber.org/mock/gomock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server Push", func() {
+	Context("push requests", func() {
+		var req *http.Request
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest(http.MethodGet, "https://example.com/index.html", nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("creates requests for paths", func() {
+			pushReq, err := newPushRequest(req, "/style.css?v=1", &http.PushOptions{Header: http.Header{"Foo": []string{"bar"}}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pushReq.Method).To(Equal(http.MethodGet))
+			Expect(pushReq.URL.String()).To(Equal("https://example.com/style.css?v=1"))
+			Expect(pushReq.Host).To(Equal("example.com"))
+			Expect(pushReq.RequestURI).To(Equal("/style.css?v=1"))
+			Expect(pushReq.Header).To(Equal(http.Header{"Foo": []string{"bar"}}))
+			Expect(pushPromiseHeaderFields(pushReq)).To(Equal([]qpack.HeaderField{
+				{Name: ":method", Value: "GET"},
+				{Name: ":scheme", Value: "https"},
+				{Name: ":authority", Value: "example.com"},
+				{Name: ":path", Value: "/style.css?v=1"},
+				{Name: "foo", Value: "bar"},
+			}))
+		})
+
+		It("creates requests for URLs", func() {
+			pushReq, err := newPushRequest(req, "https://cdn.example.com/script.js", &http.PushOptions{Method: http.MethodHead})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pushReq.Method).To(Equal(http.MethodHead))
+			Expect(pushReq.Host).To(Equal("cdn.example.com"))
+		})
+
+		It("rejects invalid push requests", func() {
+			_, err := newPushRequest(req, "/upload", &http.PushOptions{Method: http.MethodPost})
+			Expect(err).To(MatchError("http3: cannot push a POST request"))
+			_, err = newPushRequest(req, "style.css", nil)
+			Expect(err).To(MatchError(`http3: push target must be an absolute path or an https URL: "style.css"`))
+			_, err = newPushRequest(req, "http://example.com/style.css", nil)
+			Expect(err).To(MatchError(`http3: cannot push URL with scheme "http"`))
+			_, err = newPushRequest(req, "/style.css", &http.PushOptions{Header: http.Header{"Content-Length": []string{"42"}}})
+			Expect(err).To(MatchError(`http3: cannot push header "Content-Length"`))
+		})
+	})
+
+	Context("server", func() {
+		It("refuses to push before receiving MAX_PUSH_ID", func() {
+			pushes := newServerPushes()
+			_, err := pushes.newPush()
+			Expect(err).To(MatchError(http.ErrNotSupported))
+		})
+
+		It("allocates push IDs up to the maximum push ID", func() {
+			pushes := newServerPushes()
+			Expect(pushes.handleMaxPushID(1)).To(Succeed())
+			p0, err := pushes.newPush()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p0.id).To(BeZero())
+			p1, err := pushes.newPush()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p1.id).To(BeEquivalentTo(1))
+			_, err = pushes.newPush()
+			Expect(err).To(MatchError(ErrPushLimitReached))
+			Expect(pushes.handleMaxPushID(2)).To(Succeed())
+			p2, err := pushes.newPush()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p2.id).To(BeEquivalentTo(2))
+		})
+
+		It("rejects a decreasing MAX_PUSH_ID", func() {
+			pushes := newServerPushes()
+			Expect(pushes.handleMaxPushID(10)).To(Succeed())
+			Expect(pushes.handleMaxPushID(9)).To(MatchError("MAX_PUSH_ID decreased from 10 to 9"))
+		})
+
+		It("cancels pushes", func() {
+			pushes := newServerPushes()
+			Expect(pushes.handleMaxPushID(10)).To(Succeed())
+			p0, err := pushes.newPush()
+			Expect(err).ToNot(HaveOccurred())
+			p1, err := pushes.newPush()
+			Expect(err).ToNot(HaveOccurred())
+			// the push stream for push 0 was already opened
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().SetPriority(gomock.Any())
+			Expect(pushes.setStream(p0, str)).To(BeTrue())
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled))
+			Expect(pushes.handleCancelPush(0)).To(Succeed())
+			// the push stream for push 1 is not opened after the push was cancelled
+			Expect(pushes.handleCancelPush(1)).To(Succeed())
+			Expect(pushes.setStream(p1, mockquic.NewMockStream(mockCtrl))).To(BeFalse())
+		})
+
+		It("rejects CANCEL_PUSH for pushes that were never promised", func() {
+			pushes := newServerPushes()
+			Expect(pushes.handleCancelPush(0)).To(MatchError("received CANCEL_PUSH for push ID 0, which was never promised"))
+			Expect(pushes.handleMaxPushID(10)).To(Succeed())
+			_, err := pushes.newPush()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pushes.handleCancelPush(1)).To(MatchError("received CANCEL_PUSH for push ID 1, which was never promised"))
+		})
+
+		It("stops pushing after receiving a GOAWAY from the client", func() {
+			pushes := newServerPushes()
+			Expect(pushes.handleMaxPushID(10)).To(Succeed())
+			Expect(pushes.handleGoAway(2)).To(Succeed())
+			_, err := pushes.newPush()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = pushes.newPush()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = pushes.newPush()
+			Expect(err).To(MatchError(http.ErrNotSupported))
+			Expect(pushes.handleGoAway(3)).To(MatchError("GOAWAY push ID increased from 2 to 3"))
+		})
+
+		It("handles PRIORITY_UPDATE frames for pushes", func() {
+			pushes := newServerPushes()
+			Expect(pushes.handlePriorityUpdate(0, "u=1")).To(MatchError("received PRIORITY_UPDATE for push ID 0, which exceeds the maximum push ID"))
+			Expect(pushes.handleMaxPushID(10)).To(Succeed())
+			Expect(pushes.handlePriorityUpdate(11, "u=1")).To(MatchError("received PRIORITY_UPDATE for push ID 11, which exceeds the maximum push ID"))
+			p0, err := pushes.newPush()
+			Expect(err).ToNot(HaveOccurred())
+			// the push stream wasn't opened yet
+			Expect(pushes.handlePriorityUpdate(0, "u=1")).To(Succeed())
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().AnyTimes()
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 1})
+			Expect(pushes.setStream(p0, str)).To(BeTrue())
+			str.EXPECT().SetPriority(quic.StreamPriority{Urgency: 5, Incremental: true})
+			Expect(pushes.handlePriorityUpdate(0, "u=5, i")).To(Succeed())
+			// PRIORITY_UPDATE frames for pushes that were not promised yet are ignored
+			Expect(pushes.handlePriorityUpdate(5, "u=1")).To(Succeed())
+		})
+
+		It("handles MAX_PUSH_ID and CANCEL_PUSH on the control stream", func() {
+			s := &Server{}
+			pushes := newServerPushes()
+			b := (&maxPushIDFrame{PushID: 5}).Append(nil)
+			b = (&maxPushIDFrame{PushID: 4}).Append(b)
+			str := mockquic.NewMockStream(mockCtrl)
+			r := bytes.NewReader(b)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), "MAX_PUSH_ID decreased from 5 to 4")
+			s.handleControlStream(conn, str, nil, pushes, newRequestPriorities(nil))
+		})
+
+		It("accepts GOAWAY on the control stream", func() {
+			s := &Server{}
+			pushes := newServerPushes()
+			b := (&maxPushIDFrame{PushID: 5}).Append(nil)
+			b = (&goAwayFrame{StreamID: 0}).Append(b)
+			str := mockquic.NewMockStream(mockCtrl)
+			r := bytes.NewReader(b)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			s.handleControlStream(conn, str, nil, pushes, newRequestPriorities(nil))
+			_, err := pushes.newPush()
+			Expect(err).To(MatchError(http.ErrNotSupported))
+		})
+
+		It("rejects unexpected frames on the control stream", func() {
+			s := &Server{}
+			str := mockquic.NewMockStream(mockCtrl)
+			r := bytes.NewReader((&dataFrame{Length: 0}).Append(nil))
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeFrameUnexpected), gomock.Any())
+			s.handleControlStream(conn, str, nil, newServerPushes(), newRequestPriorities(nil))
+		})
+	})
+
+	Context("client", func() {
+		const hostname = "example.com:443"
+
+		var (
+			conn     *mockquic.MockEarlyConnection
+			control  *mockquic.MockStream
+			sent     *bytes.Buffer
+			promises chan *PushPromise
+			accept   bool
+		)
+
+		encodeHeaders := func(path string) []byte {
+			buf := &bytes.Buffer{}
+			enc := qpack.NewEncoder(buf)
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":method", Value: "GET"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":scheme", Value: "https"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":authority", Value: "example.com"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":path", Value: path})).To(Succeed())
+			return buf.Bytes()
+		}
+
+		newPushes := func(maxConcurrent int) *clientPushes {
+			promises := promises
+			accept := accept
+			return newClientPushes(conn, hostname, func(p *PushPromise) bool {
+				promises <- p
+				return accept
+			}, maxConcurrent, newQPACKDecoder(nil, 0, 0, nil), nil, nil)
+		}
+
+		newPushStream := func(id uint64) *mockquic.MockStream {
+			str := mockquic.NewMockStream(mockCtrl)
+			r := bytes.NewReader(quicvarint.Append(nil, id))
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+			return str
+		}
+
+		BeforeEach(func() {
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			control = mockquic.NewMockStream(mockCtrl)
+			control.EXPECT().StreamID().AnyTimes()
+			sent = &bytes.Buffer{}
+			control.EXPECT().Write(gomock.Any()).DoAndReturn(sent.Write).AnyTimes()
+			promises = make(chan *PushPromise, 10)
+			accept = true
+		})
+
+		It("sends MAX_PUSH_ID after the SETTINGS frame", func() {
+			pushes := newPushes(0)
+			Expect(sent.Len()).To(BeZero())
+			Expect(pushes.setControlStream(control)).To(Succeed())
+			Expect(sent.Bytes()).To(Equal((&maxPushIDFrame{PushID: defaultMaxConcurrentPushes - 1}).Append(nil)))
+		})
+
+		It("increases the maximum push ID when pushes are completed", func() {
+			pushes := newPushes(2)
+			Expect(pushes.setControlStream(control)).To(Succeed())
+			sent.Reset()
+			Expect(pushes.handlePromise(context.Background(), 0, 0, encodeHeaders("/foo"))).To(Equal(requestError{}))
+			Expect(pushes.handlePromise(context.Background(), 0, 1, encodeHeaders("/bar"))).To(Equal(requestError{}))
+			var p0, p1 *PushPromise
+			Eventually(promises).Should(Receive(&p0))
+			Eventually(promises).Should(Receive(&p1))
+			if p0.id != 0 {
+				p0, p1 = p1, p0
+			}
+			Expect(p0.Request.URL.String()).To(Equal("https://example.com/foo"))
+			// the maximum push ID is only increased once the lowest push is completed
+			pushes.complete(p1)
+			Expect(sent.Len()).To(BeZero())
+			rerr := pushes.handlePromise(context.Background(), 0, 2, encodeHeaders("/baz"))
+			Expect(rerr.connErr).To(Equal(ErrCodeIDError))
+			pushes.complete(p0)
+			Expect(sent.Bytes()).To(Equal((&maxPushIDFrame{PushID: 3}).Append(nil)))
+			// promises for completed pushes are ignored
+			Expect(pushes.handlePromise(context.Background(), 0, 0, encodeHeaders("/foo"))).To(Equal(requestError{}))
+			Consistently(promises).ShouldNot(Receive())
+		})
+
+		It("accepts duplicate promises", func() {
+			pushes := newPushes(10)
+			Expect(pushes.handlePromise(context.Background(), 0, 3, encodeHeaders("/foo"))).To(Equal(requestError{}))
+			Expect(pushes.handlePromise(context.Background(), 4, 3, encodeHeaders("/foo"))).To(Equal(requestError{}))
+			Eventually(promises).Should(Receive())
+			Consistently(promises).ShouldNot(Receive())
+			rerr := pushes.handlePromise(context.Background(), 8, 3, encodeHeaders("/bar"))
+			Expect(rerr.connErr).To(Equal(ErrCodeGeneralProtocolError))
+		})
+
+		It("cancels promises that are rejected by the handler", func() {
+			accept = false
+			pushes := newPushes(10)
+			Expect(pushes.setControlStream(control)).To(Succeed())
+			sent.Reset()
+			Expect(pushes.handlePromise(context.Background(), 0, 0, encodeHeaders("/foo"))).To(Equal(requestError{}))
+			Eventually(promises).Should(Receive())
+			Eventually(func() []byte {
+				pushes.mutex.Lock()
+				defer pushes.mutex.Unlock()
+				return sent.Bytes()
+			}).Should(Equal(append((&cancelPushFrame{PushID: 0}).Append(nil), (&maxPushIDFrame{PushID: 10}).Append(nil)...)))
+			// the push stream is reset
+			str := newPushStream(0)
+			str.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled))
+			Expect(pushes.handleStream(str)).To(Equal(requestError{}))
+		})
+
+		It("cancels promises for other origins", func() {
+			pushes := newPushes(10)
+			buf := &bytes.Buffer{}
+			enc := qpack.NewEncoder(buf)
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":method", Value: "GET"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":scheme", Value: "https"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":authority", Value: "evil.com"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":path", Value: "/"})).To(Succeed())
+			Expect(pushes.handlePromise(context.Background(), 0, 0, buf.Bytes())).To(Equal(requestError{}))
+			Consistently(promises).ShouldNot(Receive())
+			Expect(pushes.setControlStream(control)).To(Succeed())
+			Expect(sent.Bytes()).To(Equal(append(
+				append((&maxPushIDFrame{PushID: 9}).Append(nil), (&cancelPushFrame{PushID: 0}).Append(nil)...),
+				(&maxPushIDFrame{PushID: 10}).Append(nil)...,
+			)))
+		})
+
+		It("rejects promises for unsafe methods", func() {
+			pushes := newPushes(10)
+			buf := &bytes.Buffer{}
+			enc := qpack.NewEncoder(buf)
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":method", Value: "POST"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":scheme", Value: "https"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":authority", Value: "example.com"})).To(Succeed())
+			Expect(enc.WriteField(qpack.HeaderField{Name: ":path", Value: "/"})).To(Succeed())
+			rerr := pushes.handlePromise(context.Background(), 0, 0, buf.Bytes())
+			Expect(rerr.streamErr).To(Equal(ErrCodeMessageError))
+			Consistently(promises).ShouldNot(Receive())
+		})
+
+		It("rejects push streams exceeding the maximum push ID", func() {
+			pushes := newPushes(10)
+			rerr := pushes.handleStream(newPushStream(10))
+			Expect(rerr.connErr).To(Equal(ErrCodeIDError))
+		})
+
+		It("rejects duplicate push streams", func() {
+			pushes := newPushes(10)
+			Expect(pushes.handleStream(newPushStream(1))).To(Equal(requestError{}))
+			rerr := pushes.handleStream(newPushStream(1))
+			Expect(rerr.connErr).To(Equal(ErrCodeIDError))
+		})
+
+		It("handles CANCEL_PUSH frames", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			conn.EXPECT().Context().Return(ctx).AnyTimes()
+			pushes := newPushes(10)
+			Expect(pushes.setControlStream(control)).To(Succeed())
+			sent.Reset()
+			Expect(pushes.handlePromise(context.Background(), 0, 0, encodeHeaders("/foo"))).To(Equal(requestError{}))
+			var p *PushPromise
+			Eventually(promises).Should(Receive(&p))
+			Expect(pushes.handleCancelPush(0)).To(Succeed())
+			_, err := p.Response(context.Background())
+			Expect(err).To(MatchError(errPushCancelled))
+			Expect(sent.Bytes()).To(Equal((&maxPushIDFrame{PushID: 10}).Append(nil)))
+			Expect(pushes.handleCancelPush(11)).To(MatchError("push ID 11 exceeds the maximum push ID 10"))
+		})
+	})
+
+	It("pushes responses", func() {
+		pushErrs := make(chan error, 10)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			pusher := w.(http.Pusher)
+			// The MAX_PUSH_ID frame might arrive after the first request.
+			if err := pusher.Push("/pushed", &http.PushOptions{Header: http.Header{"Foo": []string{"bar"}}}); err != nil {
+				w.Header().Set("X-Push-Error", err.Error())
+				return
+			}
+			w.Write([]byte("index"))
+			w.(http.Flusher).Flush()
+			// PUSH_PROMISE frames can be sent after the response body
+			pushErrs <- pusher.Push("/pushed?late", nil)
+			w.Write([]byte("!"))
+		})
+		mux.HandleFunc("/pushed", func(w http.ResponseWriter, r *http.Request) {
+			if err := w.(http.Pusher).Push("/", nil); err != http.ErrNotSupported {
+				panic(fmt.Sprintf("unexpected error: %v", err))
+			}
+			w.Header().Set("Foo", r.Header.Get("Foo"))
+			w.Write([]byte("pushed " + r.URL.RawQuery))
+		})
+		s := &Server{Handler: mux}
+		ln, err := quic.ListenAddrEarly("localhost:0", ConfigureTLSConfig(testdata.GetTLSConfig()), nil)
+		Expect(err).ToNot(HaveOccurred())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			s.ServeListener(ln)
+		}()
+		defer func() {
+			Expect(s.Close()).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		}()
+
+		promises := make(chan *PushPromise, 10)
+		rt := &RoundTripper{
+			TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()},
+			PushHandler: func(p *PushPromise) bool {
+				promises <- p
+				return true
+			},
+		}
+		defer rt.Close()
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost:%d/", ln.Addr().(*net.UDPAddr).Port), nil)
+		Expect(err).ToNot(HaveOccurred())
+		var body []byte
+		Eventually(func() string {
+			rsp, err := rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			body, err = io.ReadAll(rsp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			return rsp.Header.Get("X-Push-Error")
+		}).Should(BeEmpty())
+		Expect(string(body)).To(Equal("index!"))
+		Expect(pushErrs).To(Receive(BeNil()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for i := 0; i < 2; i++ {
+			var p *PushPromise
+			Eventually(promises).Should(Receive(&p))
+			Expect(p.Request.Method).To(Equal(http.MethodGet))
+			Expect(p.Request.URL.Path).To(Equal("/pushed"))
+			Expect(p.Request.Header.Get("Foo")).To(Or(Equal("bar"), BeEmpty()))
+			pushed, err := p.Response(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pushed.StatusCode).To(Equal(http.StatusOK))
+			Expect(pushed.Header.Get("Foo")).To(Equal(p.Request.Header.Get("Foo")))
+			body, err := io.ReadAll(pushed.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("pushed " + p.Request.URL.RawQuery))
+			Expect(pushed.Body.Close()).To(Succeed())
+		}
+	})
+})
@@ -0,0 +1,63 @@
+package http3
+
+import (
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/qlog"
+
+	"github.com/quic-go/qpack"
+)
+
+// qlogHeaderFields converts QPACK header fields to their qlog representation.
+func qlogHeaderFields(hfs []qpack.HeaderField) []qlog.HTTP3HeaderField {
+	fields := make([]qlog.HTTP3HeaderField, 0, len(hfs))
+	for _, hf := range hfs {
+		fields = append(fields, qlog.HTTP3HeaderField{Name: hf.Name, Value: hf.Value})
+	}
+	return fields
+}
+
+func (f *settingsFrame) qlogFrame() *qlog.HTTP3SettingsFrame {
+	settings := make([]qlog.HTTP3Setting, 0, len(f.Other)+5)
+	if f.QPACKMaxTableCapacity > 0 {
+		settings = append(settings, qlog.HTTP3Setting{ID: settingQPACKMaxTableCapacity, Value: f.QPACKMaxTableCapacity})
+	}
+	if f.QPACKBlockedStreams > 0 {
+		settings = append(settings, qlog.HTTP3Setting{ID: settingQPACKBlockedStreams, Value: f.QPACKBlockedStreams})
+	}
+	if f.Datagram {
+		settings = append(settings, qlog.HTTP3Setting{ID: settingDatagram, Value: 1})
+	}
+	if f.ExtendedConnect {
+		settings = append(settings, qlog.HTTP3Setting{ID: settingExtendedConnect, Value: 1})
+	}
+	if f.WebTransport {
+		settings = append(settings, qlog.HTTP3Setting{ID: settingEnableWebTransport, Value: 1})
+	}
+	for id, val := range f.Other {
+		settings = append(settings, qlog.HTTP3Setting{ID: id, Value: val})
+	}
+	return &qlog.HTTP3SettingsFrame{Settings: settings}
+}
+
+func (f *settingsFrame) qlogParameters() *qlog.HTTP3Parameters {
+	return &qlog.HTTP3Parameters{
+		MaxTableCapacity:    f.QPACKMaxTableCapacity,
+		BlockedStreamsCount: f.QPACKBlockedStreams,
+		EnableDatagrams:     f.Datagram,
+	}
+}
+
+// traceControlStream logs the opening of our control stream, and the SETTINGS frame sent on it.
+func traceControlStream(tracer *qlog.HTTP3Tracer, id quic.StreamID, sf *settingsFrame) {
+	tracer.StreamTypeSet(true, id, streamTypeControlStream)
+	tracer.FrameCreated(id, uint64(sf.length()), sf.qlogFrame())
+	tracer.ParametersSet(true, sf.qlogParameters())
+	tracer.QPACKStateUpdated(true, &qlog.QPACKState{})
+}
+
+// traceReceivedSettings logs the SETTINGS frame received on the peer's control stream.
+func traceReceivedSettings(tracer *qlog.HTTP3Tracer, id quic.StreamID, sf *settingsFrame) {
+	tracer.FrameParsed(id, uint64(sf.length()), sf.qlogFrame())
+	tracer.ParametersSet(false, sf.qlogParameters())
+	tracer.QPACKStateUpdated(false, &qlog.QPACKState{})
+}
@@ -0,0 +1,32 @@
+package http3
+
+import (
+	"github.com/nxenon/xquic-go/qlog"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("qlog", func() {
+	It("converts SETTINGS frames", func() {
+		sf := &settingsFrame{
+			Datagram:              true,
+			ExtendedConnect:       true,
+			QPACKMaxTableCapacity: 4096,
+			QPACKBlockedStreams:   16,
+			Other:                 map[uint64]uint64{0x1337: 42},
+		}
+		Expect(sf.qlogFrame().Settings).To(ConsistOf(
+			qlog.HTTP3Setting{ID: settingQPACKMaxTableCapacity, Value: 4096},
+			qlog.HTTP3Setting{ID: settingQPACKBlockedStreams, Value: 16},
+			qlog.HTTP3Setting{ID: settingDatagram, Value: 1},
+			qlog.HTTP3Setting{ID: settingExtendedConnect, Value: 1},
+			qlog.HTTP3Setting{ID: 0x1337, Value: 42},
+		))
+		Expect(sf.qlogParameters().EnableDatagrams).To(BeTrue())
+		Expect(sf.qlogParameters().MaxTableCapacity).To(BeEquivalentTo(4096))
+		Expect(sf.qlogParameters().BlockedStreamsCount).To(BeEquivalentTo(16))
+		// the payload length is the frame length minus the type and length field (1 byte each)
+		Expect(sf.length()).To(BeEquivalentTo(len(sf.Append(nil)) - 2))
+	})
+})
@@ -0,0 +1,214 @@
+package http3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/qlog"
+	"github.com/nxenon/xquic-go/quicvarint"
+
+	"github.com/quic-go/qpack"
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	defaultQPACKMaxTableCapacity  = 4096
+	defaultQPACKMaxBlockedStreams = 16
+)
+
+// qpackEntryOverhead is added to the length of name and value to calculate the size of a dynamic table entry,
+// see section 3.2.1 of RFC 9204.
+const qpackEntryOverhead = 32
+
+// QPACKConfig configures the QPACK dynamic table, see RFC 9204.
+// Header fields that are repeated across requests (or responses) are inserted into the dynamic table,
+// so that they only need to be sent once per connection.
+type QPACKConfig struct {
+	// MaxTableCapacity is the maximum capacity (in bytes) of the dynamic table
+	// that the peer may use for encoding the header fields it sends.
+	// It is announced in SETTINGS_QPACK_MAX_TABLE_CAPACITY.
+	// If zero, a default value of 4096 is used.
+	// If negative, the peer is not allowed to use the dynamic table.
+	MaxTableCapacity int
+	// MaxBlockedStreams is the maximum number of streams that can be blocked
+	// waiting for entries of the dynamic table that weren't received yet.
+	// It is announced in SETTINGS_QPACK_BLOCKED_STREAMS.
+	// If zero, a default value of 16 is used.
+	// If negative, the peer is not allowed to send header fields that might block a stream.
+	MaxBlockedStreams int
+	// MaxEncoderTableCapacity is the maximum capacity (in bytes) of the dynamic table
+	// that we use for encoding the header fields we send.
+	// The capacity used is the minimum of this value and the peer's SETTINGS_QPACK_MAX_TABLE_CAPACITY.
+	// If zero, a default value of 4096 is used.
+	// If negative, the dynamic table is not used for encoding.
+	MaxEncoderTableCapacity int
+}
+
+func (c *QPACKConfig) maxTableCapacity() uint64 {
+	if c == nil || c.MaxTableCapacity == 0 {
+		return defaultQPACKMaxTableCapacity
+	}
+	if c.MaxTableCapacity < 0 {
+		return 0
+	}
+	return uint64(c.MaxTableCapacity)
+}
+
+func (c *QPACKConfig) maxBlockedStreams() uint64 {
+	if c == nil || c.MaxBlockedStreams == 0 {
+		return defaultQPACKMaxBlockedStreams
+	}
+	if c.MaxBlockedStreams < 0 {
+		return 0
+	}
+	return uint64(c.MaxBlockedStreams)
+}
+
+func (c *QPACKConfig) maxEncoderTableCapacity() uint64 {
+	if c == nil || c.MaxEncoderTableCapacity == 0 {
+		return defaultQPACKMaxTableCapacity
+	}
+	if c.MaxEncoderTableCapacity < 0 {
+		return 0
+	}
+	return uint64(c.MaxEncoderTableCapacity)
+}
+
+var errQPACKIntegerOverflow = errors.New("QPACK integer overflow")
+
+type qpackReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// appendQPACKInt appends an integer with an n-bit prefix, see section 4.1.1 of RFC 9204.
+// The bits of the first byte not used by the prefix are taken from flags.
+func appendQPACKInt(b []byte, flags byte, n uint8, v uint64) []byte {
+	max := uint64(1)<<n - 1
+	if v < max {
+		return append(b, flags|byte(v))
+	}
+	b = append(b, flags|byte(max))
+	v -= max
+	for ; v >= 0x80; v >>= 7 {
+		b = append(b, 0x80|byte(v&0x7f))
+	}
+	return append(b, byte(v))
+}
+
+// readQPACKInt reads an integer with an n-bit prefix. first is the first byte, which was already read from r.
+func readQPACKInt(r io.ByteReader, first byte, n uint8) (uint64, error) {
+	max := uint64(1)<<n - 1
+	v := uint64(first) & max
+	if v < max {
+		return v, nil
+	}
+	for shift := 0; ; shift += 7 {
+		if shift > 56 {
+			return 0, errQPACKIntegerOverflow
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v += uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+}
+
+// appendQPACKString appends a string literal with an n-bit prefix, see section 4.1.2 of RFC 9204.
+// The string is Huffman-encoded if that makes it shorter.
+func appendQPACKString(b []byte, flags byte, n uint8, s string) []byte {
+	if l := hpack.HuffmanEncodeLength(s); l < uint64(len(s)) {
+		b = appendQPACKInt(b, flags|1<<n, n, l)
+		return hpack.AppendHuffmanString(b, s)
+	}
+	b = appendQPACKInt(b, flags, n, uint64(len(s)))
+	return append(b, s...)
+}
+
+// readQPACKString reads a string literal with an n-bit prefix. first is the first byte, which was already read from r.
+// Strings with an encoded length larger than maxLen are rejected.
+func readQPACKString(r qpackReader, first byte, n uint8, maxLen uint64) (string, error) {
+	l, err := readQPACKInt(r, first, n)
+	if err != nil {
+		return "", err
+	}
+	if l > maxLen {
+		return "", fmt.Errorf("QPACK string too long: %d bytes", l)
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	if first&(1<<n) == 0 {
+		return string(b), nil
+	}
+	return hpack.HuffmanDecodeToString(b)
+}
+
+func qpackEntrySize(hf qpack.HeaderField) uint64 {
+	return uint64(len(hf.Name)+len(hf.Value)) + qpackEntryOverhead
+}
+
+// qpackDynamicTable is the QPACK dynamic table, see section 3.2 of RFC 9204.
+// Entries are identified by their absolute index: the first entry ever inserted has the index 0.
+type qpackDynamicTable struct {
+	capacity uint64
+	size     uint64
+	entries  []qpack.HeaderField // the oldest entry first
+	evicted  uint64              // number of entries evicted, this is the absolute index of entries[0]
+}
+
+// insertCount is the total number of entries inserted into the table.
+func (t *qpackDynamicTable) insertCount() uint64 {
+	return t.evicted + uint64(len(t.entries))
+}
+
+func (t *qpackDynamicTable) get(index uint64) (qpack.HeaderField, bool) {
+	if index < t.evicted || index >= t.insertCount() {
+		return qpack.HeaderField{}, false
+	}
+	return t.entries[index-t.evicted], true
+}
+
+func (t *qpackDynamicTable) evictOldest() {
+	t.size -= qpackEntrySize(t.entries[0])
+	t.entries[0] = qpack.HeaderField{}
+	t.entries = t.entries[1:]
+	t.evicted++
+}
+
+// insert inserts an entry. The caller needs to make sure that there's enough space for the entry.
+func (t *qpackDynamicTable) insert(hf qpack.HeaderField) uint64 {
+	t.entries = append(t.entries, hf)
+	t.size += qpackEntrySize(hf)
+	return t.insertCount() - 1
+}
+
+func (t *qpackDynamicTable) qlogState() *qlog.QPACKState {
+	return &qlog.QPACKState{
+		DynamicTableCapacity: t.capacity,
+		DynamicTableSize:     t.size,
+		CurrentInsertCount:   t.insertCount(),
+	}
+}
+
+// openQPACKStream opens a QPACK encoder or decoder stream.
+func openQPACKStream(conn quic.Connection, streamType uint64, tracer *qlog.HTTP3Tracer) (quic.SendStream, error) {
+	str, err := conn.OpenUniStream()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := str.Write(quicvarint.Append(nil, streamType)); err != nil {
+		return nil, err
+	}
+	if tracer != nil {
+		tracer.StreamTypeSet(true, str.StreamID(), streamType)
+	}
+	return str, nil
+}
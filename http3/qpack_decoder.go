@@ -0,0 +1,473 @@
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/qlog"
+
+	"github.com/quic-go/qpack"
+)
+
+var errQPACKTooManyBlockedStreams = errors.New("QPACK: too many blocked streams")
+
+// qpackDecoder decodes the field sections received on a connection.
+// It maintains the dynamic table, as instructed by the peer's encoder on the encoder stream,
+// and sends acknowledgements to the peer's encoder on our decoder stream.
+type qpackDecoder struct {
+	conn              quic.Connection
+	maxTableCapacity  uint64 // announced in SETTINGS_QPACK_MAX_TABLE_CAPACITY
+	maxBlockedStreams uint64 // announced in SETTINGS_QPACK_BLOCKED_STREAMS
+
+	tracer *qlog.HTTP3Tracer // may be nil
+
+	mutex        sync.Mutex
+	table        qpackDynamicTable
+	inserted     chan struct{} // closed (and replaced) when entries are inserted into the dynamic table
+	numBlocked   uint64        // number of streams waiting for entries to be inserted
+	acknowledged uint64        // the insert count that the peer's encoder knows we have received
+	pending      []byte        // instructions that weren't written to the decoder stream yet
+
+	// writeMutex serializes writes to the decoder stream.
+	// Writes may block on flow control, so they're never done while holding mutex.
+	writeMutex sync.Mutex
+	str        quic.SendStream
+}
+
+func newQPACKDecoder(conn quic.Connection, maxTableCapacity, maxBlockedStreams uint64, tracer *qlog.HTTP3Tracer) *qpackDecoder {
+	return &qpackDecoder{
+		conn:              conn,
+		maxTableCapacity:  maxTableCapacity,
+		maxBlockedStreams: maxBlockedStreams,
+		tracer:            tracer,
+		inserted:          make(chan struct{}),
+	}
+}
+
+// decode decodes a field section received on stream id.
+// If the field section references entries of the dynamic table that weren't received yet,
+// the stream is blocked until these entries are inserted, or until ctx is cancelled.
+// Errors returned are decompression errors, except when ctx is cancelled.
+func (d *qpackDecoder) decode(ctx context.Context, id quic.StreamID, b []byte) ([]qpack.HeaderField, error) {
+	r := bytes.NewReader(b)
+	defer d.flush()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	requiredInsertCount, base, err := d.readPrefix(r)
+	if err != nil {
+		return nil, err
+	}
+	if requiredInsertCount > d.table.insertCount() {
+		if err := d.waitForInserts(ctx, id, requiredInsertCount); err != nil {
+			return nil, err
+		}
+	}
+	hfs, err := d.decodeFieldLines(r, requiredInsertCount, base)
+	if err != nil {
+		return nil, err
+	}
+	if requiredInsertCount > 0 {
+		// Section Acknowledgment
+		if requiredInsertCount > d.acknowledged {
+			d.acknowledged = requiredInsertCount
+		}
+		d.pending = appendQPACKInt(d.pending, 0x80, 7, uint64(id))
+	}
+	return hfs, nil
+}
+
+// waitForInserts blocks until the dynamic table contains requiredInsertCount entries.
+// It must be called with the mutex held.
+func (d *qpackDecoder) waitForInserts(ctx context.Context, id quic.StreamID, requiredInsertCount uint64) error {
+	if d.numBlocked >= d.maxBlockedStreams {
+		return errQPACKTooManyBlockedStreams
+	}
+	d.numBlocked++
+	defer func() { d.numBlocked-- }()
+
+	for d.table.insertCount() < requiredInsertCount {
+		inserted := d.inserted
+		d.mutex.Unlock()
+		select {
+		case <-inserted:
+			d.mutex.Lock()
+		case <-ctx.Done():
+			d.mutex.Lock()
+			// Stream Cancellation
+			d.pending = appendQPACKInt(d.pending, 0x40, 6, uint64(id))
+			return ctx.Err()
+		case <-d.conn.Context().Done():
+			d.mutex.Lock()
+			return context.Cause(d.conn.Context())
+		}
+	}
+	return nil
+}
+
+// readPrefix reads the Encoded Field Section Prefix, see section 4.5.1 of RFC 9204.
+func (d *qpackDecoder) readPrefix(r *bytes.Reader) (requiredInsertCount, base uint64, _ error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, errors.New("QPACK: truncated field section prefix")
+	}
+	encRequiredInsertCount, err := readQPACKInt(r, b, 8)
+	if err != nil {
+		return 0, 0, errors.New("QPACK: truncated field section prefix")
+	}
+	requiredInsertCount, err = d.decodeRequiredInsertCount(encRequiredInsertCount)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err = r.ReadByte()
+	if err != nil {
+		return 0, 0, errors.New("QPACK: truncated field section prefix")
+	}
+	deltaBase, err := readQPACKInt(r, b, 7)
+	if err != nil {
+		return 0, 0, errors.New("QPACK: truncated field section prefix")
+	}
+	if b&0x80 == 0 {
+		return requiredInsertCount, requiredInsertCount + deltaBase, nil
+	}
+	if deltaBase >= requiredInsertCount {
+		return 0, 0, fmt.Errorf("QPACK: invalid Delta Base %d for Required Insert Count %d", deltaBase, requiredInsertCount)
+	}
+	return requiredInsertCount, requiredInsertCount - deltaBase - 1, nil
+}
+
+// decodeRequiredInsertCount decodes the Required Insert Count, see section 4.5.1.1 of RFC 9204.
+func (d *qpackDecoder) decodeRequiredInsertCount(enc uint64) (uint64, error) {
+	if enc == 0 {
+		return 0, nil
+	}
+	maxEntries := d.maxTableCapacity / qpackEntryOverhead
+	fullRange := 2 * maxEntries
+	if enc > fullRange {
+		return 0, fmt.Errorf("QPACK: invalid Required Insert Count: %d", enc)
+	}
+	maxValue := d.table.insertCount() + maxEntries
+	maxWrapped := (maxValue / fullRange) * fullRange
+	requiredInsertCount := maxWrapped + enc - 1
+	if requiredInsertCount > maxValue {
+		if requiredInsertCount <= fullRange {
+			return 0, fmt.Errorf("QPACK: invalid Required Insert Count: %d", enc)
+		}
+		requiredInsertCount -= fullRange
+	}
+	if requiredInsertCount == 0 {
+		return 0, fmt.Errorf("QPACK: invalid Required Insert Count: %d", enc)
+	}
+	return requiredInsertCount, nil
+}
+
+// decodeFieldLines decodes the field line representations, see section 4.5 of RFC 9204.
+// It must be called with the mutex held.
+func (d *qpackDecoder) decodeFieldLines(r *bytes.Reader, requiredInsertCount, base uint64) ([]qpack.HeaderField, error) {
+	hfs := make([]qpack.HeaderField, 0, 8)
+	var largestRef uint64 // the largest absolute index referenced, plus 1
+	getDynamic := func(index uint64) (qpack.HeaderField, error) {
+		if index >= requiredInsertCount {
+			return qpack.HeaderField{}, fmt.Errorf("QPACK: reference to dynamic table entry %d exceeds Required Insert Count %d", index, requiredInsertCount)
+		}
+		hf, ok := d.table.get(index)
+		if !ok {
+			return qpack.HeaderField{}, fmt.Errorf("QPACK: reference to evicted dynamic table entry %d", index)
+		}
+		if index+1 > largestRef {
+			largestRef = index + 1
+		}
+		return hf, nil
+	}
+	getRelative := func(relIndex uint64) (qpack.HeaderField, error) {
+		if relIndex >= base {
+			return qpack.HeaderField{}, fmt.Errorf("QPACK: invalid relative index %d for base %d", relIndex, base)
+		}
+		return getDynamic(base - 1 - relIndex)
+	}
+	getStatic := func(index uint64) (qpack.HeaderField, error) {
+		if index >= uint64(len(qpackStaticTable)) {
+			return qpack.HeaderField{}, fmt.Errorf("QPACK: invalid static table index %d", index)
+		}
+		return qpackStaticTable[index], nil
+	}
+	readValue := func(hf qpack.HeaderField) (qpack.HeaderField, error) {
+		b, err := r.ReadByte()
+		if err != nil {
+			return qpack.HeaderField{}, err
+		}
+		hf.Value, err = readQPACKString(r, b, 7, uint64(r.Len()))
+		return hf, err
+	}
+
+	for r.Len() > 0 {
+		b, _ := r.ReadByte()
+		var hf qpack.HeaderField
+		var err error
+		switch {
+		case b&0x80 > 0: // Indexed Field Line: 1Txxxxxx
+			var index uint64
+			index, err = readQPACKInt(r, b, 6)
+			if err != nil {
+				break
+			}
+			if b&0x40 > 0 {
+				hf, err = getStatic(index)
+			} else {
+				hf, err = getRelative(index)
+			}
+		case b&0xf0 == 0x10: // Indexed Field Line with Post-Base Index: 0001xxxx
+			var index uint64
+			index, err = readQPACKInt(r, b, 4)
+			if err != nil {
+				break
+			}
+			hf, err = getDynamic(base + index)
+		case b&0xc0 == 0x40: // Literal Field Line with Name Reference: 01NTxxxx
+			var index uint64
+			index, err = readQPACKInt(r, b, 4)
+			if err != nil {
+				break
+			}
+			if b&0x10 > 0 {
+				hf, err = getStatic(index)
+			} else {
+				hf, err = getRelative(index)
+			}
+			if err != nil {
+				break
+			}
+			hf, err = readValue(hf)
+		case b&0xe0 == 0x20: // Literal Field Line with Literal Name: 001NHxxx
+			hf.Name, err = readQPACKString(r, b, 3, uint64(r.Len()))
+			if err != nil {
+				break
+			}
+			hf, err = readValue(hf)
+		default: // Literal Field Line with Post-Base Name Reference: 0000Nxxx
+			var index uint64
+			index, err = readQPACKInt(r, b, 3)
+			if err != nil {
+				break
+			}
+			hf, err = getDynamic(base + index)
+			if err != nil {
+				break
+			}
+			hf, err = readValue(hf)
+		}
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, errors.New("QPACK: truncated field line")
+			}
+			return nil, err
+		}
+		hfs = append(hfs, hf)
+	}
+	// The Required Insert Count must not be larger than necessary, see section 2.2.3 of RFC 9204.
+	if largestRef != requiredInsertCount {
+		return nil, fmt.Errorf("QPACK: Required Insert Count %d, but largest reference is %d", requiredInsertCount, largestRef)
+	}
+	return hfs, nil
+}
+
+// handleEncoderStream handles the peer's encoder stream, after the stream type was read.
+// It returns when the stream is closed, or when an invalid instruction is received.
+func (d *qpackDecoder) handleEncoderStream(str io.Reader) requestError {
+	rec := &readErrorRecorder{Reader: str}
+	r := bufio.NewReader(rec)
+	for {
+		b, err := r.ReadByte()
+		if err == nil {
+			err = d.handleEncoderInstruction(r, b)
+		}
+		if err != nil {
+			if rec.err != nil {
+				return newConnError(ErrCodeClosedCriticalStream, fmt.Errorf("QPACK encoder stream: %w", rec.err))
+			}
+			return newConnError(ErrCodeQPACKEncoderStreamError, err)
+		}
+		// Acknowledge the insertions once all data that was received so far was processed.
+		if r.Buffered() == 0 {
+			d.mutex.Lock()
+			d.sendInsertCountIncrement()
+			d.mutex.Unlock()
+			d.flush()
+		}
+	}
+}
+
+// handleEncoderInstruction handles an instruction on the encoder stream, see section 4.3 of RFC 9204.
+func (d *qpackDecoder) handleEncoderInstruction(r *bufio.Reader, b byte) error {
+	// Strings longer than the table capacity can't be inserted anyway.
+	d.mutex.Lock()
+	maxLen := d.table.capacity
+	d.mutex.Unlock()
+
+	switch {
+	case b&0x80 > 0: // Insert with Name Reference: 1Txxxxxx
+		index, err := readQPACKInt(r, b, 6)
+		if err != nil {
+			return err
+		}
+		var hf qpack.HeaderField
+		if b&0x40 > 0 {
+			if index >= uint64(len(qpackStaticTable)) {
+				return fmt.Errorf("invalid static table index %d", index)
+			}
+			hf.Name = qpackStaticTable[index].Name
+		} else {
+			d.mutex.Lock()
+			entry, err := d.getRelative(index)
+			d.mutex.Unlock()
+			if err != nil {
+				return err
+			}
+			hf.Name = entry.Name
+		}
+		vb, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		hf.Value, err = readQPACKString(r, vb, 7, maxLen)
+		if err != nil {
+			return err
+		}
+		return d.insert(hf)
+	case b&0xc0 == 0x40: // Insert with Literal Name: 01Hxxxxx
+		name, err := readQPACKString(r, b, 5, maxLen)
+		if err != nil {
+			return err
+		}
+		vb, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		value, err := readQPACKString(r, vb, 7, maxLen)
+		if err != nil {
+			return err
+		}
+		return d.insert(qpack.HeaderField{Name: name, Value: value})
+	case b&0xe0 == 0x20: // Set Dynamic Table Capacity: 001xxxxx
+		capacity, err := readQPACKInt(r, b, 5)
+		if err != nil {
+			return err
+		}
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		if capacity > d.maxTableCapacity {
+			return fmt.Errorf("dynamic table capacity %d exceeds the maximum of %d", capacity, d.maxTableCapacity)
+		}
+		d.table.capacity = capacity
+		for d.table.size > capacity {
+			d.table.evictOldest()
+		}
+		if d.tracer != nil {
+			d.tracer.QPACKStateUpdated(false, d.table.qlogState())
+		}
+		return nil
+	default: // Duplicate: 000xxxxx
+		index, err := readQPACKInt(r, b, 5)
+		if err != nil {
+			return err
+		}
+		d.mutex.Lock()
+		hf, err := d.getRelative(index)
+		d.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+		return d.insert(hf)
+	}
+}
+
+// getRelative returns the entry with the relative index used on the encoder stream.
+// It must be called with the mutex held.
+func (d *qpackDecoder) getRelative(index uint64) (qpack.HeaderField, error) {
+	if index >= d.table.insertCount() {
+		return qpack.HeaderField{}, fmt.Errorf("invalid relative index %d", index)
+	}
+	hf, ok := d.table.get(d.table.insertCount() - 1 - index)
+	if !ok {
+		return qpack.HeaderField{}, fmt.Errorf("reference to evicted entry with relative index %d", index)
+	}
+	return hf, nil
+}
+
+func (d *qpackDecoder) insert(hf qpack.HeaderField) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	size := qpackEntrySize(hf)
+	if size > d.table.capacity {
+		return fmt.Errorf("entry of size %d exceeds the dynamic table capacity of %d", size, d.table.capacity)
+	}
+	for d.table.size+size > d.table.capacity {
+		d.table.evictOldest()
+	}
+	d.table.insert(hf)
+	close(d.inserted)
+	d.inserted = make(chan struct{})
+	if d.tracer != nil {
+		d.tracer.QPACKStateUpdated(false, d.table.qlogState())
+	}
+	return nil
+}
+
+// sendInsertCountIncrement acknowledges all insertions that the peer's encoder doesn't know we received yet.
+// The instruction is written by the next call to flush.
+// It must be called with the mutex held.
+func (d *qpackDecoder) sendInsertCountIncrement() {
+	inc := d.table.insertCount() - d.acknowledged
+	if inc == 0 {
+		return
+	}
+	d.acknowledged += inc
+	d.pending = appendQPACKInt(d.pending, 0, 6, inc)
+}
+
+// flush writes the pending instructions on our decoder stream, opening the stream if necessary.
+// It must not be called with the mutex held.
+func (d *qpackDecoder) flush() {
+	d.writeMutex.Lock()
+	defer d.writeMutex.Unlock()
+
+	d.mutex.Lock()
+	b := d.pending
+	d.pending = nil
+	d.mutex.Unlock()
+	if len(b) == 0 {
+		return
+	}
+	if d.str == nil {
+		str, err := openQPACKStream(d.conn, streamTypeQPACKDecoderStream, d.tracer)
+		if err != nil {
+			d.conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeClosedCriticalStream), "")
+			return
+		}
+		d.str = str
+	}
+	if _, err := d.str.Write(b); err != nil {
+		d.conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeClosedCriticalStream), "")
+	}
+}
+
+// readErrorRecorder records the error returned when reading from the underlying reader.
+type readErrorRecorder struct {
+	io.Reader
+	err error
+}
+
+func (r *readErrorRecorder) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,368 @@
+package http3
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/qlog"
+
+	"github.com/quic-go/qpack"
+)
+
+// qpackSection is a field section that references entries of the dynamic table,
+// and that wasn't acknowledged by the peer's decoder yet.
+type qpackSection struct {
+	requiredInsertCount uint64
+	refs                []uint64 // absolute indices of the dynamic table entries referenced
+}
+
+// qpackEncoder encodes the field sections sent on a connection.
+// Header fields are inserted into the dynamic table by sending instructions on our encoder stream.
+// The peer's decoder acknowledges the insertions and field sections on its decoder stream.
+type qpackEncoder struct {
+	conn             quic.Connection
+	maxTableCapacity uint64 // the maximum capacity we're willing to use
+
+	tracer *qlog.HTTP3Tracer // may be nil
+
+	mutex                 sync.Mutex
+	peerMaxBlockedStreams uint64
+	maxEntries            uint64 // derived from the peer's SETTINGS_QPACK_MAX_TABLE_CAPACITY, used to encode the Required Insert Count
+	table                 qpackDynamicTable
+	capacityAnnounced     bool
+	refs                  map[uint64]int // absolute index -> number of unacknowledged field sections referencing the entry
+	index                 map[qpack.HeaderField]uint64
+	nameIndex             map[string]uint64
+	knownReceivedCount    uint64
+	sections              map[quic.StreamID][]qpackSection
+
+	// writeMutex serializes writes to the encoder stream.
+	// It is acquired before releasing mutex, so that instructions are written in the order they were generated.
+	// Writes may block on flow control, so they're never done while holding mutex.
+	writeMutex sync.Mutex
+	str        quic.SendStream
+}
+
+func newQPACKEncoder(conn quic.Connection, maxTableCapacity uint64, tracer *qlog.HTTP3Tracer) *qpackEncoder {
+	return &qpackEncoder{
+		conn:             conn,
+		maxTableCapacity: maxTableCapacity,
+		tracer:           tracer,
+		refs:             make(map[uint64]int),
+		index:            make(map[qpack.HeaderField]uint64),
+		nameIndex:        make(map[string]uint64),
+		sections:         make(map[quic.StreamID][]qpackSection),
+	}
+}
+
+// handleSettings applies the peer's QPACK settings.
+// Until the peer's SETTINGS frame is received, the dynamic table is not used.
+func (e *qpackEncoder) handleSettings(sf *settingsFrame) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.maxEntries = sf.QPACKMaxTableCapacity / qpackEntryOverhead
+	e.peerMaxBlockedStreams = sf.QPACKBlockedStreams
+	e.table.capacity = min(e.maxTableCapacity, sf.QPACKMaxTableCapacity)
+}
+
+// encode encodes a field section sent on stream id.
+// Instructions inserting header fields into the dynamic table are sent on the encoder stream before encode returns,
+// so they're sent before the field section referencing them.
+func (e *qpackEncoder) encode(id quic.StreamID, hfs []qpack.HeaderField) ([]byte, error) {
+	e.mutex.Lock()
+
+	var instructions []byte
+	if !e.capacityAnnounced && e.table.capacity > 0 {
+		// Set Dynamic Table Capacity
+		instructions = appendQPACKInt(instructions, 0x20, 5, e.table.capacity)
+		e.capacityAnnounced = true
+	}
+	base := e.table.insertCount()
+	canBlock := e.isBlocking(id) || e.numBlockingStreams() < e.peerMaxBlockedStreams
+	usable := func(index uint64) bool { return index < e.knownReceivedCount || canBlock }
+
+	var refs []uint64
+	ref := func(index uint64) {
+		e.refs[index]++
+		refs = append(refs, index)
+	}
+	lines := make([]byte, 0, 128)
+	for _, hf := range hfs {
+		if index, ok := qpackStaticIndex[hf]; ok {
+			// Indexed Field Line, static table
+			lines = appendQPACKInt(lines, 0xc0, 6, index)
+			continue
+		}
+		index, ok := e.index[hf]
+		if !ok && e.shouldIndex(hf) {
+			var inserted bool
+			index, inserted, instructions = e.insert(hf, instructions)
+			ok = inserted
+		}
+		if ok && usable(index) {
+			ref(index)
+			if index < base {
+				// Indexed Field Line, dynamic table
+				lines = appendQPACKInt(lines, 0x80, 6, base-1-index)
+			} else {
+				// Indexed Field Line with Post-Base Index
+				lines = appendQPACKInt(lines, 0x10, 4, index-base)
+			}
+			continue
+		}
+		if index, ok := qpackStaticNameIndex[hf.Name]; ok {
+			// Literal Field Line with Name Reference, static table
+			lines = appendQPACKInt(lines, 0x50, 4, index)
+		} else if index, ok := e.nameIndex[hf.Name]; ok && usable(index) {
+			ref(index)
+			if index < base {
+				// Literal Field Line with Name Reference, dynamic table
+				lines = appendQPACKInt(lines, 0x40, 4, base-1-index)
+			} else {
+				// Literal Field Line with Post-Base Name Reference
+				lines = appendQPACKInt(lines, 0x00, 3, index-base)
+			}
+		} else {
+			// Literal Field Line with Literal Name
+			lines = appendQPACKString(lines, 0x20, 3, hf.Name)
+		}
+		lines = appendQPACKString(lines, 0, 7, hf.Value)
+	}
+
+	var requiredInsertCount uint64
+	for _, index := range refs {
+		requiredInsertCount = max(requiredInsertCount, index+1)
+	}
+	b := make([]byte, 0, 2+len(lines))
+	if requiredInsertCount == 0 {
+		b = append(b, 0, 0)
+	} else {
+		b = appendQPACKInt(b, 0, 8, requiredInsertCount%(2*e.maxEntries)+1)
+		if base >= requiredInsertCount {
+			b = appendQPACKInt(b, 0, 7, base-requiredInsertCount)
+		} else {
+			b = appendQPACKInt(b, 0x80, 7, requiredInsertCount-base-1)
+		}
+		e.sections[id] = append(e.sections[id], qpackSection{requiredInsertCount: requiredInsertCount, refs: refs})
+	}
+	b = append(b, lines...)
+
+	if len(instructions) == 0 {
+		e.mutex.Unlock()
+		return b, nil
+	}
+	e.traceState()
+	e.writeMutex.Lock()
+	e.mutex.Unlock()
+	defer e.writeMutex.Unlock()
+	if err := e.writeInstructions(instructions); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// shouldIndex decides if a header field is inserted into the dynamic table.
+// Header fields that are unlikely to be repeated are not inserted.
+func (e *qpackEncoder) shouldIndex(hf qpack.HeaderField) bool {
+	switch hf.Name {
+	case ":path", "content-length", "content-range", "date", "etag", "last-modified",
+		"if-modified-since", "if-none-match", "age", "expires":
+		return false
+	}
+	return qpackEntrySize(hf) <= e.table.capacity/2
+}
+
+// insert inserts a header field into the dynamic table, if there's enough space for it.
+// Only entries that are not referenced by any unacknowledged field section are evicted,
+// see section 2.1.1 of RFC 9204.
+// It must be called with the mutex held.
+func (e *qpackEncoder) insert(hf qpack.HeaderField, instructions []byte) (uint64, bool, []byte) {
+	size := qpackEntrySize(hf)
+	available := e.table.capacity - e.table.size
+	for i, entry := range e.table.entries {
+		if available >= size {
+			break
+		}
+		if index := e.table.evicted + uint64(i); e.refs[index] > 0 || index >= e.knownReceivedCount {
+			break
+		}
+		available += qpackEntrySize(entry)
+	}
+	if available < size {
+		return 0, false, instructions
+	}
+	for e.table.capacity-e.table.size < size {
+		e.evictOldest()
+	}
+
+	if index, ok := qpackStaticNameIndex[hf.Name]; ok {
+		// Insert with Name Reference, static table
+		instructions = appendQPACKInt(instructions, 0xc0, 6, index)
+	} else if index, ok := e.nameIndex[hf.Name]; ok {
+		// Insert with Name Reference, dynamic table
+		instructions = appendQPACKInt(instructions, 0x80, 6, e.table.insertCount()-1-index)
+	} else {
+		// Insert with Literal Name
+		instructions = appendQPACKString(instructions, 0x40, 5, hf.Name)
+	}
+	instructions = appendQPACKString(instructions, 0, 7, hf.Value)
+	index := e.table.insert(hf)
+	e.index[hf] = index
+	e.nameIndex[hf.Name] = index
+	return index, true, instructions
+}
+
+// evictOldest evicts the oldest entry of the dynamic table.
+// It must be called with the mutex held.
+func (e *qpackEncoder) evictOldest() {
+	index := e.table.evicted
+	hf := e.table.entries[0]
+	if i, ok := e.index[hf]; ok && i == index {
+		delete(e.index, hf)
+	}
+	if i, ok := e.nameIndex[hf.Name]; ok && i == index {
+		delete(e.nameIndex, hf.Name)
+	}
+	delete(e.refs, index)
+	e.table.evictOldest()
+}
+
+// isBlocking says if stream id has an unacknowledged field section that might block the peer's decoder.
+func (e *qpackEncoder) isBlocking(id quic.StreamID) bool {
+	for _, s := range e.sections[id] {
+		if s.requiredInsertCount > e.knownReceivedCount {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *qpackEncoder) numBlockingStreams() uint64 {
+	var n uint64
+	for id := range e.sections {
+		if e.isBlocking(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// writeInstructions writes instructions on our encoder stream, opening the stream if necessary.
+// It must be called with the writeMutex held, and without holding the mutex.
+func (e *qpackEncoder) writeInstructions(b []byte) error {
+	if e.str == nil {
+		str, err := openQPACKStream(e.conn, streamTypeQPACKEncoderStream, e.tracer)
+		if err != nil {
+			e.conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeClosedCriticalStream), "")
+			return err
+		}
+		e.str = str
+	}
+	if _, err := e.str.Write(b); err != nil {
+		e.conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeClosedCriticalStream), "")
+		return err
+	}
+	return nil
+}
+
+func (e *qpackEncoder) qlogState() *qlog.QPACKState {
+	s := e.table.qlogState()
+	s.KnownReceivedCount = e.knownReceivedCount
+	return s
+}
+
+// handleDecoderStream handles the peer's decoder stream, after the stream type was read.
+// It returns when the stream is closed, or when an invalid instruction is received.
+func (e *qpackEncoder) handleDecoderStream(str io.Reader) requestError {
+	rec := &readErrorRecorder{Reader: str}
+	r := bufio.NewReader(rec)
+	for {
+		b, err := r.ReadByte()
+		if err == nil {
+			err = e.handleDecoderInstruction(r, b)
+		}
+		if err != nil {
+			if rec.err != nil {
+				return newConnError(ErrCodeClosedCriticalStream, fmt.Errorf("QPACK decoder stream: %w", rec.err))
+			}
+			return newConnError(ErrCodeQPACKDecoderStreamError, err)
+		}
+	}
+}
+
+// handleDecoderInstruction handles an instruction on the decoder stream, see section 4.4 of RFC 9204.
+func (e *qpackEncoder) handleDecoderInstruction(r io.ByteReader, b byte) error {
+	switch {
+	case b&0x80 > 0: // Section Acknowledgment: 1xxxxxxx
+		id, err := readQPACKInt(r, b, 7)
+		if err != nil {
+			return err
+		}
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		sections := e.sections[quic.StreamID(id)]
+		if len(sections) == 0 {
+			return fmt.Errorf("Section Acknowledgment for stream %d without outstanding field section", id)
+		}
+		e.releaseSection(sections[0])
+		if len(sections) == 1 {
+			delete(e.sections, quic.StreamID(id))
+		} else {
+			e.sections[quic.StreamID(id)] = sections[1:]
+		}
+		e.knownReceivedCount = max(e.knownReceivedCount, sections[0].requiredInsertCount)
+		e.traceState()
+		return nil
+	case b&0x40 > 0: // Stream Cancellation: 01xxxxxx
+		id, err := readQPACKInt(r, b, 6)
+		if err != nil {
+			return err
+		}
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		for _, s := range e.sections[quic.StreamID(id)] {
+			e.releaseSection(s)
+		}
+		delete(e.sections, quic.StreamID(id))
+		return nil
+	default: // Insert Count Increment: 00xxxxxx
+		inc, err := readQPACKInt(r, b, 6)
+		if err != nil {
+			return err
+		}
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		if inc == 0 {
+			return errors.New("invalid Insert Count Increment: 0")
+		}
+		if e.knownReceivedCount+inc > e.table.insertCount() {
+			return fmt.Errorf("Insert Count Increment %d exceeds the number of entries inserted", inc)
+		}
+		e.knownReceivedCount += inc
+		e.traceState()
+		return nil
+	}
+}
+
+// releaseSection releases the references of a field section.
+// It must be called with the mutex held.
+func (e *qpackEncoder) releaseSection(s qpackSection) {
+	for _, index := range s.refs {
+		if e.refs[index] > 1 {
+			e.refs[index]--
+		} else {
+			delete(e.refs, index)
+		}
+	}
+}
+
+func (e *qpackEncoder) traceState() {
+	if e.tracer != nil {
+		e.tracer.QPACKStateUpdated(true, e.qlogState())
+	}
+}
@@ -0,0 +1,488 @@
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/nxenon/xquic-go"
+	mockquic "github.com/nxenon/xquic-go/internal/mocks/quic"
+	"github.com/nxenon/xquic-go/internal/testdata"
+
+	"github.com/quic-go/qpack"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("QPACK", func() {
+	Context("integers", func() {
+		It("encodes integers", func() {
+			// examples from section C.1 of RFC 7541
+			Expect(appendQPACKInt(nil, 0, 5, 10)).To(Equal([]byte{0x0a}))
+			Expect(appendQPACKInt(nil, 0, 5, 1337)).To(Equal([]byte{0x1f, 0x9a, 0x0a}))
+			Expect(appendQPACKInt(nil, 0, 8, 42)).To(Equal([]byte{0x2a}))
+			// flags are preserved
+			Expect(appendQPACKInt(nil, 0xe0, 5, 10)).To(Equal([]byte{0xea}))
+		})
+
+		It("encodes and decodes integers", func() {
+			for _, n := range []uint8{3, 4, 5, 6, 7, 8} {
+				for _, v := range []uint64{0, 1, 1<<n - 2, 1<<n - 1, 1 << n, 1337, 1 << 40, 1<<63 - 1} {
+					b := appendQPACKInt(nil, 0, n, v)
+					r := bytes.NewReader(b[1:])
+					val, err := readQPACKInt(r, b[0], n)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(val).To(Equal(v))
+					Expect(r.Len()).To(BeZero())
+				}
+			}
+		})
+
+		It("errors on truncated integers", func() {
+			b := appendQPACKInt(nil, 0, 5, 1337)
+			_, err := readQPACKInt(bytes.NewReader(b[1:2]), b[0], 5)
+			Expect(err).To(MatchError(io.EOF))
+		})
+
+		It("errors on integers that overflow", func() {
+			b := bytes.Repeat([]byte{0xff}, 11)
+			_, err := readQPACKInt(bytes.NewReader(b[1:]), b[0], 5)
+			Expect(err).To(MatchError(errQPACKIntegerOverflow))
+		})
+	})
+
+	Context("strings", func() {
+		It("encodes and decodes strings", func() {
+			for _, s := range []string{"", "foo", "www.example.com", "\x00\x01\xff", string(bytes.Repeat([]byte("a"), 1000))} {
+				b := appendQPACKString(nil, 0, 7, s)
+				r := bytes.NewReader(b[1:])
+				str, err := readQPACKString(r, b[0], 7, 10000)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str).To(Equal(s))
+				Expect(r.Len()).To(BeZero())
+			}
+		})
+
+		It("uses Huffman encoding if that makes the string shorter", func() {
+			// example from section C.4.1 of RFC 7541
+			b := appendQPACKString(nil, 0, 7, "www.example.com")
+			Expect(b).To(Equal([]byte{0x8c, 0xf1, 0xe3, 0xc2, 0xe5, 0xf2, 0x3a, 0x6b, 0xa0, 0xab, 0x90, 0xf4, 0xff}))
+			b = appendQPACKString(nil, 0, 7, "\x00\x01")
+			Expect(b).To(Equal([]byte{0x02, 0x00, 0x01}))
+		})
+
+		It("rejects strings that are too long", func() {
+			b := appendQPACKString(nil, 0, 7, "foobar")
+			_, err := readQPACKString(bytes.NewReader(b[1:]), b[0], 7, 3)
+			Expect(err).To(MatchError("QPACK string too long: 5 bytes"))
+		})
+	})
+
+	Context("dynamic table", func() {
+		var (
+			encoder          *qpackEncoder
+			decoder          *qpackDecoder
+			encoderStreamBuf *bytes.Buffer // instructions sent by the encoder
+			decoderStreamBuf *bytes.Buffer // instructions sent by the decoder
+		)
+
+		newSendStream := func(buf *bytes.Buffer) *mockquic.MockStream {
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
+			return str
+		}
+
+		setup := func(capacity, blockedStreams uint64) {
+			encoderStreamBuf = &bytes.Buffer{}
+			decoderStreamBuf = &bytes.Buffer{}
+			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().Context().Return(context.Background()).AnyTimes()
+			encoder = newQPACKEncoder(conn, 4096, nil)
+			encoder.str = newSendStream(encoderStreamBuf)
+			decoder = newQPACKDecoder(conn, capacity, blockedStreams, nil)
+			decoder.str = newSendStream(decoderStreamBuf)
+			encoder.handleSettings(&settingsFrame{QPACKMaxTableCapacity: capacity, QPACKBlockedStreams: blockedStreams})
+		}
+
+		// handleEncoderInstructions passes the instructions sent by the encoder to the decoder
+		handleEncoderInstructions := func() {
+			r := bufio.NewReader(encoderStreamBuf)
+			for {
+				b, err := r.ReadByte()
+				if err == io.EOF {
+					break
+				}
+				ExpectWithOffset(1, decoder.handleEncoderInstruction(r, b)).To(Succeed())
+			}
+			decoder.mutex.Lock()
+			decoder.sendInsertCountIncrement()
+			decoder.mutex.Unlock()
+			decoder.flush()
+		}
+
+		// handleDecoderInstructions passes the instructions sent by the decoder to the encoder
+		handleDecoderInstructions := func() {
+			for {
+				b, err := decoderStreamBuf.ReadByte()
+				if err == io.EOF {
+					break
+				}
+				ExpectWithOffset(1, encoder.handleDecoderInstruction(decoderStreamBuf, b)).To(Succeed())
+			}
+		}
+
+		BeforeEach(func() {
+			setup(4096, 16)
+		})
+
+		hfs := []qpack.HeaderField{
+			{Name: ":method", Value: "GET"},
+			{Name: ":authority", Value: "example.com"},
+			{Name: ":path", Value: "/foo"},
+			{Name: "user-agent", Value: "quic-go HTTP/3"},
+			{Name: "x-custom", Value: "foobar"},
+		}
+
+		It("inserts header fields into the dynamic table", func() {
+			b1, err := encoder.encode(0, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoderStreamBuf.Len()).ToNot(BeZero())
+			// :method GET is in the static table, and :path is never indexed
+			Expect(encoder.table.insertCount()).To(BeEquivalentTo(3))
+			handleEncoderInstructions()
+			decoded, err := decoder.decode(context.Background(), 0, b1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(Equal(hfs))
+			handleDecoderInstructions()
+			Expect(encoder.knownReceivedCount).To(BeEquivalentTo(3))
+			Expect(encoder.sections).To(BeEmpty())
+			Expect(encoder.refs).To(BeEmpty())
+
+			// the second field section references the dynamic table
+			b2, err := encoder.encode(4, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoderStreamBuf.Len()).To(BeZero())
+			static, err := newQPACKEncoder(nil, 0, nil).encode(4, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(b2)).To(BeNumerically("<", len(static)))
+			decoded, err = decoder.decode(context.Background(), 4, b2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(Equal(hfs))
+		})
+
+		It("doesn't use the dynamic table if the peer doesn't allow it", func() {
+			setup(0, 0)
+			b, err := encoder.encode(0, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoderStreamBuf.Len()).To(BeZero())
+			// the external QPACK decoder only supports the static table
+			decoded, err := qpack.NewDecoder(nil).DecodeFull(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(Equal(hfs))
+		})
+
+		It("only references unacknowledged entries if the peer allows blocked streams", func() {
+			setup(4096, 0)
+			b, err := encoder.encode(0, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			// the encoder inserts the header fields, but the field section doesn't reference them
+			Expect(encoder.table.insertCount()).To(BeEquivalentTo(3))
+			Expect(b[0]).To(BeZero()) // Required Insert Count
+			decoded, err := decoder.decode(context.Background(), 0, b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(Equal(hfs))
+			Expect(decoderStreamBuf.Len()).To(BeZero())
+
+			handleEncoderInstructions()
+			handleDecoderInstructions()
+			Expect(encoder.knownReceivedCount).To(BeEquivalentTo(3))
+			b, err = encoder.encode(4, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b[0]).ToNot(BeZero())
+			decoded, err = decoder.decode(context.Background(), 4, b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(Equal(hfs))
+		})
+
+		It("blocks streams until the referenced entries are received", func() {
+			b, err := encoder.encode(0, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				decoded, err := decoder.decode(context.Background(), 0, b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(decoded).To(Equal(hfs))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			handleEncoderInstructions()
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("limits the number of blocked streams", func() {
+			setup(4096, 1)
+			b, err := encoder.encode(0, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			go decoder.decode(context.Background(), 0, b)
+			Eventually(func() uint64 {
+				decoder.mutex.Lock()
+				defer decoder.mutex.Unlock()
+				return decoder.numBlocked
+			}).Should(BeEquivalentTo(1))
+			_, err = decoder.decode(context.Background(), 4, b)
+			Expect(err).To(MatchError(errQPACKTooManyBlockedStreams))
+			handleEncoderInstructions()
+		})
+
+		It("sends a Stream Cancellation when a blocked stream is cancelled", func() {
+			b, err := encoder.encode(8, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = decoder.decode(ctx, 8, b)
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(decoderStreamBuf.Bytes()).To(Equal([]byte{0x40 | 8}))
+			Expect(encoder.refs).ToNot(BeEmpty())
+			handleDecoderInstructions()
+			Expect(encoder.sections).To(BeEmpty())
+			Expect(encoder.refs).To(BeEmpty())
+		})
+
+		It("doesn't evict entries that are referenced by unacknowledged field sections", func() {
+			setup(100, 16) // enough space for two entries
+			hf1 := []qpack.HeaderField{{Name: "foo", Value: "bar"}, {Name: "foo", Value: "baz"}}
+			hf2 := []qpack.HeaderField{{Name: "foo", Value: "qux"}}
+			b1, err := encoder.encode(0, hf1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoder.table.insertCount()).To(BeEquivalentTo(2))
+			// The entries are still referenced, so there's no space for another entry.
+			b2, err := encoder.encode(4, hf2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoder.table.insertCount()).To(BeEquivalentTo(2))
+			handleEncoderInstructions()
+			decoded, err := decoder.decode(context.Background(), 0, b1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(Equal(hf1))
+			decoded, err = decoder.decode(context.Background(), 4, b2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(Equal(hf2))
+			handleDecoderInstructions()
+
+			// now the oldest entry can be evicted
+			b3, err := encoder.encode(8, hf2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoder.table.insertCount()).To(BeEquivalentTo(3))
+			Expect(encoder.table.entries).To(Equal([]qpack.HeaderField{hf1[1], hf2[0]}))
+			handleEncoderInstructions()
+			decoded, err = decoder.decode(context.Background(), 8, b3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(Equal(hf2))
+			Expect(decoder.table.entries).To(Equal(encoder.table.entries))
+		})
+
+		It("wraps the Required Insert Count", func() {
+			setup(100, 16) // the maximum number of entries is 3
+			for i := 0; i < 20; i++ {
+				hf := []qpack.HeaderField{{Name: "foo", Value: fmt.Sprintf("%d", i)}}
+				id := quic.StreamID(4 * i)
+				b, err := encoder.encode(id, hf)
+				Expect(err).ToNot(HaveOccurred())
+				handleEncoderInstructions()
+				decoded, err := decoder.decode(context.Background(), id, b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(decoded).To(Equal(hf))
+				handleDecoderInstructions()
+			}
+			Expect(decoder.table.insertCount()).To(BeEquivalentTo(20))
+		})
+
+		It("doesn't block the encoder while writing instructions", func() {
+			unblock := make(chan struct{})
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				<-unblock
+				return encoderStreamBuf.Write(b)
+			})
+			encoder.str = str
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := encoder.encode(0, hfs)
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			Eventually(func() uint64 {
+				encoder.mutex.Lock()
+				defer encoder.mutex.Unlock()
+				return encoder.table.insertCount()
+			}).Should(BeEquivalentTo(3))
+			// the peer's decoder acknowledges the insertions while the write is still blocked
+			Expect(encoder.handleDecoderInstruction(bytes.NewReader(nil), 3)).To(Succeed())
+			Consistently(done).ShouldNot(BeClosed())
+			close(unblock)
+			Eventually(done).Should(BeClosed())
+			Expect(encoderStreamBuf.Len()).ToNot(BeZero())
+		})
+
+		It("doesn't block the decoder while writing instructions", func() {
+			b, err := encoder.encode(0, hfs)
+			Expect(err).ToNot(HaveOccurred())
+			handleEncoderInstructions()
+			decoderStreamBuf.Reset()
+			unblock := make(chan struct{})
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				<-unblock
+				return decoderStreamBuf.Write(b)
+			})
+			decoder.str = str
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				decoded, err := decoder.decode(context.Background(), 0, b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(decoded).To(Equal(hfs))
+			}()
+			Eventually(func() bool {
+				if !decoder.writeMutex.TryLock() {
+					return true
+				}
+				decoder.writeMutex.Unlock()
+				return false
+			}).Should(BeTrue())
+			// the decoder handles instructions on the encoder stream while the write is still blocked
+			Expect(decoder.insert(qpack.HeaderField{Name: "foo", Value: "bar"})).To(Succeed())
+			Consistently(done).ShouldNot(BeClosed())
+			close(unblock)
+			Eventually(done).Should(BeClosed())
+			Expect(decoderStreamBuf.Bytes()).To(Equal([]byte{0x80}))
+		})
+
+		Context("decoder errors", func() {
+			It("rejects references to entries that don't exist", func() {
+				decoder.table.capacity = 4096
+				Expect(decoder.insert(qpack.HeaderField{Name: "foo", Value: "bar"})).To(Succeed())
+				Expect(decoder.insert(qpack.HeaderField{Name: "foo", Value: "baz"})).To(Succeed())
+				// Required Insert Count 2, Delta Base 0, Indexed Field Line with relative index 5
+				_, err := decoder.decode(context.Background(), 0, []byte{0x03, 0x00, 0x85})
+				Expect(err).To(MatchError("QPACK: invalid relative index 5 for base 2"))
+			})
+
+			It("rejects invalid Required Insert Counts", func() {
+				_, err := decoder.decode(context.Background(), 0, []byte{0xff, 0x7f, 0x00})
+				Expect(err).To(MatchError(ContainSubstring("invalid Required Insert Count")))
+			})
+
+			It("rejects field sections with a Required Insert Count that is too large", func() {
+				decoder.table.capacity = 4096
+				Expect(decoder.insert(qpack.HeaderField{Name: "foo", Value: "bar"})).To(Succeed())
+				Expect(decoder.insert(qpack.HeaderField{Name: "foo", Value: "baz"})).To(Succeed())
+				// Required Insert Count 2, Delta Base 0, references only entry 0
+				_, err := decoder.decode(context.Background(), 0, []byte{0x03, 0x00, 0x81})
+				Expect(err).To(MatchError("QPACK: Required Insert Count 2, but largest reference is 1"))
+			})
+
+			It("rejects a dynamic table capacity larger than the maximum", func() {
+				b := appendQPACKInt(nil, 0x20, 5, 4097)
+				Expect(decoder.handleEncoderInstruction(bufio.NewReader(bytes.NewReader(b[1:])), b[0])).To(MatchError("dynamic table capacity 4097 exceeds the maximum of 4096"))
+			})
+
+			It("rejects insertions larger than the dynamic table capacity", func() {
+				decoder.table.capacity = 40
+				Expect(decoder.insert(qpack.HeaderField{Name: "foo", Value: "bar"})).To(Succeed())
+				Expect(decoder.insert(qpack.HeaderField{Name: "foo", Value: "foobar"})).To(MatchError("entry of size 41 exceeds the dynamic table capacity of 40"))
+			})
+
+			It("closes the connection when the encoder stream is closed", func() {
+				rerr := decoder.handleEncoderStream(bytes.NewReader(nil))
+				Expect(rerr.connErr).To(Equal(ErrCodeClosedCriticalStream))
+			})
+
+			It("closes the connection on invalid instructions on the encoder stream", func() {
+				// Duplicate an entry that doesn't exist
+				rerr := decoder.handleEncoderStream(bytes.NewReader([]byte{0x00}))
+				Expect(rerr.connErr).To(Equal(ErrCodeQPACKEncoderStreamError))
+			})
+		})
+
+		Context("encoder errors", func() {
+			It("rejects Insert Count Increments of 0", func() {
+				Expect(encoder.handleDecoderInstruction(bytes.NewReader(nil), 0x00)).To(MatchError("invalid Insert Count Increment: 0"))
+			})
+
+			It("rejects Insert Count Increments exceeding the number of insertions", func() {
+				Expect(encoder.handleDecoderInstruction(bytes.NewReader(nil), 0x01)).To(MatchError("Insert Count Increment 1 exceeds the number of entries inserted"))
+			})
+
+			It("rejects Section Acknowledgments for streams without outstanding field sections", func() {
+				Expect(encoder.handleDecoderInstruction(bytes.NewReader(nil), 0x84)).To(MatchError("Section Acknowledgment for stream 4 without outstanding field section"))
+			})
+
+			It("closes the connection on invalid instructions on the decoder stream", func() {
+				// Insert Count Increment of 0
+				rerr := encoder.handleDecoderStream(bytes.NewReader([]byte{0x00}))
+				Expect(rerr.connErr).To(Equal(ErrCodeQPACKDecoderStreamError))
+			})
+		})
+	})
+
+	It("uses the dynamic table for requests and responses", func() {
+		s := &Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Echo", r.Header.Get("X-Custom"))
+			io.WriteString(w, r.URL.Path)
+		})}
+		ln, err := quic.ListenAddrEarly("localhost:0", ConfigureTLSConfig(testdata.GetTLSConfig()), nil)
+		Expect(err).ToNot(HaveOccurred())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			s.ServeListener(ln)
+		}()
+		defer func() {
+			Expect(s.Close()).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		}()
+
+		rt := &RoundTripper{TLSClientConfig: &tls.Config{RootCAs: testdata.GetRootCA()}}
+		defer rt.Close()
+		for i := 0; i < 5; i++ {
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost:%d/%d", ln.Addr().(*net.UDPAddr).Port, i), nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("X-Custom", "foobar")
+			rsp, err := rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Header.Get("X-Echo")).To(Equal("foobar"))
+			body, err := io.ReadAll(rsp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal(fmt.Sprintf("/%d", i)))
+		}
+
+		rt.mutex.Lock()
+		Expect(rt.clients).To(HaveLen(1))
+		var cl *client
+		for _, c := range rt.clients {
+			cl = c.roundTripCloser.(*client)
+		}
+		rt.mutex.Unlock()
+		// the server acknowledged the entries inserted by the client's encoder
+		Eventually(func() uint64 {
+			cl.encoder.mutex.Lock()
+			defer cl.encoder.mutex.Unlock()
+			return cl.encoder.knownReceivedCount
+		}).ShouldNot(BeZero())
+		// the client's decoder received the entries inserted by the server's encoder
+		cl.decoder.mutex.Lock()
+		defer cl.decoder.mutex.Unlock()
+		Expect(cl.decoder.table.insertCount()).ToNot(BeZero())
+	})
+})
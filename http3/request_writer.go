@@ -18,54 +18,66 @@ import (
 	"github.com/quic-go/qpack"
 	"github.com/nxenon/xquic-go"
 	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/qlog"
 )
 
 const bodyCopyBufferSize = 8 * 1024
 
 type requestWriter struct {
-	mutex     sync.Mutex
-	encoder   *qpack.Encoder
-	headerBuf *bytes.Buffer
+	mutex   sync.Mutex
+	encoder *qpackEncoder
+	fields  []qpack.HeaderField
 
 	logger utils.Logger
 }
 
-func newRequestWriter(logger utils.Logger) *requestWriter {
-	headerBuf := &bytes.Buffer{}
-	encoder := qpack.NewEncoder(headerBuf)
+func newRequestWriter(encoder *qpackEncoder, logger utils.Logger) *requestWriter {
 	return &requestWriter{
-		encoder:   encoder,
-		headerBuf: headerBuf,
-		logger:    logger,
+		encoder: encoder,
+		logger:  logger,
 	}
 }
 
-func (w *requestWriter) WriteRequestHeader(str quic.Stream, req *http.Request, gzip bool) error {
+// WriteRequestHeader writes the HEADERS frame for the request.
+// If tracer is non-nil, the frame is logged to the qlog.
+func (w *requestWriter) WriteRequestHeader(str quic.Stream, req *http.Request, gzip bool, tracer *qlog.HTTP3Tracer) error {
 	// TODO: figure out how to add support for trailers
 	buf := &bytes.Buffer{}
-	if err := w.writeHeaders(buf, req, gzip); err != nil {
+	var traceHeaders func(length uint64, hfs []qpack.HeaderField)
+	if tracer != nil {
+		traceHeaders = func(length uint64, hfs []qpack.HeaderField) {
+			tracer.FrameCreated(str.StreamID(), length, &qlog.HTTP3HeadersFrame{HeaderFields: qlogHeaderFields(hfs)})
+		}
+	}
+	if err := w.writeHeaders(buf, str.StreamID(), req, gzip, traceHeaders); err != nil {
 		return err
 	}
 	_, err := str.Write(buf.Bytes())
 	return err
 }
 
-func (w *requestWriter) writeHeaders(wr io.Writer, req *http.Request, gzip bool) error {
+func (w *requestWriter) writeHeaders(wr io.Writer, id quic.StreamID, req *http.Request, gzip bool, traceHeaders func(uint64, []qpack.HeaderField)) error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
-	defer w.encoder.Close()
-	defer w.headerBuf.Reset()
+	defer func() { w.fields = w.fields[:0] }()
 
 	if err := w.encodeHeaders(req, gzip, "", actualContentLength(req)); err != nil {
 		return err
 	}
+	headerBlock, err := w.encoder.encode(id, w.fields)
+	if err != nil {
+		return err
+	}
+	if traceHeaders != nil {
+		traceHeaders(uint64(len(headerBlock)), w.fields)
+	}
 
 	b := make([]byte, 0, 128)
-	b = (&headersFrame{Length: uint64(w.headerBuf.Len())}).Append(b)
+	b = (&headersFrame{Length: uint64(len(headerBlock))}).Append(b)
 	if _, err := wr.Write(b); err != nil {
 		return err
 	}
-	_, err := wr.Write(w.headerBuf.Bytes())
+	_, err = wr.Write(headerBlock)
 	return err
 }
 
@@ -86,11 +98,10 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 		return errors.New("http3: invalid Host header")
 	}
 
-	// http.NewRequest sets this field to HTTP/1.1
-	isExtendedConnect := req.Method == http.MethodConnect && req.Proto != "" && req.Proto != "HTTP/1.1"
+	extendedConnect := isExtendedConnect(req)
 
 	var path string
-	if req.Method != http.MethodConnect || isExtendedConnect {
+	if req.Method != http.MethodConnect || extendedConnect {
 		path = req.URL.RequestURI()
 		if !validPseudoPath(path) {
 			orig := path
@@ -127,11 +138,11 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 		// [RFC3986]).
 		f(":authority", host)
 		f(":method", req.Method)
-		if req.Method != http.MethodConnect || isExtendedConnect {
+		if req.Method != http.MethodConnect || extendedConnect {
 			f(":path", path)
 			f(":scheme", req.URL.Scheme)
 		}
-		if isExtendedConnect {
+		if extendedConnect {
 			f(":protocol", req.Proto)
 		}
 		if trailers != "" {
@@ -204,7 +215,7 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 	// Header list size is ok. Write the headers.
 	enumerateHeaders(func(name, value string) {
 		name = strings.ToLower(name)
-		w.encoder.WriteField(qpack.HeaderField{Name: name, Value: value})
+		w.fields = append(w.fields, qpack.HeaderField{Name: name, Value: value})
 		// if traceHeaders {
 		// 	traceWroteHeaderField(trace, name, value)
 		// }
@@ -41,16 +41,17 @@ var _ = Describe("Request Writer", func() {
 	}
 
 	BeforeEach(func() {
-		rw = newRequestWriter(utils.DefaultLogger)
+		rw = newRequestWriter(newQPACKEncoder(nil, 0, nil), utils.DefaultLogger)
 		strBuf = &bytes.Buffer{}
 		str = mockquic.NewMockStream(mockCtrl)
 		str.EXPECT().Write(gomock.Any()).DoAndReturn(strBuf.Write).AnyTimes()
+		str.EXPECT().StreamID().AnyTimes()
 	})
 
 	It("writes a GET request", func() {
 		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/index.html?foo=bar", nil)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(rw.WriteRequestHeader(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequestHeader(str, req, false, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue(":authority", "quic.clemente.io"))
 		Expect(headerFields).To(HaveKeyWithValue(":method", "GET"))
@@ -63,7 +64,7 @@ var _ = Describe("Request Writer", func() {
 		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/index.html?foo=bar", nil)
 		Expect(err).ToNot(HaveOccurred())
 		req.Host = "foo@bar" // @ is invalid
-		Expect(rw.WriteRequestHeader(str, req, false)).To(MatchError("http3: invalid Host header"))
+		Expect(rw.WriteRequestHeader(str, req, false, nil)).To(MatchError("http3: invalid Host header"))
 	})
 
 	It("sends cookies", func() {
@@ -79,7 +80,7 @@ var _ = Describe("Request Writer", func() {
 		}
 		req.AddCookie(cookie1)
 		req.AddCookie(cookie2)
-		Expect(rw.WriteRequestHeader(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequestHeader(str, req, false, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue("cookie", `Cookie #1="Value #1"; Cookie #2="Value #2"`))
 	})
@@ -87,7 +88,7 @@ var _ = Describe("Request Writer", func() {
 	It("adds the header for gzip support", func() {
 		req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/", nil)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(rw.WriteRequestHeader(str, req, true)).To(Succeed())
+		Expect(rw.WriteRequestHeader(str, req, true, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue("accept-encoding", "gzip"))
 	})
@@ -95,7 +96,7 @@ var _ = Describe("Request Writer", func() {
 	It("writes a CONNECT request", func() {
 		req, err := http.NewRequest(http.MethodConnect, "https://quic.clemente.io/", nil)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(rw.WriteRequestHeader(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequestHeader(str, req, false, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue(":method", "CONNECT"))
 		Expect(headerFields).To(HaveKeyWithValue(":authority", "quic.clemente.io"))
@@ -108,7 +109,7 @@ var _ = Describe("Request Writer", func() {
 		req, err := http.NewRequest(http.MethodConnect, "https://quic.clemente.io/foobar", nil)
 		Expect(err).ToNot(HaveOccurred())
 		req.Proto = "webtransport"
-		Expect(rw.WriteRequestHeader(str, req, false)).To(Succeed())
+		Expect(rw.WriteRequestHeader(str, req, false, nil)).To(Succeed())
 		headerFields := decode(strBuf)
 		Expect(headerFields).To(HaveKeyWithValue(":authority", "quic.clemente.io"))
 		Expect(headerFields).To(HaveKeyWithValue(":method", "CONNECT"))
@@ -2,17 +2,22 @@ package http3
 
 import (
 	"bufio"
-	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/textproto"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/nxenon/xquic-go"
 	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/qlog"
+	"github.com/nxenon/xquic-go/quicvarint"
 
 	"github.com/quic-go/qpack"
+	"golang.org/x/net/http/httpguts"
 )
 
 // The maximum length of an encoded HTTP/3 frame header is 16:
@@ -22,34 +27,58 @@ const frameHeaderLen = 16
 // headerWriter wraps the stream, so that the first Write call flushes the header to the stream
 type headerWriter struct {
 	str     quic.Stream
+	encoder *qpackEncoder
 	header  http.Header
 	status  int // status code passed to WriteHeader
 	written bool
+	// trailers are the trailers declared in the Trailer header when WriteHeader was called
+	trailers []string
 
+	tracer *qlog.HTTP3Tracer // may be nil
 	logger utils.Logger
 }
 
 // writeHeader encodes and flush header to the stream
 func (hw *headerWriter) writeHeader() error {
-	var headers bytes.Buffer
-	enc := qpack.NewEncoder(&headers)
-	enc.WriteField(qpack.HeaderField{Name: ":status", Value: strconv.Itoa(hw.status)})
+	hw.logger.Infof("Responding with %d", hw.status)
+	return hw.writeHeaderFields(responseHeaderFields(hw.status, hw.header, hw.trailers))
+}
 
-	for k, v := range hw.header {
-		for index := range v {
-			enc.WriteField(qpack.HeaderField{Name: strings.ToLower(k), Value: v[index]})
-		}
+// writeHeaderFields writes a HEADERS frame containing the header fields to the stream.
+func (hw *headerWriter) writeHeaderFields(hfs []qpack.HeaderField) error {
+	headers, err := hw.encoder.encode(hw.str.StreamID(), hfs)
+	if err != nil {
+		return err
 	}
 
-	buf := make([]byte, 0, frameHeaderLen+headers.Len())
-	buf = (&headersFrame{Length: uint64(headers.Len())}).Append(buf)
-	hw.logger.Infof("Responding with %d", hw.status)
-	buf = append(buf, headers.Bytes()...)
+	if hw.tracer != nil {
+		hw.tracer.FrameCreated(hw.str.StreamID(), uint64(len(headers)), &qlog.HTTP3HeadersFrame{HeaderFields: qlogHeaderFields(hfs)})
+	}
+	buf := make([]byte, 0, frameHeaderLen+len(headers))
+	buf = (&headersFrame{Length: uint64(len(headers))}).Append(buf)
+	buf = append(buf, headers...)
 
-	_, err := hw.str.Write(buf)
+	_, err = hw.str.Write(buf)
 	return err
 }
 
+// responseHeaderFields returns the header fields of a (final or informational) response.
+// Trailers, both declared ones and those set using the http.TrailerPrefix, are not part of the header section.
+// Since the header is only encoded when it is flushed, the handler might already have set their values.
+func responseHeaderFields(status int, header http.Header, trailers []string) []qpack.HeaderField {
+	hfs := make([]qpack.HeaderField, 0, 1+len(header))
+	hfs = append(hfs, qpack.HeaderField{Name: ":status", Value: strconv.Itoa(status)})
+	for k, v := range header {
+		if strings.HasPrefix(k, http.TrailerPrefix) || slices.Contains(trailers, k) {
+			continue
+		}
+		for index := range v {
+			hfs = append(hfs, qpack.HeaderField{Name: strings.ToLower(k), Value: v[index]})
+		}
+	}
+	return hfs
+}
+
 // first Write will trigger flushing header
 func (hw *headerWriter) Write(p []byte) (int, error) {
 	if !hw.written {
@@ -71,19 +100,28 @@ type responseWriter struct {
 	numWritten    int64 // bytes written
 	headerWritten bool
 	isHead        bool
+
+	// pusher pushes a response, nil if the client didn't enable server push
+	pusher func(target string, opts *http.PushOptions) error
+	// priority is the priority of the response, nil if the response can't be prioritized
+	priority *priorityState
 }
 
 var (
 	_ http.ResponseWriter = &responseWriter{}
 	_ http.Flusher        = &responseWriter{}
 	_ Hijacker            = &responseWriter{}
+	_ http.Pusher         = &responseWriter{}
+	_ Prioritizer         = &responseWriter{}
 )
 
-func newResponseWriter(str quic.Stream, conn quic.Connection, logger utils.Logger) *responseWriter {
+func newResponseWriter(str quic.Stream, conn quic.Connection, encoder *qpackEncoder, tracer *qlog.HTTP3Tracer, logger utils.Logger) *responseWriter {
 	hw := &headerWriter{
-		str:    str,
-		header: http.Header{},
-		logger: logger,
+		str:     str,
+		encoder: encoder,
+		header:  http.Header{},
+		tracer:  tracer,
+		logger:  logger,
 	}
 	return &responseWriter{
 		headerWriter: hw,
@@ -107,30 +145,70 @@ func (w *responseWriter) WriteHeader(status int) {
 		panic(fmt.Sprintf("invalid WriteHeader code %v", status))
 	}
 
-	if status >= 200 {
-		w.headerWritten = true
-		// Add Date header.
-		// This is what the standard library does.
-		// Can be disabled by setting the Date header to nil.
-		if _, ok := w.header["Date"]; !ok {
-			w.header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	// Informational responses are sent right away, the handler can send the final response later.
+	// This is used for 103 Early Hints, see RFC 8297.
+	if status < 200 {
+		w.status = status
+		if err := w.writeInformationalHeader(status); err != nil {
+			w.logger.Errorf("could not write informational response: %s", err.Error())
 		}
-		// Content-Length checking
-		// use ParseUint instead of ParseInt, as negative values are invalid
-		if clen := w.header.Get("Content-Length"); clen != "" {
-			if cl, err := strconv.ParseUint(clen, 10, 63); err == nil {
-				w.contentLen = int64(cl)
-			} else {
-				// emit a warning for malformed Content-Length and remove it
-				w.logger.Errorf("Malformed Content-Length %s", clen)
-				w.header.Del("Content-Length")
-			}
+		return
+	}
+
+	w.headerWritten = true
+	// Add Date header.
+	// This is what the standard library does.
+	// Can be disabled by setting the Date header to nil.
+	if _, ok := w.header["Date"]; !ok {
+		w.header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	// Content-Length checking
+	// use ParseUint instead of ParseInt, as negative values are invalid
+	if clen := w.header.Get("Content-Length"); clen != "" {
+		if cl, err := strconv.ParseUint(clen, 10, 63); err == nil {
+			w.contentLen = int64(cl)
+		} else {
+			// emit a warning for malformed Content-Length and remove it
+			w.logger.Errorf("Malformed Content-Length %s", clen)
+			w.header.Del("Content-Length")
 		}
 	}
+	w.declareTrailers()
 	w.status = status
+}
 
-	if !w.headerWritten {
-		w.writeHeader()
+// writeInformationalHeader writes a 1xx response.
+// The header map is not cleared, so the header fields are sent again with the final response.
+func (w *responseWriter) writeInformationalHeader(status int) error {
+	// HTTP/3 doesn't support the 101 status code, see section 4.5 of RFC 9114.
+	if status == http.StatusSwitchingProtocols {
+		return errors.New("HTTP/3 doesn't support 101 Switching Protocols")
+	}
+	header := w.header
+	_, hasCL := header["Content-Length"]
+	_, hasTE := header["Transfer-Encoding"]
+	if hasCL || hasTE {
+		header = header.Clone()
+		header.Del("Content-Length")
+		header.Del("Transfer-Encoding")
+	}
+	w.logger.Infof("Sending informational response %d", status)
+	return maybeReplaceError(w.writeHeaderFields(responseHeaderFields(status, header, nil)))
+}
+
+// declareTrailers records the trailers declared in the Trailer header.
+func (w *responseWriter) declareTrailers() {
+	for _, v := range w.header["Trailer"] {
+		for _, k := range strings.Split(v, ",") {
+			k = http.CanonicalHeaderKey(textproto.TrimString(k))
+			if !httpguts.ValidTrailerHeader(k) {
+				w.logger.Errorf("Ignoring invalid trailer %q", k)
+				continue
+			}
+			if !slices.Contains(w.trailers, k) {
+				w.trailers = append(w.trailers, k)
+			}
+		}
 	}
 }
 
@@ -173,6 +251,9 @@ func (w *responseWriter) Write(p []byte) (int, error) {
 	if _, err := w.bufferedStr.Write(w.buf); err != nil {
 		return 0, maybeReplaceError(err)
 	}
+	if w.tracer != nil {
+		w.tracer.FrameCreated(w.str.StreamID(), df.Length, &qlog.HTTP3DataFrame{})
+	}
 	n, err := w.bufferedStr.Write(p)
 	return n, maybeReplaceError(err)
 }
@@ -196,6 +277,96 @@ func (w *responseWriter) Flush() {
 	}
 }
 
+// finish sends the response after the handler returned.
+func (w *responseWriter) finish() {
+	// response not written to the client yet, set Content-Length
+	if !w.written {
+		if _, haveCL := w.header["Content-Length"]; !haveCL {
+			w.header.Set("Content-Length", strconv.FormatInt(w.numWritten, 10))
+		}
+	}
+	w.Flush()
+	if err := w.writeTrailers(); err != nil {
+		w.logger.Errorf("could not write trailers: %s", err.Error())
+	}
+}
+
+// writeTrailers writes the trailers in a HEADERS frame after the response body, see section 4.1 of RFC 9114.
+// Trailers are either declared in the Trailer header before the response header was written,
+// or set using the http.TrailerPrefix.
+func (w *responseWriter) writeTrailers() error {
+	var hfs []qpack.HeaderField
+	for _, k := range w.trailers {
+		for _, v := range w.header[k] {
+			hfs = append(hfs, qpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	for k, vals := range w.header {
+		if !strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(k, http.TrailerPrefix)
+		if !httpguts.ValidTrailerHeader(name) {
+			w.logger.Errorf("Ignoring invalid trailer %q", name)
+			continue
+		}
+		for _, v := range vals {
+			hfs = append(hfs, qpack.HeaderField{Name: strings.ToLower(name), Value: v})
+		}
+	}
+	if len(hfs) == 0 {
+		return nil
+	}
+	return maybeReplaceError(w.writeHeaderFields(hfs))
+}
+
+// Push initiates an HTTP/3 server push, see section 4.6 of RFC 9114.
+// It returns http.ErrNotSupported if the client didn't enable server push, or when called for a pushed response.
+// If the client doesn't allow any more pushes at this point, it returns ErrPushLimitReached.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if w.pusher == nil {
+		return http.ErrNotSupported
+	}
+	return w.pusher(target, opts)
+}
+
+// SetPriority sets the priority of the response.
+// Priority updates sent by the client are ignored from now on.
+func (w *responseWriter) SetPriority(p Priority) error {
+	if w.priority == nil {
+		return http.ErrNotSupported
+	}
+	if p.Urgency > maxUrgency {
+		return fmt.Errorf("http3: invalid urgency: %d", p.Urgency)
+	}
+	w.priority.update(p, true)
+	return nil
+}
+
+// writePushPromise writes a PUSH_PROMISE frame on the request stream.
+func (w *responseWriter) writePushPromise(pushID uint64, req *http.Request) error {
+	// If the HEADERS frame was already sent, flush the buffered DATA frames first,
+	// so that the PUSH_PROMISE frame doesn't end up in the middle of a DATA frame.
+	if w.written {
+		if err := w.bufferedStr.Flush(); err != nil {
+			return maybeReplaceError(err)
+		}
+	}
+	hfs := pushPromiseHeaderFields(req)
+	headers, err := w.encoder.encode(w.str.StreamID(), hfs)
+	if err != nil {
+		return err
+	}
+	if w.tracer != nil {
+		w.tracer.FrameCreated(w.str.StreamID(), uint64(quicvarint.Len(pushID))+uint64(len(headers)), &qlog.HTTP3PushPromiseFrame{PushID: pushID, HeaderFields: qlogHeaderFields(hfs)})
+	}
+	buf := make([]byte, 0, frameHeaderLen+8+len(headers))
+	buf = (&pushPromiseFrame{PushID: pushID, Length: uint64(len(headers))}).Append(buf)
+	buf = append(buf, headers...)
+	_, err = w.str.Write(buf)
+	return maybeReplaceError(err)
+}
+
 func (w *responseWriter) StreamCreator() StreamCreator {
 	return w.conn
 }
@@ -26,9 +26,10 @@ var _ = Describe("Response Writer", func() {
 		strBuf = &bytes.Buffer{}
 		str := mockquic.NewMockStream(mockCtrl)
 		str.EXPECT().Write(gomock.Any()).DoAndReturn(strBuf.Write).AnyTimes()
+		str.EXPECT().StreamID().AnyTimes()
 		str.EXPECT().SetReadDeadline(gomock.Any()).Return(nil).AnyTimes()
 		str.EXPECT().SetWriteDeadline(gomock.Any()).Return(nil).AnyTimes()
-		rw = newResponseWriter(str, nil, utils.DefaultLogger)
+		rw = newResponseWriter(str, nil, newQPACKEncoder(nil, 0, nil), nil, utils.DefaultLogger)
 	})
 
 	decodeHeader := func(str io.Reader) map[string][]string {
@@ -147,6 +148,85 @@ var _ = Describe("Response Writer", func() {
 		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
 	})
 
+	It("doesn't send the Content-Length in informational responses", func() {
+		rw.Header().Set("Content-Length", "6")
+		rw.WriteHeader(http.StatusEarlyHints)
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue(":status", []string{"103"}))
+		Expect(fields).ToNot(HaveKey("content-length"))
+		Expect(rw.Header().Get("Content-Length")).To(Equal("6"))
+	})
+
+	It("doesn't send 101 responses", func() {
+		rw.WriteHeader(http.StatusSwitchingProtocols)
+		Expect(strBuf.Len()).To(BeZero())
+		rw.WriteHeader(http.StatusOK)
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+	})
+
+	It("writes trailers declared in the Trailer header", func() {
+		rw.Header().Set("Trailer", "Foo, bar")
+		_, err := rw.Write([]byte("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		rw.Header().Set("Foo", "1")
+		rw.Header().Add("Bar", "2")
+		rw.Header().Add("Bar", "3")
+		rw.Header().Set("Baz", "not declared")
+		rw.finish()
+
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue("trailer", []string{"Foo, bar"}))
+		Expect(fields).ToNot(HaveKey("foo"))
+		Expect(fields).ToNot(HaveKey("bar"))
+		Expect(fields).To(HaveKeyWithValue("baz", []string{"not declared"}))
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		trailers := decodeHeader(strBuf)
+		Expect(trailers).To(Equal(map[string][]string{
+			"foo": {"1"},
+			"bar": {"2", "3"},
+		}))
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
+	It("writes trailers set using the TrailerPrefix", func() {
+		rw.Header().Set(http.TrailerPrefix+"Foo", "bar")
+		_, err := rw.Write([]byte("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		rw.Header().Set(http.TrailerPrefix+"Baz", "qux")
+		rw.finish()
+
+		fields := decodeHeader(strBuf)
+		Expect(fields).ToNot(HaveKey("trailer:foo"))
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		trailers := decodeHeader(strBuf)
+		Expect(trailers).To(Equal(map[string][]string{
+			"foo": {"bar"},
+			"baz": {"qux"},
+		}))
+	})
+
+	It("doesn't write invalid trailers", func() {
+		rw.Header().Set("Trailer", "Content-Length, Foo")
+		rw.WriteHeader(http.StatusOK)
+		rw.Header().Set("Foo", "bar")
+		rw.finish()
+
+		decodeHeader(strBuf)
+		Expect(decodeHeader(strBuf)).To(Equal(map[string][]string{"foo": {"bar"}}))
+	})
+
+	It("doesn't write a trailer section if there are no trailers", func() {
+		rw.Header().Set("Trailer", "Foo")
+		_, err := rw.Write([]byte("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		rw.finish()
+
+		decodeHeader(strBuf)
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
 	It("doesn't allow writes if the status code doesn't allow a body", func() {
 		rw.WriteHeader(304)
 		n, err := rw.Write([]byte("foobar"))
@@ -8,6 +8,8 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/netip"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -55,6 +57,16 @@ type RoundTripper struct {
 	// See https://datatracker.ietf.org/doc/html/rfc9297.
 	EnableDatagrams bool
 
+	// Enable support for WebTransport sessions.
+	// It implies EnableDatagrams, and allows the server to open bidirectional streams.
+	// Sessions are established using DialWebTransport.
+	// See https://datatracker.ietf.org/doc/html/draft-ietf-webtrans-http3.
+	EnableWebTransport bool
+
+	// WebTransportConfig configures the limits of WebTransport sessions.
+	// If nil, default values are used.
+	WebTransportConfig *WebTransportConfig
+
 	// Additional HTTP/3 settings.
 	// It is invalid to specify any settings defined by the HTTP/3 draft and the datagram draft.
 	AdditionalSettings map[uint64]uint64
@@ -73,10 +85,25 @@ type RoundTripper struct {
 	// In that case, the stream type will not be set.
 	UniStreamHijacker func(StreamType, quic.Connection, quic.ReceiveStream, error) (hijacked bool)
 
+	// PushHandler enables HTTP/3 server push.
+	// It is called for every response promised by the server.
+	// If it returns false, the push is cancelled. Otherwise, the callback takes ownership of the push:
+	// It must either read the response using PushPromise.Response and close its body, or call PushPromise.Cancel.
+	// Pushes that are neither read nor cancelled count against MaxConcurrentPushes.
+	// If nil, server push is disabled.
+	PushHandler func(*PushPromise) bool
+
+	// MaxConcurrentPushes is the maximum number of pushes that the server can have in flight at the same time.
+	// The server is only allowed to use more push IDs once pushes are completed.
+	// Zero means to use a default limit.
+	MaxConcurrentPushes int
+
 	// Dial specifies an optional dial function for creating QUIC
 	// connections for requests.
 	// If Dial is nil, a UDPConn will be created at the first request
 	// and will be reused for subsequent connections to other servers.
+	// The context passed to Dial carries the httptrace.ClientTrace of the request (if any).
+	// Unless Dial is nil, it is responsible for calling the DNS, Connect and TLS handshake hooks of the trace.
 	Dial func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error)
 
 	// MaxResponseHeaderBytes specifies a limit on how many response bytes are
@@ -84,6 +111,10 @@ type RoundTripper struct {
 	// Zero means to use a default limit.
 	MaxResponseHeaderBytes int64
 
+	// QPACKConfig configures the QPACK dynamic table used for compressing header fields.
+	// If nil, default values are used.
+	QPACKConfig *QPACKConfig
+
 	newClient func(hostname string, tlsConf *tls.Config, opts *roundTripperOpts, conf *quic.Config, dialer dialFunc) (roundTripCloser, error) // so we can mock it in tests
 	clients   map[string]*roundTripCloserWithCount
 	transport *quic.Transport
@@ -107,6 +138,9 @@ var (
 // ErrNoCachedConn is returned when RoundTripper.OnlyCachedConn is set
 var ErrNoCachedConn = errors.New("http3: no cached connection was available")
 
+// maxRequestRetries is the number of times a request that the server didn't process is retried.
+const maxRequestRetries = 3
+
 // RoundTripOpt is like RoundTrip, but takes options.
 func (r *RoundTripper) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Response, error) {
 	if req.URL == nil {
@@ -142,21 +176,75 @@ func (r *RoundTripper) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.
 	}
 
 	hostname := authorityAddr("https", hostnameFromRequest(req))
-	cl, isReused, err := r.getClient(hostname, opt.OnlyCachedConn)
-	if err != nil {
-		return nil, err
-	}
-	defer cl.useCount.Add(-1)
-	rsp, err := cl.RoundTripOpt(req, opt)
-	if err != nil {
+	for retry := 0; ; retry++ {
+		cl, isReused, err := r.getClient(hostname, opt.OnlyCachedConn)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err := cl.RoundTripOpt(req, opt)
+		cl.useCount.Add(-1)
+		// The connection is still usable if the server just didn't enable Extended CONNECT or WebTransport.
+		if err == nil || err == ErrExtendedConnectNotSupported || err == ErrWebTransportNotSupported {
+			return rsp, err
+		}
+		// Requests that the server didn't process can be retried on a new connection.
+		if nerr, ok := err.(*requestNotProcessedError); ok {
+			r.removeClientIfCurrent(hostname, cl)
+			if retry >= maxRequestRetries {
+				return nil, err
+			}
+			newReq, rerr := rewindRequest(req, nerr.sent)
+			if rerr != nil {
+				return nil, err
+			}
+			req = newReq
+			continue
+		}
 		r.removeClient(hostname)
 		if isReused {
 			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
-				return r.RoundTripOpt(req, opt)
+				continue
 			}
 		}
+		return rsp, err
+	}
+}
+
+// rewindRequest prepares a request that the server didn't process for being sent again.
+// If the request was already (partially) sent, it is only retried if it is idempotent,
+// and if its body can be obtained again.
+func rewindRequest(req *http.Request, sent bool) (*http.Request, error) {
+	if !sent {
+		return req, nil
+	}
+	if !isReplayable(req) {
+		return nil, fmt.Errorf("http3: cannot retry %s request", req.Method)
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	newReq := *req
+	newReq.Body = body
+	return &newReq, nil
+}
+
+// isReplayable says if a request can be sent again.
+// It follows the logic of net/http: only idempotent requests with a body that can be recreated are replayable.
+func isReplayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
 	}
-	return rsp, err
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, MethodGet0RTT:
+		return true
+	}
+	_, hasKey := req.Header["Idempotency-Key"]
+	_, hasXKey := req.Header["X-Idempotency-Key"]
+	return hasKey || hasXKey
 }
 
 // RoundTrip does a round trip.
@@ -164,6 +252,47 @@ func (r *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return r.RoundTripOpt(req, RoundTripOpt{})
 }
 
+// ExtendedConnect opens an Extended CONNECT stream (RFC 9220) to the server,
+// e.g. using the "websocket" protocol to run WebSockets over HTTP/3.
+// The request is sent using the CONNECT method, with the :protocol pseudo header set to protocol.
+// It blocks until the server's SETTINGS frame was received,
+// and returns ErrExtendedConnectNotSupported if the server didn't enable Extended CONNECT.
+// If the server doesn't respond with a 2xx status code, the response is returned with its body closed,
+// together with an error.
+func (r *RoundTripper) ExtendedConnect(req *http.Request, protocol string) (*http.Response, *ExtendedConnectStream, error) {
+	if protocol == "" {
+		return nil, nil, errors.New("http3: Extended CONNECT requires a protocol")
+	}
+	req = req.Clone(req.Context())
+	req.Method = http.MethodConnect
+	req.Proto = protocol
+	rsp, err := r.RoundTripOpt(req, RoundTripOpt{DontCloseRequestStream: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	streamer, ok := rsp.Body.(HTTPStreamer)
+	if !ok {
+		rsp.Body.Close()
+		return nil, nil, errors.New("http3: response body doesn't allow taking over the stream")
+	}
+	str := streamer.HTTPStream()
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		str.CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled))
+		rsp.Body.Close()
+		return rsp, nil, fmt.Errorf("http3: Extended CONNECT request failed: %s", rsp.Status)
+	}
+	var localAddr, remoteAddr net.Addr
+	if h, ok := rsp.Body.(Hijacker); ok {
+		localAddr = h.StreamCreator().LocalAddr()
+		remoteAddr = h.StreamCreator().RemoteAddr()
+	}
+	var router *datagramRouter
+	if body, ok := rsp.Body.(*hijackableBody); ok {
+		router = body.datagrams
+	}
+	return rsp, newExtendedConnectStream(str, localAddr, remoteAddr, router), nil
+}
+
 func (r *RoundTripper) getClient(hostname string, onlyCached bool) (rtc *roundTripCloserWithCount, isReused bool, err error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -197,12 +326,19 @@ func (r *RoundTripper) getClient(hostname string, onlyCached bool) (rtc *roundTr
 			hostname,
 			r.TLSClientConfig,
 			&roundTripperOpts{
-				EnableDatagram:     r.EnableDatagrams,
-				DisableCompression: r.DisableCompression,
-				MaxHeaderBytes:     r.MaxResponseHeaderBytes,
-				StreamHijacker:     r.StreamHijacker,
-				UniStreamHijacker:  r.UniStreamHijacker,
-				AdditionalSettings: r.AdditionalSettings,
+				EnableDatagram:      r.EnableDatagrams,
+				DisableCompression:  r.DisableCompression,
+				MaxHeaderBytes:      r.MaxResponseHeaderBytes,
+				StreamHijacker:      r.StreamHijacker,
+				UniStreamHijacker:   r.UniStreamHijacker,
+				AdditionalSettings:  r.AdditionalSettings,
+				EnableWebTransport:  r.EnableWebTransport,
+				WebTransportConfig:  r.WebTransportConfig,
+				PushHandler:         r.PushHandler,
+				MaxConcurrentPushes: r.MaxConcurrentPushes,
+				QPACKConfig:         r.QPACKConfig,
+				// Stop using this connection once the server starts shutting it down.
+				onGoAway: func() { r.removeClientIfCurrent(hostname, client) },
 			},
 			r.QuicConfig,
 			dial,
@@ -219,6 +355,15 @@ func (r *RoundTripper) getClient(hostname string, onlyCached bool) (rtc *roundTr
 	return client, isReused, nil
 }
 
+// removeClientIfCurrent removes the client, unless it was already replaced by a new client.
+func (r *RoundTripper) removeClientIfCurrent(hostname string, client *roundTripCloserWithCount) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.clients[hostname] == client {
+		delete(r.clients, hostname)
+	}
+}
+
 func (r *RoundTripper) removeClient(hostname string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -282,12 +427,45 @@ func isNotToken(r rune) bool {
 // makeDialer makes a QUIC dialer using r.udpConn.
 func (r *RoundTripper) makeDialer() func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
 	return func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
-		udpAddr, err := net.ResolveUDPAddr("udp", addr)
+		udpAddr, err := resolveUDPAddr(ctx, addr)
 		if err != nil {
 			return nil, err
 		}
-		return r.transport.DialEarly(ctx, udpAddr, tlsCfg, cfg)
+		trace := httptrace.ContextClientTrace(ctx)
+		traceConnectStart(trace, "udp", udpAddr.String())
+		cfg, handshakeDone := traceHandshake(trace, cfg)
+		conn, err := r.transport.DialEarly(ctx, udpAddr, tlsCfg, cfg)
+		traceConnectDone(trace, "udp", udpAddr.String(), err)
+		handshakeDone(conn, err)
+		return conn, err
+	}
+}
+
+// resolveUDPAddr resolves a UDP address.
+// Unlike net.ResolveUDPAddr, it uses the context for the DNS lookup,
+// so that the DNSStart and DNSDone hooks of a httptrace.ClientTrace are called.
+// Like net.ResolveUDPAddr, it prefers IPv4 addresses.
+func resolveUDPAddr(ctx context.Context, addr string) (*net.UDPAddr, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := net.DefaultResolver.LookupPort(ctx, "udp", portStr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	ip := ips[0]
+	for _, a := range ips {
+		if a.Unmap().Is4() {
+			ip = a
+			break
+		}
 	}
+	return net.UDPAddrFromAddrPort(netip.AddrPortFrom(ip.Unmap(), uint16(port))), nil
 }
 
 func (r *RoundTripper) CloseIdleConnections() {
@@ -5,13 +5,19 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"sync/atomic"
 	"time"
 
 	"github.com/nxenon/xquic-go"
+	mockquic "github.com/nxenon/xquic-go/internal/mocks/quic"
 	"github.com/nxenon/xquic-go/internal/qerr"
+	"github.com/nxenon/xquic-go/internal/testdata"
+	"github.com/nxenon/xquic-go/internal/utils"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -112,6 +118,95 @@ var _ = Describe("RoundTripper", func() {
 		})
 	})
 
+	Context("tracing", func() {
+		It("calls the DNS hooks when resolving the address", func() {
+			var dnsStart []string
+			var dnsDone []error
+			ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				DNSStart: func(info httptrace.DNSStartInfo) { dnsStart = append(dnsStart, info.Host) },
+				DNSDone:  func(info httptrace.DNSDoneInfo) { dnsDone = append(dnsDone, info.Err) },
+			})
+			addr, err := resolveUDPAddr(ctx, "localhost:1337")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(addr.IP.IsLoopback()).To(BeTrue())
+			Expect(addr.Port).To(Equal(1337))
+			Expect(dnsStart).To(Equal([]string{"localhost"}))
+			Expect(dnsDone).To(Equal([]error{nil}))
+		})
+
+		It("resolves IP addresses without a DNS lookup", func() {
+			var dnsStarted bool
+			ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				DNSStart: func(httptrace.DNSStartInfo) { dnsStarted = true },
+			})
+			addr, err := resolveUDPAddr(ctx, "[::1]:443")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(addr.String()).To(Equal("[::1]:443"))
+			Expect(dnsStarted).To(BeFalse())
+		})
+
+		It("calls the Connect hooks when dialing", func() {
+			udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			rt.transport = &quic.Transport{Conn: udpConn}
+			defer rt.transport.Close()
+
+			type connectEvent struct {
+				network, addr string
+				err           error
+			}
+			var started, done []connectEvent
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+				ConnectStart: func(network, addr string) { started = append(started, connectEvent{network: network, addr: addr}) },
+				ConnectDone: func(network, addr string, err error) {
+					done = append(done, connectEvent{network: network, addr: addr, err: err})
+				},
+			})
+			// nothing is listening on this address, so dialing times out
+			_, err = rt.makeDialer()(ctx, "127.0.0.1:1", &tls.Config{NextProtos: []string{NextProtoH3}}, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(started).To(Equal([]connectEvent{{network: "udp", addr: "127.0.0.1:1"}}))
+			Expect(done).To(HaveLen(1))
+			Expect(done[0].addr).To(Equal("127.0.0.1:1"))
+			Expect(done[0].err).To(MatchError(err))
+		})
+
+		It("calls the TLS handshake hooks when dialing", func() {
+			ln, err := quic.ListenAddrEarly("localhost:0", ConfigureTLSConfig(testdata.GetTLSConfig()), nil)
+			Expect(err).ToNot(HaveOccurred())
+			defer ln.Close()
+			udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			rt.transport = &quic.Transport{Conn: udpConn}
+			defer rt.transport.Close()
+
+			events := make(chan string, 10)
+			trace := &httptrace.ClientTrace{
+				TLSHandshakeStart: func() { events <- "TLSHandshakeStart" },
+				TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+					events <- fmt.Sprintf("TLSHandshakeDone %t %v", state.HandshakeComplete, err)
+				},
+			}
+			tlsConf := &tls.Config{RootCAs: testdata.GetRootCA(), ServerName: "localhost", NextProtos: []string{NextProtoH3}}
+			conn, err := rt.makeDialer()(httptrace.WithClientTrace(context.Background(), trace), ln.Addr().String(), tlsConf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.CloseWithError(0, "")
+			Expect(events).To(Receive(Equal("TLSHandshakeStart")))
+			Expect(events).To(Receive(Equal("TLSHandshakeDone true <nil>")))
+
+			// nothing is listening on this address, so dialing times out
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err = rt.makeDialer()(httptrace.WithClientTrace(ctx, trace), "127.0.0.1:1", tlsConf, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(events).To(Receive(Equal("TLSHandshakeStart")))
+			Expect(events).To(Receive(Equal(fmt.Sprintf("TLSHandshakeDone false %v", err))))
+			Expect(events).ToNot(Receive())
+		})
+	})
+
 	Context("reusing clients", func() {
 		var req1, req2 *http.Request
 
@@ -246,6 +341,113 @@ var _ = Describe("RoundTripper", func() {
 			Expect(count).To(Equal(1))
 		})
 
+		It("stops using a client after it received a GOAWAY frame", func() {
+			var clientOpts []*roundTripperOpts
+			rt.newClient = func(_ string, _ *tls.Config, opts *roundTripperOpts, _ *quic.Config, _ dialFunc) (roundTripCloser, error) {
+				clientOpts = append(clientOpts, opts)
+				cl := NewMockRoundTripCloser(mockCtrl)
+				cl.EXPECT().RoundTripOpt(gomock.Any(), gomock.Any()).DoAndReturn(func(req *http.Request, _ RoundTripOpt) (*http.Response, error) {
+					return &http.Response{Request: req}, nil
+				}).AnyTimes()
+				cl.EXPECT().HandshakeComplete().Return(true).AnyTimes()
+				return cl, nil
+			}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(clientOpts).To(HaveLen(1))
+			clientOpts[0].onGoAway()
+			_, err = rt.RoundTrip(req2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(clientOpts).To(HaveLen(2))
+			// a late GOAWAY on the old connection doesn't affect the new connection
+			clientOpts[0].onGoAway()
+			_, err = rt.RoundTrip(req2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(clientOpts).To(HaveLen(2))
+		})
+
+		Context("retrying requests that the server didn't process", func() {
+			newClients := func(errs ...error) *int {
+				var count int
+				rt.newClient = func(string, *tls.Config, *roundTripperOpts, *quic.Config, dialFunc) (roundTripCloser, error) {
+					cl := NewMockRoundTripCloser(mockCtrl)
+					err := errs[count]
+					count++
+					cl.EXPECT().RoundTripOpt(gomock.Any(), gomock.Any()).DoAndReturn(func(req *http.Request, _ RoundTripOpt) (*http.Response, error) {
+						if err != nil {
+							return nil, err
+						}
+						return &http.Response{Request: req}, nil
+					})
+					return cl, nil
+				}
+				return &count
+			}
+
+			It("retries requests that weren't sent", func() {
+				req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io/upload", bytes.NewReader([]byte("foobar")))
+				Expect(err).ToNot(HaveOccurred())
+				req.GetBody = nil
+				count := newClients(&requestNotProcessedError{err: errGoAway}, nil)
+				rsp, err := rt.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.Request).To(Equal(req))
+				Expect(*count).To(Equal(2))
+			})
+
+			It("retries idempotent requests that were sent", func() {
+				count := newClients(&requestNotProcessedError{err: &Error{ErrorCode: ErrCodeRequestRejected, Remote: true}, sent: true}, nil)
+				_, err := rt.RoundTrip(req1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(*count).To(Equal(2))
+			})
+
+			It("doesn't retry non-idempotent requests that were sent", func() {
+				req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io/upload", bytes.NewReader([]byte("foobar")))
+				Expect(err).ToNot(HaveOccurred())
+				testErr := &Error{ErrorCode: ErrCodeRequestRejected, Remote: true}
+				count := newClients(&requestNotProcessedError{err: testErr, sent: true}, nil)
+				_, err = rt.RoundTrip(req)
+				Expect(err).To(MatchError(testErr))
+				Expect(*count).To(Equal(1))
+			})
+
+			It("rewinds the body of requests with an idempotency key", func() {
+				req, err := http.NewRequest(http.MethodPost, "https://quic.clemente.io/upload", bytes.NewReader([]byte("foobar")))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Idempotency-Key", "42")
+				io.ReadAll(req.Body) // the body was consumed when sending the request the first time
+				count := newClients(&requestNotProcessedError{err: quic.Err0RTTRejected, sent: true}, nil)
+				rsp, err := rt.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(*count).To(Equal(2))
+				body, err := io.ReadAll(rsp.Request.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(Equal([]byte("foobar")))
+			})
+
+			It("doesn't retry requests with a body that can't be rewound", func() {
+				req, err := http.NewRequest(http.MethodPut, "https://quic.clemente.io/upload", &mockBody{})
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Idempotency-Key", "42")
+				count := newClients(&requestNotProcessedError{err: quic.Err0RTTRejected, sent: true}, nil)
+				_, err = rt.RoundTrip(req)
+				Expect(err).To(MatchError(quic.Err0RTTRejected))
+				Expect(*count).To(Equal(1))
+			})
+
+			It("gives up after a few retries", func() {
+				errs := make([]error, maxRequestRetries+1)
+				for i := range errs {
+					errs[i] = &requestNotProcessedError{err: errGoAway}
+				}
+				count := newClients(errs...)
+				_, err := rt.RoundTrip(req1)
+				Expect(err).To(MatchError(errGoAway))
+				Expect(*count).To(Equal(maxRequestRetries + 1))
+			})
+		})
+
 		It("doesn't create new clients if RoundTripOpt.OnlyCachedConn is set", func() {
 			req, err := http.NewRequest("GET", "https://quic.clemente.io/foobar.html", nil)
 			Expect(err).ToNot(HaveOccurred())
@@ -316,6 +518,119 @@ var _ = Describe("RoundTripper", func() {
 		})
 	})
 
+	Context("Extended CONNECT", func() {
+		var (
+			str  *mockquic.MockStream
+			conn *mockquic.MockEarlyConnection
+		)
+
+		BeforeEach(func() {
+			str = mockquic.NewMockStream(mockCtrl)
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+		})
+
+		It("opens an Extended CONNECT stream", func() {
+			remoteAddr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 443}
+			localAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
+			conn.EXPECT().LocalAddr().Return(localAddr)
+			conn.EXPECT().RemoteAddr().Return(remoteAddr)
+			rt.newClient = func(string, *tls.Config, *roundTripperOpts, *quic.Config, dialFunc) (roundTripCloser, error) {
+				cl := NewMockRoundTripCloser(mockCtrl)
+				cl.EXPECT().RoundTripOpt(gomock.Any(), gomock.Any()).DoAndReturn(func(r *http.Request, opt RoundTripOpt) (*http.Response, error) {
+					Expect(r.Method).To(Equal(http.MethodConnect))
+					Expect(r.Proto).To(Equal("websocket"))
+					Expect(r.URL).To(Equal(req.URL))
+					Expect(opt.DontCloseRequestStream).To(BeTrue())
+					return &http.Response{StatusCode: http.StatusOK, Body: newResponseBody(str, conn, nil)}, nil
+				})
+				return cl, nil
+			}
+			rsp, cstr, err := rt.ExtendedConnect(req, "websocket")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+			Expect(cstr.LocalAddr()).To(Equal(localAddr))
+			Expect(cstr.RemoteAddr()).To(Equal(remoteAddr))
+			// the original request is not modified
+			Expect(req.Method).To(Equal(http.MethodGet))
+
+			str.EXPECT().Write([]byte("foobar")).Return(6, nil)
+			n, err := cstr.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(6))
+			str.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeNoError))
+			str.EXPECT().Close()
+			Expect(cstr.Close()).To(Succeed())
+		})
+
+		It("closes the stream if the server doesn't accept the request", func() {
+			rt.newClient = func(string, *tls.Config, *roundTripperOpts, *quic.Config, dialFunc) (roundTripCloser, error) {
+				cl := NewMockRoundTripCloser(mockCtrl)
+				cl.EXPECT().RoundTripOpt(gomock.Any(), gomock.Any()).Return(&http.Response{
+					StatusCode: http.StatusNotFound,
+					Status:     "404 Not Found",
+					Body:       newResponseBody(str, conn, nil),
+				}, nil)
+				return cl, nil
+			}
+			str.EXPECT().CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled))
+			str.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled))
+			rsp, cstr, err := rt.ExtendedConnect(req, "websocket")
+			Expect(err).To(MatchError("http3: Extended CONNECT request failed: 404 Not Found"))
+			Expect(rsp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(cstr).To(BeNil())
+		})
+
+		It("keeps the client if the server doesn't support Extended CONNECT", func() {
+			var count int
+			rt.newClient = func(string, *tls.Config, *roundTripperOpts, *quic.Config, dialFunc) (roundTripCloser, error) {
+				count++
+				cl := NewMockRoundTripCloser(mockCtrl)
+				cl.EXPECT().RoundTripOpt(gomock.Any(), gomock.Any()).Return(nil, ErrExtendedConnectNotSupported)
+				cl.EXPECT().RoundTripOpt(gomock.Any(), gomock.Any()).Return(&http.Response{}, nil)
+				cl.EXPECT().HandshakeComplete().Return(true)
+				return cl, nil
+			}
+			_, _, err := rt.ExtendedConnect(req, "websocket")
+			Expect(err).To(MatchError(ErrExtendedConnectNotSupported))
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(1))
+		})
+
+		It("dials WebTransport sessions", func() {
+			rt.EnableWebTransport = true
+			conn.EXPECT().LocalAddr().AnyTimes()
+			conn.EXPECT().RemoteAddr().AnyTimes()
+			wt := newWebTransportManager(conn, nil, utils.DefaultLogger)
+			rt.newClient = func(string, *tls.Config, *roundTripperOpts, *quic.Config, dialFunc) (roundTripCloser, error) {
+				cl := NewMockRoundTripCloser(mockCtrl)
+				cl.EXPECT().RoundTripOpt(gomock.Any(), gomock.Any()).DoAndReturn(func(r *http.Request, opt RoundTripOpt) (*http.Response, error) {
+					Expect(r.Method).To(Equal(http.MethodConnect))
+					Expect(r.Proto).To(Equal("webtransport"))
+					Expect(r.Header.Get("Origin")).To(Equal("https://example.com"))
+					body := newResponseBody(str, conn, nil)
+					body.webTransport = wt
+					return &http.Response{StatusCode: http.StatusOK, Body: body}, nil
+				})
+				return cl, nil
+			}
+			str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+			pr, pw := io.Pipe()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(pr.Read).AnyTimes()
+			rsp, sess, err := rt.DialWebTransport(context.Background(), "https://quic.clemente.io/wt", http.Header{"Origin": {"https://example.com"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.StatusCode).To(Equal(http.StatusOK))
+			Expect(wt.sessions).To(HaveKeyWithValue(quic.StreamID(4), sess))
+			pw.Close() // the server closes the CONNECT stream
+			Eventually(sess.Context().Done()).Should(BeClosed())
+		})
+
+		It("requires a protocol", func() {
+			_, _, err := rt.ExtendedConnect(req, "")
+			Expect(err).To(MatchError("http3: Extended CONNECT requires a protocol"))
+		})
+	})
+
 	Context("closing", func() {
 		It("closes", func() {
 			rt.clients = make(map[string]*roundTripCloserWithCount)
@@ -9,14 +9,15 @@ import (
 	"net"
 	"net/http"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nxenon/xquic-go"
 	"github.com/nxenon/xquic-go/internal/protocol"
 	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/qlog"
 	"github.com/nxenon/xquic-go/quicvarint"
 
 	"github.com/quic-go/qpack"
@@ -126,6 +127,12 @@ var ServerContextKey = &contextKey{"http3-server"}
 // than its string representation.
 var RemoteAddrContextKey = &contextKey{"remote-addr"}
 
+// datagramRouterContextKey is used to pass the HTTP datagram state of the connection to AcceptExtendedConnect.
+var datagramRouterContextKey = &contextKey{"datagram-router"}
+
+// webTransportContextKey is used to pass the WebTransport state of the connection to Server.UpgradeWebTransport.
+var webTransportContextKey = &contextKey{"webtransport"}
+
 type requestError struct {
 	err       error
 	streamErr ErrCode
@@ -187,14 +194,37 @@ type Server struct {
 	// See https://datatracker.ietf.org/doc/html/rfc9297.
 	EnableDatagrams bool
 
+	// EnableExtendedConnect enables support for the Extended CONNECT method,
+	// as used by WebSockets over HTTP/3.
+	// If set to true, SETTINGS_ENABLE_CONNECT_PROTOCOL is sent to the client, and Extended CONNECT requests
+	// are passed to the Handler, which can take over the stream using AcceptExtendedConnect.
+	// Otherwise, requests carrying a :protocol pseudo header are rejected.
+	// See https://datatracker.ietf.org/doc/html/rfc9220.
+	EnableExtendedConnect bool
+
+	// EnableWebTransport enables support for WebTransport sessions.
+	// It implies EnableDatagrams and EnableExtendedConnect.
+	// Sessions are established from inside the Handler using UpgradeWebTransport.
+	// See https://datatracker.ietf.org/doc/html/draft-ietf-webtrans-http3.
+	EnableWebTransport bool
+
+	// WebTransportConfig configures the limits of WebTransport sessions.
+	// If nil, default values are used.
+	WebTransportConfig *WebTransportConfig
+
 	// MaxHeaderBytes controls the maximum number of bytes the server will
 	// read parsing the request HEADERS frame. It does not limit the size of
 	// the request body. If zero or negative, http.DefaultMaxHeaderBytes is
 	// used.
 	MaxHeaderBytes int
 
+	// QPACKConfig configures the QPACK dynamic table used for compressing header fields.
+	// If nil, default values are used.
+	QPACKConfig *QPACKConfig
+
 	// AdditionalSettings specifies additional HTTP/3 settings.
 	// It is invalid to specify any settings defined by the HTTP/3 draft and the datagram draft.
+	// Setting SETTINGS_ENABLE_CONNECT_PROTOCOL (0x8) to 1 is equivalent to setting EnableExtendedConnect.
 	AdditionalSettings map[uint64]uint64
 
 	// StreamHijacker, when set, is called for the first unknown frame parsed on a bidirectional stream.
@@ -221,6 +251,15 @@ type Server struct {
 
 	closed bool
 
+	initOnce    sync.Once
+	graceCtx    context.Context // canceled when CloseGracefully is called
+	graceCancel context.CancelFunc
+	closeCtx    context.Context // canceled when Close is called
+	closeCancel context.CancelFunc
+
+	connCount int           // number of connections currently handled, protected by the mutex
+	connsDone chan struct{} // closed when the last connection was handled after CloseGracefully was called
+
 	altSvcHeader string
 
 	logger utils.Logger
@@ -316,7 +355,7 @@ func (s *Server) serveConn(tlsConf *tls.Config, conn net.PacketConn) error {
 	} else {
 		quicConf = s.QuicConfig.Clone()
 	}
-	if s.EnableDatagrams {
+	if s.enableDatagrams() {
 		quicConf.EnableDatagrams = true
 	}
 
@@ -441,34 +480,87 @@ func (s *Server) removeListener(l *QUICEarlyListener) {
 	s.generateAltSvcHeader()
 }
 
+func (s *Server) init() {
+	s.initOnce.Do(func() {
+		s.graceCtx, s.graceCancel = context.WithCancel(context.Background())
+		s.closeCtx, s.closeCancel = context.WithCancel(context.Background())
+	})
+}
+
 func (s *Server) handleConn(conn quic.Connection) error {
-	decoder := qpack.NewDecoder(nil)
+	s.init()
+	s.mutex.Lock()
+	s.connCount++
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.connCount--
+		if s.connCount == 0 && s.connsDone != nil {
+			close(s.connsDone)
+			s.connsDone = nil
+		}
+		s.mutex.Unlock()
+	}()
+
+	// HTTP/3 events are written into the qlog of the QUIC connection (if any)
+	tracer := qlog.HTTP3TracerFromContext(conn.Context())
+	decoder := newQPACKDecoder(conn, s.QPACKConfig.maxTableCapacity(), s.QPACKConfig.maxBlockedStreams(), tracer)
+	encoder := newQPACKEncoder(conn, s.QPACKConfig.maxEncoderTableCapacity(), tracer)
 
 	// send a SETTINGS frame
-	str, err := conn.OpenUniStream()
+	ctrlStr, err := conn.OpenUniStream()
 	if err != nil {
 		return fmt.Errorf("opening the control stream failed: %w", err)
 	}
 	b := make([]byte, 0, 64)
 	b = quicvarint.Append(b, streamTypeControlStream) // stream type
-	b = (&settingsFrame{Datagram: s.EnableDatagrams, Other: s.AdditionalSettings}).Append(b)
-	str.Write(b)
+	sf := &settingsFrame{
+		Datagram:              s.enableDatagrams(),
+		ExtendedConnect:       s.enableExtendedConnect(),
+		WebTransport:          s.EnableWebTransport,
+		QPACKMaxTableCapacity: decoder.maxTableCapacity,
+		QPACKBlockedStreams:   decoder.maxBlockedStreams,
+		Other:                 s.additionalSettings(),
+	}
+	b = sf.Append(b)
+	ctrlStr.Write(b)
+	if tracer != nil {
+		traceControlStream(tracer, ctrlStr.StreamID(), sf)
+	}
 
-	go s.handleUnidirectionalStreams(conn)
+	var dg *datagramRouter
+	if s.enableDatagrams() {
+		dg = newDatagramRouter(conn)
+		go dg.run()
+	}
+	var wt *webTransportManager
+	if s.EnableWebTransport {
+		wt = newWebTransportManager(conn, s.WebTransportConfig, s.logger)
+	}
+
+	pushes := newServerPushes()
+	priorities := newRequestPriorities(tracer)
+	go s.handleUnidirectionalStreams(conn, tracer, decoder, encoder, wt, pushes, priorities)
 
 	// Process all requests immediately.
 	// It's the client's responsibility to decide which requests are eligible for 0-RTT.
+	var nextStreamID quic.StreamID
 	for {
-		str, err := conn.AcceptStream(context.Background())
+		str, err := conn.AcceptStream(s.graceCtx)
 		if err != nil {
+			if s.graceCtx.Err() != nil {
+				s.drainConn(conn, ctrlStr, tracer, nextStreamID)
+				return nil
+			}
 			var appErr *quic.ApplicationError
 			if errors.As(err, &appErr) && appErr.ErrorCode == quic.ApplicationErrorCode(ErrCodeNoError) {
 				return nil
 			}
 			return fmt.Errorf("accepting stream failed: %w", err)
 		}
+		nextStreamID = str.StreamID() + 4
 		go func() {
-			rerr := s.handleRequest(conn, str, decoder, func() {
+			rerr := s.handleRequest(conn, str, tracer, decoder, encoder, dg, wt, pushes, priorities, func() {
 				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeFrameUnexpected), "")
 			})
 			if rerr.err == errHijacked {
@@ -493,7 +585,27 @@ func (s *Server) handleConn(conn quic.Connection) error {
 	}
 }
 
-func (s *Server) handleUnidirectionalStreams(conn quic.Connection) {
+// drainConn is called when the server is closed gracefully.
+// It sends a GOAWAY frame, telling the client that requests on streams starting at nextStreamID won't be processed,
+// such that the client can retry them on a new connection.
+// The client closes the connection once the requests in flight have completed.
+// If it doesn't do so before the server is closed, the connection is closed by the server.
+func (s *Server) drainConn(conn quic.Connection, ctrlStr quic.SendStream, tracer *qlog.HTTP3Tracer, nextStreamID quic.StreamID) {
+	f := &goAwayFrame{StreamID: uint64(nextStreamID)}
+	ctrlStr.Write(f.Append(nil))
+	if tracer != nil {
+		tracer.FrameCreated(ctrlStr.StreamID(), uint64(quicvarint.Len(f.StreamID)), &qlog.HTTP3GoAwayFrame{ID: f.StreamID})
+	}
+
+	select {
+	case <-conn.Context().Done():
+	case <-s.closeCtx.Done():
+		conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeNoError), "")
+	}
+}
+
+func (s *Server) handleUnidirectionalStreams(conn quic.Connection, tracer *qlog.HTTP3Tracer, decoder *qpackDecoder, encoder *qpackEncoder, wt *webTransportManager, pushes *serverPushes, priorities *requestPriorities) {
+	var rcvdQPACKEncoderStr, rcvdQPACKDecoderStr atomic.Bool
 	for {
 		str, err := conn.AcceptUniStream(context.Background())
 		if err != nil {
@@ -510,17 +622,38 @@ func (s *Server) handleUnidirectionalStreams(conn quic.Connection) {
 				s.logger.Debugf("reading stream type on stream %d failed: %s", str.StreamID(), err)
 				return
 			}
+			if tracer != nil {
+				tracer.StreamTypeSet(false, str.StreamID(), streamType)
+			}
 			// We're only interested in the control stream here.
 			switch streamType {
 			case streamTypeControlStream:
-			case streamTypeQPACKEncoderStream, streamTypeQPACKDecoderStream:
-				// Our QPACK implementation doesn't use the dynamic table yet.
-				// TODO: check that only one stream of each type is opened.
+			case streamTypeQPACKEncoderStream:
+				if isFirst := rcvdQPACKEncoderStr.CompareAndSwap(false, true); !isFirst {
+					conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeStreamCreationError), "duplicate QPACK encoder stream")
+					return
+				}
+				if rerr := decoder.handleEncoderStream(str); rerr.connErr != 0 {
+					conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), rerr.err.Error())
+				}
+				return
+			case streamTypeQPACKDecoderStream:
+				if isFirst := rcvdQPACKDecoderStr.CompareAndSwap(false, true); !isFirst {
+					conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeStreamCreationError), "duplicate QPACK decoder stream")
+					return
+				}
+				if rerr := encoder.handleDecoderStream(str); rerr.connErr != 0 {
+					conn.CloseWithError(quic.ApplicationErrorCode(rerr.connErr), rerr.err.Error())
+				}
 				return
 			case streamTypePushStream: // only the server can push
 				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeStreamCreationError), "")
 				return
 			default:
+				if wt != nil && streamType == streamTypeWebTransportStream {
+					wt.handleUniStream(str)
+					return
+				}
 				if s.UniStreamHijacker != nil && s.UniStreamHijacker(StreamType(streamType), conn, str, nil) {
 					return
 				}
@@ -537,19 +670,105 @@ func (s *Server) handleUnidirectionalStreams(conn quic.Connection) {
 				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeMissingSettings), "")
 				return
 			}
-			if !sf.Datagram {
-				return
+			if tracer != nil {
+				traceReceivedSettings(tracer, str.StreamID(), sf)
 			}
+			encoder.handleSettings(sf)
 			// If datagram support was enabled on our side as well as on the client side,
 			// we can expect it to have been negotiated both on the transport and on the HTTP/3 layer.
 			// Note: ConnectionState() will block until the handshake is complete (relevant when using 0-RTT).
-			if s.EnableDatagrams && !conn.ConnectionState().SupportsDatagrams {
+			if sf.Datagram && s.enableDatagrams() && !conn.ConnectionState().SupportsDatagrams {
 				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeSettingsError), "missing QUIC Datagram support")
+				return
 			}
+			s.handleControlStream(conn, str, tracer, pushes, priorities)
 		}(str)
 	}
 }
 
+// handleControlStream handles the frames sent on the client's control stream after the SETTINGS frame.
+func (s *Server) handleControlStream(conn quic.Connection, str quic.ReceiveStream, tracer *qlog.HTTP3Tracer, pushes *serverPushes, priorities *requestPriorities) {
+	for {
+		f, err := parseNextFrame(str, nil)
+		if err != nil {
+			return
+		}
+		switch f := f.(type) {
+		case *maxPushIDFrame:
+			if tracer != nil {
+				tracer.FrameParsed(str.StreamID(), uint64(quicvarint.Len(f.PushID)), &qlog.HTTP3MaxPushIDFrame{PushID: f.PushID})
+			}
+			if err := pushes.handleMaxPushID(f.PushID); err != nil {
+				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), err.Error())
+				return
+			}
+		case *cancelPushFrame:
+			if tracer != nil {
+				tracer.FrameParsed(str.StreamID(), uint64(quicvarint.Len(f.PushID)), &qlog.HTTP3CancelPushFrame{PushID: f.PushID})
+			}
+			if err := pushes.handleCancelPush(f.PushID); err != nil {
+				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), err.Error())
+				return
+			}
+		case *goAwayFrame:
+			if tracer != nil {
+				tracer.FrameParsed(str.StreamID(), uint64(quicvarint.Len(f.StreamID)), &qlog.HTTP3GoAwayFrame{ID: f.StreamID})
+			}
+			// The client's GOAWAY carries a push ID. Pushes with this or a higher ID won't be accepted.
+			if err := pushes.handleGoAway(f.StreamID); err != nil {
+				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), err.Error())
+				return
+			}
+		case *priorityUpdateFrame:
+			if tracer != nil {
+				tracer.FrameParsed(str.StreamID(), uint64(quicvarint.Len(f.ID))+uint64(len(f.PriorityFieldValue)), &qlog.HTTP3PriorityUpdateFrame{
+					IsPush:             f.IsPush,
+					ElementID:          f.ID,
+					PriorityFieldValue: f.PriorityFieldValue,
+				})
+			}
+			var err error
+			if f.IsPush {
+				err = pushes.handlePriorityUpdate(f.ID, f.PriorityFieldValue)
+			} else {
+				err = priorities.handlePriorityUpdate(f.ID, f.PriorityFieldValue)
+			}
+			if err != nil {
+				conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeIDError), err.Error())
+				return
+			}
+		default:
+			conn.CloseWithError(quic.ApplicationErrorCode(ErrCodeFrameUnexpected), fmt.Sprintf("unexpected frame on the control stream: %T", f))
+			return
+		}
+	}
+}
+
+func (s *Server) enableDatagrams() bool {
+	// WebTransport uses HTTP/3 datagrams
+	return s.EnableDatagrams || s.EnableWebTransport
+}
+
+func (s *Server) enableExtendedConnect() bool {
+	// WebTransport sessions are established using Extended CONNECT
+	return s.EnableExtendedConnect || s.EnableWebTransport || s.AdditionalSettings[settingExtendedConnect] == 1
+}
+
+// additionalSettings returns the AdditionalSettings sent in the SETTINGS frame.
+// SETTINGS_ENABLE_CONNECT_PROTOCOL is controlled by enableExtendedConnect, and must not be sent twice.
+func (s *Server) additionalSettings() map[uint64]uint64 {
+	if _, ok := s.AdditionalSettings[settingExtendedConnect]; !ok {
+		return s.AdditionalSettings
+	}
+	settings := make(map[uint64]uint64, len(s.AdditionalSettings))
+	for id, val := range s.AdditionalSettings {
+		if id != settingExtendedConnect {
+			settings[id] = val
+		}
+	}
+	return settings
+}
+
 func (s *Server) maxHeaderBytes() uint64 {
 	if s.MaxHeaderBytes <= 0 {
 		return http.DefaultMaxHeaderBytes
@@ -557,10 +776,21 @@ func (s *Server) maxHeaderBytes() uint64 {
 	return uint64(s.MaxHeaderBytes)
 }
 
-func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, decoder *qpack.Decoder, onFrameError func()) requestError {
+func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, tracer *qlog.HTTP3Tracer, decoder *qpackDecoder, encoder *qpackEncoder, dg *datagramRouter, wt *webTransportManager, pushes *serverPushes, priorities *requestPriorities, onFrameError func()) requestError {
 	var ufh unknownFrameHandlerFunc
-	if s.StreamHijacker != nil {
-		ufh = func(ft FrameType, e error) (processed bool, err error) { return s.StreamHijacker(ft, conn, str, e) }
+	if s.StreamHijacker != nil || wt != nil {
+		ufh = func(ft FrameType, e error) (processed bool, err error) {
+			if wt != nil && e == nil && ft == frameTypeWebTransportStream {
+				if err := wt.handleStream(str); err != nil {
+					return false, err
+				}
+				return true, nil
+			}
+			if s.StreamHijacker == nil {
+				return false, nil
+			}
+			return s.StreamHijacker(ft, conn, str, e)
+		}
 	}
 	frame, err := parseNextFrame(str, ufh)
 	if err != nil {
@@ -573,34 +803,55 @@ func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, decoder *q
 	if !ok {
 		return newConnError(ErrCodeFrameUnexpected, errors.New("expected first frame to be a HEADERS frame"))
 	}
-	if hf.Length > s.maxHeaderBytes() {
-		return newStreamError(ErrCodeFrameError, fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, s.maxHeaderBytes()))
+	headerBlock, rerr := s.readHeaderBlock(str, hf)
+	if rerr.err != nil {
+		return rerr
 	}
-	headerBlock := make([]byte, hf.Length)
-	if _, err := io.ReadFull(str, headerBlock); err != nil {
-		return newStreamError(ErrCodeRequestIncomplete, err)
+	ctx := str.Context()
+	hfs, rerr := decodeHeaderBlock(ctx, str, decoder, headerBlock)
+	if rerr.err != nil {
+		return rerr
 	}
-	hfs, err := decoder.DecodeFull(headerBlock)
-	if err != nil {
-		// TODO: use the right error code
-		return newConnError(ErrCodeGeneralProtocolError, err)
+	if tracer != nil {
+		tracer.FrameParsed(str.StreamID(), hf.Length, &qlog.HTTP3HeadersFrame{HeaderFields: qlogHeaderFields(hfs)})
 	}
 	req, err := requestFromHeaders(hfs)
 	if err != nil {
 		return newStreamError(ErrCodeMessageError, err)
 	}
+	// Without SETTINGS_ENABLE_CONNECT_PROTOCOL, requests carrying a :protocol pseudo header are malformed.
+	// See section 3 of RFC 9220.
+	if !s.enableExtendedConnect() && isExtendedConnect(req) {
+		return newStreamError(ErrCodeMessageError, errors.New("received Extended CONNECT request, but Extended CONNECT is not enabled"))
+	}
 
 	connState := conn.ConnectionState().TLS
 	req.TLS = &connState
 	req.RemoteAddr = conn.RemoteAddr().String()
 
+	// The trailers declared in the Trailer header are filled in once they're received after the body.
+	req.Trailer = declaredTrailers(req.Header)
+	hstr := newStream(str, tracer, onFrameError)
+	hstr.onTrailers = func(f *headersFrame) error {
+		trailer, rerr := s.readTrailers(ctx, str, decoder, tracer, f)
+		if rerr.err != nil {
+			return abortOnError(conn, str, rerr)
+		}
+		if req.Trailer == nil {
+			req.Trailer = make(http.Header, len(trailer))
+		}
+		for k, v := range trailer {
+			req.Trailer[k] = v
+		}
+		return nil
+	}
 	// Check that the client doesn't send more data in DATA frames than indicated by the Content-Length header (if set).
 	// See section 4.1.2 of RFC 9114.
 	var httpStr Stream
 	if _, ok := req.Header["Content-Length"]; ok && req.ContentLength >= 0 {
-		httpStr = newLengthLimitedStream(newStream(str, onFrameError), req.ContentLength)
+		httpStr = newLengthLimitedStream(hstr, req.ContentLength)
 	} else {
-		httpStr = newStream(str, onFrameError)
+		httpStr = hstr
 	}
 	body := newRequestBody(httpStr)
 	req.Body = body
@@ -611,10 +862,21 @@ func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, decoder *q
 		s.logger.Infof("%s %s%s", req.Method, req.Host, req.RequestURI)
 	}
 
-	ctx := str.Context()
 	ctx = context.WithValue(ctx, ServerContextKey, s)
 	ctx = context.WithValue(ctx, http.LocalAddrContextKey, conn.LocalAddr())
 	ctx = context.WithValue(ctx, RemoteAddrContextKey, conn.RemoteAddr())
+	if dg != nil {
+		ctx = context.WithValue(ctx, datagramRouterContextKey, dg)
+	}
+	if wt != nil {
+		ctx = context.WithValue(ctx, webTransportContextKey, wt)
+	}
+	var prio *priorityState
+	if priorities != nil {
+		prio = priorities.newRequest(str, req.Header.Values("Priority"))
+		defer priorities.remove(str.StreamID())
+		ctx = context.WithValue(ctx, priorityContextKey, prio)
+	}
 	if s.ConnContext != nil {
 		ctx = s.ConnContext(ctx, conn)
 		if ctx == nil {
@@ -622,32 +884,18 @@ func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, decoder *q
 		}
 	}
 	req = req.WithContext(ctx)
-	r := newResponseWriter(str, conn, s.logger)
+	r := newResponseWriter(str, conn, encoder, tracer, s.logger)
+	r.priority = prio
 	if req.Method == http.MethodHead {
 		r.isHead = true
 	}
-	handler := s.Handler
-	if handler == nil {
-		handler = http.DefaultServeMux
+	if pushes != nil {
+		r.pusher = func(target string, opts *http.PushOptions) error {
+			return s.push(conn, tracer, pushes, r, req, target, opts)
+		}
 	}
 
-	var panicked bool
-	func() {
-		defer func() {
-			if p := recover(); p != nil {
-				panicked = true
-				if p == http.ErrAbortHandler {
-					return
-				}
-				// Copied from net/http/server.go
-				const size = 64 << 10
-				buf := make([]byte, size)
-				buf = buf[:runtime.Stack(buf, false)]
-				s.logger.Errorf("http: panic serving: %v\n%s", p, buf)
-			}
-		}()
-		handler.ServeHTTP(r, req)
-	}()
+	panicked := s.serveHTTP(r, req)
 
 	if body.wasStreamHijacked() {
 		return requestError{err: errHijacked}
@@ -655,13 +903,7 @@ func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, decoder *q
 
 	// only write response when there is no panic
 	if !panicked {
-		// response not written to the client yet, set Content-Length
-		if !r.written {
-			if _, haveCL := r.header["Content-Length"]; !haveCL {
-				r.header.Set("Content-Length", strconv.FormatInt(r.numWritten, 10))
-			}
-		}
-		r.Flush()
+		r.finish()
 	}
 	// If the EOF was read by the handler, CancelRead() is a no-op.
 	str.CancelRead(quic.StreamErrorCode(ErrCodeNoError))
@@ -673,13 +915,151 @@ func (s *Server) handleRequest(conn quic.Connection, str quic.Stream, decoder *q
 	return requestError{}
 }
 
+// readHeaderBlock reads the payload of a HEADERS frame.
+func (s *Server) readHeaderBlock(str quic.Stream, hf *headersFrame) ([]byte, requestError) {
+	if hf.Length > s.maxHeaderBytes() {
+		return nil, newStreamError(ErrCodeFrameError, fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, s.maxHeaderBytes()))
+	}
+	headerBlock := make([]byte, hf.Length)
+	if _, err := io.ReadFull(str, headerBlock); err != nil {
+		return nil, newStreamError(ErrCodeRequestIncomplete, err)
+	}
+	return headerBlock, requestError{}
+}
+
+// decodeHeaderBlock decodes the header fields of a field section received on a request stream.
+func decodeHeaderBlock(ctx context.Context, str quic.Stream, decoder *qpackDecoder, headerBlock []byte) ([]qpack.HeaderField, requestError) {
+	hfs, err := decoder.decode(ctx, str.StreamID(), headerBlock)
+	if err != nil {
+		if err == errQPACKTooManyBlockedStreams || ctx.Err() == nil {
+			return nil, newConnError(ErrCodeQPACKDecompressionFailed, err)
+		}
+		return nil, newStreamError(ErrCodeRequestIncomplete, err)
+	}
+	return hfs, requestError{}
+}
+
+// readTrailers reads the trailer section of a request.
+// Decoding it through the QPACK decoder makes sure that a Section Acknowledgment is sent, if required.
+func (s *Server) readTrailers(ctx context.Context, str quic.Stream, decoder *qpackDecoder, tracer *qlog.HTTP3Tracer, hf *headersFrame) (http.Header, requestError) {
+	headerBlock, rerr := s.readHeaderBlock(str, hf)
+	if rerr.err != nil {
+		return nil, rerr
+	}
+	hfs, rerr := decodeHeaderBlock(ctx, str, decoder, headerBlock)
+	if rerr.err != nil {
+		return nil, rerr
+	}
+	if tracer != nil {
+		tracer.FrameParsed(str.StreamID(), hf.Length, &qlog.HTTP3HeadersFrame{HeaderFields: qlogHeaderFields(hfs)})
+	}
+	trailer, err := parseTrailers(hfs)
+	if err != nil {
+		return nil, newStreamError(ErrCodeMessageError, err)
+	}
+	return trailer, requestError{}
+}
+
+// serveHTTP calls the handler. It returns true if the handler panicked.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) (panicked bool) {
+	handler := s.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			panicked = true
+			if p == http.ErrAbortHandler {
+				return
+			}
+			// Copied from net/http/server.go
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			s.logger.Errorf("http: panic serving: %v\n%s", p, buf)
+		}
+	}()
+	handler.ServeHTTP(w, r)
+	return false
+}
+
+// push promises the response for target on the request stream, and serves it on a push stream.
+func (s *Server) push(conn quic.Connection, tracer *qlog.HTTP3Tracer, pushes *serverPushes, w *responseWriter, req *http.Request, target string, opts *http.PushOptions) error {
+	pushReq, err := newPushRequest(req, target, opts)
+	if err != nil {
+		return err
+	}
+	push, err := pushes.newPush()
+	if err != nil {
+		return err
+	}
+	// The priority header field of the push request sets the initial priority of the push.
+	push.priority.update(parsePriority(strings.Join(pushReq.Header.Values("Priority"), ",")), false)
+	if err := w.writePushPromise(push.id, pushReq); err != nil {
+		pushes.remove(push)
+		return err
+	}
+	go s.handlePush(conn, tracer, w.encoder, pushes, push, pushReq)
+	return nil
+}
+
+func (s *Server) handlePush(conn quic.Connection, tracer *qlog.HTTP3Tracer, encoder *qpackEncoder, pushes *serverPushes, push *serverPush, req *http.Request) {
+	defer pushes.remove(push)
+
+	str, err := conn.OpenUniStreamSync(conn.Context())
+	if err != nil {
+		s.logger.Debugf("opening push stream failed: %s", err)
+		return
+	}
+	if !pushes.setStream(push, str) {
+		// the client cancelled the push before we opened the stream
+		str.CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled))
+		return
+	}
+	b := quicvarint.Append(make([]byte, 0, 16), streamTypePushStream)
+	b = quicvarint.Append(b, push.id)
+	if _, err := str.Write(b); err != nil {
+		return
+	}
+	if tracer != nil {
+		tracer.StreamTypeSet(true, str.StreamID(), streamTypePushStream)
+	}
+	s.logger.Debugf("pushing %s %s%s, push ID %d", req.Method, req.Host, req.RequestURI, push.id)
+
+	ctx := str.Context()
+	ctx = context.WithValue(ctx, ServerContextKey, s)
+	ctx = context.WithValue(ctx, http.LocalAddrContextKey, conn.LocalAddr())
+	ctx = context.WithValue(ctx, RemoteAddrContextKey, conn.RemoteAddr())
+	ctx = context.WithValue(ctx, priorityContextKey, push.priority)
+	if s.ConnContext != nil {
+		ctx = s.ConnContext(ctx, conn)
+		if ctx == nil {
+			panic("http3: ConnContext returned nil")
+		}
+	}
+	req = req.WithContext(ctx)
+	w := newResponseWriter(sendOnlyStream{str}, conn, encoder, tracer, s.logger)
+	w.priority = push.priority
+	if req.Method == http.MethodHead {
+		w.isHead = true
+	}
+	if s.serveHTTP(w, req) {
+		str.CancelWrite(quic.StreamErrorCode(ErrCodeInternalError))
+		return
+	}
+	w.finish()
+	str.Close()
+}
+
 // Close the server immediately, aborting requests and sending CONNECTION_CLOSE frames to connected clients.
 // Close in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
 func (s *Server) Close() error {
+	s.init()
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	s.closed = true
+	s.closeCancel()
 
 	var err error
 	for ln := range s.listeners {
@@ -691,10 +1071,33 @@ func (s *Server) Close() error {
 }
 
 // CloseGracefully shuts down the server gracefully. The server sends a GOAWAY frame first, then waits for either timeout to trigger, or for all running requests to complete.
+// Clients retry requests that weren't processed by the server on a new connection,
+// and close the connection once their requests in flight have completed.
+// When all connections have been closed, or when the timeout triggers, the server is closed using Close.
 // CloseGracefully in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
 func (s *Server) CloseGracefully(timeout time.Duration) error {
-	// TODO: implement
-	return nil
+	s.init()
+	s.mutex.Lock()
+	s.closed = true
+	s.graceCancel()
+	var done chan struct{}
+	if s.connCount > 0 {
+		if s.connsDone == nil {
+			s.connsDone = make(chan struct{})
+		}
+		done = s.connsDone
+	}
+	s.mutex.Unlock()
+
+	if done != nil {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+		}
+	}
+	return s.Close()
 }
 
 // ErrNoAltSvcPort is the error returned by SetQuicHeaders when no port was found
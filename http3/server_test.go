@@ -1,6 +1,7 @@
 package http3
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
@@ -86,7 +87,8 @@ var _ = Describe("Server", func() {
 
 	Context("handling requests", func() {
 		var (
-			qpackDecoder       *qpack.Decoder
+			qpackDecoder       *qpackDecoder
+			qpackEncoder       *qpackEncoder
 			str                *mockquic.MockStream
 			conn               *mockquic.MockEarlyConnection
 			exampleGetRequest  *http.Request
@@ -117,8 +119,9 @@ var _ = Describe("Server", func() {
 			buf := &bytes.Buffer{}
 			str := mockquic.NewMockStream(mockCtrl)
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
-			rw := newRequestWriter(utils.DefaultLogger)
-			Expect(rw.WriteRequestHeader(str, req, false)).To(Succeed())
+			str.EXPECT().StreamID().AnyTimes()
+			rw := newRequestWriter(newQPACKEncoder(nil, 0, nil), utils.DefaultLogger)
+			Expect(rw.WriteRequestHeader(str, req, false, nil)).To(Succeed())
 			return buf.Bytes()
 		}
 
@@ -139,9 +142,13 @@ var _ = Describe("Server", func() {
 			examplePostRequest, err = http.NewRequest("POST", "https://www.example.com", bytes.NewReader([]byte("foobar")))
 			Expect(err).ToNot(HaveOccurred())
 
-			qpackDecoder = qpack.NewDecoder(nil)
+			qpackDecoder = newQPACKDecoder(nil, 0, 0, nil)
+			qpackEncoder = newQPACKEncoder(nil, 0, nil)
 			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().AnyTimes()
+			str.EXPECT().SetPriority(gomock.Any()).AnyTimes()
 			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 			addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
 			conn.EXPECT().RemoteAddr().Return(addr).AnyTimes()
 			conn.EXPECT().LocalAddr().AnyTimes()
@@ -161,7 +168,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			Expect(s.handleRequest(conn, str, qpackDecoder, nil)).To(Equal(requestError{}))
+			Expect(s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)).To(Equal(requestError{}))
 			var req *http.Request
 			Eventually(requestChan).Should(Receive(&req))
 			Expect(req.Host).To(Equal("www.example.com"))
@@ -179,7 +186,7 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
@@ -196,7 +203,7 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
@@ -218,7 +225,7 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
@@ -238,7 +245,7 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Context().Return(reqContext)
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
@@ -257,7 +264,7 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Context().Return(reqContext)
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
@@ -276,7 +283,7 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)
 			Expect(serr.err).To(MatchError(errPanicked))
 			Expect(responseBuf.Bytes()).To(HaveLen(0))
 		})
@@ -292,11 +299,86 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)
 			Expect(serr.err).To(MatchError(errPanicked))
 			Expect(responseBuf.Bytes()).To(HaveLen(0))
 		})
 
+		It("reads request trailers", func() {
+			decoderStreamBuf := &bytes.Buffer{}
+			decoderStr := mockquic.NewMockStream(mockCtrl)
+			decoderStr.EXPECT().Write(gomock.Any()).DoAndReturn(decoderStreamBuf.Write).AnyTimes()
+			qpackDecoder = newQPACKDecoder(conn, 4096, 16, nil)
+			qpackDecoder.str = decoderStr
+
+			// The trailers are encoded using the dynamic table, so the decoder needs to acknowledge the field section.
+			encoderStreamBuf := &bytes.Buffer{}
+			encoderStr := mockquic.NewMockStream(mockCtrl)
+			encoderStr.EXPECT().Write(gomock.Any()).DoAndReturn(encoderStreamBuf.Write).AnyTimes()
+			trailerEncoder := newQPACKEncoder(conn, 4096, nil)
+			trailerEncoder.str = encoderStr
+			trailerEncoder.handleSettings(&settingsFrame{QPACKMaxTableCapacity: 4096, QPACKBlockedStreams: 16})
+			trailers, err := trailerEncoder.encode(0, []qpack.HeaderField{{Name: "grpc-status", Value: "0"}})
+			Expect(err).ToNot(HaveOccurred())
+			r := bufio.NewReader(encoderStreamBuf)
+			for {
+				b, err := r.ReadByte()
+				if err == io.EOF {
+					break
+				}
+				Expect(qpackDecoder.handleEncoderInstruction(r, b)).To(Succeed())
+			}
+
+			examplePostRequest.Header.Set("Trailer", "Grpc-Status")
+			data := encodeRequest(examplePostRequest)
+			data = (&dataFrame{Length: 6}).Append(data)
+			data = append(data, []byte("foobar")...)
+			data = (&headersFrame{Length: uint64(len(trailers))}).Append(data)
+			data = append(data, trailers...)
+			setRequest(data)
+
+			done := make(chan struct{})
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(r.Header).ToNot(HaveKey("Trailer"))
+				Expect(r.Trailer).To(Equal(http.Header{"Grpc-Status": nil}))
+				body, err := io.ReadAll(r.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("foobar"))
+				Expect(r.Trailer).To(Equal(http.Header{"Grpc-Status": []string{"0"}}))
+			})
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			Expect(s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)).To(Equal(requestError{}))
+			Eventually(done).Should(BeClosed())
+			// Section Acknowledgment for stream 0
+			Expect(decoderStreamBuf.Bytes()).To(Equal([]byte{0x80}))
+		})
+
+		It("rejects malformed request trailers", func() {
+			trailers, err := newQPACKEncoder(nil, 0, nil).encode(0, []qpack.HeaderField{{Name: ":status", Value: "200"}})
+			Expect(err).ToNot(HaveOccurred())
+			data := encodeRequest(exampleGetRequest)
+			data = (&headersFrame{Length: uint64(len(trailers))}).Append(data)
+			data = append(data, trailers...)
+			setRequest(data)
+
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				_, err := io.ReadAll(r.Body)
+				Expect(err).To(MatchError("received pseudo header in trailer: :status"))
+			})
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+			str.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeMessageError))
+			str.EXPECT().CancelRead(gomock.Any())
+
+			Expect(s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)).To(Equal(requestError{}))
+		})
+
 		Context("hijacking bidirectional streams", func() {
 			var conn *mockquic.MockEarlyConnection
 			testDone := make(chan struct{})
@@ -304,6 +386,7 @@ var _ = Describe("Server", func() {
 			BeforeEach(func() {
 				testDone = make(chan struct{})
 				conn = mockquic.NewMockEarlyConnection(mockCtrl)
+				conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 				controlStr := mockquic.NewMockStream(mockCtrl)
 				controlStr.EXPECT().Write(gomock.Any())
 				conn.EXPECT().OpenUniStream().Return(controlStr, nil)
@@ -323,6 +406,7 @@ var _ = Describe("Server", func() {
 
 				buf := bytes.NewBuffer(quicvarint.Append(nil, 0x41))
 				unknownStr := mockquic.NewMockStream(mockCtrl)
+				unknownStr.EXPECT().StreamID().AnyTimes()
 				unknownStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
 				conn.EXPECT().AcceptStream(gomock.Any()).Return(unknownStr, nil)
 				conn.EXPECT().AcceptStream(gomock.Any()).Return(nil, errors.New("done"))
@@ -335,6 +419,37 @@ var _ = Describe("Server", func() {
 				time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to conn.CloseWithError
 			})
 
+			It("passes WebTransport streams to the WebTransport session", func() {
+				s.EnableWebTransport = true
+				s.StreamHijacker = func(FrameType, quic.Connection, quic.Stream, error) (bool, error) {
+					Fail("StreamHijacker should not be called.")
+					return false, nil
+				}
+
+				b := quicvarint.Append(nil, frameTypeWebTransportStream)
+				b = quicvarint.Append(b, 0) // session ID
+				buf := bytes.NewBuffer(b)
+				var bytesRead atomic.Int64
+				wtStr := mockquic.NewMockStream(mockCtrl)
+				wtStr.EXPECT().StreamID().AnyTimes()
+				wtStr.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+					n, err := buf.Read(p)
+					bytesRead.Add(int64(n))
+					return n, err
+				}).AnyTimes()
+				conn.EXPECT().ReceiveDatagram(gomock.Any()).Return(nil, errors.New("done"))
+				conn.EXPECT().AcceptStream(gomock.Any()).Return(wtStr, nil)
+				conn.EXPECT().AcceptStream(gomock.Any()).Return(nil, errors.New("done"))
+				conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				s.handleConn(conn)
+				// the stream is buffered until the session is established
+				Eventually(bytesRead.Load).Should(BeEquivalentTo(len(b)))
+				time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to wtStr.CancelWrite or conn.CloseWithError
+			})
+
 			It("cancels writing when hijacker didn't hijack a bidirectional stream", func() {
 				frameTypeChan := make(chan FrameType, 1)
 				s.StreamHijacker = func(ft FrameType, c quic.Connection, s quic.Stream, e error) (hijacked bool, err error) {
@@ -345,6 +460,7 @@ var _ = Describe("Server", func() {
 
 				buf := bytes.NewBuffer(quicvarint.Append(nil, 0x41))
 				unknownStr := mockquic.NewMockStream(mockCtrl)
+				unknownStr.EXPECT().StreamID().AnyTimes()
 				unknownStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
 				unknownStr.EXPECT().CancelWrite(quic.StreamErrorCode(ErrCodeRequestIncomplete))
 				conn.EXPECT().AcceptStream(gomock.Any()).Return(unknownStr, nil)
@@ -368,6 +484,7 @@ var _ = Describe("Server", func() {
 
 				buf := bytes.NewBuffer(quicvarint.Append(nil, 0x41))
 				unknownStr := mockquic.NewMockStream(mockCtrl)
+				unknownStr.EXPECT().StreamID().AnyTimes()
 				unknownStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
 				unknownStr.EXPECT().CancelWrite(quic.StreamErrorCode(ErrCodeRequestIncomplete))
 				conn.EXPECT().AcceptStream(gomock.Any()).Return(unknownStr, nil)
@@ -385,6 +502,7 @@ var _ = Describe("Server", func() {
 				testErr := errors.New("test error")
 				done := make(chan struct{})
 				unknownStr := mockquic.NewMockStream(mockCtrl)
+				unknownStr.EXPECT().StreamID().AnyTimes()
 				s.StreamHijacker = func(ft FrameType, _ quic.Connection, str quic.Stream, err error) (bool, error) {
 					defer close(done)
 					Expect(ft).To(BeZero())
@@ -413,6 +531,7 @@ var _ = Describe("Server", func() {
 			BeforeEach(func() {
 				testDone = make(chan struct{})
 				conn = mockquic.NewMockEarlyConnection(mockCtrl)
+				conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 				controlStr := mockquic.NewMockStream(mockCtrl)
 				controlStr.EXPECT().Write(gomock.Any())
 				conn.EXPECT().OpenUniStream().Return(controlStr, nil)
@@ -501,6 +620,7 @@ var _ = Describe("Server", func() {
 
 			BeforeEach(func() {
 				conn = mockquic.NewMockEarlyConnection(mockCtrl)
+				conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 				controlStr := mockquic.NewMockStream(mockCtrl)
 				controlStr.EXPECT().Write(gomock.Any())
 				conn.EXPECT().OpenUniStream().Return(controlStr, nil)
@@ -535,10 +655,34 @@ var _ = Describe("Server", func() {
 					name = "decoder"
 				}
 
-				It(fmt.Sprintf("ignores the QPACK %s streams", name), func() {
+				It(fmt.Sprintf("accepts the QPACK %s stream", name), func() {
+					buf := bytes.NewBuffer(quicvarint.Append(nil, streamType))
+					str := mockquic.NewMockStream(mockCtrl)
+					block := make(chan struct{}) // the QPACK stream stays open
+					str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+						if buf.Len() == 0 {
+							<-block
+						}
+						return buf.Read(b)
+					}).AnyTimes()
+
+					conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+						return str, nil
+					})
+					conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+						<-testDone
+						return nil, errors.New("test done")
+					})
+					s.handleConn(conn)
+					time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to str.CancelRead or conn.CloseWithError
+				})
+
+				It(fmt.Sprintf("closes the connection when the QPACK %s stream is closed", name), func() {
 					buf := bytes.NewBuffer(quicvarint.Append(nil, streamType))
 					str := mockquic.NewMockStream(mockCtrl)
 					str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+					closed := make(chan struct{})
+					conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeClosedCriticalStream), gomock.Any()).Do(func(quic.ApplicationErrorCode, string) error { close(closed); return nil })
 
 					conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
 						return str, nil
@@ -548,7 +692,7 @@ var _ = Describe("Server", func() {
 						return nil, errors.New("test done")
 					})
 					s.handleConn(conn)
-					time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to str.CancelRead
+					Eventually(closed).Should(BeClosed())
 				})
 			}
 
@@ -638,6 +782,7 @@ var _ = Describe("Server", func() {
 
 			It("errors when the client advertises datagram support (and we enabled support for it)", func() {
 				s.EnableDatagrams = true
+				conn.EXPECT().ReceiveDatagram(gomock.Any()).Return(nil, errors.New("done"))
 				b := quicvarint.Append(nil, streamTypeControlStream)
 				b = (&settingsFrame{Datagram: true}).Append(b)
 				r := bytes.NewReader(b)
@@ -669,6 +814,7 @@ var _ = Describe("Server", func() {
 				testDone = make(chan struct{})
 				addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
 				conn = mockquic.NewMockEarlyConnection(mockCtrl)
+				conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 				controlStr := mockquic.NewMockStream(mockCtrl)
 				controlStr.EXPECT().Write(gomock.Any())
 				conn.EXPECT().OpenUniStream().Return(controlStr, nil)
@@ -728,6 +874,102 @@ var _ = Describe("Server", func() {
 				Eventually(handlerCalled).Should(BeClosed())
 			})
 
+			Context("Extended CONNECT", func() {
+				var connectRequest *http.Request
+
+				BeforeEach(func() {
+					var err error
+					connectRequest, err = http.NewRequest(http.MethodConnect, "https://www.example.com/chat", nil)
+					Expect(err).ToNot(HaveOccurred())
+					connectRequest.Proto = "websocket"
+				})
+
+				It("rejects Extended CONNECT requests if Extended CONNECT is not enabled", func() {
+					s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						Fail("Handler should not be called.")
+					})
+
+					setRequest(encodeRequest(connectRequest))
+					done := make(chan struct{})
+					str.EXPECT().Context().Return(reqContext)
+					str.EXPECT().CancelWrite(quic.StreamErrorCode(ErrCodeMessageError)).Do(func(quic.StreamErrorCode) { close(done) })
+
+					s.handleConn(conn)
+					Eventually(done).Should(BeClosed())
+				})
+
+				It("lets the handler take over the stream", func() {
+					s.EnableExtendedConnect = true
+					handlerCalled := make(chan struct{})
+					s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						defer close(handlerCalled)
+						Expect(r.Method).To(Equal(http.MethodConnect))
+						Expect(r.Proto).To(Equal("websocket"))
+						cstr, err := AcceptExtendedConnect(w, r)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(cstr.RemoteAddr()).To(Equal(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}))
+						_, err = cstr.Write([]byte("foobar"))
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					setRequest(encodeRequest(connectRequest))
+					responseBuf := &bytes.Buffer{}
+					str.EXPECT().Context().Return(reqContext)
+					str.EXPECT().StreamID().AnyTimes()
+					str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
+					// the stream is neither closed nor reset when the handler returns
+
+					s.handleConn(conn)
+					Eventually(handlerCalled).Should(BeClosed())
+					hfs := decodeHeader(responseBuf)
+					Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
+					Expect(hfs).ToNot(HaveKey("content-length"))
+					frame, err := parseNextFrame(responseBuf, nil)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(frame).To(Equal(&dataFrame{Length: 6}))
+					Expect(responseBuf.Bytes()).To(Equal([]byte("foobar")))
+				})
+
+				It("lets the handler take over the stream if Extended CONNECT is enabled in the AdditionalSettings", func() {
+					s.AdditionalSettings = map[uint64]uint64{settingExtendedConnect: 1}
+					handlerCalled := make(chan struct{})
+					s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						defer close(handlerCalled)
+						Expect(r.Proto).To(Equal("websocket"))
+						_, err := AcceptExtendedConnect(w, r)
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					setRequest(encodeRequest(connectRequest))
+					str.EXPECT().Context().Return(reqContext)
+					str.EXPECT().StreamID().AnyTimes()
+					str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+
+					s.handleConn(conn)
+					Eventually(handlerCalled).Should(BeClosed())
+				})
+
+				It("refuses to accept requests that are not Extended CONNECT requests", func() {
+					handlerCalled := make(chan struct{})
+					s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						defer close(handlerCalled)
+						_, err := AcceptExtendedConnect(w, r)
+						Expect(err).To(MatchError("http3: not an Extended CONNECT request"))
+					})
+
+					setRequest(encodeRequest(exampleGetRequest))
+					done := make(chan struct{})
+					str.EXPECT().Context().Return(reqContext)
+					str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) { return len(p), nil }).AnyTimes()
+					str.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeNoError))
+					str.EXPECT().Close().Do(func() error { close(done); return nil })
+
+					s.handleConn(conn)
+					Eventually(handlerCalled).Should(BeClosed())
+					Eventually(done).Should(BeClosed())
+				})
+			})
+
 			It("errors when the client sends a too large header frame", func() {
 				s.MaxHeaderBytes = 20
 				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -824,7 +1066,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeNoError))
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			Eventually(handlerCalled).Should(BeClosed())
 		})
@@ -847,7 +1089,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeNoError))
 
-			serr := s.handleRequest(conn, str, qpackDecoder, nil)
+			serr := s.handleRequest(conn, str, nil, qpackDecoder, qpackEncoder, nil, nil, nil, nil, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			Eventually(handlerCalled).Should(BeClosed())
 		})
@@ -1255,6 +1497,7 @@ var _ = Describe("Server", func() {
 			tlsConf := testdata.GetTLSConfig()
 			tlsConf.NextProtos = []string{NextProtoH3}
 			conn := mockquic.NewMockEarlyConnection(mockCtrl)
+			conn.EXPECT().Context().Return(context.Background()).AnyTimes()
 			controlStr := mockquic.NewMockStream(mockCtrl)
 			controlStr.EXPECT().Write(gomock.Any())
 			conn.EXPECT().OpenUniStream().Return(controlStr, nil)
@@ -1269,6 +1512,83 @@ var _ = Describe("Server", func() {
 		})
 	})
 
+	Context("closing gracefully", func() {
+		var (
+			conn      *mockquic.MockEarlyConnection
+			connCtx   context.Context
+			closeConn context.CancelFunc
+			written   chan []byte
+			served    chan error
+		)
+
+		BeforeEach(func() {
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			connCtx, closeConn = context.WithCancel(context.Background())
+			conn.EXPECT().Context().Return(connCtx).AnyTimes()
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().StreamID().Return(quic.StreamID(3)).AnyTimes()
+			written = make(chan []byte, 2)
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				written <- append([]byte{}, b...)
+				return len(b), nil
+			}).Times(2)
+			conn.EXPECT().OpenUniStream().Return(controlStr, nil)
+			conn.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(ctx context.Context) (quic.ReceiveStream, error) {
+				<-connCtx.Done()
+				return nil, errors.New("connection closed")
+			}).MaxTimes(1)
+			accepting := make(chan struct{})
+			conn.EXPECT().AcceptStream(gomock.Any()).DoAndReturn(func(ctx context.Context) (quic.Stream, error) {
+				close(accepting)
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+			served = make(chan error, 1)
+			go func() { served <- s.ServeQUICConn(conn) }()
+			Eventually(accepting).Should(BeClosed())
+		})
+
+		AfterEach(func() { closeConn() })
+
+		expectGoAway := func(id uint64) {
+			var settings, goAway []byte
+			EventuallyWithOffset(1, written).Should(Receive(&settings))
+			r := bytes.NewReader(settings)
+			st, err := quicvarint.Read(r)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			ExpectWithOffset(1, st).To(BeEquivalentTo(streamTypeControlStream))
+			EventuallyWithOffset(1, written).Should(Receive(&goAway))
+			f, err := parseNextFrame(bytes.NewReader(goAway), nil)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			ExpectWithOffset(1, f).To(Equal(&goAwayFrame{StreamID: id}))
+		}
+
+		It("sends a GOAWAY frame and waits for the client to close the connection", func() {
+			closed := make(chan error, 1)
+			go func() { closed <- s.CloseGracefully(time.Hour) }()
+			Consistently(closed, scaleDuration(50*time.Millisecond)).ShouldNot(Receive())
+			expectGoAway(0)
+			closeConn()
+			Eventually(served).Should(Receive(BeNil()))
+			Eventually(closed).Should(Receive(BeNil()))
+		})
+
+		It("closes the connection when the timeout triggers", func() {
+			conn.EXPECT().CloseWithError(quic.ApplicationErrorCode(ErrCodeNoError), "").Do(func(quic.ApplicationErrorCode, string) error {
+				closeConn()
+				return nil
+			})
+			Expect(s.CloseGracefully(scaleDuration(50 * time.Millisecond))).To(Succeed())
+			Eventually(served).Should(Receive(BeNil()))
+			expectGoAway(0)
+		})
+	})
+
+	It("doesn't serve after closing gracefully", func() {
+		Expect(s.CloseGracefully(0)).To(Succeed())
+		Expect(s.ListenAndServeTLS(testdata.GetCertificatePaths())).To(MatchError(http.ErrServerClosed))
+	})
+
 	Context("ListenAndServe", func() {
 		BeforeEach(func() {
 			s.Addr = "localhost:0"
@@ -1314,4 +1634,22 @@ var _ = Describe("Server", func() {
 		Expect(s.ListenAndServe()).To(HaveOccurred())
 		Expect(receivedConf.EnableDatagrams).To(BeTrue())
 	})
+
+	It("enables Extended CONNECT if SETTINGS_ENABLE_CONNECT_PROTOCOL is set in the AdditionalSettings", func() {
+		s.AdditionalSettings = map[uint64]uint64{settingExtendedConnect: 1, 0x1337: 42}
+		Expect(s.enableExtendedConnect()).To(BeTrue())
+		Expect(s.additionalSettings()).To(Equal(map[uint64]uint64{0x1337: 42}))
+		// the setting is only sent once
+		b := (&settingsFrame{ExtendedConnect: s.enableExtendedConnect(), Other: s.additionalSettings()}).Append(nil)
+		f, err := parseNextFrame(bytes.NewReader(b), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.(*settingsFrame).ExtendedConnect).To(BeTrue())
+		Expect(f.(*settingsFrame).Other).To(Equal(map[uint64]uint64{0x1337: 42}))
+
+		s.AdditionalSettings = map[uint64]uint64{settingExtendedConnect: 0}
+		Expect(s.enableExtendedConnect()).To(BeFalse())
+		s.EnableExtendedConnect = true
+		Expect(s.enableExtendedConnect()).To(BeTrue())
+		Expect(s.additionalSettings()).To(BeEmpty())
+	})
 })
@@ -3,6 +3,7 @@ package http3
 import (
 	"context"
 	"errors"
+	"io"
 	"os"
 	"sync"
 
@@ -102,6 +103,20 @@ func (s *stateTrackingStream) Write(b []byte) (int, error) {
 	return n, err
 }
 
+func (s *stateTrackingStream) WriteBuffer(b []byte, release func()) (int, error) {
+	n, err := s.Stream.WriteBuffer(b, release)
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		s.closeSend(err)
+	}
+	return n, err
+}
+
+// ReadFrom uses Write, such that errors are tracked.
+// Errors returned by the ReadFrom method of the underlying stream might have been returned by r.
+func (s *stateTrackingStream) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{s}, r)
+}
+
 func (s *stateTrackingStream) CancelRead(e quic.StreamErrorCode) {
 	s.closeReceive(&quic.StreamError{StreamID: s.Stream.StreamID(), ErrorCode: e})
 	s.Stream.CancelRead(e)
@@ -114,3 +129,11 @@ func (s *stateTrackingStream) Read(b []byte) (int, error) {
 	}
 	return n, err
 }
+
+func (s *stateTrackingStream) Peek() ([]byte, error) {
+	b, err := s.Stream.Peek()
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		s.closeReceive(err)
+	}
+	return b, err
+}
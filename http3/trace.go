@@ -0,0 +1,151 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/logging"
+)
+
+// The hooks of a httptrace.ClientTrace are optional, and the trace itself may be nil.
+// The functions in this file only call a hook if it is set.
+
+func traceGetConn(trace *httptrace.ClientTrace, hostPort string) {
+	if trace != nil && trace.GetConn != nil {
+		trace.GetConn(hostPort)
+	}
+}
+
+func traceGotConn(trace *httptrace.ClientTrace, conn quic.Connection, reused bool) {
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: &traceConn{conn: conn}, Reused: reused})
+	}
+}
+
+func traceConnectStart(trace *httptrace.ClientTrace, network, addr string) {
+	if trace != nil && trace.ConnectStart != nil {
+		trace.ConnectStart(network, addr)
+	}
+}
+
+func traceConnectDone(trace *httptrace.ClientTrace, network, addr string, err error) {
+	if trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone(network, addr, err)
+	}
+}
+
+func traceTLSHandshakeStart(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+}
+
+func traceTLSHandshakeDone(trace *httptrace.ClientTrace, conn quic.Connection, err error) {
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		var state tls.ConnectionState
+		if err == nil {
+			state = conn.ConnectionState().TLS
+		}
+		trace.TLSHandshakeDone(state, err)
+	}
+}
+
+// traceHandshake calls the TLS handshake hooks of the trace for a connection dialed using conf.
+// It returns the config to dial with, and a function that must be called with the result of the dial.
+// TLSHandshakeStart is called once the first Initial packet was sent,
+// and TLSHandshakeDone once the handshake completed, or the dial failed.
+func traceHandshake(trace *httptrace.ClientTrace, conf *quic.Config) (*quic.Config, func(quic.EarlyConnection, error)) {
+	if trace == nil || (trace.TLSHandshakeStart == nil && trace.TLSHandshakeDone == nil) {
+		return conf, func(quic.EarlyConnection, error) {}
+	}
+	if conf == nil {
+		conf = &quic.Config{}
+	} else {
+		conf = conf.Clone()
+	}
+	var started atomic.Bool
+	connTracer := &logging.ConnectionTracer{
+		SentLongHeaderPacket: func(hdr *logging.ExtendedHeader, _ logging.ByteCount, _ logging.ECN, _ *logging.AckFrame, _ []logging.Frame) {
+			if logging.PacketTypeFromHeader(&hdr.Header) == logging.PacketTypeInitial && started.CompareAndSwap(false, true) {
+				traceTLSHandshakeStart(trace)
+			}
+		},
+	}
+	if tracer := conf.Tracer; tracer != nil {
+		conf.Tracer = func(ctx context.Context, p logging.Perspective, connID quic.ConnectionID) *logging.ConnectionTracer {
+			if t := tracer(ctx, p, connID); t != nil {
+				return logging.NewMultiplexedConnectionTracer(t, connTracer)
+			}
+			return connTracer
+		}
+	} else {
+		conf.Tracer = func(context.Context, logging.Perspective, quic.ConnectionID) *logging.ConnectionTracer {
+			return connTracer
+		}
+	}
+	return conf, func(conn quic.EarlyConnection, err error) {
+		if err != nil {
+			if started.Load() {
+				traceTLSHandshakeDone(trace, nil, err)
+			}
+			return
+		}
+		select {
+		case <-conn.HandshakeComplete():
+			traceTLSHandshakeDone(trace, conn, nil)
+		default:
+			// The connection can be used for 0-RTT requests before the handshake completes.
+			go func() {
+				select {
+				case <-conn.HandshakeComplete():
+					traceTLSHandshakeDone(trace, conn, nil)
+				case <-conn.Context().Done():
+					traceTLSHandshakeDone(trace, conn, context.Cause(conn.Context()))
+				}
+			}()
+		}
+	}
+}
+
+func traceWroteHeaders(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.WroteHeaders != nil {
+		trace.WroteHeaders()
+	}
+}
+
+func traceWroteRequest(trace *httptrace.ClientTrace, err error) {
+	if trace != nil && trace.WroteRequest != nil {
+		trace.WroteRequest(httptrace.WroteRequestInfo{Err: err})
+	}
+}
+
+func traceGot100Continue(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.Got100Continue != nil {
+		trace.Got100Continue()
+	}
+}
+
+var errTraceConn = errors.New("http3: the QUIC connection can't be used as a net.Conn")
+
+// traceConn is passed to httptrace.ClientTrace.GotConn, which expects a net.Conn.
+// It exposes the addresses of the QUIC connection, but it can't be used to send or receive data.
+type traceConn struct {
+	conn quic.Connection
+}
+
+var _ net.Conn = &traceConn{}
+
+func (c *traceConn) Read([]byte) (int, error)         { return 0, errTraceConn }
+func (c *traceConn) Write([]byte) (int, error)        { return 0, errTraceConn }
+func (c *traceConn) Close() error                     { return errTraceConn }
+func (c *traceConn) LocalAddr() net.Addr              { return c.conn.LocalAddr() }
+func (c *traceConn) RemoteAddr() net.Addr             { return c.conn.RemoteAddr() }
+func (c *traceConn) SetDeadline(time.Time) error      { return errTraceConn }
+func (c *traceConn) SetReadDeadline(time.Time) error  { return errTraceConn }
+func (c *traceConn) SetWriteDeadline(time.Time) error { return errTraceConn }
@@ -0,0 +1,993 @@
+package http3
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/quicvarint"
+)
+
+// The value of the :protocol pseudo header of the Extended CONNECT request that establishes a WebTransport session.
+const webTransportProtocol = "webtransport"
+
+const (
+	// the frame type that starts a bidirectional WebTransport stream, see section 4.2 of draft-ietf-webtrans-http3-02
+	frameTypeWebTransportStream = 0x41
+	// the stream type of a unidirectional WebTransport stream, see section 4.1 of draft-ietf-webtrans-http3-02
+	streamTypeWebTransportStream = 0x54
+)
+
+// capsuleTypeCloseWebTransportSession is the type of the CLOSE_WEBTRANSPORT_SESSION capsule.
+const capsuleTypeCloseWebTransportSession CapsuleType = 0x2843
+
+// maxWebTransportCloseMessageLen is the maximum length of the message sent in a CLOSE_WEBTRANSPORT_SESSION capsule.
+const maxWebTransportCloseMessageLen = 1024
+
+// HTTP/3 error codes defined by draft-ietf-webtrans-http3
+const (
+	errCodeWebTransportBufferedStreamRejected quic.StreamErrorCode = 0x3994bd84
+	errCodeWebTransportSessionGone            quic.StreamErrorCode = 0x170d7b68
+)
+
+// WebTransport stream error codes are mapped onto this range of HTTP/3 error codes.
+const (
+	firstWebTransportErrorCode = 0x52e4a40fa8db
+	lastWebTransportErrorCode  = 0x52e5ac983162
+)
+
+// maxBufferedWebTransportStreams is the maximum number of streams buffered per connection
+// while waiting for the WebTransport session they belong to to be established.
+const maxBufferedWebTransportStreams = 16
+
+const (
+	defaultWebTransportMaxIncomingStreams    = 100
+	defaultWebTransportMaxIncomingUniStreams = 100
+)
+
+// ErrWebTransportNotSupported is returned when dialing a WebTransport session
+// to a server that didn't enable WebTransport.
+var ErrWebTransportNotSupported = errors.New("http3: server doesn't support WebTransport")
+
+// WebTransportConfig configures the limits of WebTransport sessions.
+//
+// draft-ietf-webtrans-http3-02 doesn't define flow control for WebTransport sessions,
+// so these limits are enforced locally, by resetting the streams that exceed them.
+// Unless MaxSessionReceiveBuffer is set, data received on the streams of a session is only limited by QUIC flow control:
+// Every stream buffers up to quic.Config.MaxStreamReceiveWindow bytes,
+// and all sessions on a connection share the connection's receive window,
+// so a single session can use up the receive window of the whole connection.
+// HTTP datagrams are queued per session, up to 32 datagrams and 32 KB.
+type WebTransportConfig struct {
+	// MaxIncomingStreams is the maximum number of bidirectional streams
+	// that the peer may have open concurrently in a single session.
+	// Streams exceeding this limit are reset.
+	// If zero, a default value of 100 is used.
+	MaxIncomingStreams int
+	// MaxIncomingUniStreams is the maximum number of unidirectional streams
+	// that the peer may have open concurrently in a single session.
+	// Streams exceeding this limit are reset.
+	// If zero, a default value of 100 is used.
+	MaxIncomingUniStreams int
+	// MaxSessionReceiveBuffer is the maximum number of bytes buffered per session,
+	// i.e. data received on the streams of the session that the application hasn't read yet.
+	// If set, stream data is read from the QUIC connection as soon as it arrives,
+	// such that a session can't use up the receive window of the connection.
+	// The stream that exceeds the limit is reset with H3_EXCESSIVE_LOAD,
+	// other streams of the session and other sessions on the same connection are not affected.
+	// If zero, stream data is only limited by QUIC flow control.
+	MaxSessionReceiveBuffer int
+}
+
+func (c *WebTransportConfig) maxIncomingStreams() int {
+	if c == nil || c.MaxIncomingStreams <= 0 {
+		return defaultWebTransportMaxIncomingStreams
+	}
+	return c.MaxIncomingStreams
+}
+
+func (c *WebTransportConfig) maxIncomingUniStreams() int {
+	if c == nil || c.MaxIncomingUniStreams <= 0 {
+		return defaultWebTransportMaxIncomingUniStreams
+	}
+	return c.MaxIncomingUniStreams
+}
+
+func (c *WebTransportConfig) maxSessionReceiveBuffer() int {
+	if c == nil || c.MaxSessionReceiveBuffer <= 0 {
+		return 0
+	}
+	return c.MaxSessionReceiveBuffer
+}
+
+// WebTransportSessionErrorCode is the application error code used when closing a WebTransport session.
+type WebTransportSessionErrorCode uint32
+
+// WebTransportStreamErrorCode is the application error code used when resetting a WebTransport stream.
+type WebTransportStreamErrorCode uint32
+
+// A WebTransportSessionError is returned when using a WebTransport session after it was closed,
+// either locally by calling CloseWithError, or by the peer.
+type WebTransportSessionError struct {
+	Remote    bool
+	ErrorCode WebTransportSessionErrorCode
+	Message   string
+}
+
+var _ error = &WebTransportSessionError{}
+
+func (e *WebTransportSessionError) Error() string {
+	s := fmt.Sprintf("WebTransport session closed with error %d", e.ErrorCode)
+	if !e.Remote {
+		s += " (local)"
+	}
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	return s
+}
+
+// A WebTransportStreamError is returned when using a WebTransport stream after it was reset.
+type WebTransportStreamError struct {
+	Remote    bool
+	ErrorCode WebTransportStreamErrorCode
+}
+
+var _ error = &WebTransportStreamError{}
+
+func (e *WebTransportStreamError) Error() string {
+	s := fmt.Sprintf("WebTransport stream reset with error %d", e.ErrorCode)
+	if !e.Remote {
+		s += " (local)"
+	}
+	return s
+}
+
+// webTransportCodeToHTTPCode maps a WebTransport stream error code onto the HTTP/3 error code space,
+// skipping the reserved codepoints.
+func webTransportCodeToHTTPCode(n WebTransportStreamErrorCode) quic.StreamErrorCode {
+	return quic.StreamErrorCode(firstWebTransportErrorCode) + quic.StreamErrorCode(n) + quic.StreamErrorCode(n/0x1e)
+}
+
+func httpCodeToWebTransportCode(h quic.StreamErrorCode) (WebTransportStreamErrorCode, bool) {
+	if h < firstWebTransportErrorCode || h > lastWebTransportErrorCode {
+		return 0, false
+	}
+	// reserved codepoint
+	if (h-0x21)%0x1f == 0 {
+		return 0, false
+	}
+	shifted := h - firstWebTransportErrorCode
+	return WebTransportStreamErrorCode(shifted - shifted/0x1f), true
+}
+
+// UpgradeWebTransport establishes a WebTransport session from inside an http.Handler.
+// The request must be an Extended CONNECT request using the "webtransport" protocol,
+// and WebTransport must be enabled on the server.
+// Unless the handler already called WriteHeader, a 200 response is sent.
+func (s *Server) UpgradeWebTransport(w http.ResponseWriter, r *http.Request) (*WebTransportSession, error) {
+	if !isExtendedConnect(r) || r.Proto != webTransportProtocol {
+		return nil, errors.New("http3: not a WebTransport request")
+	}
+	m, ok := r.Context().Value(webTransportContextKey).(*webTransportManager)
+	if !ok || m == nil {
+		return nil, errors.New("http3: WebTransport not enabled")
+	}
+	str, err := AcceptExtendedConnect(w, r)
+	if err != nil {
+		return nil, err
+	}
+	return m.addSession(str), nil
+}
+
+// DialWebTransport establishes a WebTransport session with the server at urlStr.
+// It requires EnableWebTransport to be set,
+// and returns ErrWebTransportNotSupported if the server didn't enable WebTransport.
+// If the server doesn't respond with a 2xx status code, the response is returned with its body closed,
+// together with an error.
+func (r *RoundTripper) DialWebTransport(ctx context.Context, urlStr string, header http.Header) (*http.Response, *WebTransportSession, error) {
+	if !r.EnableWebTransport {
+		return nil, nil, errors.New("http3: WebTransport not enabled")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodConnect, urlStr, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header != nil {
+		req.Header = header.Clone()
+	}
+	rsp, str, err := r.ExtendedConnect(req, webTransportProtocol)
+	if err != nil {
+		return rsp, nil, err
+	}
+	body, ok := rsp.Body.(*hijackableBody)
+	if !ok || body.webTransport == nil {
+		str.Close()
+		return nil, nil, errors.New("http3: WebTransport not enabled on the connection")
+	}
+	return rsp, body.webTransport.addSession(str), nil
+}
+
+// webTransportManager associates the WebTransport streams received on a connection with their sessions.
+type webTransportManager struct {
+	conn   quic.Connection
+	config *WebTransportConfig
+	logger utils.Logger
+
+	mutex    sync.Mutex
+	sessions map[quic.StreamID]*WebTransportSession
+	// streams received before their session was established
+	buffered []bufferedWebTransportStream
+}
+
+type bufferedWebTransportStream struct {
+	sessionID quic.StreamID
+	str       quic.Stream        // set for bidirectional streams
+	uniStr    quic.ReceiveStream // set for unidirectional streams
+}
+
+func (b *bufferedWebTransportStream) reject(code quic.StreamErrorCode) {
+	if b.str != nil {
+		b.str.CancelRead(code)
+		b.str.CancelWrite(code)
+		return
+	}
+	b.uniStr.CancelRead(code)
+}
+
+func newWebTransportManager(conn quic.Connection, config *WebTransportConfig, logger utils.Logger) *webTransportManager {
+	return &webTransportManager{
+		conn:     conn,
+		config:   config,
+		logger:   logger,
+		sessions: make(map[quic.StreamID]*WebTransportSession),
+	}
+}
+
+// addSession establishes a WebTransport session on the CONNECT stream.
+// Streams that were received for this session before are passed to the session.
+func (m *webTransportManager) addSession(str *ExtendedConnectStream) *WebTransportSession {
+	sess := newWebTransportSession(str, m.conn, m.config, m.removeSession)
+	m.mutex.Lock()
+	m.sessions[sess.id] = sess
+	var buffered []bufferedWebTransportStream
+	n := 0
+	for _, b := range m.buffered {
+		if b.sessionID == sess.id {
+			buffered = append(buffered, b)
+			continue
+		}
+		m.buffered[n] = b
+		n++
+	}
+	clear(m.buffered[n:])
+	m.buffered = m.buffered[:n]
+	m.mutex.Unlock()
+
+	for _, b := range buffered {
+		if b.str != nil {
+			sess.addIncomingStream(b.str)
+		} else {
+			sess.addIncomingUniStream(b.uniStr)
+		}
+	}
+	return sess
+}
+
+func (m *webTransportManager) removeSession(id quic.StreamID) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.sessions, id)
+}
+
+// handleStream handles a bidirectional WebTransport stream, after its frame type was read.
+func (m *webTransportManager) handleStream(str quic.Stream) error {
+	id, err := quicvarint.Read(quicvarint.NewReader(str))
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	sess, ok := m.sessions[quic.StreamID(id)]
+	if !ok {
+		m.bufferStream(bufferedWebTransportStream{sessionID: quic.StreamID(id), str: str})
+		m.mutex.Unlock()
+		return nil
+	}
+	m.mutex.Unlock()
+	sess.addIncomingStream(str)
+	return nil
+}
+
+// handleUniStream handles a unidirectional WebTransport stream, after its stream type was read.
+func (m *webTransportManager) handleUniStream(str quic.ReceiveStream) {
+	id, err := quicvarint.Read(quicvarint.NewReader(str))
+	if err != nil {
+		m.logger.Debugf("reading the session ID on stream %d failed: %s", str.StreamID(), err)
+		str.CancelRead(quic.StreamErrorCode(ErrCodeGeneralProtocolError))
+		return
+	}
+	m.mutex.Lock()
+	sess, ok := m.sessions[quic.StreamID(id)]
+	if !ok {
+		m.bufferStream(bufferedWebTransportStream{sessionID: quic.StreamID(id), uniStr: str})
+		m.mutex.Unlock()
+		return
+	}
+	m.mutex.Unlock()
+	sess.addIncomingUniStream(str)
+}
+
+// bufferStream buffers a stream until its session is established.
+// If too many streams are buffered already, the oldest one is rejected.
+// It must be called with the mutex held.
+func (m *webTransportManager) bufferStream(b bufferedWebTransportStream) {
+	if len(m.buffered) >= maxBufferedWebTransportStreams {
+		m.buffered[0].reject(errCodeWebTransportBufferedStreamRejected)
+		copy(m.buffered, m.buffered[1:])
+		m.buffered = m.buffered[:len(m.buffered)-1]
+	}
+	m.buffered = append(m.buffered, b)
+}
+
+// A WebTransportSession is a WebTransport session, established by an Extended CONNECT request.
+// On the server side, sessions are established using Server.UpgradeWebTransport.
+// On the client side, sessions are established using RoundTripper.DialWebTransport.
+type WebTransportSession struct {
+	id       quic.StreamID // the stream ID of the CONNECT stream
+	str      *ExtendedConnectStream
+	conn     quic.Connection
+	onClosed func(quic.StreamID)
+
+	maxIncomingStreams    int
+	maxIncomingUniStreams int
+	maxReceiveBuffer      int // 0 if the stream data buffered is not limited
+	acceptQueue           chan *WebTransportStream
+	acceptUniQueue        chan *WebTransportReceiveStream
+
+	ctx       context.Context
+	ctxCancel context.CancelCauseFunc
+
+	mutex                 sync.Mutex
+	closed                bool
+	numIncomingStreams    int
+	numIncomingUniStreams int
+	receiveBuffered       int // stream data buffered in webTransportReceiveBuffers
+	// all streams of the session, they are reset when the session is closed
+	streams map[quic.StreamID]func(quic.StreamErrorCode)
+}
+
+func newWebTransportSession(str *ExtendedConnectStream, conn quic.Connection, config *WebTransportConfig, onClosed func(quic.StreamID)) *WebTransportSession {
+	s := &WebTransportSession{
+		id:                    str.StreamID(),
+		str:                   str,
+		conn:                  conn,
+		onClosed:              onClosed,
+		maxIncomingStreams:    config.maxIncomingStreams(),
+		maxIncomingUniStreams: config.maxIncomingUniStreams(),
+		maxReceiveBuffer:      config.maxSessionReceiveBuffer(),
+		streams:               make(map[quic.StreamID]func(quic.StreamErrorCode)),
+	}
+	// The number of streams waiting to be accepted is limited by the number of concurrently open incoming streams,
+	// so sending on these channels never blocks.
+	s.acceptQueue = make(chan *WebTransportStream, s.maxIncomingStreams)
+	s.acceptUniQueue = make(chan *WebTransportReceiveStream, s.maxIncomingUniStreams)
+	s.ctx, s.ctxCancel = context.WithCancelCause(context.Background())
+	go s.handleConnectStream()
+	return s
+}
+
+// handleConnectStream reads the capsules sent on the CONNECT stream, until the session is closed.
+func (s *WebTransportSession) handleConnectStream() {
+	r := quicvarint.NewReader(s.str)
+	for {
+		ct, cr, err := ParseCapsule(r)
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				// the peer closed the CONNECT stream without sending a CLOSE_WEBTRANSPORT_SESSION capsule
+				err = &WebTransportSessionError{Remote: true}
+			}
+			s.closeWithError(err)
+			return
+		}
+		switch ct {
+		case capsuleTypeCloseWebTransportSession:
+			b, err := io.ReadAll(io.LimitReader(cr, 4+maxWebTransportCloseMessageLen+1))
+			if err == nil && (len(b) < 4 || len(b) > 4+maxWebTransportCloseMessageLen) {
+				err = fmt.Errorf("invalid length for CLOSE_WEBTRANSPORT_SESSION capsule: %d", len(b))
+			}
+			if err != nil {
+				s.closeWithError(err)
+				s.str.str.CancelWrite(quic.StreamErrorCode(ErrCodeMessageError))
+				s.str.str.CancelRead(quic.StreamErrorCode(ErrCodeMessageError))
+				return
+			}
+			s.closeWithError(&WebTransportSessionError{
+				Remote:    true,
+				ErrorCode: WebTransportSessionErrorCode(binary.BigEndian.Uint32(b[:4])),
+				Message:   string(b[4:]),
+			})
+			s.str.Close()
+			return
+		default:
+			// Capsules of unknown type are skipped, see section 3.2 of RFC 9297.
+			if _, err := io.Copy(io.Discard, cr); err != nil {
+				s.closeWithError(err)
+				return
+			}
+		}
+	}
+}
+
+// closeWithError closes the session, resetting all of its streams.
+// It returns false if the session was already closed.
+func (s *WebTransportSession) closeWithError(err error) bool {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return false
+	}
+	s.closed = true
+	streams := s.streams
+	s.streams = nil
+	s.mutex.Unlock()
+
+	s.ctxCancel(err)
+	s.str.closeDatagrams(err)
+	for _, reset := range streams {
+		reset(errCodeWebTransportSessionGone)
+	}
+	s.onClosed(s.id)
+	return true
+}
+
+// CloseWithError closes the session, and sends a CLOSE_WEBTRANSPORT_SESSION capsule to the peer.
+// All streams of the session are reset.
+// Messages longer than 1024 bytes are truncated.
+func (s *WebTransportSession) CloseWithError(code WebTransportSessionErrorCode, msg string) error {
+	if len(msg) > maxWebTransportCloseMessageLen {
+		msg = msg[:maxWebTransportCloseMessageLen]
+	}
+	if !s.closeWithError(&WebTransportSessionError{ErrorCode: code, Message: msg}) {
+		return nil
+	}
+	b := make([]byte, 0, 16+4+len(msg))
+	b = quicvarint.Append(b, uint64(capsuleTypeCloseWebTransportSession))
+	b = quicvarint.Append(b, uint64(4+len(msg)))
+	b = binary.BigEndian.AppendUint32(b, uint32(code))
+	b = append(b, msg...)
+	if _, err := s.str.Write(b); err != nil {
+		s.str.Close()
+		return err
+	}
+	return s.str.Close()
+}
+
+// Context returns a context that is cancelled when the session is closed.
+func (s *WebTransportSession) Context() context.Context {
+	return s.ctx
+}
+
+// trackStream starts tracking a stream, such that it can be reset when the session is closed.
+// It returns false if the session is already closed.
+// It must be called with the mutex held.
+func (s *WebTransportSession) trackStream(id quic.StreamID, reset func(quic.StreamErrorCode)) bool {
+	if s.closed {
+		return false
+	}
+	s.streams[id] = reset
+	return true
+}
+
+func (s *WebTransportSession) streamDone(id quic.StreamID, incoming, bidi bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	delete(s.streams, id)
+	if incoming && bidi {
+		s.numIncomingStreams--
+	} else if incoming {
+		s.numIncomingUniStreams--
+	}
+}
+
+func resetStream(r webTransportStreamReader, w quic.SendStream) func(quic.StreamErrorCode) {
+	return func(code quic.StreamErrorCode) {
+		r.CancelRead(code)
+		w.CancelWrite(code)
+	}
+}
+
+// newStreamReader returns the reader for the data received on a stream.
+// If the stream data buffered per session is limited, the data is read as soon as it arrives.
+func (s *WebTransportSession) newStreamReader(str quic.ReceiveStream) webTransportStreamReader {
+	if s.maxReceiveBuffer == 0 {
+		return str
+	}
+	return newWebTransportReceiveBuffer(str, s)
+}
+
+// reserveReceiveBuffer accounts for n bytes of stream data being buffered.
+// It returns false if this would exceed the session's receive buffer.
+func (s *WebTransportSession) reserveReceiveBuffer(n int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.receiveBuffered+n > s.maxReceiveBuffer {
+		return false
+	}
+	s.receiveBuffered += n
+	return true
+}
+
+func (s *WebTransportSession) releaseReceiveBuffer(n int) {
+	s.mutex.Lock()
+	s.receiveBuffered -= n
+	s.mutex.Unlock()
+}
+
+func (s *WebTransportSession) addIncomingStream(str quic.Stream) {
+	id := str.StreamID()
+	r := s.newStreamReader(str)
+	reset := resetStream(r, str)
+	s.mutex.Lock()
+	if s.numIncomingStreams >= s.maxIncomingStreams {
+		s.mutex.Unlock()
+		reset(errCodeWebTransportBufferedStreamRejected)
+		return
+	}
+	if !s.trackStream(id, reset) {
+		s.mutex.Unlock()
+		reset(errCodeWebTransportSessionGone)
+		return
+	}
+	s.numIncomingStreams++
+	s.mutex.Unlock()
+	s.acceptQueue <- newWebTransportStream(str, r, s, func() { s.streamDone(id, true, true) })
+}
+
+func (s *WebTransportSession) addIncomingUniStream(str quic.ReceiveStream) {
+	id := str.StreamID()
+	r := s.newStreamReader(str)
+	s.mutex.Lock()
+	if s.numIncomingUniStreams >= s.maxIncomingUniStreams {
+		s.mutex.Unlock()
+		r.CancelRead(errCodeWebTransportBufferedStreamRejected)
+		return
+	}
+	if !s.trackStream(id, r.CancelRead) {
+		s.mutex.Unlock()
+		r.CancelRead(errCodeWebTransportSessionGone)
+		return
+	}
+	s.numIncomingUniStreams++
+	s.mutex.Unlock()
+	s.acceptUniQueue <- newWebTransportReceiveStream(r, s, func() { s.streamDone(id, true, false) })
+}
+
+// AcceptStream accepts the next bidirectional stream opened by the peer.
+func (s *WebTransportSession) AcceptStream(ctx context.Context) (*WebTransportStream, error) {
+	if s.ctx.Err() != nil {
+		return nil, context.Cause(s.ctx)
+	}
+	select {
+	case str := <-s.acceptQueue:
+		return str, nil
+	case <-s.ctx.Done():
+		return nil, context.Cause(s.ctx)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// AcceptUniStream accepts the next unidirectional stream opened by the peer.
+func (s *WebTransportSession) AcceptUniStream(ctx context.Context) (*WebTransportReceiveStream, error) {
+	if s.ctx.Err() != nil {
+		return nil, context.Cause(s.ctx)
+	}
+	select {
+	case str := <-s.acceptUniQueue:
+		return str, nil
+	case <-s.ctx.Done():
+		return nil, context.Cause(s.ctx)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// OpenStream opens a new bidirectional stream, without blocking.
+func (s *WebTransportSession) OpenStream() (*WebTransportStream, error) {
+	if s.ctx.Err() != nil {
+		return nil, context.Cause(s.ctx)
+	}
+	str, err := s.conn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return s.initStream(str)
+}
+
+// OpenStreamSync opens a new bidirectional stream.
+// It blocks until the peer's stream limit allows opening the stream.
+func (s *WebTransportSession) OpenStreamSync(ctx context.Context) (*WebTransportStream, error) {
+	if s.ctx.Err() != nil {
+		return nil, context.Cause(s.ctx)
+	}
+	str, err := s.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.initStream(str)
+}
+
+func (s *WebTransportSession) initStream(str quic.Stream) (*WebTransportStream, error) {
+	b := make([]byte, 0, 16)
+	b = quicvarint.Append(b, frameTypeWebTransportStream)
+	b = quicvarint.Append(b, uint64(s.id))
+	if _, err := str.Write(b); err != nil {
+		resetStream(str, str)(errCodeWebTransportSessionGone)
+		return nil, err
+	}
+	id := str.StreamID()
+	r := s.newStreamReader(str)
+	reset := resetStream(r, str)
+	s.mutex.Lock()
+	ok := s.trackStream(id, reset)
+	s.mutex.Unlock()
+	if !ok {
+		reset(errCodeWebTransportSessionGone)
+		return nil, context.Cause(s.ctx)
+	}
+	return newWebTransportStream(str, r, s, func() { s.streamDone(id, false, true) }), nil
+}
+
+// OpenUniStream opens a new unidirectional stream, without blocking.
+func (s *WebTransportSession) OpenUniStream() (*WebTransportSendStream, error) {
+	if s.ctx.Err() != nil {
+		return nil, context.Cause(s.ctx)
+	}
+	str, err := s.conn.OpenUniStream()
+	if err != nil {
+		return nil, err
+	}
+	return s.initUniStream(str)
+}
+
+// OpenUniStreamSync opens a new unidirectional stream.
+// It blocks until the peer's stream limit allows opening the stream.
+func (s *WebTransportSession) OpenUniStreamSync(ctx context.Context) (*WebTransportSendStream, error) {
+	if s.ctx.Err() != nil {
+		return nil, context.Cause(s.ctx)
+	}
+	str, err := s.conn.OpenUniStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.initUniStream(str)
+}
+
+func (s *WebTransportSession) initUniStream(str quic.SendStream) (*WebTransportSendStream, error) {
+	b := make([]byte, 0, 16)
+	b = quicvarint.Append(b, streamTypeWebTransportStream)
+	b = quicvarint.Append(b, uint64(s.id))
+	if _, err := str.Write(b); err != nil {
+		str.CancelWrite(errCodeWebTransportSessionGone)
+		return nil, err
+	}
+	id := str.StreamID()
+	s.mutex.Lock()
+	ok := s.trackStream(id, str.CancelWrite)
+	s.mutex.Unlock()
+	if !ok {
+		str.CancelWrite(errCodeWebTransportSessionGone)
+		return nil, context.Cause(s.ctx)
+	}
+	return newWebTransportSendStream(str, s, func() { s.streamDone(id, false, false) }), nil
+}
+
+// SendDatagram sends an HTTP datagram associated with this session.
+func (s *WebTransportSession) SendDatagram(b []byte) error {
+	return s.str.SendDatagram(b)
+}
+
+// ReceiveDatagram receives the next HTTP datagram associated with this session.
+func (s *WebTransportSession) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	return s.str.ReceiveDatagram(ctx)
+}
+
+func (s *WebTransportSession) LocalAddr() net.Addr  { return s.conn.LocalAddr() }
+func (s *WebTransportSession) RemoteAddr() net.Addr { return s.conn.RemoteAddr() }
+
+// convertStreamError converts stream errors into WebTransport errors.
+func (s *WebTransportSession) convertStreamError(err error) error {
+	var strErr *quic.StreamError
+	if err == nil || !errors.As(err, &strErr) {
+		return err
+	}
+	if strErr.ErrorCode == errCodeWebTransportSessionGone && s.ctx.Err() != nil {
+		return context.Cause(s.ctx)
+	}
+	if code, ok := httpCodeToWebTransportCode(strErr.ErrorCode); ok {
+		return &WebTransportStreamError{Remote: strErr.Remote, ErrorCode: code}
+	}
+	return err
+}
+
+// A WebTransportSendStream is a unidirectional WebTransport stream opened by us,
+// or the send direction of a bidirectional WebTransport stream.
+type WebTransportSendStream struct {
+	str  quic.SendStream
+	sess *WebTransportSession
+
+	onDone   func()
+	doneOnce sync.Once
+}
+
+func newWebTransportSendStream(str quic.SendStream, sess *WebTransportSession, onDone func()) *WebTransportSendStream {
+	return &WebTransportSendStream{str: str, sess: sess, onDone: onDone}
+}
+
+func (s *WebTransportSendStream) done() { s.doneOnce.Do(s.onDone) }
+
+func (s *WebTransportSendStream) Write(b []byte) (int, error) {
+	n, err := s.str.Write(b)
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		s.done()
+	}
+	return n, s.sess.convertStreamError(err)
+}
+
+// Close closes the send direction of the stream.
+func (s *WebTransportSendStream) Close() error {
+	s.done()
+	return s.sess.convertStreamError(s.str.Close())
+}
+
+// CancelWrite resets the send direction of the stream.
+func (s *WebTransportSendStream) CancelWrite(code WebTransportStreamErrorCode) {
+	s.done()
+	s.str.CancelWrite(webTransportCodeToHTTPCode(code))
+}
+
+func (s *WebTransportSendStream) SetWriteDeadline(t time.Time) error {
+	return s.str.SetWriteDeadline(t)
+}
+
+func (s *WebTransportSendStream) StreamID() quic.StreamID { return s.str.StreamID() }
+
+// A webTransportStreamReader reads the data received on a WebTransport stream.
+// It is either the QUIC stream itself, or a webTransportReceiveBuffer.
+type webTransportStreamReader interface {
+	io.Reader
+	CancelRead(quic.StreamErrorCode)
+	SetReadDeadline(time.Time) error
+	StreamID() quic.StreamID
+}
+
+// webTransportReceiveBufferChunkSize is the size of the chunks read from the QUIC stream.
+const webTransportReceiveBufferChunkSize = 4096
+
+// A webTransportReceiveBuffer reads the data received on a stream as soon as it arrives,
+// and buffers it until the application reads it.
+// The buffered data is accounted for by the session, and the stream is reset
+// when it would exceed the session's receive buffer.
+type webTransportReceiveBuffer struct {
+	str  quic.ReceiveStream
+	sess *WebTransportSession
+
+	// signaled when data is buffered, an error occurs, or the deadline is changed
+	signal chan struct{}
+
+	mutex    sync.Mutex
+	data     []byte
+	readPos  int
+	err      error // the error returned once all data has been read, e.g. io.EOF
+	deadline time.Time
+}
+
+var _ webTransportStreamReader = &webTransportReceiveBuffer{}
+
+func newWebTransportReceiveBuffer(str quic.ReceiveStream, sess *WebTransportSession) *webTransportReceiveBuffer {
+	b := &webTransportReceiveBuffer{
+		str:    str,
+		sess:   sess,
+		signal: make(chan struct{}, 1),
+	}
+	go b.run()
+	return b
+}
+
+func (b *webTransportReceiveBuffer) run() {
+	buf := make([]byte, webTransportReceiveBufferChunkSize)
+	for {
+		n, err := b.str.Read(buf)
+		if n > 0 && !b.push(buf[:n]) {
+			return
+		}
+		if err != nil {
+			b.mutex.Lock()
+			if b.err == nil {
+				b.err = err
+			}
+			b.mutex.Unlock()
+			b.notify()
+			return
+		}
+	}
+}
+
+// push buffers data read from the stream.
+// It returns false if the stream was canceled, or if it was reset because it exceeded the session's receive buffer.
+func (b *webTransportReceiveBuffer) push(data []byte) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.err != nil {
+		return false
+	}
+	if !b.sess.reserveReceiveBuffer(len(data)) {
+		b.str.CancelRead(quic.StreamErrorCode(ErrCodeExcessiveLoad))
+		b.closeWithError(&quic.StreamError{StreamID: b.str.StreamID(), ErrorCode: quic.StreamErrorCode(ErrCodeExcessiveLoad)})
+		return false
+	}
+	b.data = append(b.data, data...)
+	b.notify()
+	return true
+}
+
+// closeWithError drops all buffered data, and makes all future calls to Read return err.
+// It must be called with the mutex held.
+func (b *webTransportReceiveBuffer) closeWithError(err error) {
+	if b.err != nil && len(b.data) == b.readPos {
+		return
+	}
+	b.sess.releaseReceiveBuffer(len(b.data) - b.readPos)
+	b.data = nil
+	b.readPos = 0
+	b.err = err
+	b.notify()
+}
+
+func (b *webTransportReceiveBuffer) notify() {
+	select {
+	case b.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (b *webTransportReceiveBuffer) Read(p []byte) (int, error) {
+	for {
+		b.mutex.Lock()
+		if b.readPos < len(b.data) {
+			n := copy(p, b.data[b.readPos:])
+			b.readPos += n
+			if b.readPos == len(b.data) {
+				// Keep the backing array around, unless it grew beyond the size of a single chunk.
+				if cap(b.data) > webTransportReceiveBufferChunkSize {
+					b.data = nil
+				} else {
+					b.data = b.data[:0]
+				}
+				b.readPos = 0
+			}
+			b.sess.releaseReceiveBuffer(n)
+			b.mutex.Unlock()
+			return n, nil
+		}
+		if b.err != nil {
+			err := b.err
+			b.mutex.Unlock()
+			return 0, err
+		}
+		deadline := b.deadline
+		b.mutex.Unlock()
+
+		if deadline.IsZero() {
+			<-b.signal
+			continue
+		}
+		d := time.Until(deadline)
+		if d <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-b.signal:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (b *webTransportReceiveBuffer) CancelRead(code quic.StreamErrorCode) {
+	b.str.CancelRead(code)
+	b.mutex.Lock()
+	b.closeWithError(&quic.StreamError{StreamID: b.str.StreamID(), ErrorCode: code})
+	b.mutex.Unlock()
+}
+
+func (b *webTransportReceiveBuffer) SetReadDeadline(t time.Time) error {
+	b.mutex.Lock()
+	b.deadline = t
+	b.mutex.Unlock()
+	b.notify()
+	return nil
+}
+
+func (b *webTransportReceiveBuffer) StreamID() quic.StreamID { return b.str.StreamID() }
+
+// A WebTransportReceiveStream is a unidirectional WebTransport stream opened by the peer,
+// or the receive direction of a bidirectional WebTransport stream.
+type WebTransportReceiveStream struct {
+	str  webTransportStreamReader
+	sess *WebTransportSession
+
+	onDone   func()
+	doneOnce sync.Once
+}
+
+func newWebTransportReceiveStream(str webTransportStreamReader, sess *WebTransportSession, onDone func()) *WebTransportReceiveStream {
+	return &WebTransportReceiveStream{str: str, sess: sess, onDone: onDone}
+}
+
+func (s *WebTransportReceiveStream) done() { s.doneOnce.Do(s.onDone) }
+
+func (s *WebTransportReceiveStream) Read(b []byte) (int, error) {
+	n, err := s.str.Read(b)
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		s.done()
+	}
+	return n, s.sess.convertStreamError(err)
+}
+
+// CancelRead aborts receiving on the stream.
+func (s *WebTransportReceiveStream) CancelRead(code WebTransportStreamErrorCode) {
+	s.done()
+	s.str.CancelRead(webTransportCodeToHTTPCode(code))
+}
+
+func (s *WebTransportReceiveStream) SetReadDeadline(t time.Time) error {
+	return s.str.SetReadDeadline(t)
+}
+
+func (s *WebTransportReceiveStream) StreamID() quic.StreamID { return s.str.StreamID() }
+
+// A WebTransportStream is a bidirectional WebTransport stream.
+type WebTransportStream struct {
+	*WebTransportSendStream
+	*WebTransportReceiveStream
+}
+
+var _ io.ReadWriteCloser = &WebTransportStream{}
+
+// newWebTransportStream creates a new bidirectional stream, reading the data received on the stream from r.
+// onDone is called once both directions of the stream are done.
+func newWebTransportStream(str quic.Stream, r webTransportStreamReader, sess *WebTransportSession, onDone func()) *WebTransportStream {
+	var remaining atomic.Int32
+	remaining.Store(2)
+	done := func() {
+		if remaining.Add(-1) == 0 {
+			onDone()
+		}
+	}
+	return &WebTransportStream{
+		WebTransportSendStream:    newWebTransportSendStream(str, sess, done),
+		WebTransportReceiveStream: newWebTransportReceiveStream(r, sess, done),
+	}
+}
+
+func (s *WebTransportStream) StreamID() quic.StreamID { return s.WebTransportSendStream.StreamID() }
+
+func (s *WebTransportStream) SetDeadline(t time.Time) error {
+	_ = s.SetReadDeadline(t)
+	return s.SetWriteDeadline(t)
+}
@@ -0,0 +1,401 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	mockquic "github.com/nxenon/xquic-go/internal/mocks/quic"
+	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/quicvarint"
+
+	"go.uber.org/mock/gomock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebTransport", func() {
+	It("maps stream error codes", func() {
+		for _, n := range []WebTransportStreamErrorCode{0, 1, 0x1d, 0x1e, 0x1f, 1337, math.MaxUint32} {
+			h := webTransportCodeToHTTPCode(n)
+			Expect(uint64(h)).To(And(BeNumerically(">=", firstWebTransportErrorCode), BeNumerically("<=", lastWebTransportErrorCode)))
+			code, ok := httpCodeToWebTransportCode(h)
+			Expect(ok).To(BeTrue())
+			Expect(code).To(Equal(n))
+		}
+		Expect(webTransportCodeToHTTPCode(0)).To(BeEquivalentTo(firstWebTransportErrorCode))
+		Expect(webTransportCodeToHTTPCode(math.MaxUint32)).To(BeEquivalentTo(lastWebTransportErrorCode))
+		// codes outside of the range
+		_, ok := httpCodeToWebTransportCode(firstWebTransportErrorCode - 1)
+		Expect(ok).To(BeFalse())
+		_, ok = httpCodeToWebTransportCode(lastWebTransportErrorCode + 1)
+		Expect(ok).To(BeFalse())
+		// reserved codepoints are skipped
+		for h := quic.StreamErrorCode(firstWebTransportErrorCode); h < firstWebTransportErrorCode+100; h++ {
+			code, ok := httpCodeToWebTransportCode(h)
+			if (h-0x21)%0x1f == 0 {
+				Expect(ok).To(BeFalse())
+				continue
+			}
+			Expect(ok).To(BeTrue())
+			Expect(webTransportCodeToHTTPCode(code)).To(Equal(h))
+		}
+	})
+
+	It("refuses to upgrade requests that are not WebTransport requests", func() {
+		s := &Server{}
+		req := httptest.NewRequest(http.MethodConnect, "https://example.com/wt", nil)
+		req.Proto = "websocket"
+		_, err := s.UpgradeWebTransport(httptest.NewRecorder(), req)
+		Expect(err).To(MatchError("http3: not a WebTransport request"))
+		req.Proto = webTransportProtocol
+		_, err = s.UpgradeWebTransport(httptest.NewRecorder(), req)
+		Expect(err).To(MatchError("http3: WebTransport not enabled"))
+	})
+
+	It("refuses to dial WebTransport sessions if WebTransport is not enabled", func() {
+		_, _, err := (&RoundTripper{}).DialWebTransport(context.Background(), "https://example.com/wt", nil)
+		Expect(err).To(MatchError("http3: WebTransport not enabled"))
+	})
+
+	Context("sessions", func() {
+		const sessionID = 8
+
+		var (
+			conn       *mockquic.MockEarlyConnection
+			router     *datagramRouter
+			manager    *webTransportManager
+			connectStr *mockquic.MockStream
+			pw         *io.PipeWriter
+		)
+
+		BeforeEach(func() {
+			conn = mockquic.NewMockEarlyConnection(mockCtrl)
+			router = newDatagramRouter(conn)
+			manager = newWebTransportManager(conn, nil, utils.DefaultLogger)
+			connectStr = mockquic.NewMockStream(mockCtrl)
+			connectStr.EXPECT().StreamID().Return(quic.StreamID(sessionID)).AnyTimes()
+			var pr *io.PipeReader
+			pr, pw = io.Pipe()
+			connectStr.EXPECT().Read(gomock.Any()).DoAndReturn(pr.Read).AnyTimes()
+		})
+
+		addSession := func() *WebTransportSession {
+			return manager.addSession(newExtendedConnectStream(newStream(connectStr, nil, func() {}), nil, nil, router))
+		}
+
+		// closeSession simulates the peer closing the CONNECT stream
+		closeSession := func(sess *WebTransportSession) {
+			pw.Close()
+			Eventually(sess.Context().Done()).Should(BeClosed())
+		}
+
+		newIncomingStream := func(id quic.StreamID, data []byte) *mockquic.MockStream {
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(id).AnyTimes()
+			r := bytes.NewReader(data)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(r.Read).AnyTimes()
+			return str
+		}
+
+		sessionHeader := func(id quic.StreamID) []byte {
+			return quicvarint.Append(nil, uint64(id))
+		}
+
+		It("opens bidirectional streams", func() {
+			sess := addSession()
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(4)).AnyTimes()
+			buf := &bytes.Buffer{}
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
+			conn.EXPECT().OpenStream().Return(str, nil)
+			wstr, err := sess.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wstr.StreamID()).To(Equal(quic.StreamID(4)))
+			_, err = wstr.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			b := quicvarint.Append(nil, frameTypeWebTransportStream)
+			b = quicvarint.Append(b, sessionID)
+			Expect(buf.Bytes()).To(Equal(append(b, []byte("foobar")...)))
+
+			// the stream is reset when the session is closed
+			str.EXPECT().CancelRead(errCodeWebTransportSessionGone)
+			str.EXPECT().CancelWrite(errCodeWebTransportSessionGone)
+			closeSession(sess)
+			_, err = sess.OpenStream()
+			Expect(err).To(MatchError(&WebTransportSessionError{Remote: true}))
+		})
+
+		It("opens unidirectional streams", func() {
+			sess := addSession()
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(2)).AnyTimes()
+			buf := &bytes.Buffer{}
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
+			conn.EXPECT().OpenUniStreamSync(gomock.Any()).Return(str, nil)
+			wstr, err := sess.OpenUniStreamSync(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			b := quicvarint.Append(nil, streamTypeWebTransportStream)
+			b = quicvarint.Append(b, sessionID)
+			Expect(buf.Bytes()).To(Equal(b))
+			str.EXPECT().Close()
+			Expect(wstr.Close()).To(Succeed())
+			// the stream is done, and is not reset when the session is closed
+			closeSession(sess)
+		})
+
+		It("accepts streams", func() {
+			sess := addSession()
+			str := newIncomingStream(1, append(sessionHeader(sessionID), []byte("foobar")...))
+			Expect(manager.handleStream(str)).To(Succeed())
+			wstr, err := sess.AcceptStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			data, err := io.ReadAll(wstr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+
+			uniStr := newIncomingStream(3, append(sessionHeader(sessionID), []byte("raboof")...))
+			manager.handleUniStream(uniStr)
+			wuniStr, err := sess.AcceptUniStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			data, err = io.ReadAll(wuniStr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("raboof")))
+
+			// the send direction of the bidirectional stream is still open
+			str.EXPECT().CancelRead(errCodeWebTransportSessionGone)
+			str.EXPECT().CancelWrite(errCodeWebTransportSessionGone)
+			closeSession(sess)
+		})
+
+		It("buffers streams until the session is established", func() {
+			str := newIncomingStream(1, sessionHeader(sessionID))
+			Expect(manager.handleStream(str)).To(Succeed())
+			uniStr := newIncomingStream(3, sessionHeader(sessionID))
+			manager.handleUniStream(uniStr)
+			// a stream for a different session
+			otherStr := newIncomingStream(5, sessionHeader(sessionID+4))
+			Expect(manager.handleStream(otherStr)).To(Succeed())
+
+			sess := addSession()
+			wstr, err := sess.AcceptStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wstr.StreamID()).To(Equal(quic.StreamID(1)))
+			wuniStr, err := sess.AcceptUniStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wuniStr.StreamID()).To(Equal(quic.StreamID(3)))
+			Expect(manager.buffered).To(HaveLen(1))
+
+			str.EXPECT().CancelRead(errCodeWebTransportSessionGone)
+			str.EXPECT().CancelWrite(errCodeWebTransportSessionGone)
+			uniStr.EXPECT().CancelRead(errCodeWebTransportSessionGone)
+			closeSession(sess)
+		})
+
+		It("rejects the oldest buffered stream when too many streams are buffered", func() {
+			first := newIncomingStream(1, sessionHeader(sessionID))
+			Expect(manager.handleStream(first)).To(Succeed())
+			for i := 1; i < maxBufferedWebTransportStreams; i++ {
+				manager.handleUniStream(newIncomingStream(quic.StreamID(4*i+3), sessionHeader(sessionID)))
+			}
+			Expect(manager.buffered).To(HaveLen(maxBufferedWebTransportStreams))
+			first.EXPECT().CancelRead(errCodeWebTransportBufferedStreamRejected)
+			first.EXPECT().CancelWrite(errCodeWebTransportBufferedStreamRejected)
+			manager.handleUniStream(newIncomingStream(1003, sessionHeader(sessionID)))
+			Expect(manager.buffered).To(HaveLen(maxBufferedWebTransportStreams))
+		})
+
+		It("limits the number of concurrently open incoming streams", func() {
+			manager.config = &WebTransportConfig{MaxIncomingUniStreams: 1}
+			sess := addSession()
+			str1 := newIncomingStream(3, sessionHeader(sessionID))
+			manager.handleUniStream(str1)
+			str2 := newIncomingStream(7, sessionHeader(sessionID))
+			str2.EXPECT().CancelRead(errCodeWebTransportBufferedStreamRejected)
+			manager.handleUniStream(str2)
+
+			wstr, err := sess.AcceptUniStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			str1.EXPECT().CancelRead(webTransportCodeToHTTPCode(42))
+			wstr.CancelRead(42)
+
+			// now that the first stream is done, a new stream can be opened
+			str3 := newIncomingStream(11, sessionHeader(sessionID))
+			manager.handleUniStream(str3)
+			wstr, err = sess.AcceptUniStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wstr.StreamID()).To(Equal(quic.StreamID(11)))
+
+			str3.EXPECT().CancelRead(errCodeWebTransportSessionGone)
+			closeSession(sess)
+		})
+
+		It("limits the stream data buffered per session", func() {
+			manager.config = &WebTransportConfig{MaxSessionReceiveBuffer: 10}
+			sess := addSession()
+			// another session on the same connection
+			otherConnectStr := mockquic.NewMockStream(mockCtrl)
+			otherConnectStr.EXPECT().StreamID().Return(quic.StreamID(sessionID + 4)).AnyTimes()
+			otherPR, otherPW := io.Pipe()
+			otherConnectStr.EXPECT().Read(gomock.Any()).DoAndReturn(otherPR.Read).AnyTimes()
+			otherSess := manager.addSession(newExtendedConnectStream(newStream(otherConnectStr, nil, func() {}), nil, nil, router))
+			receiveBuffered := func(s *WebTransportSession) int {
+				s.mutex.Lock()
+				defer s.mutex.Unlock()
+				return s.receiveBuffered
+			}
+
+			str := newIncomingStream(1, append(sessionHeader(sessionID), []byte("foobar")...))
+			Expect(manager.handleStream(str)).To(Succeed())
+			Eventually(func() int { return receiveBuffered(sess) }).Should(Equal(6))
+			// this stream exceeds the receive buffer of the session
+			uniStr := newIncomingStream(3, append(sessionHeader(sessionID), []byte("raboof")...))
+			canceled := make(chan struct{})
+			uniStr.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeExcessiveLoad)).Do(func(quic.StreamErrorCode) { close(canceled) })
+			manager.handleUniStream(uniStr)
+			Eventually(canceled).Should(BeClosed())
+			wuniStr, err := sess.AcceptUniStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			_, err = wuniStr.Read([]byte{0})
+			Expect(err).To(MatchError(&quic.StreamError{StreamID: 3, ErrorCode: quic.StreamErrorCode(ErrCodeExcessiveLoad)}))
+			Expect(receiveBuffered(sess)).To(Equal(6))
+
+			// the other session is not affected
+			otherStr := newIncomingStream(5, append(sessionHeader(sessionID+4), []byte("deadbeef")...))
+			Expect(manager.handleStream(otherStr)).To(Succeed())
+			wotherStr, err := otherSess.AcceptStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			data, err := io.ReadAll(wotherStr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("deadbeef")))
+
+			// reading the data frees up the receive buffer
+			wstr, err := sess.AcceptStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			data, err = io.ReadAll(wstr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+			Expect(receiveBuffered(sess)).To(BeZero())
+
+			str.EXPECT().CancelRead(errCodeWebTransportSessionGone)
+			str.EXPECT().CancelWrite(errCodeWebTransportSessionGone)
+			closeSession(sess)
+			otherStr.EXPECT().CancelRead(errCodeWebTransportSessionGone)
+			otherStr.EXPECT().CancelWrite(errCodeWebTransportSessionGone)
+			otherPW.Close()
+			Eventually(otherSess.Context().Done()).Should(BeClosed())
+		})
+
+		It("times out reading from buffered streams", func() {
+			manager.config = &WebTransportConfig{MaxSessionReceiveBuffer: 10}
+			sess := addSession()
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(3)).AnyTimes()
+			pr, pw := io.Pipe()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(pr.Read).AnyTimes()
+			go pw.Write(sessionHeader(sessionID))
+			manager.handleUniStream(str)
+			wstr, err := sess.AcceptUniStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wstr.SetReadDeadline(time.Now().Add(scaleDuration(10 * time.Millisecond)))).To(Succeed())
+			_, err = wstr.Read([]byte{0})
+			Expect(err).To(MatchError(os.ErrDeadlineExceeded))
+
+			Expect(wstr.SetReadDeadline(time.Time{})).To(Succeed())
+			go pw.Write([]byte("foo"))
+			b := make([]byte, 3)
+			_, err = io.ReadFull(wstr, b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foo")))
+
+			str.EXPECT().CancelRead(errCodeWebTransportSessionGone).Do(func(quic.StreamErrorCode) { pw.CloseWithError(errors.New("canceled")) })
+			closeSession(sess)
+		})
+
+		It("converts stream errors", func() {
+			sess := addSession()
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(3)).AnyTimes()
+			r := bytes.NewReader(sessionHeader(sessionID))
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				if r.Len() > 0 {
+					return r.Read(b)
+				}
+				return 0, &quic.StreamError{StreamID: 3, ErrorCode: webTransportCodeToHTTPCode(1337), Remote: true}
+			}).AnyTimes()
+			manager.handleUniStream(str)
+			wstr, err := sess.AcceptUniStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			_, err = wstr.Read([]byte{0})
+			Expect(err).To(MatchError(&WebTransportStreamError{Remote: true, ErrorCode: 1337}))
+			closeSession(sess)
+		})
+
+		It("sends and receives datagrams", func() {
+			sess := addSession()
+			conn.EXPECT().SendDatagram(append(quicvarint.Append(nil, sessionID/4), []byte("foobar")...))
+			Expect(sess.SendDatagram([]byte("foobar"))).To(Succeed())
+
+			Expect(router.handleDatagram(append(quicvarint.Append(nil, sessionID/4), []byte("raboof")...))).To(Succeed())
+			data, err := sess.ReceiveDatagram(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("raboof")))
+			closeSession(sess)
+		})
+
+		It("closes the session", func() {
+			sess := addSession()
+			str := newIncomingStream(1, sessionHeader(sessionID))
+			Expect(manager.handleStream(str)).To(Succeed())
+
+			buf := &bytes.Buffer{}
+			connectStr.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
+			connectStr.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeNoError))
+			connectStr.EXPECT().Close()
+			str.EXPECT().CancelRead(errCodeWebTransportSessionGone)
+			str.EXPECT().CancelWrite(errCodeWebTransportSessionGone)
+			Expect(sess.CloseWithError(1337, "foobar")).To(Succeed())
+			Expect(sess.Context().Done()).To(BeClosed())
+			_, err := sess.AcceptStream(context.Background())
+			Expect(err).To(MatchError(&WebTransportSessionError{ErrorCode: 1337, Message: "foobar"}))
+			_, err = sess.ReceiveDatagram(context.Background())
+			Expect(err).To(MatchError(&WebTransportSessionError{ErrorCode: 1337, Message: "foobar"}))
+			Expect(manager.sessions).To(BeEmpty())
+
+			// the CLOSE_WEBTRANSPORT_SESSION capsule is sent in a DATA frame
+			frame, err := parseNextFrame(buf, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&dataFrame{Length: uint64(buf.Len())}))
+			ct, r, err := ParseCapsule(quicvarint.NewReader(buf))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ct).To(Equal(capsuleTypeCloseWebTransportSession))
+			val, err := io.ReadAll(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(val).To(Equal(append([]byte{0, 0, 0x5, 0x39}, []byte("foobar")...)))
+			// closing again is a no-op
+			Expect(sess.CloseWithError(42, "")).To(Succeed())
+			pw.Close()
+		})
+
+		It("handles the CLOSE_WEBTRANSPORT_SESSION capsule sent by the peer", func() {
+			sess := addSession()
+			var capsule bytes.Buffer
+			Expect(WriteCapsule(&capsule, capsuleTypeCloseWebTransportSession, append([]byte{0, 0, 0, 42}, []byte("bye")...))).To(Succeed())
+			connectStr.EXPECT().CancelRead(quic.StreamErrorCode(ErrCodeNoError))
+			connectStr.EXPECT().Close()
+			_, err := pw.Write(append((&dataFrame{Length: uint64(capsule.Len())}).Append(nil), capsule.Bytes()...))
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(sess.Context().Done()).Should(BeClosed())
+			Expect(context.Cause(sess.Context())).To(MatchError(&WebTransportSessionError{Remote: true, ErrorCode: 42, Message: "bye"}))
+			pw.Close()
+		})
+	})
+})
@@ -1,10 +1,18 @@
 package self_test
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nxenon/xquic-go"
 )
@@ -90,3 +98,187 @@ func BenchmarkStreamChurn(b *testing.B) {
 		}
 	}
 }
+
+// The steady-state allocation benchmarks run the peer in a separate process,
+// such that only the allocations on the sending side are counted.
+const benchmarkPeerEnv = "QUIC_GO_BENCHMARK_PEER"
+
+// TestBenchmarkPeer runs the peer for the steady-state allocation benchmarks.
+// It accepts a connection, discards all stream data and datagrams it receives,
+// and exits once its stdin is closed.
+func TestBenchmarkPeer(t *testing.T) {
+	if os.Getenv(benchmarkPeerEnv) == "" {
+		t.Skip("only runs as the peer of the allocation benchmarks")
+	}
+	ln, err := quic.ListenAddr("localhost:0", tlsConfig, &quic.Config{EnableDatagrams: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	fmt.Println(ln.Addr())
+
+	go func() {
+		for {
+			conn, err := ln.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				for {
+					if _, err := conn.ReceiveDatagram(context.Background()); err != nil {
+						return
+					}
+				}
+			}()
+			go func() {
+				for {
+					str, err := conn.AcceptUniStream(context.Background())
+					if err != nil {
+						return
+					}
+					go io.Copy(io.Discard, str)
+				}
+			}()
+		}
+	}()
+	io.Copy(io.Discard, os.Stdin)
+}
+
+// startBenchmarkPeer starts the peer in a new process, and returns its address.
+func startBenchmarkPeer(b *testing.B) net.Addr {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestBenchmarkPeer$")
+	cmd.Env = append(os.Environ(), benchmarkPeerEnv+"=1")
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		b.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		stdin.Close()
+		cmd.Wait()
+	})
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		b.Fatal(err)
+	}
+	addr, err := net.ResolveUDPAddr("udp", strings.TrimSpace(line))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return addr
+}
+
+// maxAllocsPerPacket is the number of allocations per packet tolerated in steady state.
+// Sending a packet doesn't allocate, but some events that happen every few thousand packets do:
+// key updates derive new keys and create new AEADs, connection IDs are rotated
+// (see protocol.PacketsPerConnectionID), and sync.Pools occasionally grow their internal storage.
+// Amortized, this is well below 0.01 allocations per packet,
+// whereas a single allocation on the send path would amount to (at least) 1.
+const maxAllocsPerPacket = 0.01
+
+// benchmarkSteadyStateAllocs measures the number of allocations per packet sent,
+// once the connection has reached steady state, and fails if it exceeds maxAllocsPerPacket.
+// The GC is disabled during warmup and while measuring, since it empties the sync.Pools.
+func benchmarkSteadyStateAllocs(b *testing.B, warmup int, send func(quic.Connection) error) {
+	addr := startBenchmarkPeer(b)
+	udpConn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	tr := &quic.Transport{Conn: udpConn}
+	defer tr.Close()
+	// The peer uses a certificate issued by the CA generated in its own process.
+	tlsConf := getTLSClientConfig()
+	tlsConf.InsecureSkipVerify = true
+	conn, err := tr.Dial(context.Background(), addr, tlsConf, &quic.Config{EnableDatagrams: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.CloseWithError(0, "")
+
+	stats := func() (sent, received uint64) {
+		for _, c := range tr.Connections() {
+			sent += c.Stats.PacketsSent
+			received += c.Stats.PacketsReceived
+		}
+		return sent, received
+	}
+
+	runtime.GC()
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	for i := 0; i < warmup; i++ {
+		if err := send(conn); err != nil {
+			b.Fatal(err)
+		}
+	}
+	// Wait for the acknowledgements for the packets sent during warmup.
+	for _, r := stats(); ; {
+		time.Sleep(50 * time.Millisecond)
+		_, received := stats()
+		if received == r {
+			break
+		}
+		r = received
+	}
+
+	var ms1, ms2 runtime.MemStats
+	p1, _ := stats()
+	runtime.ReadMemStats(&ms1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := send(conn); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&ms2)
+	// Packets are sent asynchronously by the connection's run loop.
+	// For small values of b.N, they might not have been sent yet.
+	var packets uint64
+	for deadline := time.Now().Add(time.Second); packets == 0 && time.Now().Before(deadline); {
+		p2, _ := stats()
+		if packets = p2 - p1; packets == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if packets == 0 {
+		b.Fatal("no packets sent")
+	}
+	allocs := ms2.Mallocs - ms1.Mallocs
+	b.ReportMetric(float64(packets)/float64(b.N), "packets/op")
+	allocsPerPacket := float64(allocs) / float64(packets)
+	b.ReportMetric(allocsPerPacket, "allocs/packet")
+	if allocsPerPacket > maxAllocsPerPacket {
+		b.Fatalf("expected at most %g allocations per packet in steady state, got %d allocations for %d packets", maxAllocsPerPacket, allocs, packets)
+	}
+}
+
+func BenchmarkSteadyStateStream(b *testing.B) {
+	var str quic.SendStream
+	buf := make([]byte, 1<<16)
+	benchmarkSteadyStateAllocs(b, 5000, func(conn quic.Connection) error {
+		if str == nil {
+			var err error
+			str, err = conn.OpenUniStreamSync(context.Background())
+			if err != nil {
+				return err
+			}
+		}
+		_, err := str.Write(buf)
+		return err
+	})
+}
+
+func BenchmarkSteadyStateDatagram(b *testing.B) {
+	data := make([]byte, 1000)
+	benchmarkSteadyStateAllocs(b, 20000, func(conn quic.Connection) error {
+		return conn.SendDatagram(data)
+	})
+}
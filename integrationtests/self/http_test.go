@@ -10,6 +10,8 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"os"
 	"strconv"
 	"time"
@@ -228,6 +230,53 @@ var _ = Describe("HTTP tests", func() {
 		Expect(resp.Header.Get("lorem")).To(Equal("ipsum"))
 	})
 
+	It("sends trailers", func() {
+		mux.HandleFunc("/trailers", func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.Write([]byte("foobar"))
+			w.Header().Set("Grpc-Status", "0")
+			w.Header().Set(http.TrailerPrefix+"Grpc-Message", "ok")
+		})
+
+		resp, err := client.Get(fmt.Sprintf("https://localhost:%d/trailers", port))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(200))
+		Expect(resp.Header).ToNot(HaveKey("Grpc-Status"))
+		body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal("foobar"))
+		Expect(resp.Trailer.Get("Grpc-Status")).To(Equal("0"))
+		Expect(resp.Trailer.Get("Grpc-Message")).To(Equal("ok"))
+	})
+
+	It("sends 103 Early Hints", func() {
+		mux.HandleFunc("/early-hints", func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+			w.WriteHeader(http.StatusEarlyHints)
+			w.Write([]byte("foobar"))
+		})
+
+		var earlyHints []textproto.MIMEHeader
+		ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				defer GinkgoRecover()
+				Expect(code).To(Equal(http.StatusEarlyHints))
+				earlyHints = append(earlyHints, header)
+				return nil
+			},
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://localhost:%d/early-hints", port), nil)
+		Expect(err).ToNot(HaveOccurred())
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(200))
+		Expect(earlyHints).To(HaveLen(1))
+		Expect(earlyHints[0].Get("Link")).To(Equal("</style.css>; rel=preload; as=style"))
+		Expect(resp.Header.Get("Link")).To(Equal("</style.css>; rel=preload; as=style"))
+	})
+
 	It("downloads a small file", func() {
 		resp, err := client.Get(fmt.Sprintf("https://localhost:%d/prdata", port))
 		Expect(err).ToNot(HaveOccurred())
@@ -467,6 +516,73 @@ var _ = Describe("HTTP tests", func() {
 		Eventually(done).Should(BeClosed())
 	})
 
+	It("retries requests on a new connection when the server closes gracefully", func() {
+		tlsConf := getTLSConfig()
+		tlsConf.NextProtos = []string{http3.NextProtoH3}
+		ln, err := quic.ListenAddr("localhost:0", tlsConf, getQuicConfig(nil))
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+
+		handlingSlowRequest := make(chan struct{})
+		unblockSlowRequest := make(chan struct{})
+		newHandler := func(name string) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Server", name)
+				if r.URL.Path == "/slow" {
+					close(handlingSlowRequest)
+					<-unblockSlowRequest
+				}
+				io.WriteString(w, "Hello, World!\n")
+			})
+		}
+		oldServer := &http3.Server{Handler: newHandler("old")}
+		newServer := &http3.Server{Handler: newHandler("new")}
+		// The first connection is handled by oldServer, all following connections by newServer.
+		go func() {
+			defer GinkgoRecover()
+			server := oldServer
+			for {
+				conn, err := ln.Accept(context.Background())
+				if err != nil {
+					return
+				}
+				go server.ServeQUICConn(conn)
+				server = newServer
+			}
+		}()
+
+		slowResp := make(chan *http.Response, 1)
+		go func() {
+			defer GinkgoRecover()
+			resp, err := client.Get(fmt.Sprintf("https://localhost:%d/slow", ln.Addr().(*net.UDPAddr).Port))
+			Expect(err).ToNot(HaveOccurred())
+			slowResp <- resp
+		}()
+		Eventually(handlingSlowRequest).Should(BeClosed())
+
+		closed := make(chan error, 1)
+		go func() { closed <- oldServer.CloseGracefully(10 * time.Second) }()
+		// Requests that the old server didn't process are retried on a new connection.
+		Eventually(func() string {
+			resp, err := client.Get(fmt.Sprintf("https://localhost:%d/hello", ln.Addr().(*net.UDPAddr).Port))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			return resp.Header.Get("X-Server")
+		}).Should(Equal("new"))
+
+		// The request in flight is completed before the connection is closed.
+		Consistently(closed, scaleDuration(50*time.Millisecond)).ShouldNot(Receive())
+		close(unblockSlowRequest)
+		var resp *http.Response
+		Eventually(slowResp).Should(Receive(&resp))
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("X-Server")).To(Equal("old"))
+		body, err := io.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal("Hello, World!\n"))
+		Eventually(closed).Should(Receive(BeNil()))
+	})
+
 	It("supports read deadlines", func() {
 		mux.HandleFunc("/read-deadline", func(w http.ResponseWriter, r *http.Request) {
 			defer GinkgoRecover()
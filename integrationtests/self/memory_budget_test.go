@@ -0,0 +1,62 @@
+package self_test
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/simnet"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Receive memory budget", func() {
+	It("limits the memory reserved for receive windows", func() {
+		n := simnet.NewNetwork()
+		serverConn := newSimnetConn(n)
+		defer serverConn.Close()
+		serverTr := &quic.Transport{Conn: serverConn, MaxReceiveMemory: 2 << 20}
+		defer serverTr.Close()
+		ln, err := serverTr.Listen(getTLSConfig(), getQuicConfig(&quic.Config{InitialConnectionReceiveWindow: 1 << 20}))
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+
+		clientConn := newSimnetConn(n)
+		defer clientConn.Close()
+		clientTr := &quic.Transport{Conn: clientConn}
+		defer clientTr.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, err := clientTr.Dial(ctx, ln.Addr(), getTLSClientConfig(), getQuicConfig(nil))
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.CloseWithError(0, "")
+		sconn, err := ln.Accept(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		stats := serverTr.MemoryStats()
+		Expect(stats.Limit).To(BeEquivalentTo(2 << 20))
+		Expect(stats.Reserved).To(BeEquivalentTo(1 << 20))
+
+		data := GeneratePRData(5 << 20)
+		go func() {
+			defer GinkgoRecover()
+			str, err := conn.OpenUniStream()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}()
+		str, err := sconn.AcceptUniStream(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		received, err := io.ReadAll(str)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(received).To(Equal(data))
+		Expect(serverTr.MemoryStats().Reserved).To(BeNumerically("<=", 2<<20))
+
+		// the memory is released when the connection is closed
+		Expect(sconn.CloseWithError(0, "")).To(Succeed())
+		Expect(serverTr.MemoryStats().Reserved).To(BeZero())
+	})
+})
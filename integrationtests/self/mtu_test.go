@@ -6,12 +6,14 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nxenon/xquic-go"
 	quicproxy "github.com/nxenon/xquic-go/integrationtests/tools/proxy"
 	"github.com/nxenon/xquic-go/internal/protocol"
 	"github.com/nxenon/xquic-go/logging"
+	"github.com/nxenon/xquic-go/simnet"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -168,3 +170,53 @@ var _ = Describe("DPLPMTUD", func() {
 		Eventually(done).Should(BeClosed())
 	})
 })
+
+var _ = Describe("Jumbo packets", func() {
+	It("sends packets up to the configured maximum packet size", func() {
+		n := simnet.NewNetwork()
+		var maxSize atomic.Int64
+		n.DropPacket = func(_, _ net.Addr, b []byte) bool {
+			if size := int64(len(b)); size > maxSize.Load() {
+				maxSize.Store(size)
+			}
+			return false
+		}
+		conf := getQuicConfig(&quic.Config{InitialPacketSize: 9000, MaxPacketSize: 9000})
+
+		serverConn := newSimnetConn(n)
+		defer serverConn.Close()
+		serverTr := &quic.Transport{Conn: serverConn}
+		defer serverTr.Close()
+		ln, err := serverTr.Listen(getTLSConfig(), conf)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+
+		clientConn := newSimnetConn(n)
+		defer clientConn.Close()
+		clientTr := &quic.Transport{Conn: clientConn}
+		defer clientTr.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, err := clientTr.Dial(ctx, ln.Addr(), getTLSClientConfig(), conf)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.CloseWithError(0, "")
+		sconn, err := ln.Accept(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		data := GeneratePRData(100 * 1024)
+		go func() {
+			defer GinkgoRecover()
+			str, err := sconn.OpenUniStream()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}()
+		str, err := conn.AcceptUniStream(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		received, err := io.ReadAll(str)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(received).To(Equal(data))
+		Expect(maxSize.Load()).To(BeEquivalentTo(9000))
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"runtime/pprof"
 	"strconv"
@@ -22,6 +23,7 @@ import (
 	"github.com/nxenon/xquic-go/internal/utils"
 	"github.com/nxenon/xquic-go/internal/wire"
 	"github.com/nxenon/xquic-go/logging"
+	"github.com/nxenon/xquic-go/simnet"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -252,6 +254,13 @@ func newTracer(tracer *logging.ConnectionTracer) func(context.Context, logging.P
 	return func(context.Context, logging.Perspective, quic.ConnectionID) *logging.ConnectionTracer { return tracer }
 }
 
+// newSimnetConn creates a new packet conn on the simulated network.
+func newSimnetConn(n *simnet.Network) *simnet.PacketConn {
+	c, err := n.ListenPacket(&net.UDPAddr{})
+	Expect(err).ToNot(HaveOccurred())
+	return c
+}
+
 type packet struct {
 	time   time.Time
 	hdr    *logging.ExtendedHeader
@@ -0,0 +1,77 @@
+package self_test
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/simnet"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection worker pool", func() {
+	It("drives the server's connections", func() {
+		const numConns = 10
+		clock := simnet.NewClock(time.Now())
+		n := simnet.NewNetwork()
+
+		serverConn := newSimnetConn(n)
+		defer serverConn.Close()
+		serverTr := &quic.Transport{Conn: serverConn, Clock: clock, ConnectionWorkers: 2}
+		defer serverTr.Close()
+		ln, err := serverTr.Listen(getTLSConfig(), getQuicConfig(&quic.Config{MaxIdleTimeout: time.Hour}))
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+
+		// echo the data sent by the clients
+		serverConns := make(chan quic.Connection, numConns)
+		go func() {
+			for {
+				sconn, err := ln.Accept(context.Background())
+				if err != nil {
+					return
+				}
+				serverConns <- sconn
+				go func() {
+					str, err := sconn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					io.Copy(str, str)
+					str.Close()
+				}()
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for i := 0; i < numConns; i++ {
+			clientConn := newSimnetConn(n)
+			defer clientConn.Close()
+			clientTr := &quic.Transport{Conn: clientConn, Clock: clock}
+			defer clientTr.Close()
+			conn, err := clientTr.Dial(ctx, ln.Addr(), getTLSClientConfig(), getQuicConfig(&quic.Config{MaxIdleTimeout: time.Hour}))
+			Expect(err).ToNot(HaveOccurred())
+			str, err := conn.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+			data, err := io.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+		}
+
+		// The timers of the server's connections are serviced by the worker pool.
+		clock.Advance(2 * time.Hour)
+		for i := 0; i < numConns; i++ {
+			var sconn quic.Connection
+			Eventually(serverConns).Should(Receive(&sconn))
+			Eventually(sconn.Context().Done()).Should(BeClosed())
+			Expect(context.Cause(sconn.Context())).To(MatchError(&quic.IdleTimeoutError{}))
+		}
+	})
+})
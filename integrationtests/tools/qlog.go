@@ -15,7 +15,7 @@ import (
 )
 
 func NewQlogger(logger io.Writer) func(context.Context, logging.Perspective, quic.ConnectionID) *logging.ConnectionTracer {
-	return func(_ context.Context, p logging.Perspective, connID quic.ConnectionID) *logging.ConnectionTracer {
+	return func(ctx context.Context, p logging.Perspective, connID quic.ConnectionID) *logging.ConnectionTracer {
 		role := "server"
 		if p == logging.PerspectiveClient {
 			role = "client"
@@ -28,6 +28,6 @@ func NewQlogger(logger io.Writer) func(context.Context, logging.Perspective, qui
 			return nil
 		}
 		bw := bufio.NewWriter(f)
-		return qlog.NewConnectionTracer(utils.NewBufferedWriteCloser(bw, f), p, connID)
+		return qlog.NewConnectionTracerWithContext(ctx, utils.NewBufferedWriteCloser(bw, f), p, connID)
 	}
 }
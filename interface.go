@@ -8,8 +8,10 @@ import (
 	"net"
 	"time"
 
+	"github.com/nxenon/xquic-go/internal/flowcontrol"
 	"github.com/nxenon/xquic-go/internal/handshake"
 	"github.com/nxenon/xquic-go/internal/protocol"
+	"github.com/nxenon/xquic-go/internal/utils"
 	"github.com/nxenon/xquic-go/logging"
 )
 
@@ -19,6 +21,12 @@ type StreamID = protocol.StreamID
 // A VersionNumber is a QUIC version number.
 type VersionNumber = protocol.VersionNumber
 
+// A Clock is a source of time, see Transport.Clock.
+type Clock = utils.Clock
+
+// A ClockTimer is a timer created by a Clock.
+type ClockTimer = utils.ClockTimer
+
 const (
 	// Version1 is RFC 9000
 	Version1 = protocol.Version1
@@ -95,6 +103,16 @@ type ReceiveStream interface {
 	// A zero value for t means Read will not time out.
 
 	SetReadDeadline(t time.Time) error
+	// Peek returns data received on the stream without copying it.
+	// It blocks until data is available, and then returns the data that is stored contiguously,
+	// which might be less than the total amount of data available.
+	// The returned slice must not be modified, and is only valid until the next call to Consume, Peek or Read.
+	// Data returned by Peek is not considered read until Consume is called.
+	// Once all data has been consumed, Peek returns io.EOF.
+	// Peek times out in the same way as Read.
+	Peek() ([]byte, error)
+	// Consume marks the first n bytes of the data returned by the last call to Peek as read.
+	Consume(n int) error
 }
 
 // A SendStream is a unidirectional Send Stream.
@@ -109,6 +127,16 @@ type SendStream interface {
 	// If the connection was closed due to a timeout, the error satisfies
 	// the net.Error interface, and Timeout() will be true.
 	io.Writer
+	// WriteBuffer writes p to the stream without copying it.
+	// The stream takes ownership of p: p must not be modified until release is called.
+	// release is called exactly once, as soon as the stream doesn't reference p any more.
+	// This happens when all data of p was acknowledged by the peer, or when the stream was canceled or closed abruptly.
+	// WriteBuffer blocks and times out in the same way as Write.
+	WriteBuffer(p []byte, release func()) (int, error)
+	// ReadFrom reads data from r until io.EOF and writes it to the stream.
+	// The data is read into buffers that are sent without being copied again,
+	// and that are reused once the data has been acknowledged by the peer.
+	io.ReaderFrom
 	// Close closes the write-direction of the stream.
 	// Future calls to Write are not permitted after calling Close.
 	// It must not be called concurrently with Write.
@@ -131,6 +159,15 @@ type SendStream interface {
 	// some data was successfully written.
 	// A zero value for t means Write will not time out.
 	SetWriteDeadline(t time.Time) error
+	// SetDSCP sets the DSCP that packets carrying data of this stream are marked with,
+	// overriding the DSCP of the connection.
+	// Data of streams with different DSCPs is never sent in the same packet.
+	// The DSCP must be a value between 0 and 63.
+	SetDSCP(dscp uint8) error
+	// SetPriority sets the priority of the stream, determining when its data is sent
+	// relative to the data of other streams.
+	// Streams that don't have a priority set have urgency 3, and are incremental.
+	SetPriority(StreamPriority) error
 }
 
 // A Connection is a QUIC connection between two peers.
@@ -195,6 +232,11 @@ type Connection interface {
 	SendDatagram(payload []byte) error
 	// ReceiveDatagram gets a message received in a datagram, as specified in RFC 9221.
 	ReceiveDatagram(context.Context) ([]byte, error)
+	// SetDSCP sets the DSCP that packets are marked with (see Config.DSCP).
+	// It applies to all packets sent after the call, except for packets carrying data
+	// of streams that have their own DSCP set.
+	// The DSCP must be a value between 0 and 63.
+	SetDSCP(dscp uint8) error
 }
 
 // An EarlyConnection is a connection that is handshaking.
@@ -321,14 +363,40 @@ type Config struct {
 	// DisablePathMTUDiscovery disables Path MTU Discovery (RFC 8899).
 	// This allows the sending of QUIC packets that fully utilize the available MTU of the path.
 	// Path MTU discovery is only available on systems that allow setting of the Don't Fragment (DF) bit.
-	// If unavailable or disabled, packets will be at most 1252 (IPv4) / 1232 (IPv6) bytes in size.
+	// If unavailable or disabled, packets will be at most InitialPacketSize bytes in size.
 	DisablePathMTUDiscovery bool
+	// InitialPacketSize is the size of the packets sent before Path MTU Discovery has discovered a larger MTU.
+	// Path MTU Discovery falls back to this size when it detects that the path doesn't support larger packets any more.
+	// It must be at least 1200 bytes, and it can't be larger than MaxPacketSize.
+	// If unset, 1252 (IPv4) / 1232 (IPv6) bytes are used.
+	InitialPacketSize uint16
+	// MaxPacketSize is the maximum size of QUIC packets.
+	// It is advertised to the peer in the max_udp_payload_size transport parameter,
+	// and Path MTU Discovery doesn't probe for packet sizes larger than this value.
+	// It only makes sense to set this to a value larger than the default on networks that support jumbo frames.
+	// It can be any value between 1200 and 20480 bytes.
+	// If unset, 1452 bytes are used.
+	MaxPacketSize uint16
+	// DSCP is the Differentiated Services Code Point (RFC 2474) that packets are marked with.
+	// It is combined with the ECN codepoint in the IPv4 TOS / IPv6 Traffic Class field.
+	// It can be any value between 0 and 63, and it can be changed during the lifetime of a connection
+	// using Connection.SetDSCP.
+	// Packets are only marked if the packet conn supports sending control messages, which is the case
+	// for a *net.UDPConn on Linux and macOS.
+	DSCP uint8
 	// Allow0RTT allows the application to decide if a 0-RTT connection attempt should be accepted.
 	// Only valid for the server.
 	Allow0RTT bool
 	// Enable QUIC datagram support (RFC 9221).
 	EnableDatagrams bool
 	Tracer          func(context.Context, logging.Perspective, ConnectionID) *logging.ConnectionTracer
+
+	// Set by the Transport, if it uses a Clock.
+	clock Clock
+	// Set by the Transport, if connections are driven by a worker pool.
+	workerPool *workerPool
+	// Set by the Transport, if it limits the memory used for receive windows.
+	memoryBudget *flowcontrol.MemoryBudget
 }
 
 type ClientHelloInfo struct {
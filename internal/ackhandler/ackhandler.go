@@ -13,12 +13,14 @@ func NewAckHandler(
 	initialPacketNumber protocol.PacketNumber,
 	initialMaxDatagramSize protocol.ByteCount,
 	rttStats *utils.RTTStats,
+	clock utils.Clock,
 	clientAddressValidated bool,
 	enableECN bool,
 	pers protocol.Perspective,
+	mtuTracker PathMTUTracker,
 	tracer *logging.ConnectionTracer,
 	logger utils.Logger,
 ) (SentPacketHandler, ReceivedPacketHandler) {
-	sph := newSentPacketHandler(initialPacketNumber, initialMaxDatagramSize, rttStats, clientAddressValidated, enableECN, pers, tracer, logger)
-	return sph, newReceivedPacketHandler(sph, rttStats, logger)
+	sph := newSentPacketHandler(initialPacketNumber, initialMaxDatagramSize, rttStats, clock, clientAddressValidated, enableECN, pers, mtuTracker, tracer, logger)
+	return sph, newReceivedPacketHandler(sph, rttStats, clock, logger)
 }
@@ -9,7 +9,8 @@ import (
 
 // SentPacketHandler handles ACKs received for outgoing packets
 type SentPacketHandler interface {
-	// SentPacket may modify the packet
+	// SentPacket may modify the packet.
+	// The frames are copied, so the caller may reuse the streamFrames and frames slices after SentPacket returns.
 	SentPacket(t time.Time, pn, largestAcked protocol.PacketNumber, streamFrames []StreamFrame, frames []Frame, encLevel protocol.EncryptionLevel, ecn protocol.ECN, size protocol.ByteCount, isPathMTUProbePacket bool)
 	// ReceivedAck processes an ACK frame.
 	// It does not store a copy of the frame.
@@ -37,6 +38,13 @@ type SentPacketHandler interface {
 	OnLossDetectionTimeout() error
 }
 
+// A PathMTUTracker is informed about acknowledged and lost 1-RTT packets.
+// Path MTU probe packets are not reported.
+type PathMTUTracker interface {
+	OnPacketAcked(protocol.PacketNumber, protocol.ByteCount)
+	OnPacketLost(protocol.PacketNumber, protocol.ByteCount)
+}
+
 type sentPacketTracker interface {
 	GetLowestPacketNotConfirmedAcked() protocol.PacketNumber
 	ReceivedPacket(protocol.EncryptionLevel)
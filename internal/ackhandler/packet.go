@@ -33,8 +33,8 @@ var packetPool = sync.Pool{New: func() any { return &packet{} }}
 func getPacket() *packet {
 	p := packetPool.Get().(*packet)
 	p.PacketNumber = 0
-	p.StreamFrames = nil
-	p.Frames = nil
+	p.StreamFrames = p.StreamFrames[:0]
+	p.Frames = p.Frames[:0]
 	p.LargestAcked = 0
 	p.Length = 0
 	p.EncryptionLevel = protocol.EncryptionLevel(0)
@@ -46,10 +46,13 @@ func getPacket() *packet {
 	return p
 }
 
-// We currently only return Packets back into the pool when they're acknowledged (not when they're lost).
-// This simplifies the code, and gives the vast majority of the performance benefit we can gain from using the pool.
+// Packets are returned into the pool when they're acknowledged or declared lost,
+// and skipped packets when they're removed from the history.
+// The frame slices keep their capacity, such that a packet taken from the pool can be filled without allocating.
 func putPacket(p *packet) {
-	p.Frames = nil
-	p.StreamFrames = nil
+	clear(p.Frames)
+	clear(p.StreamFrames)
+	p.Frames = p.Frames[:0]
+	p.StreamFrames = p.StreamFrames[:0]
 	packetPool.Put(p)
 }
@@ -24,13 +24,14 @@ var _ ReceivedPacketHandler = &receivedPacketHandler{}
 func newReceivedPacketHandler(
 	sentPackets sentPacketTracker,
 	rttStats *utils.RTTStats,
+	clock utils.Clock,
 	logger utils.Logger,
 ) ReceivedPacketHandler {
 	return &receivedPacketHandler{
 		sentPackets:      sentPackets,
-		initialPackets:   newReceivedPacketTracker(rttStats, logger),
-		handshakePackets: newReceivedPacketTracker(rttStats, logger),
-		appDataPackets:   newReceivedPacketTracker(rttStats, logger),
+		initialPackets:   newReceivedPacketTracker(rttStats, clock, logger),
+		handshakePackets: newReceivedPacketTracker(rttStats, clock, logger),
+		appDataPackets:   newReceivedPacketTracker(rttStats, clock, logger),
 		lowest1RTTPacket: protocol.InvalidPacketNumber,
 	}
 }
@@ -21,6 +21,7 @@ var _ = Describe("Received Packet Handler", func() {
 		handler = newReceivedPacketHandler(
 			sentPackets,
 			&utils.RTTStats{},
+			utils.DefaultClock{},
 			utils.DefaultLogger,
 		)
 	})
@@ -22,6 +22,7 @@ type receivedPacketTracker struct {
 
 	maxAckDelay time.Duration
 	rttStats    *utils.RTTStats
+	clock       utils.Clock
 
 	hasNewAck bool // true as soon as we received an ack-eliciting new packet
 	ackQueued bool // true once we received more than 2 (or later in the connection 10) ack-eliciting packets
@@ -35,12 +36,14 @@ type receivedPacketTracker struct {
 
 func newReceivedPacketTracker(
 	rttStats *utils.RTTStats,
+	clock utils.Clock,
 	logger utils.Logger,
 ) *receivedPacketTracker {
 	return &receivedPacketTracker{
 		packetHistory: newReceivedPacketHistory(),
 		maxAckDelay:   protocol.MaxAckDelay,
 		rttStats:      rttStats,
+		clock:         clock,
 		logger:        logger,
 	}
 }
@@ -158,7 +161,7 @@ func (h *receivedPacketTracker) GetAckFrame(onlyIfQueued bool) *wire.AckFrame {
 	if !h.hasNewAck {
 		return nil
 	}
-	now := time.Now()
+	now := h.clock.Now()
 	if onlyIfQueued {
 		if !h.ackQueued && (h.ackAlarm.IsZero() || h.ackAlarm.After(now)) {
 			return nil
@@ -81,6 +81,7 @@ type sentPacketHandler struct {
 
 	congestion congestion.SendAlgorithmWithDebugInfos
 	rttStats   *utils.RTTStats
+	clock      utils.Clock
 
 	// The number of times a PTO has been sent without receiving an ack.
 	ptoCount uint32
@@ -97,6 +98,8 @@ type sentPacketHandler struct {
 
 	perspective protocol.Perspective
 
+	mtuTracker PathMTUTracker // may be nil
+
 	tracer *logging.ConnectionTracer
 	logger utils.Logger
 }
@@ -112,14 +115,16 @@ func newSentPacketHandler(
 	initialPN protocol.PacketNumber,
 	initialMaxDatagramSize protocol.ByteCount,
 	rttStats *utils.RTTStats,
+	clock utils.Clock,
 	clientAddressValidated bool,
 	enableECN bool,
 	pers protocol.Perspective,
+	mtuTracker PathMTUTracker,
 	tracer *logging.ConnectionTracer,
 	logger utils.Logger,
 ) *sentPacketHandler {
 	congestion := congestion.NewCubicSender(
-		congestion.DefaultClock{},
+		clock,
 		rttStats,
 		initialMaxDatagramSize,
 		true, // use Reno
@@ -133,8 +138,10 @@ func newSentPacketHandler(
 		handshakePackets:               newPacketNumberSpace(0, false),
 		appDataPackets:                 newPacketNumberSpace(0, true),
 		rttStats:                       rttStats,
+		clock:                          clock,
 		congestion:                     congestion,
 		perspective:                    pers,
+		mtuTracker:                     mtuTracker,
 		tracer:                         tracer,
 		logger:                         logger,
 	}
@@ -280,8 +287,9 @@ func (h *sentPacketHandler) SentPacket(
 	p.EncryptionLevel = encLevel
 	p.Length = size
 	p.LargestAcked = largestAcked
-	p.StreamFrames = streamFrames
-	p.Frames = frames
+	// The caller is allowed to reuse the frame slices, so we need to copy them.
+	p.StreamFrames = append(p.StreamFrames, streamFrames...)
+	p.Frames = append(p.Frames, frames...)
 	p.IsPathMTUProbePacket = isPathMTUProbePacket
 	p.includedInBytesInFlight = true
 
@@ -459,6 +467,9 @@ func (h *sentPacketHandler) detectAndRemoveAckedPackets(ack *wire.AckFrame, encL
 				f.Handler.OnAcked(f.Frame)
 			}
 		}
+		if encLevel == protocol.Encryption1RTT && h.mtuTracker != nil && !p.IsPathMTUProbePacket {
+			h.mtuTracker.OnPacketAcked(p.PacketNumber, p.Length)
+		}
 		if err := pnSpace.history.Remove(p.PacketNumber); err != nil {
 			return nil, err
 		}
@@ -505,7 +516,7 @@ func (h *sentPacketHandler) getPTOTimeAndSpace() (pto time.Time, encLevel protoc
 		if h.peerCompletedAddressValidation {
 			return
 		}
-		t := time.Now().Add(h.getScaledPTO(false))
+		t := h.clock.Now().Add(h.getScaledPTO(false))
 		if h.initialPackets != nil {
 			return t, protocol.EncryptionInitial, true
 		}
@@ -659,11 +670,15 @@ func (h *sentPacketHandler) detectLostPackets(now time.Time, encLevel protocol.E
 				h.queueFramesForRetransmission(p)
 				if !p.IsPathMTUProbePacket {
 					h.congestion.OnCongestionEvent(p.PacketNumber, p.Length, priorInFlight)
+					if encLevel == protocol.Encryption1RTT && h.mtuTracker != nil {
+						h.mtuTracker.OnPacketLost(p.PacketNumber, p.Length)
+					}
 				}
 				if encLevel == protocol.Encryption1RTT && h.ecnTracker != nil {
 					h.ecnTracker.LostPacket(p.PacketNumber)
 				}
 			}
+			putPacket(p)
 		}
 		return true, nil
 	})
@@ -680,7 +695,7 @@ func (h *sentPacketHandler) OnLossDetectionTimeout() error {
 			h.tracer.LossTimerExpired(logging.TimerTypeACK, encLevel)
 		}
 		// Early retransmit or time loss detection
-		return h.detectLostPackets(time.Now(), encLevel)
+		return h.detectLostPackets(h.clock.Now(), encLevel)
 	}
 
 	// PTO
@@ -843,6 +858,7 @@ func (h *sentPacketHandler) QueueProbePacket(encLevel protocol.EncryptionLevel)
 	// Keep track of acknowledged frames instead.
 	h.removeFromBytesInFlight(p)
 	pnSpace.history.DeclareLost(p.PacketNumber)
+	putPacket(p)
 	return true
 }
 
@@ -860,8 +876,10 @@ func (h *sentPacketHandler) queueFramesForRetransmission(p *packet) {
 			f.Handler.OnLost(f.Frame)
 		}
 	}
-	p.StreamFrames = nil
-	p.Frames = nil
+	clear(p.StreamFrames)
+	clear(p.Frames)
+	p.StreamFrames = p.StreamFrames[:0]
+	p.Frames = p.Frames[:0]
 }
 
 func (h *sentPacketHandler) ResetForRetry(now time.Time) error {
@@ -31,6 +31,18 @@ func (h *customFrameHandler) OnAcked(f wire.Frame) {
 	}
 }
 
+type recordingPathMTUTracker struct {
+	acked, lost []protocol.PacketNumber
+}
+
+func (t *recordingPathMTUTracker) OnPacketAcked(pn protocol.PacketNumber, _ protocol.ByteCount) {
+	t.acked = append(t.acked, pn)
+}
+
+func (t *recordingPathMTUTracker) OnPacketLost(pn protocol.PacketNumber, _ protocol.ByteCount) {
+	t.lost = append(t.lost, pn)
+}
+
 var _ = Describe("SentPacketHandler", func() {
 	var (
 		handler     *sentPacketHandler
@@ -44,7 +56,7 @@ var _ = Describe("SentPacketHandler", func() {
 	JustBeforeEach(func() {
 		lostPackets = nil
 		rttStats := utils.NewRTTStats()
-		handler = newSentPacketHandler(42, protocol.InitialPacketSizeIPv4, rttStats, false, false, perspective, nil, utils.DefaultLogger)
+		handler = newSentPacketHandler(42, protocol.InitialPacketSizeIPv4, rttStats, utils.DefaultClock{}, false, false, perspective, nil, nil, utils.DefaultLogger)
 		streamFrame = wire.StreamFrame{
 			StreamID: 5,
 			Data:     []byte{0x13, 0x37},
@@ -161,6 +173,19 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(2)))
 		})
 
+		It("copies the frames", func() {
+			frames := []Frame{{Frame: &wire.PingFrame{}}}
+			streamFrames := []StreamFrame{{Frame: &wire.StreamFrame{StreamID: 5}}}
+			handler.SentPacket(time.Now(), 1, protocol.InvalidPacketNumber, streamFrames, frames, protocol.Encryption1RTT, protocol.ECNNon, 1000, false)
+			// the caller is allowed to reuse the slices
+			frames[0] = Frame{Frame: &wire.MaxDataFrame{}}
+			streamFrames[0] = StreamFrame{Frame: &wire.StreamFrame{StreamID: 9}}
+			p := getPacket(1, protocol.Encryption1RTT)
+			Expect(p).ToNot(BeNil())
+			Expect(p.Frames).To(Equal([]Frame{{Frame: &wire.PingFrame{}}}))
+			Expect(p.StreamFrames).To(Equal([]StreamFrame{{Frame: &wire.StreamFrame{StreamID: 5}}}))
+		})
+
 		It("stores the sent time", func() {
 			sendTime := time.Now().Add(-time.Minute)
 			sentPacket(ackElicitingPacket(&packet{PacketNumber: 1, SendTime: sendTime}))
@@ -984,7 +1009,7 @@ var _ = Describe("SentPacketHandler", func() {
 	Context("amplification limit, for the server, with validated address", func() {
 		JustBeforeEach(func() {
 			rttStats := utils.NewRTTStats()
-			handler = newSentPacketHandler(42, protocol.InitialPacketSizeIPv4, rttStats, true, false, perspective, nil, utils.DefaultLogger)
+			handler = newSentPacketHandler(42, protocol.InitialPacketSizeIPv4, rttStats, utils.DefaultClock{}, true, false, perspective, nil, nil, utils.DefaultLogger)
 		})
 
 		It("do not limits the window", func() {
@@ -1443,7 +1468,7 @@ var _ = Describe("SentPacketHandler", func() {
 			lostPackets = nil
 			rttStats := utils.NewRTTStats()
 			rttStats.UpdateRTT(time.Hour, 0, time.Now())
-			handler = newSentPacketHandler(42, protocol.InitialPacketSizeIPv4, rttStats, false, false, perspective, nil, utils.DefaultLogger)
+			handler = newSentPacketHandler(42, protocol.InitialPacketSizeIPv4, rttStats, utils.DefaultClock{}, false, false, perspective, nil, nil, utils.DefaultLogger)
 			handler.ecnTracker = ecnHandler
 			handler.congestion = cong
 		})
@@ -1576,4 +1601,40 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
+
+	Context("Path MTU tracking", func() {
+		var tracker *recordingPathMTUTracker
+
+		JustBeforeEach(func() {
+			tracker = &recordingPathMTUTracker{}
+			rttStats := utils.NewRTTStats()
+			rttStats.UpdateRTT(time.Hour, 0, time.Now())
+			handler = newSentPacketHandler(42, protocol.InitialPacketSizeIPv4, rttStats, utils.DefaultClock{}, false, false, perspective, tracker, nil, utils.DefaultLogger)
+		})
+
+		It("informs about acknowledged and lost 1-RTT packets", func() {
+			handler.SentPacket(time.Now(), 42, -1, nil, []Frame{{Frame: &wire.PingFrame{}}}, protocol.EncryptionInitial, protocol.ECNNon, 1200, false)
+			_, err := handler.ReceivedAck(&wire.AckFrame{AckRanges: []wire.AckRange{{Largest: 42, Smallest: 42}}}, protocol.EncryptionInitial, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tracker.acked).To(BeEmpty())
+
+			for i := 10; i < 20; i++ {
+				handler.SentPacket(time.Now(), protocol.PacketNumber(i), -1, []StreamFrame{{Frame: &streamFrame}}, nil, protocol.Encryption1RTT, protocol.ECNNon, 1200, false)
+			}
+			_, err = handler.ReceivedAck(&wire.AckFrame{AckRanges: []wire.AckRange{{Largest: 16, Smallest: 13}}}, protocol.Encryption1RTT, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tracker.acked).To(Equal([]protocol.PacketNumber{13, 14, 15, 16}))
+			Expect(tracker.lost).To(Equal([]protocol.PacketNumber{10, 11, 12}))
+		})
+
+		It("doesn't inform about Path MTU probe packets", func() {
+			for i := 10; i < 20; i++ {
+				handler.SentPacket(time.Now(), protocol.PacketNumber(i), -1, nil, []Frame{{Frame: &wire.PingFrame{}}}, protocol.Encryption1RTT, protocol.ECNNon, 1500, i%2 == 0)
+			}
+			_, err := handler.ReceivedAck(&wire.AckFrame{AckRanges: []wire.AckRange{{Largest: 17, Smallest: 14}}}, protocol.Encryption1RTT, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tracker.acked).To(Equal([]protocol.PacketNumber{15, 17}))
+			Expect(tracker.lost).To(Equal([]protocol.PacketNumber{11, 13}))
+		})
+	})
 })
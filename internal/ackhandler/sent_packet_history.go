@@ -8,6 +8,9 @@ import (
 
 type sentPacketHistory struct {
 	packets []*packet
+	// buf is the full underlying array of packets.
+	// packets is a window into buf, which moves forward as packets are removed from the front.
+	buf []*packet
 
 	numOutstanding int
 
@@ -15,12 +18,32 @@ type sentPacketHistory struct {
 }
 
 func newSentPacketHistory() *sentPacketHistory {
+	buf := make([]*packet, 32)
 	return &sentPacketHistory{
-		packets:             make([]*packet, 0, 32),
+		packets:             buf[:0],
+		buf:                 buf,
 		highestPacketNumber: protocol.InvalidPacketNumber,
 	}
 }
 
+// append appends a packet (or a nil placeholder) to the history.
+// Removing packets from the front of the history shrinks the capacity of the packets slice.
+// Once the end of the underlying array is reached, the packets are moved back to the beginning of the array,
+// as long as this frees up a sufficient amount of space. This avoids allocating in steady state.
+func (h *sentPacketHistory) append(p *packet) {
+	if len(h.packets) == cap(h.packets) && len(h.packets) < len(h.buf)/2 {
+		n := copy(h.buf, h.packets)
+		clear(h.buf[n:])
+		h.packets = h.buf[:n]
+	}
+	if len(h.packets) == cap(h.packets) {
+		h.packets = append(h.packets, p)
+		h.buf = h.packets[:cap(h.packets)]
+		return
+	}
+	h.packets = append(h.packets, p)
+}
+
 func (h *sentPacketHistory) checkSequentialPacketNumberUse(pn protocol.PacketNumber) {
 	if h.highestPacketNumber != protocol.InvalidPacketNumber {
 		if pn != h.highestPacketNumber+1 {
@@ -32,24 +55,24 @@ func (h *sentPacketHistory) checkSequentialPacketNumberUse(pn protocol.PacketNum
 func (h *sentPacketHistory) SkippedPacket(pn protocol.PacketNumber) {
 	h.checkSequentialPacketNumberUse(pn)
 	h.highestPacketNumber = pn
-	h.packets = append(h.packets, &packet{
-		PacketNumber:  pn,
-		skippedPacket: true,
-	})
+	p := getPacket()
+	p.PacketNumber = pn
+	p.skippedPacket = true
+	h.append(p)
 }
 
 func (h *sentPacketHistory) SentNonAckElicitingPacket(pn protocol.PacketNumber) {
 	h.checkSequentialPacketNumberUse(pn)
 	h.highestPacketNumber = pn
 	if len(h.packets) > 0 {
-		h.packets = append(h.packets, nil)
+		h.append(nil)
 	}
 }
 
 func (h *sentPacketHistory) SentAckElicitingPacket(p *packet) {
 	h.checkSequentialPacketNumberUse(p.PacketNumber)
 	h.highestPacketNumber = p.PacketNumber
-	h.packets = append(h.packets, p)
+	h.append(p)
 	if p.outstanding() {
 		h.numOutstanding++
 	}
@@ -110,6 +133,7 @@ func (h *sentPacketHistory) Remove(pn protocol.PacketNumber) error {
 			break
 		}
 		h.packets[idx] = nil
+		putPacket(p)
 	}
 	if idx == 0 {
 		h.cleanupStart()
@@ -148,7 +172,7 @@ func (h *sentPacketHistory) cleanupStart() {
 			return
 		}
 	}
-	h.packets = h.packets[:0]
+	h.packets = h.buf[:0]
 }
 
 func (h *sentPacketHistory) LowestPacketNumber() protocol.PacketNumber {
@@ -169,6 +169,22 @@ var _ = Describe("SentPacketHistory", func() {
 		expectInHistory(nil)
 	})
 
+	It("reuses the underlying array when packets are removed from the front", func() {
+		for i := 0; i < 10; i++ {
+			hist.SentAckElicitingPacket(&packet{PacketNumber: protocol.PacketNumber(i)})
+		}
+		c := cap(hist.buf)
+		for i := 10; i < 10*c; i++ {
+			Expect(hist.Remove(protocol.PacketNumber(i - 10))).To(Succeed())
+			hist.SentAckElicitingPacket(&packet{PacketNumber: protocol.PacketNumber(i)})
+		}
+		Expect(hist.buf).To(HaveCap(c))
+		expectInHistory([]protocol.PacketNumber{
+			protocol.PacketNumber(10*c - 10), protocol.PacketNumber(10*c - 9), protocol.PacketNumber(10*c - 8), protocol.PacketNumber(10*c - 7), protocol.PacketNumber(10*c - 6),
+			protocol.PacketNumber(10*c - 5), protocol.PacketNumber(10*c - 4), protocol.PacketNumber(10*c - 3), protocol.PacketNumber(10*c - 2), protocol.PacketNumber(10*c - 1),
+		})
+	})
+
 	It("errors when trying to remove a non existing packet", func() {
 		hist.SentAckElicitingPacket(&packet{PacketNumber: 1})
 		Expect(hist.Remove(2)).To(MatchError("packet 2 not found in sent packet history"))
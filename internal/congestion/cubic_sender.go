@@ -1,7 +1,6 @@
 package congestion
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/nxenon/xquic-go/internal/protocol"
@@ -303,14 +302,14 @@ func (c *cubicSender) maybeTraceStateChange(new logging.CongestionState) {
 	c.lastState = new
 }
 
+// SetMaxDatagramSize sets the maximum datagram size.
+// The size is decreased when Path MTU Discovery detects a black hole.
 func (c *cubicSender) SetMaxDatagramSize(s protocol.ByteCount) {
-	if s < c.maxDatagramSize {
-		panic(fmt.Sprintf("congestion BUG: decreased max datagram size from %d to %d", c.maxDatagramSize, s))
-	}
 	cwndIsMinCwnd := c.congestionWindow == c.minCongestionWindow()
 	c.maxDatagramSize = s
 	if cwndIsMinCwnd {
 		c.congestionWindow = c.minCongestionWindow()
 	}
+	c.congestionWindow = min(c.congestionWindow, c.maxCongestionWindow())
 	c.pacer.SetMaxDatagramSize(s)
 }
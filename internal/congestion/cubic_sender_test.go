@@ -469,8 +469,14 @@ var _ = Describe("Cubic Sender", func() {
 		Expect(sender.GetCongestionWindow()).To(Equal(initialMaxCongestionWindow))
 	})
 
-	It("doesn't allow reductions of the maximum packet size", func() {
-		Expect(func() { sender.SetMaxDatagramSize(initialMaxDatagramSize - 1) }).To(Panic())
+	It("allows reductions of the maximum packet size", func() {
+		Expect(sender.GetCongestionWindow()).To(Equal(defaultWindowTCP))
+		const packetSize = initialMaxDatagramSize - 100
+		sender.SetMaxDatagramSize(packetSize)
+		Expect(sender.GetCongestionWindow()).To(Equal(defaultWindowTCP))
+		// The minimum congestion window is reduced as well.
+		sender.OnRetransmissionTimeout(true)
+		Expect(sender.GetCongestionWindow()).To(Equal(minCongestionWindowPackets * packetSize))
 	})
 
 	It("slow starts up to maximum congestion window, if larger packets are sent", func() {
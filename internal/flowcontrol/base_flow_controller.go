@@ -24,6 +24,8 @@ type baseFlowController struct {
 	maxReceiveWindowSize protocol.ByteCount
 
 	allowWindowIncrease func(size protocol.ByteCount) bool
+	// only set for the connection flow controller, if a memory budget is used
+	memory *MemoryAccount
 
 	epochStartTime   time.Time
 	epochStartOffset protocol.ByteCount
@@ -108,13 +110,30 @@ func (c *baseFlowController) maybeAdjustWindowSize() {
 	if now.Sub(c.epochStartTime) < time.Duration(4*fraction*float64(rtt)) {
 		// window is consumed too fast, try to increase the window size
 		newSize := min(2*c.receiveWindowSize, c.maxReceiveWindowSize)
-		if newSize > c.receiveWindowSize && (c.allowWindowIncrease == nil || c.allowWindowIncrease(newSize-c.receiveWindowSize)) {
-			c.receiveWindowSize = newSize
+		if newSize > c.receiveWindowSize {
+			c.increaseWindowSize(newSize - c.receiveWindowSize)
 		}
 	}
 	c.startNewAutoTuningEpoch(now)
 }
 
+// increaseWindowSize increases the receive window size by up to delta.
+// If a memory budget is used, the increase is drawn from the budget, which might reduce or deny it.
+func (c *baseFlowController) increaseWindowSize(delta protocol.ByteCount) {
+	if c.memory != nil {
+		if delta = c.memory.reserve(delta); delta == 0 {
+			return
+		}
+	}
+	if c.allowWindowIncrease != nil && !c.allowWindowIncrease(delta) {
+		if c.memory != nil {
+			c.memory.release(delta)
+		}
+		return
+	}
+	c.receiveWindowSize += delta
+}
+
 func (c *baseFlowController) startNewAutoTuningEpoch(now time.Time) {
 	c.epochStartTime = now
 	c.epochStartOffset = c.bytesRead
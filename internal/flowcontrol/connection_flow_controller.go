@@ -20,14 +20,19 @@ var _ ConnectionFlowController = &connectionFlowController{}
 
 // NewConnectionFlowController gets a new flow controller for the connection
 // It is created before we receive the peer's transport parameters, thus it starts with a sendWindow of 0.
+// If a memory account is passed, the receive window is reserved from the memory budget.
 func NewConnectionFlowController(
 	receiveWindow protocol.ByteCount,
 	maxReceiveWindow protocol.ByteCount,
 	queueWindowUpdate func(),
 	allowWindowIncrease func(size protocol.ByteCount) bool,
+	memory *MemoryAccount,
 	rttStats *utils.RTTStats,
 	logger utils.Logger,
 ) ConnectionFlowController {
+	if memory != nil {
+		memory.forceReserve(receiveWindow)
+	}
 	return &connectionFlowController{
 		baseFlowController: baseFlowController{
 			rttStats:             rttStats,
@@ -35,6 +40,7 @@ func NewConnectionFlowController(
 			receiveWindowSize:    receiveWindow,
 			maxReceiveWindowSize: maxReceiveWindow,
 			allowWindowIncrease:  allowWindowIncrease,
+			memory:               memory,
 			logger:               logger,
 		},
 		queueWindowUpdate: queueWindowUpdate,
@@ -88,8 +94,8 @@ func (c *connectionFlowController) EnsureMinimumWindowSize(inc protocol.ByteCoun
 	if inc > c.receiveWindowSize {
 		c.logger.Debugf("Increasing receive flow control window for the connection to %d kB, in response to stream flow control window increase", c.receiveWindowSize/(1<<10))
 		newSize := min(inc, c.maxReceiveWindowSize)
-		if delta := newSize - c.receiveWindowSize; delta > 0 && c.allowWindowIncrease(delta) {
-			c.receiveWindowSize = newSize
+		if delta := newSize - c.receiveWindowSize; delta > 0 {
+			c.increaseWindowSize(delta)
 		}
 		c.startNewAutoTuningEpoch(time.Now())
 	}
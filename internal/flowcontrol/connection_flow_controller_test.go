@@ -43,6 +43,7 @@ var _ = Describe("Connection Flow controller", func() {
 				maxReceiveWindow,
 				nil,
 				func(protocol.ByteCount) bool { return true },
+				nil,
 				rttStats,
 				utils.DefaultLogger).(*connectionFlowController)
 			Expect(fc.receiveWindow).To(Equal(receiveWindow))
@@ -121,6 +122,55 @@ var _ = Describe("Connection Flow controller", func() {
 				Expect(newWindowSize).To(Equal(oldWindowSize))
 				Expect(offset).To(Equal(oldOffset + dataRead + newWindowSize))
 			})
+
+			It("draws window increases from the memory budget", func() {
+				budget := NewMemoryBudget(1000)
+				controller.memory = budget.NewAccount()
+				controller.memory.forceReserve(controller.receiveWindowSize)
+				oldOffset := controller.bytesRead
+				oldWindowSize := controller.receiveWindowSize
+				setRtt(scaleDuration(20 * time.Millisecond))
+				controller.epochStartTime = time.Now().Add(-time.Millisecond)
+				controller.epochStartOffset = oldOffset
+				controller.AddBytesRead(oldWindowSize/2 + 1)
+				controller.GetWindowUpdate()
+				Expect(controller.receiveWindowSize).To(Equal(2 * oldWindowSize))
+				Expect(budget.Reserved()).To(Equal(2 * oldWindowSize))
+				controller.memory.Close()
+				Expect(budget.Reserved()).To(BeZero())
+			})
+
+			It("returns the memory to the budget if the window increase is not allowed", func() {
+				budget := NewMemoryBudget(1000)
+				controller.memory = budget.NewAccount()
+				controller.allowWindowIncrease = func(protocol.ByteCount) bool { return false }
+				oldOffset := controller.bytesRead
+				oldWindowSize := controller.receiveWindowSize
+				setRtt(scaleDuration(20 * time.Millisecond))
+				controller.epochStartTime = time.Now().Add(-time.Millisecond)
+				controller.epochStartOffset = oldOffset
+				controller.AddBytesRead(oldWindowSize/2 + 1)
+				controller.GetWindowUpdate()
+				Expect(controller.receiveWindowSize).To(Equal(oldWindowSize))
+				Expect(budget.Reserved()).To(BeZero())
+			})
+
+			It("reduces window increases under memory pressure", func() {
+				budget := NewMemoryBudget(1000)
+				budget.NewAccount().forceReserve(800) // another connection uses most of the budget
+				controller.memory = budget.NewAccount()
+				controller.memory.forceReserve(controller.receiveWindowSize)
+				oldOffset := controller.bytesRead
+				oldWindowSize := controller.receiveWindowSize
+				setRtt(scaleDuration(20 * time.Millisecond))
+				controller.epochStartTime = time.Now().Add(-time.Millisecond)
+				controller.epochStartOffset = oldOffset
+				controller.AddBytesRead(oldWindowSize/2 + 1)
+				controller.GetWindowUpdate()
+				// only half of the increase is granted under memory pressure
+				Expect(controller.receiveWindowSize).To(Equal(oldWindowSize + oldWindowSize/2))
+				Expect(budget.WindowIncreasesLimited()).To(BeEquivalentTo(1))
+			})
 		})
 	})
 
@@ -0,0 +1,140 @@
+package flowcontrol
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/nxenon/xquic-go/internal/protocol"
+)
+
+// A MemoryBudget limits the memory used for the receive windows of all connections of a Transport.
+// Connection flow controllers draw from the budget when auto-tuning increases their receive window.
+type MemoryBudget struct {
+	limit    protocol.ByteCount
+	reserved atomic.Int64
+
+	windowIncreasesLimited atomic.Uint64
+	packetsDropped         atomic.Uint64
+}
+
+// NewMemoryBudget creates a new memory budget.
+func NewMemoryBudget(limit protocol.ByteCount) *MemoryBudget {
+	return &MemoryBudget{limit: limit}
+}
+
+// Limit returns the size of the memory budget.
+func (b *MemoryBudget) Limit() protocol.ByteCount { return b.limit }
+
+// Reserved returns the amount of memory currently reserved.
+func (b *MemoryBudget) Reserved() protocol.ByteCount { return protocol.ByteCount(b.reserved.Load()) }
+
+// UnderPressure says if more than 3/4 of the budget are reserved.
+func (b *MemoryBudget) UnderPressure() bool {
+	return b.isUnderPressure(b.Reserved())
+}
+
+func (b *MemoryBudget) isUnderPressure(reserved protocol.ByteCount) bool {
+	return reserved > b.limit/4*3
+}
+
+// WindowIncreasesLimited returns the number of receive window increases that were reduced or denied.
+func (b *MemoryBudget) WindowIncreasesLimited() uint64 { return b.windowIncreasesLimited.Load() }
+
+// DroppedPacket is called when a packet is dropped because a queue was shrunk due to memory pressure.
+func (b *MemoryBudget) DroppedPacket() { b.packetsDropped.Add(1) }
+
+// PacketsDropped returns the number of packets that were dropped due to memory pressure.
+func (b *MemoryBudget) PacketsDropped() uint64 { return b.packetsDropped.Load() }
+
+// reserve reserves up to n bytes.
+// Under memory pressure, only half of the requested amount is reserved.
+// It returns the number of bytes that were reserved.
+func (b *MemoryBudget) reserve(n protocol.ByteCount) protocol.ByteCount {
+	for {
+		reserved := b.Reserved()
+		granted := n
+		if b.isUnderPressure(reserved) {
+			granted /= 2
+		}
+		granted = min(granted, max(b.limit-reserved, 0))
+		if granted == 0 {
+			b.windowIncreasesLimited.Add(1)
+			return 0
+		}
+		if b.reserved.CompareAndSwap(int64(reserved), int64(reserved+granted)) {
+			if granted < n {
+				b.windowIncreasesLimited.Add(1)
+			}
+			return granted
+		}
+	}
+}
+
+func (b *MemoryBudget) release(n protocol.ByteCount) {
+	b.reserved.Add(-int64(n))
+}
+
+// NewAccount creates a new account that tracks the memory reserved by a single connection.
+func (b *MemoryBudget) NewAccount() *MemoryAccount {
+	return &MemoryAccount{budget: b}
+}
+
+// A MemoryAccount tracks the memory that a connection reserved from the MemoryBudget.
+type MemoryAccount struct {
+	budget *MemoryBudget
+
+	mutex    sync.Mutex
+	reserved protocol.ByteCount
+	closed   bool
+}
+
+// forceReserve reserves n bytes, even if this exceeds the budget.
+// It is used for the initial receive window, which was already advertised to the peer.
+func (a *MemoryAccount) forceReserve(n protocol.ByteCount) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.closed {
+		return
+	}
+	a.budget.reserved.Add(int64(n))
+	a.reserved += n
+}
+
+// reserve reserves up to n bytes, and returns the number of bytes reserved.
+func (a *MemoryAccount) reserve(n protocol.ByteCount) protocol.ByteCount {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.closed {
+		return 0
+	}
+	granted := a.budget.reserve(n)
+	a.reserved += granted
+	return granted
+}
+
+func (a *MemoryAccount) release(n protocol.ByteCount) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.closed {
+		return
+	}
+	a.budget.release(n)
+	a.reserved -= n
+}
+
+// Close releases all memory reserved by this account.
+// It must be called when the connection is closed.
+func (a *MemoryAccount) Close() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.closed {
+		return
+	}
+	a.closed = true
+	a.budget.release(a.reserved)
+	a.reserved = 0
+}
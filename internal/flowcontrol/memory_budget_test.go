@@ -0,0 +1,74 @@
+package flowcontrol
+
+import (
+	"github.com/nxenon/xquic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory Budget", func() {
+	It("reserves and releases memory", func() {
+		b := NewMemoryBudget(1000)
+		a := b.NewAccount()
+		Expect(a.reserve(100)).To(Equal(protocol.ByteCount(100)))
+		Expect(b.Reserved()).To(Equal(protocol.ByteCount(100)))
+		a.release(40)
+		Expect(b.Reserved()).To(Equal(protocol.ByteCount(60)))
+		Expect(b.UnderPressure()).To(BeFalse())
+		Expect(b.WindowIncreasesLimited()).To(BeZero())
+	})
+
+	It("halves reservations under memory pressure", func() {
+		b := NewMemoryBudget(1000)
+		a := b.NewAccount()
+		Expect(a.reserve(700)).To(Equal(protocol.ByteCount(700)))
+		Expect(b.UnderPressure()).To(BeFalse())
+		Expect(a.reserve(100)).To(Equal(protocol.ByteCount(100)))
+		Expect(b.UnderPressure()).To(BeTrue())
+		Expect(b.WindowIncreasesLimited()).To(BeZero())
+		Expect(a.reserve(100)).To(Equal(protocol.ByteCount(50)))
+		Expect(b.WindowIncreasesLimited()).To(BeEquivalentTo(1))
+	})
+
+	It("doesn't exceed the budget", func() {
+		b := NewMemoryBudget(1000)
+		a := b.NewAccount()
+		Expect(a.reserve(900)).To(Equal(protocol.ByteCount(900)))
+		Expect(a.reserve(400)).To(Equal(protocol.ByteCount(100)))
+		Expect(a.reserve(400)).To(BeZero())
+		Expect(b.Reserved()).To(Equal(protocol.ByteCount(1000)))
+		Expect(b.WindowIncreasesLimited()).To(BeEquivalentTo(2))
+	})
+
+	It("force-reserves memory, even if this exceeds the budget", func() {
+		b := NewMemoryBudget(1000)
+		a := b.NewAccount()
+		a.forceReserve(1200)
+		Expect(b.Reserved()).To(Equal(protocol.ByteCount(1200)))
+		Expect(a.reserve(1)).To(BeZero())
+	})
+
+	It("releases all memory when an account is closed", func() {
+		b := NewMemoryBudget(1000)
+		a1 := b.NewAccount()
+		a2 := b.NewAccount()
+		a1.forceReserve(100)
+		Expect(a1.reserve(200)).To(Equal(protocol.ByteCount(200)))
+		Expect(a2.reserve(300)).To(Equal(protocol.ByteCount(300)))
+		a1.Close()
+		Expect(b.Reserved()).To(Equal(protocol.ByteCount(300)))
+		// reservations on closed accounts fail
+		Expect(a1.reserve(100)).To(BeZero())
+		a1.release(100)
+		a1.Close()
+		Expect(b.Reserved()).To(Equal(protocol.ByteCount(300)))
+	})
+
+	It("counts dropped packets", func() {
+		b := NewMemoryBudget(1000)
+		b.DroppedPacket()
+		b.DroppedPacket()
+		Expect(b.PacketsDropped()).To(BeEquivalentTo(2))
+	})
+})
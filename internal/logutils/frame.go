@@ -15,6 +15,14 @@ func ConvertFrame(frame wire.Frame) logging.Frame {
 		// We use a pool for ACK frames.
 		// Implementations of the tracer interface may hold on to frames, so we need to make a copy here.
 		return ConvertAckFrame(f)
+	case *wire.MaxDataFrame:
+		// The frame parser reuses MAX_DATA and MAX_STREAM_DATA frames, so we need to make a copy here.
+		return &logging.MaxDataFrame{MaximumData: f.MaximumData}
+	case *wire.MaxStreamDataFrame:
+		return &logging.MaxStreamDataFrame{
+			StreamID:          f.StreamID,
+			MaximumStreamData: f.MaximumStreamData,
+		}
 	case *wire.CryptoFrame:
 		return &logging.CryptoFrame{
 			Offset: f.Offset,
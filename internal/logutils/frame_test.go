@@ -42,6 +42,18 @@ var _ = Describe("CRYPTO frame", func() {
 		Expect(df.Length).To(Equal(logging.ByteCount(6)))
 	})
 
+	It("copies MAX_DATA and MAX_STREAM_DATA frames", func() {
+		mdf := &wire.MaxDataFrame{MaximumData: 1234}
+		f := ConvertFrame(mdf)
+		mdf.MaximumData = 42
+		Expect(f).To(Equal(&logging.MaxDataFrame{MaximumData: 1234}))
+
+		msdf := &wire.MaxStreamDataFrame{StreamID: 4, MaximumStreamData: 1234}
+		f = ConvertFrame(msdf)
+		msdf.MaximumStreamData = 42
+		Expect(f).To(Equal(&logging.MaxStreamDataFrame{StreamID: 4, MaximumStreamData: 1234}))
+	})
+
 	It("converts other frames", func() {
 		f := ConvertFrame(&wire.MaxDataFrame{MaximumData: 1234})
 		Expect(f).To(BeAssignableToTypeOf(&logging.MaxDataFrame{}))
@@ -1051,6 +1051,42 @@ func (c *ConnectionTracerUpdatedKeyFromTLSCall) DoAndReturn(f func(protocol.Encr
 	return c
 }
 
+// UpdatedMTU mocks base method.
+func (m *MockConnectionTracer) UpdatedMTU(arg0 protocol.ByteCount, arg1 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdatedMTU", arg0, arg1)
+}
+
+// UpdatedMTU indicates an expected call of UpdatedMTU.
+func (mr *MockConnectionTracerMockRecorder) UpdatedMTU(arg0, arg1 any) *ConnectionTracerUpdatedMTUCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatedMTU", reflect.TypeOf((*MockConnectionTracer)(nil).UpdatedMTU), arg0, arg1)
+	return &ConnectionTracerUpdatedMTUCall{Call: call}
+}
+
+// ConnectionTracerUpdatedMTUCall wrap *gomock.Call
+type ConnectionTracerUpdatedMTUCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *ConnectionTracerUpdatedMTUCall) Return() *ConnectionTracerUpdatedMTUCall {
+	c.Call = c.Call.Return()
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *ConnectionTracerUpdatedMTUCall) Do(f func(protocol.ByteCount, bool)) *ConnectionTracerUpdatedMTUCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *ConnectionTracerUpdatedMTUCall) DoAndReturn(f func(protocol.ByteCount, bool)) *ConnectionTracerUpdatedMTUCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // UpdatedMetrics mocks base method.
 func (m *MockConnectionTracer) UpdatedMetrics(arg0 *utils.RTTStats, arg1, arg2 protocol.ByteCount, arg3 int) {
 	m.ctrl.T.Helper()
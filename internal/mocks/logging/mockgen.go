@@ -35,6 +35,7 @@ type ConnectionTracer interface {
 	UpdatedMetrics(rttStats *logging.RTTStats, cwnd, bytesInFlight logging.ByteCount, packetsInFlight int)
 	AcknowledgedPacket(logging.EncryptionLevel, logging.PacketNumber)
 	LostPacket(logging.EncryptionLevel, logging.PacketNumber, logging.PacketLossReason)
+	UpdatedMTU(mtu logging.ByteCount, done bool)
 	UpdatedCongestionState(logging.CongestionState)
 	UpdatedPTOCount(value uint32)
 	UpdatedKeyFromTLS(logging.EncryptionLevel, logging.Perspective)
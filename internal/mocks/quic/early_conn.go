@@ -617,3 +617,41 @@ func (c *EarlyConnectionSendDatagramCall) DoAndReturn(f func([]byte) error) *Ear
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
+
+// SetDSCP mocks base method.
+func (m *MockEarlyConnection) SetDSCP(arg0 uint8) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDSCP", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDSCP indicates an expected call of SetDSCP.
+func (mr *MockEarlyConnectionMockRecorder) SetDSCP(arg0 any) *EarlyConnectionSetDSCPCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDSCP", reflect.TypeOf((*MockEarlyConnection)(nil).SetDSCP), arg0)
+	return &EarlyConnectionSetDSCPCall{Call: call}
+}
+
+// EarlyConnectionSetDSCPCall wrap *gomock.Call
+type EarlyConnectionSetDSCPCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *EarlyConnectionSetDSCPCall) Return(arg0 error) *EarlyConnectionSetDSCPCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *EarlyConnectionSetDSCPCall) Do(f func(uint8) error) *EarlyConnectionSetDSCPCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *EarlyConnectionSetDSCPCall) DoAndReturn(f func(uint8) error) *EarlyConnectionSetDSCPCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
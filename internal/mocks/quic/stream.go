@@ -10,9 +10,11 @@ package mockquic
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 	time "time"
 
+	quic "github.com/nxenon/xquic-go"
 	protocol "github.com/nxenon/xquic-go/internal/protocol"
 	qerr "github.com/nxenon/xquic-go/internal/qerr"
 	gomock "go.uber.org/mock/gomock"
@@ -151,6 +153,44 @@ func (c *StreamCloseCall) DoAndReturn(f func() error) *StreamCloseCall {
 	return c
 }
 
+// Consume mocks base method.
+func (m *MockStream) Consume(arg0 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockStreamMockRecorder) Consume(arg0 any) *StreamConsumeCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockStream)(nil).Consume), arg0)
+	return &StreamConsumeCall{Call: call}
+}
+
+// StreamConsumeCall wrap *gomock.Call
+type StreamConsumeCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamConsumeCall) Return(arg0 error) *StreamConsumeCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamConsumeCall) Do(f func(int) error) *StreamConsumeCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamConsumeCall) DoAndReturn(f func(int) error) *StreamConsumeCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Context mocks base method.
 func (m *MockStream) Context() context.Context {
 	m.ctrl.T.Helper()
@@ -189,6 +229,45 @@ func (c *StreamContextCall) DoAndReturn(f func() context.Context) *StreamContext
 	return c
 }
 
+// Peek mocks base method.
+func (m *MockStream) Peek() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockStreamMockRecorder) Peek() *StreamPeekCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockStream)(nil).Peek))
+	return &StreamPeekCall{Call: call}
+}
+
+// StreamPeekCall wrap *gomock.Call
+type StreamPeekCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamPeekCall) Return(arg0 []byte, arg1 error) *StreamPeekCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamPeekCall) Do(f func() ([]byte, error)) *StreamPeekCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamPeekCall) DoAndReturn(f func() ([]byte, error)) *StreamPeekCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Read mocks base method.
 func (m *MockStream) Read(arg0 []byte) (int, error) {
 	m.ctrl.T.Helper()
@@ -228,6 +307,83 @@ func (c *StreamReadCall) DoAndReturn(f func([]byte) (int, error)) *StreamReadCal
 	return c
 }
 
+// ReadFrom mocks base method.
+func (m *MockStream) ReadFrom(arg0 io.Reader) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadFrom", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadFrom indicates an expected call of ReadFrom.
+func (mr *MockStreamMockRecorder) ReadFrom(arg0 any) *StreamReadFromCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFrom", reflect.TypeOf((*MockStream)(nil).ReadFrom), arg0)
+	return &StreamReadFromCall{Call: call}
+}
+
+// StreamReadFromCall wrap *gomock.Call
+type StreamReadFromCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamReadFromCall) Return(arg0 int64, arg1 error) *StreamReadFromCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamReadFromCall) Do(f func(io.Reader) (int64, error)) *StreamReadFromCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamReadFromCall) DoAndReturn(f func(io.Reader) (int64, error)) *StreamReadFromCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// SetDSCP mocks base method.
+func (m *MockStream) SetDSCP(arg0 uint8) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDSCP", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDSCP indicates an expected call of SetDSCP.
+func (mr *MockStreamMockRecorder) SetDSCP(arg0 any) *StreamSetDSCPCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDSCP", reflect.TypeOf((*MockStream)(nil).SetDSCP), arg0)
+	return &StreamSetDSCPCall{Call: call}
+}
+
+// StreamSetDSCPCall wrap *gomock.Call
+type StreamSetDSCPCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamSetDSCPCall) Return(arg0 error) *StreamSetDSCPCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamSetDSCPCall) Do(f func(uint8) error) *StreamSetDSCPCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamSetDSCPCall) DoAndReturn(f func(uint8) error) *StreamSetDSCPCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // SetDeadline mocks base method.
 func (m *MockStream) SetDeadline(arg0 time.Time) error {
 	m.ctrl.T.Helper()
@@ -266,6 +422,44 @@ func (c *StreamSetDeadlineCall) DoAndReturn(f func(time.Time) error) *StreamSetD
 	return c
 }
 
+// SetPriority mocks base method.
+func (m *MockStream) SetPriority(arg0 quic.StreamPriority) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPriority", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPriority indicates an expected call of SetPriority.
+func (mr *MockStreamMockRecorder) SetPriority(arg0 any) *StreamSetPriorityCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPriority", reflect.TypeOf((*MockStream)(nil).SetPriority), arg0)
+	return &StreamSetPriorityCall{Call: call}
+}
+
+// StreamSetPriorityCall wrap *gomock.Call
+type StreamSetPriorityCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamSetPriorityCall) Return(arg0 error) *StreamSetPriorityCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamSetPriorityCall) Do(f func(quic.StreamPriority) error) *StreamSetPriorityCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamSetPriorityCall) DoAndReturn(f func(quic.StreamPriority) error) *StreamSetPriorityCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // SetReadDeadline mocks base method.
 func (m *MockStream) SetReadDeadline(arg0 time.Time) error {
 	m.ctrl.T.Helper()
@@ -418,3 +612,42 @@ func (c *StreamWriteCall) DoAndReturn(f func([]byte) (int, error)) *StreamWriteC
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
+
+// WriteBuffer mocks base method.
+func (m *MockStream) WriteBuffer(arg0 []byte, arg1 func()) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteBuffer", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteBuffer indicates an expected call of WriteBuffer.
+func (mr *MockStreamMockRecorder) WriteBuffer(arg0, arg1 any) *StreamWriteBufferCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteBuffer", reflect.TypeOf((*MockStream)(nil).WriteBuffer), arg0, arg1)
+	return &StreamWriteBufferCall{Call: call}
+}
+
+// StreamWriteBufferCall wrap *gomock.Call
+type StreamWriteBufferCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamWriteBufferCall) Return(arg0 int, arg1 error) *StreamWriteBufferCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamWriteBufferCall) Do(f func([]byte, func()) (int, error)) *StreamWriteBufferCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamWriteBufferCall) DoAndReturn(f func([]byte, func()) (int, error)) *StreamWriteBufferCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
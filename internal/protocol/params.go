@@ -20,6 +20,10 @@ const MaxCongestionWindowPackets = 10000
 // MaxUndecryptablePackets limits the number of undecryptable packets that are queued in the connection.
 const MaxUndecryptablePackets = 32
 
+// MaxUndecryptablePacketsUnderMemoryPressure limits the number of undecryptable packets that are queued in the connection,
+// when the receive memory budget of the Transport is under pressure.
+const MaxUndecryptablePacketsUnderMemoryPressure = 8
+
 // ConnectionFlowControlMultiplier determines how much larger the connection flow control windows needs to be relative to any stream's flow control window
 // This is the value that Chromium is using
 const ConnectionFlowControlMultiplier = 1.5
@@ -181,3 +185,12 @@ const Max0RTTQueues = 32
 // To avoid blocking, this value has to be smaller than MaxConnUnprocessedPackets.
 // To avoid packets being dropped as undecryptable by the connection, this value has to be smaller than MaxUndecryptablePackets.
 const Max0RTTQueueLen = 31
+
+// Max0RTTQueuesUnderMemoryPressure is the maximum number of connections that we buffer 0-RTT packets for,
+// when the receive memory budget of the Transport is under pressure.
+const Max0RTTQueuesUnderMemoryPressure = 8
+
+// Max0RTTQueueLenUnderMemoryPressure is the maximum number of 0-RTT packets that we buffer for each connection,
+// when the receive memory budget of the Transport is under pressure.
+// It has to be smaller than MaxUndecryptablePacketsUnderMemoryPressure.
+const Max0RTTQueueLenUnderMemoryPressure = 7
@@ -113,6 +113,10 @@ const MaxPacketBufferSize = 1452
 // MaxLargePacketBufferSize is used when using GSO
 const MaxLargePacketBufferSize = 20 * 1024
 
+// MaxGROBufferSize is the size of the receive buffers used with GRO.
+// The kernel coalesces up to 64 KiB of datagrams into a single read.
+const MaxGROBufferSize = 64 * 1024
+
 // MinInitialPacketSize is the minimum size an Initial packet is required to have.
 const MinInitialPacketSize = 1200
 
@@ -0,0 +1,40 @@
+package utils
+
+import "time"
+
+// A Clock is a source of time.
+// It allows running connections on a simulated (virtual) clock.
+type Clock interface {
+	Now() time.Time
+	// NewTimer creates a new timer that fires after the duration d.
+	NewTimer(d time.Duration) ClockTimer
+}
+
+// A ClockTimer is a timer created by a Clock.
+// It behaves like a time.Timer.
+type ClockTimer interface {
+	Chan() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// DefaultClock implements the Clock interface using the Go stdlib clock.
+type DefaultClock struct{}
+
+var _ Clock = DefaultClock{}
+
+// Now gets the current time
+func (DefaultClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a new time.Timer
+func (DefaultClock) NewTimer(d time.Duration) ClockTimer {
+	return &stdlibTimer{Timer: time.NewTimer(d)}
+}
+
+type stdlibTimer struct {
+	*time.Timer
+}
+
+func (t *stdlibTimer) Chan() <-chan time.Time { return t.C }
@@ -45,6 +45,22 @@ func (r *RingBuffer[T]) PushBack(t T) {
 	}
 }
 
+// PushFront adds a new element in front of all other elements.
+// If the ring buffer is full, its capacity is increased first.
+func (r *RingBuffer[T]) PushFront(t T) {
+	if r.full || len(r.ring) == 0 {
+		r.grow()
+	}
+	r.headPos--
+	if r.headPos < 0 {
+		r.headPos = len(r.ring) - 1
+	}
+	r.ring[r.headPos] = t
+	if r.tailPos == r.headPos {
+		r.full = true
+	}
+}
+
 // PopFront returns the next element.
 // It must not be called when the buffer is empty, that means that
 // callers might need to check if there are elements in the buffer first.
@@ -29,6 +29,23 @@ var _ = Describe("RingBuffer", func() {
 		Expect(r.PopFront()).To(Equal(6))
 	})
 
+	It("pushes to the front", func() {
+		r := RingBuffer[int]{}
+		r.PushFront(1)
+		r.PushBack(2)
+		r.PushFront(3)
+		Expect(r.Len()).To(Equal(3))
+		r.PushFront(4)
+		r.PushFront(5) // causes the buffer to grow
+		Expect(r.Len()).To(Equal(5))
+		Expect(r.PopFront()).To(Equal(5))
+		Expect(r.PopFront()).To(Equal(4))
+		Expect(r.PopFront()).To(Equal(3))
+		Expect(r.PopFront()).To(Equal(1))
+		Expect(r.PopFront()).To(Equal(2))
+		Expect(r.Empty()).To(BeTrue())
+	})
+
 	It("panics when Peek or Pop are called on an empty buffer", func() {
 		r := RingBuffer[string]{}
 		Expect(r.Empty()).To(BeTrue())
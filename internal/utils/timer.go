@@ -7,19 +7,25 @@ import (
 
 // A Timer wrapper that behaves correctly when resetting
 type Timer struct {
-	t        *time.Timer
+	t        ClockTimer
+	clock    Clock
 	read     bool
 	deadline time.Time
 }
 
 // NewTimer creates a new timer that is not set
 func NewTimer() *Timer {
-	return &Timer{t: time.NewTimer(time.Duration(math.MaxInt64))}
+	return NewTimerWithClock(DefaultClock{})
+}
+
+// NewTimerWithClock creates a new timer that is not set, using the clock
+func NewTimerWithClock(clock Clock) *Timer {
+	return &Timer{t: clock.NewTimer(time.Duration(math.MaxInt64)), clock: clock}
 }
 
 // Chan returns the channel of the wrapped timer
 func (t *Timer) Chan() <-chan time.Time {
-	return t.t.C
+	return t.t.Chan()
 }
 
 // Reset the timer, no matter whether the value was read or not
@@ -32,10 +38,10 @@ func (t *Timer) Reset(deadline time.Time) {
 	// We need to drain the timer if the value from its channel was not read yet.
 	// See https://groups.google.com/forum/#!topic/golang-dev/c9UUfASVPoU
 	if !t.t.Stop() && !t.read {
-		<-t.t.C
+		<-t.t.Chan()
 	}
 	if !deadline.IsZero() {
-		t.t.Reset(time.Until(deadline))
+		t.t.Reset(deadline.Sub(t.clock.Now()))
 	}
 
 	t.read = false
@@ -18,6 +18,8 @@ var MaxDatagramSize protocol.ByteCount = 16383
 type DatagramFrame struct {
 	DataLenPresent bool
 	Data           []byte
+
+	fromPool bool
 }
 
 func parseDatagramFrame(r *bytes.Reader, typ uint64, _ protocol.VersionNumber) (*DatagramFrame, error) {
@@ -58,6 +60,13 @@ func (f *DatagramFrame) Append(b []byte, _ protocol.VersionNumber) ([]byte, erro
 	return b, nil
 }
 
+// PutBack returns the frame to the pool.
+// It is a no-op for frames that were not obtained by GetDatagramFrame.
+// The frame must not be used afterwards.
+func (f *DatagramFrame) PutBack() {
+	putDatagramFrame(f)
+}
+
 // MaxDataLen returns the maximum data length
 func (f *DatagramFrame) MaxDataLen(maxSize protocol.ByteCount, version protocol.VersionNumber) protocol.ByteCount {
 	headerLen := protocol.ByteCount(1)
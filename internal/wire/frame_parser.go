@@ -45,6 +45,10 @@ type frameParser struct {
 	// To avoid allocating when parsing, keep a single ACK frame struct.
 	// It is used over and over again.
 	ackFrame *AckFrame
+	// The same applies to MAX_DATA and MAX_STREAM_DATA frames,
+	// which are received regularly during a transfer.
+	maxDataFrame       *MaxDataFrame
+	maxStreamDataFrame *MaxStreamDataFrame
 }
 
 var _ FrameParser = &frameParser{}
@@ -52,9 +56,11 @@ var _ FrameParser = &frameParser{}
 // NewFrameParser creates a new frame parser.
 func NewFrameParser(supportsDatagrams bool) *frameParser {
 	return &frameParser{
-		r:                 *bytes.NewReader(nil),
-		supportsDatagrams: supportsDatagrams,
-		ackFrame:          &AckFrame{},
+		r:                  *bytes.NewReader(nil),
+		supportsDatagrams:  supportsDatagrams,
+		ackFrame:           &AckFrame{},
+		maxDataFrame:       &MaxDataFrame{},
+		maxStreamDataFrame: &MaxStreamDataFrame{},
 	}
 }
 
@@ -121,9 +127,11 @@ func (p *frameParser) parseFrame(r *bytes.Reader, typ uint64, encLevel protocol.
 		case newTokenFrameType:
 			frame, err = parseNewTokenFrame(r, v)
 		case maxDataFrameType:
-			frame, err = parseMaxDataFrame(r, v)
+			err = parseMaxDataFrame(p.maxDataFrame, r, v)
+			frame = p.maxDataFrame
 		case maxStreamDataFrameType:
-			frame, err = parseMaxStreamDataFrame(r, v)
+			err = parseMaxStreamDataFrame(p.maxStreamDataFrame, r, v)
+			frame = p.maxStreamDataFrame
 		case bidiMaxStreamsFrameType, uniMaxStreamsFrameType:
 			frame, err = parseMaxStreamsFrame(r, typ, v)
 		case dataBlockedFrameType:
@@ -13,14 +13,13 @@ type MaxDataFrame struct {
 }
 
 // parseMaxDataFrame parses a MAX_DATA frame
-func parseMaxDataFrame(r *bytes.Reader, _ protocol.VersionNumber) (*MaxDataFrame, error) {
-	frame := &MaxDataFrame{}
+func parseMaxDataFrame(frame *MaxDataFrame, r *bytes.Reader, _ protocol.VersionNumber) error {
 	byteOffset, err := quicvarint.Read(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	frame.MaximumData = protocol.ByteCount(byteOffset)
-	return frame, nil
+	return nil
 }
 
 func (f *MaxDataFrame) Append(b []byte, _ protocol.VersionNumber) ([]byte, error) {
@@ -16,19 +16,18 @@ var _ = Describe("MAX_DATA frame", func() {
 		It("accepts sample frame", func() {
 			data := encodeVarInt(0xdecafbad123456) // byte offset
 			b := bytes.NewReader(data)
-			frame, err := parseMaxDataFrame(b, protocol.Version1)
-			Expect(err).ToNot(HaveOccurred())
+			var frame MaxDataFrame
+			Expect(parseMaxDataFrame(&frame, b, protocol.Version1)).To(Succeed())
 			Expect(frame.MaximumData).To(Equal(protocol.ByteCount(0xdecafbad123456)))
 			Expect(b.Len()).To(BeZero())
 		})
 
 		It("errors on EOFs", func() {
 			data := encodeVarInt(0xdecafbad1234567) // byte offset
-			_, err := parseMaxDataFrame(bytes.NewReader(data), protocol.Version1)
-			Expect(err).NotTo(HaveOccurred())
+			var frame MaxDataFrame
+			Expect(parseMaxDataFrame(&frame, bytes.NewReader(data), protocol.Version1)).To(Succeed())
 			for i := range data {
-				_, err := parseMaxDataFrame(bytes.NewReader(data[:i]), protocol.Version1)
-				Expect(err).To(MatchError(io.EOF))
+				Expect(parseMaxDataFrame(&frame, bytes.NewReader(data[:i]), protocol.Version1)).To(MatchError(io.EOF))
 			}
 		})
 	})
@@ -13,20 +13,18 @@ type MaxStreamDataFrame struct {
 	MaximumStreamData protocol.ByteCount
 }
 
-func parseMaxStreamDataFrame(r *bytes.Reader, _ protocol.VersionNumber) (*MaxStreamDataFrame, error) {
+func parseMaxStreamDataFrame(frame *MaxStreamDataFrame, r *bytes.Reader, _ protocol.VersionNumber) error {
 	sid, err := quicvarint.Read(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	offset, err := quicvarint.Read(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return &MaxStreamDataFrame{
-		StreamID:          protocol.StreamID(sid),
-		MaximumStreamData: protocol.ByteCount(offset),
-	}, nil
+	frame.StreamID = protocol.StreamID(sid)
+	frame.MaximumStreamData = protocol.ByteCount(offset)
+	return nil
 }
 
 func (f *MaxStreamDataFrame) Append(b []byte, version protocol.VersionNumber) ([]byte, error) {
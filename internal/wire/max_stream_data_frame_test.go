@@ -17,8 +17,8 @@ var _ = Describe("MAX_STREAM_DATA frame", func() {
 			data := encodeVarInt(0xdeadbeef)                 // Stream ID
 			data = append(data, encodeVarInt(0x12345678)...) // Offset
 			b := bytes.NewReader(data)
-			frame, err := parseMaxStreamDataFrame(b, protocol.Version1)
-			Expect(err).ToNot(HaveOccurred())
+			var frame MaxStreamDataFrame
+			Expect(parseMaxStreamDataFrame(&frame, b, protocol.Version1)).To(Succeed())
 			Expect(frame.StreamID).To(Equal(protocol.StreamID(0xdeadbeef)))
 			Expect(frame.MaximumStreamData).To(Equal(protocol.ByteCount(0x12345678)))
 			Expect(b.Len()).To(BeZero())
@@ -28,11 +28,10 @@ var _ = Describe("MAX_STREAM_DATA frame", func() {
 			data := encodeVarInt(0xdeadbeef)                 // Stream ID
 			data = append(data, encodeVarInt(0x12345678)...) // Offset
 			b := bytes.NewReader(data)
-			_, err := parseMaxStreamDataFrame(b, protocol.Version1)
-			Expect(err).NotTo(HaveOccurred())
+			var frame MaxStreamDataFrame
+			Expect(parseMaxStreamDataFrame(&frame, b, protocol.Version1)).To(Succeed())
 			for i := range data {
-				_, err := parseMaxStreamDataFrame(bytes.NewReader(data[:i]), protocol.Version1)
-				Expect(err).To(MatchError(io.EOF))
+				Expect(parseMaxStreamDataFrame(&frame, bytes.NewReader(data[:i]), protocol.Version1)).To(MatchError(io.EOF))
 			}
 		})
 	})
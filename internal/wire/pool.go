@@ -6,7 +6,7 @@ import (
 	"github.com/nxenon/xquic-go/internal/protocol"
 )
 
-var pool sync.Pool
+var pool, largePool, datagramPool sync.Pool
 
 func init() {
 	pool.New = func() interface{} {
@@ -15,6 +15,18 @@ func init() {
 			fromPool: true,
 		}
 	}
+	largePool.New = func() interface{} {
+		return &StreamFrame{
+			Data:     make([]byte, 0, protocol.MaxLargePacketBufferSize),
+			fromPool: true,
+		}
+	}
+	datagramPool.New = func() interface{} {
+		return &DatagramFrame{
+			Data:     make([]byte, 0, protocol.MaxPacketBufferSize),
+			fromPool: true,
+		}
+	}
 }
 
 func GetStreamFrame() *StreamFrame {
@@ -22,12 +34,38 @@ func GetStreamFrame() *StreamFrame {
 	return f
 }
 
+// GetLargeStreamFrame returns a STREAM frame that can hold the data of a packet larger than protocol.MaxPacketBufferSize.
+// It is used when sending and receiving jumbo packets.
+func GetLargeStreamFrame() *StreamFrame {
+	f := largePool.Get().(*StreamFrame)
+	return f
+}
+
 func putStreamFrame(f *StreamFrame) {
 	if !f.fromPool {
 		return
 	}
-	if protocol.ByteCount(cap(f.Data)) != protocol.MaxPacketBufferSize {
+	switch protocol.ByteCount(cap(f.Data)) {
+	case protocol.MaxPacketBufferSize:
+		pool.Put(f)
+	case protocol.MaxLargePacketBufferSize:
+		largePool.Put(f)
+	default:
 		panic("wire.PutStreamFrame called with packet of wrong size!")
 	}
-	pool.Put(f)
+}
+
+// GetDatagramFrame returns a DATAGRAM frame that can hold up to protocol.MaxPacketBufferSize bytes of data.
+// Larger datagrams can be stored as well, but then the frame won't be returned to the pool.
+func GetDatagramFrame() *DatagramFrame {
+	return datagramPool.Get().(*DatagramFrame)
+}
+
+func putDatagramFrame(f *DatagramFrame) {
+	if !f.fromPool || cap(f.Data) != protocol.MaxPacketBufferSize {
+		return
+	}
+	f.DataLenPresent = false
+	f.Data = f.Data[:0]
+	datagramPool.Put(f)
 }
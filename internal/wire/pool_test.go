@@ -1,6 +1,8 @@
 package wire
 
 import (
+	"github.com/nxenon/xquic-go/internal/protocol"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -11,6 +13,12 @@ var _ = Describe("Pool", func() {
 		putStreamFrame(f)
 	})
 
+	It("gets and puts large STREAM frames", func() {
+		f := GetLargeStreamFrame()
+		Expect(f.Data).To(HaveCap(protocol.MaxLargePacketBufferSize))
+		putStreamFrame(f)
+	})
+
 	It("panics when putting a STREAM frame with a wrong capacity", func() {
 		f := GetStreamFrame()
 		f.Data = []byte("foobar")
@@ -21,4 +29,28 @@ var _ = Describe("Pool", func() {
 		f := &StreamFrame{Data: []byte("foobar")}
 		putStreamFrame(f)
 	})
+
+	It("gets and puts DATAGRAM frames", func() {
+		f := GetDatagramFrame()
+		Expect(f.Data).To(BeEmpty())
+		Expect(f.Data).To(HaveCap(protocol.MaxPacketBufferSize))
+		f.DataLenPresent = true
+		f.Data = append(f.Data, []byte("foobar")...)
+		f.PutBack()
+		Expect(f.DataLenPresent).To(BeFalse())
+		Expect(f.Data).To(BeEmpty())
+	})
+
+	It("doesn't put DATAGRAM frames with a wrong capacity back", func() {
+		f := GetDatagramFrame()
+		f.Data = append(f.Data, make([]byte, protocol.MaxPacketBufferSize+1)...)
+		f.PutBack()
+		Expect(f.Data).To(HaveLen(protocol.MaxPacketBufferSize + 1))
+	})
+
+	It("accepts DATAGRAM frames not from the pool, but ignores them", func() {
+		f := &DatagramFrame{Data: []byte("foobar")}
+		f.PutBack()
+		Expect(f.Data).To(Equal([]byte("foobar")))
+	})
 })
@@ -53,10 +53,15 @@ func parseStreamFrame(r *bytes.Reader, typ uint64, _ protocol.VersionNumber) (*S
 	if dataLen < protocol.MinStreamFrameBufferSize {
 		frame = &StreamFrame{Data: make([]byte, dataLen)}
 	} else {
-		frame = GetStreamFrame()
+		if dataLen > protocol.MaxPacketBufferSize {
+			frame = GetLargeStreamFrame()
+		} else {
+			frame = GetStreamFrame()
+		}
 		// The STREAM frame can't be larger than the StreamFrame we obtained from the buffer,
 		// since those StreamFrames have a buffer length of the maximum packet size.
 		if dataLen > uint64(cap(frame.Data)) {
+			frame.PutBack()
 			return nil, io.EOF
 		}
 		frame.Data = frame.Data[:dataLen]
@@ -161,7 +166,17 @@ func (f *StreamFrame) MaybeSplitOffFrame(maxSize protocol.ByteCount, version pro
 		return nil, true
 	}
 
-	new := GetStreamFrame()
+	// The remaining data is copied into the buffer of the new frame,
+	// so it needs to be large enough to hold it (e.g. when splitting a frame obtained from GetLargeStreamFrame).
+	var new *StreamFrame
+	switch remaining := protocol.ByteCount(len(f.Data)) - n; {
+	case remaining <= protocol.MaxPacketBufferSize:
+		new = GetStreamFrame()
+	case remaining <= protocol.MaxLargePacketBufferSize:
+		new = GetLargeStreamFrame()
+	default:
+		new = &StreamFrame{Data: make([]byte, 0, remaining)}
+	}
 	new.StreamID = f.StreamID
 	new.Offset = f.Offset
 	new.Fin = false
@@ -2,6 +2,7 @@ package wire
 
 import (
 	"bytes"
+	"crypto/rand"
 	"io"
 
 	"github.com/nxenon/xquic-go/internal/protocol"
@@ -76,14 +77,26 @@ var _ = Describe("STREAM frame", func() {
 		})
 
 		It("rejects frames that claim to be longer than the packet size", func() {
-			data := encodeVarInt(0x12345)                                                // stream ID
-			data = append(data, encodeVarInt(uint64(protocol.MaxPacketBufferSize)+1)...) // data length
-			data = append(data, make([]byte, protocol.MaxPacketBufferSize+1)...)
+			data := encodeVarInt(0x12345)                                                     // stream ID
+			data = append(data, encodeVarInt(uint64(protocol.MaxLargePacketBufferSize)+1)...) // data length
+			data = append(data, make([]byte, protocol.MaxLargePacketBufferSize+1)...)
 			r := bytes.NewReader(data)
 			_, err := parseStreamFrame(r, 0x8^0x2, protocol.Version1)
 			Expect(err).To(Equal(io.EOF))
 		})
 
+		It("parses frames larger than the default packet size", func() {
+			data := encodeVarInt(0x12345)                      // stream ID
+			data = append(data, encodeVarInt(uint64(8000))...) // data length
+			data = append(data, bytes.Repeat([]byte{'f'}, 8000)...)
+			r := bytes.NewReader(data)
+			frame, err := parseStreamFrame(r, 0x8^0x2, protocol.Version1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Data).To(Equal(bytes.Repeat([]byte{'f'}, 8000)))
+			Expect(frame.fromPool).To(BeTrue())
+			frame.PutBack()
+		})
+
 		It("errors on EOFs", func() {
 			typ := uint64(0x8 ^ 0x4 ^ 0x2)
 			data := encodeVarInt(0x12345)                    // stream ID
@@ -368,6 +381,38 @@ var _ = Describe("STREAM frame", func() {
 			Expect(frame.Fin).To(BeFalse())
 		})
 
+		It("splits large frames", func() {
+			data := make([]byte, 10000)
+			rand.Read(data)
+			f := GetLargeStreamFrame()
+			f.StreamID = 0x1337
+			f.Offset = 0x100
+			f.DataLenPresent = true
+			f.Data = append(f.Data, data...)
+			frame, needsSplit := f.MaybeSplitOffFrame(1000, protocol.Version1)
+			Expect(needsSplit).To(BeTrue())
+			Expect(frame.Length(protocol.Version1)).To(BeNumerically("<=", 1000))
+			n := frame.DataLen()
+			Expect(frame.Offset).To(Equal(protocol.ByteCount(0x100)))
+			Expect(frame.Data).To(Equal(data[:n]))
+			Expect(f.Offset).To(Equal(0x100 + n))
+			Expect(f.Data).To(Equal(data[n:]))
+			frame.PutBack()
+			f.PutBack()
+		})
+
+		It("splits frames that are larger than a large frame buffer", func() {
+			data := make([]byte, 2*protocol.MaxLargePacketBufferSize)
+			rand.Read(data)
+			f := &StreamFrame{StreamID: 0x1337, Data: data}
+			frame, needsSplit := f.MaybeSplitOffFrame(1000, protocol.Version1)
+			Expect(needsSplit).To(BeTrue())
+			n := frame.DataLen()
+			Expect(frame.Data).To(Equal(data[:n]))
+			Expect(f.Offset).To(Equal(n))
+			Expect(f.Data).To(Equal(data[n:]))
+		})
+
 		It("produces frames of the correct length, without data len", func() {
 			const size = 1000
 			f := &StreamFrame{
@@ -29,7 +29,7 @@ func GetSSLKeyLog() (io.WriteCloser, error) {
 }
 
 // NewQLOGConnectionTracer create a qlog file in QLOGDIR
-func NewQLOGConnectionTracer(_ context.Context, p logging.Perspective, connID quic.ConnectionID) *logging.ConnectionTracer {
+func NewQLOGConnectionTracer(ctx context.Context, p logging.Perspective, connID quic.ConnectionID) *logging.ConnectionTracer {
 	qlogDir := os.Getenv("QLOGDIR")
 	if len(qlogDir) == 0 {
 		return nil
@@ -46,5 +46,5 @@ func NewQLOGConnectionTracer(_ context.Context, p logging.Perspective, connID qu
 		return nil
 	}
 	log.Printf("Created qlog file: %s\n", path)
-	return qlog.NewConnectionTracer(utils.NewBufferedWriteCloser(bufio.NewWriter(f), f), p, connID)
+	return qlog.NewConnectionTracerWithContext(ctx, utils.NewBufferedWriteCloser(bufio.NewWriter(f), f), p, connID)
 }
@@ -0,0 +1,139 @@
+// Package introspection provides an HTTP handler that lists the connections handled by a quic.Transport.
+//
+// The handler is intended to be exposed on a debug port, similar to net/http/pprof:
+//
+//	http.Handle("/debug/quic", introspection.Handler(tr))
+//
+// By default, the handler renders an HTML page. JSON is returned if the "format=json" query parameter is set,
+// or if the request's Accept header asks for application/json.
+package introspection
+
+import (
+	"encoding/json"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+)
+
+// Connection is the JSON representation of a quic.ConnectionInfo.
+type Connection struct {
+	Perspective   string   `json:"perspective"`
+	ConnectionIDs []string `json:"connection_ids"`
+	LocalAddr     string   `json:"local_address"`
+	RemoteAddr    string   `json:"remote_address"`
+	Status        string   `json:"status"`
+	Version       string   `json:"version"`
+	ALPN          string   `json:"alpn,omitempty"`
+	Stats         Stats    `json:"stats"`
+}
+
+// Stats is the JSON representation of a quic.ConnectionStats.
+// RTTs are given in milliseconds.
+type Stats struct {
+	MinRTT          float64 `json:"min_rtt"`
+	LatestRTT       float64 `json:"latest_rtt"`
+	SmoothedRTT     float64 `json:"smoothed_rtt"`
+	BytesSent       uint64  `json:"bytes_sent"`
+	PacketsSent     uint64  `json:"packets_sent"`
+	BytesReceived   uint64  `json:"bytes_received"`
+	PacketsReceived uint64  `json:"packets_received"`
+}
+
+type handler struct {
+	connections func() []quic.ConnectionInfo
+}
+
+// Handler returns an http.Handler that lists the connections handled by the Transport.
+func Handler(tr *quic.Transport) http.Handler {
+	return &handler{connections: tr.Connections}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	infos := h.connections()
+	conns := make([]Connection, 0, len(infos))
+	for _, info := range infos {
+		conns = append(conns, newConnection(info))
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(conns)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	htmlTemplate.Execute(w, conns)
+}
+
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func newConnection(info quic.ConnectionInfo) Connection {
+	connIDs := make([]string, 0, len(info.ConnectionIDs))
+	for _, id := range info.ConnectionIDs {
+		connIDs = append(connIDs, id.String())
+	}
+	return Connection{
+		Perspective:   strings.ToLower(info.Perspective.String()),
+		ConnectionIDs: connIDs,
+		LocalAddr:     addrString(info.LocalAddr),
+		RemoteAddr:    addrString(info.RemoteAddr),
+		Status:        info.Status.String(),
+		Version:       info.Version.String(),
+		ALPN:          info.ALPN,
+		Stats: Stats{
+			MinRTT:          milliseconds(info.Stats.MinRTT),
+			LatestRTT:       milliseconds(info.Stats.LatestRTT),
+			SmoothedRTT:     milliseconds(info.Stats.SmoothedRTT),
+			BytesSent:       info.Stats.BytesSent,
+			PacketsSent:     info.Stats.PacketsSent,
+			BytesReceived:   info.Stats.BytesReceived,
+			PacketsReceived: info.Stats.PacketsReceived,
+		},
+	}
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+var htmlTemplate = template.Must(template.New("connections").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>QUIC Connections</title>
+<style>
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; font-family: monospace; text-align: left; }
+</style>
+</head>
+<body>
+<h1>QUIC Connections ({{len .}})</h1>
+<table>
+<tr>
+<th>Perspective</th><th>Connection IDs</th><th>Local Address</th><th>Remote Address</th><th>Status</th><th>Version</th><th>ALPN</th>
+<th>Smoothed RTT (ms)</th><th>Min RTT (ms)</th><th>Bytes Sent</th><th>Packets Sent</th><th>Bytes Received</th><th>Packets Received</th>
+</tr>
+{{range .}}<tr>
+<td>{{.Perspective}}</td><td>{{range $i, $id := .ConnectionIDs}}{{if $i}}<br>{{end}}{{$id}}{{end}}</td><td>{{.LocalAddr}}</td><td>{{.RemoteAddr}}</td><td>{{.Status}}</td><td>{{.Version}}</td><td>{{.ALPN}}</td>
+<td>{{printf "%.3f" .Stats.SmoothedRTT}}</td><td>{{printf "%.3f" .Stats.MinRTT}}</td><td>{{.Stats.BytesSent}}</td><td>{{.Stats.PacketsSent}}</td><td>{{.Stats.BytesReceived}}</td><td>{{.Stats.PacketsReceived}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
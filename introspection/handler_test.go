@@ -0,0 +1,90 @@
+package introspection
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/internal/protocol"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHandler() http.Handler {
+	return &handler{connections: func() []quic.ConnectionInfo {
+		return []quic.ConnectionInfo{
+			{
+				Perspective: protocol.PerspectiveServer,
+				ConnectionIDs: []quic.ConnectionID{
+					protocol.ParseConnectionID([]byte{0xde, 0xad, 0xbe, 0xef}),
+					protocol.ParseConnectionID([]byte{0xca, 0xfe}),
+				},
+				LocalAddr:  &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 443},
+				RemoteAddr: &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337},
+				Status:     quic.ConnectionStatusEstablished,
+				Version:    protocol.Version1,
+				ALPN:       "h3",
+				Stats: quic.ConnectionStats{
+					SmoothedRTT: 1500 * time.Microsecond,
+					BytesSent:   1234,
+					PacketsSent: 5,
+				},
+			},
+		}
+	}}
+}
+
+func TestHandlerJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		target string
+		accept string
+	}{
+		{name: "query parameter", target: "/?format=json"},
+		{name: "Accept header", target: "/", accept: "application/json"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			newTestHandler().ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var conns []Connection
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &conns))
+			require.Equal(t, []Connection{{
+				Perspective:   "server",
+				ConnectionIDs: []string{"deadbeef", "cafe"},
+				LocalAddr:     "127.0.0.1:443",
+				RemoteAddr:    "192.168.0.1:1337",
+				Status:        "established",
+				Version:       "v1",
+				ALPN:          "h3",
+				Stats: Stats{
+					SmoothedRTT: 1.5,
+					BytesSent:   1234,
+					PacketsSent: 5,
+				},
+			}}, conns)
+		})
+	}
+}
+
+func TestHandlerHTML(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	require.Contains(t, body, "QUIC Connections (1)")
+	require.Contains(t, body, "deadbeef<br>cafe")
+	require.Contains(t, body, "192.168.0.1:1337")
+	require.Contains(t, body, "<td>1.500</td>")
+}
@@ -0,0 +1,189 @@
+package masque
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nxenon/xquic-go/http3"
+)
+
+// A Client establishes CONNECT-UDP and CONNECT-IP tunnels through a proxy.
+type Client struct {
+	// RoundTripper is used to send the CONNECT-UDP and CONNECT-IP requests.
+	// If it has EnableDatagrams set, UDP payloads and IP packets are sent in HTTP datagrams,
+	// otherwise they are sent in DATAGRAM capsules on the request stream.
+	RoundTripper *http3.RoundTripper
+}
+
+// Dial establishes a tunnel to target (host:port) through the proxy described by the template.
+// The returned net.PacketConn sends and receives UDP payloads to and from the target.
+// If the proxy rejects the request, the response is returned together with an error.
+func (c *Client) Dial(ctx context.Context, t *Template, target string) (net.PacketConn, *http.Response, error) {
+	if c.RoundTripper == nil {
+		return nil, nil, errors.New("masque: no RoundTripper configured")
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodConnect, t.Expand(map[string]string{
+		varTargetHost: host,
+		varTargetPort: port,
+	}), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set(capsuleProtocolHeader, capsuleProtocolHeaderValue)
+	rsp, str, err := c.RoundTripper.ExtendedConnect(req, connectUDPProtocol)
+	if err != nil {
+		return nil, rsp, err
+	}
+	return newProxiedConn(newTunnel(str, nil), str.LocalAddr(), targetAddr(target)), rsp, nil
+}
+
+// DialIP establishes a CONNECT-IP tunnel through the proxy described by the template.
+// The tunnel is scoped to target ("*" or "" for any target, or a hostname, an IP address or an IP prefix)
+// and to the IP protocol ipProto (0 for any IP protocol), if the template contains the respective variables.
+// If the proxy rejects the request, the response is returned together with an error.
+func (c *Client) DialIP(ctx context.Context, t *Template, target string, ipProto uint8) (*IPConn, *http.Response, error) {
+	if c.RoundTripper == nil {
+		return nil, nil, errors.New("masque: no RoundTripper configured")
+	}
+	if target == "" {
+		target = "*"
+	}
+	proto := "*"
+	if ipProto != 0 {
+		proto = strconv.Itoa(int(ipProto))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodConnect, t.Expand(map[string]string{
+		varTarget:  target,
+		varIPProto: proto,
+	}), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set(capsuleProtocolHeader, capsuleProtocolHeaderValue)
+	rsp, str, err := c.RoundTripper.ExtendedConnect(req, connectIPProtocol)
+	if err != nil {
+		return nil, rsp, err
+	}
+	return newIPConn(str), rsp, nil
+}
+
+// targetAddr is the address of the target of a tunnel.
+type targetAddr string
+
+func (a targetAddr) Network() string { return "udp" }
+func (a targetAddr) String() string  { return string(a) }
+
+// A proxiedConn is a net.PacketConn that sends and receives UDP payloads through a tunnel.
+type proxiedConn struct {
+	tunnel                *tunnel
+	localAddr, remoteAddr net.Addr
+
+	deadlineMutex   sync.Mutex
+	readDeadline    time.Time
+	deadlineChanged chan struct{} // closed when the read deadline changes
+}
+
+var _ net.PacketConn = &proxiedConn{}
+
+func newProxiedConn(t *tunnel, localAddr, remoteAddr net.Addr) *proxiedConn {
+	return &proxiedConn{
+		tunnel:          t,
+		localAddr:       localAddr,
+		remoteAddr:      remoteAddr,
+		deadlineChanged: make(chan struct{}),
+	}
+}
+
+// ReadFrom reads the next UDP payload received from the target.
+// The returned address is the target of the tunnel.
+func (c *proxiedConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		c.deadlineMutex.Lock()
+		deadline := c.readDeadline
+		deadlineChanged := c.deadlineChanged
+		c.deadlineMutex.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, nil, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+		n, err, done := c.readOrWait(b, timeout, deadlineChanged)
+		if timer != nil {
+			timer.Stop()
+		}
+		if done {
+			if err != nil {
+				return 0, nil, err
+			}
+			return n, c.remoteAddr, nil
+		}
+	}
+}
+
+// readOrWait reads the next payload, unless the timeout expires or the deadline is changed before.
+// It returns false if the deadline was changed.
+func (c *proxiedConn) readOrWait(b []byte, timeout <-chan time.Time, deadlineChanged <-chan struct{}) (int, error, bool) {
+	select {
+	case p := <-c.tunnel.queue:
+		return copy(b, p), nil, true
+	case <-c.tunnel.closed:
+		return 0, c.tunnel.closeErr, true
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded, true
+	case <-deadlineChanged:
+		return 0, nil, false
+	}
+}
+
+// WriteTo sends a UDP payload to the target.
+// Since the tunnel is bound to a single target, the address is ignored.
+func (c *proxiedConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	if err := c.tunnel.send(b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes the tunnel.
+func (c *proxiedConn) Close() error {
+	c.tunnel.close(net.ErrClosed)
+	return nil
+}
+
+func (c *proxiedConn) LocalAddr() net.Addr { return c.localAddr }
+
+func (c *proxiedConn) SetDeadline(t time.Time) error {
+	_ = c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *proxiedConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMutex.Lock()
+	defer c.deadlineMutex.Unlock()
+	c.readDeadline = t
+	close(c.deadlineChanged)
+	c.deadlineChanged = make(chan struct{})
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for writing payloads that are sent in DATAGRAM capsules.
+// Sending HTTP datagrams never blocks.
+func (c *proxiedConn) SetWriteDeadline(t time.Time) error {
+	return c.tunnel.str.SetWriteDeadline(t)
+}
@@ -0,0 +1,254 @@
+package masque
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/nxenon/xquic-go/http3"
+	"github.com/nxenon/xquic-go/quicvarint"
+)
+
+// the value of the :protocol pseudo header of CONNECT-IP requests
+const connectIPProtocol = "connect-ip"
+
+// capsule types defined in section 4.7 of RFC 9484
+const (
+	capsuleTypeAddressAssign      http3.CapsuleType = 0x01
+	capsuleTypeAddressRequest     http3.CapsuleType = 0x02
+	capsuleTypeRouteAdvertisement http3.CapsuleType = 0x03
+)
+
+// maxIPCapsuleLen is the maximum length of an ADDRESS_ASSIGN, ADDRESS_REQUEST or ROUTE_ADVERTISEMENT capsule.
+const maxIPCapsuleLen = 1 << 16
+
+// An AssignedAddress is an address (or prefix) assigned by an ADDRESS_ASSIGN capsule.
+type AssignedAddress struct {
+	// RequestID is the ID of the ADDRESS_REQUEST this assignment responds to,
+	// or 0 if the address was assigned unsolicited.
+	RequestID uint64
+	Prefix    netip.Prefix
+}
+
+// A RequestedAddress is an address (or prefix) requested by an ADDRESS_REQUEST capsule.
+// An unspecified address (0.0.0.0 or ::) requests any address of the respective IP version.
+type RequestedAddress struct {
+	// RequestID must not be 0.
+	RequestID uint64
+	Prefix    netip.Prefix
+}
+
+// An IPRoute is an IP address range advertised by a ROUTE_ADVERTISEMENT capsule.
+// Packets to addresses in the range can be sent through the tunnel.
+type IPRoute struct {
+	StartIP netip.Addr
+	EndIP   netip.Addr
+	// IPProtocol restricts the route to one IP protocol. 0 means all protocols.
+	IPProtocol uint8
+}
+
+func (r IPRoute) contains(addr netip.Addr, proto uint8) bool {
+	if r.IPProtocol != 0 && r.IPProtocol != proto {
+		return false
+	}
+	return r.StartIP.Compare(addr) <= 0 && addr.Compare(r.EndIP) <= 0
+}
+
+// validateRoutes checks that the routes are valid and ordered, see section 4.7.3 of RFC 9484.
+func validateRoutes(routes []IPRoute) error {
+	for i, r := range routes {
+		if !r.StartIP.IsValid() || !r.EndIP.IsValid() || r.StartIP.BitLen() != r.EndIP.BitLen() {
+			return fmt.Errorf("masque: invalid route: %s-%s", r.StartIP, r.EndIP)
+		}
+		if r.StartIP.Compare(r.EndIP) > 0 {
+			return fmt.Errorf("masque: route start after end: %s-%s", r.StartIP, r.EndIP)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := routes[i-1]
+		if c := prev.StartIP.Compare(r.StartIP); c > 0 || (c == 0 && prev.IPProtocol > r.IPProtocol) {
+			return errors.New("masque: routes not ordered")
+		}
+		// ranges for the same IP protocol must not overlap
+		for _, p := range routes[:i] {
+			if p.IPProtocol == r.IPProtocol && p.StartIP.BitLen() == r.StartIP.BitLen() && p.EndIP.Compare(r.StartIP) >= 0 {
+				return fmt.Errorf("masque: overlapping routes: %s-%s and %s-%s", p.StartIP, p.EndIP, r.StartIP, r.EndIP)
+			}
+		}
+	}
+	return nil
+}
+
+func appendPrefix(b []byte, requestID uint64, prefix netip.Prefix) []byte {
+	b = quicvarint.Append(b, requestID)
+	b = append(b, ipVersion(prefix.Addr()))
+	b = append(b, prefix.Addr().AsSlice()...)
+	return append(b, uint8(prefix.Bits()))
+}
+
+func appendAddressAssign(b []byte, addrs []AssignedAddress) []byte {
+	for _, a := range addrs {
+		b = appendPrefix(b, a.RequestID, a.Prefix)
+	}
+	return b
+}
+
+func appendAddressRequest(b []byte, addrs []RequestedAddress) []byte {
+	for _, a := range addrs {
+		b = appendPrefix(b, a.RequestID, a.Prefix)
+	}
+	return b
+}
+
+func appendRouteAdvertisement(b []byte, routes []IPRoute) []byte {
+	for _, r := range routes {
+		b = append(b, ipVersion(r.StartIP))
+		b = append(b, r.StartIP.AsSlice()...)
+		b = append(b, r.EndIP.AsSlice()...)
+		b = append(b, r.IPProtocol)
+	}
+	return b
+}
+
+func ipVersion(addr netip.Addr) uint8 {
+	if addr.Is4() {
+		return 4
+	}
+	return 6
+}
+
+// readAddr reads an IP address of the given version.
+func readAddr(r *bytes.Reader, version uint8) (netip.Addr, error) {
+	var b []byte
+	switch version {
+	case 4:
+		b = make([]byte, 4)
+	case 6:
+		b = make([]byte, 16)
+	default:
+		return netip.Addr{}, fmt.Errorf("masque: invalid IP version: %d", version)
+	}
+	if _, err := io.ReadFull(r, b); err != nil {
+		return netip.Addr{}, io.ErrUnexpectedEOF
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr, nil
+}
+
+// parsePrefixes parses the payload of an ADDRESS_ASSIGN or ADDRESS_REQUEST capsule.
+func parsePrefixes(r *bytes.Reader) ([]uint64, []netip.Prefix, error) {
+	var ids []uint64
+	var prefixes []netip.Prefix
+	for r.Len() > 0 {
+		id, err := quicvarint.Read(r)
+		if err != nil {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		version, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		addr, err := readAddr(r, version)
+		if err != nil {
+			return nil, nil, err
+		}
+		bits, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		if int(bits) > addr.BitLen() {
+			return nil, nil, fmt.Errorf("masque: invalid prefix length %d for %s", bits, addr)
+		}
+		prefix := netip.PrefixFrom(addr, int(bits))
+		// the lower bits of the address must be zero, see section 4.7.1 of RFC 9484
+		if prefix.Masked() != prefix {
+			return nil, nil, fmt.Errorf("masque: prefix %s has host bits set", prefix)
+		}
+		ids = append(ids, id)
+		prefixes = append(prefixes, prefix)
+	}
+	return ids, prefixes, nil
+}
+
+func parseAddressAssign(r *bytes.Reader) ([]AssignedAddress, error) {
+	ids, prefixes, err := parsePrefixes(r)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]AssignedAddress, 0, len(prefixes))
+	for i, p := range prefixes {
+		addrs = append(addrs, AssignedAddress{RequestID: ids[i], Prefix: p})
+	}
+	return addrs, nil
+}
+
+func parseAddressRequest(r *bytes.Reader) ([]RequestedAddress, error) {
+	ids, prefixes, err := parsePrefixes(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefixes) == 0 {
+		return nil, errors.New("masque: empty ADDRESS_REQUEST capsule")
+	}
+	addrs := make([]RequestedAddress, 0, len(prefixes))
+	for i, p := range prefixes {
+		if ids[i] == 0 {
+			return nil, errors.New("masque: ADDRESS_REQUEST with request ID 0")
+		}
+		addrs = append(addrs, RequestedAddress{RequestID: ids[i], Prefix: p})
+	}
+	return addrs, nil
+}
+
+func parseRouteAdvertisement(r *bytes.Reader) ([]IPRoute, error) {
+	var routes []IPRoute
+	for r.Len() > 0 {
+		version, _ := r.ReadByte()
+		start, err := readAddr(r, version)
+		if err != nil {
+			return nil, err
+		}
+		end, err := readAddr(r, version)
+		if err != nil {
+			return nil, err
+		}
+		proto, err := r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		routes = append(routes, IPRoute{StartIP: start, EndIP: end, IPProtocol: proto})
+	}
+	if err := validateRoutes(routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// parseIPHeader parses the addresses and the protocol of an IPv4 or IPv6 packet.
+// For IPv6, the protocol is the Next Header field of the fixed header.
+func parseIPHeader(b []byte) (src, dst netip.Addr, proto uint8, err error) {
+	if len(b) == 0 {
+		return netip.Addr{}, netip.Addr{}, 0, errors.New("masque: empty IP packet")
+	}
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < 20 || int(b[0]&0xf)*4 < 20 || int(b[0]&0xf)*4 > len(b) {
+			return netip.Addr{}, netip.Addr{}, 0, errors.New("masque: invalid IPv4 header")
+		}
+		src, _ = netip.AddrFromSlice(b[12:16])
+		dst, _ = netip.AddrFromSlice(b[16:20])
+		return src, dst, b[9], nil
+	case 6:
+		if len(b) < 40 {
+			return netip.Addr{}, netip.Addr{}, 0, errors.New("masque: invalid IPv6 header")
+		}
+		src, _ = netip.AddrFromSlice(b[8:24])
+		dst, _ = netip.AddrFromSlice(b[24:40])
+		return src, dst, b[6], nil
+	default:
+		return netip.Addr{}, netip.Addr{}, 0, fmt.Errorf("masque: invalid IP version: %d", b[0]>>4)
+	}
+}
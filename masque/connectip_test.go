@@ -0,0 +1,174 @@
+package masque
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/nxenon/xquic-go/quicvarint"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressAssignCapsule(t *testing.T) {
+	addrs := []AssignedAddress{
+		{RequestID: 0, Prefix: netip.MustParsePrefix("192.0.2.1/32")},
+		{RequestID: 1337, Prefix: netip.MustParsePrefix("2001:db8::/64")},
+	}
+	parsed, err := parseAddressAssign(bytes.NewReader(appendAddressAssign(nil, addrs)))
+	require.NoError(t, err)
+	require.Equal(t, addrs, parsed)
+
+	parsed, err = parseAddressAssign(bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.Empty(t, parsed)
+}
+
+func TestAddressRequestCapsule(t *testing.T) {
+	addrs := []RequestedAddress{
+		{RequestID: 1, Prefix: netip.MustParsePrefix("0.0.0.0/32")},
+		{RequestID: 2, Prefix: netip.MustParsePrefix("2001:db8::/48")},
+	}
+	parsed, err := parseAddressRequest(bytes.NewReader(appendAddressRequest(nil, addrs)))
+	require.NoError(t, err)
+	require.Equal(t, addrs, parsed)
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := parseAddressRequest(bytes.NewReader(nil))
+		require.EqualError(t, err, "masque: empty ADDRESS_REQUEST capsule")
+	})
+
+	t.Run("request ID 0", func(t *testing.T) {
+		b := appendAddressRequest(nil, []RequestedAddress{{RequestID: 0, Prefix: netip.MustParsePrefix("192.0.2.0/24")}})
+		_, err := parseAddressRequest(bytes.NewReader(b))
+		require.EqualError(t, err, "masque: ADDRESS_REQUEST with request ID 0")
+	})
+}
+
+func TestAddressCapsuleParsingErrors(t *testing.T) {
+	valid := appendAddressAssign(nil, []AssignedAddress{{RequestID: 42, Prefix: netip.MustParsePrefix("192.0.2.0/24")}})
+
+	t.Run("truncated", func(t *testing.T) {
+		for i := 1; i < len(valid); i++ {
+			_, err := parseAddressAssign(bytes.NewReader(valid[:i]))
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("invalid IP version", func(t *testing.T) {
+		b := quicvarint.Append(nil, 42)
+		b = append(b, 5, 192, 0, 2, 0, 24)
+		_, err := parseAddressAssign(bytes.NewReader(b))
+		require.EqualError(t, err, "masque: invalid IP version: 5")
+	})
+
+	t.Run("invalid prefix length", func(t *testing.T) {
+		b := quicvarint.Append(nil, 42)
+		b = append(b, 4, 192, 0, 2, 0, 33)
+		_, err := parseAddressAssign(bytes.NewReader(b))
+		require.ErrorContains(t, err, "invalid prefix length 33")
+	})
+
+	t.Run("host bits set", func(t *testing.T) {
+		b := quicvarint.Append(nil, 42)
+		b = append(b, 4, 192, 0, 2, 1, 24)
+		_, err := parseAddressAssign(bytes.NewReader(b))
+		require.EqualError(t, err, "masque: prefix 192.0.2.1/24 has host bits set")
+	})
+}
+
+func TestRouteAdvertisementCapsule(t *testing.T) {
+	routes := []IPRoute{
+		{StartIP: netip.MustParseAddr("192.0.2.0"), EndIP: netip.MustParseAddr("192.0.2.255"), IPProtocol: 0},
+		{StartIP: netip.MustParseAddr("192.0.2.0"), EndIP: netip.MustParseAddr("192.0.2.41"), IPProtocol: 17},
+		{StartIP: netip.MustParseAddr("198.51.100.0"), EndIP: netip.MustParseAddr("198.51.100.255"), IPProtocol: 0},
+		{StartIP: netip.MustParseAddr("2001:db8::"), EndIP: netip.MustParseAddr("2001:db8::ffff"), IPProtocol: 0},
+	}
+	parsed, err := parseRouteAdvertisement(bytes.NewReader(appendRouteAdvertisement(nil, routes)))
+	require.NoError(t, err)
+	require.Equal(t, routes, parsed)
+
+	t.Run("truncated", func(t *testing.T) {
+		b := appendRouteAdvertisement(nil, routes[:1])
+		for i := 1; i < len(b); i++ {
+			_, err := parseRouteAdvertisement(bytes.NewReader(b[:i]))
+			require.Error(t, err)
+		}
+	})
+}
+
+func TestRouteValidation(t *testing.T) {
+	route := func(start, end string, proto uint8) IPRoute {
+		return IPRoute{StartIP: netip.MustParseAddr(start), EndIP: netip.MustParseAddr(end), IPProtocol: proto}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		routes []IPRoute
+		err    string
+	}{
+		{name: "start after end", routes: []IPRoute{route("192.0.2.10", "192.0.2.1", 0)}, err: "route start after end"},
+		{name: "mixed IP versions", routes: []IPRoute{route("192.0.2.1", "2001:db8::1", 0)}, err: "invalid route"},
+		{name: "IPv6 before IPv4", routes: []IPRoute{route("2001:db8::", "2001:db8::1", 0), route("192.0.2.0", "192.0.2.1", 0)}, err: "not ordered"},
+		{name: "not ordered by start", routes: []IPRoute{route("192.0.2.10", "192.0.2.20", 0), route("192.0.2.0", "192.0.2.5", 0)}, err: "not ordered"},
+		{name: "not ordered by protocol", routes: []IPRoute{route("192.0.2.0", "192.0.2.5", 17), route("192.0.2.0", "192.0.2.5", 6)}, err: "not ordered"},
+		{name: "overlapping", routes: []IPRoute{route("192.0.2.0", "192.0.2.10", 0), route("192.0.2.10", "192.0.2.20", 0)}, err: "overlapping routes"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorContains(t, validateRoutes(tc.routes), tc.err)
+		})
+	}
+
+	require.NoError(t, validateRoutes(nil))
+	// ranges for different IP protocols may overlap
+	require.NoError(t, validateRoutes([]IPRoute{route("192.0.2.0", "192.0.2.10", 6), route("192.0.2.5", "192.0.2.20", 17)}))
+}
+
+// ipv4Packet creates an IPv4 packet. The header checksum is not set.
+func ipv4Packet(src, dst string, proto uint8, payload []byte) []byte {
+	b := make([]byte, 20, 20+len(payload))
+	b[0] = 0x45
+	b[2] = uint8((20 + len(payload)) >> 8)
+	b[3] = uint8(20 + len(payload))
+	b[8] = 64
+	b[9] = proto
+	copy(b[12:16], netip.MustParseAddr(src).AsSlice())
+	copy(b[16:20], netip.MustParseAddr(dst).AsSlice())
+	return append(b, payload...)
+}
+
+// ipv6Packet creates an IPv6 packet.
+func ipv6Packet(src, dst string, proto uint8, payload []byte) []byte {
+	b := make([]byte, 40, 40+len(payload))
+	b[0] = 0x60
+	b[4] = uint8(len(payload) >> 8)
+	b[5] = uint8(len(payload))
+	b[6] = proto
+	b[7] = 64
+	copy(b[8:24], netip.MustParseAddr(src).AsSlice())
+	copy(b[24:40], netip.MustParseAddr(dst).AsSlice())
+	return append(b, payload...)
+}
+
+func TestIPHeaderParsing(t *testing.T) {
+	src, dst, proto, err := parseIPHeader(ipv4Packet("192.0.2.1", "198.51.100.2", 17, []byte("foobar")))
+	require.NoError(t, err)
+	require.Equal(t, netip.MustParseAddr("192.0.2.1"), src)
+	require.Equal(t, netip.MustParseAddr("198.51.100.2"), dst)
+	require.Equal(t, uint8(17), proto)
+
+	src, dst, proto, err = parseIPHeader(ipv6Packet("2001:db8::1", "2001:db8::2", 6, []byte("foobar")))
+	require.NoError(t, err)
+	require.Equal(t, netip.MustParseAddr("2001:db8::1"), src)
+	require.Equal(t, netip.MustParseAddr("2001:db8::2"), dst)
+	require.Equal(t, uint8(6), proto)
+
+	_, _, _, err = parseIPHeader(nil)
+	require.Error(t, err)
+	_, _, _, err = parseIPHeader(ipv4Packet("192.0.2.1", "198.51.100.2", 17, nil)[:19])
+	require.Error(t, err)
+	_, _, _, err = parseIPHeader(ipv6Packet("2001:db8::1", "2001:db8::2", 6, nil)[:39])
+	require.Error(t, err)
+	_, _, _, err = parseIPHeader([]byte{0x50, 0, 0, 0})
+	require.EqualError(t, err, "masque: invalid IP version: 5")
+}
@@ -0,0 +1,214 @@
+package masque
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"sync"
+
+	"github.com/nxenon/xquic-go/http3"
+)
+
+// A PacketInterface reads and writes IP packets, for example a TUN device.
+type PacketInterface interface {
+	ReadPacket(b []byte) (int, error)
+	WritePacket(b []byte) error
+	Close() error
+}
+
+// An IPSession configures the tunnel established for a CONNECT-IP request.
+type IPSession struct {
+	// Addresses are assigned to the client.
+	Addresses []netip.Prefix
+	// Routes are advertised to the client.
+	// They must be ordered as described in section 4.7.3 of RFC 9484.
+	Routes []IPRoute
+	// Interface is the interface that IP packets are forwarded to and from.
+	// It is closed when the tunnel is closed.
+	Interface PacketInterface
+}
+
+// An IPProxy is an http.Handler that proxies IP packets for CONNECT-IP requests.
+// It must be served by an http3.Server that has EnableExtendedConnect set.
+// If EnableDatagrams is set as well, IP packets are sent in HTTP datagrams,
+// otherwise they are sent in DATAGRAM capsules on the request stream.
+//
+// The proxy assigns the addresses of the session to the client and advertises its routes.
+// ADDRESS_REQUEST capsules are answered by assigning the session's addresses of the same IP version.
+// It doesn't decrement the TTL or Hop Limit of forwarded packets; that's up to the interface.
+type IPProxy struct {
+	// Template is the URI template of the proxy.
+	// Requests that don't match the template are rejected.
+	Template *Template
+
+	// Setup is called for every CONNECT-IP request. It returns the configuration of the tunnel.
+	// If it returns an error, the request is rejected with a 403 status code.
+	Setup func(r *http.Request, req *IPRequest) (*IPSession, error)
+
+	mutex  sync.Mutex
+	closed bool
+	conns  map[*IPConn]struct{}
+}
+
+var _ http.Handler = &IPProxy{}
+
+func (p *IPProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mutex.Lock()
+	closed := p.closed
+	p.mutex.Unlock()
+	if closed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	req, err := ParseIPRequest(r, p.Template)
+	if err != nil {
+		var perr *RequestParseError
+		if errors.As(err, &perr) {
+			w.WriteHeader(perr.HTTPStatus)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if p.Setup == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	session, err := p.Setup(r, req)
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if err := validateRoutes(session.Routes); err != nil {
+		session.Interface.Close()
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(capsuleProtocolHeader, capsuleProtocolHeaderValue)
+	str, err := http3.AcceptExtendedConnect(w, r)
+	if err != nil {
+		session.Interface.Close()
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	p.proxy(newIPConn(str), session)
+}
+
+// proxy forwards IP packets between the tunnel and the interface, until either of them is closed.
+func (p *IPProxy) proxy(conn *IPConn, session *IPSession) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		conn.Close()
+		session.Interface.Close()
+		return
+	}
+	if p.conns == nil {
+		p.conns = make(map[*IPConn]struct{})
+	}
+	p.conns[conn] = struct{}{}
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		delete(p.conns, conn)
+		p.mutex.Unlock()
+	}()
+
+	if err := conn.AssignAddresses(assignAddresses(session.Addresses, nil)); err != nil {
+		conn.Close()
+		session.Interface.Close()
+		return
+	}
+	if err := conn.AdvertiseRoutes(session.Routes); err != nil {
+		conn.Close()
+		session.Interface.Close()
+		return
+	}
+	go func() {
+		for {
+			reqs, err := conn.AddressRequests(context.Background())
+			if err != nil {
+				return
+			}
+			if err := conn.AssignAddresses(assignAddresses(session.Addresses, reqs)); err != nil {
+				return
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, maxPayloadSize)
+		for {
+			n, err := session.Interface.ReadPacket(buf)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			// packets for other clients are dropped
+			if err := conn.WritePacket(buf[:n]); err != nil && !errors.Is(err, errPacketNotAllowed) {
+				return
+			}
+		}
+	}()
+	buf := make([]byte, maxPayloadSize)
+	for {
+		n, err := conn.ReadPacket(buf)
+		if err != nil {
+			break
+		}
+		// IP is unreliable, errors for individual packets are ignored
+		session.Interface.WritePacket(buf[:n])
+	}
+	session.Interface.Close()
+	<-done
+}
+
+// assignAddresses assigns the prefixes, answering the address requests.
+// Each request is answered by a prefix of the same IP version, if available.
+// Requests that can't be answered are rejected by assigning the unspecified address.
+func assignAddresses(prefixes []netip.Prefix, reqs []RequestedAddress) []AssignedAddress {
+	addrs := make([]AssignedAddress, 0, len(prefixes)+len(reqs))
+	answered := make([]bool, len(reqs))
+	for _, prefix := range prefixes {
+		a := AssignedAddress{Prefix: prefix}
+		for i, req := range reqs {
+			if !answered[i] && req.Prefix.Addr().BitLen() == prefix.Addr().BitLen() {
+				answered[i] = true
+				a.RequestID = req.RequestID
+				break
+			}
+		}
+		addrs = append(addrs, a)
+	}
+	for i, req := range reqs {
+		if answered[i] {
+			continue
+		}
+		unspecified := netip.IPv4Unspecified()
+		if req.Prefix.Addr().Is6() {
+			unspecified = netip.IPv6Unspecified()
+		}
+		addrs = append(addrs, AssignedAddress{
+			RequestID: req.RequestID,
+			Prefix:    netip.PrefixFrom(unspecified, unspecified.BitLen()),
+		})
+	}
+	return addrs
+}
+
+// Close closes the proxy.
+// All tunnels are closed, and new requests are rejected.
+func (p *IPProxy) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closed = true
+	for conn := range p.conns {
+		conn.Close()
+	}
+	return nil
+}
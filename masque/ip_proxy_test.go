@@ -0,0 +1,234 @@
+package masque
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A memoryInterface is an in-memory PacketInterface.
+// Packets written to one end of a pair are read from the other end.
+type memoryInterface struct {
+	in, out chan []byte
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newMemoryInterfacePair() (*memoryInterface, *memoryInterface) {
+	a := make(chan []byte, 16)
+	b := make(chan []byte, 16)
+	return &memoryInterface{in: a, out: b, closed: make(chan struct{})},
+		&memoryInterface{in: b, out: a, closed: make(chan struct{})}
+}
+
+func (i *memoryInterface) ReadPacket(b []byte) (int, error) {
+	select {
+	case p := <-i.in:
+		return copy(b, p), nil
+	case <-i.closed:
+		return 0, net.ErrClosed
+	}
+}
+
+func (i *memoryInterface) WritePacket(b []byte) error {
+	p := make([]byte, len(b))
+	copy(p, b)
+	select {
+	case i.out <- p:
+		return nil
+	case <-i.closed:
+		return net.ErrClosed
+	}
+}
+
+func (i *memoryInterface) Close() error {
+	i.closeOnce.Do(func() { close(i.closed) })
+	return nil
+}
+
+func readPacket(t *testing.T, iface PacketInterface) []byte {
+	t.Helper()
+	type result struct {
+		p   []byte
+		err error
+	}
+	c := make(chan result, 1)
+	go func() {
+		b := make([]byte, 1500)
+		n, err := iface.ReadPacket(b)
+		c <- result{b[:n], err}
+	}()
+	select {
+	case r := <-c:
+		require.NoError(t, r.err)
+		return r.p
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+		return nil
+	}
+}
+
+var testRoutes = []IPRoute{
+	{StartIP: netip.MustParseAddr("198.51.100.0"), EndIP: netip.MustParseAddr("198.51.100.255")},
+	{StartIP: netip.MustParseAddr("2001:db8:1::"), EndIP: netip.MustParseAddr("2001:db8:1::ffff"), IPProtocol: 17},
+}
+
+// runIPProxy runs an HTTP/3 server serving a CONNECT-IP proxy.
+// It returns the proxy's URI template, and the other end of the interface of the session.
+func runIPProxy(t *testing.T, setup func(*http.Request, *IPRequest) error) (*Template, *memoryInterface) {
+	t.Helper()
+	local, remote := newMemoryInterfacePair()
+	conn := listen(t)
+	tmpl, err := ParseIPTemplate(fmt.Sprintf("https://localhost:%d/masque/ip/{target}/{ipproto}/", conn.LocalAddr().(*net.UDPAddr).Port))
+	require.NoError(t, err)
+	proxy := &IPProxy{
+		Template: tmpl,
+		Setup: func(r *http.Request, req *IPRequest) (*IPSession, error) {
+			if setup != nil {
+				if err := setup(r, req); err != nil {
+					return nil, err
+				}
+			}
+			return &IPSession{
+				Addresses: []netip.Prefix{netip.MustParsePrefix("192.0.2.1/32"), netip.MustParsePrefix("2001:db8::/64")},
+				Routes:    testRoutes,
+				Interface: local,
+			}, nil
+		},
+	}
+	t.Cleanup(func() { proxy.Close() })
+	serve(t, conn, proxy)
+	return tmpl, remote
+}
+
+func TestIPProxying(t *testing.T) {
+	for _, enableDatagrams := range []bool{true, false} {
+		name := "using datagrams"
+		if !enableDatagrams {
+			name = "using capsules"
+		}
+		t.Run(name, func(t *testing.T) {
+			tmpl, iface := runIPProxy(t, nil)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, rsp, err := newClient(t, enableDatagrams).DialIP(ctx, tmpl, "", 0)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, rsp.StatusCode)
+			defer conn.Close()
+
+			addrs, err := conn.AssignedAddresses(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []AssignedAddress{
+				{Prefix: netip.MustParsePrefix("192.0.2.1/32")},
+				{Prefix: netip.MustParsePrefix("2001:db8::/64")},
+			}, addrs)
+			routes, err := conn.Routes(ctx)
+			require.NoError(t, err)
+			require.Equal(t, testRoutes, routes)
+
+			// IPv4
+			packet := ipv4Packet("192.0.2.1", "198.51.100.7", 6, []byte("foobar"))
+			require.NoError(t, conn.WritePacket(packet))
+			require.Equal(t, packet, readPacket(t, iface))
+			reply := ipv4Packet("198.51.100.7", "192.0.2.1", 6, []byte("raboof"))
+			require.NoError(t, iface.WritePacket(reply))
+			require.Equal(t, reply, readPacket(t, conn))
+
+			// IPv6
+			packet = ipv6Packet("2001:db8::42", "2001:db8:1::1", 17, []byte("foobar"))
+			require.NoError(t, conn.WritePacket(packet))
+			require.Equal(t, packet, readPacket(t, iface))
+			reply = ipv6Packet("2001:db8:1::1", "2001:db8::42", 17, []byte("raboof"))
+			require.NoError(t, iface.WritePacket(reply))
+			require.Equal(t, reply, readPacket(t, conn))
+		})
+	}
+}
+
+func TestIPProxyFiltering(t *testing.T) {
+	tmpl, iface := runIPProxy(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := newClient(t, true).DialIP(ctx, tmpl, "", 0)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Routes(ctx)
+	require.NoError(t, err)
+
+	// source address not assigned
+	require.ErrorIs(t, conn.WritePacket(ipv4Packet("192.0.2.2", "198.51.100.7", 6, nil)), errPacketNotAllowed)
+	// destination not routed
+	require.ErrorIs(t, conn.WritePacket(ipv4Packet("192.0.2.1", "203.0.113.1", 6, nil)), errPacketNotAllowed)
+	// IP protocol not routed
+	require.ErrorIs(t, conn.WritePacket(ipv6Packet("2001:db8::1", "2001:db8:1::1", 6, nil)), errPacketNotAllowed)
+
+	// packets for other clients are dropped by the proxy
+	require.NoError(t, iface.WritePacket(ipv4Packet("198.51.100.7", "192.0.2.2", 6, []byte("foo"))))
+	reply := ipv4Packet("198.51.100.7", "192.0.2.1", 6, []byte("bar"))
+	require.NoError(t, iface.WritePacket(reply))
+	require.Equal(t, reply, readPacket(t, conn))
+}
+
+func TestIPProxyAddressRequests(t *testing.T) {
+	tmpl, _ := runIPProxy(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := newClient(t, true).DialIP(ctx, tmpl, "", 0)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.AssignedAddresses(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.RequestAddresses([]RequestedAddress{
+		{RequestID: 1, Prefix: netip.MustParsePrefix("::/128")},
+		{RequestID: 2, Prefix: netip.MustParsePrefix("0.0.0.0/32")},
+		{RequestID: 3, Prefix: netip.MustParsePrefix("10.0.0.0/8")},
+	}))
+	expected := []AssignedAddress{
+		{RequestID: 2, Prefix: netip.MustParsePrefix("192.0.2.1/32")},
+		{RequestID: 1, Prefix: netip.MustParsePrefix("2001:db8::/64")},
+		{RequestID: 3, Prefix: netip.MustParsePrefix("0.0.0.0/32")},
+	}
+	require.Eventually(t, func() bool {
+		addrs, err := conn.AssignedAddresses(ctx)
+		require.NoError(t, err)
+		return len(addrs) == len(expected)
+	}, 5*time.Second, 10*time.Millisecond)
+	addrs, err := conn.AssignedAddresses(ctx)
+	require.NoError(t, err)
+	require.Equal(t, expected, addrs)
+
+	require.EqualError(t, conn.RequestAddresses([]RequestedAddress{{Prefix: netip.MustParsePrefix("0.0.0.0/32")}}), "masque: request ID must not be 0")
+}
+
+func TestIPProxyScoping(t *testing.T) {
+	requests := make(chan *IPRequest, 1)
+	tmpl, _ := runIPProxy(t, func(_ *http.Request, req *IPRequest) error {
+		requests <- req
+		if req.IPProtocol == 6 {
+			return errors.New("TCP not allowed")
+		}
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := newClient(t, true)
+	conn, _, err := client.DialIP(ctx, tmpl, "198.51.100.0/24", 17)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, &IPRequest{Target: "198.51.100.0/24", IPProtocol: 17}, <-requests)
+
+	_, rsp, err := client.DialIP(ctx, tmpl, "198.51.100.0/24", 6)
+	require.Error(t, err)
+	require.Equal(t, http.StatusForbidden, rsp.StatusCode)
+	require.Equal(t, &IPRequest{Target: "198.51.100.0/24", IPProtocol: 6}, <-requests)
+}
@@ -0,0 +1,272 @@
+package masque
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/nxenon/xquic-go/http3"
+)
+
+// addressRequestQueueLen is the number of received ADDRESS_REQUEST capsules that are queued.
+const addressRequestQueueLen = 8
+
+var errPacketNotAllowed = errors.New("masque: packet not allowed by the assigned addresses and advertised routes")
+
+// An IPConn is a CONNECT-IP tunnel, see RFC 9484.
+// It is used by both the client and the proxy to send and receive IP packets,
+// and to exchange address assignments and route advertisements.
+//
+// Packets are only sent and received if they are allowed by the addresses and routes:
+// Either the source address was assigned to the sender and the destination was advertised by the receiver,
+// or the source was advertised by the sender and the destination address was assigned to the receiver.
+type IPConn struct {
+	tunnel *tunnel
+
+	mutex          sync.Mutex
+	localAddresses []AssignedAddress // the last ADDRESS_ASSIGN capsule received
+	localPrefixes  []netip.Prefix    // assigned to us by the peer
+	peerPrefixes   []netip.Prefix    // assigned to the peer by us
+	localRoutes    []IPRoute         // advertised by us
+	peerRoutes     []IPRoute         // advertised by the peer
+	assigned       chan struct{}     // closed when the first ADDRESS_ASSIGN capsule is received
+	advertised     chan struct{}     // closed when the first ROUTE_ADVERTISEMENT capsule is received
+
+	addressRequests chan []RequestedAddress
+}
+
+var _ PacketInterface = &IPConn{}
+
+func newIPConn(str *http3.ExtendedConnectStream) *IPConn {
+	c := &IPConn{
+		assigned:        make(chan struct{}),
+		advertised:      make(chan struct{}),
+		addressRequests: make(chan []RequestedAddress, addressRequestQueueLen),
+	}
+	c.tunnel = newTunnel(str, c.handleCapsule)
+	return c
+}
+
+func (c *IPConn) handleCapsule(ct http3.CapsuleType, r io.Reader) error {
+	switch ct {
+	case capsuleTypeAddressAssign, capsuleTypeAddressRequest, capsuleTypeRouteAdvertisement:
+	default:
+		return nil
+	}
+	payload, err := io.ReadAll(io.LimitReader(r, maxIPCapsuleLen+1))
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxIPCapsuleLen {
+		return fmt.Errorf("masque: capsule of type %#x too large", uint64(ct))
+	}
+	br := bytes.NewReader(payload)
+	switch ct {
+	case capsuleTypeAddressAssign:
+		addrs, err := parseAddressAssign(br)
+		if err != nil {
+			return err
+		}
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.localAddresses = addrs
+		c.localPrefixes = assignedPrefixes(addrs)
+		select {
+		case <-c.assigned:
+		default:
+			close(c.assigned)
+		}
+	case capsuleTypeAddressRequest:
+		addrs, err := parseAddressRequest(br)
+		if err != nil {
+			return err
+		}
+		select {
+		case c.addressRequests <- addrs:
+		default:
+			return errors.New("masque: too many outstanding address requests")
+		}
+	case capsuleTypeRouteAdvertisement:
+		routes, err := parseRouteAdvertisement(br)
+		if err != nil {
+			return err
+		}
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.peerRoutes = routes
+		select {
+		case <-c.advertised:
+		default:
+			close(c.advertised)
+		}
+	}
+	return nil
+}
+
+// assignedPrefixes returns the prefixes of an address assignment.
+// Unspecified addresses are used to reject address requests, and are not assigned.
+func assignedPrefixes(addrs []AssignedAddress) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(addrs))
+	for _, a := range addrs {
+		if !a.Prefix.Addr().IsUnspecified() {
+			prefixes = append(prefixes, a.Prefix)
+		}
+	}
+	return prefixes
+}
+
+// ReadPacket reads the next IP packet received from the peer.
+// Packets that are not allowed by the addresses and routes are dropped.
+func (c *IPConn) ReadPacket(b []byte) (int, error) {
+	for {
+		p, err := c.tunnel.receive()
+		if err != nil {
+			return 0, err
+		}
+		src, dst, proto, err := parseIPHeader(p)
+		if err != nil {
+			continue
+		}
+		c.mutex.Lock()
+		allowed := (containsAddr(c.peerPrefixes, src) && routesContain(c.localRoutes, dst, proto)) ||
+			(routesContain(c.peerRoutes, src, proto) && containsAddr(c.localPrefixes, dst))
+		c.mutex.Unlock()
+		if !allowed {
+			continue
+		}
+		return copy(b, p), nil
+	}
+}
+
+// WritePacket sends an IP packet to the peer.
+// It returns an error if the packet is not allowed by the addresses and routes.
+func (c *IPConn) WritePacket(b []byte) error {
+	src, dst, proto, err := parseIPHeader(b)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	allowed := (containsAddr(c.localPrefixes, src) && routesContain(c.peerRoutes, dst, proto)) ||
+		(routesContain(c.localRoutes, src, proto) && containsAddr(c.peerPrefixes, dst))
+	c.mutex.Unlock()
+	if !allowed {
+		return errPacketNotAllowed
+	}
+	return c.tunnel.send(b)
+}
+
+// AssignAddresses sends an ADDRESS_ASSIGN capsule.
+// It replaces all addresses previously assigned to the peer.
+func (c *IPConn) AssignAddresses(addrs []AssignedAddress) error {
+	for _, a := range addrs {
+		if !a.Prefix.IsValid() || a.Prefix.Masked() != a.Prefix {
+			return fmt.Errorf("masque: invalid prefix: %s", a.Prefix)
+		}
+	}
+	c.mutex.Lock()
+	c.peerPrefixes = assignedPrefixes(addrs)
+	c.mutex.Unlock()
+	return c.tunnel.writeCapsule(capsuleTypeAddressAssign, appendAddressAssign(nil, addrs))
+}
+
+// RequestAddresses sends an ADDRESS_REQUEST capsule.
+// The peer responds with an ADDRESS_ASSIGN capsule.
+func (c *IPConn) RequestAddresses(addrs []RequestedAddress) error {
+	if len(addrs) == 0 {
+		return errors.New("masque: no addresses requested")
+	}
+	for _, a := range addrs {
+		if a.RequestID == 0 {
+			return errors.New("masque: request ID must not be 0")
+		}
+		if !a.Prefix.IsValid() || a.Prefix.Masked() != a.Prefix {
+			return fmt.Errorf("masque: invalid prefix: %s", a.Prefix)
+		}
+	}
+	return c.tunnel.writeCapsule(capsuleTypeAddressRequest, appendAddressRequest(nil, addrs))
+}
+
+// AdvertiseRoutes sends a ROUTE_ADVERTISEMENT capsule.
+// It replaces all routes previously advertised.
+// The routes must be ordered as described in section 4.7.3 of RFC 9484.
+func (c *IPConn) AdvertiseRoutes(routes []IPRoute) error {
+	if err := validateRoutes(routes); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.localRoutes = routes
+	c.mutex.Unlock()
+	return c.tunnel.writeCapsule(capsuleTypeRouteAdvertisement, appendRouteAdvertisement(nil, routes))
+}
+
+// AssignedAddresses returns the addresses assigned by the most recent ADDRESS_ASSIGN capsule received from the peer.
+// Address requests rejected by the peer are contained with an unspecified address.
+// It blocks until the first ADDRESS_ASSIGN capsule is received.
+func (c *IPConn) AssignedAddresses(ctx context.Context) ([]AssignedAddress, error) {
+	select {
+	case <-c.assigned:
+	case <-c.tunnel.closed:
+		return nil, c.tunnel.closeErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.localAddresses, nil
+}
+
+// Routes returns the routes advertised by the peer.
+// It blocks until the first ROUTE_ADVERTISEMENT capsule is received.
+func (c *IPConn) Routes(ctx context.Context) ([]IPRoute, error) {
+	select {
+	case <-c.advertised:
+	case <-c.tunnel.closed:
+		return nil, c.tunnel.closeErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.peerRoutes, nil
+}
+
+// AddressRequests returns the addresses requested by the next ADDRESS_REQUEST capsule.
+func (c *IPConn) AddressRequests(ctx context.Context) ([]RequestedAddress, error) {
+	select {
+	case addrs := <-c.addressRequests:
+		return addrs, nil
+	case <-c.tunnel.closed:
+		return nil, c.tunnel.closeErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close closes the tunnel.
+func (c *IPConn) Close() error {
+	c.tunnel.close(net.ErrClosed)
+	return nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func routesContain(routes []IPRoute, addr netip.Addr, proto uint8) bool {
+	for _, r := range routes {
+		if r.contains(addr, proto) {
+			return true
+		}
+	}
+	return false
+}
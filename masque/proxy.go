@@ -0,0 +1,175 @@
+// Package masque implements proxying of UDP (CONNECT-UDP, RFC 9298) and IP (CONNECT-IP, RFC 9484) in HTTP/3.
+package masque
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"syscall"
+
+	"github.com/nxenon/xquic-go/http3"
+)
+
+// A Resolver resolves the hostnames of proxy targets.
+// It is implemented by net.Resolver.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+var _ Resolver = net.DefaultResolver
+
+// A Proxy is an http.Handler that proxies UDP packets for CONNECT-UDP requests.
+// It must be served by an http3.Server that has EnableExtendedConnect set.
+// If EnableDatagrams is set as well, UDP payloads are sent in HTTP datagrams,
+// otherwise they are sent in DATAGRAM capsules on the request stream.
+//
+// ServeHTTP blocks until the tunnel is closed,
+// either by the client closing the request stream, or by calling Close.
+type Proxy struct {
+	// Template is the URI template of the proxy.
+	// Requests that don't match the template are rejected.
+	Template *Template
+
+	// Resolver is used to resolve the hostnames of targets.
+	// If nil, net.DefaultResolver is used.
+	Resolver Resolver
+
+	// Allow, when set, is called for every request, after the target was resolved.
+	// If it returns false, the request is rejected with a 403 status code.
+	Allow func(r *http.Request, target netip.AddrPort) bool
+
+	mutex   sync.Mutex
+	closed  bool
+	tunnels map[*tunnel]*net.UDPConn
+}
+
+var _ http.Handler = &Proxy{}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mutex.Lock()
+	closed := p.closed
+	p.mutex.Unlock()
+	if closed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	req, err := ParseRequest(r, p.Template)
+	if err != nil {
+		var perr *RequestParseError
+		if errors.As(err, &perr) {
+			w.WriteHeader(perr.HTTPStatus)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	target, err := p.resolve(r.Context(), req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	if p.Allow != nil && !p.Allow(r, target) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	conn, err := net.DialUDP("udp", nil, net.UDPAddrFromAddrPort(target))
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	w.Header().Set(capsuleProtocolHeader, capsuleProtocolHeaderValue)
+	str, err := http3.AcceptExtendedConnect(w, r)
+	if err != nil {
+		conn.Close()
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	p.proxy(newTunnel(str, nil), conn)
+}
+
+func (p *Proxy) resolve(ctx context.Context, req *Request) (netip.AddrPort, error) {
+	if addr, err := netip.ParseAddr(req.Host); err == nil {
+		return netip.AddrPortFrom(addr.Unmap(), req.Port), nil
+	}
+	resolver := p.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupNetIP(ctx, "ip", req.Host)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	if len(addrs) == 0 {
+		return netip.AddrPort{}, fmt.Errorf("masque: no addresses found for %s", req.Host)
+	}
+	return netip.AddrPortFrom(addrs[0].Unmap(), req.Port), nil
+}
+
+// proxy proxies UDP payloads between the tunnel and the target, until either of them is closed.
+func (p *Proxy) proxy(t *tunnel, conn *net.UDPConn) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		t.close(net.ErrClosed)
+		conn.Close()
+		return
+	}
+	if p.tunnels == nil {
+		p.tunnels = make(map[*tunnel]*net.UDPConn)
+	}
+	p.tunnels[t] = conn
+	p.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, maxPayloadSize)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				// ICMP errors caused by previous packets don't close the tunnel
+				if errors.Is(err, syscall.ECONNREFUSED) {
+					continue
+				}
+				t.close(err)
+				return
+			}
+			if err := t.send(buf[:n]); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+	for {
+		payload, err := t.receive()
+		if err != nil {
+			break
+		}
+		// UDP is unreliable, errors for individual packets are ignored
+		conn.Write(payload)
+	}
+	conn.Close()
+	<-done
+
+	p.mutex.Lock()
+	delete(p.tunnels, t)
+	p.mutex.Unlock()
+}
+
+// Close closes the proxy.
+// All tunnels are closed, and new requests are rejected.
+func (p *Proxy) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closed = true
+	for t, conn := range p.tunnels {
+		t.close(net.ErrClosed)
+		conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package masque
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nxenon/xquic-go/http3"
+	"github.com/nxenon/xquic-go/internal/testdata"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runEchoServer runs a UDP server that echoes all packets it receives.
+func runEchoServer(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		b := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(b[:n], addr)
+		}
+	}()
+	return conn
+}
+
+// listen creates the UDP socket for an HTTP/3 server.
+func listen(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	return conn
+}
+
+// serve runs an HTTP/3 server on the UDP socket.
+func serve(t *testing.T, conn *net.UDPConn, handler http.Handler) {
+	t.Helper()
+	server := &http3.Server{
+		Handler:               handler,
+		TLSConfig:             testdata.GetTLSConfig(),
+		EnableDatagrams:       true,
+		EnableExtendedConnect: true,
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Serve(conn)
+	}()
+	t.Cleanup(func() {
+		server.Close()
+		conn.Close()
+		<-done
+	})
+}
+
+// runProxy runs an HTTP/3 server serving the proxy, and returns the proxy's URI template.
+func runProxy(t *testing.T, proxy *Proxy) *Template {
+	t.Helper()
+	conn := listen(t)
+	tmpl, err := ParseTemplate(fmt.Sprintf("https://localhost:%d/masque?h={target_host}&p={target_port}", conn.LocalAddr().(*net.UDPAddr).Port))
+	require.NoError(t, err)
+	proxy.Template = tmpl
+	t.Cleanup(func() { proxy.Close() })
+	serve(t, conn, proxy)
+	return tmpl
+}
+
+func newClient(t *testing.T, enableDatagrams bool) *Client {
+	t.Helper()
+	rt := &http3.RoundTripper{
+		TLSClientConfig: testdata.GetTLSConfig(),
+		EnableDatagrams: enableDatagrams,
+	}
+	rt.TLSClientConfig.RootCAs = testdata.GetRootCA()
+	t.Cleanup(func() { rt.Close() })
+	return &Client{RoundTripper: rt}
+}
+
+func TestProxying(t *testing.T) {
+	for _, enableDatagrams := range []bool{true, false} {
+		name := "using datagrams"
+		if !enableDatagrams {
+			name = "using capsules"
+		}
+		t.Run(name, func(t *testing.T) {
+			echo := runEchoServer(t)
+			tmpl := runProxy(t, &Proxy{})
+			client := newClient(t, enableDatagrams)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, rsp, err := client.Dial(ctx, tmpl, echo.LocalAddr().String())
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, rsp.StatusCode)
+			require.Equal(t, capsuleProtocolHeaderValue, rsp.Header.Get(capsuleProtocolHeader))
+			defer conn.Close()
+
+			b := make([]byte, 1500)
+			for i := 0; i < 10; i++ {
+				msg := fmt.Sprintf("foobar %d", i)
+				_, err := conn.WriteTo([]byte(msg), nil)
+				require.NoError(t, err)
+				require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+				n, addr, err := conn.ReadFrom(b)
+				require.NoError(t, err)
+				require.Equal(t, msg, string(b[:n]))
+				require.Equal(t, echo.LocalAddr().String(), addr.String())
+			}
+		})
+	}
+}
+
+func TestProxyResolver(t *testing.T) {
+	echo := runEchoServer(t)
+	resolver := &mockResolver{addr: netip.MustParseAddr("127.0.0.1"), hosts: make(chan string, 1)}
+	tmpl := runProxy(t, &Proxy{Resolver: resolver})
+	client := newClient(t, true)
+
+	conn, _, err := client.Dial(context.Background(), tmpl, fmt.Sprintf("echo.example.com:%d", echo.LocalAddr().(*net.UDPAddr).Port))
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, "echo.example.com", <-resolver.hosts)
+
+	_, err = conn.WriteTo([]byte("foobar"), nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	b := make([]byte, 1500)
+	n, _, err := conn.ReadFrom(b)
+	require.NoError(t, err)
+	require.Equal(t, "foobar", string(b[:n]))
+}
+
+func TestProxyRejections(t *testing.T) {
+	t.Run("resolution failure", func(t *testing.T) {
+		tmpl := runProxy(t, &Proxy{Resolver: &mockResolver{hosts: make(chan string, 1)}})
+		_, rsp, err := newClient(t, true).Dial(context.Background(), tmpl, "unknown.example.com:443")
+		require.Error(t, err)
+		require.Equal(t, http.StatusBadGateway, rsp.StatusCode)
+	})
+
+	t.Run("forbidden by policy", func(t *testing.T) {
+		targets := make(chan netip.AddrPort, 1)
+		tmpl := runProxy(t, &Proxy{
+			Allow: func(_ *http.Request, t netip.AddrPort) bool {
+				targets <- t
+				return false
+			},
+		})
+		_, rsp, err := newClient(t, true).Dial(context.Background(), tmpl, "127.0.0.1:1234")
+		require.Error(t, err)
+		require.Equal(t, http.StatusForbidden, rsp.StatusCode)
+		require.Equal(t, netip.MustParseAddrPort("127.0.0.1:1234"), <-targets)
+	})
+}
+
+func TestProxiedConnDeadline(t *testing.T) {
+	echo := runEchoServer(t)
+	tmpl := runProxy(t, &Proxy{})
+	conn, _, err := newClient(t, true).Dial(context.Background(), tmpl, echo.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadFrom(make([]byte, 1500))
+		errChan <- err
+	}()
+	select {
+	case <-errChan:
+		t.Fatal("ReadFrom returned before the deadline was set")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	select {
+	case err := <-errChan:
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestProxiedConnClose(t *testing.T) {
+	echo := runEchoServer(t)
+	tmpl := runProxy(t, &Proxy{})
+	conn, _, err := newClient(t, true).Dial(context.Background(), tmpl, echo.LocalAddr().String())
+	require.NoError(t, err)
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadFrom(make([]byte, 1500))
+		errChan <- err
+	}()
+	require.NoError(t, conn.Close())
+	select {
+	case err := <-errChan:
+		require.ErrorIs(t, err, net.ErrClosed)
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	_, err = conn.WriteTo([]byte("foobar"), nil)
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+type mockResolver struct {
+	addr  netip.Addr
+	hosts chan string
+}
+
+func (r *mockResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	r.hosts <- host
+	if !r.addr.IsValid() {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []netip.Addr{r.addr}, nil
+}
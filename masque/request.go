@@ -0,0 +1,164 @@
+package masque
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the value of the :protocol pseudo header of CONNECT-UDP requests
+	connectUDPProtocol = "connect-udp"
+
+	capsuleProtocolHeader      = "Capsule-Protocol"
+	capsuleProtocolHeaderValue = "?1"
+)
+
+// A Request is a parsed CONNECT-UDP request.
+type Request struct {
+	// Host is the target host, either a hostname or an IP address.
+	Host string
+	// Port is the target port.
+	Port uint16
+}
+
+// Target returns the target of the request, as host:port.
+func (r *Request) Target() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(int(r.Port)))
+}
+
+// A RequestParseError is returned by ParseRequest if the request is not a valid CONNECT-UDP request.
+type RequestParseError struct {
+	// HTTPStatus is the HTTP status code that should be sent in response to the request.
+	HTTPStatus int
+	Err        error
+}
+
+func (e *RequestParseError) Error() string { return e.Err.Error() }
+func (e *RequestParseError) Unwrap() error { return e.Err }
+
+// ParseRequest parses a CONNECT-UDP request, see section 3.4 of RFC 9298.
+// The request must match the template.
+func ParseRequest(r *http.Request, t *Template) (*Request, error) {
+	values, err := parseConnectRequest(r, t, connectUDPProtocol)
+	if err != nil {
+		return nil, err
+	}
+	host := values[varTargetHost]
+	if host == "" || host == "*" {
+		return nil, &RequestParseError{
+			HTTPStatus: http.StatusBadRequest,
+			Err:        fmt.Errorf("masque: invalid target host: %q", host),
+		}
+	}
+	port, err := strconv.ParseUint(values[varTargetPort], 10, 16)
+	if err != nil || port == 0 {
+		return nil, &RequestParseError{
+			HTTPStatus: http.StatusBadRequest,
+			Err:        fmt.Errorf("masque: invalid target port: %q", values[varTargetPort]),
+		}
+	}
+	return &Request{Host: host, Port: uint16(port)}, nil
+}
+
+// An IPRequest is a parsed CONNECT-IP request.
+type IPRequest struct {
+	// Target is the requested scope of the tunnel:
+	// "*" for any target, or a hostname, an IP address or an IP prefix.
+	Target string
+	// IPProtocol is the requested IP protocol, or 0 for any IP protocol.
+	IPProtocol uint8
+}
+
+// ParseIPRequest parses a CONNECT-IP request, see section 4 of RFC 9484.
+// The request must match the template.
+// If the template doesn't contain the target or ipproto variable, the tunnel is not scoped accordingly.
+func ParseIPRequest(r *http.Request, t *Template) (*IPRequest, error) {
+	values, err := parseConnectRequest(r, t, connectIPProtocol)
+	if err != nil {
+		return nil, err
+	}
+	req := &IPRequest{Target: "*"}
+	if target, ok := values[varTarget]; ok {
+		if !isValidIPTarget(target) {
+			return nil, &RequestParseError{
+				HTTPStatus: http.StatusBadRequest,
+				Err:        fmt.Errorf("masque: invalid target: %q", target),
+			}
+		}
+		req.Target = target
+	}
+	if proto, ok := values[varIPProto]; ok && proto != "*" {
+		p, err := strconv.ParseUint(proto, 10, 8)
+		if err != nil {
+			return nil, &RequestParseError{
+				HTTPStatus: http.StatusBadRequest,
+				Err:        fmt.Errorf("masque: invalid IP protocol: %q", proto),
+			}
+		}
+		req.IPProtocol = uint8(p)
+	}
+	return req, nil
+}
+
+func isValidIPTarget(target string) bool {
+	if target == "" {
+		return false
+	}
+	if strings.Contains(target, "/") {
+		_, err := netip.ParsePrefix(target)
+		return err == nil
+	}
+	return true
+}
+
+// parseConnectRequest performs the checks common to CONNECT-UDP and CONNECT-IP requests,
+// and returns the values of the template variables.
+func parseConnectRequest(r *http.Request, t *Template, protocol string) (map[string]string, error) {
+	if r.Method != http.MethodConnect {
+		return nil, &RequestParseError{
+			HTTPStatus: http.StatusMethodNotAllowed,
+			Err:        fmt.Errorf("masque: expected CONNECT request, got %s", r.Method),
+		}
+	}
+	if r.Proto != protocol {
+		return nil, &RequestParseError{
+			HTTPStatus: http.StatusNotImplemented,
+			Err:        fmt.Errorf("masque: unexpected protocol: %s", r.Proto),
+		}
+	}
+	if r.Host != t.host {
+		return nil, &RequestParseError{
+			HTTPStatus: http.StatusBadRequest,
+			Err:        fmt.Errorf("masque: host doesn't match template: %s", r.Host),
+		}
+	}
+	if !isCapsuleProtocol(r.Header.Get(capsuleProtocolHeader)) {
+		return nil, &RequestParseError{
+			HTTPStatus: http.StatusBadRequest,
+			Err:        errors.New("masque: missing Capsule-Protocol header"),
+		}
+	}
+	values, ok := t.match(r.URL)
+	if !ok {
+		return nil, &RequestParseError{
+			HTTPStatus: http.StatusBadRequest,
+			Err:        errors.New("masque: request doesn't match template"),
+		}
+	}
+	return values, nil
+}
+
+// isCapsuleProtocol checks that the Capsule-Protocol header is the Structured Field boolean true,
+// optionally followed by parameters, see section 3.4 of RFC 9297.
+func isCapsuleProtocol(v string) bool {
+	v = strings.TrimSpace(v)
+	if !strings.HasPrefix(v, capsuleProtocolHeaderValue) {
+		return false
+	}
+	return len(v) == len(capsuleProtocolHeaderValue) || v[len(capsuleProtocolHeaderValue)] == ';'
+}
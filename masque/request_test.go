@@ -0,0 +1,128 @@
+package masque
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newConnectUDPRequest(t *testing.T, target string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodConnect, target, nil)
+	require.NoError(t, err)
+	req.Proto = connectUDPProtocol
+	req.Header.Set(capsuleProtocolHeader, capsuleProtocolHeaderValue)
+	return req
+}
+
+func TestParseRequest(t *testing.T) {
+	tmpl, err := ParseTemplate("https://proxy.example.org/masque/{target_host}/{target_port}/")
+	require.NoError(t, err)
+
+	t.Run("valid request", func(t *testing.T) {
+		req, err := ParseRequest(newConnectUDPRequest(t, "https://proxy.example.org/masque/example.com/1234/"), tmpl)
+		require.NoError(t, err)
+		require.Equal(t, &Request{Host: "example.com", Port: 1234}, req)
+		require.Equal(t, "example.com:1234", req.Target())
+	})
+
+	t.Run("IPv6 target", func(t *testing.T) {
+		req, err := ParseRequest(newConnectUDPRequest(t, "https://proxy.example.org/masque/2001%3Adb8%3A%3A1/443/"), tmpl)
+		require.NoError(t, err)
+		require.Equal(t, "2001:db8::1", req.Host)
+		require.Equal(t, "[2001:db8::1]:443", req.Target())
+	})
+
+	t.Run("Capsule-Protocol with parameters", func(t *testing.T) {
+		r := newConnectUDPRequest(t, "https://proxy.example.org/masque/example.com/1234/")
+		r.Header.Set(capsuleProtocolHeader, "?1;foo=bar")
+		_, err := ParseRequest(r, tmpl)
+		require.NoError(t, err)
+	})
+
+	for _, tc := range []struct {
+		name   string
+		modify func(*http.Request)
+		status int
+	}{
+		{name: "wrong method", modify: func(r *http.Request) { r.Method = http.MethodGet }, status: http.StatusMethodNotAllowed},
+		{name: "wrong protocol", modify: func(r *http.Request) { r.Proto = "connect-ip" }, status: http.StatusNotImplemented},
+		{name: "wrong host", modify: func(r *http.Request) { r.Host = "other.example.org" }, status: http.StatusBadRequest},
+		{name: "missing Capsule-Protocol", modify: func(r *http.Request) { r.Header.Del(capsuleProtocolHeader) }, status: http.StatusBadRequest},
+		{name: "invalid Capsule-Protocol", modify: func(r *http.Request) { r.Header.Set(capsuleProtocolHeader, "?0") }, status: http.StatusBadRequest},
+		{name: "wrong path", modify: func(r *http.Request) { r.URL.Path = "/foo/example.com/1234/" }, status: http.StatusBadRequest},
+		{name: "wildcard host", modify: func(r *http.Request) { r.URL.Path = "/masque/*/1234/" }, status: http.StatusBadRequest},
+		{name: "empty host", modify: func(r *http.Request) { r.URL.Path = "/masque//1234/" }, status: http.StatusBadRequest},
+		{name: "invalid port", modify: func(r *http.Request) { r.URL.Path = "/masque/example.com/foo/" }, status: http.StatusBadRequest},
+		{name: "port out of range", modify: func(r *http.Request) { r.URL.Path = "/masque/example.com/65536/" }, status: http.StatusBadRequest},
+		{name: "zero port", modify: func(r *http.Request) { r.URL.Path = "/masque/example.com/0/" }, status: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newConnectUDPRequest(t, "https://proxy.example.org/masque/example.com/1234/")
+			tc.modify(r)
+			_, err := ParseRequest(r, tmpl)
+			require.Error(t, err)
+			var perr *RequestParseError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, tc.status, perr.HTTPStatus)
+		})
+	}
+}
+
+func TestParseIPRequest(t *testing.T) {
+	tmpl, err := ParseIPTemplate("https://proxy.example.org/masque/ip/{target}/{ipproto}/")
+	require.NoError(t, err)
+
+	newRequest := func(t *testing.T, target string) *http.Request {
+		req := newConnectUDPRequest(t, target)
+		req.Proto = connectIPProtocol
+		return req
+	}
+
+	for _, tc := range []struct {
+		name, url string
+		request   *IPRequest
+	}{
+		{name: "unscoped", url: "https://proxy.example.org/masque/ip/%2A/%2A/", request: &IPRequest{Target: "*"}},
+		{name: "IP prefix", url: "https://proxy.example.org/masque/ip/192.0.2.0%2F24/17/", request: &IPRequest{Target: "192.0.2.0/24", IPProtocol: 17}},
+		{name: "IPv6 address", url: "https://proxy.example.org/masque/ip/2001%3Adb8%3A%3A1/%2A/", request: &IPRequest{Target: "2001:db8::1"}},
+		{name: "hostname", url: "https://proxy.example.org/masque/ip/example.com/6/", request: &IPRequest{Target: "example.com", IPProtocol: 6}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := ParseIPRequest(newRequest(t, tc.url), tmpl)
+			require.NoError(t, err)
+			require.Equal(t, tc.request, req)
+		})
+	}
+
+	t.Run("template without variables", func(t *testing.T) {
+		tmpl, err := ParseIPTemplate("https://proxy.example.org/masque/ip")
+		require.NoError(t, err)
+		req, err := ParseIPRequest(newRequest(t, "https://proxy.example.org/masque/ip"), tmpl)
+		require.NoError(t, err)
+		require.Equal(t, &IPRequest{Target: "*"}, req)
+	})
+
+	for _, tc := range []struct {
+		name, url string
+		modify    func(*http.Request)
+		status    int
+	}{
+		{name: "CONNECT-UDP request", url: "https://proxy.example.org/masque/ip/%2A/%2A/", modify: func(r *http.Request) { r.Proto = connectUDPProtocol }, status: http.StatusNotImplemented},
+		{name: "invalid prefix", url: "https://proxy.example.org/masque/ip/192.0.2.0%2F33/%2A/", status: http.StatusBadRequest},
+		{name: "empty target", url: "https://proxy.example.org/masque/ip//%2A/", status: http.StatusBadRequest},
+		{name: "invalid IP protocol", url: "https://proxy.example.org/masque/ip/%2A/256/", status: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newRequest(t, tc.url)
+			if tc.modify != nil {
+				tc.modify(r)
+			}
+			_, err := ParseIPRequest(r, tmpl)
+			var perr *RequestParseError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, tc.status, perr.HTTPStatus)
+		})
+	}
+}
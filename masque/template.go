@@ -0,0 +1,317 @@
+package masque
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// variables of CONNECT-UDP templates
+	varTargetHost = "target_host"
+	varTargetPort = "target_port"
+
+	// variables of CONNECT-IP templates
+	varTarget  = "target"
+	varIPProto = "ipproto"
+)
+
+var varNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// A Template is the URI template (RFC 6570) of a proxy.
+// For CONNECT-UDP (see section 3 of RFC 9298), for example:
+// "https://proxy.example.org/.well-known/masque/udp/{target_host}/{target_port}/".
+// For CONNECT-IP (see section 3 of RFC 9484), for example:
+// "https://proxy.example.org/.well-known/masque/ip/{target}/{ipproto}/".
+//
+// Only the subset of RFC 6570 commonly used for CONNECT-UDP templates is supported:
+// simple string expansion ({var}) in the path, and form-style query expansion ({?var1,var2}),
+// or query parameters whose value is a simple expansion (?h={target_host}&p={target_port}).
+type Template struct {
+	raw  string
+	host string // the authority of the proxy
+
+	// path is a sequence of literals and variables.
+	// Variables are marked by a non-empty name.
+	path []templatePart
+	// query parameters, the value is either a literal or a variable
+	query []templateQueryParam
+
+	pathRegexp *regexp.Regexp
+}
+
+type templatePart struct {
+	literal string
+	name    string
+}
+
+type templateQueryParam struct {
+	key     string
+	literal string
+	name    string
+}
+
+// ParseTemplate parses the URI template of a CONNECT-UDP proxy.
+// The template must contain the target_host and target_port variables.
+func ParseTemplate(raw string) (*Template, error) {
+	t, err := parseTemplate(raw)
+	if err != nil {
+		return nil, err
+	}
+	vars := t.variables()
+	for _, name := range []string{varTargetHost, varTargetPort} {
+		switch vars[name] {
+		case 0:
+			return nil, fmt.Errorf("masque: template is missing the %s variable", name)
+		case 1:
+		default:
+			return nil, fmt.Errorf("masque: template contains the %s variable multiple times", name)
+		}
+	}
+	return t, nil
+}
+
+// ParseIPTemplate parses the URI template of a CONNECT-IP proxy.
+// The template may contain the target and ipproto variables, but no other variables.
+func ParseIPTemplate(raw string) (*Template, error) {
+	t, err := parseTemplate(raw)
+	if err != nil {
+		return nil, err
+	}
+	for name, n := range t.variables() {
+		if name != varTarget && name != varIPProto {
+			return nil, fmt.Errorf("masque: template contains unexpected variable %s", name)
+		}
+		if n > 1 {
+			return nil, fmt.Errorf("masque: template contains the %s variable multiple times", name)
+		}
+	}
+	return t, nil
+}
+
+func parseTemplate(raw string) (*Template, error) {
+	t := &Template{raw: raw}
+	if !strings.HasPrefix(raw, "https://") {
+		return nil, errors.New("masque: template must use the https scheme")
+	}
+	rest := raw[len("https://"):]
+	authorityEnd := strings.IndexAny(rest, "/?{")
+	if authorityEnd == -1 {
+		return nil, errors.New("masque: template without path")
+	}
+	if rest[authorityEnd] == '{' {
+		return nil, errors.New("masque: template must not contain variables in the authority")
+	}
+	t.host = rest[:authorityEnd]
+	if t.host == "" {
+		return nil, errors.New("masque: template without host")
+	}
+	rest = normalizeQueryExpressions(rest[authorityEnd:])
+
+	pathAndQuery := strings.SplitN(rest, "?", 2)
+	if err := t.parsePath(pathAndQuery[0]); err != nil {
+		return nil, err
+	}
+	if len(pathAndQuery) == 2 {
+		if err := t.parseQuery(pathAndQuery[1]); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// variables returns how often each variable occurs in the template.
+func (t *Template) variables() map[string]int {
+	vars := make(map[string]int)
+	for _, p := range t.path {
+		if p.name != "" {
+			vars[p.name]++
+		}
+	}
+	for _, q := range t.query {
+		if q.name != "" {
+			vars[q.name]++
+		}
+	}
+	return vars
+}
+
+// normalizeQueryExpressions rewrites form-style query expansions into query parameters,
+// e.g. {?a,b} into ?a={a}&b={b}.
+func normalizeQueryExpressions(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "{")
+		if start == -1 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := strings.Index(s[start:], "}")
+		if end == -1 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end += start
+		b.WriteString(s[:start])
+		expr := s[start+1 : end]
+		if len(expr) > 0 && (expr[0] == '?' || expr[0] == '&') {
+			for i, name := range strings.Split(expr[1:], ",") {
+				if i == 0 {
+					b.WriteByte(expr[0])
+				} else {
+					b.WriteByte('&')
+				}
+				b.WriteString(name + "={" + name + "}")
+			}
+		} else {
+			b.WriteString(s[start : end+1])
+		}
+		s = s[end+1:]
+	}
+}
+
+func (t *Template) parsePath(s string) error {
+	var re strings.Builder
+	re.WriteByte('^')
+	for len(s) > 0 {
+		start := strings.Index(s, "{")
+		if start == -1 {
+			t.path = append(t.path, templatePart{literal: s})
+			re.WriteString(regexp.QuoteMeta(s))
+			break
+		}
+		if start > 0 {
+			t.path = append(t.path, templatePart{literal: s[:start]})
+			re.WriteString(regexp.QuoteMeta(s[:start]))
+		}
+		end := strings.Index(s, "}")
+		if end < start {
+			return errors.New("masque: unterminated template expression")
+		}
+		name := s[start+1 : end]
+		if !varNameRegexp.MatchString(name) {
+			return fmt.Errorf("masque: unsupported template expression: {%s}", name)
+		}
+		t.path = append(t.path, templatePart{name: name})
+		re.WriteString(`([^/?#]*)`)
+		s = s[end+1:]
+	}
+	re.WriteByte('$')
+	var err error
+	t.pathRegexp, err = regexp.Compile(re.String())
+	return err
+}
+
+func (t *Template) parseQuery(s string) error {
+	for _, param := range strings.Split(s, "&") {
+		if param == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(param, "=")
+		if strings.ContainsAny(key, "{}") {
+			return fmt.Errorf("masque: unsupported query parameter in template: %s", param)
+		}
+		if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+			name := value[1 : len(value)-1]
+			if !varNameRegexp.MatchString(name) {
+				return fmt.Errorf("masque: unsupported template expression: %s", value)
+			}
+			t.query = append(t.query, templateQueryParam{key: key, name: name})
+			continue
+		}
+		if strings.ContainsAny(value, "{}") {
+			return fmt.Errorf("masque: unsupported query parameter in template: %s", param)
+		}
+		t.query = append(t.query, templateQueryParam{key: key, literal: value})
+	}
+	return nil
+}
+
+// Expand expands the template, using the values of the variables.
+func (t *Template) Expand(values map[string]string) string {
+	var b strings.Builder
+	b.WriteString("https://")
+	b.WriteString(t.host)
+	for _, p := range t.path {
+		if p.name == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		b.WriteString(escape(values[p.name]))
+	}
+	for i, q := range t.query {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(q.key)
+		b.WriteByte('=')
+		if q.name == "" {
+			b.WriteString(q.literal)
+		} else {
+			b.WriteString(escape(values[q.name]))
+		}
+	}
+	return b.String()
+}
+
+// match matches a request URL against the template.
+// It returns the values of the variables.
+func (t *Template) match(u *url.URL) (map[string]string, bool) {
+	m := t.pathRegexp.FindStringSubmatch(u.EscapedPath())
+	if m == nil {
+		return nil, false
+	}
+	values := make(map[string]string)
+	var i int
+	for _, p := range t.path {
+		if p.name == "" {
+			continue
+		}
+		i++
+		v, err := url.PathUnescape(m[i])
+		if err != nil {
+			return nil, false
+		}
+		values[p.name] = v
+	}
+	query := u.Query()
+	for _, q := range t.query {
+		if !query.Has(q.key) {
+			return nil, false
+		}
+		v := query.Get(q.key)
+		if q.name == "" {
+			if v != q.literal {
+				return nil, false
+			}
+			continue
+		}
+		values[q.name] = v
+	}
+	return values, true
+}
+
+// String returns the template.
+func (t *Template) String() string { return t.raw }
+
+// escape percent-encodes all characters except for unreserved characters,
+// as required for simple string expansion, see section 3.2.2 of RFC 6570.
+func escape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
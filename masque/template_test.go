@@ -0,0 +1,105 @@
+package masque
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateParsing(t *testing.T) {
+	for _, tc := range []struct {
+		name, template, err string
+	}{
+		{name: "not https", template: "http://proxy.example.org/{target_host}/{target_port}/", err: "https scheme"},
+		{name: "variable in authority", template: "https://{target_host}/{target_port}/", err: "authority"},
+		{name: "missing host", template: "https://proxy.example.org/{target_port}/", err: "missing the target_host variable"},
+		{name: "missing port", template: "https://proxy.example.org/{target_host}/", err: "missing the target_port variable"},
+		{name: "duplicate variable", template: "https://proxy.example.org/{target_host}/{target_port}/{target_host}", err: "multiple times"},
+		{name: "unsupported expression", template: "https://proxy.example.org/{+target_host}/{target_port}/", err: "unsupported template expression"},
+		{name: "unterminated expression", template: "https://proxy.example.org/{target_host}/{target_port", err: "unterminated"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseTemplate(tc.template)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestTemplateExpansion(t *testing.T) {
+	for _, tc := range []struct {
+		name, template, expanded string
+	}{
+		{
+			name:     "path",
+			template: "https://proxy.example.org/.well-known/masque/udp/{target_host}/{target_port}/",
+			expanded: "https://proxy.example.org/.well-known/masque/udp/2001%3Adb8%3A%3A1/443/",
+		},
+		{
+			name:     "form-style query",
+			template: "https://proxy.example.org:4443/masque{?target_host,target_port}",
+			expanded: "https://proxy.example.org:4443/masque?target_host=2001%3Adb8%3A%3A1&target_port=443",
+		},
+		{
+			name:     "query parameters",
+			template: "https://proxy.example.org/masque?proto=udp&h={target_host}&p={target_port}",
+			expanded: "https://proxy.example.org/masque?proto=udp&h=2001%3Adb8%3A%3A1&p=443",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tc.template)
+			require.NoError(t, err)
+			require.Equal(t, tc.template, tmpl.String())
+			expanded := tmpl.Expand(map[string]string{"target_host": "2001:db8::1", "target_port": "443"})
+			require.Equal(t, tc.expanded, expanded)
+
+			u, err := url.Parse(expanded)
+			require.NoError(t, err)
+			values, ok := tmpl.match(u)
+			require.True(t, ok)
+			require.Equal(t, map[string]string{"target_host": "2001:db8::1", "target_port": "443"}, values)
+		})
+	}
+}
+
+func TestTemplateMatching(t *testing.T) {
+	tmpl, err := ParseTemplate("https://proxy.example.org/masque/{target_host}/{target_port}?proto=udp")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name, url string
+		matches   bool
+	}{
+		{name: "matching", url: "https://proxy.example.org/masque/example.com/443?proto=udp", matches: true},
+		{name: "wrong path", url: "https://proxy.example.org/other/example.com/443?proto=udp"},
+		{name: "additional path segment", url: "https://proxy.example.org/masque/example.com/443/foo?proto=udp"},
+		{name: "missing query parameter", url: "https://proxy.example.org/masque/example.com/443"},
+		{name: "wrong query parameter", url: "https://proxy.example.org/masque/example.com/443?proto=tcp"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			require.NoError(t, err)
+			_, ok := tmpl.match(u)
+			require.Equal(t, tc.matches, ok)
+		})
+	}
+}
+
+func TestIPTemplateParsing(t *testing.T) {
+	tmpl, err := ParseIPTemplate("https://proxy.example.org/.well-known/masque/ip/{target}/{ipproto}/")
+	require.NoError(t, err)
+	require.Equal(t,
+		"https://proxy.example.org/.well-known/masque/ip/192.0.2.0%2F24/17/",
+		tmpl.Expand(map[string]string{"target": "192.0.2.0/24", "ipproto": "17"}),
+	)
+
+	_, err = ParseIPTemplate("https://proxy.example.org/masque/ip")
+	require.NoError(t, err)
+
+	_, err = ParseIPTemplate("https://proxy.example.org/masque/{target}/{target_port}")
+	require.EqualError(t, err, "masque: template contains unexpected variable target_port")
+	_, err = ParseIPTemplate("https://proxy.example.org/masque/{target}/{target}")
+	require.EqualError(t, err, "masque: template contains the target variable multiple times")
+	_, err = ParseIPTemplate("https://proxy.example.org")
+	require.EqualError(t, err, "masque: template without path")
+}
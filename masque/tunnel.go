@@ -0,0 +1,175 @@
+package masque
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/nxenon/xquic-go/http3"
+	"github.com/nxenon/xquic-go/quicvarint"
+)
+
+// capsuleTypeDatagram is the type of the DATAGRAM capsule, see section 3.5 of RFC 9297.
+const capsuleTypeDatagram http3.CapsuleType = 0x00
+
+// contextIDPayload is the context ID of HTTP datagrams carrying UDP payloads (see section 4 of RFC 9298)
+// or IP packets (see section 6 of RFC 9484).
+const contextIDPayload = 0
+
+// maxPayloadSize is the maximum size of a UDP payload or an IP packet.
+const maxPayloadSize = 1<<16 - 1
+
+// tunnelQueueLen is the number of received payloads that are queued per tunnel.
+// Payloads received when the queue is full are dropped.
+const tunnelQueueLen = 32
+
+// A tunnel sends and receives payloads on the stream of a CONNECT-UDP or CONNECT-IP request.
+// Payloads are sent in HTTP datagrams. If that's not possible, for example because the peer
+// doesn't support datagrams, or because the payload is too large, they are sent in DATAGRAM capsules.
+// Payloads are received both in HTTP datagrams and in DATAGRAM capsules.
+type tunnel struct {
+	str *http3.ExtendedConnectStream
+
+	// handleCapsule is called for capsules other than DATAGRAM capsules.
+	// The rest of the capsule is skipped after it returns.
+	// If it returns an error, the tunnel is closed.
+	// If nil, these capsules are skipped.
+	handleCapsule func(http3.CapsuleType, io.Reader) error
+
+	writeMutex sync.Mutex // capsules must not be interleaved
+
+	queue chan []byte
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	closeErr  error // set before closed is closed
+}
+
+func newTunnel(str *http3.ExtendedConnectStream, handleCapsule func(http3.CapsuleType, io.Reader) error) *tunnel {
+	t := &tunnel{
+		str:           str,
+		handleCapsule: handleCapsule,
+		queue:         make(chan []byte, tunnelQueueLen),
+		closed:        make(chan struct{}),
+	}
+	go t.receiveDatagrams()
+	go t.readCapsules()
+	return t
+}
+
+func (t *tunnel) send(p []byte) error {
+	select {
+	case <-t.closed:
+		return t.closeErr
+	default:
+	}
+	data := make([]byte, 0, 1+len(p))
+	data = quicvarint.Append(data, contextIDPayload)
+	data = append(data, p...)
+	if err := t.str.SendDatagram(data); err == nil {
+		return nil
+	}
+	return t.writeCapsule(capsuleTypeDatagram, data)
+}
+
+// writeCapsule writes a capsule to the stream.
+func (t *tunnel) writeCapsule(ct http3.CapsuleType, payload []byte) error {
+	select {
+	case <-t.closed:
+		return t.closeErr
+	default:
+	}
+	b := make([]byte, 0, 16+len(payload))
+	b = quicvarint.Append(b, uint64(ct))
+	b = quicvarint.Append(b, uint64(len(payload)))
+	b = append(b, payload...)
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+	_, err := t.str.Write(b)
+	return err
+}
+
+// receive returns the next payload received on the tunnel.
+func (t *tunnel) receive() ([]byte, error) {
+	select {
+	case p := <-t.queue:
+		return p, nil
+	case <-t.closed:
+		return nil, t.closeErr
+	}
+}
+
+func (t *tunnel) handleDatagram(data []byte) {
+	r := bytes.NewReader(data)
+	contextID, err := quicvarint.Read(r)
+	if err != nil || contextID != contextIDPayload {
+		// datagrams with an unknown context ID are dropped
+		return
+	}
+	select {
+	case t.queue <- data[len(data)-r.Len():]:
+	default:
+	}
+}
+
+func (t *tunnel) receiveDatagrams() {
+	for {
+		data, err := t.str.ReceiveDatagram(context.Background())
+		if err != nil {
+			// HTTP datagrams are not enabled, or the tunnel was closed
+			return
+		}
+		t.handleDatagram(data)
+	}
+}
+
+func (t *tunnel) readCapsules() {
+	r := quicvarint.NewReader(t.str)
+	for {
+		ct, cr, err := http3.ParseCapsule(r)
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				// the peer closed the stream
+				err = io.EOF
+			}
+			t.close(err)
+			return
+		}
+		if ct != capsuleTypeDatagram {
+			if t.handleCapsule != nil {
+				if err := t.handleCapsule(ct, cr); err != nil {
+					t.close(err)
+					return
+				}
+			}
+			// Capsules of unknown type are skipped, see section 3.2 of RFC 9297.
+			if _, err := io.Copy(io.Discard, cr); err != nil {
+				t.close(err)
+				return
+			}
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(cr, maxPayloadSize+2))
+		if err == nil && len(data) > maxPayloadSize+1 {
+			err = fmt.Errorf("masque: DATAGRAM capsule too large")
+		}
+		if err != nil {
+			t.close(err)
+			return
+		}
+		t.handleDatagram(data)
+	}
+}
+
+// close closes the tunnel and its stream.
+// Sending and receiving returns err from now on.
+func (t *tunnel) close(err error) {
+	t.closeOnce.Do(func() {
+		t.closeErr = err
+		close(t.closed)
+		t.str.Close()
+	})
+}
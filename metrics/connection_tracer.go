@@ -49,8 +49,182 @@ var (
 		},
 		[]string{"dir"},
 	)
+	connSmoothedRTT = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "connection_smoothed_rtt_seconds",
+			Help:      "Smoothed RTT of a Connection, at the time it was closed",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 1.5, 25), // up to 17s
+		},
+		[]string{"dir"},
+	)
+	connMinRTT = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "connection_min_rtt_seconds",
+			Help:      "Minimum RTT of a Connection",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 1.5, 25), // up to 17s
+		},
+		[]string{"dir"},
+	)
+	connCongestionWindow = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "connection_congestion_window_bytes",
+			Help:      "Congestion Window of a Connection, at the time it was closed",
+			Buckets:   prometheus.ExponentialBuckets(1024, 2, 16), // up to 32 MB
+		},
+		[]string{"dir"},
+	)
+	connPacketsLost = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "connection_packets_lost",
+			Help:      "Number of Packets lost during a Connection",
+			Buckets:   append([]float64{0}, prometheus.ExponentialBuckets(1, 2, 16)...),
+		},
+		[]string{"dir"},
+	)
+	connBytesLost = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "connection_bytes_lost",
+			Help:      "Number of Bytes lost during a Connection",
+			Buckets:   append([]float64{0}, prometheus.ExponentialBuckets(1024, 2, 20)...), // up to 512 MB
+		},
+		[]string{"dir"},
+	)
+	connPTOs = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "connection_ptos",
+			Help:      "Number of Probe Timeouts during a Connection",
+			Buckets:   prometheus.LinearBuckets(0, 1, 10),
+		},
+		[]string{"dir"},
+	)
+	connKeyUpdates = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "connection_key_updates",
+			Help:      "Number of Key Updates during a Connection",
+			Buckets:   append([]float64{0}, prometheus.ExponentialBuckets(1, 2, 10)...),
+		},
+		[]string{"dir"},
+	)
+	connECNValidation = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "connection_ecn_validation_total",
+			Help:      "Outcome of the ECN Validation",
+		},
+		[]string{"dir", "outcome"},
+	)
 )
 
+// packetNumberSpace identifies the packet number space of a sent packet.
+// 0-RTT and 1-RTT packets share a packet number space.
+type packetNumberSpace struct {
+	encLevel logging.EncryptionLevel
+	pn       logging.PacketNumber
+}
+
+type sentPacket struct {
+	encLevel logging.EncryptionLevel
+	size     logging.ByteCount
+}
+
+// sentPacketTracker stores the size of packets that haven't been acknowledged or declared lost yet.
+// This is needed since the LostPacket callback doesn't tell us the size of the lost packet.
+type sentPacketTracker map[packetNumberSpace]sentPacket
+
+// Sent tracks a sent packet, if it is ack-eliciting.
+func (s sentPacketTracker) Sent(encLevel logging.EncryptionLevel, pn logging.PacketNumber, size logging.ByteCount, frames []logging.Frame) {
+	// Packets that only contain ACK and CONNECTION_CLOSE frames are not ack-eliciting,
+	// and will therefore never be acknowledged or declared lost.
+	var isAckEliciting bool
+	for _, f := range frames {
+		if _, ok := f.(*logging.ConnectionCloseFrame); !ok {
+			isAckEliciting = true
+			break
+		}
+	}
+	if !isAckEliciting {
+		return
+	}
+	space := encLevel
+	if space == logging.Encryption0RTT {
+		space = logging.Encryption1RTT
+	}
+	s[packetNumberSpace{encLevel: space, pn: pn}] = sentPacket{encLevel: encLevel, size: size}
+}
+
+// Remove stops tracking a packet that was acknowledged or declared lost.
+// It returns the size of the packet, and false if the packet wasn't tracked.
+func (s sentPacketTracker) Remove(encLevel logging.EncryptionLevel, pn logging.PacketNumber) (logging.ByteCount, bool) {
+	if encLevel == logging.Encryption0RTT {
+		encLevel = logging.Encryption1RTT
+	}
+	key := packetNumberSpace{encLevel: encLevel, pn: pn}
+	p, ok := s[key]
+	if !ok {
+		return 0, false
+	}
+	delete(s, key)
+	return p.size, true
+}
+
+// DropEncryptionLevel stops tracking all packets sent at the encryption level.
+func (s sentPacketTracker) DropEncryptionLevel(encLevel logging.EncryptionLevel) {
+	for key, p := range s {
+		if p.encLevel == encLevel {
+			delete(s, key)
+		}
+	}
+}
+
+func encryptionLevelFromPacketType(t logging.PacketType) logging.EncryptionLevel {
+	//nolint:exhaustive // Only long header packet types carry data that needs to be acknowledged.
+	switch t {
+	case logging.PacketTypeInitial:
+		return logging.EncryptionInitial
+	case logging.PacketTypeHandshake:
+		return logging.EncryptionHandshake
+	default:
+		return logging.Encryption0RTT
+	}
+}
+
+func ecnValidationOutcome(state logging.ECNState, trigger logging.ECNStateTrigger) string {
+	switch state {
+	case logging.ECNStateTesting:
+		return "testing"
+	case logging.ECNStateUnknown:
+		return "unknown"
+	case logging.ECNStateCapable:
+		return "capable"
+	case logging.ECNStateFailed:
+		switch trigger {
+		case logging.ECNFailedNoECNCounts:
+			return "failed_no_ecn_counts"
+		case logging.ECNFailedDecreasedECNCounts:
+			return "failed_decreased_ecn_counts"
+		case logging.ECNFailedLostAllTestingPackets:
+			return "failed_lost_all_testing_packets"
+		case logging.ECNFailedMoreECNCountsThanSent:
+			return "failed_more_ecn_counts_than_sent"
+		case logging.ECNFailedTooFewECNCounts:
+			return "failed_too_few_ecn_counts"
+		case logging.ECNFailedManglingDetected:
+			return "failed_mangling_detected"
+		default:
+			return "failed"
+		}
+	default:
+		return "unknown"
+	}
+}
+
 // DefaultConnectionTracer returns a callback that creates a metrics ConnectionTracer.
 // The ConnectionTracer returned can be set on the quic.Config for a new connection.
 func DefaultConnectionTracer(_ context.Context, p logging.Perspective, _ logging.ConnectionID) *logging.ConnectionTracer {
@@ -82,6 +256,14 @@ func newConnectionTracerWithRegisterer(registerer prometheus.Registerer, isClien
 		connHandshakeDuration,
 		connClosed,
 		connDuration,
+		connSmoothedRTT,
+		connMinRTT,
+		connCongestionWindow,
+		connPacketsLost,
+		connBytesLost,
+		connPTOs,
+		connKeyUpdates,
+		connECNValidation,
 	} {
 		if err := registerer.Register(c); err != nil {
 			if ok := errors.As(err, &prometheus.AlreadyRegisteredError{}); !ok {
@@ -98,6 +280,15 @@ func newConnectionTracerWithRegisterer(registerer prometheus.Registerer, isClien
 	var (
 		startTime         time.Time
 		handshakeComplete bool
+
+		smoothedRTT, minRTT time.Duration
+		cwnd                logging.ByteCount
+		packetsLost         int
+		bytesLost           logging.ByteCount
+		ptos                int
+		keyUpdates          int
+		ecnOutcome          = "disabled" // until we receive the first ECN state update
+		sentPackets         = make(sentPacketTracker)
 	)
 	return &logging.ConnectionTracer{
 		StartedConnection: func(_, _ net.Addr, _, _ logging.ConnectionID) {
@@ -117,6 +308,16 @@ func newConnectionTracerWithRegisterer(registerer prometheus.Registerer, isClien
 			// call connDuration.Observe before adding any more labels
 			if handshakeComplete {
 				connDuration.WithLabelValues(*tags...).Observe(time.Since(startTime).Seconds())
+				if minRTT > 0 {
+					connSmoothedRTT.WithLabelValues(*tags...).Observe(smoothedRTT.Seconds())
+					connMinRTT.WithLabelValues(*tags...).Observe(minRTT.Seconds())
+				}
+				connCongestionWindow.WithLabelValues(*tags...).Observe(float64(cwnd))
+				connPacketsLost.WithLabelValues(*tags...).Observe(float64(packetsLost))
+				connBytesLost.WithLabelValues(*tags...).Observe(float64(bytesLost))
+				connPTOs.WithLabelValues(*tags...).Observe(float64(ptos))
+				connKeyUpdates.WithLabelValues(*tags...).Observe(float64(keyUpdates))
+				connECNValidation.WithLabelValues(direction, ecnOutcome).Inc()
 			}
 
 			var (
@@ -188,5 +389,40 @@ func newConnectionTracerWithRegisterer(registerer prometheus.Registerer, isClien
 			*tags = append(*tags, direction)
 			connHandshakeDuration.WithLabelValues(*tags...).Observe(time.Since(startTime).Seconds())
 		},
+		SentLongHeaderPacket: func(hdr *logging.ExtendedHeader, size logging.ByteCount, _ logging.ECN, _ *logging.AckFrame, frames []logging.Frame) {
+			sentPackets.Sent(encryptionLevelFromPacketType(logging.PacketTypeFromHeader(&hdr.Header)), hdr.PacketNumber, size, frames)
+		},
+		SentShortHeaderPacket: func(hdr *logging.ShortHeader, size logging.ByteCount, _ logging.ECN, _ *logging.AckFrame, frames []logging.Frame) {
+			sentPackets.Sent(logging.Encryption1RTT, hdr.PacketNumber, size, frames)
+		},
+		AcknowledgedPacket: func(encLevel logging.EncryptionLevel, pn logging.PacketNumber) {
+			sentPackets.Remove(encLevel, pn)
+		},
+		LostPacket: func(encLevel logging.EncryptionLevel, pn logging.PacketNumber, _ logging.PacketLossReason) {
+			packetsLost++
+			if size, ok := sentPackets.Remove(encLevel, pn); ok {
+				bytesLost += size
+			}
+		},
+		DroppedEncryptionLevel: func(encLevel logging.EncryptionLevel) {
+			sentPackets.DropEncryptionLevel(encLevel)
+		},
+		UpdatedMetrics: func(rttStats *logging.RTTStats, congestionWindow, _ logging.ByteCount, _ int) {
+			smoothedRTT = rttStats.SmoothedRTT()
+			minRTT = rttStats.MinRTT()
+			cwnd = congestionWindow
+		},
+		UpdatedPTOCount: func(value uint32) {
+			// The PTO count is reset to 0 when an acknowledgement is received.
+			if value > 0 {
+				ptos++
+			}
+		},
+		UpdatedKey: func(logging.KeyPhase, bool) {
+			keyUpdates++
+		},
+		ECNStateUpdated: func(state logging.ECNState, trigger logging.ECNStateTrigger) {
+			ecnOutcome = ecnValidationOutcome(state, trigger)
+		},
 	}
 }
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/logging"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSentPacketTrackerAckAndLoss(t *testing.T) {
+	s := make(sentPacketTracker)
+	s.Sent(logging.Encryption1RTT, 1, 1000, []logging.Frame{&logging.PingFrame{}})
+	s.Sent(logging.Encryption1RTT, 2, 1200, []logging.Frame{&logging.PingFrame{}})
+	require.Len(t, s, 2)
+
+	// acknowledged
+	size, ok := s.Remove(logging.Encryption1RTT, 1)
+	require.True(t, ok)
+	require.Equal(t, logging.ByteCount(1000), size)
+	require.Len(t, s, 1)
+	_, ok = s.Remove(logging.Encryption1RTT, 1)
+	require.False(t, ok)
+
+	// declared lost
+	size, ok = s.Remove(logging.Encryption1RTT, 2)
+	require.True(t, ok)
+	require.Equal(t, logging.ByteCount(1200), size)
+	require.Empty(t, s)
+}
+
+func TestSentPacketTrackerNonAckElicitingPackets(t *testing.T) {
+	s := make(sentPacketTracker)
+	s.Sent(logging.Encryption1RTT, 1, 100, nil)
+	s.Sent(logging.Encryption1RTT, 2, 100, []logging.Frame{&logging.ConnectionCloseFrame{}})
+	require.Empty(t, s)
+}
+
+func TestSentPacketTracker0RTT(t *testing.T) {
+	s := make(sentPacketTracker)
+	// 0-RTT and 1-RTT packets share a packet number space
+	s.Sent(logging.Encryption0RTT, 1, 1000, []logging.Frame{&logging.PingFrame{}})
+	size, ok := s.Remove(logging.Encryption1RTT, 1)
+	require.True(t, ok)
+	require.Equal(t, logging.ByteCount(1000), size)
+	require.Empty(t, s)
+}
+
+func TestSentPacketTrackerDropEncryptionLevel(t *testing.T) {
+	s := make(sentPacketTracker)
+	frames := []logging.Frame{&logging.PingFrame{}}
+	s.Sent(logging.EncryptionInitial, 0, 1200, frames)
+	s.Sent(logging.EncryptionHandshake, 0, 1100, frames)
+	s.Sent(logging.Encryption0RTT, 0, 1000, frames)
+	s.Sent(logging.Encryption1RTT, 1, 900, frames)
+
+	s.DropEncryptionLevel(logging.EncryptionInitial)
+	require.Len(t, s, 3)
+	_, ok := s.Remove(logging.EncryptionInitial, 0)
+	require.False(t, ok)
+
+	s.DropEncryptionLevel(logging.Encryption0RTT)
+	require.Len(t, s, 2)
+	_, ok = s.Remove(logging.Encryption1RTT, 0)
+	require.False(t, ok)
+	size, ok := s.Remove(logging.Encryption1RTT, 1)
+	require.True(t, ok)
+	require.Equal(t, logging.ByteCount(900), size)
+	size, ok = s.Remove(logging.EncryptionHandshake, 0)
+	require.True(t, ok)
+	require.Equal(t, logging.ByteCount(1100), size)
+}
+
+func TestConnectionTracerBytesLost(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	tr := NewClientConnectionTracerWithRegisterer(registry)
+	tr.StartedConnection(nil, nil, logging.ConnectionID{}, logging.ConnectionID{})
+	tr.UpdatedKeyFromTLS(logging.Encryption1RTT, logging.PerspectiveClient)
+	frames := []logging.Frame{&logging.PingFrame{}}
+	for pn := logging.PacketNumber(1); pn <= 3; pn++ {
+		tr.SentShortHeaderPacket(&logging.ShortHeader{PacketNumber: pn}, 1000+logging.ByteCount(pn), logging.ECNUnsupported, nil, frames)
+	}
+	tr.AcknowledgedPacket(logging.Encryption1RTT, 1)
+	tr.LostPacket(logging.Encryption1RTT, 2, logging.PacketLossReorderingThreshold)
+	// the size of a packet is only counted once, even if the callbacks are called again
+	tr.LostPacket(logging.Encryption1RTT, 2, logging.PacketLossTimeThreshold)
+	tr.LostPacket(logging.Encryption1RTT, 1, logging.PacketLossReorderingThreshold)
+	tr.ClosedConnection(&quic.IdleTimeoutError{})
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	var found bool
+	for _, f := range families {
+		if f.GetName() != "quicgo_connection_bytes_lost" {
+			continue
+		}
+		found = true
+		require.Len(t, f.GetMetric(), 1)
+		h := f.GetMetric()[0].GetHistogram()
+		require.Equal(t, uint64(1), h.GetSampleCount())
+		require.Equal(t, float64(1002), h.GetSampleSum())
+	}
+	require.True(t, found)
+}
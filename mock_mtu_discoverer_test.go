@@ -117,6 +117,78 @@ func (c *MTUDiscovererGetPingCall) DoAndReturn(f func() (ackhandler.Frame, proto
 	return c
 }
 
+// OnPacketAcked mocks base method.
+func (m *MockMTUDiscoverer) OnPacketAcked(arg0 protocol.PacketNumber, arg1 protocol.ByteCount) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnPacketAcked", arg0, arg1)
+}
+
+// OnPacketAcked indicates an expected call of OnPacketAcked.
+func (mr *MockMTUDiscovererMockRecorder) OnPacketAcked(arg0, arg1 any) *MTUDiscovererOnPacketAckedCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnPacketAcked", reflect.TypeOf((*MockMTUDiscoverer)(nil).OnPacketAcked), arg0, arg1)
+	return &MTUDiscovererOnPacketAckedCall{Call: call}
+}
+
+// MTUDiscovererOnPacketAckedCall wrap *gomock.Call
+type MTUDiscovererOnPacketAckedCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MTUDiscovererOnPacketAckedCall) Return() *MTUDiscovererOnPacketAckedCall {
+	c.Call = c.Call.Return()
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MTUDiscovererOnPacketAckedCall) Do(f func(protocol.PacketNumber, protocol.ByteCount)) *MTUDiscovererOnPacketAckedCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MTUDiscovererOnPacketAckedCall) DoAndReturn(f func(protocol.PacketNumber, protocol.ByteCount)) *MTUDiscovererOnPacketAckedCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// OnPacketLost mocks base method.
+func (m *MockMTUDiscoverer) OnPacketLost(arg0 protocol.PacketNumber, arg1 protocol.ByteCount) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnPacketLost", arg0, arg1)
+}
+
+// OnPacketLost indicates an expected call of OnPacketLost.
+func (mr *MockMTUDiscovererMockRecorder) OnPacketLost(arg0, arg1 any) *MTUDiscovererOnPacketLostCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnPacketLost", reflect.TypeOf((*MockMTUDiscoverer)(nil).OnPacketLost), arg0, arg1)
+	return &MTUDiscovererOnPacketLostCall{Call: call}
+}
+
+// MTUDiscovererOnPacketLostCall wrap *gomock.Call
+type MTUDiscovererOnPacketLostCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MTUDiscovererOnPacketLostCall) Return() *MTUDiscovererOnPacketLostCall {
+	c.Call = c.Call.Return()
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MTUDiscovererOnPacketLostCall) Do(f func(protocol.PacketNumber, protocol.ByteCount)) *MTUDiscovererOnPacketLostCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MTUDiscovererOnPacketLostCall) DoAndReturn(f func(protocol.PacketNumber, protocol.ByteCount)) *MTUDiscovererOnPacketLostCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // ShouldSendProbe mocks base method.
 func (m *MockMTUDiscoverer) ShouldSendProbe(arg0 time.Time) bool {
 	m.ctrl.T.Helper()
@@ -302,6 +302,42 @@ func (c *PacketHandlerManagerGetStatelessResetTokenCall) DoAndReturn(f func(prot
 	return c
 }
 
+// Range mocks base method.
+func (m *MockPacketHandlerManager) Range(arg0 func(protocol.ConnectionID, packetHandler)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Range", arg0)
+}
+
+// Range indicates an expected call of Range.
+func (mr *MockPacketHandlerManagerMockRecorder) Range(arg0 any) *PacketHandlerManagerRangeCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Range", reflect.TypeOf((*MockPacketHandlerManager)(nil).Range), arg0)
+	return &PacketHandlerManagerRangeCall{Call: call}
+}
+
+// PacketHandlerManagerRangeCall wrap *gomock.Call
+type PacketHandlerManagerRangeCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *PacketHandlerManagerRangeCall) Return() *PacketHandlerManagerRangeCall {
+	c.Call = c.Call.Return()
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *PacketHandlerManagerRangeCall) Do(f func(func(protocol.ConnectionID, packetHandler))) *PacketHandlerManagerRangeCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *PacketHandlerManagerRangeCall) DoAndReturn(f func(func(protocol.ConnectionID, packetHandler))) *PacketHandlerManagerRangeCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Remove mocks base method.
 func (m *MockPacketHandlerManager) Remove(arg0 protocol.ConnectionID) {
 	m.ctrl.T.Helper()
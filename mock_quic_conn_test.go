@@ -656,6 +656,82 @@ func (c *QUICConnSendDatagramCall) DoAndReturn(f func([]byte) error) *QUICConnSe
 	return c
 }
 
+// SetDSCP mocks base method.
+func (m *MockQUICConn) SetDSCP(arg0 uint8) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDSCP", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDSCP indicates an expected call of SetDSCP.
+func (mr *MockQUICConnMockRecorder) SetDSCP(arg0 any) *QUICConnSetDSCPCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDSCP", reflect.TypeOf((*MockQUICConn)(nil).SetDSCP), arg0)
+	return &QUICConnSetDSCPCall{Call: call}
+}
+
+// QUICConnSetDSCPCall wrap *gomock.Call
+type QUICConnSetDSCPCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *QUICConnSetDSCPCall) Return(arg0 error) *QUICConnSetDSCPCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *QUICConnSetDSCPCall) Do(f func(uint8) error) *QUICConnSetDSCPCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *QUICConnSetDSCPCall) DoAndReturn(f func(uint8) error) *QUICConnSetDSCPCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// connectionStats mocks base method.
+func (m *MockQUICConn) connectionStats() ConnectionStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "connectionStats")
+	ret0, _ := ret[0].(ConnectionStats)
+	return ret0
+}
+
+// connectionStats indicates an expected call of connectionStats.
+func (mr *MockQUICConnMockRecorder) connectionStats() *QUICConnconnectionStatsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "connectionStats", reflect.TypeOf((*MockQUICConn)(nil).connectionStats))
+	return &QUICConnconnectionStatsCall{Call: call}
+}
+
+// QUICConnconnectionStatsCall wrap *gomock.Call
+type QUICConnconnectionStatsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *QUICConnconnectionStatsCall) Return(arg0 ConnectionStats) *QUICConnconnectionStatsCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *QUICConnconnectionStatsCall) Do(f func() ConnectionStats) *QUICConnconnectionStatsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *QUICConnconnectionStatsCall) DoAndReturn(f func() ConnectionStats) *QUICConnconnectionStatsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // destroy mocks base method.
 func (m *MockQUICConn) destroy(arg0 error) {
 	m.ctrl.T.Helper()
@@ -842,6 +918,42 @@ func (c *QUICConnrunCall) DoAndReturn(f func() error) *QUICConnrunCall {
 	return c
 }
 
+// runInWorkerPool mocks base method.
+func (m *MockQUICConn) runInWorkerPool() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "runInWorkerPool")
+}
+
+// runInWorkerPool indicates an expected call of runInWorkerPool.
+func (mr *MockQUICConnMockRecorder) runInWorkerPool() *QUICConnrunInWorkerPoolCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "runInWorkerPool", reflect.TypeOf((*MockQUICConn)(nil).runInWorkerPool))
+	return &QUICConnrunInWorkerPoolCall{Call: call}
+}
+
+// QUICConnrunInWorkerPoolCall wrap *gomock.Call
+type QUICConnrunInWorkerPoolCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *QUICConnrunInWorkerPoolCall) Return() *QUICConnrunInWorkerPoolCall {
+	c.Call = c.Call.Return()
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *QUICConnrunInWorkerPoolCall) Do(f func()) *QUICConnrunInWorkerPoolCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *QUICConnrunInWorkerPoolCall) DoAndReturn(f func()) *QUICConnrunInWorkerPoolCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // shutdown mocks base method.
 func (m *MockQUICConn) shutdown() {
 	m.ctrl.T.Helper()
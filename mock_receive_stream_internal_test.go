@@ -77,6 +77,83 @@ func (c *ReceiveStreamICancelReadCall) DoAndReturn(f func(qerr.StreamErrorCode))
 	return c
 }
 
+// Consume mocks base method.
+func (m *MockReceiveStreamI) Consume(arg0 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockReceiveStreamIMockRecorder) Consume(arg0 any) *ReceiveStreamIConsumeCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockReceiveStreamI)(nil).Consume), arg0)
+	return &ReceiveStreamIConsumeCall{Call: call}
+}
+
+// ReceiveStreamIConsumeCall wrap *gomock.Call
+type ReceiveStreamIConsumeCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *ReceiveStreamIConsumeCall) Return(arg0 error) *ReceiveStreamIConsumeCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *ReceiveStreamIConsumeCall) Do(f func(int) error) *ReceiveStreamIConsumeCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *ReceiveStreamIConsumeCall) DoAndReturn(f func(int) error) *ReceiveStreamIConsumeCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Peek mocks base method.
+func (m *MockReceiveStreamI) Peek() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockReceiveStreamIMockRecorder) Peek() *ReceiveStreamIPeekCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockReceiveStreamI)(nil).Peek))
+	return &ReceiveStreamIPeekCall{Call: call}
+}
+
+// ReceiveStreamIPeekCall wrap *gomock.Call
+type ReceiveStreamIPeekCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *ReceiveStreamIPeekCall) Return(arg0 []byte, arg1 error) *ReceiveStreamIPeekCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *ReceiveStreamIPeekCall) Do(f func() ([]byte, error)) *ReceiveStreamIPeekCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *ReceiveStreamIPeekCall) DoAndReturn(f func() ([]byte, error)) *ReceiveStreamIPeekCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Read mocks base method.
 func (m *MockReceiveStreamI) Read(arg0 []byte) (int, error) {
 	m.ctrl.T.Helper()
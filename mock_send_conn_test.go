@@ -154,17 +154,17 @@ func (c *SendConnRemoteAddrCall) DoAndReturn(f func() net.Addr) *SendConnRemoteA
 }
 
 // Write mocks base method.
-func (m *MockSendConn) Write(arg0 []byte, arg1 uint16, arg2 protocol.ECN) error {
+func (m *MockSendConn) Write(arg0 []byte, arg1 uint16, arg2 protocol.ECN, arg3 uint8) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Write", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Write", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Write indicates an expected call of Write.
-func (mr *MockSendConnMockRecorder) Write(arg0, arg1, arg2 any) *SendConnWriteCall {
+func (mr *MockSendConnMockRecorder) Write(arg0, arg1, arg2, arg3 any) *SendConnWriteCall {
 	mr.mock.ctrl.T.Helper()
-	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockSendConn)(nil).Write), arg0, arg1, arg2)
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockSendConn)(nil).Write), arg0, arg1, arg2, arg3)
 	return &SendConnWriteCall{Call: call}
 }
 
@@ -180,13 +180,13 @@ func (c *SendConnWriteCall) Return(arg0 error) *SendConnWriteCall {
 }
 
 // Do rewrite *gomock.Call.Do
-func (c *SendConnWriteCall) Do(f func([]byte, uint16, protocol.ECN) error) *SendConnWriteCall {
+func (c *SendConnWriteCall) Do(f func([]byte, uint16, protocol.ECN, uint8) error) *SendConnWriteCall {
 	c.Call = c.Call.Do(f)
 	return c
 }
 
 // DoAndReturn rewrite *gomock.Call.DoAndReturn
-func (c *SendConnWriteCall) DoAndReturn(f func([]byte, uint16, protocol.ECN) error) *SendConnWriteCall {
+func (c *SendConnWriteCall) DoAndReturn(f func([]byte, uint16, protocol.ECN, uint8) error) *SendConnWriteCall {
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
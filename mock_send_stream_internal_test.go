@@ -10,6 +10,7 @@ package quic
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 	time "time"
 
@@ -155,6 +156,121 @@ func (c *SendStreamIContextCall) DoAndReturn(f func() context.Context) *SendStre
 	return c
 }
 
+// ReadFrom mocks base method.
+func (m *MockSendStreamI) ReadFrom(arg0 io.Reader) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadFrom", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadFrom indicates an expected call of ReadFrom.
+func (mr *MockSendStreamIMockRecorder) ReadFrom(arg0 any) *SendStreamIReadFromCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFrom", reflect.TypeOf((*MockSendStreamI)(nil).ReadFrom), arg0)
+	return &SendStreamIReadFromCall{Call: call}
+}
+
+// SendStreamIReadFromCall wrap *gomock.Call
+type SendStreamIReadFromCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *SendStreamIReadFromCall) Return(arg0 int64, arg1 error) *SendStreamIReadFromCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *SendStreamIReadFromCall) Do(f func(io.Reader) (int64, error)) *SendStreamIReadFromCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *SendStreamIReadFromCall) DoAndReturn(f func(io.Reader) (int64, error)) *SendStreamIReadFromCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// SetDSCP mocks base method.
+func (m *MockSendStreamI) SetDSCP(arg0 uint8) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDSCP", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDSCP indicates an expected call of SetDSCP.
+func (mr *MockSendStreamIMockRecorder) SetDSCP(arg0 any) *SendStreamISetDSCPCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDSCP", reflect.TypeOf((*MockSendStreamI)(nil).SetDSCP), arg0)
+	return &SendStreamISetDSCPCall{Call: call}
+}
+
+// SendStreamISetDSCPCall wrap *gomock.Call
+type SendStreamISetDSCPCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *SendStreamISetDSCPCall) Return(arg0 error) *SendStreamISetDSCPCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *SendStreamISetDSCPCall) Do(f func(uint8) error) *SendStreamISetDSCPCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *SendStreamISetDSCPCall) DoAndReturn(f func(uint8) error) *SendStreamISetDSCPCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// SetPriority mocks base method.
+func (m *MockSendStreamI) SetPriority(arg0 StreamPriority) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPriority", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPriority indicates an expected call of SetPriority.
+func (mr *MockSendStreamIMockRecorder) SetPriority(arg0 any) *SendStreamISetPriorityCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPriority", reflect.TypeOf((*MockSendStreamI)(nil).SetPriority), arg0)
+	return &SendStreamISetPriorityCall{Call: call}
+}
+
+// SendStreamISetPriorityCall wrap *gomock.Call
+type SendStreamISetPriorityCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *SendStreamISetPriorityCall) Return(arg0 error) *SendStreamISetPriorityCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *SendStreamISetPriorityCall) Do(f func(StreamPriority) error) *SendStreamISetPriorityCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *SendStreamISetPriorityCall) DoAndReturn(f func(StreamPriority) error) *SendStreamISetPriorityCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // SetWriteDeadline mocks base method.
 func (m *MockSendStreamI) SetWriteDeadline(arg0 time.Time) error {
 	m.ctrl.T.Helper()
@@ -270,6 +386,45 @@ func (c *SendStreamIWriteCall) DoAndReturn(f func([]byte) (int, error)) *SendStr
 	return c
 }
 
+// WriteBuffer mocks base method.
+func (m *MockSendStreamI) WriteBuffer(arg0 []byte, arg1 func()) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteBuffer", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteBuffer indicates an expected call of WriteBuffer.
+func (mr *MockSendStreamIMockRecorder) WriteBuffer(arg0, arg1 any) *SendStreamIWriteBufferCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteBuffer", reflect.TypeOf((*MockSendStreamI)(nil).WriteBuffer), arg0, arg1)
+	return &SendStreamIWriteBufferCall{Call: call}
+}
+
+// SendStreamIWriteBufferCall wrap *gomock.Call
+type SendStreamIWriteBufferCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *SendStreamIWriteBufferCall) Return(arg0 int, arg1 error) *SendStreamIWriteBufferCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *SendStreamIWriteBufferCall) Do(f func([]byte, func()) (int, error)) *SendStreamIWriteBufferCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *SendStreamIWriteBufferCall) DoAndReturn(f func([]byte, func()) (int, error)) *SendStreamIWriteBufferCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // closeForShutdown mocks base method.
 func (m *MockSendStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.T.Helper()
@@ -306,6 +461,44 @@ func (c *SendStreamIcloseForShutdownCall) DoAndReturn(f func(error)) *SendStream
 	return c
 }
 
+// dscpClass mocks base method.
+func (m *MockSendStreamI) dscpClass() dscpClass {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "dscpClass")
+	ret0, _ := ret[0].(dscpClass)
+	return ret0
+}
+
+// dscpClass indicates an expected call of dscpClass.
+func (mr *MockSendStreamIMockRecorder) dscpClass() *SendStreamIdscpClassCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "dscpClass", reflect.TypeOf((*MockSendStreamI)(nil).dscpClass))
+	return &SendStreamIdscpClassCall{Call: call}
+}
+
+// SendStreamIdscpClassCall wrap *gomock.Call
+type SendStreamIdscpClassCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *SendStreamIdscpClassCall) Return(arg0 dscpClass) *SendStreamIdscpClassCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *SendStreamIdscpClassCall) Do(f func() dscpClass) *SendStreamIdscpClassCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *SendStreamIdscpClassCall) DoAndReturn(f func() dscpClass) *SendStreamIdscpClassCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // handleStopSendingFrame mocks base method.
 func (m *MockSendStreamI) handleStopSendingFrame(arg0 *wire.StopSendingFrame) {
 	m.ctrl.T.Helper()
@@ -151,15 +151,15 @@ func (c *SenderRunCall) DoAndReturn(f func() error) *SenderRunCall {
 }
 
 // Send mocks base method.
-func (m *MockSender) Send(arg0 *packetBuffer, arg1 uint16, arg2 protocol.ECN) {
+func (m *MockSender) Send(arg0 *packetBuffer, arg1 uint16, arg2 protocol.ECN, arg3 uint8) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Send", arg0, arg1, arg2)
+	m.ctrl.Call(m, "Send", arg0, arg1, arg2, arg3)
 }
 
 // Send indicates an expected call of Send.
-func (mr *MockSenderMockRecorder) Send(arg0, arg1, arg2 any) *SenderSendCall {
+func (mr *MockSenderMockRecorder) Send(arg0, arg1, arg2, arg3 any) *SenderSendCall {
 	mr.mock.ctrl.T.Helper()
-	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockSender)(nil).Send), arg0, arg1, arg2)
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockSender)(nil).Send), arg0, arg1, arg2, arg3)
 	return &SenderSendCall{Call: call}
 }
 
@@ -175,13 +175,13 @@ func (c *SenderSendCall) Return() *SenderSendCall {
 }
 
 // Do rewrite *gomock.Call.Do
-func (c *SenderSendCall) Do(f func(*packetBuffer, uint16, protocol.ECN)) *SenderSendCall {
+func (c *SenderSendCall) Do(f func(*packetBuffer, uint16, protocol.ECN, uint8)) *SenderSendCall {
 	c.Call = c.Call.Do(f)
 	return c
 }
 
 // DoAndReturn rewrite *gomock.Call.DoAndReturn
-func (c *SenderSendCall) DoAndReturn(f func(*packetBuffer, uint16, protocol.ECN)) *SenderSendCall {
+func (c *SenderSendCall) DoAndReturn(f func(*packetBuffer, uint16, protocol.ECN, uint8)) *SenderSendCall {
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
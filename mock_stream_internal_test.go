@@ -10,6 +10,7 @@ package quic
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 	time "time"
 
@@ -153,6 +154,44 @@ func (c *StreamICloseCall) DoAndReturn(f func() error) *StreamICloseCall {
 	return c
 }
 
+// Consume mocks base method.
+func (m *MockStreamI) Consume(arg0 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockStreamIMockRecorder) Consume(arg0 any) *StreamIConsumeCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockStreamI)(nil).Consume), arg0)
+	return &StreamIConsumeCall{Call: call}
+}
+
+// StreamIConsumeCall wrap *gomock.Call
+type StreamIConsumeCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamIConsumeCall) Return(arg0 error) *StreamIConsumeCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamIConsumeCall) Do(f func(int) error) *StreamIConsumeCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamIConsumeCall) DoAndReturn(f func(int) error) *StreamIConsumeCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Context mocks base method.
 func (m *MockStreamI) Context() context.Context {
 	m.ctrl.T.Helper()
@@ -191,6 +230,45 @@ func (c *StreamIContextCall) DoAndReturn(f func() context.Context) *StreamIConte
 	return c
 }
 
+// Peek mocks base method.
+func (m *MockStreamI) Peek() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockStreamIMockRecorder) Peek() *StreamIPeekCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockStreamI)(nil).Peek))
+	return &StreamIPeekCall{Call: call}
+}
+
+// StreamIPeekCall wrap *gomock.Call
+type StreamIPeekCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamIPeekCall) Return(arg0 []byte, arg1 error) *StreamIPeekCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamIPeekCall) Do(f func() ([]byte, error)) *StreamIPeekCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamIPeekCall) DoAndReturn(f func() ([]byte, error)) *StreamIPeekCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Read mocks base method.
 func (m *MockStreamI) Read(arg0 []byte) (int, error) {
 	m.ctrl.T.Helper()
@@ -230,6 +308,83 @@ func (c *StreamIReadCall) DoAndReturn(f func([]byte) (int, error)) *StreamIReadC
 	return c
 }
 
+// ReadFrom mocks base method.
+func (m *MockStreamI) ReadFrom(arg0 io.Reader) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadFrom", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadFrom indicates an expected call of ReadFrom.
+func (mr *MockStreamIMockRecorder) ReadFrom(arg0 any) *StreamIReadFromCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFrom", reflect.TypeOf((*MockStreamI)(nil).ReadFrom), arg0)
+	return &StreamIReadFromCall{Call: call}
+}
+
+// StreamIReadFromCall wrap *gomock.Call
+type StreamIReadFromCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamIReadFromCall) Return(arg0 int64, arg1 error) *StreamIReadFromCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamIReadFromCall) Do(f func(io.Reader) (int64, error)) *StreamIReadFromCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamIReadFromCall) DoAndReturn(f func(io.Reader) (int64, error)) *StreamIReadFromCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// SetDSCP mocks base method.
+func (m *MockStreamI) SetDSCP(arg0 uint8) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDSCP", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDSCP indicates an expected call of SetDSCP.
+func (mr *MockStreamIMockRecorder) SetDSCP(arg0 any) *StreamISetDSCPCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDSCP", reflect.TypeOf((*MockStreamI)(nil).SetDSCP), arg0)
+	return &StreamISetDSCPCall{Call: call}
+}
+
+// StreamISetDSCPCall wrap *gomock.Call
+type StreamISetDSCPCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamISetDSCPCall) Return(arg0 error) *StreamISetDSCPCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamISetDSCPCall) Do(f func(uint8) error) *StreamISetDSCPCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamISetDSCPCall) DoAndReturn(f func(uint8) error) *StreamISetDSCPCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // SetDeadline mocks base method.
 func (m *MockStreamI) SetDeadline(arg0 time.Time) error {
 	m.ctrl.T.Helper()
@@ -268,6 +423,44 @@ func (c *StreamISetDeadlineCall) DoAndReturn(f func(time.Time) error) *StreamISe
 	return c
 }
 
+// SetPriority mocks base method.
+func (m *MockStreamI) SetPriority(arg0 StreamPriority) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPriority", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPriority indicates an expected call of SetPriority.
+func (mr *MockStreamIMockRecorder) SetPriority(arg0 any) *StreamISetPriorityCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPriority", reflect.TypeOf((*MockStreamI)(nil).SetPriority), arg0)
+	return &StreamISetPriorityCall{Call: call}
+}
+
+// StreamISetPriorityCall wrap *gomock.Call
+type StreamISetPriorityCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamISetPriorityCall) Return(arg0 error) *StreamISetPriorityCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamISetPriorityCall) Do(f func(StreamPriority) error) *StreamISetPriorityCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamISetPriorityCall) DoAndReturn(f func(StreamPriority) error) *StreamISetPriorityCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // SetReadDeadline mocks base method.
 func (m *MockStreamI) SetReadDeadline(arg0 time.Time) error {
 	m.ctrl.T.Helper()
@@ -421,6 +614,45 @@ func (c *StreamIWriteCall) DoAndReturn(f func([]byte) (int, error)) *StreamIWrit
 	return c
 }
 
+// WriteBuffer mocks base method.
+func (m *MockStreamI) WriteBuffer(arg0 []byte, arg1 func()) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteBuffer", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteBuffer indicates an expected call of WriteBuffer.
+func (mr *MockStreamIMockRecorder) WriteBuffer(arg0, arg1 any) *StreamIWriteBufferCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteBuffer", reflect.TypeOf((*MockStreamI)(nil).WriteBuffer), arg0, arg1)
+	return &StreamIWriteBufferCall{Call: call}
+}
+
+// StreamIWriteBufferCall wrap *gomock.Call
+type StreamIWriteBufferCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamIWriteBufferCall) Return(arg0 int, arg1 error) *StreamIWriteBufferCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamIWriteBufferCall) Do(f func([]byte, func()) (int, error)) *StreamIWriteBufferCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamIWriteBufferCall) DoAndReturn(f func([]byte, func()) (int, error)) *StreamIWriteBufferCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // closeForShutdown mocks base method.
 func (m *MockStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.T.Helper()
@@ -457,6 +689,44 @@ func (c *StreamIcloseForShutdownCall) DoAndReturn(f func(error)) *StreamIcloseFo
 	return c
 }
 
+// dscpClass mocks base method.
+func (m *MockStreamI) dscpClass() dscpClass {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "dscpClass")
+	ret0, _ := ret[0].(dscpClass)
+	return ret0
+}
+
+// dscpClass indicates an expected call of dscpClass.
+func (mr *MockStreamIMockRecorder) dscpClass() *StreamIdscpClassCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "dscpClass", reflect.TypeOf((*MockStreamI)(nil).dscpClass))
+	return &StreamIdscpClassCall{Call: call}
+}
+
+// StreamIdscpClassCall wrap *gomock.Call
+type StreamIdscpClassCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamIdscpClassCall) Return(arg0 dscpClass) *StreamIdscpClassCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamIdscpClassCall) Do(f func() dscpClass) *StreamIdscpClassCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamIdscpClassCall) DoAndReturn(f func() dscpClass) *StreamIdscpClassCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // getWindowUpdate mocks base method.
 func (m *MockStreamI) getWindowUpdate() protocol.ByteCount {
 	m.ctrl.T.Helper()
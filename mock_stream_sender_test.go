@@ -111,6 +111,42 @@ func (c *StreamSenderonStreamCompletedCall) DoAndReturn(f func(protocol.StreamID
 	return c
 }
 
+// onStreamPriorityChanged mocks base method.
+func (m *MockStreamSender) onStreamPriorityChanged(arg0 protocol.StreamID, arg1 streamPriority) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "onStreamPriorityChanged", arg0, arg1)
+}
+
+// onStreamPriorityChanged indicates an expected call of onStreamPriorityChanged.
+func (mr *MockStreamSenderMockRecorder) onStreamPriorityChanged(arg0, arg1 any) *StreamSenderonStreamPriorityChangedCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "onStreamPriorityChanged", reflect.TypeOf((*MockStreamSender)(nil).onStreamPriorityChanged), arg0, arg1)
+	return &StreamSenderonStreamPriorityChangedCall{Call: call}
+}
+
+// StreamSenderonStreamPriorityChangedCall wrap *gomock.Call
+type StreamSenderonStreamPriorityChangedCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *StreamSenderonStreamPriorityChangedCall) Return() *StreamSenderonStreamPriorityChangedCall {
+	c.Call = c.Call.Return()
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *StreamSenderonStreamPriorityChangedCall) Do(f func(protocol.StreamID, streamPriority)) *StreamSenderonStreamPriorityChangedCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *StreamSenderonStreamPriorityChangedCall) DoAndReturn(f func(protocol.StreamID, streamPriority)) *StreamSenderonStreamPriorityChangedCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // queueControlFrame mocks base method.
 func (m *MockStreamSender) queueControlFrame(arg0 wire.Frame) {
 	m.ctrl.T.Helper()
@@ -8,6 +8,7 @@ import (
 	"github.com/nxenon/xquic-go/internal/protocol"
 	"github.com/nxenon/xquic-go/internal/utils"
 	"github.com/nxenon/xquic-go/internal/wire"
+	"github.com/nxenon/xquic-go/logging"
 )
 
 type mtuDiscoverer interface {
@@ -17,6 +18,10 @@ type mtuDiscoverer interface {
 	ShouldSendProbe(now time.Time) bool
 	CurrentSize() protocol.ByteCount
 	GetPing() (ping ackhandler.Frame, datagramSize protocol.ByteCount)
+
+	// The MTU discoverer is informed about acknowledged and lost 1-RTT packets,
+	// which allows it to detect Path MTU black holes.
+	ackhandler.PathMTUTracker
 }
 
 const (
@@ -25,6 +30,12 @@ const (
 	maxMTUDiff = 20
 	// send a probe packet every mtuProbeDelay RTTs
 	mtuProbeDelay = 5
+	// Once MTU discovery has completed, a larger MTU is probed for after mtuRaiseInterval.
+	// This is the PMTU_RAISE_TIMER of RFC 8899.
+	mtuRaiseInterval = 10 * time.Minute
+	// If this many packets larger than the fallback size are lost, without any later packet of that size
+	// being acknowledged, we assume that the path doesn't support the current MTU any longer (a black hole).
+	maxLostFullSizePackets = 3
 )
 
 func getMaxPacketSize(addr net.Addr) protocol.ByteCount {
@@ -41,24 +52,63 @@ func getMaxPacketSize(addr net.Addr) protocol.ByteCount {
 	return maxSize
 }
 
+// getInitialPacketSize returns the size of the packets sent before Path MTU Discovery has discovered a larger MTU.
+func getInitialPacketSize(addr net.Addr, config *Config) protocol.ByteCount {
+	if config.InitialPacketSize > 0 {
+		return protocol.ByteCount(config.InitialPacketSize)
+	}
+	maxSize := getMaxPacketSize(addr)
+	if config.MaxPacketSize > 0 {
+		maxSize = min(maxSize, protocol.ByteCount(config.MaxPacketSize))
+	}
+	return maxSize
+}
+
+// The mtuFinder implements the state machine of RFC 8899 (DPLPMTUD):
+//   - SEARCHING: The MTU is increased using a binary search, by sending probe packets.
+//   - SEARCH_COMPLETE: Once the search is close enough to the maximum, probing stops.
+//     Every mtuRaiseInterval, the search is restarted, since the Path MTU might have increased.
+//   - PROBE_BASE: When full-size packets are repeatedly lost, we assume that the path has become a black hole
+//     for packets of that size, and fall back to the base size (the initial packet size).
+//     The search is then restarted from the base size.
+//   - ERROR: When packets of the base size are repeatedly lost, we fall back to the minimum size of a QUIC packet.
 type mtuFinder struct {
 	lastProbeTime time.Time
-	mtuIncreased  func(protocol.ByteCount)
+	mtuChanged    func(protocol.ByteCount)
 
 	rttStats *utils.RTTStats
+	clock    utils.Clock
 	inFlight protocol.ByteCount // the size of the probe packet currently in flight. InvalidByteCount if none is in flight
 	current  protocol.ByteCount
-	max      protocol.ByteCount // the maximum value, as advertised by the peer (or our maximum size buffer)
+	max      protocol.ByteCount // the upper bound of the current search
+	limit    protocol.ByteCount // the maximum value, as advertised by the peer (or our maximum size buffer)
+	base     protocol.ByteCount // the size that we fall back to when a black hole is detected
+
+	// black hole detection
+	largestAckedFullSize protocol.PacketNumber // the largest acknowledged packet that was larger than the fallback size
+	numLostFullSize      int                   // the number of lost packets larger than the fallback size sent after largestAckedFullSize
+
+	tracer *logging.ConnectionTracer
 }
 
 var _ mtuDiscoverer = &mtuFinder{}
 
-func newMTUDiscoverer(rttStats *utils.RTTStats, start protocol.ByteCount, mtuIncreased func(protocol.ByteCount)) *mtuFinder {
+func newMTUDiscoverer(
+	rttStats *utils.RTTStats,
+	clock utils.Clock,
+	start protocol.ByteCount,
+	mtuChanged func(protocol.ByteCount),
+	tracer *logging.ConnectionTracer,
+) *mtuFinder {
 	return &mtuFinder{
-		inFlight:     protocol.InvalidByteCount,
-		current:      start,
-		rttStats:     rttStats,
-		mtuIncreased: mtuIncreased,
+		inFlight:             protocol.InvalidByteCount,
+		current:              start,
+		base:                 start,
+		largestAckedFullSize: protocol.InvalidPacketNumber,
+		rttStats:             rttStats,
+		clock:                clock,
+		mtuChanged:           mtuChanged,
+		tracer:               tracer,
 	}
 }
 
@@ -67,23 +117,34 @@ func (f *mtuFinder) done() bool {
 }
 
 func (f *mtuFinder) Start(maxPacketSize protocol.ByteCount) {
-	f.lastProbeTime = time.Now() // makes sure the first probe packet is not sent immediately
+	f.lastProbeTime = f.clock.Now() // makes sure the first probe packet is not sent immediately
 	f.max = maxPacketSize
+	f.limit = maxPacketSize
 }
 
 func (f *mtuFinder) ShouldSendProbe(now time.Time) bool {
 	if f.max == 0 || f.lastProbeTime.IsZero() {
 		return false
 	}
-	if f.inFlight != protocol.InvalidByteCount || f.done() {
+	if f.inFlight != protocol.InvalidByteCount {
 		return false
 	}
+	if f.done() {
+		// Restart the search if the Path MTU might have increased.
+		if f.limit-f.current <= maxMTUDiff+1 {
+			return false
+		}
+		return !now.Before(f.lastProbeTime.Add(mtuRaiseInterval))
+	}
 	return !now.Before(f.lastProbeTime.Add(mtuProbeDelay * f.rttStats.SmoothedRTT()))
 }
 
 func (f *mtuFinder) GetPing() (ackhandler.Frame, protocol.ByteCount) {
+	if f.done() {
+		f.max = f.limit
+	}
 	size := (f.max + f.current) / 2
-	f.lastProbeTime = time.Now()
+	f.lastProbeTime = f.clock.Now()
 	f.inFlight = size
 	return ackhandler.Frame{
 		Frame:   &wire.PingFrame{},
@@ -95,6 +156,55 @@ func (f *mtuFinder) CurrentSize() protocol.ByteCount {
 	return f.current
 }
 
+// fallbackSize is the size that we fall back to when a black hole is detected.
+func (f *mtuFinder) fallbackSize() protocol.ByteCount {
+	if f.current > f.base {
+		return f.base
+	}
+	return min(f.current, protocol.MinInitialPacketSize)
+}
+
+// isFullSize says if a packet counts for black hole detection.
+// Packets larger than the current size were sent before the MTU was decreased.
+func (f *mtuFinder) isFullSize(size protocol.ByteCount) bool {
+	return size > f.fallbackSize() && size <= f.current
+}
+
+func (f *mtuFinder) OnPacketAcked(pn protocol.PacketNumber, size protocol.ByteCount) {
+	if f.max == 0 || !f.isFullSize(size) {
+		return
+	}
+	if pn > f.largestAckedFullSize {
+		f.largestAckedFullSize = pn
+		f.numLostFullSize = 0
+	}
+}
+
+func (f *mtuFinder) OnPacketLost(pn protocol.PacketNumber, size protocol.ByteCount) {
+	if f.max == 0 || !f.isFullSize(size) {
+		return
+	}
+	// A larger packet sent after this packet was acknowledged.
+	// The packet was lost for a reason unrelated to its size.
+	if pn < f.largestAckedFullSize {
+		return
+	}
+	f.numLostFullSize++
+	if f.numLostFullSize < maxLostFullSizePackets {
+		return
+	}
+	f.current = f.fallbackSize()
+	f.numLostFullSize = 0
+	f.largestAckedFullSize = protocol.InvalidPacketNumber
+	// Restart the search from the fallback size.
+	f.max = f.limit
+	f.lastProbeTime = f.clock.Now()
+	f.mtuChanged(f.current)
+	if f.tracer != nil && f.tracer.UpdatedMTU != nil {
+		f.tracer.UpdatedMTU(f.current, false)
+	}
+}
+
 type mtuFinderAckHandler mtuFinder
 
 var _ ackhandler.FrameHandler = &mtuFinderAckHandler{}
@@ -105,8 +215,15 @@ func (h *mtuFinderAckHandler) OnAcked(wire.Frame) {
 		panic("OnAcked callback called although there's no MTU probe packet in flight")
 	}
 	h.inFlight = protocol.InvalidByteCount
+	// The MTU might have been decreased while the probe packet was in flight.
+	if size <= h.current {
+		return
+	}
 	h.current = size
-	h.mtuIncreased(size)
+	h.mtuChanged(size)
+	if h.tracer != nil && h.tracer.UpdatedMTU != nil {
+		h.tracer.UpdatedMTU(size, (*mtuFinder)(h).done())
+	}
 }
 
 func (h *mtuFinderAckHandler) OnLost(wire.Frame) {
@@ -4,11 +4,14 @@ import (
 	"math/rand"
 	"time"
 
+	mocklogging "github.com/nxenon/xquic-go/internal/mocks/logging"
 	"github.com/nxenon/xquic-go/internal/protocol"
 	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/logging"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
 )
 
 var _ = Describe("MTU Discoverer", func() {
@@ -29,7 +32,7 @@ var _ = Describe("MTU Discoverer", func() {
 		rttStats = &utils.RTTStats{}
 		rttStats.SetInitialRTT(rtt)
 		Expect(rttStats.SmoothedRTT()).To(Equal(rtt))
-		d = newMTUDiscoverer(rttStats, startMTU, func(s protocol.ByteCount) { discoveredMTU = s })
+		d = newMTUDiscoverer(rttStats, utils.DefaultClock{}, startMTU, func(s protocol.ByteCount) { discoveredMTU = s }, nil)
 		d.Start(maxMTU)
 		now = time.Now()
 	})
@@ -78,7 +81,7 @@ var _ = Describe("MTU Discoverer", func() {
 	})
 
 	It("doesn't do discovery before being started", func() {
-		d := newMTUDiscoverer(rttStats, startMTU, func(s protocol.ByteCount) {})
+		d := newMTUDiscoverer(rttStats, utils.DefaultClock{}, startMTU, func(s protocol.ByteCount) {}, nil)
 		for i := 0; i < 5; i++ {
 			Expect(d.ShouldSendProbe(time.Now())).To(BeFalse())
 		}
@@ -90,7 +93,7 @@ var _ = Describe("MTU Discoverer", func() {
 		for i := 0; i < rep; i++ {
 			maxMTU := protocol.ByteCount(rand.Intn(int(3000-startMTU))) + startMTU + 1
 			currentMTU := startMTU
-			d := newMTUDiscoverer(rttStats, startMTU, func(s protocol.ByteCount) { currentMTU = s })
+			d := newMTUDiscoverer(rttStats, utils.DefaultClock{}, startMTU, func(s protocol.ByteCount) { currentMTU = s }, nil)
 			d.Start(maxMTU)
 			now := time.Now()
 			realMTU := protocol.ByteCount(rand.Intn(int(maxMTU-startMTU))) + startMTU
@@ -116,4 +119,132 @@ var _ = Describe("MTU Discoverer", func() {
 		}
 		Expect(maxDiff).To(BeEquivalentTo(maxMTUDiff))
 	})
+
+	It("traces MTU updates", func() {
+		tr, tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+		d := newMTUDiscoverer(rttStats, utils.DefaultClock{}, startMTU, func(s protocol.ByteCount) {}, tr)
+		d.Start(maxMTU)
+		ping, size := d.GetPing()
+		tracer.EXPECT().UpdatedMTU(size, false)
+		ping.Handler.OnAcked(ping.Frame)
+		t := now.Add(5 * rtt)
+		for d.ShouldSendProbe(t) {
+			ping, size := d.GetPing()
+			tracer.EXPECT().UpdatedMTU(size, gomock.Any()).Do(func(_ logging.ByteCount, done bool) {
+				Expect(done).To(Equal(d.done()))
+			})
+			ping.Handler.OnAcked(ping.Frame)
+			t = t.Add(5 * rtt)
+		}
+	})
+
+	Context("black hole detection", func() {
+		var tracer *mocklogging.MockConnectionTracer
+
+		BeforeEach(func() {
+			var tr *logging.ConnectionTracer
+			tr, tracer = mocklogging.NewMockConnectionTracer(mockCtrl)
+			d = newMTUDiscoverer(rttStats, utils.DefaultClock{}, startMTU, func(s protocol.ByteCount) { discoveredMTU = s }, tr)
+			d.Start(maxMTU)
+			ping, size := d.GetPing()
+			Expect(size).To(Equal(protocol.ByteCount(1500)))
+			tracer.EXPECT().UpdatedMTU(protocol.ByteCount(1500), false)
+			ping.Handler.OnAcked(ping.Frame)
+			Expect(d.CurrentSize()).To(Equal(protocol.ByteCount(1500)))
+		})
+
+		It("falls back to the initial size when full-size packets are lost", func() {
+			d.OnPacketAcked(10, 1500)
+			d.OnPacketLost(11, 1500)
+			d.OnPacketLost(12, 1500)
+			Expect(d.CurrentSize()).To(Equal(protocol.ByteCount(1500)))
+			tracer.EXPECT().UpdatedMTU(startMTU, false)
+			d.OnPacketLost(13, 1500)
+			Expect(d.CurrentSize()).To(Equal(startMTU))
+			Expect(discoveredMTU).To(Equal(startMTU))
+		})
+
+		It("resets the counter when a full-size packet is acknowledged", func() {
+			d.OnPacketLost(10, 1500)
+			d.OnPacketLost(11, 1500)
+			d.OnPacketAcked(12, 1500)
+			d.OnPacketLost(13, 1500)
+			d.OnPacketLost(14, 1500)
+			Expect(d.CurrentSize()).To(Equal(protocol.ByteCount(1500)))
+		})
+
+		It("ignores lost packets sent before an acknowledged full-size packet", func() {
+			d.OnPacketAcked(20, 1500)
+			for pn := protocol.PacketNumber(10); pn < 20; pn++ {
+				d.OnPacketLost(pn, 1500)
+			}
+			Expect(d.CurrentSize()).To(Equal(protocol.ByteCount(1500)))
+		})
+
+		It("ignores small packets", func() {
+			for pn := protocol.PacketNumber(10); pn < 20; pn++ {
+				d.OnPacketLost(pn, startMTU)
+			}
+			Expect(d.CurrentSize()).To(Equal(protocol.ByteCount(1500)))
+		})
+
+		It("ignores packets sent before the MTU was decreased", func() {
+			tracer.EXPECT().UpdatedMTU(startMTU, false)
+			for pn := protocol.PacketNumber(10); pn < 13; pn++ {
+				d.OnPacketLost(pn, 1500)
+			}
+			Expect(d.CurrentSize()).To(Equal(startMTU))
+			discoveredMTU = 0
+			for pn := protocol.PacketNumber(13); pn < 20; pn++ {
+				d.OnPacketLost(pn, 1500)
+			}
+			Expect(discoveredMTU).To(BeZero())
+		})
+
+		It("restarts the search after falling back", func() {
+			tracer.EXPECT().UpdatedMTU(startMTU, false)
+			for pn := protocol.PacketNumber(10); pn < 13; pn++ {
+				d.OnPacketLost(pn, 1500)
+			}
+			Expect(d.ShouldSendProbe(time.Now())).To(BeFalse())
+			Expect(d.ShouldSendProbe(time.Now().Add(mtuProbeDelay * rtt))).To(BeTrue())
+			_, size := d.GetPing()
+			Expect(size).To(Equal(protocol.ByteCount(1500)))
+		})
+
+		It("falls back to the minimum packet size when packets of the initial size are lost", func() {
+			d := newMTUDiscoverer(rttStats, utils.DefaultClock{}, protocol.InitialPacketSizeIPv4, func(s protocol.ByteCount) { discoveredMTU = s }, nil)
+			d.Start(maxMTU)
+			for pn := protocol.PacketNumber(10); pn < 13; pn++ {
+				d.OnPacketLost(pn, protocol.InitialPacketSizeIPv4)
+			}
+			Expect(d.CurrentSize()).To(BeEquivalentTo(protocol.MinInitialPacketSize))
+			Expect(discoveredMTU).To(BeEquivalentTo(protocol.MinInitialPacketSize))
+			// we can't go any lower
+			for pn := protocol.PacketNumber(13); pn < 20; pn++ {
+				d.OnPacketLost(pn, protocol.MinInitialPacketSize)
+			}
+			Expect(d.CurrentSize()).To(BeEquivalentTo(protocol.MinInitialPacketSize))
+		})
+	})
+
+	It("probes for a larger MTU after the search completes", func() {
+		t := now.Add(5 * rtt)
+		for d.ShouldSendProbe(t) {
+			ping, size := d.GetPing()
+			if size > 1500 {
+				ping.Handler.OnLost(ping.Frame)
+			} else {
+				ping.Handler.OnAcked(ping.Frame)
+			}
+			t = t.Add(5 * rtt)
+		}
+		Expect(d.CurrentSize()).To(BeNumerically(">", 1500-maxMTUDiff))
+		Expect(d.ShouldSendProbe(t.Add(mtuRaiseInterval / 2))).To(BeFalse())
+		Expect(d.ShouldSendProbe(t.Add(mtuRaiseInterval))).To(BeTrue())
+		ping, size := d.GetPing()
+		Expect(size).To(Equal((d.CurrentSize() + maxMTU) / 2))
+		ping.Handler.OnAcked(ping.Frame)
+		Expect(d.CurrentSize()).To(Equal(size))
+	})
 })
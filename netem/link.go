@@ -0,0 +1,320 @@
+package netem
+
+import (
+	"container/heap"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nxenon/xquic-go/internal/utils"
+)
+
+// DefaultQueueSize is the default size of the bottleneck queue, in bytes.
+const DefaultQueueSize = 128 << 10
+
+// LinkConfig configures one direction of the emulated link.
+// Packets pass through the following stages, in this order:
+//  1. loss, duplication and corruption
+//  2. the bottleneck queue, if a bandwidth is configured
+//  3. the propagation delay, jitter and reordering
+type LinkConfig struct {
+	// Delay is the one-way propagation delay.
+	Delay time.Duration
+	// Jitter is added to the propagation delay of every packet.
+	// The jitter is chosen uniformly at random from [-Jitter, Jitter].
+	// Note that jitter reorders packets, if it's larger than the time between two packets.
+	Jitter time.Duration
+	// ReorderProbability is the probability that a packet skips the propagation delay,
+	// and therefore overtakes packets that were sent before it.
+	// This has no effect if no Delay is configured.
+	ReorderProbability float64
+
+	// Bandwidth is the bandwidth of the bottleneck, in bits per second.
+	// If 0, the bandwidth is unlimited, and packets don't queue.
+	Bandwidth uint64
+	// QueueSize is the size of the bottleneck queue, in bytes.
+	// If 0, DefaultQueueSize is used.
+	QueueSize int
+	// QueueDiscipline is the queue management algorithm of the bottleneck queue.
+	QueueDiscipline QueueDiscipline
+	// CoDelTarget and CoDelInterval are the CoDel parameters.
+	// If 0, DefaultCoDelTarget and DefaultCoDelInterval are used.
+	CoDelTarget   time.Duration
+	CoDelInterval time.Duration
+
+	// Loss determines which packets are lost.
+	// If nil, no packets are lost.
+	Loss LossModel
+	// DuplicateProbability is the probability that a packet is duplicated.
+	DuplicateProbability float64
+	// CorruptProbability is the probability that a single bit of a packet is flipped.
+	CorruptProbability float64
+}
+
+func (c *LinkConfig) queueSize() int {
+	if c.QueueSize == 0 {
+		return DefaultQueueSize
+	}
+	return c.QueueSize
+}
+
+// transmissionTime is the time it takes to serialize a packet of the given size onto the link.
+func (c *LinkConfig) transmissionTime(size int) time.Duration {
+	return time.Duration(uint64(size) * 8 * uint64(time.Second) / c.Bandwidth)
+}
+
+// LinkStats are statistics for one direction of the emulated link.
+type LinkStats struct {
+	// Packets is the number of packets sent onto the link.
+	Packets uint64
+	// Lost is the number of packets dropped by the LossModel.
+	Lost uint64
+	// QueueDropped is the number of packets dropped by the bottleneck queue,
+	// either because the queue was full, or by CoDel.
+	QueueDropped uint64
+	// Duplicated and Corrupted are the number of packets that were duplicated and corrupted, respectively.
+	Duplicated uint64
+	Corrupted  uint64
+	// Delivered is the number of packets that left the link, including duplicates.
+	Delivered uint64
+}
+
+// delayLine is a priority queue of packets, ordered by their delivery time.
+type delayLine []*packet
+
+func (d delayLine) Len() int { return len(d) }
+func (d delayLine) Less(i, j int) bool {
+	if d[i].deliverAt.Equal(d[j].deliverAt) {
+		return d[i].seq < d[j].seq
+	}
+	return d[i].deliverAt.Before(d[j].deliverAt)
+}
+func (d delayLine) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d *delayLine) Push(x any)   { *d = append(*d, x.(*packet)) }
+func (d *delayLine) Pop() any {
+	old := *d
+	n := len(old)
+	p := old[n-1]
+	old[n-1] = nil
+	*d = old[:n-1]
+	return p
+}
+
+// A link emulates one direction of a network path.
+// It is shared by all connections that use this direction of the proxy.
+type link struct {
+	mutex sync.Mutex
+
+	config LinkConfig
+	rand   *rand.Rand
+	stats  LinkStats
+
+	queue       packetQueue
+	codel       codel
+	queueSignal chan struct{}
+
+	delayed     delayLine
+	seq         uint64
+	delaySignal chan struct{}
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+	done      sync.WaitGroup
+}
+
+func newLink(config LinkConfig, seed int64) *link {
+	l := &link{
+		rand:        rand.New(rand.NewSource(seed)),
+		queueSignal: make(chan struct{}, 1),
+		delaySignal: make(chan struct{}, 1),
+		closeChan:   make(chan struct{}),
+	}
+	l.setConfig(config)
+	l.done.Add(2)
+	go l.runBottleneck()
+	go l.runDelayLine()
+	return l
+}
+
+func (l *link) setConfig(config LinkConfig) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.config = config
+	l.codel.target = config.CoDelTarget
+	if l.codel.target == 0 {
+		l.codel.target = DefaultCoDelTarget
+	}
+	l.codel.interval = config.CoDelInterval
+	if l.codel.interval == 0 {
+		l.codel.interval = DefaultCoDelInterval
+	}
+}
+
+func (l *link) getStats() LinkStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.stats
+}
+
+// Send sends a packet onto the link.
+// When the packet leaves the link, deliver is called.
+// The link takes ownership of data.
+func (l *link) Send(data []byte, deliver func([]byte)) {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.stats.Packets++
+	if l.config.Loss != nil && l.config.Loss.Lose(l.rand) {
+		l.stats.Lost++
+		return
+	}
+	copies := 1
+	if l.config.DuplicateProbability > 0 && l.rand.Float64() < l.config.DuplicateProbability {
+		l.stats.Duplicated++
+		copies = 2
+	}
+	for i := 0; i < copies; i++ {
+		b := data
+		if i > 0 {
+			b = make([]byte, len(data))
+			copy(b, data)
+		}
+		if len(b) > 0 && l.config.CorruptProbability > 0 && l.rand.Float64() < l.config.CorruptProbability {
+			l.stats.Corrupted++
+			if i == 0 {
+				// don't modify the copy that's sent as a duplicate
+				b = make([]byte, len(data))
+				copy(b, data)
+			}
+			bit := l.rand.Intn(len(b) * 8)
+			b[bit/8] ^= 1 << (bit % 8)
+		}
+		p := &packet{data: b, deliver: deliver, enqueued: now}
+		if l.config.Bandwidth == 0 && l.queue.Len() == 0 {
+			l.scheduleLocked(now, p)
+			continue
+		}
+		if l.queue.bytes+len(b) > l.config.queueSize() {
+			l.stats.QueueDropped++
+			continue
+		}
+		l.queue.push(p)
+		select {
+		case l.queueSignal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (l *link) dequeueLocked(now time.Time) *packet {
+	if l.config.QueueDiscipline == CoDel {
+		return l.codel.dequeue(now, &l.queue, func(*packet) { l.stats.QueueDropped++ })
+	}
+	return l.queue.pop()
+}
+
+// runBottleneck dequeues packets from the bottleneck queue, and serializes them onto the link.
+func (l *link) runBottleneck() {
+	defer l.done.Done()
+
+	timer := utils.NewTimer()
+	defer timer.Stop()
+	for {
+		l.mutex.Lock()
+		now := time.Now()
+		p := l.dequeueLocked(now)
+		var txTime time.Duration
+		if p != nil && l.config.Bandwidth > 0 {
+			txTime = l.config.transmissionTime(len(p.data))
+		}
+		l.mutex.Unlock()
+
+		if p == nil {
+			select {
+			case <-l.closeChan:
+				return
+			case <-l.queueSignal:
+				continue
+			}
+		}
+		if txTime > 0 {
+			timer.Reset(now.Add(txTime))
+			select {
+			case <-l.closeChan:
+				return
+			case <-timer.Chan():
+				timer.SetRead()
+			}
+		}
+		l.mutex.Lock()
+		l.scheduleLocked(time.Now(), p)
+		l.mutex.Unlock()
+	}
+}
+
+// scheduleLocked puts a packet into the delay line.
+func (l *link) scheduleLocked(now time.Time, p *packet) {
+	delay := l.config.Delay
+	if delay > 0 && l.config.ReorderProbability > 0 && l.rand.Float64() < l.config.ReorderProbability {
+		delay = 0
+	}
+	if l.config.Jitter > 0 {
+		delay += time.Duration(l.rand.Int63n(2*int64(l.config.Jitter)+1)) - l.config.Jitter
+		if delay < 0 {
+			delay = 0
+		}
+	}
+	p.deliverAt = now.Add(delay)
+	p.seq = l.seq
+	l.seq++
+	heap.Push(&l.delayed, p)
+	select {
+	case l.delaySignal <- struct{}{}:
+	default:
+	}
+}
+
+// runDelayLine delivers packets once their propagation delay has passed.
+func (l *link) runDelayLine() {
+	defer l.done.Done()
+
+	timer := utils.NewTimer()
+	defer timer.Stop()
+	for {
+		l.mutex.Lock()
+		now := time.Now()
+		var deliver []*packet
+		for len(l.delayed) > 0 && !l.delayed[0].deliverAt.After(now) {
+			deliver = append(deliver, heap.Pop(&l.delayed).(*packet))
+		}
+		l.stats.Delivered += uint64(len(deliver))
+		var next time.Time
+		if len(l.delayed) > 0 {
+			next = l.delayed[0].deliverAt
+		}
+		l.mutex.Unlock()
+
+		for _, p := range deliver {
+			p.deliver(p.data)
+		}
+
+		if !next.IsZero() {
+			timer.Reset(next)
+		}
+		select {
+		case <-l.closeChan:
+			return
+		case <-l.delaySignal:
+		case <-timer.Chan():
+			timer.SetRead()
+		}
+	}
+}
+
+func (l *link) Close() {
+	l.closeOnce.Do(func() { close(l.closeChan) })
+	l.done.Wait()
+}
@@ -0,0 +1,176 @@
+package netem
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type delivered struct {
+	data []byte
+	time time.Time
+}
+
+type receiver struct {
+	mutex   sync.Mutex
+	packets []delivered
+}
+
+func (r *receiver) deliver(b []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.packets = append(r.packets, delivered{data: b, time: time.Now()})
+}
+
+func (r *receiver) get() []delivered {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]delivered(nil), r.packets...)
+}
+
+func (r *receiver) waitFor(t *testing.T, num int) []delivered {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(r.get()) >= num }, 2*time.Second, time.Millisecond)
+	return r.get()
+}
+
+func TestLinkForwards(t *testing.T) {
+	l := newLink(LinkConfig{}, 1)
+	defer l.Close()
+
+	var r receiver
+	for i := 0; i < 10; i++ {
+		l.Send([]byte{byte(i)}, r.deliver)
+	}
+	packets := r.waitFor(t, 10)
+	for i, p := range packets {
+		require.Equal(t, []byte{byte(i)}, p.data)
+	}
+	require.Equal(t, LinkStats{Packets: 10, Delivered: 10}, l.getStats())
+}
+
+func TestLinkDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	l := newLink(LinkConfig{Delay: delay}, 1)
+	defer l.Close()
+
+	var r receiver
+	start := time.Now()
+	l.Send([]byte("foobar"), r.deliver)
+	packets := r.waitFor(t, 1)
+	require.GreaterOrEqual(t, packets[0].time.Sub(start), delay)
+}
+
+func TestLinkJitterAndReordering(t *testing.T) {
+	for _, config := range []LinkConfig{
+		{Delay: 10 * time.Millisecond, Jitter: 10 * time.Millisecond},
+		{Delay: 20 * time.Millisecond, ReorderProbability: 0.25},
+	} {
+		l := newLink(config, 1)
+
+		var r receiver
+		const num = 100
+		for i := 0; i < num; i++ {
+			l.Send([]byte{byte(i)}, r.deliver)
+			time.Sleep(100 * time.Microsecond)
+		}
+		packets := r.waitFor(t, num)
+		var reordered bool
+		for i := 1; i < len(packets); i++ {
+			if packets[i].data[0] < packets[i-1].data[0] {
+				reordered = true
+			}
+		}
+		require.True(t, reordered)
+		l.Close()
+	}
+}
+
+func TestLinkLoss(t *testing.T) {
+	l := newLink(LinkConfig{Loss: RandomLoss(1)}, 1)
+	defer l.Close()
+
+	var r receiver
+	for i := 0; i < 10; i++ {
+		l.Send([]byte{byte(i)}, r.deliver)
+	}
+	time.Sleep(10 * time.Millisecond)
+	require.Empty(t, r.get())
+	require.Equal(t, LinkStats{Packets: 10, Lost: 10}, l.getStats())
+}
+
+func TestLinkDuplication(t *testing.T) {
+	l := newLink(LinkConfig{DuplicateProbability: 1}, 1)
+	defer l.Close()
+
+	var r receiver
+	l.Send([]byte("foobar"), r.deliver)
+	packets := r.waitFor(t, 2)
+	require.Equal(t, []byte("foobar"), packets[0].data)
+	require.Equal(t, []byte("foobar"), packets[1].data)
+	require.Equal(t, LinkStats{Packets: 1, Duplicated: 1, Delivered: 2}, l.getStats())
+}
+
+func TestLinkCorruption(t *testing.T) {
+	l := newLink(LinkConfig{CorruptProbability: 1}, 1)
+	defer l.Close()
+
+	var r receiver
+	data := bytes.Repeat([]byte{0}, 100)
+	l.Send(data, r.deliver)
+	packets := r.waitFor(t, 1)
+	require.Equal(t, make([]byte, 100), data, "the original packet was modified")
+	require.Len(t, packets[0].data, 100)
+	var flipped int
+	for _, b := range packets[0].data {
+		for ; b != 0; b &= b - 1 {
+			flipped++
+		}
+	}
+	require.Equal(t, 1, flipped)
+}
+
+func TestLinkBandwidth(t *testing.T) {
+	// 1 Mbit/s: a 1250 byte packet takes 10ms to transmit
+	l := newLink(LinkConfig{Bandwidth: 1e6}, 1)
+	defer l.Close()
+
+	var r receiver
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		l.Send(make([]byte, 1250), r.deliver)
+	}
+	packets := r.waitFor(t, 5)
+	require.GreaterOrEqual(t, packets[4].time.Sub(start), 50*time.Millisecond)
+}
+
+func TestLinkDropTail(t *testing.T) {
+	l := newLink(LinkConfig{Bandwidth: 1e6, QueueSize: 5000}, 1)
+	defer l.Close()
+
+	var r receiver
+	for i := 0; i < 10; i++ {
+		l.Send(make([]byte, 1000), r.deliver)
+	}
+	// The first packet might already have been dequeued,
+	// so either 5 or 6 packets make it through the queue.
+	stats := l.getStats()
+	require.Equal(t, uint64(10), stats.Packets)
+	require.Contains(t, []uint64{4, 5}, stats.QueueDropped)
+	r.waitFor(t, int(10-stats.QueueDropped))
+}
+
+func TestLinkSetConfig(t *testing.T) {
+	l := newLink(LinkConfig{Loss: RandomLoss(1)}, 1)
+	defer l.Close()
+
+	var r receiver
+	l.Send([]byte("foo"), r.deliver)
+	l.setConfig(LinkConfig{})
+	l.Send([]byte("bar"), r.deliver)
+	packets := r.waitFor(t, 1)
+	require.Equal(t, []byte("bar"), packets[0].data)
+}
@@ -0,0 +1,57 @@
+package netem
+
+import "math/rand"
+
+// A LossModel decides which packets are lost.
+// A LossModel may be stateful. It is only used for a single direction,
+// and it is never called concurrently.
+type LossModel interface {
+	// Lose is called for every packet sent on the link.
+	Lose(r *rand.Rand) bool
+}
+
+// RandomLoss drops every packet independently with the given probability.
+type RandomLoss float64
+
+var _ LossModel = RandomLoss(0)
+
+// Lose implements the LossModel interface.
+func (p RandomLoss) Lose(r *rand.Rand) bool {
+	return p > 0 && r.Float64() < float64(p)
+}
+
+// GilbertElliott is a two-state Markov loss model that produces bursty loss.
+// The link is either in the good or in the bad state. Every packet is dropped with the loss probability of
+// the current state. After each packet, the link transitions between the states with the given probabilities.
+//
+// The mean length of a stay in the bad state is 1/BadToGood packets.
+// The classic Gilbert model is obtained by setting LossGood to 0 and LossBad to 1.
+type GilbertElliott struct {
+	// GoodToBad is the probability of transitioning from the good to the bad state (p).
+	GoodToBad float64
+	// BadToGood is the probability of transitioning from the bad to the good state (r).
+	BadToGood float64
+	// LossGood is the loss probability in the good state (1-k).
+	LossGood float64
+	// LossBad is the loss probability in the bad state (1-h).
+	LossBad float64
+
+	bad bool
+}
+
+var _ LossModel = &GilbertElliott{}
+
+// Lose implements the LossModel interface.
+func (g *GilbertElliott) Lose(r *rand.Rand) bool {
+	lossProb := g.LossGood
+	if g.bad {
+		lossProb = g.LossBad
+	}
+	lost := lossProb > 0 && r.Float64() < lossProb
+	if g.bad {
+		g.bad = r.Float64() >= g.BadToGood
+	} else {
+		g.bad = r.Float64() < g.GoodToBad
+	}
+	return lost
+}
@@ -0,0 +1,46 @@
+package netem
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomLoss(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	const num = 100000
+	var lost int
+	for i := 0; i < num; i++ {
+		if RandomLoss(0.1).Lose(r) {
+			lost++
+		}
+	}
+	require.InDelta(t, 0.1, float64(lost)/num, 0.01)
+
+	for i := 0; i < 100; i++ {
+		require.False(t, RandomLoss(0).Lose(r))
+	}
+}
+
+func TestGilbertElliott(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	g := &GilbertElliott{GoodToBad: 0.01, BadToGood: 0.25, LossBad: 1}
+	const num = 200000
+	var lost, bursts int
+	var prevLost bool
+	for i := 0; i < num; i++ {
+		l := g.Lose(r)
+		if l {
+			lost++
+			if !prevLost {
+				bursts++
+			}
+		}
+		prevLost = l
+	}
+	// The stationary probability of the bad state is p / (p + r).
+	require.InDelta(t, 0.01/(0.01+0.25), float64(lost)/num, 0.01)
+	// The mean burst length is 1/r.
+	require.InDelta(t, 1/0.25, float64(lost)/float64(bursts), 0.3)
+}
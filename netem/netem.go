@@ -0,0 +1,345 @@
+// Package netem emulates a network link between a QUIC client and a QUIC server.
+//
+// The Proxy is a UDP proxy that is put between the client and the server.
+// Packets sent in each direction pass through a configurable link, which can model
+// propagation delay, jitter, reordering, a bandwidth-limited bottleneck queue (drop-tail or CoDel),
+// random and bursty (Gilbert-Elliott) loss, duplication and corruption.
+// The Proxy can also simulate NAT rebindings and address changes, either on demand or scripted.
+//
+// The emulation runs in real time, so it is best suited for tests with moderate bandwidths and delays.
+package netem
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nxenon/xquic-go/internal/protocol"
+	"github.com/nxenon/xquic-go/internal/utils"
+)
+
+// Direction is the direction a packet is sent.
+type Direction int
+
+const (
+	// DirectionIncoming is the direction from the client to the server.
+	DirectionIncoming Direction = iota
+	// DirectionOutgoing is the direction from the server to the client.
+	DirectionOutgoing
+	// DirectionBoth is both incoming and outgoing
+	DirectionBoth
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionIncoming:
+		return "incoming"
+	case DirectionOutgoing:
+		return "outgoing"
+	case DirectionBoth:
+		return "both"
+	default:
+		return "unknown direction"
+	}
+}
+
+// Is says if one direction matches another direction.
+// For example, incoming matches both incoming and both, but not outgoing.
+func (d Direction) Is(dir Direction) bool {
+	if d == DirectionBoth || dir == DirectionBoth {
+		return true
+	}
+	return d == dir
+}
+
+// An Event is a scripted change of the emulated network.
+type Event struct {
+	// At is the time of the event, relative to the creation of the Proxy.
+	At time.Duration
+	// Link, if set, replaces the configuration of the link in the given Direction.
+	Direction Direction
+	Link      *LinkConfig
+	// Rebind simulates a NAT rebinding, see Proxy.Rebind.
+	Rebind bool
+	// RebindAddr is the local address used for the rebinding, see Proxy.Rebind.
+	RebindAddr string
+}
+
+// Opts are the Proxy options.
+type Opts struct {
+	// The address this proxy proxies packets to.
+	RemoteAddr string
+	// Incoming configures the link from the client to the server.
+	Incoming LinkConfig
+	// Outgoing configures the link from the server to the client.
+	Outgoing LinkConfig
+	// Seed seeds the random number generators.
+	// Using the same seed makes the decisions of the loss, duplication, corruption, jitter and reordering
+	// models reproducible (given the same sequence of packets).
+	// If 0, a random seed is used.
+	Seed int64
+	// Script is a list of events that are applied during the lifetime of the Proxy.
+	Script []Event
+}
+
+// client is a client, as identified by its address.
+type client struct {
+	addr *net.UDPAddr
+
+	mutex      sync.Mutex
+	serverConn *net.UDPConn // the UDP connection used to send packets to the server
+}
+
+func (c *client) getServerConn() *net.UDPConn {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.serverConn
+}
+
+// Proxy is a UDP proxy that emulates a network link.
+type Proxy struct {
+	conn       *net.UDPConn
+	serverAddr *net.UDPAddr
+
+	incoming *link
+	outgoing *link
+
+	mutex     sync.Mutex
+	closed    bool
+	closeChan chan struct{}
+	// Mapping from client addresses (as host:port) to clients
+	clients map[string]*client
+
+	logger utils.Logger
+}
+
+// NewProxy creates a new Proxy, listening on the local address.
+func NewProxy(local string, opts *Opts) (*Proxy, error) {
+	if opts == nil {
+		opts = &Opts{}
+	}
+	laddr, err := net.ResolveUDPAddr("udp", local)
+	if err != nil {
+		return nil, err
+	}
+	raddr, err := net.ResolveUDPAddr("udp", opts.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	setBufferSizes(conn)
+
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	p := &Proxy{
+		conn:       conn,
+		serverAddr: raddr,
+		incoming:   newLink(opts.Incoming, seed),
+		outgoing:   newLink(opts.Outgoing, seed+1),
+		closeChan:  make(chan struct{}),
+		clients:    make(map[string]*client),
+		logger:     utils.DefaultLogger.WithPrefix("netem"),
+	}
+	p.logger.Debugf("Starting netem proxy %s <-> %s", conn.LocalAddr(), raddr)
+	go p.runProxy()
+	if len(opts.Script) > 0 {
+		go p.runScript(opts.Script)
+	}
+	return p, nil
+}
+
+func setBufferSizes(conn *net.UDPConn) {
+	// Errors are ignored: the proxy still works with smaller buffers, it's just more likely to drop packets.
+	_ = conn.SetReadBuffer(protocol.DesiredReceiveBufferSize)
+	_ = conn.SetWriteBuffer(protocol.DesiredSendBufferSize)
+}
+
+// LocalAddr is the address the proxy is listening on.
+func (p *Proxy) LocalAddr() net.Addr {
+	return p.conn.LocalAddr()
+}
+
+// LocalPort is the UDP port number the proxy is listening on.
+func (p *Proxy) LocalPort() int {
+	return p.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// SetLink changes the configuration of the link in the given direction.
+// Packets that are currently in flight are not affected.
+func (p *Proxy) SetLink(dir Direction, config LinkConfig) {
+	if dir.Is(DirectionIncoming) {
+		p.incoming.setConfig(config)
+	}
+	if dir.Is(DirectionOutgoing) {
+		p.outgoing.setConfig(config)
+	}
+}
+
+// Stats returns the statistics of the link in the given direction.
+// It is invalid to pass DirectionBoth.
+func (p *Proxy) Stats(dir Direction) LinkStats {
+	switch dir {
+	case DirectionIncoming:
+		return p.incoming.getStats()
+	case DirectionOutgoing:
+		return p.outgoing.getStats()
+	default:
+		panic("netem: invalid direction")
+	}
+}
+
+// Rebind simulates a NAT rebinding for all clients:
+// From now on, packets from the clients arrive at the server from a new address.
+// If localAddr is empty, the new address uses a new port on the same IP address.
+// Otherwise, it's used as the local address of the new socket, which allows simulating an address change,
+// e.g. by using a different loopback address.
+// Packets sent by the server to the old address are dropped.
+func (p *Proxy) Rebind(localAddr string) error {
+	var laddr *net.UDPAddr
+	if localAddr != "" {
+		var err error
+		laddr, err = net.ResolveUDPAddr("udp", localAddr)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return net.ErrClosed
+	}
+	for _, c := range p.clients {
+		conn, err := net.DialUDP("udp", laddr, p.serverAddr)
+		if err != nil {
+			return err
+		}
+		setBufferSizes(conn)
+		c.mutex.Lock()
+		oldConn := c.serverConn
+		c.serverConn = conn
+		c.mutex.Unlock()
+		p.logger.Debugf("Rebinding client %s: %s -> %s", c.addr, oldConn.LocalAddr(), conn.LocalAddr())
+		oldConn.Close()
+		go p.runOutgoing(c, conn)
+	}
+	return nil
+}
+
+// Close stops the Proxy.
+func (p *Proxy) Close() error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.closeChan)
+	var errs []error
+	for _, c := range p.clients {
+		if err := c.getServerConn().Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, p.conn.Close())
+	p.mutex.Unlock()
+
+	p.incoming.Close()
+	p.outgoing.Close()
+	return errors.Join(errs...)
+}
+
+func (p *Proxy) runScript(events []Event) {
+	start := time.Now()
+	events = append([]Event(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for _, e := range events {
+		timer.Reset(time.Until(start.Add(e.At)))
+		select {
+		case <-p.closeChan:
+			return
+		case <-timer.C:
+		}
+		if e.Link != nil {
+			p.SetLink(e.Direction, *e.Link)
+		}
+		if e.Rebind {
+			if err := p.Rebind(e.RebindAddr); err != nil {
+				p.logger.Errorf("Rebinding failed: %s", err)
+			}
+		}
+	}
+}
+
+func (p *Proxy) newClient(addr *net.UDPAddr) (*client, error) {
+	conn, err := net.DialUDP("udp", nil, p.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	setBufferSizes(conn)
+	return &client{addr: addr, serverConn: conn}, nil
+}
+
+// runProxy listens on the proxy address and handles packets sent by the clients.
+func (p *Proxy) runProxy() error {
+	for {
+		buffer := make([]byte, protocol.MaxPacketBufferSize)
+		n, cliaddr, err := p.conn.ReadFromUDP(buffer)
+		if err != nil {
+			return err
+		}
+		raw := buffer[:n]
+
+		saddr := cliaddr.String()
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return net.ErrClosed
+		}
+		c, ok := p.clients[saddr]
+		if !ok {
+			c, err = p.newClient(cliaddr)
+			if err != nil {
+				p.mutex.Unlock()
+				return err
+			}
+			p.clients[saddr] = c
+			go p.runOutgoing(c, c.serverConn)
+		}
+		p.mutex.Unlock()
+
+		p.incoming.Send(raw, func(b []byte) {
+			if _, err := c.getServerConn().Write(b); err != nil {
+				p.logger.Debugf("Failed to forward incoming packet (%d bytes): %s", len(b), err)
+			}
+		})
+	}
+}
+
+// runOutgoing handles packets sent by the server to a single client.
+func (p *Proxy) runOutgoing(c *client, conn *net.UDPConn) {
+	for {
+		buffer := make([]byte, protocol.MaxPacketBufferSize)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		p.outgoing.Send(buffer[:n], func(b []byte) {
+			if _, err := p.conn.WriteToUDP(b, c.addr); err != nil {
+				p.logger.Debugf("Failed to forward outgoing packet (%d bytes): %s", len(b), err)
+			}
+		})
+	}
+}
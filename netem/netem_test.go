@@ -0,0 +1,148 @@
+package netem
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newUDPConn(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readFrom(t *testing.T, conn *net.UDPConn) ([]byte, *net.UDPAddr) {
+	t.Helper()
+	b := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, addr, err := conn.ReadFromUDP(b)
+	require.NoError(t, err)
+	return b[:n], addr
+}
+
+func TestDirection(t *testing.T) {
+	require.Equal(t, "incoming", DirectionIncoming.String())
+	require.Equal(t, "outgoing", DirectionOutgoing.String())
+	require.Equal(t, "both", DirectionBoth.String())
+	require.True(t, DirectionIncoming.Is(DirectionBoth))
+	require.True(t, DirectionBoth.Is(DirectionOutgoing))
+	require.False(t, DirectionIncoming.Is(DirectionOutgoing))
+}
+
+func TestProxyForwards(t *testing.T) {
+	server := newUDPConn(t)
+	proxy, err := NewProxy("127.0.0.1:0", &Opts{
+		RemoteAddr: server.LocalAddr().String(),
+		Incoming:   LinkConfig{Delay: 10 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	client := newUDPConn(t)
+	start := time.Now()
+	_, err = client.WriteTo([]byte("foo"), proxy.LocalAddr())
+	require.NoError(t, err)
+	b, addr := readFrom(t, server)
+	require.Equal(t, []byte("foo"), b)
+	require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	_, err = server.WriteTo([]byte("bar"), addr)
+	require.NoError(t, err)
+	b, _ = readFrom(t, client)
+	require.Equal(t, []byte("bar"), b)
+
+	require.Equal(t, uint64(1), proxy.Stats(DirectionIncoming).Delivered)
+	require.Equal(t, uint64(1), proxy.Stats(DirectionOutgoing).Delivered)
+}
+
+func TestProxySetLink(t *testing.T) {
+	server := newUDPConn(t)
+	proxy, err := NewProxy("127.0.0.1:0", &Opts{RemoteAddr: server.LocalAddr().String()})
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	proxy.SetLink(DirectionBoth, LinkConfig{Loss: RandomLoss(1)})
+	client := newUDPConn(t)
+	_, err = client.WriteTo([]byte("foo"), proxy.LocalAddr())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return proxy.Stats(DirectionIncoming).Lost == 1 }, time.Second, time.Millisecond)
+
+	proxy.SetLink(DirectionIncoming, LinkConfig{})
+	_, err = client.WriteTo([]byte("bar"), proxy.LocalAddr())
+	require.NoError(t, err)
+	b, _ := readFrom(t, server)
+	require.Equal(t, []byte("bar"), b)
+}
+
+func TestProxyRebind(t *testing.T) {
+	server := newUDPConn(t)
+	proxy, err := NewProxy("127.0.0.1:0", &Opts{RemoteAddr: server.LocalAddr().String()})
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	client := newUDPConn(t)
+	_, err = client.WriteTo([]byte("foo"), proxy.LocalAddr())
+	require.NoError(t, err)
+	_, addr1 := readFrom(t, server)
+
+	require.NoError(t, proxy.Rebind(""))
+	_, err = client.WriteTo([]byte("bar"), proxy.LocalAddr())
+	require.NoError(t, err)
+	b, addr2 := readFrom(t, server)
+	require.Equal(t, []byte("bar"), b)
+	require.NotEqual(t, addr1.String(), addr2.String())
+
+	// packets sent to the new address are forwarded to the client
+	_, err = server.WriteTo([]byte("baz"), addr2)
+	require.NoError(t, err)
+	b, _ = readFrom(t, client)
+	require.Equal(t, []byte("baz"), b)
+}
+
+func TestProxyScript(t *testing.T) {
+	server := newUDPConn(t)
+	proxy, err := NewProxy("127.0.0.1:0", &Opts{
+		RemoteAddr: server.LocalAddr().String(),
+		Script: []Event{
+			{At: 50 * time.Millisecond, Direction: DirectionIncoming, Link: &LinkConfig{Loss: RandomLoss(1)}},
+			{At: 20 * time.Millisecond, Rebind: true},
+		},
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	client := newUDPConn(t)
+	_, err = client.WriteTo([]byte("foo"), proxy.LocalAddr())
+	require.NoError(t, err)
+	_, addr1 := readFrom(t, server)
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = client.WriteTo([]byte("bar"), proxy.LocalAddr())
+	require.NoError(t, err)
+	_, addr2 := readFrom(t, server)
+	require.NotEqual(t, addr1.String(), addr2.String())
+
+	time.Sleep(50 * time.Millisecond)
+	_, err = client.WriteTo([]byte("baz"), proxy.LocalAddr())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return proxy.Stats(DirectionIncoming).Lost == 1 }, time.Second, time.Millisecond)
+}
+
+func TestProxyClose(t *testing.T) {
+	server := newUDPConn(t)
+	proxy, err := NewProxy("127.0.0.1:0", &Opts{RemoteAddr: server.LocalAddr().String()})
+	require.NoError(t, err)
+	client := newUDPConn(t)
+	_, err = client.WriteTo([]byte("foo"), proxy.LocalAddr())
+	require.NoError(t, err)
+	readFrom(t, server)
+
+	require.NoError(t, proxy.Close())
+	require.NoError(t, proxy.Close())
+	require.ErrorIs(t, proxy.Rebind(""), net.ErrClosed)
+}
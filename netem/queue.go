@@ -0,0 +1,157 @@
+package netem
+
+import (
+	"math"
+	"time"
+)
+
+// QueueDiscipline is the queue management algorithm used for the bottleneck queue.
+type QueueDiscipline uint8
+
+const (
+	// DropTail drops packets when the queue is full.
+	DropTail QueueDiscipline = iota
+	// CoDel uses Controlled Delay Active Queue Management, as specified in RFC 8289.
+	// The queue size is still enforced by dropping packets when the queue is full.
+	CoDel
+)
+
+func (d QueueDiscipline) String() string {
+	switch d {
+	case DropTail:
+		return "drop-tail"
+	case CoDel:
+		return "CoDel"
+	default:
+		return "unknown queue discipline"
+	}
+}
+
+const (
+	// DefaultCoDelTarget is the default CoDel target, see Section 4.4 of RFC 8289.
+	DefaultCoDelTarget = 5 * time.Millisecond
+	// DefaultCoDelInterval is the default CoDel interval, see Section 4.5 of RFC 8289.
+	DefaultCoDelInterval = 100 * time.Millisecond
+)
+
+// maxPacketSize is the size of a full-sized packet on the emulated link (the MTU in RFC 8289).
+const maxPacketSize = 1500
+
+type packet struct {
+	data    []byte
+	deliver func([]byte)
+
+	enqueued  time.Time // when the packet entered the bottleneck queue
+	deliverAt time.Time // when the packet leaves the delay line
+	seq       uint64    // used to keep the delay line stable for packets with equal delivery times
+}
+
+// packetQueue is a FIFO queue of packets.
+type packetQueue struct {
+	packets []*packet
+	bytes   int
+}
+
+func (q *packetQueue) Len() int { return len(q.packets) }
+
+func (q *packetQueue) push(p *packet) {
+	q.packets = append(q.packets, p)
+	q.bytes += len(p.data)
+}
+
+func (q *packetQueue) pop() *packet {
+	if len(q.packets) == 0 {
+		return nil
+	}
+	p := q.packets[0]
+	q.packets[0] = nil
+	q.packets = q.packets[1:]
+	q.bytes -= len(p.data)
+	return p
+}
+
+// codel implements the CoDel dequeue algorithm (Section 5 of RFC 8289).
+type codel struct {
+	target, interval time.Duration
+
+	firstAboveTime time.Time
+	dropNext       time.Time
+	count          uint32
+	lastCount      uint32
+	dropping       bool
+}
+
+// doDequeue pops the next packet and says if it may be dropped.
+func (c *codel) doDequeue(now time.Time, q *packetQueue) (*packet, bool) {
+	p := q.pop()
+	if p == nil {
+		c.firstAboveTime = time.Time{}
+		return nil, false
+	}
+	if now.Sub(p.enqueued) < c.target || q.bytes <= maxPacketSize {
+		// went below, so we'll stay below for at least interval
+		c.firstAboveTime = time.Time{}
+		return p, false
+	}
+	if c.firstAboveTime.IsZero() {
+		// just went above from below.
+		// If still above at first_above_time, will say it's ok to drop.
+		c.firstAboveTime = now.Add(c.interval)
+		return p, false
+	}
+	return p, !now.Before(c.firstAboveTime)
+}
+
+func (c *codel) controlLaw(t time.Time) time.Time {
+	return t.Add(time.Duration(float64(c.interval) / math.Sqrt(float64(c.count))))
+}
+
+// dequeue returns the next packet that should be transmitted.
+// Dropped packets are passed to drop.
+func (c *codel) dequeue(now time.Time, q *packetQueue, drop func(*packet)) *packet {
+	p, okToDrop := c.doDequeue(now, q)
+	if p == nil {
+		c.dropping = false
+		return nil
+	}
+	if c.dropping {
+		if !okToDrop {
+			// sojourn time below target - leave drop state
+			c.dropping = false
+		}
+		// Time for the next drop. Drop current packet and dequeue next.
+		// If the dequeue doesn't take us out of dropping state, schedule the next drop.
+		for c.dropping && !now.Before(c.dropNext) {
+			drop(p)
+			c.count++
+			p, okToDrop = c.doDequeue(now, q)
+			if !okToDrop {
+				// leave drop state
+				c.dropping = false
+			} else {
+				// schedule the next drop
+				c.dropNext = c.controlLaw(c.dropNext)
+			}
+		}
+		return p
+	}
+	if okToDrop {
+		// If we get here, we're not in drop state.
+		// The okToDrop return from doDequeue means that the sojourn time has been above target for interval,
+		// so enter drop state.
+		drop(p)
+		p, _ = c.doDequeue(now, q)
+		c.dropping = true
+		// If min went above target close to when it last went below, assume that the drop rate that
+		// controlled the queue on the last cycle is a good starting point to control it now.
+		delta := c.count - c.lastCount
+		if delta > 1 && now.Sub(c.dropNext) < 16*c.interval {
+			c.count = delta
+		} else {
+			c.count = 1
+		}
+		c.dropNext = c.controlLaw(now)
+		c.lastCount = c.count
+	}
+	return p
+}
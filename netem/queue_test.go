@@ -0,0 +1,82 @@
+package netem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketQueue(t *testing.T) {
+	var q packetQueue
+	require.Nil(t, q.pop())
+	q.push(&packet{data: make([]byte, 100)})
+	q.push(&packet{data: make([]byte, 200)})
+	require.Equal(t, 2, q.Len())
+	require.Equal(t, 300, q.bytes)
+	require.Len(t, q.pop().data, 100)
+	require.Equal(t, 200, q.bytes)
+	require.Len(t, q.pop().data, 200)
+	require.Zero(t, q.bytes)
+	require.Nil(t, q.pop())
+}
+
+func TestCoDelNoDropsBelowTarget(t *testing.T) {
+	c := &codel{target: 5 * time.Millisecond, interval: 100 * time.Millisecond}
+	var q packetQueue
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		q.push(&packet{data: make([]byte, 1000), enqueued: now})
+	}
+	var dropped int
+	// every packet spends less than the target in the queue
+	for i := 0; i < 100; i++ {
+		require.NotNil(t, c.dequeue(now.Add(4*time.Millisecond), &q, func(*packet) { dropped++ }))
+	}
+	require.Zero(t, dropped)
+}
+
+func TestCoDelDropsStandingQueue(t *testing.T) {
+	c := &codel{target: 5 * time.Millisecond, interval: 100 * time.Millisecond}
+	var q packetQueue
+	start := time.Now()
+	var dropped []time.Time
+	// Simulate a standing queue: a packet is enqueued every millisecond,
+	// and every packet spends 20ms in the queue.
+	now := start
+	for i := 0; i < 20; i++ {
+		q.push(&packet{data: make([]byte, 1000), enqueued: now.Add(time.Duration(i) * time.Millisecond)})
+	}
+	for i := 0; i < 1000; i++ {
+		now = start.Add(time.Duration(i+20) * time.Millisecond)
+		q.push(&packet{data: make([]byte, 1000), enqueued: now})
+		c.dequeue(now, &q, func(*packet) { dropped = append(dropped, now) })
+	}
+	require.NotEmpty(t, dropped)
+	// CoDel only starts dropping after the sojourn time was above the target for an interval
+	require.GreaterOrEqual(t, dropped[0].Sub(start), 100*time.Millisecond)
+	// the drop rate increases over time
+	require.Greater(t, len(dropped), 3)
+	require.Less(t, dropped[2].Sub(dropped[1]), dropped[1].Sub(dropped[0]))
+}
+
+func TestCoDelLeavesDroppingState(t *testing.T) {
+	c := &codel{target: 5 * time.Millisecond, interval: 100 * time.Millisecond}
+	var q packetQueue
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		q.push(&packet{data: make([]byte, 1000), enqueued: start})
+	}
+	var dropped int
+	c.dequeue(start.Add(time.Second), &q, func(*packet) { dropped++ })
+	c.dequeue(start.Add(time.Second+200*time.Millisecond), &q, func(*packet) { dropped++ })
+	require.True(t, c.dropping)
+	require.NotZero(t, dropped)
+	// the queue drains
+	for q.Len() > 0 {
+		c.dequeue(start.Add(2*time.Second), &q, func(*packet) {})
+	}
+	q.push(&packet{data: make([]byte, 1000), enqueued: start.Add(2 * time.Second)})
+	require.NotNil(t, c.dequeue(start.Add(2*time.Second+time.Millisecond), &q, func(*packet) { t.Fatal("didn't expect a drop") }))
+	require.False(t, c.dropping)
+}
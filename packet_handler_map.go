@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"hash"
+	"hash/maphash"
 	"io"
 	"net"
 	"sync"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/nxenon/xquic-go/internal/protocol"
 	"github.com/nxenon/xquic-go/internal/utils"
+
+	"golang.org/x/sys/cpu"
 )
 
 type connCapabilities struct {
@@ -20,6 +23,8 @@ type connCapabilities struct {
 	DF bool
 	// GSO (Generic Segmentation Offload) supported
 	GSO bool
+	// GRO (Generic Receive Offload) enabled
+	GRO bool
 	// ECN (Explicit Congestion Notifications) supported
 	ECN bool
 }
@@ -30,7 +35,9 @@ type rawConn interface {
 	// WritePacket writes a packet on the wire.
 	// gsoSize is the size of a single packet, or 0 to disable GSO.
 	// It is invalid to set gsoSize if capabilities.GSO is not set.
-	WritePacket(b []byte, addr net.Addr, packetInfoOOB []byte, gsoSize uint16, ecn protocol.ECN) (int, error)
+	// The dscp is combined with the ECN codepoint into the IPv4 TOS / IPv6 Traffic Class field.
+	// It is ignored if the conn doesn't support sending control messages.
+	WritePacket(b []byte, addr net.Addr, packetInfoOOB []byte, gsoSize uint16, ecn protocol.ECN, dscp uint8) (int, error)
 	LocalAddr() net.Addr
 	SetReadDeadline(time.Time) error
 	io.Closer
@@ -44,20 +51,40 @@ type closePacket struct {
 	info    packetInfo
 }
 
-type packetHandlerMap struct {
-	mutex       sync.Mutex
+// numPacketHandlerMapShards is the number of shards of the packetHandlerMap.
+// It needs to be a power of 2.
+const numPacketHandlerMapShards = 64
+
+// A packetHandlerMapShard holds a part of the connection IDs and stateless reset tokens.
+// Packets are routed by looking up their connection ID, which is by far the most common operation,
+// so every shard is protected by its own RWMutex.
+type packetHandlerMapShard struct {
+	mutex       sync.RWMutex
 	handlers    map[protocol.ConnectionID]packetHandler
 	resetTokens map[protocol.StatelessResetToken] /* stateless reset token */ packetHandler
 
-	closed    bool
-	closeChan chan struct{}
+	// prevent false sharing between shards that are used from different CPU cores
+	_ cpu.CacheLinePad
+}
+
+// The packetHandlerMap maps connection IDs and stateless reset tokens to packet handlers.
+// In order to reduce lock contention on servers with many CPU cores, the maps are split into shards,
+// using a randomly seeded hash of the connection ID (or the stateless reset token).
+type packetHandlerMap struct {
+	seed   maphash.Seed
+	shards [numPacketHandlerMapShards]packetHandlerMapShard
+
+	closeMutex sync.Mutex
+	closed     bool
+	closeChan  chan struct{}
 
 	enqueueClosePacket func(closePacket)
 
 	deleteRetiredConnsAfter time.Duration
 
-	statelessResetMutex  sync.Mutex
-	statelessResetHasher hash.Hash
+	// Generating a stateless reset token requires a HMAC.
+	// hash.Hash is not safe for concurrent use, so we keep a pool of them.
+	statelessResetHashers *sync.Pool
 
 	logger utils.Logger
 }
@@ -66,15 +93,21 @@ var _ packetHandlerManager = &packetHandlerMap{}
 
 func newPacketHandlerMap(key *StatelessResetKey, enqueueClosePacket func(closePacket), logger utils.Logger) *packetHandlerMap {
 	h := &packetHandlerMap{
+		seed:                    maphash.MakeSeed(),
 		closeChan:               make(chan struct{}),
-		handlers:                make(map[protocol.ConnectionID]packetHandler),
-		resetTokens:             make(map[protocol.StatelessResetToken]packetHandler),
 		deleteRetiredConnsAfter: protocol.RetiredConnectionIDDeleteTimeout,
 		enqueueClosePacket:      enqueueClosePacket,
 		logger:                  logger,
 	}
+	for i := range h.shards {
+		h.shards[i].handlers = make(map[protocol.ConnectionID]packetHandler)
+		h.shards[i].resetTokens = make(map[protocol.StatelessResetToken]packetHandler)
+	}
 	if key != nil {
-		h.statelessResetHasher = hmac.New(sha256.New, key[:])
+		k := *key
+		h.statelessResetHashers = &sync.Pool{
+			New: func() any { return hmac.New(sha256.New, k[:]) },
+		}
 	}
 	if h.logger.Debug() {
 		go h.logUsage()
@@ -82,6 +115,18 @@ func newPacketHandlerMap(key *StatelessResetKey, enqueueClosePacket func(closePa
 	return h
 }
 
+func (h *packetHandlerMap) shardIndex(id protocol.ConnectionID) int {
+	return int(maphash.Bytes(h.seed, id.Bytes()) & (numPacketHandlerMapShards - 1))
+}
+
+func (h *packetHandlerMap) shard(id protocol.ConnectionID) *packetHandlerMapShard {
+	return &h.shards[h.shardIndex(id)]
+}
+
+func (h *packetHandlerMap) tokenShard(token protocol.StatelessResetToken) *packetHandlerMapShard {
+	return &h.shards[maphash.Bytes(h.seed, token[:])&(numPacketHandlerMapShards-1)]
+}
+
 func (h *packetHandlerMap) logUsage() {
 	ticker := time.NewTicker(2 * time.Second)
 	var printedZero bool
@@ -92,10 +137,14 @@ func (h *packetHandlerMap) logUsage() {
 		case <-ticker.C:
 		}
 
-		h.mutex.Lock()
-		numHandlers := len(h.handlers)
-		numTokens := len(h.resetTokens)
-		h.mutex.Unlock()
+		var numHandlers, numTokens int
+		for i := range h.shards {
+			shard := &h.shards[i]
+			shard.mutex.RLock()
+			numHandlers += len(shard.handlers)
+			numTokens += len(shard.resetTokens)
+			shard.mutex.RUnlock()
+		}
 		// If the number tracked handlers and tokens is zero, only print it a single time.
 		hasZero := numHandlers == 0 && numTokens == 0
 		if !hasZero || (hasZero && !printedZero) {
@@ -109,31 +158,42 @@ func (h *packetHandlerMap) logUsage() {
 }
 
 func (h *packetHandlerMap) Get(id protocol.ConnectionID) (packetHandler, bool) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
-
-	handler, ok := h.handlers[id]
+	shard := h.shard(id)
+	shard.mutex.RLock()
+	handler, ok := shard.handlers[id]
+	shard.mutex.RUnlock()
 	return handler, ok
 }
 
 func (h *packetHandlerMap) Add(id protocol.ConnectionID, handler packetHandler) bool /* was added */ {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+	shard := h.shard(id)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
-	if _, ok := h.handlers[id]; ok {
+	if _, ok := shard.handlers[id]; ok {
 		h.logger.Debugf("Not adding connection ID %s, as it already exists.", id)
 		return false
 	}
-	h.handlers[id] = handler
+	shard.handlers[id] = handler
 	h.logger.Debugf("Adding connection ID %s.", id)
 	return true
 }
 
 func (h *packetHandlerMap) AddWithConnID(clientDestConnID, newConnID protocol.ConnectionID, fn func() (packetHandler, bool)) bool {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+	// Both connection IDs need to be added atomically.
+	// Lock the shards in a fixed order to avoid deadlocks.
+	i1, i2 := h.shardIndex(clientDestConnID), h.shardIndex(newConnID)
+	if i1 > i2 {
+		i1, i2 = i2, i1
+	}
+	h.shards[i1].mutex.Lock()
+	defer h.shards[i1].mutex.Unlock()
+	if i1 != i2 {
+		h.shards[i2].mutex.Lock()
+		defer h.shards[i2].mutex.Unlock()
+	}
 
-	if _, ok := h.handlers[clientDestConnID]; ok {
+	if _, ok := h.shard(clientDestConnID).handlers[clientDestConnID]; ok {
 		h.logger.Debugf("Not adding connection ID %s for a new connection, as it already exists.", clientDestConnID)
 		return false
 	}
@@ -141,25 +201,28 @@ func (h *packetHandlerMap) AddWithConnID(clientDestConnID, newConnID protocol.Co
 	if !ok {
 		return false
 	}
-	h.handlers[clientDestConnID] = conn
-	h.handlers[newConnID] = conn
+	h.shard(clientDestConnID).handlers[clientDestConnID] = conn
+	h.shard(newConnID).handlers[newConnID] = conn
 	h.logger.Debugf("Adding connection IDs %s and %s for a new connection.", clientDestConnID, newConnID)
 	return true
 }
 
 func (h *packetHandlerMap) Remove(id protocol.ConnectionID) {
-	h.mutex.Lock()
-	delete(h.handlers, id)
-	h.mutex.Unlock()
+	h.remove(id)
 	h.logger.Debugf("Removing connection ID %s.", id)
 }
 
+func (h *packetHandlerMap) remove(id protocol.ConnectionID) {
+	shard := h.shard(id)
+	shard.mutex.Lock()
+	delete(shard.handlers, id)
+	shard.mutex.Unlock()
+}
+
 func (h *packetHandlerMap) Retire(id protocol.ConnectionID) {
 	h.logger.Debugf("Retiring connection ID %s in %s.", id, h.deleteRetiredConnsAfter)
 	time.AfterFunc(h.deleteRetiredConnsAfter, func() {
-		h.mutex.Lock()
-		delete(h.handlers, id)
-		h.mutex.Unlock()
+		h.remove(id)
 		h.logger.Debugf("Removing connection ID %s after it has been retired.", id)
 	})
 }
@@ -182,80 +245,105 @@ func (h *packetHandlerMap) ReplaceWithClosed(ids []protocol.ConnectionID, pers p
 		handler = newClosedRemoteConn(pers)
 	}
 
-	h.mutex.Lock()
 	for _, id := range ids {
-		h.handlers[id] = handler
+		shard := h.shard(id)
+		shard.mutex.Lock()
+		shard.handlers[id] = handler
+		shard.mutex.Unlock()
 	}
-	h.mutex.Unlock()
 	h.logger.Debugf("Replacing connection for connection IDs %s with a closed connection.", ids)
 
 	time.AfterFunc(h.deleteRetiredConnsAfter, func() {
-		h.mutex.Lock()
 		handler.shutdown()
 		for _, id := range ids {
-			delete(h.handlers, id)
+			h.remove(id)
 		}
-		h.mutex.Unlock()
 		h.logger.Debugf("Removing connection IDs %s for a closed connection after it has been retired.", ids)
 	})
 }
 
+// Range calls fn for every connection ID, and the handler it is routed to.
+// It operates on a snapshot of the map, so fn may call other methods of the packetHandlerMap.
+// The snapshot is taken one shard at a time, so it is not guaranteed to be consistent across shards.
+func (h *packetHandlerMap) Range(fn func(protocol.ConnectionID, packetHandler)) {
+	var connIDs []protocol.ConnectionID
+	var handlers []packetHandler
+	for i := range h.shards {
+		shard := &h.shards[i]
+		shard.mutex.RLock()
+		for id, handler := range shard.handlers {
+			connIDs = append(connIDs, id)
+			handlers = append(handlers, handler)
+		}
+		shard.mutex.RUnlock()
+	}
+
+	for i, id := range connIDs {
+		fn(id, handlers[i])
+	}
+}
+
 func (h *packetHandlerMap) AddResetToken(token protocol.StatelessResetToken, handler packetHandler) {
-	h.mutex.Lock()
-	h.resetTokens[token] = handler
-	h.mutex.Unlock()
+	shard := h.tokenShard(token)
+	shard.mutex.Lock()
+	shard.resetTokens[token] = handler
+	shard.mutex.Unlock()
 }
 
 func (h *packetHandlerMap) RemoveResetToken(token protocol.StatelessResetToken) {
-	h.mutex.Lock()
-	delete(h.resetTokens, token)
-	h.mutex.Unlock()
+	shard := h.tokenShard(token)
+	shard.mutex.Lock()
+	delete(shard.resetTokens, token)
+	shard.mutex.Unlock()
 }
 
 func (h *packetHandlerMap) GetByResetToken(token protocol.StatelessResetToken) (packetHandler, bool) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
-
-	handler, ok := h.resetTokens[token]
+	shard := h.tokenShard(token)
+	shard.mutex.RLock()
+	handler, ok := shard.resetTokens[token]
+	shard.mutex.RUnlock()
 	return handler, ok
 }
 
 func (h *packetHandlerMap) Close(e error) {
-	h.mutex.Lock()
+	h.closeMutex.Lock()
+	defer h.closeMutex.Unlock()
 
 	if h.closed {
-		h.mutex.Unlock()
 		return
 	}
-
+	h.closed = true
 	close(h.closeChan)
 
 	var wg sync.WaitGroup
-	for _, handler := range h.handlers {
-		wg.Add(1)
-		go func(handler packetHandler) {
-			handler.destroy(e)
-			wg.Done()
-		}(handler)
+	for i := range h.shards {
+		shard := &h.shards[i]
+		shard.mutex.RLock()
+		for _, handler := range shard.handlers {
+			wg.Add(1)
+			go func(handler packetHandler) {
+				handler.destroy(e)
+				wg.Done()
+			}(handler)
+		}
+		shard.mutex.RUnlock()
 	}
-	h.closed = true
-	h.mutex.Unlock()
 	wg.Wait()
 }
 
 func (h *packetHandlerMap) GetStatelessResetToken(connID protocol.ConnectionID) protocol.StatelessResetToken {
 	var token protocol.StatelessResetToken
-	if h.statelessResetHasher == nil {
+	if h.statelessResetHashers == nil {
 		// Return a random stateless reset token.
 		// This token will be sent in the server's transport parameters.
 		// By using a random token, an off-path attacker won't be able to disrupt the connection.
 		rand.Read(token[:])
 		return token
 	}
-	h.statelessResetMutex.Lock()
-	h.statelessResetHasher.Write(connID.Bytes())
-	copy(token[:], h.statelessResetHasher.Sum(nil))
-	h.statelessResetHasher.Reset()
-	h.statelessResetMutex.Unlock()
+	hasher := h.statelessResetHashers.Get().(hash.Hash)
+	hasher.Write(connID.Bytes())
+	copy(token[:], hasher.Sum(nil))
+	hasher.Reset()
+	h.statelessResetHashers.Put(hasher)
 	return token
 }
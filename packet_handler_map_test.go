@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"errors"
 	"net"
+	"sync"
+	"testing"
 	"time"
 
 	"github.com/nxenon/xquic-go/internal/protocol"
@@ -73,6 +75,31 @@ var _ = Describe("Packet Handler Map", func() {
 		})).To(BeFalse())
 	})
 
+	It("ranges over all handlers", func() {
+		m := newPacketHandlerMap(nil, nil, utils.DefaultLogger)
+		connID1 := protocol.ParseConnectionID([]byte{1, 2, 3, 4})
+		connID2 := protocol.ParseConnectionID([]byte{4, 3, 2, 1})
+		connID3 := protocol.ParseConnectionID([]byte{1, 1, 1, 1})
+		handler1 := NewMockPacketHandler(mockCtrl)
+		handler2 := NewMockPacketHandler(mockCtrl)
+		Expect(m.Add(connID1, handler1)).To(BeTrue())
+		Expect(m.Add(connID2, handler1)).To(BeTrue())
+		Expect(m.Add(connID3, handler2)).To(BeTrue())
+		handlers := make(map[protocol.ConnectionID]packetHandler)
+		m.Range(func(id protocol.ConnectionID, h packetHandler) {
+			// it's possible to modify the map while ranging over it
+			m.Remove(id)
+			handlers[id] = h
+		})
+		Expect(handlers).To(Equal(map[protocol.ConnectionID]packetHandler{
+			connID1: handler1,
+			connID2: handler1,
+			connID3: handler2,
+		}))
+		_, ok := m.Get(connID1)
+		Expect(ok).To(BeFalse())
+	})
+
 	It("adds, gets and removes reset tokens", func() {
 		m := newPacketHandlerMap(nil, nil, utils.DefaultLogger)
 		token := protocol.StatelessResetToken{1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 0xa, 0xb, 0xc, 0xd, 0xe, 0xf}
@@ -159,6 +186,34 @@ var _ = Describe("Packet Handler Map", func() {
 		Eventually(func() bool { _, ok := m.Get(connID); return ok }).Should(BeFalse())
 	})
 
+	It("adds, gets and removes from multiple goroutines", func() {
+		m := newPacketHandlerMap(nil, nil, utils.DefaultLogger)
+		const num = 1000
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for i := 0; i < num; i++ {
+					connID := protocol.ParseConnectionID([]byte{byte(g), byte(i >> 8), byte(i), 0xde, 0xad})
+					handler := NewMockPacketHandler(mockCtrl)
+					Expect(m.Add(connID, handler)).To(BeTrue())
+					h, ok := m.Get(connID)
+					Expect(ok).To(BeTrue())
+					Expect(h).To(Equal(handler))
+					if i%2 == 0 {
+						m.Remove(connID)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		var count int
+		m.Range(func(protocol.ConnectionID, packetHandler) { count++ })
+		Expect(count).To(Equal(4 * num / 2))
+	})
+
 	It("closes", func() {
 		m := newPacketHandlerMap(nil, nil, utils.DefaultLogger)
 		testErr := errors.New("shutdown")
@@ -174,3 +229,106 @@ var _ = Describe("Packet Handler Map", func() {
 		m.Close(errors.New("close"))
 	})
 })
+
+func newBenchmarkConnIDs(n int) []protocol.ConnectionID {
+	connIDs := make([]protocol.ConnectionID, n)
+	for i := range connIDs {
+		b := make([]byte, 8)
+		rand.Read(b)
+		connIDs[i] = protocol.ParseConnectionID(b)
+	}
+	return connIDs
+}
+
+func BenchmarkPacketHandlerMapGet(b *testing.B) {
+	const numConns = 100000
+	m := newPacketHandlerMap(nil, nil, utils.DefaultLogger)
+	connIDs := newBenchmarkConnIDs(numConns)
+	handler := newClosedRemoteConn(protocol.PerspectiveServer)
+	for _, id := range connIDs {
+		m.Add(id, handler)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			if _, ok := m.Get(connIDs[i%numConns]); !ok {
+				b.Fatal("connection ID not found")
+			}
+			i++
+		}
+	})
+}
+
+// BenchmarkPacketHandlerMapChurn simulates a server with many connections,
+// where connections are constantly being established and closed, while packets are being routed.
+func BenchmarkPacketHandlerMapChurn(b *testing.B) {
+	const numConns = 100000
+	m := newPacketHandlerMap(nil, nil, utils.DefaultLogger)
+	connIDs := newBenchmarkConnIDs(numConns)
+	handler := newClosedRemoteConn(protocol.PerspectiveServer)
+	for _, id := range connIDs {
+		m.Add(id, handler)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		newConnIDs := newBenchmarkConnIDs(64)
+		var i int
+		for pb.Next() {
+			// one in 16 operations adds or removes a connection ID, the rest are lookups
+			switch i % 16 {
+			case 0:
+				m.Add(newConnIDs[(i/16)%len(newConnIDs)], handler)
+			case 8:
+				m.Remove(newConnIDs[(i/16)%len(newConnIDs)])
+			default:
+				m.Get(connIDs[i%numConns])
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkPacketHandlerMapGetByResetToken(b *testing.B) {
+	const numTokens = 100000
+	m := newPacketHandlerMap(nil, nil, utils.DefaultLogger)
+	tokens := make([]protocol.StatelessResetToken, numTokens)
+	handler := newClosedRemoteConn(protocol.PerspectiveServer)
+	for i := range tokens {
+		rand.Read(tokens[i][:])
+		m.AddResetToken(tokens[i], handler)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			if _, ok := m.GetByResetToken(tokens[i%numTokens]); !ok {
+				b.Fatal("stateless reset token not found")
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkPacketHandlerMapGetStatelessResetToken(b *testing.B) {
+	var key StatelessResetKey
+	rand.Read(key[:])
+	m := newPacketHandlerMap(&key, nil, utils.DefaultLogger)
+	connIDs := newBenchmarkConnIDs(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			m.GetStatelessResetToken(connIDs[i%len(connIDs)])
+			i++
+		}
+	})
+}
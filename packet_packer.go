@@ -17,6 +17,7 @@ import (
 
 var errNothingToPack = errors.New("nothing to pack")
 
+// The frames of the 0-RTT and 1-RTT packets returned by a packer are only valid until the next packet is packed.
 type packer interface {
 	PackCoalescedPacket(onlyAck bool, maxPacketSize protocol.ByteCount, v protocol.VersionNumber) (*coalescedPacket, error)
 	PackAckOnlyPacket(maxPacketSize protocol.ByteCount, v protocol.VersionNumber) (shortHeaderPacket, *packetBuffer, error)
@@ -38,6 +39,7 @@ type payload struct {
 	frames       []ackhandler.Frame
 	ack          *wire.AckFrame
 	length       protocol.ByteCount
+	dscp         dscpClass // the DSCP class of the streams that the STREAM frames belong to
 }
 
 type longHeaderPacket struct {
@@ -56,6 +58,7 @@ type shortHeaderPacket struct {
 	Ack                  *wire.AckFrame
 	Length               protocol.ByteCount
 	IsPathMTUProbePacket bool
+	DSCP                 dscpClass
 
 	// used for logging
 	DestConnID      protocol.ConnectionID
@@ -106,7 +109,7 @@ type sealingManager interface {
 
 type frameSource interface {
 	HasData() bool
-	AppendStreamFrames([]ackhandler.StreamFrame, protocol.ByteCount, protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount)
+	AppendStreamFrames([]ackhandler.StreamFrame, protocol.ByteCount, protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount, dscpClass)
 	AppendControlFrames([]ackhandler.Frame, protocol.ByteCount, protocol.VersionNumber) ([]ackhandler.Frame, protocol.ByteCount)
 }
 
@@ -134,6 +137,11 @@ type packetPacker struct {
 	rand                rand.Rand
 
 	numNonAckElicitingAcks int
+
+	// Scratch space for the frames of the application data packet that is currently being packed.
+	// The frames returned in a packet are only valid until the next packet is packed.
+	frames       []ackhandler.Frame
+	streamFrames []ackhandler.StreamFrame
 }
 
 var _ packer = &packetPacker{}
@@ -260,7 +268,7 @@ func (p *packetPacker) packConnectionClose(
 		}
 		payloads[i] = pl
 	}
-	buffer := getPacketBuffer()
+	buffer := getPacketBufferForSize(maxPacketSize)
 	packet := &coalescedPacket{
 		buffer:         buffer,
 		longHdrPackets: make([]*longHeaderPacket, 0, numLongHdrPackets),
@@ -404,7 +412,7 @@ func (p *packetPacker) PackCoalescedPacket(onlyAck bool, maxPacketSize protocol.
 		return nil, nil
 	}
 
-	buffer := getPacketBuffer()
+	buffer := getPacketBufferForSize(maxPacketSize)
 	packet := &coalescedPacket{
 		buffer:         buffer,
 		longHdrPackets: make([]*longHeaderPacket, 0, 3),
@@ -443,9 +451,13 @@ func (p *packetPacker) PackCoalescedPacket(onlyAck bool, maxPacketSize protocol.
 // PackAckOnlyPacket packs a packet containing only an ACK in the application data packet number space.
 // It should be called after the handshake is confirmed.
 func (p *packetPacker) PackAckOnlyPacket(maxPacketSize protocol.ByteCount, v protocol.VersionNumber) (shortHeaderPacket, *packetBuffer, error) {
-	buf := getPacketBuffer()
+	buf := getPacketBufferForSize(maxPacketSize)
 	packet, err := p.appendPacket(buf, true, maxPacketSize, v)
-	return packet, buf, err
+	if err != nil {
+		buf.Release()
+		return shortHeaderPacket{}, nil, err
+	}
+	return packet, buf, nil
 }
 
 // AppendPacket packs a packet in the application data packet number space.
@@ -569,7 +581,7 @@ func (p *packetPacker) maybeGetAppDataPacket(maxPayloadSize protocol.ByteCount,
 		// the packet only contains an ACK
 		if p.numNonAckElicitingAcks >= protocol.MaxNonAckElicitingAcks {
 			ping := &wire.PingFrame{}
-			pl.frames = append(pl.frames, ackhandler.Frame{Frame: ping})
+			pl.frames = append(p.frames[:0], ackhandler.Frame{Frame: ping})
 			pl.length += ping.Length(v)
 			p.numNonAckElicitingAcks = 0
 		} else {
@@ -578,6 +590,13 @@ func (p *packetPacker) maybeGetAppDataPacket(maxPayloadSize protocol.ByteCount,
 	} else {
 		p.numNonAckElicitingAcks = 0
 	}
+	// Keep the (potentially grown) slices around, so they can be reused for the next packet.
+	if cap(pl.frames) > cap(p.frames) {
+		p.frames = pl.frames
+	}
+	if cap(pl.streamFrames) > cap(p.streamFrames) {
+		p.streamFrames = pl.streamFrames
+	}
 	return pl
 }
 
@@ -589,7 +608,7 @@ func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount, onlyAc
 		return payload{}
 	}
 
-	pl := payload{streamFrames: make([]ackhandler.StreamFrame, 0, 1)}
+	pl := payload{frames: p.frames[:0], streamFrames: p.streamFrames[:0]}
 
 	hasData := p.framer.HasData()
 	hasRetransmission := p.retransmissionQueue.HasAppData()
@@ -607,7 +626,7 @@ func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount, onlyAc
 		if f := p.datagramQueue.Peek(); f != nil {
 			size := f.Length(v)
 			if size <= maxFrameSize-pl.length { // DATAGRAM frame fits
-				pl.frames = append(pl.frames, ackhandler.Frame{Frame: f})
+				pl.frames = append(pl.frames, ackhandler.Frame{Frame: f, Handler: datagramFrameHandler{}})
 				pl.length += size
 				p.datagramQueue.Pop()
 			} else if !hasAck {
@@ -615,6 +634,7 @@ func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount, onlyAc
 				// Discard this frame. There's no point in retrying this in the next packet,
 				// as it's unlikely that the available packet size will increase.
 				p.datagramQueue.Pop()
+				f.PutBack()
 			}
 			// If the DATAGRAM frame was too large and the packet contained an ACK, we'll try to send it out later.
 		}
@@ -655,7 +675,7 @@ func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount, onlyAc
 			}
 		}
 
-		pl.streamFrames, lengthAdded = p.framer.AppendStreamFrames(pl.streamFrames, maxFrameSize-pl.length, v)
+		pl.streamFrames, lengthAdded, pl.dscp = p.framer.AppendStreamFrames(pl.streamFrames, maxFrameSize-pl.length, v)
 		pl.length += lengthAdded
 	}
 	return pl
@@ -675,7 +695,7 @@ func (p *packetPacker) MaybePackProbePacket(encLevel protocol.EncryptionLevel, m
 		if pl.length == 0 {
 			return nil, nil
 		}
-		buffer := getPacketBuffer()
+		buffer := getPacketBufferForSize(maxPacketSize)
 		packet := &coalescedPacket{buffer: buffer}
 		shp, err := p.appendShortHeaderPacket(buffer, connID, pn, pnLen, kp, pl, 0, maxPacketSize, s, false, v)
 		if err != nil {
@@ -711,7 +731,7 @@ func (p *packetPacker) MaybePackProbePacket(encLevel protocol.EncryptionLevel, m
 	if pl.length == 0 {
 		return nil, nil
 	}
-	buffer := getPacketBuffer()
+	buffer := getPacketBufferForSize(maxPacketSize)
 	packet := &coalescedPacket{buffer: buffer}
 	size := p.longHeaderPacketLength(hdr, pl, v) + protocol.ByteCount(sealer.Overhead())
 	var padding protocol.ByteCount
@@ -732,7 +752,7 @@ func (p *packetPacker) PackMTUProbePacket(ping ackhandler.Frame, size protocol.B
 		frames: []ackhandler.Frame{ping},
 		length: ping.Frame.Length(v),
 	}
-	buffer := getPacketBuffer()
+	buffer := getPacketBufferForSize(size)
 	s, err := p.cryptoSetup.Get1RTTSealer()
 	if err != nil {
 		return shortHeaderPacket{}, nil, err
@@ -855,6 +875,7 @@ func (p *packetPacker) appendShortHeaderPacket(
 		Length:               protocol.ByteCount(len(raw)),
 		DestConnID:           connID,
 		IsPathMTUProbePacket: isMTUProbePacket,
+		DSCP:                 pl.dscp,
 	}, nil
 }
 
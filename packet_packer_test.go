@@ -65,12 +65,12 @@ var _ = Describe("Packet packer", func() {
 	}
 
 	expectAppendStreamFrames := func(frames ...ackhandler.StreamFrame) {
-		framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(fs []ackhandler.StreamFrame, _ protocol.ByteCount, v protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount) {
+		framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(fs []ackhandler.StreamFrame, _ protocol.ByteCount, v protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount, dscpClass) {
 			var length protocol.ByteCount
 			for _, f := range frames {
 				length += f.Frame.Length(v)
 			}
-			return append(fs, frames...), length
+			return append(fs, frames...), length, dscpClassDefault
 		})
 	}
 
@@ -115,6 +115,15 @@ var _ = Describe("Packet packer", func() {
 			addr := &net.UDPAddr{IP: ip, Port: 1337}
 			Expect(getMaxPacketSize(addr)).To(BeEquivalentTo(protocol.InitialPacketSizeIPv6))
 		})
+
+		It("uses the configured initial packet size", func() {
+			addr := &net.UDPAddr{IP: net.IPv4(11, 12, 13, 14), Port: 1337}
+			Expect(getInitialPacketSize(addr, populateConfig(&Config{}))).To(BeEquivalentTo(protocol.InitialPacketSizeIPv4))
+			Expect(getInitialPacketSize(addr, populateConfig(&Config{InitialPacketSize: 1400}))).To(BeEquivalentTo(1400))
+			Expect(getInitialPacketSize(addr, populateConfig(&Config{InitialPacketSize: 8000, MaxPacketSize: 9000}))).To(BeEquivalentTo(8000))
+			// the initial packet size is never larger than the maximum packet size
+			Expect(getInitialPacketSize(addr, populateConfig(&Config{MaxPacketSize: 1220}))).To(BeEquivalentTo(1220))
+		})
 	})
 
 	Context("generating a packet header", func() {
@@ -306,8 +315,8 @@ var _ = Describe("Packet packer", func() {
 					return append(frames, cf), cf.Frame.Length(v)
 				})
 				// TODO: check sizes
-				framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any(), protocol.Version1).DoAndReturn(func(frames []ackhandler.StreamFrame, _ protocol.ByteCount, _ protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount) {
-					return frames, 0
+				framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any(), protocol.Version1).DoAndReturn(func(frames []ackhandler.StreamFrame, _ protocol.ByteCount, _ protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount, dscpClass) {
+					return frames, 0, dscpClassDefault
 				})
 				p, err := packer.PackCoalescedPacket(false, maxPacketSize, protocol.Version1)
 				Expect(p).ToNot(BeNil())
@@ -525,6 +534,23 @@ var _ = Describe("Packet packer", func() {
 				Expect(buffer.Data).To(ContainSubstring(string(b)))
 			})
 
+			It("sets the DSCP class of the STREAM frames", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+				framer.EXPECT().HasData().Return(true)
+				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, false)
+				expectAppendControlFrames()
+				f := &wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}
+				framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any(), protocol.Version1).DoAndReturn(func(fs []ackhandler.StreamFrame, _ protocol.ByteCount, v protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount, dscpClass) {
+					return append(fs, ackhandler.StreamFrame{Frame: f}), f.Length(v), newDSCPClass(46)
+				})
+				p, err := packer.AppendPacket(getPacketBuffer(), maxPacketSize, protocol.Version1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.StreamFrames).To(HaveLen(1))
+				Expect(p.DSCP).To(Equal(newDSCPClass(46)))
+			})
+
 			It("packs a single ACK", func() {
 				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
 				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
@@ -613,6 +639,7 @@ var _ = Describe("Packet packer", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.Frames).To(HaveLen(1))
 				Expect(p.Frames[0].Frame).To(Equal(f))
+				Expect(p.Frames[0].Handler).To(Equal(datagramFrameHandler{}))
 				Expect(buffer.Data).ToNot(BeEmpty())
 				Eventually(done).Should(BeClosed())
 			})
@@ -685,9 +712,9 @@ var _ = Describe("Packet packer", func() {
 						maxSize = maxLen
 						return fs, 444
 					}),
-					framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any(), protocol.Version1).Do(func(fs []ackhandler.StreamFrame, maxLen protocol.ByteCount, _ protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount) {
+					framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any(), protocol.Version1).Do(func(fs []ackhandler.StreamFrame, maxLen protocol.ByteCount, _ protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount, dscpClass) {
 						Expect(maxLen).To(Equal(maxSize - 444))
-						return fs, 0
+						return fs, 0, dscpClassDefault
 					}),
 				)
 				_, err := packer.AppendPacket(getPacketBuffer(), maxPacketSize, protocol.Version1)
@@ -1491,10 +1518,10 @@ var _ = Describe("Packet packer", func() {
 				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
 				framer.EXPECT().HasData().Return(true)
 				expectAppendControlFrames()
-				framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any(), protocol.Version1).DoAndReturn(func(fs []ackhandler.StreamFrame, maxSize protocol.ByteCount, v protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount) {
+				framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any(), protocol.Version1).DoAndReturn(func(fs []ackhandler.StreamFrame, maxSize protocol.ByteCount, v protocol.VersionNumber) ([]ackhandler.StreamFrame, protocol.ByteCount, dscpClass) {
 					sf, split := f.MaybeSplitOffFrame(maxSize, v)
 					Expect(split).To(BeTrue())
-					return append(fs, ackhandler.StreamFrame{Frame: sf}), sf.Length(v)
+					return append(fs, ackhandler.StreamFrame{Frame: sf}), sf.Length(v), dscpClassDefault
 				})
 
 				p, err := packer.MaybePackProbePacket(protocol.Encryption1RTT, maxPacketSize, protocol.Version1)
@@ -0,0 +1,52 @@
+package quic
+
+import "fmt"
+
+// maxUrgency is the least urgent value for StreamPriority.Urgency.
+const maxUrgency = 7
+
+// defaultUrgency is the urgency of streams that don't have a priority set.
+const defaultUrgency = 3
+
+// A StreamPriority determines the order in which the data of streams is sent.
+// It uses the priority parameters defined in RFC 9218, but is not specific to HTTP.
+type StreamPriority struct {
+	// Urgency is a value between 0 (most urgent) and 7 (least urgent).
+	// Data of a stream is only sent if no stream with a lower urgency has data to send.
+	Urgency uint8
+	// Incremental streams of the same urgency share the available bandwidth (round-robin).
+	// Non-incremental streams are sent one after the other, in the order they became ready to send.
+	Incremental bool
+}
+
+func validatePriority(p StreamPriority) error {
+	if p.Urgency > maxUrgency {
+		return fmt.Errorf("invalid urgency: %d", p.Urgency)
+	}
+	return nil
+}
+
+// A streamPriority is the priority that the framer schedules a stream with.
+// The zero value is the priority of streams that don't have a priority set:
+// they have the default urgency, and are incremental.
+// This matches the round-robin scheduling of streams without a priority.
+type streamPriority uint8
+
+const streamPriorityDefault streamPriority = 0
+
+func newStreamPriority(p StreamPriority) streamPriority {
+	v := streamPriority(p.Urgency+1) << 1
+	if !p.Incremental {
+		v |= 1
+	}
+	return v
+}
+
+func (p streamPriority) urgency() uint8 {
+	if p == streamPriorityDefault {
+		return defaultUrgency
+	}
+	return uint8(p>>1) - 1
+}
+
+func (p streamPriority) incremental() bool { return p&1 == 0 }
@@ -2,7 +2,6 @@ package qlog
 
 import (
 	"bytes"
-	"io"
 	"net"
 	"net/netip"
 	"testing"
@@ -17,14 +16,6 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-type nopWriteCloserImpl struct{ io.Writer }
-
-func (nopWriteCloserImpl) Close() error { return nil }
-
-func nopWriteCloser(w io.Writer) io.WriteCloser {
-	return &nopWriteCloserImpl{Writer: w}
-}
-
 func newConnectionTracer() (*logging.ConnectionTracer, *bytes.Buffer) {
 	buf := &bytes.Buffer{}
 	tracer := NewConnectionTracer(
@@ -41,7 +32,7 @@ func TestConnectionTraceMetadata(t *testing.T) {
 
 	m := make(map[string]interface{})
 	require.NoError(t, unmarshal(buf.Bytes(), &m))
-	require.Equal(t, "0.3", m["qlog_version"])
+	require.Equal(t, "draft-02", m["qlog_version"])
 	require.Contains(t, m, "title")
 	require.Contains(t, m, "trace")
 	trace := m["trace"].(map[string]interface{})
@@ -94,7 +85,7 @@ func TestVersionNegotiation(t *testing.T) {
 
 func TestVersionNegotiationWithPriorAttempts(t *testing.T) {
 	tracer, buf := newConnectionTracer()
-	tracer.NegotiatedVersion(0x1337, []logging.Version{1, 2, 3}, []logging.Version{4, 5, 6})
+	tracer.NegotiatedVersion(0x1337, []logging.VersionNumber{1, 2, 3}, []logging.VersionNumber{4, 5, 6})
 	tracer.Close()
 	entry := exportAndParseSingle(t, buf)
 	require.WithinDuration(t, time.Now(), entry.Time, scaleDuration(10*time.Millisecond))
@@ -533,7 +524,7 @@ func TestReceivedVersionNegotiationPacket(t *testing.T) {
 	tracer.ReceivedVersionNegotiationPacket(
 		protocol.ArbitraryLenConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
 		protocol.ArbitraryLenConnectionID{4, 3, 2, 1},
-		[]protocol.Version{0xdeadbeef, 0xdecafbad},
+		[]protocol.VersionNumber{0xdeadbeef, 0xdecafbad},
 	)
 	tracer.Close()
 	entry := exportAndParseSingle(t, buf)
@@ -718,7 +709,7 @@ func TestTLSKeyUpdates(t *testing.T) {
 	ev := entry.Event
 	require.Equal(t, "client_handshake_secret", ev["key_type"])
 	require.Equal(t, "tls", ev["trigger"])
-	require.NotContains(t, ev, "key_phase")
+	require.NotContains(t, ev, "generation")
 	require.NotContains(t, ev, "old")
 	require.NotContains(t, ev, "new")
 }
@@ -733,7 +724,7 @@ func TestTLSKeyUpdatesFor1RTTKeys(t *testing.T) {
 	ev := entry.Event
 	require.Equal(t, "server_1rtt_secret", ev["key_type"])
 	require.Equal(t, "tls", ev["trigger"])
-	require.Equal(t, float64(0), ev["key_phase"])
+	require.Equal(t, float64(0), ev["generation"])
 	require.NotContains(t, ev, "old")
 	require.NotContains(t, ev, "new")
 }
@@ -749,7 +740,7 @@ func TestQUICKeyUpdates(t *testing.T) {
 		require.WithinDuration(t, time.Now(), entry.Time, scaleDuration(10*time.Millisecond))
 		require.Equal(t, "security:key_updated", entry.Name)
 		ev := entry.Event
-		require.Equal(t, float64(1337), ev["key_phase"])
+		require.Equal(t, float64(1337), ev["generation"])
 		require.Equal(t, "remote_update", ev["trigger"])
 		require.Contains(t, ev, "key_type")
 		keyTypes = append(keyTypes, ev["key_type"].(string))
@@ -802,7 +793,7 @@ func TestDroppedKeys(t *testing.T) {
 		require.WithinDuration(t, time.Now(), entry.Time, scaleDuration(10*time.Millisecond))
 		require.Equal(t, "security:key_discarded", entry.Name)
 		ev := entry.Event
-		require.Equal(t, float64(42), ev["key_phase"])
+		require.Equal(t, float64(42), ev["generation"])
 		require.NotContains(t, ev, "trigger")
 		require.Contains(t, ev, "key_type")
 		keyTypes = append(keyTypes, ev["key_type"].(string))
@@ -0,0 +1,279 @@
+package qlog
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/logging"
+
+	"github.com/francoispqt/gojay"
+)
+
+var (
+	http3TracersMutex sync.Mutex
+	http3Tracers      map[uint64]*HTTP3Tracer // indexed by the connection tracing ID
+)
+
+func tracingIDFromContext(ctx context.Context) (uint64, bool) {
+	id, ok := ctx.Value(quic.ConnectionTracingKey).(uint64)
+	return id, ok
+}
+
+func registerHTTP3Tracer(tracingID uint64, t *HTTP3Tracer) {
+	http3TracersMutex.Lock()
+	defer http3TracersMutex.Unlock()
+
+	if http3Tracers == nil {
+		http3Tracers = make(map[uint64]*HTTP3Tracer)
+	}
+	http3Tracers[tracingID] = t
+}
+
+func unregisterHTTP3Tracer(tracingID uint64) {
+	http3TracersMutex.Lock()
+	defer http3TracersMutex.Unlock()
+
+	delete(http3Tracers, tracingID)
+}
+
+// HTTP3TracerFromContext returns the HTTP3Tracer for the QUIC connection,
+// identified by the quic.ConnectionTracingKey on ctx (usually the context returned by quic.Connection.Context).
+// Events are written into the same qlog trace as the transport events of that connection.
+// It returns nil if no qlog is recorded for this connection,
+// or if the connection tracer was created without the connection's context,
+// i.e. by NewConnectionTracer instead of NewConnectionTracerWithContext.
+// All methods of the HTTP3Tracer can be called on a nil HTTP3Tracer.
+func HTTP3TracerFromContext(ctx context.Context) *HTTP3Tracer {
+	tracingID, ok := tracingIDFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	http3TracersMutex.Lock()
+	defer http3TracersMutex.Unlock()
+
+	return http3Tracers[tracingID]
+}
+
+// An HTTP3Tracer records HTTP/3 and QPACK events, as defined in draft-ietf-quic-qlog-h3-events.
+type HTTP3Tracer struct {
+	t *connectionTracer
+}
+
+// HTTP3Parameters are the HTTP/3 settings used on a connection.
+type HTTP3Parameters struct {
+	MaxFieldSectionSize   uint64
+	MaxTableCapacity      uint64
+	BlockedStreamsCount   uint64
+	EnableConnectProtocol bool
+	EnableDatagrams       bool
+}
+
+// QPACKState is the state of a QPACK encoder or decoder.
+type QPACKState struct {
+	DynamicTableCapacity uint64
+	DynamicTableSize     uint64
+	KnownReceivedCount   uint64
+	CurrentInsertCount   uint64
+}
+
+func ownerFromBool(local bool) owner {
+	if local {
+		return ownerLocal
+	}
+	return ownerRemote
+}
+
+// ParametersSet records the HTTP/3 settings used by the local or the remote endpoint.
+func (t *HTTP3Tracer) ParametersSet(local bool, p *HTTP3Parameters) {
+	if t == nil {
+		return
+	}
+	t.recordEvent(&eventHTTP3ParametersSet{Owner: ownerFromBool(local), Parameters: *p})
+}
+
+// StreamTypeSet records the type of a unidirectional stream, as encoded in the first bytes of the stream.
+func (t *HTTP3Tracer) StreamTypeSet(local bool, id logging.StreamID, streamType uint64) {
+	if t == nil {
+		return
+	}
+	t.recordEvent(&eventHTTP3StreamTypeSet{
+		Owner:      ownerFromBool(local),
+		StreamID:   id,
+		StreamType: streamType,
+	})
+}
+
+// FrameCreated records an HTTP/3 frame that was written to a stream.
+// The length is the length of the frame payload.
+func (t *HTTP3Tracer) FrameCreated(id logging.StreamID, length uint64, f HTTP3Frame) {
+	if t == nil {
+		return
+	}
+	t.recordEvent(&eventHTTP3Frame{StreamID: id, Length: length, Frame: f})
+}
+
+// FrameParsed records an HTTP/3 frame that was read from a stream.
+// The length is the length of the frame payload.
+func (t *HTTP3Tracer) FrameParsed(id logging.StreamID, length uint64, f HTTP3Frame) {
+	if t == nil {
+		return
+	}
+	t.recordEvent(&eventHTTP3Frame{Parsed: true, StreamID: id, Length: length, Frame: f})
+}
+
+// PushResolved records that a pushed response was either claimed by a request, or abandoned.
+func (t *HTTP3Tracer) PushResolved(pushID uint64, id logging.StreamID, claimed bool) {
+	if t == nil {
+		return
+	}
+	t.recordEvent(&eventHTTP3PushResolved{PushID: pushID, StreamID: id, Claimed: claimed})
+}
+
+// PriorityUpdated records a change of the priority of a request stream.
+// The priorities are encoded as Priority Field Values, see RFC 9218.
+func (t *HTTP3Tracer) PriorityUpdated(id logging.StreamID, oldPriority, newPriority string) {
+	if t == nil {
+		return
+	}
+	t.recordEvent(&eventHTTP3PriorityUpdated{StreamID: id, Old: oldPriority, New: newPriority})
+}
+
+// QPACKStateUpdated records the state of the local or the remote QPACK encoder.
+func (t *HTTP3Tracer) QPACKStateUpdated(local bool, s *QPACKState) {
+	if t == nil {
+		return
+	}
+	t.recordEvent(&eventQPACKStateUpdated{Owner: ownerFromBool(local), State: *s})
+}
+
+func (t *HTTP3Tracer) recordEvent(details eventDetails) {
+	t.t.mutex.Lock()
+	t.t.recordEvent(time.Now(), details)
+	t.t.mutex.Unlock()
+}
+
+type eventHTTP3ParametersSet struct {
+	Owner      owner
+	Parameters HTTP3Parameters
+}
+
+func (e eventHTTP3ParametersSet) Category() category { return categoryHTTP }
+func (e eventHTTP3ParametersSet) Name() string       { return "parameters_set" }
+func (e eventHTTP3ParametersSet) IsNil() bool        { return false }
+
+func (e eventHTTP3ParametersSet) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.StringKey("owner", e.Owner.String())
+	enc.Uint64KeyOmitEmpty("max_field_section_size", e.Parameters.MaxFieldSectionSize)
+	enc.Uint64Key("max_table_capacity", e.Parameters.MaxTableCapacity)
+	enc.Uint64Key("blocked_streams_count", e.Parameters.BlockedStreamsCount)
+	enc.BoolKey("enable_connect_protocol", e.Parameters.EnableConnectProtocol)
+	enc.BoolKey("h3_datagram", e.Parameters.EnableDatagrams)
+}
+
+type eventHTTP3StreamTypeSet struct {
+	Owner      owner
+	StreamID   logging.StreamID
+	StreamType uint64
+}
+
+func (e eventHTTP3StreamTypeSet) Category() category { return categoryHTTP }
+func (e eventHTTP3StreamTypeSet) Name() string       { return "stream_type_set" }
+func (e eventHTTP3StreamTypeSet) IsNil() bool        { return false }
+
+func (e eventHTTP3StreamTypeSet) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.StringKey("owner", e.Owner.String())
+	enc.Int64Key("stream_id", int64(e.StreamID))
+	switch e.StreamType {
+	case 0x0:
+		enc.StringKey("stream_type", "control")
+	case 0x1:
+		enc.StringKey("stream_type", "push")
+	case 0x2:
+		enc.StringKey("stream_type", "qpack_encode")
+	case 0x3:
+		enc.StringKey("stream_type", "qpack_decode")
+	default:
+		if isReservedHTTP3Type(e.StreamType) {
+			enc.StringKey("stream_type", "reserved")
+		} else {
+			enc.StringKey("stream_type", "unknown")
+		}
+		enc.Uint64Key("stream_type_value", e.StreamType)
+	}
+}
+
+type eventHTTP3Frame struct {
+	Parsed   bool
+	StreamID logging.StreamID
+	Length   uint64
+	Frame    HTTP3Frame
+}
+
+func (e eventHTTP3Frame) Category() category { return categoryHTTP }
+func (e eventHTTP3Frame) Name() string {
+	if e.Parsed {
+		return "frame_parsed"
+	}
+	return "frame_created"
+}
+func (e eventHTTP3Frame) IsNil() bool { return false }
+
+func (e eventHTTP3Frame) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.Int64Key("stream_id", int64(e.StreamID))
+	enc.Uint64Key("length", e.Length)
+	enc.ObjectKey("frame", http3Frame{Frame: e.Frame})
+}
+
+type eventHTTP3PushResolved struct {
+	PushID   uint64
+	StreamID logging.StreamID
+	Claimed  bool
+}
+
+func (e eventHTTP3PushResolved) Category() category { return categoryHTTP }
+func (e eventHTTP3PushResolved) Name() string       { return "push_resolved" }
+func (e eventHTTP3PushResolved) IsNil() bool        { return false }
+
+func (e eventHTTP3PushResolved) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.Uint64Key("push_id", e.PushID)
+	enc.Int64Key("stream_id", int64(e.StreamID))
+	if e.Claimed {
+		enc.StringKey("decision", "claimed")
+	} else {
+		enc.StringKey("decision", "abandoned")
+	}
+}
+
+type eventHTTP3PriorityUpdated struct {
+	StreamID logging.StreamID
+	Old, New string
+}
+
+func (e eventHTTP3PriorityUpdated) Category() category { return categoryHTTP }
+func (e eventHTTP3PriorityUpdated) Name() string       { return "priority_updated" }
+func (e eventHTTP3PriorityUpdated) IsNil() bool        { return false }
+
+func (e eventHTTP3PriorityUpdated) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.Int64Key("stream_id", int64(e.StreamID))
+	enc.StringKeyOmitEmpty("old", e.Old)
+	enc.StringKey("new", e.New)
+}
+
+type eventQPACKStateUpdated struct {
+	Owner owner
+	State QPACKState
+}
+
+func (e eventQPACKStateUpdated) Category() category { return categoryQPACK }
+func (e eventQPACKStateUpdated) Name() string       { return "state_updated" }
+func (e eventQPACKStateUpdated) IsNil() bool        { return false }
+
+func (e eventQPACKStateUpdated) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.StringKey("owner", e.Owner.String())
+	enc.Uint64Key("dynamic_table_capacity", e.State.DynamicTableCapacity)
+	enc.Uint64Key("dynamic_table_size", e.State.DynamicTableSize)
+	enc.Uint64Key("known_received_count", e.State.KnownReceivedCount)
+	enc.Uint64Key("current_insert_count", e.State.CurrentInsertCount)
+}
@@ -0,0 +1,191 @@
+package qlog
+
+import (
+	"fmt"
+
+	"github.com/francoispqt/gojay"
+)
+
+// An HTTP3Frame is an HTTP/3 frame, as defined in draft-ietf-quic-qlog-h3-events.
+type HTTP3Frame interface {
+	isHTTP3Frame()
+}
+
+// An HTTP3HeaderField is a header field carried in a HEADERS or a PUSH_PROMISE frame.
+type HTTP3HeaderField struct {
+	Name  string
+	Value string
+}
+
+// An HTTP3Setting is a setting carried in a SETTINGS frame.
+type HTTP3Setting struct {
+	ID    uint64
+	Value uint64
+}
+
+// An HTTP3DataFrame is a DATA frame.
+type HTTP3DataFrame struct{}
+
+// An HTTP3HeadersFrame is a HEADERS frame.
+type HTTP3HeadersFrame struct {
+	HeaderFields []HTTP3HeaderField
+}
+
+// An HTTP3SettingsFrame is a SETTINGS frame.
+type HTTP3SettingsFrame struct {
+	Settings []HTTP3Setting
+}
+
+// An HTTP3GoAwayFrame is a GOAWAY frame.
+// The ID is a stream ID when sent by the server, and a push ID when sent by the client.
+type HTTP3GoAwayFrame struct {
+	ID uint64
+}
+
+// An HTTP3CancelPushFrame is a CANCEL_PUSH frame.
+type HTTP3CancelPushFrame struct {
+	PushID uint64
+}
+
+// An HTTP3MaxPushIDFrame is a MAX_PUSH_ID frame.
+type HTTP3MaxPushIDFrame struct {
+	PushID uint64
+}
+
+// An HTTP3PushPromiseFrame is a PUSH_PROMISE frame.
+type HTTP3PushPromiseFrame struct {
+	PushID       uint64
+	HeaderFields []HTTP3HeaderField
+}
+
+// An HTTP3PriorityUpdateFrame is a PRIORITY_UPDATE frame, see RFC 9218.
+type HTTP3PriorityUpdateFrame struct {
+	// IsPush is set if the frame prioritizes a push stream.
+	IsPush bool
+	// ElementID is the stream ID of the request stream, or the push ID.
+	ElementID          uint64
+	PriorityFieldValue string
+}
+
+// An HTTP3UnknownFrame is a frame of a type not defined by HTTP/3 or any of the extensions we implement.
+type HTTP3UnknownFrame struct {
+	FrameType uint64
+}
+
+func (*HTTP3DataFrame) isHTTP3Frame()           {}
+func (*HTTP3HeadersFrame) isHTTP3Frame()        {}
+func (*HTTP3SettingsFrame) isHTTP3Frame()       {}
+func (*HTTP3GoAwayFrame) isHTTP3Frame()         {}
+func (*HTTP3CancelPushFrame) isHTTP3Frame()     {}
+func (*HTTP3MaxPushIDFrame) isHTTP3Frame()      {}
+func (*HTTP3PushPromiseFrame) isHTTP3Frame()    {}
+func (*HTTP3PriorityUpdateFrame) isHTTP3Frame() {}
+func (*HTTP3UnknownFrame) isHTTP3Frame()        {}
+
+type http3Frame struct {
+	Frame HTTP3Frame
+}
+
+var _ gojay.MarshalerJSONObject = http3Frame{}
+
+func (f http3Frame) IsNil() bool { return false }
+func (f http3Frame) MarshalJSONObject(enc *gojay.Encoder) {
+	switch frame := f.Frame.(type) {
+	case *HTTP3DataFrame:
+		enc.StringKey("frame_type", "data")
+	case *HTTP3HeadersFrame:
+		enc.StringKey("frame_type", "headers")
+		enc.ArrayKey("headers", http3HeaderFields(frame.HeaderFields))
+	case *HTTP3SettingsFrame:
+		enc.StringKey("frame_type", "settings")
+		enc.ArrayKey("settings", http3Settings(frame.Settings))
+	case *HTTP3GoAwayFrame:
+		enc.StringKey("frame_type", "goaway")
+		enc.Uint64Key("id", frame.ID)
+	case *HTTP3CancelPushFrame:
+		enc.StringKey("frame_type", "cancel_push")
+		enc.Uint64Key("push_id", frame.PushID)
+	case *HTTP3MaxPushIDFrame:
+		enc.StringKey("frame_type", "max_push_id")
+		enc.Uint64Key("push_id", frame.PushID)
+	case *HTTP3PushPromiseFrame:
+		enc.StringKey("frame_type", "push_promise")
+		enc.Uint64Key("push_id", frame.PushID)
+		enc.ArrayKey("headers", http3HeaderFields(frame.HeaderFields))
+	case *HTTP3PriorityUpdateFrame:
+		enc.StringKey("frame_type", "priority_update")
+		if frame.IsPush {
+			enc.StringKey("prioritized_element_type", "push_stream")
+		} else {
+			enc.StringKey("prioritized_element_type", "request_stream")
+		}
+		enc.Uint64Key("element_id", frame.ElementID)
+		enc.StringKey("priority_field_value", frame.PriorityFieldValue)
+	case *HTTP3UnknownFrame:
+		if isReservedHTTP3Type(frame.FrameType) {
+			enc.StringKey("frame_type", "reserved")
+		} else {
+			enc.StringKey("frame_type", "unknown")
+		}
+		enc.Uint64Key("frame_type_value", frame.FrameType)
+	default:
+		panic("unknown HTTP/3 frame type")
+	}
+}
+
+type http3HeaderFields []HTTP3HeaderField
+
+func (h http3HeaderFields) IsNil() bool { return false }
+func (h http3HeaderFields) MarshalJSONArray(enc *gojay.Encoder) {
+	for _, f := range h {
+		enc.Object(http3HeaderField(f))
+	}
+}
+
+type http3HeaderField HTTP3HeaderField
+
+func (f http3HeaderField) IsNil() bool { return false }
+func (f http3HeaderField) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.StringKey("name", f.Name)
+	enc.StringKey("value", f.Value)
+}
+
+type http3Settings []HTTP3Setting
+
+func (s http3Settings) IsNil() bool { return false }
+func (s http3Settings) MarshalJSONArray(enc *gojay.Encoder) {
+	for _, setting := range s {
+		enc.Object(http3Setting(setting))
+	}
+}
+
+type http3Setting HTTP3Setting
+
+func (s http3Setting) IsNil() bool { return false }
+func (s http3Setting) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.StringKey("name", http3SettingName(s.ID))
+	enc.Uint64Key("value", s.Value)
+}
+
+func http3SettingName(id uint64) string {
+	switch id {
+	case 0x1:
+		return "settings_qpack_max_table_capacity"
+	case 0x6:
+		return "settings_max_field_section_size"
+	case 0x7:
+		return "settings_qpack_blocked_streams"
+	case 0x8:
+		return "settings_enable_connect_protocol"
+	case 0x33:
+		return "settings_h3_datagram"
+	default:
+		return fmt.Sprintf("unknown (%#x)", id)
+	}
+}
+
+// isReservedHTTP3Type says if a frame type, stream type or setting is reserved for greasing,
+// see section 7.2.8 of RFC 9114.
+func isReservedHTTP3Type(t uint64) bool {
+	return t >= 0x21 && (t-0x21)%0x1f == 0
+}
@@ -0,0 +1,127 @@
+package qlog
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/internal/protocol"
+	"github.com/nxenon/xquic-go/logging"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newHTTP3Tracer(t *testing.T, tracingID uint64) (*logging.ConnectionTracer, *HTTP3Tracer, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	ctx := context.WithValue(context.Background(), quic.ConnectionTracingKey, tracingID)
+	tracer := NewConnectionTracerWithContext(
+		ctx,
+		nopWriteCloser(buf),
+		logging.PerspectiveServer,
+		protocol.ParseConnectionID([]byte{0xde, 0xad, 0xbe, 0xef}),
+	)
+	h3Tracer := HTTP3TracerFromContext(ctx)
+	require.NotNil(t, h3Tracer)
+	return tracer, h3Tracer, buf
+}
+
+func TestHTTP3TracerLookup(t *testing.T) {
+	require.Nil(t, HTTP3TracerFromContext(context.Background()))
+
+	tracer, h3Tracer, _ := newHTTP3Tracer(t, 1337)
+	ctx := context.WithValue(context.Background(), quic.ConnectionTracingKey, uint64(1337))
+	require.Same(t, h3Tracer, HTTP3TracerFromContext(ctx))
+	tracer.Close()
+	require.Nil(t, HTTP3TracerFromContext(ctx))
+	// recording events after the connection tracer was closed is a no-op
+	h3Tracer.FrameCreated(0, 0, &HTTP3DataFrame{})
+}
+
+func TestHTTP3TracerNil(t *testing.T) {
+	var tracer *HTTP3Tracer
+	tracer.FrameParsed(0, 42, &HTTP3DataFrame{})
+	tracer.StreamTypeSet(true, 2, 0)
+	tracer.ParametersSet(true, &HTTP3Parameters{})
+}
+
+func TestHTTP3StreamTypeSet(t *testing.T) {
+	tracer, h3Tracer, buf := newHTTP3Tracer(t, 1)
+	h3Tracer.StreamTypeSet(true, 3, 0x0)
+	h3Tracer.StreamTypeSet(false, 7, 0x2)
+	h3Tracer.StreamTypeSet(false, 11, 0x21+0x1f)
+	tracer.Close()
+	entries := exportAndParse(t, buf)
+	require.Len(t, entries, 3)
+	require.Equal(t, "http:stream_type_set", entries[0].Name)
+	require.Equal(t, "local", entries[0].Event["owner"])
+	require.Equal(t, float64(3), entries[0].Event["stream_id"])
+	require.Equal(t, "control", entries[0].Event["stream_type"])
+	require.Equal(t, "remote", entries[1].Event["owner"])
+	require.Equal(t, "qpack_encode", entries[1].Event["stream_type"])
+	require.Equal(t, "reserved", entries[2].Event["stream_type"])
+	require.Equal(t, float64(0x21+0x1f), entries[2].Event["stream_type_value"])
+}
+
+func TestHTTP3ParametersSet(t *testing.T) {
+	tracer, h3Tracer, buf := newHTTP3Tracer(t, 2)
+	h3Tracer.ParametersSet(false, &HTTP3Parameters{
+		MaxFieldSectionSize: 1234,
+		EnableDatagrams:     true,
+	})
+	tracer.Close()
+	entry := exportAndParseSingle(t, buf)
+	require.Equal(t, "http:parameters_set", entry.Name)
+	require.Equal(t, "remote", entry.Event["owner"])
+	require.Equal(t, float64(1234), entry.Event["max_field_section_size"])
+	require.Equal(t, float64(0), entry.Event["max_table_capacity"])
+	require.Equal(t, true, entry.Event["h3_datagram"])
+	require.Equal(t, false, entry.Event["enable_connect_protocol"])
+}
+
+func TestHTTP3FrameCreated(t *testing.T) {
+	tracer, h3Tracer, buf := newHTTP3Tracer(t, 3)
+	h3Tracer.FrameCreated(4, 42, &HTTP3HeadersFrame{
+		HeaderFields: []HTTP3HeaderField{{Name: ":status", Value: "200"}},
+	})
+	tracer.Close()
+	entry := exportAndParseSingle(t, buf)
+	require.Equal(t, "http:frame_created", entry.Name)
+	require.Equal(t, float64(4), entry.Event["stream_id"])
+	require.Equal(t, float64(42), entry.Event["length"])
+	f := entry.Event["frame"].(map[string]interface{})
+	require.Equal(t, "headers", f["frame_type"])
+	require.Equal(t, []interface{}{map[string]interface{}{"name": ":status", "value": "200"}}, f["headers"])
+}
+
+func TestHTTP3FrameParsed(t *testing.T) {
+	tracer, h3Tracer, buf := newHTTP3Tracer(t, 4)
+	h3Tracer.FrameParsed(2, 6, &HTTP3SettingsFrame{
+		Settings: []HTTP3Setting{{ID: 0x33, Value: 1}, {ID: 0x1337, Value: 42}},
+	})
+	h3Tracer.FrameParsed(2, 1, &HTTP3GoAwayFrame{ID: 8})
+	tracer.Close()
+	entries := exportAndParse(t, buf)
+	require.Len(t, entries, 2)
+	require.Equal(t, "http:frame_parsed", entries[0].Name)
+	f := entries[0].Event["frame"].(map[string]interface{})
+	require.Equal(t, "settings", f["frame_type"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"name": "settings_h3_datagram", "value": float64(1)},
+		map[string]interface{}{"name": "unknown (0x1337)", "value": float64(42)},
+	}, f["settings"])
+	f = entries[1].Event["frame"].(map[string]interface{})
+	require.Equal(t, "goaway", f["frame_type"])
+	require.Equal(t, float64(8), f["id"])
+}
+
+func TestQPACKStateUpdated(t *testing.T) {
+	tracer, h3Tracer, buf := newHTTP3Tracer(t, 5)
+	h3Tracer.QPACKStateUpdated(true, &QPACKState{DynamicTableCapacity: 4096, CurrentInsertCount: 3})
+	tracer.Close()
+	entry := exportAndParseSingle(t, buf)
+	require.Equal(t, "qpack:state_updated", entry.Name)
+	require.Equal(t, "local", entry.Event["owner"])
+	require.Equal(t, float64(4096), entry.Event["dynamic_table_capacity"])
+	require.Equal(t, float64(3), entry.Event["current_insert_count"])
+}
@@ -30,44 +30,6 @@ func unmarshal(data []byte, v interface{}) error {
 	return json.Unmarshal(data, v)
 }
 
-func checkEncoding(t *testing.T, data []byte, expected map[string]interface{}) {
-	m := make(map[string]interface{})
-	require.NoError(t, json.Unmarshal(data, &m))
-	require.Len(t, m, len(expected))
-
-	for key, value := range expected {
-		switch v := value.(type) {
-		case bool, string, map[string]interface{}:
-			require.Equal(t, v, m[key])
-		case int:
-			require.Equal(t, float64(v), m[key])
-		case [][]float64: // used in the ACK frame
-			require.Contains(t, m, key)
-			outerSlice, ok := m[key].([]interface{})
-			require.True(t, ok)
-			require.Len(t, outerSlice, len(v))
-			for i, innerExpected := range v {
-				innerSlice, ok := outerSlice[i].([]interface{})
-				require.True(t, ok)
-				require.Len(t, innerSlice, len(innerExpected))
-				for j, expectedValue := range innerExpected {
-					v, ok := innerSlice[j].(float64)
-					require.True(t, ok)
-					require.Equal(t, expectedValue, v)
-				}
-			}
-		default:
-			t.Fatalf("unexpected type: %T", v)
-		}
-	}
-}
-
-type entry struct {
-	Time  time.Time
-	Name  string
-	Event map[string]interface{}
-}
-
 func exportAndParse(t *testing.T, buf *bytes.Buffer) []entry {
 	m := make(map[string]interface{})
 	line, err := buf.ReadBytes('\n')
@@ -56,6 +56,9 @@ type connectionTracer struct {
 	perspective   protocol.Perspective
 	referenceTime time.Time
 
+	// HTTP/3 events are recorded from a different goroutine than the QUIC events,
+	// and might still be recorded after the QUIC connection was closed.
+	closed     bool
 	events     chan event
 	encodeErr  error
 	runStopped chan struct{}
@@ -63,112 +66,16 @@ type connectionTracer struct {
 	lastMetrics *metrics
 }
 
-// NewConnectionTracer creates a new tracer to record a qlog for a connection.
-func NewConnectionTracer(w io.WriteCloser, p protocol.Perspective, odcid protocol.ConnectionID) *logging.ConnectionTracer {
-	t := connectionTracer{
-		w:             w,
-		perspective:   p,
-		odcid:         odcid,
-		runStopped:    make(chan struct{}),
-		events:        make(chan event, eventChanSize),
-		referenceTime: time.Now(),
-	}
-	go t.run()
-	return &logging.ConnectionTracer{
-		StartedConnection: func(local, remote net.Addr, srcConnID, destConnID logging.ConnectionID) {
-			t.StartedConnection(local, remote, srcConnID, destConnID)
-		},
-		NegotiatedVersion: func(chosen logging.VersionNumber, clientVersions, serverVersions []logging.VersionNumber) {
-			t.NegotiatedVersion(chosen, clientVersions, serverVersions)
-		},
-		ClosedConnection:            func(e error) { t.ClosedConnection(e) },
-		SentTransportParameters:     func(tp *wire.TransportParameters) { t.SentTransportParameters(tp) },
-		ReceivedTransportParameters: func(tp *wire.TransportParameters) { t.ReceivedTransportParameters(tp) },
-		RestoredTransportParameters: func(tp *wire.TransportParameters) { t.RestoredTransportParameters(tp) },
-		SentLongHeaderPacket: func(hdr *logging.ExtendedHeader, size logging.ByteCount, ecn logging.ECN, ack *logging.AckFrame, frames []logging.Frame) {
-			t.SentLongHeaderPacket(hdr, size, ecn, ack, frames)
-		},
-		SentShortHeaderPacket: func(hdr *logging.ShortHeader, size logging.ByteCount, ecn logging.ECN, ack *logging.AckFrame, frames []logging.Frame) {
-			t.SentShortHeaderPacket(hdr, size, ecn, ack, frames)
-		},
-		ReceivedLongHeaderPacket: func(hdr *logging.ExtendedHeader, size logging.ByteCount, ecn logging.ECN, frames []logging.Frame) {
-			t.ReceivedLongHeaderPacket(hdr, size, ecn, frames)
-		},
-		ReceivedShortHeaderPacket: func(hdr *logging.ShortHeader, size logging.ByteCount, ecn logging.ECN, frames []logging.Frame) {
-			t.ReceivedShortHeaderPacket(hdr, size, ecn, frames)
-		},
-		ReceivedRetry: func(hdr *wire.Header) {
-			t.ReceivedRetry(hdr)
-		},
-		ReceivedVersionNegotiationPacket: func(dest, src logging.ArbitraryLenConnectionID, versions []logging.VersionNumber) {
-			t.ReceivedVersionNegotiationPacket(dest, src, versions)
-		},
-		BufferedPacket: func(pt logging.PacketType, size protocol.ByteCount) {
-			t.BufferedPacket(pt, size)
-		},
-		DroppedPacket: func(pt logging.PacketType, pn logging.PacketNumber, size logging.ByteCount, reason logging.PacketDropReason) {
-			t.DroppedPacket(pt, pn, size, reason)
-		},
-		UpdatedMetrics: func(rttStats *utils.RTTStats, cwnd, bytesInFlight protocol.ByteCount, packetsInFlight int) {
-			t.UpdatedMetrics(rttStats, cwnd, bytesInFlight, packetsInFlight)
-		},
-		LostPacket: func(encLevel protocol.EncryptionLevel, pn protocol.PacketNumber, lossReason logging.PacketLossReason) {
-			t.LostPacket(encLevel, pn, lossReason)
-		},
-		UpdatedCongestionState: func(state logging.CongestionState) {
-			t.UpdatedCongestionState(state)
-		},
-		UpdatedPTOCount: func(value uint32) {
-			t.UpdatedPTOCount(value)
-		},
-		UpdatedKeyFromTLS: func(encLevel protocol.EncryptionLevel, pers protocol.Perspective) {
-			t.UpdatedKeyFromTLS(encLevel, pers)
-		},
-		UpdatedKey: func(generation protocol.KeyPhase, remote bool) {
-			t.UpdatedKey(generation, remote)
-		},
-		DroppedEncryptionLevel: func(encLevel protocol.EncryptionLevel) {
-			t.DroppedEncryptionLevel(encLevel)
-		},
-		DroppedKey: func(generation protocol.KeyPhase) {
-			t.DroppedKey(generation)
-		},
-		SetLossTimer: func(tt logging.TimerType, encLevel protocol.EncryptionLevel, timeout time.Time) {
-			t.SetLossTimer(tt, encLevel, timeout)
-		},
-		LossTimerExpired: func(tt logging.TimerType, encLevel protocol.EncryptionLevel) {
-			t.LossTimerExpired(tt, encLevel)
-		},
-		LossTimerCanceled: func() {
-			t.LossTimerCanceled()
-		},
-		ECNStateUpdated: func(state logging.ECNState, trigger logging.ECNStateTrigger) {
-			t.ECNStateUpdated(state, trigger)
-		},
-		ChoseALPN: func(protocol string) {
-			t.mutex.Lock()
-			t.recordEvent(time.Now(), eventALPNInformation{chosenALPN: protocol})
-			t.mutex.Unlock()
-		},
-		Debug: func(name, msg string) {
-			t.Debug(name, msg)
-		},
-		Close: func() {
-			t.Close()
-		},
-	}
-}
-
 func (t *connectionTracer) run() {
 	defer close(t.runStopped)
 	buf := &bytes.Buffer{}
 	enc := gojay.NewEncoder(buf)
 	tl := &topLevel{
 		trace: trace{
-			VantagePoint: vantagePoint{Type: t.perspective},
+			VantagePoint: vantagePoint{Type: vantagePointType(t.perspective)},
 			CommonFields: commonFields{
-				ODCID:         t.odcid,
-				GroupID:       t.odcid,
+				ODCID:         &t.odcid,
+				GroupID:       &t.odcid,
 				ReferenceTime: t.referenceTime,
 			},
 		},
@@ -205,7 +112,10 @@ func (t *connectionTracer) Close() {
 
 // export writes a qlog.
 func (t *connectionTracer) export() error {
+	t.mutex.Lock()
+	t.closed = true
 	close(t.events)
+	t.mutex.Unlock()
 	<-t.runStopped
 	if t.encodeErr != nil {
 		return t.encodeErr
@@ -214,6 +124,9 @@ func (t *connectionTracer) export() error {
 }
 
 func (t *connectionTracer) recordEvent(eventTime time.Time, details eventDetails) {
+	if t.closed {
+		return
+	}
 	t.events <- event{
 		RelativeTime: eventTime.Sub(t.referenceTime),
 		eventDetails: details,
@@ -489,6 +402,12 @@ func (t *connectionTracer) LostPacket(encLevel protocol.EncryptionLevel, pn prot
 	t.mutex.Unlock()
 }
 
+func (t *connectionTracer) UpdatedMTU(mtu protocol.ByteCount, done bool) {
+	t.mutex.Lock()
+	t.recordEvent(time.Now(), &eventMTUUpdated{mtu: mtu, done: done})
+	t.mutex.Unlock()
+}
+
 func (t *connectionTracer) UpdatedCongestionState(state logging.CongestionState) {
 	t.mutex.Lock()
 	t.recordEvent(time.Now(), &eventCongestionStateUpdated{state: congestionState(state)})
@@ -14,8 +14,9 @@ import (
 
 // DefaultTracer creates a qlog file in the qlog directory specified by the QLOGDIR environment variable.
 // File names are <odcid>_<perspective>.qlog.
+// HTTP/3 events are written to the same file, see HTTP3TracerFromContext.
 // Returns nil if QLOGDIR is not set.
-func DefaultTracer(_ context.Context, p logging.Perspective, connID logging.ConnectionID) *logging.ConnectionTracer {
+func DefaultTracer(ctx context.Context, p logging.Perspective, connID logging.ConnectionID) *logging.ConnectionTracer {
 	var label string
 	switch p {
 	case logging.PerspectiveClient:
@@ -23,13 +24,13 @@ func DefaultTracer(_ context.Context, p logging.Perspective, connID logging.Conn
 	case logging.PerspectiveServer:
 		label = "server"
 	}
-	return qlogDirTracer(p, connID, label)
+	return qlogDirTracer(ctx, p, connID, label)
 }
 
 // qlogDirTracer creates a qlog file in the qlog directory specified by the QLOGDIR environment variable.
 // File names are <odcid>_<label>.qlog.
 // Returns nil if QLOGDIR is not set.
-func qlogDirTracer(p logging.Perspective, connID logging.ConnectionID, label string) *logging.ConnectionTracer {
+func qlogDirTracer(ctx context.Context, p logging.Perspective, connID logging.ConnectionID, label string) *logging.ConnectionTracer {
 	qlogDir := os.Getenv("QLOGDIR")
 	if qlogDir == "" {
 		return nil
@@ -45,5 +46,5 @@ func qlogDirTracer(p logging.Perspective, connID logging.ConnectionID, label str
 		log.Printf("Failed to create qlog file %s: %s", path, err.Error())
 		return nil
 	}
-	return NewConnectionTracer(utils.NewBufferedWriteCloser(bufio.NewWriter(f), f), p, connID)
+	return NewConnectionTracerWithContext(ctx, utils.NewBufferedWriteCloser(bufio.NewWriter(f), f), p, connID)
 }
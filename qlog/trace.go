@@ -33,30 +33,38 @@ func (c configuration) MarshalJSONObject(enc *gojay.Encoder) {
 
 type vantagePoint struct {
 	Name string
-	Type protocol.Perspective
+	Type string
 }
 
-func (p vantagePoint) IsNil() bool { return false }
-func (p vantagePoint) MarshalJSONObject(enc *gojay.Encoder) {
-	enc.StringKeyOmitEmpty("name", p.Name)
-	switch p.Type {
+func vantagePointType(p protocol.Perspective) string {
+	switch p {
 	case protocol.PerspectiveClient:
-		enc.StringKey("type", "client")
+		return "client"
 	case protocol.PerspectiveServer:
-		enc.StringKey("type", "server")
+		return "server"
+	default:
+		return ""
 	}
 }
 
+func (p vantagePoint) IsNil() bool { return false }
+func (p vantagePoint) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.StringKeyOmitEmpty("name", p.Name)
+	enc.StringKeyOmitEmpty("type", p.Type)
+}
+
 type commonFields struct {
-	ODCID         logging.ConnectionID
-	GroupID       logging.ConnectionID
+	ODCID         *logging.ConnectionID // not set for traces that aren't bound to a connection
+	GroupID       *logging.ConnectionID
 	ProtocolType  string
 	ReferenceTime time.Time
 }
 
 func (f commonFields) MarshalJSONObject(enc *gojay.Encoder) {
-	enc.StringKey("ODCID", f.ODCID.String())
-	enc.StringKey("group_id", f.ODCID.String())
+	if f.ODCID != nil {
+		enc.StringKey("ODCID", f.ODCID.String())
+		enc.StringKey("group_id", f.ODCID.String())
+	}
 	enc.StringKeyOmitEmpty("protocol_type", f.ProtocolType)
 	enc.Float64Key("reference_time", float64(f.ReferenceTime.UnixNano())/1e6)
 	enc.StringKey("time_format", "relative")
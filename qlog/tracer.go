@@ -14,7 +14,7 @@ func NewTracer(w io.WriteCloser) *logging.Tracer {
 		VantagePoint: vantagePoint{Type: "transport"},
 		CommonFields: commonFields{ReferenceTime: time.Now()},
 	}
-	wr := *newWriter(w, tr)
+	wr := newWriter(w, tr)
 	go wr.Run()
 	return &logging.Tracer{
 		SentPacket: func(_ net.Addr, hdr *logging.Header, size logging.ByteCount, frames []logging.Frame) {
@@ -28,10 +28,10 @@ func NewTracer(w io.WriteCloser) *logging.Tracer {
 				Frames: fs,
 			})
 		},
-		SentVersionNegotiationPacket: func(_ net.Addr, dest, src logging.ArbitraryLenConnectionID, versions []logging.Version) {
-			ver := make([]version, len(versions))
+		SentVersionNegotiationPacket: func(_ net.Addr, dest, src logging.ArbitraryLenConnectionID, versions []logging.VersionNumber) {
+			ver := make([]versionNumber, len(versions))
 			for i, v := range versions {
-				ver[i] = version(v)
+				ver[i] = versionNumber(v)
 			}
 			wr.RecordEvent(time.Now(), &eventVersionNegotiationSent{
 				Header: packetHeaderVersionNegotiation{
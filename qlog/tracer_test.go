@@ -24,7 +24,7 @@ func TestTraceMetadata(t *testing.T) {
 	var m map[string]interface{}
 	err := unmarshal(buf.Bytes(), &m)
 	require.NoError(t, err)
-	require.Equal(t, "0.3", m["qlog_version"])
+	require.Equal(t, "draft-02", m["qlog_version"])
 	require.Contains(t, m, "title")
 	require.Contains(t, m, "trace")
 	trace := m["trace"].(map[string]interface{})
@@ -83,7 +83,7 @@ func TestSendingVersionNegotiationPacket(t *testing.T) {
 		nil,
 		protocol.ArbitraryLenConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
 		protocol.ArbitraryLenConnectionID{4, 3, 2, 1},
-		[]protocol.Version{0xdeadbeef, 0xdecafbad},
+		[]protocol.VersionNumber{0xdeadbeef, 0xdecafbad},
 	)
 	tracer.Close()
 	entry := exportAndParseSingle(t, buf)
@@ -47,6 +47,8 @@ const (
 	categoryTransport
 	categorySecurity
 	categoryRecovery
+	categoryHTTP
+	categoryQPACK
 )
 
 func (c category) String() string {
@@ -59,6 +61,10 @@ func (c category) String() string {
 		return "security"
 	case categoryRecovery:
 		return "recovery"
+	case categoryHTTP:
+		return "http"
+	case categoryQPACK:
+		return "qpack"
 	default:
 		return "unknown category"
 	}
@@ -5,13 +5,12 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/francoispqt/gojay"
 )
 
-const eventChanSize = 50
-
 const recordSeparator = 0x1e
 
 func writeRecordSeparator(w io.Writer) error {
@@ -25,6 +24,11 @@ type writer struct {
 	referenceTime time.Time
 	tr            *trace
 
+	// HTTP/3 events are recorded from a different goroutine than the QUIC events,
+	// and might still be recorded after the QUIC connection was closed.
+	mx     sync.RWMutex
+	closed bool
+
 	events     chan event
 	encodeErr  error
 	runStopped chan struct{}
@@ -41,6 +45,12 @@ func newWriter(w io.WriteCloser, tr *trace) *writer {
 }
 
 func (w *writer) RecordEvent(eventTime time.Time, details eventDetails) {
+	w.mx.RLock()
+	defer w.mx.RUnlock()
+
+	if w.closed {
+		return
+	}
 	w.events <- event{
 		RelativeTime: eventTime.Sub(w.referenceTime),
 		eventDetails: details,
@@ -89,7 +99,10 @@ func (w *writer) Close() {
 }
 
 func (w *writer) close() error {
+	w.mx.Lock()
+	w.closed = true
 	close(w.events)
+	w.mx.Unlock()
 	<-w.runStopped
 	if w.encodeErr != nil {
 		return w.encodeErr
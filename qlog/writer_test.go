@@ -2,8 +2,6 @@ package qlog
 
 import (
 	"bytes"
-	"errors"
-	"io"
 	"log"
 	"os"
 	"testing"
@@ -12,21 +10,6 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-type limitedWriter struct {
-	io.WriteCloser
-	N       int
-	written int
-}
-
-func (w *limitedWriter) Write(p []byte) (int, error) {
-	if w.written+len(p) > w.N {
-		return 0, errors.New("writer full")
-	}
-	n, err := w.WriteCloser.Write(p)
-	w.written += n
-	return n, err
-}
-
 func TestWritingStopping(t *testing.T) {
 	buf := &bytes.Buffer{}
 	t.Run("stops writing when encountering an error", func(t *testing.T) {
@@ -108,6 +108,11 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 
 	var bytesRead int
 	var deadlineTimer *utils.Timer
+	defer func() {
+		if deadlineTimer != nil {
+			deadlineTimer.Stop()
+		}
+	}()
 	for bytesRead < len(p) {
 		if s.currentFrame == nil || s.readPosInFrame >= len(s.currentFrame) {
 			s.dequeueNextFrame()
@@ -115,49 +120,8 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 		if s.currentFrame == nil && bytesRead > 0 {
 			return false, bytesRead, s.closeForShutdownErr
 		}
-
-		for {
-			// Stop waiting on errors
-			if s.closeForShutdownErr != nil {
-				return false, bytesRead, s.closeForShutdownErr
-			}
-			if s.cancelReadErr != nil {
-				return false, bytesRead, s.cancelReadErr
-			}
-			if s.resetRemotelyErr != nil {
-				return false, bytesRead, s.resetRemotelyErr
-			}
-
-			deadline := s.deadline
-			if !deadline.IsZero() {
-				if !time.Now().Before(deadline) {
-					return false, bytesRead, errDeadline
-				}
-				if deadlineTimer == nil {
-					deadlineTimer = utils.NewTimer()
-					defer deadlineTimer.Stop()
-				}
-				deadlineTimer.Reset(deadline)
-			}
-
-			if s.currentFrame != nil || s.currentFrameIsLast {
-				break
-			}
-
-			s.mutex.Unlock()
-			if deadline.IsZero() {
-				<-s.readChan
-			} else {
-				select {
-				case <-s.readChan:
-				case <-deadlineTimer.Chan():
-					deadlineTimer.SetRead()
-				}
-			}
-			s.mutex.Lock()
-			if s.currentFrame == nil {
-				s.dequeueNextFrame()
-			}
+		if err := s.waitForData(&deadlineTimer); err != nil {
+			return false, bytesRead, err
 		}
 
 		if bytesRead > len(p) {
@@ -178,17 +142,155 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 		}
 
 		if s.readPosInFrame >= len(s.currentFrame) && s.currentFrameIsLast {
-			s.finRead = true
-			s.currentFrame = nil
-			if s.currentFrameDone != nil {
-				s.currentFrameDone()
-			}
+			s.finishReading()
 			return true, bytesRead, io.EOF
 		}
 	}
 	return false, bytesRead, nil
 }
 
+// waitForData blocks until a frame is available, or until the last frame was received.
+// It must be called with the mutex held.
+func (s *receiveStream) waitForData(deadlineTimer **utils.Timer) error {
+	for {
+		// Stop waiting on errors
+		if s.closeForShutdownErr != nil {
+			return s.closeForShutdownErr
+		}
+		if s.cancelReadErr != nil {
+			return s.cancelReadErr
+		}
+		if s.resetRemotelyErr != nil {
+			return s.resetRemotelyErr
+		}
+
+		deadline := s.deadline
+		if !deadline.IsZero() {
+			if !time.Now().Before(deadline) {
+				return errDeadline
+			}
+			if *deadlineTimer == nil {
+				*deadlineTimer = utils.NewTimer()
+			}
+			(*deadlineTimer).Reset(deadline)
+		}
+
+		if s.currentFrame != nil || s.currentFrameIsLast {
+			return nil
+		}
+
+		s.mutex.Unlock()
+		if deadline.IsZero() {
+			<-s.readChan
+		} else {
+			select {
+			case <-s.readChan:
+			case <-(*deadlineTimer).Chan():
+				(*deadlineTimer).SetRead()
+			}
+		}
+		s.mutex.Lock()
+		if s.currentFrame == nil {
+			s.dequeueNextFrame()
+		}
+	}
+}
+
+// Peek returns the data that can be read from the stream, without copying it.
+// It is not thread safe!
+func (s *receiveStream) Peek() ([]byte, error) {
+	s.readOnce <- struct{}{}
+	defer func() { <-s.readOnce }()
+
+	s.mutex.Lock()
+	completed, data, err := s.peekImpl()
+	s.mutex.Unlock()
+
+	if completed {
+		s.sender.onStreamCompleted(s.streamID)
+	}
+	return data, err
+}
+
+func (s *receiveStream) peekImpl() (bool /*stream completed */, []byte, error) {
+	if s.finRead {
+		return false, nil, io.EOF
+	}
+	if s.cancelReadErr != nil {
+		return false, nil, s.cancelReadErr
+	}
+	if s.resetRemotelyErr != nil {
+		return false, nil, s.resetRemotelyErr
+	}
+	if s.closeForShutdownErr != nil {
+		return false, nil, s.closeForShutdownErr
+	}
+
+	if s.currentFrame == nil || s.readPosInFrame >= len(s.currentFrame) {
+		s.dequeueNextFrame()
+	}
+	var deadlineTimer *utils.Timer
+	err := s.waitForData(&deadlineTimer)
+	if deadlineTimer != nil {
+		deadlineTimer.Stop()
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	// This happens if the last frame didn't contain any data.
+	if s.readPosInFrame >= len(s.currentFrame) && s.currentFrameIsLast {
+		s.finishReading()
+		return true, nil, io.EOF
+	}
+	return false, s.currentFrame[s.readPosInFrame:], nil
+}
+
+// Consume marks the first n bytes of the data returned by Peek as read.
+// It is not thread safe!
+func (s *receiveStream) Consume(n int) error {
+	s.readOnce <- struct{}{}
+	defer func() { <-s.readOnce }()
+
+	s.mutex.Lock()
+	completed, err := s.consumeImpl(n)
+	s.mutex.Unlock()
+
+	if completed {
+		s.sender.onStreamCompleted(s.streamID)
+	}
+	return err
+}
+
+func (s *receiveStream) consumeImpl(n int) (bool /*stream completed */, error) {
+	if n == 0 {
+		return false, nil
+	}
+	if available := len(s.currentFrame) - s.readPosInFrame; n < 0 || n > available {
+		return false, fmt.Errorf("cannot consume %d bytes on stream %d, only %d bytes available", n, s.streamID, max(available, 0))
+	}
+	s.readPosInFrame += n
+	// when a RESET_STREAM was received, the flow controller was already
+	// informed about the final byteOffset for this stream
+	if s.resetRemotelyErr == nil {
+		s.flowController.AddBytesRead(protocol.ByteCount(n))
+	}
+	if s.readPosInFrame >= len(s.currentFrame) && s.currentFrameIsLast {
+		s.finishReading()
+		return true, nil
+	}
+	return false, nil
+}
+
+// finishReading is called when the last frame was read completely.
+func (s *receiveStream) finishReading() {
+	s.finRead = true
+	s.currentFrame = nil
+	if s.currentFrameDone != nil {
+		s.currentFrameDone()
+	}
+}
+
 func (s *receiveStream) dequeueNextFrame() {
 	var offset protocol.ByteCount
 	// We're done with the last frame. Release the buffer.
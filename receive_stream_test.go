@@ -484,6 +484,131 @@ var _ = Describe("Receive Stream", func() {
 		})
 	})
 
+	Context("peeking and consuming", func() {
+		It("returns data without copying it", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			data := []byte("foobar")
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: data})).To(Succeed())
+			b, err := str.Peek()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foobar")))
+			Expect(&b[0]).To(BeIdenticalTo(&data[0]))
+			// peeking again returns the same data
+			b, err = str.Peek()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foobar")))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2))
+			Expect(str.Consume(2)).To(Succeed())
+			b, err = str.Peek()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("obar")))
+		})
+
+		It("returns the data of one frame at a time", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo")})).To(Succeed())
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 3, Data: []byte("bar")})).To(Succeed())
+			b, err := str.Peek()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foo")))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3)).Times(2)
+			Expect(str.Consume(3)).To(Succeed())
+			b, err = str.Peek()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("bar")))
+			Expect(str.Consume(3)).To(Succeed())
+		})
+
+		It("waits until data is available", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(2), false)
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(10 * time.Millisecond)
+				Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte{0xde, 0xad}})).To(Succeed())
+			}()
+			b, err := str.Peek()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte{0xde, 0xad}))
+		})
+
+		It("can be mixed with Read", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})).To(Succeed())
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2)).Times(3)
+			b := make([]byte, 2)
+			_, err := strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("fo")))
+			p, err := str.Peek()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p).To(Equal([]byte("obar")))
+			Expect(str.Consume(2)).To(Succeed())
+			_, err = strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("ar")))
+		})
+
+		It("errors when consuming more data than available", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})).To(Succeed())
+			_, err := str.Peek()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Consume(7)).To(MatchError("cannot consume 7 bytes on stream 1337, only 6 bytes available"))
+		})
+
+		It("returns EOF once all data has been consumed", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), true)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar"), Fin: true})).To(Succeed())
+			b, err := str.Peek()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foobar")))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(6))
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			Expect(str.Consume(6)).To(Succeed())
+			_, err = str.Peek()
+			Expect(err).To(MatchError(io.EOF))
+			_, err = strWithTimeout.Read([]byte{0})
+			Expect(err).To(MatchError(io.EOF))
+		})
+
+		It("handles immediate FINs", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(0), true)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Fin: true})).To(Succeed())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			_, err := str.Peek()
+			Expect(err).To(MatchError(io.EOF))
+		})
+
+		It("returns an error when Peek is called after the deadline", func() {
+			str.SetReadDeadline(time.Now().Add(-time.Second))
+			_, err := str.Peek()
+			Expect(err).To(MatchError(errDeadline))
+		})
+
+		It("unblocks Peek after the deadline", func() {
+			deadline := time.Now().Add(scaleDuration(50 * time.Millisecond))
+			str.SetReadDeadline(deadline)
+			_, err := str.Peek()
+			Expect(err).To(MatchError(errDeadline))
+			Expect(time.Now()).To(BeTemporally("~", deadline, scaleDuration(20*time.Millisecond)))
+		})
+
+		It("unblocks Peek when the stream is canceled", func() {
+			mockSender.EXPECT().queueControlFrame(gomock.Any())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := str.Peek()
+				Expect(err).To(Equal(&StreamError{StreamID: streamID, ErrorCode: 1234}))
+				close(done)
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			str.CancelRead(1234)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
 	Context("stream cancellations", func() {
 		Context("canceling read", func() {
 			It("unblocks Read", func() {
@@ -9,7 +9,7 @@ import (
 
 // A sendConn allows sending using a simple Write() on a non-connected packet conn.
 type sendConn interface {
-	Write(b []byte, gsoSize uint16, ecn protocol.ECN) error
+	Write(b []byte, gsoSize uint16, ecn protocol.ECN, dscp uint8) error
 	Close() error
 	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
@@ -46,7 +46,7 @@ func newSendConn(c rawConn, remote net.Addr, info packetInfo, logger utils.Logge
 	}
 
 	oob := info.OOB()
-	// increase oob slice capacity, so we can add the UDP_SEGMENT and ECN control messages without allocating
+	// increase oob slice capacity, so we can add the UDP_SEGMENT and TOS / Traffic Class control messages without allocating
 	l := len(oob)
 	oob = append(oob, make([]byte, 64)...)[:l]
 	return &sconn{
@@ -58,8 +58,8 @@ func newSendConn(c rawConn, remote net.Addr, info packetInfo, logger utils.Logge
 	}
 }
 
-func (c *sconn) Write(p []byte, gsoSize uint16, ecn protocol.ECN) error {
-	err := c.writePacket(p, c.remoteAddr, c.packetInfoOOB, gsoSize, ecn)
+func (c *sconn) Write(p []byte, gsoSize uint16, ecn protocol.ECN, dscp uint8) error {
+	err := c.writePacket(p, c.remoteAddr, c.packetInfoOOB, gsoSize, ecn, dscp)
 	if err != nil && isGSOError(err) {
 		// disable GSO for future calls
 		c.gotGSOError = true
@@ -72,7 +72,7 @@ func (c *sconn) Write(p []byte, gsoSize uint16, ecn protocol.ECN) error {
 			if l > int(gsoSize) {
 				l = int(gsoSize)
 			}
-			if err := c.writePacket(p[:l], c.remoteAddr, c.packetInfoOOB, 0, ecn); err != nil {
+			if err := c.writePacket(p[:l], c.remoteAddr, c.packetInfoOOB, 0, ecn, dscp); err != nil {
 				return err
 			}
 			p = p[l:]
@@ -82,10 +82,10 @@ func (c *sconn) Write(p []byte, gsoSize uint16, ecn protocol.ECN) error {
 	return err
 }
 
-func (c *sconn) writePacket(p []byte, addr net.Addr, oob []byte, gsoSize uint16, ecn protocol.ECN) error {
-	_, err := c.WritePacket(p, addr, oob, gsoSize, ecn)
+func (c *sconn) writePacket(p []byte, addr net.Addr, oob []byte, gsoSize uint16, ecn protocol.ECN, dscp uint8) error {
+	_, err := c.WritePacket(p, addr, oob, gsoSize, ecn, dscp)
 	if err != nil && !c.wroteFirstPacket && isPermissionError(err) {
-		_, err = c.WritePacket(p, addr, oob, gsoSize, ecn)
+		_, err = c.WritePacket(p, addr, oob, gsoSize, ecn, dscp)
 	}
 	c.wroteFirstPacket = true
 	return err
@@ -46,8 +46,8 @@ var _ = Describe("Connection (for sending packets)", func() {
 			pi := packetInfo{addr: netip.IPv6Loopback()}
 			Expect(pi.OOB()).ToNot(BeEmpty())
 			c := newSendConn(rawConn, remoteAddr, pi, utils.DefaultLogger)
-			rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, pi.OOB(), uint16(0), protocol.ECT1)
-			Expect(c.Write([]byte("foobar"), 0, protocol.ECT1)).To(Succeed())
+			rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, pi.OOB(), uint16(0), protocol.ECT1, uint8(0))
+			Expect(c.Write([]byte("foobar"), 0, protocol.ECT1, 0)).To(Succeed())
 		})
 	}
 
@@ -56,8 +56,8 @@ var _ = Describe("Connection (for sending packets)", func() {
 		rawConn.EXPECT().LocalAddr()
 		rawConn.EXPECT().capabilities().AnyTimes()
 		c := newSendConn(rawConn, remoteAddr, packetInfo{}, utils.DefaultLogger)
-		rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), uint16(3), protocol.ECNCE)
-		Expect(c.Write([]byte("foobar"), 3, protocol.ECNCE)).To(Succeed())
+		rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), uint16(3), protocol.ECNCE, uint8(46))
+		Expect(c.Write([]byte("foobar"), 3, protocol.ECNCE, 46)).To(Succeed())
 	})
 
 	if platformSupportsGSO {
@@ -68,11 +68,11 @@ var _ = Describe("Connection (for sending packets)", func() {
 			c := newSendConn(rawConn, remoteAddr, packetInfo{}, utils.DefaultLogger)
 			Expect(c.capabilities().GSO).To(BeTrue())
 			gomock.InOrder(
-				rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), uint16(4), protocol.ECNCE).Return(0, errGSO),
-				rawConn.EXPECT().WritePacket([]byte("foob"), remoteAddr, gomock.Any(), uint16(0), protocol.ECNCE).Return(4, nil),
-				rawConn.EXPECT().WritePacket([]byte("ar"), remoteAddr, gomock.Any(), uint16(0), protocol.ECNCE).Return(2, nil),
+				rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), uint16(4), protocol.ECNCE, uint8(46)).Return(0, errGSO),
+				rawConn.EXPECT().WritePacket([]byte("foob"), remoteAddr, gomock.Any(), uint16(0), protocol.ECNCE, uint8(46)).Return(4, nil),
+				rawConn.EXPECT().WritePacket([]byte("ar"), remoteAddr, gomock.Any(), uint16(0), protocol.ECNCE, uint8(46)).Return(2, nil),
 			)
-			Expect(c.Write([]byte("foobar"), 4, protocol.ECNCE)).To(Succeed())
+			Expect(c.Write([]byte("foobar"), 4, protocol.ECNCE, 46)).To(Succeed())
 			Expect(c.capabilities().GSO).To(BeFalse())
 		})
 	}
@@ -84,10 +84,10 @@ var _ = Describe("Connection (for sending packets)", func() {
 			rawConn.EXPECT().capabilities().AnyTimes()
 			c := newSendConn(rawConn, remoteAddr, packetInfo{}, utils.DefaultLogger)
 			gomock.InOrder(
-				rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), gomock.Any(), protocol.ECNCE).Return(0, errNotPermitted),
-				rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), uint16(0), protocol.ECNCE).Return(6, nil),
+				rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), gomock.Any(), protocol.ECNCE, uint8(0)).Return(0, errNotPermitted),
+				rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), uint16(0), protocol.ECNCE, uint8(0)).Return(6, nil),
 			)
-			Expect(c.Write([]byte("foobar"), 0, protocol.ECNCE)).To(Succeed())
+			Expect(c.Write([]byte("foobar"), 0, protocol.ECNCE, 0)).To(Succeed())
 		})
 
 		It("fails if the sendmsg calls fail multiple times", func() {
@@ -95,8 +95,8 @@ var _ = Describe("Connection (for sending packets)", func() {
 			rawConn.EXPECT().LocalAddr()
 			rawConn.EXPECT().capabilities().AnyTimes()
 			c := newSendConn(rawConn, remoteAddr, packetInfo{}, utils.DefaultLogger)
-			rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), gomock.Any(), protocol.ECNCE).Return(0, errNotPermitted).Times(2)
-			Expect(c.Write([]byte("foobar"), 0, protocol.ECNCE)).To(MatchError(errNotPermitted))
+			rawConn.EXPECT().WritePacket([]byte("foobar"), remoteAddr, gomock.Any(), gomock.Any(), protocol.ECNCE, uint8(0)).Return(0, errNotPermitted).Times(2)
+			Expect(c.Write([]byte("foobar"), 0, protocol.ECNCE, 0)).To(MatchError(errNotPermitted))
 		})
 	}
 })
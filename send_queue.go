@@ -3,7 +3,7 @@ package quic
 import "github.com/nxenon/xquic-go/internal/protocol"
 
 type sender interface {
-	Send(p *packetBuffer, gsoSize uint16, ecn protocol.ECN)
+	Send(p *packetBuffer, gsoSize uint16, ecn protocol.ECN, dscp uint8)
 	Run() error
 	WouldBlock() bool
 	Available() <-chan struct{}
@@ -14,6 +14,7 @@ type queueEntry struct {
 	buf     *packetBuffer
 	gsoSize uint16
 	ecn     protocol.ECN
+	dscp    uint8
 }
 
 type sendQueue struct {
@@ -41,9 +42,9 @@ func newSendQueue(conn sendConn) sender {
 // Send sends out a packet. It's guaranteed to not block.
 // Callers need to make sure that there's actually space in the send queue by calling WouldBlock.
 // Otherwise Send will panic.
-func (h *sendQueue) Send(p *packetBuffer, gsoSize uint16, ecn protocol.ECN) {
+func (h *sendQueue) Send(p *packetBuffer, gsoSize uint16, ecn protocol.ECN, dscp uint8) {
 	select {
-	case h.queue <- queueEntry{buf: p, gsoSize: gsoSize, ecn: ecn}:
+	case h.queue <- queueEntry{buf: p, gsoSize: gsoSize, ecn: ecn, dscp: dscp}:
 		// clear available channel if we've reached capacity
 		if len(h.queue) == sendQueueCapacity {
 			select {
@@ -78,7 +79,7 @@ func (h *sendQueue) Run() error {
 			// make sure that all queued packets are actually sent out
 			shouldClose = true
 		case e := <-h.queue:
-			if err := h.conn.Write(e.buf.Data, e.gsoSize, e.ecn); err != nil {
+			if err := h.conn.Write(e.buf.Data, e.gsoSize, e.ecn, e.dscp); err != nil {
 				// This additional check enables:
 				// 1. Checking for "datagram too large" message from the kernel, as such,
 				// 2. Path MTU discovery,and
@@ -101,3 +102,38 @@ func (h *sendQueue) Close() {
 	// wait until the run loop returned
 	<-h.runStopped
 }
+
+// A syncSender sends out packets synchronously.
+// It is used by connections driven by a worker pool, which don't use a separate goroutine for sending.
+type syncSender struct {
+	conn    sendConn
+	onError func(error)
+	failed  bool
+}
+
+var _ sender = &syncSender{}
+
+func newSyncSender(conn sendConn, onError func(error)) sender {
+	return &syncSender{conn: conn, onError: onError}
+}
+
+// Send sends out a packet. It blocks until the packet has been written to the connection.
+func (h *syncSender) Send(p *packetBuffer, gsoSize uint16, ecn protocol.ECN, dscp uint8) {
+	defer p.Release()
+	if h.failed {
+		return
+	}
+	if err := h.conn.Write(p.Data, gsoSize, ecn, dscp); err != nil && !isSendMsgSizeErr(err) {
+		h.failed = true
+		h.onError(err)
+	}
+}
+
+// Run doesn't do anything, since packets are sent out by Send.
+func (h *syncSender) Run() error { return nil }
+
+func (h *syncSender) WouldBlock() bool { return false }
+
+func (h *syncSender) Available() <-chan struct{} { return nil }
+
+func (h *syncSender) Close() {}
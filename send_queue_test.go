@@ -28,10 +28,10 @@ var _ = Describe("Send Queue", func() {
 
 	It("sends a packet", func() {
 		p := getPacket([]byte("foobar"))
-		q.Send(p, 10, protocol.ECT1) // make sure the packet size is passed through to the conn
+		q.Send(p, 10, protocol.ECT1, 46) // make sure the packet size, ECN and DSCP are passed through to the conn
 
 		written := make(chan struct{})
-		c.EXPECT().Write([]byte("foobar"), uint16(10), protocol.ECT1).Do(func([]byte, uint16, protocol.ECN) error { close(written); return nil })
+		c.EXPECT().Write([]byte("foobar"), uint16(10), protocol.ECT1, uint8(46)).Do(func([]byte, uint16, protocol.ECN, uint8) error { close(written); return nil })
 		done := make(chan struct{})
 		go func() {
 			defer GinkgoRecover()
@@ -47,19 +47,19 @@ var _ = Describe("Send Queue", func() {
 	It("panics when Send() is called although there's no space in the queue", func() {
 		for i := 0; i < sendQueueCapacity; i++ {
 			Expect(q.WouldBlock()).To(BeFalse())
-			q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon)
+			q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon, 0)
 		}
 		Expect(q.WouldBlock()).To(BeTrue())
-		Expect(func() { q.Send(getPacket([]byte("raboof")), 6, protocol.ECNNon) }).To(Panic())
+		Expect(func() { q.Send(getPacket([]byte("raboof")), 6, protocol.ECNNon, 0) }).To(Panic())
 	})
 
 	It("signals when sending is possible again", func() {
 		Expect(q.WouldBlock()).To(BeFalse())
-		q.Send(getPacket([]byte("foobar1")), 6, protocol.ECNNon)
+		q.Send(getPacket([]byte("foobar1")), 6, protocol.ECNNon, 0)
 		Consistently(q.Available()).ShouldNot(Receive())
 
 		// now start sending out packets. This should free up queue space.
-		c.EXPECT().Write(gomock.Any(), gomock.Any(), protocol.ECNNon).MinTimes(1).MaxTimes(2)
+		c.EXPECT().Write(gomock.Any(), gomock.Any(), protocol.ECNNon, gomock.Any()).MinTimes(1).MaxTimes(2)
 		done := make(chan struct{})
 		go func() {
 			defer GinkgoRecover()
@@ -69,7 +69,7 @@ var _ = Describe("Send Queue", func() {
 
 		Eventually(q.Available()).Should(Receive())
 		Expect(q.WouldBlock()).To(BeFalse())
-		Expect(func() { q.Send(getPacket([]byte("foobar2")), 7, protocol.ECNNon) }).ToNot(Panic())
+		Expect(func() { q.Send(getPacket([]byte("foobar2")), 7, protocol.ECNNon, 0) }).ToNot(Panic())
 
 		q.Close()
 		Eventually(done).Should(BeClosed())
@@ -79,7 +79,7 @@ var _ = Describe("Send Queue", func() {
 		write := make(chan struct{}, 1)
 		written := make(chan struct{}, 100)
 		// now start sending out packets. This should free up queue space.
-		c.EXPECT().Write(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func([]byte, uint16, protocol.ECN) error {
+		c.EXPECT().Write(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func([]byte, uint16, protocol.ECN, uint8) error {
 			written <- struct{}{}
 			<-write
 			return nil
@@ -94,19 +94,19 @@ var _ = Describe("Send Queue", func() {
 			close(done)
 		}()
 
-		q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon)
+		q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon, 0)
 		<-written
 
 		// now fill up the send queue
 		for i := 0; i < sendQueueCapacity; i++ {
 			Expect(q.WouldBlock()).To(BeFalse())
-			q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon)
+			q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon, 0)
 		}
 		// One more packet is queued when it's picked up by Run and written to the connection.
 		// In this test, it's blocked on write channel in the mocked Write call.
 		<-written
 		Eventually(q.WouldBlock()).Should(BeFalse())
-		q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon)
+		q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon, 0)
 
 		Expect(q.WouldBlock()).To(BeTrue())
 		Consistently(q.Available()).ShouldNot(Receive())
@@ -132,15 +132,15 @@ var _ = Describe("Send Queue", func() {
 
 		// the run loop exits if there is a write error
 		testErr := errors.New("test error")
-		c.EXPECT().Write(gomock.Any(), gomock.Any(), gomock.Any()).Return(testErr)
-		q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon)
+		c.EXPECT().Write(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(testErr)
+		q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon, 0)
 		Eventually(done).Should(BeClosed())
 
 		sent := make(chan struct{})
 		go func() {
 			defer GinkgoRecover()
-			q.Send(getPacket([]byte("raboof")), 6, protocol.ECNNon)
-			q.Send(getPacket([]byte("quux")), 4, protocol.ECNNon)
+			q.Send(getPacket([]byte("raboof")), 6, protocol.ECNNon, 0)
+			q.Send(getPacket([]byte("quux")), 4, protocol.ECNNon, 0)
 			close(sent)
 		}()
 
@@ -149,7 +149,7 @@ var _ = Describe("Send Queue", func() {
 
 	It("blocks Close() until the packet has been sent out", func() {
 		written := make(chan []byte)
-		c.EXPECT().Write(gomock.Any(), gomock.Any(), gomock.Any()).Do(func(p []byte, _ uint16, _ protocol.ECN) error { written <- p; return nil })
+		c.EXPECT().Write(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(p []byte, _ uint16, _ protocol.ECN, _ uint8) error { written <- p; return nil })
 		done := make(chan struct{})
 		go func() {
 			defer GinkgoRecover()
@@ -157,7 +157,7 @@ var _ = Describe("Send Queue", func() {
 			close(done)
 		}()
 
-		q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon)
+		q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon, 0)
 
 		closed := make(chan struct{})
 		go func() {
@@ -173,3 +173,39 @@ var _ = Describe("Send Queue", func() {
 		Eventually(closed).Should(BeClosed())
 	})
 })
+
+var _ = Describe("Synchronous Sender", func() {
+	var q sender
+	var c *MockSendConn
+	var sendErr error
+
+	BeforeEach(func() {
+		sendErr = nil
+		c = NewMockSendConn(mockCtrl)
+		q = newSyncSender(c, func(err error) { sendErr = err })
+	})
+
+	getPacket := func(b []byte) *packetBuffer {
+		buf := getPacketBuffer()
+		buf.Data = buf.Data[:len(b)]
+		copy(buf.Data, b)
+		return buf
+	}
+
+	It("sends packets synchronously", func() {
+		c.EXPECT().Write([]byte("foobar"), uint16(10), protocol.ECT1, uint8(46))
+		q.Send(getPacket([]byte("foobar")), 10, protocol.ECT1, 46)
+		Expect(q.WouldBlock()).To(BeFalse())
+		Expect(q.Available()).To(BeNil())
+		q.Close()
+	})
+
+	It("reports errors, and stops sending", func() {
+		testErr := errors.New("test error")
+		c.EXPECT().Write(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(testErr)
+		q.Send(getPacket([]byte("foobar")), 6, protocol.ECNNon, 0)
+		Expect(sendErr).To(MatchError(testErr))
+		// no more calls to Write
+		q.Send(getPacket([]byte("raboof")), 6, protocol.ECNNon, 0)
+	})
+})
@@ -3,6 +3,8 @@ package quic
 import (
 	"context"
 	"fmt"
+	"io"
+	"slices"
 	"sync"
 	"time"
 
@@ -21,6 +23,7 @@ type sendStreamI interface {
 	popStreamFrame(maxBytes protocol.ByteCount, v protocol.VersionNumber) (frame ackhandler.StreamFrame, ok, hasMore bool)
 	closeForShutdown(error)
 	updateSendWindow(protocol.ByteCount)
+	dscpClass() dscpClass
 }
 
 type sendStream struct {
@@ -47,10 +50,17 @@ type sendStream struct {
 	dataForWriting []byte // during a Write() call, this slice is the part of p that still needs to be sent out
 	nextFrame      *wire.StreamFrame
 
+	// The buffers passed to WriteBuffer, ordered by stream offset.
+	// They are referenced by STREAM frames until their data was acknowledged.
+	ownedBuffers []*ownedBuffer
+	writingOwned *ownedBuffer // set during a WriteBuffer() call
+
 	writeChan chan struct{}
 	writeOnce chan struct{}
 	deadline  time.Time
 
+	dscp dscpClass
+
 	flowController flowcontrol.StreamFlowController
 }
 
@@ -89,6 +99,59 @@ func (s *sendStream) Write(p []byte) (int, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	return s.writeImpl(p)
+}
+
+// WriteBuffer writes p without copying it.
+// The STREAM frames sent reference p directly, and release is called once p isn't referenced any more.
+func (s *sendStream) WriteBuffer(p []byte, release func()) (int, error) {
+	s.writeOnce <- struct{}{}
+	defer func() { <-s.writeOnce }()
+
+	s.mutex.Lock()
+	b := &ownedBuffer{writing: true, release: release}
+	s.ownedBuffers = append(s.ownedBuffers, b)
+	s.writingOwned = b
+	n, err := s.writeImpl(p)
+	s.writingOwned = nil
+	// If the write was aborted, the rest of p is not going to be sent.
+	s.dataForWriting = nil
+	b.writing = false
+	release = s.maybeReleaseOwnedBuffer(b)
+	s.mutex.Unlock()
+
+	if release != nil {
+		release()
+	}
+	return n, err
+}
+
+// ReadFrom implements io.ReaderFrom.
+// Data is read into pooled buffers, which are then written using WriteBuffer.
+func (s *sendStream) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		buf := getReadFromBuffer()
+		n, rerr := r.Read(*buf)
+		if n > 0 {
+			written, err := s.WriteBuffer((*buf)[:n], func() { putReadFromBuffer(buf) })
+			total += int64(written)
+			if err != nil {
+				return total, err
+			}
+		} else {
+			putReadFromBuffer(buf)
+		}
+		if rerr == io.EOF {
+			return total, nil
+		}
+		if rerr != nil {
+			return total, rerr
+		}
+	}
+}
+
+func (s *sendStream) writeImpl(p []byte) (int, error) {
 	if s.finishedWriting {
 		return 0, fmt.Errorf("write on closed stream %d", s.streamID)
 	}
@@ -120,7 +183,8 @@ func (s *sendStream) Write(p []byte) (int, error) {
 		// This allows us to return Write() when all data but x bytes have been sent out.
 		// When the user now calls Close(), this is much more likely to happen before we popped that last STREAM frame,
 		// allowing us to set the FIN bit on that frame (instead of sending an empty STREAM frame with FIN).
-		if s.canBufferStreamFrame() && len(s.dataForWriting) > 0 {
+		// Data passed to WriteBuffer is never copied.
+		if s.writingOwned == nil && s.canBufferStreamFrame() && len(s.dataForWriting) > 0 {
 			if s.nextFrame == nil {
 				f := wire.GetStreamFrame()
 				f.Offset = s.writeOffset
@@ -203,6 +267,11 @@ func (s *sendStream) popStreamFrame(maxBytes protocol.ByteCount, v protocol.Vers
 	f, hasMoreData := s.popNewOrRetransmittedStreamFrame(maxBytes, v)
 	if f != nil {
 		s.numOutstandingFrames++
+		if len(s.ownedBuffers) > 0 && f.DataLen() > 0 {
+			if b := s.ownedBufferAt(f.Offset); b != nil {
+				b.inFlight++
+			}
+		}
 	}
 	s.mutex.Unlock()
 
@@ -289,7 +358,15 @@ func (s *sendStream) popNewStreamFrame(maxBytes, sendWindow protocol.ByteCount,
 		return nextFrame, s.nextFrame != nil || s.dataForWriting != nil
 	}
 
-	f := wire.GetStreamFrame()
+	var f *wire.StreamFrame
+	if s.writingOwned != nil {
+		// The frame references the data of the owned buffer, see getDataForWriting.
+		f = &wire.StreamFrame{}
+	} else if maxBytes > protocol.MaxPacketBufferSize {
+		f = wire.GetLargeStreamFrame()
+	} else {
+		f = wire.GetStreamFrame()
+	}
 	f.Fin = false
 	f.StreamID = s.streamID
 	f.Offset = s.writeOffset
@@ -320,8 +397,12 @@ func (s *sendStream) maybeGetRetransmission(maxBytes protocol.ByteCount, v proto
 	if needsSplit {
 		return newFrame, true
 	}
-	s.retransmissionQueue = s.retransmissionQueue[1:]
-	return f, len(s.retransmissionQueue) > 0
+	// Shift the remaining frames instead of reslicing,
+	// such that OnLost can reuse the capacity of the slice.
+	n := copy(s.retransmissionQueue, s.retransmissionQueue[1:])
+	s.retransmissionQueue[n] = nil
+	s.retransmissionQueue = s.retransmissionQueue[:n]
+	return f, n > 0
 }
 
 func (s *sendStream) hasData() bool {
@@ -332,6 +413,21 @@ func (s *sendStream) hasData() bool {
 }
 
 func (s *sendStream) getDataForWriting(f *wire.StreamFrame, maxBytes protocol.ByteCount) {
+	if b := s.writingOwned; b != nil {
+		n := min(protocol.ByteCount(len(s.dataForWriting)), maxBytes)
+		if b.length == 0 {
+			b.offset = f.Offset
+		}
+		b.length += n
+		f.Data = s.dataForWriting[:n:n]
+		if n == protocol.ByteCount(len(s.dataForWriting)) {
+			s.dataForWriting = nil
+			s.signalWrite()
+		} else {
+			s.dataForWriting = s.dataForWriting[n:]
+		}
+		return
+	}
 	if protocol.ByteCount(len(s.dataForWriting)) <= maxBytes {
 		f.Data = f.Data[:len(s.dataForWriting)]
 		copy(f.Data, s.dataForWriting)
@@ -390,8 +486,12 @@ func (s *sendStream) cancelWriteImpl(errorCode qerr.StreamErrorCode, remote bool
 	s.numOutstandingFrames = 0
 	s.retransmissionQueue = nil
 	newlyCompleted := s.isNewlyCompleted()
+	releases := s.releaseOwnedBuffers()
 	s.mutex.Unlock()
 
+	for _, release := range releases {
+		release()
+	}
 	s.signalWrite()
 	s.sender.queueControlFrame(&wire.ResetStreamFrame{
 		StreamID:  s.streamID,
@@ -430,6 +530,30 @@ func (s *sendStream) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+func (s *sendStream) SetDSCP(dscp uint8) error {
+	if err := validateDSCP(dscp); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.dscp = newDSCPClass(dscp)
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *sendStream) SetPriority(p StreamPriority) error {
+	if err := validatePriority(p); err != nil {
+		return err
+	}
+	s.sender.onStreamPriorityChanged(s.streamID, newStreamPriority(p))
+	return nil
+}
+
+func (s *sendStream) dscpClass() dscpClass {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dscp
+}
+
 // CloseForShutdown closes a stream abruptly.
 // It makes Write unblock (and return the error) immediately.
 // The peer will NOT be informed about this: the stream is closed without sending a FIN or RST.
@@ -437,10 +561,86 @@ func (s *sendStream) closeForShutdown(err error) {
 	s.mutex.Lock()
 	s.ctxCancel(err)
 	s.closeForShutdownErr = err
+	releases := s.releaseOwnedBuffers()
 	s.mutex.Unlock()
+
+	for _, release := range releases {
+		release()
+	}
 	s.signalWrite()
 }
 
+// ownedBufferAt returns the owned buffer that contains the data at offset.
+func (s *sendStream) ownedBufferAt(offset protocol.ByteCount) *ownedBuffer {
+	for _, b := range s.ownedBuffers {
+		if offset >= b.offset && offset < b.offset+b.length {
+			return b
+		}
+	}
+	return nil
+}
+
+// ownedFrameDone is called when a STREAM frame is acknowledged or lost.
+// It returns the release function of the owned buffer that the frame referenced,
+// if that buffer isn't referenced any more.
+func (s *sendStream) ownedFrameDone(offset, dataLen protocol.ByteCount, acked bool) func() {
+	if len(s.ownedBuffers) == 0 || dataLen == 0 {
+		return nil
+	}
+	b := s.ownedBufferAt(offset)
+	if b == nil {
+		return nil
+	}
+	b.inFlight--
+	if acked {
+		b.acked += dataLen
+	}
+	return s.maybeReleaseOwnedBuffer(b)
+}
+
+// maybeReleaseOwnedBuffer removes the owned buffer and returns its release function,
+// if the buffer isn't referenced any more.
+func (s *sendStream) maybeReleaseOwnedBuffer(b *ownedBuffer) func() {
+	if b.writing {
+		return nil
+	}
+	switch {
+	case s.closeForShutdownErr != nil:
+		// closeForShutdown is called from the connection's run loop, so no frames are being packed
+	case s.cancelWriteErr != nil:
+		// lost frames are not retransmitted, but packets in flight might still reference the buffer
+		if b.inFlight > 0 {
+			return nil
+		}
+	default:
+		if b.acked < b.length {
+			return nil
+		}
+	}
+	for i, buf := range s.ownedBuffers {
+		if buf == b {
+			s.ownedBuffers = append(s.ownedBuffers[:i], s.ownedBuffers[i+1:]...)
+			break
+		}
+	}
+	if b.release == nil {
+		return func() {}
+	}
+	return b.release
+}
+
+// releaseOwnedBuffers is called when the stream is canceled or closed.
+// It returns the release functions of all owned buffers that aren't referenced any more.
+func (s *sendStream) releaseOwnedBuffers() []func() {
+	var releases []func()
+	for _, b := range slices.Clone(s.ownedBuffers) {
+		if release := s.maybeReleaseOwnedBuffer(b); release != nil {
+			releases = append(releases, release)
+		}
+	}
+	return releases
+}
+
 // signalWrite performs a non-blocking send on the writeChan
 func (s *sendStream) signalWrite() {
 	select {
@@ -455,10 +655,15 @@ var _ ackhandler.FrameHandler = &sendStreamAckHandler{}
 
 func (s *sendStreamAckHandler) OnAcked(f wire.Frame) {
 	sf := f.(*wire.StreamFrame)
+	offset, dataLen := sf.Offset, sf.DataLen()
 	sf.PutBack()
 	s.mutex.Lock()
+	release := (*sendStream)(s).ownedFrameDone(offset, dataLen, true)
 	if s.cancelWriteErr != nil {
 		s.mutex.Unlock()
+		if release != nil {
+			release()
+		}
 		return
 	}
 	s.numOutstandingFrames--
@@ -468,6 +673,9 @@ func (s *sendStreamAckHandler) OnAcked(f wire.Frame) {
 	newlyCompleted := (*sendStream)(s).isNewlyCompleted()
 	s.mutex.Unlock()
 
+	if release != nil {
+		release()
+	}
 	if newlyCompleted {
 		s.sender.onStreamCompleted(s.streamID)
 	}
@@ -476,6 +684,12 @@ func (s *sendStreamAckHandler) OnAcked(f wire.Frame) {
 func (s *sendStreamAckHandler) OnLost(f wire.Frame) {
 	sf := f.(*wire.StreamFrame)
 	s.mutex.Lock()
+	if release := (*sendStream)(s).ownedFrameDone(sf.Offset, sf.DataLen(), false); release != nil {
+		// This only happens if the stream was canceled or closed.
+		s.mutex.Unlock()
+		release()
+		return
+	}
 	if s.cancelWriteErr != nil {
 		s.mutex.Unlock()
 		return
@@ -490,3 +704,29 @@ func (s *sendStreamAckHandler) OnLost(f wire.Frame) {
 
 	s.sender.onHasStreamData(s.streamID)
 }
+
+// An ownedBuffer is a buffer passed to WriteBuffer.
+// STREAM frames reference its data directly,
+// so it can only be released once all of its data has been acknowledged.
+type ownedBuffer struct {
+	offset   protocol.ByteCount // the stream offset of the first byte
+	length   protocol.ByteCount // the number of bytes that were put into STREAM frames
+	acked    protocol.ByteCount
+	inFlight int  // the number of STREAM frames referencing the buffer that were sent, and neither acknowledged nor lost
+	writing  bool // set while the WriteBuffer call is ongoing
+	release  func()
+}
+
+// readFromBufferSize is the size of the buffers used by ReadFrom.
+const readFromBufferSize = 32 * 1024
+
+var readFromBufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, readFromBufferSize)
+		return &b
+	},
+}
+
+func getReadFromBuffer() *[]byte { return readFromBufferPool.Get().(*[]byte) }
+
+func putReadFromBuffer(b *[]byte) { readFromBufferPool.Put(b) }
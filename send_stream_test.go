@@ -74,6 +74,23 @@ var _ = Describe("Send Stream", func() {
 		Expect(str.StreamID()).To(Equal(protocol.StreamID(1337)))
 	})
 
+	It("sets the DSCP class", func() {
+		Expect(str.dscpClass()).To(Equal(dscpClassDefault))
+		Expect(str.SetDSCP(0)).To(Succeed())
+		Expect(str.dscpClass()).To(Equal(newDSCPClass(0)))
+		Expect(str.dscpClass().resolve(46)).To(BeZero())
+		Expect(str.SetDSCP(46)).To(Succeed())
+		Expect(str.dscpClass().resolve(0)).To(Equal(uint8(46)))
+		Expect(str.SetDSCP(64)).To(MatchError("invalid DSCP: 64"))
+		Expect(str.dscpClass().resolve(0)).To(Equal(uint8(46)))
+	})
+
+	It("sets the priority", func() {
+		mockSender.EXPECT().onStreamPriorityChanged(streamID, newStreamPriority(StreamPriority{Urgency: 1, Incremental: true}))
+		Expect(str.SetPriority(StreamPriority{Urgency: 1, Incremental: true})).To(Succeed())
+		Expect(str.SetPriority(StreamPriority{Urgency: 8})).To(MatchError("invalid urgency: 8"))
+	})
+
 	Context("writing", func() {
 		It("writes and gets all data at once", func() {
 			done := make(chan struct{})
@@ -938,6 +955,177 @@ var _ = Describe("Send Stream", func() {
 		})
 	})
 
+	Context("writing caller-owned buffers", func() {
+		BeforeEach(func() {
+			mockSender.EXPECT().onHasStreamData(streamID).AnyTimes()
+			mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).AnyTimes()
+			mockFC.EXPECT().AddBytesSent(gomock.Any()).AnyTimes()
+		})
+
+		writeBuffer := func(data []byte) (released <-chan struct{}, writeDone <-chan struct{}) {
+			releasedChan := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				n, err := str.WriteBuffer(data, func() { close(releasedChan) })
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(len(data)))
+			}()
+			Eventually(func() bool {
+				str.mutex.Lock()
+				defer str.mutex.Unlock()
+				return str.writingOwned != nil && str.dataForWriting != nil
+			}).Should(BeTrue())
+			return releasedChan, done
+		}
+
+		It("sends the data without copying it, and releases the buffer once all data was acknowledged", func() {
+			data := getData(100)
+			released, done := writeBuffer(data)
+			frame1, ok, hasMore := str.popStreamFrame(60, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			Expect(hasMore).To(BeTrue())
+			Expect(&frame1.Frame.Data[0]).To(BeIdenticalTo(&data[0]))
+			frame2, ok, _ := str.popStreamFrame(protocol.MaxByteCount, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			Expect(frame2.Frame.Offset).To(Equal(frame1.Frame.DataLen()))
+			Expect(&frame2.Frame.Data[0]).To(BeIdenticalTo(&data[frame1.Frame.DataLen()]))
+			Expect(append(frame1.Frame.Data, frame2.Frame.Data...)).To(Equal(getData(100)))
+			Eventually(done).Should(BeClosed())
+			Expect(released).ToNot(BeClosed())
+			frame2.Handler.OnAcked(frame2.Frame)
+			Expect(released).ToNot(BeClosed())
+			frame1.Handler.OnAcked(frame1.Frame)
+			Expect(released).To(BeClosed())
+			Expect(str.ownedBuffers).To(BeEmpty())
+		})
+
+		It("can be mixed with Write", func() {
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			Expect(str.Write([]byte("foo"))).To(Equal(3))
+			released, done := writeBuffer([]byte("bar"))
+			frame1, ok, _ := str.popStreamFrame(protocol.MaxByteCount, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			Expect(frame1.Frame.Data).To(Equal([]byte("foo")))
+			frame2, ok, _ := str.popStreamFrame(protocol.MaxByteCount, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			Expect(frame2.Frame.Offset).To(Equal(protocol.ByteCount(3)))
+			Expect(frame2.Frame.Data).To(Equal([]byte("bar")))
+			Eventually(done).Should(BeClosed())
+			Expect(str.Close()).To(Succeed())
+			frame3, ok, _ := str.popStreamFrame(protocol.MaxByteCount, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			Expect(frame3.Frame.Fin).To(BeTrue())
+			frame1.Handler.OnAcked(frame1.Frame)
+			frame3.Handler.OnAcked(frame3.Frame)
+			Expect(released).ToNot(BeClosed())
+			frame2.Handler.OnAcked(frame2.Frame)
+			Expect(released).To(BeClosed())
+		})
+
+		It("releases the buffer once retransmitted data was acknowledged", func() {
+			released, done := writeBuffer(getData(100))
+			frame, ok, _ := str.popStreamFrame(protocol.MaxByteCount, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			Eventually(done).Should(BeClosed())
+			frame.Handler.OnLost(frame.Frame)
+			// split the retransmission
+			frame1, ok, _ := str.popStreamFrame(60, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			frame2, ok, _ := str.popStreamFrame(protocol.MaxByteCount, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			Expect(append(frame1.Frame.Data, frame2.Frame.Data...)).To(Equal(getData(100)))
+			frame1.Handler.OnAcked(frame1.Frame)
+			Expect(released).ToNot(BeClosed())
+			frame2.Handler.OnAcked(frame2.Frame)
+			Expect(released).To(BeClosed())
+		})
+
+		It("releases the buffer when the stream is canceled, once no frames are in flight", func() {
+			released, done := writeBuffer(getData(100))
+			frame, ok, _ := str.popStreamFrame(protocol.MaxByteCount, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			Eventually(done).Should(BeClosed())
+			mockSender.EXPECT().queueControlFrame(gomock.Any())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			str.CancelWrite(1234)
+			Expect(released).ToNot(BeClosed())
+			frame.Handler.OnLost(frame.Frame)
+			Expect(released).To(BeClosed())
+		})
+
+		It("releases the buffer when the stream is canceled during the write", func() {
+			mockSender.EXPECT().queueControlFrame(gomock.Any())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			released := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := str.WriteBuffer(getData(100), func() { close(released) })
+				Expect(err).To(MatchError(&StreamError{StreamID: streamID, ErrorCode: 1234}))
+			}()
+			waitForWrite()
+			str.CancelWrite(1234)
+			Eventually(done).Should(BeClosed())
+			Expect(released).To(BeClosed())
+		})
+
+		It("releases the buffer when the stream is closed for shutdown", func() {
+			released, done := writeBuffer(getData(100))
+			_, ok, _ := str.popStreamFrame(protocol.MaxByteCount, protocol.Version1)
+			Expect(ok).To(BeTrue())
+			Eventually(done).Should(BeClosed())
+			str.closeForShutdown(errors.New("test error"))
+			Expect(released).To(BeClosed())
+		})
+
+		It("releases the buffer when the deadline expires before any data was sent", func() {
+			str.SetWriteDeadline(time.Now().Add(-time.Second))
+			var released bool
+			_, err := str.WriteBuffer(getData(100), func() { released = true })
+			Expect(err).To(MatchError(errDeadline))
+			Expect(released).To(BeTrue())
+		})
+
+		It("reads data using ReadFrom", func() {
+			data := make([]byte, 100_000)
+			_, err := rand.Read(data)
+			Expect(err).ToNot(HaveOccurred())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				n, err := str.ReadFrom(bytes.NewReader(data))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(BeEquivalentTo(len(data)))
+			}()
+
+			received := make([]byte, 0, len(data))
+			for {
+				f, ok, _ := str.popStreamFrame(protocol.MaxPacketBufferSize, protocol.Version1)
+				if ok {
+					Expect(f.Frame.Offset).To(BeEquivalentTo(len(received)))
+					received = append(received, f.Frame.Data...)
+					f.Handler.OnAcked(f.Frame)
+					continue
+				}
+				select {
+				case <-done:
+				default:
+					runtime.Gosched()
+					continue
+				}
+				if !str.hasData() {
+					break
+				}
+			}
+			Expect(received).To(Equal(data))
+			Expect(str.ownedBuffers).To(BeEmpty())
+		})
+	})
+
 	Context("retransmissions", func() {
 		It("queues and retrieves frames", func() {
 			str.numOutstandingFrames = 1
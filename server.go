@@ -33,6 +33,7 @@ type packetHandlerManager interface {
 	Get(protocol.ConnectionID) (packetHandler, bool)
 	GetByResetToken(protocol.StatelessResetToken) (packetHandler, bool)
 	AddWithConnID(protocol.ConnectionID, protocol.ConnectionID, func() (packetHandler, bool)) bool
+	Range(func(protocol.ConnectionID, packetHandler))
 	Close(error)
 	connRunner
 }
@@ -43,7 +44,9 @@ type quicConn interface {
 	handlePacket(receivedPacket)
 	GetVersion() protocol.VersionNumber
 	getPerspective() protocol.Perspective
+	connectionStats() ConnectionStats
 	run() error
+	runInWorkerPool()
 	destroy(error)
 	shutdown()
 }
@@ -74,6 +77,9 @@ type baseServer struct {
 	connIDGenerator ConnectionIDGenerator
 	connHandler     packetHandlerManager
 	onClose         func()
+	// assignWorker, if set, assigns a new connection to one of the Transports of a TransportGroup.
+	// It returns the connection used to send packets, and the generator for the connection IDs of the new connection.
+	assignWorker func() (rawConn, ConnectionIDGenerator)
 
 	receivedPackets chan receivedPacket
 
@@ -467,8 +473,18 @@ func (s *baseServer) handle0RTTPacket(p receivedPacket) bool {
 		return true
 	}
 
+	// Buffer fewer 0-RTT packets if the Transport's memory budget is under pressure.
+	maxQueues, maxQueueLen := protocol.Max0RTTQueues, protocol.Max0RTTQueueLen
+	underMemoryPressure := s.config.memoryBudget != nil && s.config.memoryBudget.UnderPressure()
+	if underMemoryPressure {
+		maxQueues, maxQueueLen = protocol.Max0RTTQueuesUnderMemoryPressure, protocol.Max0RTTQueueLenUnderMemoryPressure
+	}
+
 	if q, ok := s.zeroRTTQueues[connID]; ok {
-		if len(q.packets) >= protocol.Max0RTTQueueLen {
+		if len(q.packets) >= maxQueueLen {
+			if underMemoryPressure && len(q.packets) < protocol.Max0RTTQueueLen {
+				s.config.memoryBudget.DroppedPacket()
+			}
 			if s.tracer != nil && s.tracer.DroppedPacket != nil {
 				s.tracer.DroppedPacket(p.remoteAddr, logging.PacketType0RTT, p.Size(), logging.PacketDropDOSPrevention)
 			}
@@ -478,7 +494,10 @@ func (s *baseServer) handle0RTTPacket(p receivedPacket) bool {
 		return true
 	}
 
-	if len(s.zeroRTTQueues) >= protocol.Max0RTTQueues {
+	if len(s.zeroRTTQueues) >= maxQueues {
+		if underMemoryPressure && len(s.zeroRTTQueues) < protocol.Max0RTTQueues {
+			s.config.memoryBudget.DroppedPacket()
+		}
 		if s.tracer != nil && s.tracer.DroppedPacket != nil {
 			s.tracer.DroppedPacket(p.remoteAddr, logging.PacketType0RTT, p.Size(), logging.PacketDropDOSPrevention)
 		}
@@ -616,7 +635,11 @@ func (s *baseServer) handleInitialImpl(p receivedPacket, hdr *wire.Header) error
 		return nil
 	}
 
-	connID, err := s.connIDGenerator.GenerateConnectionID()
+	sendConn, connIDGenerator := s.conn, s.connIDGenerator
+	if s.assignWorker != nil {
+		sendConn, connIDGenerator = s.assignWorker()
+	}
+	connID, err := connIDGenerator.GenerateConnectionID()
 	if err != nil {
 		return err
 	}
@@ -632,6 +655,12 @@ func (s *baseServer) handleInitialImpl(p receivedPacket, hdr *wire.Header) error
 				return nil, false
 			}
 			config = populateConfig(conf)
+			config.clock = s.config.clock
+			// The connection is driven by the worker pool below, and its receive memory is accounted
+			// for by the Transport, regardless of the config returned by the callback.
+			config.workerPool = s.config.workerPool
+			config.memoryBudget = s.config.memoryBudget
+			maybeEnableLargePackets(sendConn, config.MaxPacketSize)
 		}
 		var tracer *logging.ConnectionTracer
 		if config.Tracer != nil {
@@ -643,14 +672,14 @@ func (s *baseServer) handleInitialImpl(p receivedPacket, hdr *wire.Header) error
 			tracer = config.Tracer(context.WithValue(context.Background(), ConnectionTracingKey, tracingID), protocol.PerspectiveServer, connID)
 		}
 		conn = s.newConn(
-			newSendConn(s.conn, p.remoteAddr, p.info, s.logger),
+			newSendConn(sendConn, p.remoteAddr, p.info, s.logger),
 			s.connHandler,
 			origDestConnID,
 			retrySrcConnID,
 			hdr.DestConnectionID,
 			hdr.SrcConnectionID,
 			connID,
-			s.connIDGenerator,
+			connIDGenerator,
 			s.connHandler.GetStatelessResetToken(connID),
 			config,
 			s.tlsConf,
@@ -680,7 +709,11 @@ func (s *baseServer) handleInitialImpl(p receivedPacket, hdr *wire.Header) error
 		}
 		return nil
 	}
-	go conn.run()
+	if s.config.workerPool != nil {
+		conn.runInWorkerPool()
+	} else {
+		go conn.run()
+	}
 	go s.handleNewConn(conn)
 	if conn == nil {
 		p.buffer.Release()
@@ -766,7 +799,7 @@ func (s *baseServer) sendRetryPacket(p rejectedPacket) error {
 	if s.tracer != nil && s.tracer.SentPacket != nil {
 		s.tracer.SentPacket(p.remoteAddr, &replyHdr.Header, protocol.ByteCount(len(buf.Data)), nil)
 	}
-	_, err = s.conn.WritePacket(buf.Data, p.remoteAddr, p.info.OOB(), 0, protocol.ECNUnsupported)
+	_, err = s.conn.WritePacket(buf.Data, p.remoteAddr, p.info.OOB(), 0, protocol.ECNUnsupported, 0)
 	return err
 }
 
@@ -851,7 +884,7 @@ func (s *baseServer) sendError(remoteAddr net.Addr, hdr *wire.Header, sealer han
 	if s.tracer != nil && s.tracer.SentPacket != nil {
 		s.tracer.SentPacket(remoteAddr, &replyHdr.Header, protocol.ByteCount(len(b.Data)), []logging.Frame{ccf})
 	}
-	_, err = s.conn.WritePacket(b.Data, remoteAddr, info.OOB(), 0, protocol.ECNUnsupported)
+	_, err = s.conn.WritePacket(b.Data, remoteAddr, info.OOB(), 0, protocol.ECNUnsupported, 0)
 	return err
 }
 
@@ -889,7 +922,7 @@ func (s *baseServer) maybeSendVersionNegotiationPacket(p receivedPacket) {
 	if s.tracer != nil && s.tracer.SentVersionNegotiationPacket != nil {
 		s.tracer.SentVersionNegotiationPacket(p.remoteAddr, src, dest, s.config.Versions)
 	}
-	if _, err := s.conn.WritePacket(data, p.remoteAddr, p.info.OOB(), 0, protocol.ECNUnsupported); err != nil {
+	if _, err := s.conn.WritePacket(data, p.remoteAddr, p.info.OOB(), 0, protocol.ECNUnsupported, 0); err != nil {
 		s.logger.Debugf("Error sending Version Negotiation: %s", err)
 	}
 }
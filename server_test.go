@@ -11,6 +11,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/nxenon/xquic-go/internal/flowcontrol"
 	"github.com/nxenon/xquic-go/internal/handshake"
 	mocklogging "github.com/nxenon/xquic-go/internal/mocks/logging"
 	"github.com/nxenon/xquic-go/internal/protocol"
@@ -1098,6 +1099,57 @@ var _ = Describe("Server", func() {
 				Eventually(done).Should(BeClosed())
 			})
 
+			It("uses the worker pool and the memory budget for connections accepted using GetConfigForClient", func() {
+				conn := NewMockQUICConn(mockCtrl)
+				pool := newWorkerPool(1, nil)
+				defer pool.close()
+				budget := flowcontrol.NewMemoryBudget(1 << 20)
+
+				serv.config = populateServerConfig(&Config{GetConfigForClient: func(*ClientHelloInfo) (*Config, error) { return &Config{}, nil }})
+				serv.config.workerPool = pool
+				serv.config.memoryBudget = budget
+				serv.newConn = func(
+					_ sendConn,
+					_ connRunner,
+					_ protocol.ConnectionID,
+					_ *protocol.ConnectionID,
+					_ protocol.ConnectionID,
+					_ protocol.ConnectionID,
+					_ protocol.ConnectionID,
+					_ ConnectionIDGenerator,
+					_ protocol.StatelessResetToken,
+					conf *Config,
+					_ *tls.Config,
+					_ *handshake.TokenGenerator,
+					_ bool,
+					_ *logging.ConnectionTracer,
+					_ uint64,
+					_ utils.Logger,
+					_ protocol.VersionNumber,
+				) quicConn {
+					Expect(conf.workerPool).To(Equal(pool))
+					Expect(conf.memoryBudget).To(Equal(budget))
+					conn.EXPECT().handlePacket(gomock.Any())
+					conn.EXPECT().HandshakeComplete().Return(make(chan struct{})).MaxTimes(1)
+					conn.EXPECT().Context().Return(context.Background()).MaxTimes(1)
+					conn.EXPECT().destroy(gomock.Any()).MaxTimes(1) // when the server is closed
+					return conn
+				}
+				ran := make(chan struct{})
+				conn.EXPECT().runInWorkerPool().Do(func() { close(ran) })
+				phm.EXPECT().Get(gomock.Any())
+				phm.EXPECT().AddWithConnID(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_, _ protocol.ConnectionID, fn func() (packetHandler, bool)) bool {
+					phm.EXPECT().GetStatelessResetToken(gomock.Any())
+					_, ok := fn()
+					return ok
+				})
+				serv.handleInitialImpl(
+					receivedPacket{buffer: getPacketBuffer()},
+					&wire.Header{DestConnectionID: protocol.ParseConnectionID([]byte{1, 2, 3, 4, 5, 6, 7, 8})},
+				)
+				Eventually(ran).Should(BeClosed())
+			})
+
 			It("rejects a connection attempt when GetConfigClient returns an error", func() {
 				serv.config = populateServerConfig(&Config{GetConfigForClient: func(*ClientHelloInfo) (*Config, error) { return nil, errors.New("rejected") }})
 
@@ -1511,6 +1563,42 @@ var _ = Describe("Server", func() {
 			Eventually(dropped).Should(BeClosed())
 		})
 
+		It("limits the number of queues under memory pressure", func() {
+			budget := flowcontrol.NewMemoryBudget(1000)
+			// reserve the whole budget
+			flowcontrol.NewConnectionFlowController(1000, 1000, nil, nil, budget.NewAccount(), &utils.RTTStats{}, utils.DefaultLogger)
+			Expect(budget.UnderPressure()).To(BeTrue())
+			serv.config.memoryBudget = budget
+
+			for i := 0; i < protocol.Max0RTTQueuesUnderMemoryPressure; i++ {
+				b := make([]byte, 16)
+				rand.Read(b)
+				connID := protocol.ParseConnectionID(b)
+				p := getPacket(&wire.Header{
+					Type:             protocol.PacketType0RTT,
+					DestConnectionID: connID,
+					Version:          serv.config.Versions[0],
+				}, make([]byte, 100+i))
+				phm.EXPECT().Get(connID)
+				serv.handlePacket(p)
+			}
+
+			connID := protocol.ParseConnectionID([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+			p := getPacket(&wire.Header{
+				Type:             protocol.PacketType0RTT,
+				DestConnectionID: connID,
+				Version:          serv.config.Versions[0],
+			}, make([]byte, 200))
+			phm.EXPECT().Get(connID)
+			dropped := make(chan struct{})
+			tracer.EXPECT().DroppedPacket(p.remoteAddr, logging.PacketType0RTT, p.Size(), logging.PacketDropDOSPrevention).Do(func(net.Addr, logging.PacketType, protocol.ByteCount, logging.PacketDropReason) {
+				close(dropped)
+			})
+			serv.handlePacket(p)
+			Eventually(dropped).Should(BeClosed())
+			Expect(budget.PacketsDropped()).To(BeEquivalentTo(1))
+		})
+
 		It("drops queues after a while", func() {
 			now := time.Now()
 
@@ -0,0 +1,177 @@
+package simnet
+
+import (
+	"container/heap"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+)
+
+// A Clock is a virtual clock.
+// Time only advances when Advance is called.
+// It implements the quic.Clock interface.
+type Clock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers timerHeap
+	seq    uint64 // used to fire timers with the same deadline in the order they were set
+}
+
+var _ quic.Clock = &Clock{}
+
+// NewClock creates a new virtual clock, starting at start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the current (virtual) time.
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// NewTimer creates a new timer that fires once the clock was advanced by d.
+func (c *Clock) NewTimer(d time.Duration) quic.ClockTimer {
+	t := &timer{
+		clock: c,
+		c:     make(chan time.Time, 1),
+		index: -1,
+	}
+	t.Reset(d)
+	return t
+}
+
+// Advance advances the clock by d.
+// Timers are fired in the order of their deadlines, and the clock is set to the deadline of each timer when it fires.
+// The goroutines waiting for a timer are given the chance to run (and set new timers)
+// before the next timer is fired.
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	end := c.now.Add(d)
+	c.mutex.Unlock()
+	c.AdvanceTo(end)
+}
+
+// AdvanceTo advances the clock to t.
+// It is a no-op if t is before the current time.
+func (c *Clock) AdvanceTo(t time.Time) {
+	for {
+		c.mutex.Lock()
+		if len(c.timers) == 0 || c.timers[0].deadline.After(t) {
+			if t.After(c.now) {
+				c.now = t
+			}
+			c.mutex.Unlock()
+			return
+		}
+		tm := heap.Pop(&c.timers).(*timer)
+		if tm.deadline.After(c.now) {
+			c.now = tm.deadline
+		}
+		tm.fire(c.now)
+		c.mutex.Unlock()
+		runtime.Gosched()
+	}
+}
+
+// NextDeadline returns the deadline of the next timer.
+// It returns false if no timer is set.
+func (c *Clock) NextDeadline() (time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.timers) == 0 {
+		return time.Time{}, false
+	}
+	return c.timers[0].deadline, true
+}
+
+type timer struct {
+	clock    *Clock
+	c        chan time.Time
+	deadline time.Time
+	seq      uint64
+	index    int // the index in the timerHeap, -1 if the timer is not active
+}
+
+func (t *timer) Chan() <-chan time.Time { return t.c }
+
+// Reset changes the timer to fire after the duration d.
+// It returns true if the timer had been active.
+func (t *timer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	wasActive := t.stop()
+	if d <= 0 {
+		t.fire(c.now)
+		return wasActive
+	}
+	t.deadline = c.now.Add(d)
+	t.seq = c.seq
+	c.seq++
+	heap.Push(&c.timers, t)
+	return wasActive
+}
+
+// Stop prevents the timer from firing.
+// It returns true if the timer had been active.
+func (t *timer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	return t.stop()
+}
+
+func (t *timer) stop() bool {
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&t.clock.timers, t.index)
+	return true
+}
+
+func (t *timer) fire(now time.Time) {
+	// Just like a time.Timer, drop the value if the last value wasn't read yet.
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+type timerHeap []*timer
+
+var _ heap.Interface = &timerHeap{}
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].deadline.Equal(h[j].deadline) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
@@ -0,0 +1,155 @@
+package simnet
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nxenon/xquic-go"
+	"github.com/nxenon/xquic-go/internal/testdata"
+
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestClockNow(t *testing.T) {
+	c := NewClock(start)
+	require.Equal(t, start, c.Now())
+	c.Advance(time.Hour)
+	require.Equal(t, start.Add(time.Hour), c.Now())
+	c.AdvanceTo(start) // no-op, time doesn't run backwards
+	require.Equal(t, start.Add(time.Hour), c.Now())
+}
+
+func TestClockTimer(t *testing.T) {
+	c := NewClock(start)
+	timer := c.NewTimer(time.Second)
+	c.Advance(999 * time.Millisecond)
+	require.Empty(t, timer.Chan())
+	c.Advance(time.Millisecond)
+	require.Equal(t, start.Add(time.Second), <-timer.Chan())
+	_, ok := c.NextDeadline()
+	require.False(t, ok)
+}
+
+func TestClockTimerOrder(t *testing.T) {
+	c := NewClock(start)
+	t1 := c.NewTimer(3 * time.Second)
+	t2 := c.NewTimer(time.Second)
+	t3 := c.NewTimer(2 * time.Second)
+	deadline, ok := c.NextDeadline()
+	require.True(t, ok)
+	require.Equal(t, start.Add(time.Second), deadline)
+
+	// each timer fires at its own deadline, even if the clock is advanced past it
+	c.Advance(time.Minute)
+	require.Equal(t, start.Add(3*time.Second), <-t1.Chan())
+	require.Equal(t, start.Add(time.Second), <-t2.Chan())
+	require.Equal(t, start.Add(2*time.Second), <-t3.Chan())
+	require.Equal(t, start.Add(time.Minute), c.Now())
+}
+
+func TestClockTimerResetAndStop(t *testing.T) {
+	c := NewClock(start)
+	timer := c.NewTimer(time.Second)
+	require.True(t, timer.Reset(time.Hour))
+	c.Advance(time.Minute)
+	require.Empty(t, timer.Chan())
+	require.True(t, timer.Stop())
+	require.False(t, timer.Stop())
+	c.Advance(2 * time.Hour)
+	require.Empty(t, timer.Chan())
+
+	// resetting to a non-positive duration fires immediately
+	require.False(t, timer.Reset(0))
+	require.Equal(t, c.Now(), <-timer.Chan())
+}
+
+func TestClockTimerSetWhileAdvancing(t *testing.T) {
+	c := NewClock(start)
+	timer := c.NewTimer(time.Second)
+	fired := make(chan time.Time, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			v := <-timer.Chan()
+			timer.Reset(time.Second)
+			fired <- v
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		c.Advance(time.Second)
+		require.Equal(t, start.Add(time.Duration(i+1)*time.Second), <-fired)
+	}
+	<-done
+}
+
+func TestConnectionWithVirtualClock(t *testing.T) {
+	clock := NewClock(start)
+	n := NewNetwork()
+
+	serverTr := &quic.Transport{Conn: listen(t, n), Clock: clock}
+	defer serverTr.Close()
+	tlsConf := testdata.GetTLSConfig()
+	tlsConf.NextProtos = []string{"simnet"}
+	ln, err := serverTr.Listen(tlsConf, &quic.Config{MaxIdleTimeout: time.Hour})
+	require.NoError(t, err)
+	defer ln.Close()
+
+	clientTr := &quic.Transport{Conn: listen(t, n), Clock: clock}
+	defer clientTr.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := clientTr.Dial(
+		ctx,
+		ln.Addr(),
+		&tls.Config{ServerName: "localhost", RootCAs: testdata.GetRootCA(), NextProtos: []string{"simnet"}},
+		&quic.Config{MaxIdleTimeout: time.Hour},
+	)
+	require.NoError(t, err)
+	sconn, err := ln.Accept(ctx)
+	require.NoError(t, err)
+
+	// echo the data sent by the client
+	go func() {
+		str, err := sconn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		io.Copy(str, str)
+		str.Close()
+	}()
+	str, err := conn.OpenStream()
+	require.NoError(t, err)
+	_, err = str.Write([]byte("foobar"))
+	require.NoError(t, err)
+	require.NoError(t, str.Close())
+	data, err := io.ReadAll(str)
+	require.NoError(t, err)
+	require.Equal(t, []byte("foobar"), data)
+
+	// 30 minutes of idle time don't kill the connection
+	clock.Advance(30 * time.Minute)
+	select {
+	case <-conn.Context().Done():
+		t.Fatal("connection closed unexpectedly")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// but after 2 hours, both sides time out
+	clock.Advance(2 * time.Hour)
+	for _, c := range []quic.Connection{conn, sconn} {
+		select {
+		case <-c.Context().Done():
+			var idleErr *quic.IdleTimeoutError
+			require.ErrorAs(t, context.Cause(c.Context()), &idleErr)
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	require.Equal(t, start.Add(150*time.Minute), clock.Now())
+}
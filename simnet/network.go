@@ -0,0 +1,252 @@
+// Package simnet provides an in-memory network and a virtual clock for simulation tests.
+//
+// A PacketConn created on a Network can be used as the Conn of a quic.Transport.
+// Setting the Clock of the Transport to a virtual Clock makes the connections use virtual time
+// for loss detection, pacing, and the idle and handshake timeouts.
+// Time then only advances when the test calls Clock.Advance,
+// which allows simulating long periods of time (e.g. hours of idle time) within milliseconds.
+package simnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultQueueLen is the default number of datagrams queued for each PacketConn.
+const DefaultQueueLen = 1024
+
+type datagram struct {
+	data []byte
+	from net.Addr
+}
+
+// A Network is an in-memory network.
+// PacketConns created on the same Network can send datagrams to each other.
+// Delivery is reliable and immediate: a datagram is only lost if the receive queue of the receiver is full,
+// or if DropPacket decides to drop it.
+type Network struct {
+	// QueueLen is the number of datagrams that are queued for each PacketConn.
+	// If 0, DefaultQueueLen is used.
+	QueueLen int
+	// DropPacket is called for every datagram sent on the network.
+	// If it returns true, the datagram is dropped.
+	DropPacket func(from, to net.Addr, b []byte) bool
+
+	mutex    sync.Mutex
+	conns    map[string]*PacketConn
+	nextPort int
+}
+
+// NewNetwork creates a new in-memory network.
+func NewNetwork() *Network {
+	return &Network{
+		conns:    make(map[string]*PacketConn),
+		nextPort: 10000,
+	}
+}
+
+// ListenPacket creates a new PacketConn on the network.
+// If the port of addr is 0, a port is chosen automatically.
+func (n *Network) ListenPacket(addr *net.UDPAddr) (*PacketConn, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	laddr := &net.UDPAddr{IP: addr.IP, Port: addr.Port, Zone: addr.Zone}
+	if laddr.IP == nil {
+		laddr.IP = net.IPv4(127, 0, 0, 1)
+	}
+	if laddr.Port == 0 {
+		for {
+			laddr.Port = n.nextPort
+			n.nextPort++
+			if _, ok := n.conns[laddr.String()]; !ok {
+				break
+			}
+		}
+	}
+	if _, ok := n.conns[laddr.String()]; ok {
+		return nil, fmt.Errorf("simnet: address %s already in use", laddr)
+	}
+	queueLen := n.QueueLen
+	if queueLen == 0 {
+		queueLen = DefaultQueueLen
+	}
+	c := &PacketConn{
+		network:      n,
+		addr:         laddr,
+		queue:        make(chan datagram, queueLen),
+		closeChan:    make(chan struct{}),
+		readDeadline: newDeadline(),
+	}
+	n.conns[laddr.String()] = c
+	return c, nil
+}
+
+func (n *Network) send(from, to net.Addr, b []byte) {
+	if n.DropPacket != nil && n.DropPacket(from, to, b) {
+		return
+	}
+	n.mutex.Lock()
+	c, ok := n.conns[to.String()]
+	n.mutex.Unlock()
+	if !ok {
+		return
+	}
+	c.deliver(datagram{data: append([]byte(nil), b...), from: from})
+}
+
+func (n *Network) remove(c *PacketConn) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.conns[c.addr.String()] == c {
+		delete(n.conns, c.addr.String())
+	}
+}
+
+// A PacketConn is a net.PacketConn on an in-memory Network.
+type PacketConn struct {
+	network *Network
+	addr    *net.UDPAddr
+
+	queue        chan datagram
+	readDeadline *deadline
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+}
+
+var _ net.PacketConn = &PacketConn{}
+
+func (c *PacketConn) deliver(d datagram) {
+	select {
+	case <-c.closeChan:
+	case c.queue <- d:
+	default:
+		// the queue is full, drop the datagram
+	}
+}
+
+// ReadFrom reads a datagram.
+func (c *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case <-c.closeChan:
+		return 0, nil, net.ErrClosed
+	case <-c.readDeadline.wait():
+		return 0, nil, os.ErrDeadlineExceeded
+	default:
+	}
+	select {
+	case <-c.closeChan:
+		return 0, nil, net.ErrClosed
+	case <-c.readDeadline.wait():
+		return 0, nil, os.ErrDeadlineExceeded
+	case d := <-c.queue:
+		return copy(b, d.data), d.from, nil
+	}
+}
+
+// WriteTo sends a datagram to addr.
+// Datagrams sent to addresses that no PacketConn listens on are silently dropped.
+func (c *PacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.closeChan:
+		return 0, net.ErrClosed
+	default:
+	}
+	if addr == nil {
+		return 0, errors.New("simnet: no address")
+	}
+	c.network.send(c.addr, addr, b)
+	return len(b), nil
+}
+
+// Close closes the PacketConn.
+func (c *PacketConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closeChan)
+		c.network.remove(c)
+	})
+	return nil
+}
+
+// LocalAddr returns the local address.
+func (c *PacketConn) LocalAddr() net.Addr { return c.addr }
+
+// SetDeadline sets the read deadline.
+// Writes never block, so there's no write deadline.
+func (c *PacketConn) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+
+// SetReadDeadline sets the read deadline.
+// Deadlines use the system clock, as required by the net.PacketConn interface.
+func (c *PacketConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline is a no-op, since writes never block.
+func (c *PacketConn) SetWriteDeadline(time.Time) error { return nil }
+
+// SetReadBuffer is a no-op. The size of the receive queue is configured by Network.QueueLen.
+func (c *PacketConn) SetReadBuffer(int) error { return nil }
+
+// SetWriteBuffer is a no-op.
+func (c *PacketConn) SetWriteBuffer(int) error { return nil }
+
+// deadline is a deadline that can be waited on, similar to the one used by net.Pipe.
+type deadline struct {
+	mutex  sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{} // closed when the deadline expires
+}
+
+func newDeadline() *deadline {
+	return &deadline{cancel: make(chan struct{})}
+}
+
+func (d *deadline) set(t time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.cancel // wait for the timer callback to finish and close cancel
+	}
+	d.timer = nil
+
+	closed := isClosedChan(d.cancel)
+	if t.IsZero() {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		return
+	}
+	if dur := time.Until(t); dur > 0 {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		cancel := d.cancel
+		d.timer = time.AfterFunc(dur, func() { close(cancel) })
+		return
+	}
+	if !closed {
+		close(d.cancel)
+	}
+}
+
+func (d *deadline) wait() chan struct{} {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.cancel
+}
+
+func isClosedChan(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
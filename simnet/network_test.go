@@ -0,0 +1,127 @@
+package simnet
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func listen(t *testing.T, n *Network) *PacketConn {
+	t.Helper()
+	c, err := n.ListenPacket(&net.UDPAddr{})
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestNetworkSendAndReceive(t *testing.T) {
+	n := NewNetwork()
+	c1 := listen(t, n)
+	c2 := listen(t, n)
+	require.NotEqual(t, c1.LocalAddr().String(), c2.LocalAddr().String())
+
+	b := []byte("foobar")
+	_, err := c1.WriteTo(b, c2.LocalAddr())
+	require.NoError(t, err)
+	b[0] = 'x' // the datagram is copied when it's sent
+
+	buf := make([]byte, 100)
+	num, addr, err := c2.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte("foobar"), buf[:num])
+	require.Equal(t, c1.LocalAddr(), addr)
+}
+
+func TestNetworkAddressInUse(t *testing.T) {
+	n := NewNetwork()
+	c := listen(t, n)
+	_, err := n.ListenPacket(c.LocalAddr().(*net.UDPAddr))
+	require.ErrorContains(t, err, "already in use")
+	require.NoError(t, c.Close())
+	c, err = n.ListenPacket(c.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	c.Close()
+}
+
+func TestNetworkQueueFull(t *testing.T) {
+	n := NewNetwork()
+	n.QueueLen = 2
+	c1 := listen(t, n)
+	c2 := listen(t, n)
+	for i := 0; i < 3; i++ {
+		_, err := c1.WriteTo([]byte{byte(i)}, c2.LocalAddr())
+		require.NoError(t, err)
+	}
+	buf := make([]byte, 10)
+	for i := 0; i < 2; i++ {
+		_, _, err := c2.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(i), buf[0])
+	}
+	c2.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, _, err := c2.ReadFrom(buf)
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestNetworkDropPacket(t *testing.T) {
+	n := NewNetwork()
+	n.DropPacket = func(_, _ net.Addr, b []byte) bool { return b[0] == 'd' }
+	c1 := listen(t, n)
+	c2 := listen(t, n)
+	_, err := c1.WriteTo([]byte("drop"), c2.LocalAddr())
+	require.NoError(t, err)
+	_, err = c1.WriteTo([]byte("keep"), c2.LocalAddr())
+	require.NoError(t, err)
+	buf := make([]byte, 10)
+	num, _, err := c2.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte("keep"), buf[:num])
+}
+
+func TestNetworkReadDeadline(t *testing.T) {
+	n := NewNetwork()
+	c := listen(t, n)
+	errChan := make(chan error, 1)
+	go func() {
+		_, _, err := c.ReadFrom(make([]byte, 10))
+		errChan <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, c.SetReadDeadline(time.Now()))
+	select {
+	case err := <-errChan:
+		require.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+
+	// resetting the deadline allows reading again
+	require.NoError(t, c.SetReadDeadline(time.Time{}))
+	_, err := c.WriteTo([]byte("foo"), c.LocalAddr())
+	require.NoError(t, err)
+	_, _, err = c.ReadFrom(make([]byte, 10))
+	require.NoError(t, err)
+}
+
+func TestNetworkClose(t *testing.T) {
+	n := NewNetwork()
+	c := listen(t, n)
+	errChan := make(chan error, 1)
+	go func() {
+		_, _, err := c.ReadFrom(make([]byte, 10))
+		errChan <- err
+	}()
+	require.NoError(t, c.Close())
+	select {
+	case err := <-errChan:
+		require.ErrorIs(t, err, net.ErrClosed)
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	_, err := c.WriteTo([]byte("foo"), c.LocalAddr())
+	require.ErrorIs(t, err, net.ErrClosed)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -83,15 +84,26 @@ func wrapConn(pc net.PacketConn) (rawConn, error) {
 type basicConn struct {
 	net.PacketConn
 	supportsDF bool
+
+	largePackets atomic.Bool // set when the connection might receive packets larger than protocol.MaxPacketBufferSize
 }
 
-var _ rawConn = &basicConn{}
+var (
+	_ rawConn         = &basicConn{}
+	_ largePacketConn = &basicConn{}
+)
 
 func (c *basicConn) ReadPacket() (receivedPacket, error) {
-	buffer := getPacketBuffer()
-	// The packet size should not exceed protocol.MaxPacketBufferSize bytes
-	// If it does, we only read a truncated packet, which will then end up undecryptable
-	buffer.Data = buffer.Data[:protocol.MaxPacketBufferSize]
+	var buffer *packetBuffer
+	if c.largePackets.Load() {
+		buffer = getLargePacketBuffer()
+		buffer.Data = buffer.Data[:protocol.MaxLargePacketBufferSize]
+	} else {
+		buffer = getPacketBuffer()
+		// The packet size should not exceed protocol.MaxPacketBufferSize bytes
+		// If it does, we only read a truncated packet, which will then end up undecryptable
+		buffer.Data = buffer.Data[:protocol.MaxPacketBufferSize]
+	}
 	n, addr, err := c.PacketConn.ReadFrom(buffer.Data)
 	if err != nil {
 		return receivedPacket{}, err
@@ -104,7 +116,7 @@ func (c *basicConn) ReadPacket() (receivedPacket, error) {
 	}, nil
 }
 
-func (c *basicConn) WritePacket(b []byte, addr net.Addr, _ []byte, gsoSize uint16, ecn protocol.ECN) (n int, err error) {
+func (c *basicConn) WritePacket(b []byte, addr net.Addr, _ []byte, gsoSize uint16, ecn protocol.ECN, _ uint8) (n int, err error) {
 	if gsoSize != 0 {
 		panic("cannot use GSO with a basicConn")
 	}
@@ -115,3 +127,22 @@ func (c *basicConn) WritePacket(b []byte, addr net.Addr, _ []byte, gsoSize uint1
 }
 
 func (c *basicConn) capabilities() connCapabilities { return connCapabilities{DF: c.supportsDF} }
+
+func (c *basicConn) enableLargePackets() { c.largePackets.Store(true) }
+
+// A largePacketConn can receive packets larger than protocol.MaxPacketBufferSize,
+// once enableLargePackets was called.
+type largePacketConn interface {
+	enableLargePackets()
+}
+
+// maybeEnableLargePackets makes the conn use receive buffers that are large enough
+// for packets of maxPacketSize bytes, if that's larger than protocol.MaxPacketBufferSize.
+func maybeEnableLargePackets(c rawConn, maxPacketSize uint16) {
+	if maxPacketSize <= protocol.MaxPacketBufferSize {
+		return
+	}
+	if lc, ok := c.(largePacketConn); ok {
+		lc.enableLargePackets()
+	}
+}
@@ -13,6 +13,8 @@ import (
 const (
 	msgTypeIPTOS = unix.IP_RECVTOS
 	ipv4PKTINFO  = unix.IP_RECVPKTINFO
+	// GRO is not supported
+	msgTypeUDPGRO = -1
 )
 
 const ecnIPv4DataLen = 4
@@ -34,3 +36,4 @@ func parseIPv4PktInfo(body []byte) (ip netip.Addr, ifIndex uint32, ok bool) {
 }
 
 func isGSOSupported(syscall.RawConn) bool { return false }
+func enableGRO(syscall.RawConn) bool      { return false }
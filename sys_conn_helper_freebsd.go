@@ -12,6 +12,8 @@ import (
 const (
 	msgTypeIPTOS = unix.IP_RECVTOS
 	ipv4PKTINFO  = 0x7
+	// GRO is not supported
+	msgTypeUDPGRO = -1
 )
 
 const ecnIPv4DataLen = 1
@@ -29,3 +31,4 @@ func parseIPv4PktInfo(body []byte) (ip netip.Addr, _ uint32, ok bool) {
 }
 
 func isGSOSupported(syscall.RawConn) bool { return false }
+func enableGRO(syscall.RawConn) bool      { return false }
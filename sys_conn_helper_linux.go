@@ -15,8 +15,9 @@ import (
 )
 
 const (
-	msgTypeIPTOS = unix.IP_TOS
-	ipv4PKTINFO  = unix.IP_PKTINFO
+	msgTypeIPTOS  = unix.IP_TOS
+	ipv4PKTINFO   = unix.IP_PKTINFO
+	msgTypeUDPGRO = unix.UDP_GRO
 )
 
 const ecnIPv4DataLen = 1
@@ -71,6 +72,22 @@ func isGSOSupported(conn syscall.RawConn) bool {
 	return serr == nil
 }
 
+// enableGRO enables UDP GRO (Generic Receive Offload) on the socket.
+// If enabled, the kernel coalesces multiple datagrams received from the same sender into a single large datagram.
+func enableGRO(conn syscall.RawConn) bool {
+	disabled, err := strconv.ParseBool(os.Getenv("QUIC_GO_DISABLE_GRO"))
+	if err == nil && disabled {
+		return false
+	}
+	var serr error
+	if err := conn.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_UDP, unix.UDP_GRO, 1)
+	}); err != nil {
+		return false
+	}
+	return serr == nil
+}
+
 func appendUDPSegmentSizeMsg(b []byte, size uint16) []byte {
 	startLen := len(b)
 	const dataLen = 2 // payload is a uint16
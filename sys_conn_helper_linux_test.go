@@ -6,6 +6,8 @@ import (
 	"errors"
 	"net"
 	"os"
+	"syscall"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 
@@ -79,3 +81,17 @@ var _ = Describe("forcing a change of send and receive buffer sizes", func() {
 		Expect(isGSOError(errors.New("test"))).To(BeFalse())
 	})
 })
+
+var platformSupportsGRO = true
+
+func appendGROMsg(b []byte, segmentSize int32) []byte {
+	startLen := len(b)
+	const dataLen = 4 // payload is an int
+	b = append(b, make([]byte, unix.CmsgSpace(dataLen))...)
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&b[startLen]))
+	h.Level = syscall.IPPROTO_UDP
+	h.Type = unix.UDP_GRO
+	h.SetLen(unix.CmsgLen(dataLen))
+	*(*int32)(unsafe.Pointer(&b[startLen+unix.CmsgSpace(0)])) = segmentSize
+	return b
+}
@@ -8,3 +8,7 @@ var (
 	errGSO          = errors.New("fake GSO error")
 	errNotPermitted = errors.New("fake not permitted error")
 )
+
+var platformSupportsGRO = false
+
+func appendGROMsg([]byte, int32) []byte { return nil }
@@ -0,0 +1,156 @@
+//go:build linux && (amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x)
+
+package quic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/sys/unix"
+)
+
+// mmsghdr is the struct mmsghdr used by recvmmsg(2).
+type mmsghdr struct {
+	hdr unix.Msghdr
+	len uint32
+}
+
+// mmsgConn reads batches of packets using recvmmsg(2).
+// In contrast to ipv4.PacketConn.ReadBatch, it doesn't allocate a new net.UDPAddr for every packet.
+// As long as packets are received from the same peer, the net.UDPAddr of the last packet is reused.
+// It only supports messages using a single buffer.
+type mmsgConn struct {
+	rawConn syscall.RawConn
+
+	hdrs  [batchSize]mmsghdr
+	iovs  [batchSize]unix.Iovec
+	names [batchSize][unix.SizeofSockaddrInet6]byte
+
+	lastName [unix.SizeofSockaddrInet6]byte
+	lastLen  uint32
+	lastAddr *net.UDPAddr
+
+	// The function passed to rawConn.Read, and its arguments and results.
+	// It is created once, so that reading doesn't allocate.
+	readFn      func(fd uintptr) bool
+	num, flags  int
+	numReceived int
+	errno       syscall.Errno
+}
+
+var _ batchConn = &mmsgConn{}
+
+func newMmsgConn(rawConn syscall.RawConn) batchConn {
+	c := &mmsgConn{rawConn: rawConn}
+	c.readFn = func(fd uintptr) bool {
+		for {
+			n, _, errno := unix.Syscall6(unix.SYS_RECVMMSG, fd, uintptr(unsafe.Pointer(&c.hdrs[0])), uintptr(c.num), uintptr(c.flags), 0, 0)
+			if errno == unix.EINTR {
+				continue
+			}
+			if errno == unix.EAGAIN || errno == unix.EWOULDBLOCK {
+				return false // wait until the socket becomes readable
+			}
+			c.numReceived = int(n)
+			c.errno = errno
+			return true
+		}
+	}
+	return c
+}
+
+func (c *mmsgConn) ReadBatch(ms []ipv4.Message, flags int) (int, error) {
+	num := min(len(ms), batchSize)
+	for i := 0; i < num; i++ {
+		m := &ms[i]
+		if len(m.Buffers) != 1 || len(m.Buffers[0]) == 0 {
+			return 0, errors.New("mmsgConn only supports messages with a single buffer")
+		}
+		c.iovs[i].Base = &m.Buffers[0][0]
+		c.iovs[i].SetLen(len(m.Buffers[0]))
+		h := &c.hdrs[i]
+		h.len = 0
+		h.hdr.Name = &c.names[i][0]
+		h.hdr.Namelen = unix.SizeofSockaddrInet6
+		h.hdr.Iov = &c.iovs[i]
+		h.hdr.SetIovlen(1)
+		if len(m.OOB) > 0 {
+			h.hdr.Control = &m.OOB[0]
+			h.hdr.SetControllen(len(m.OOB))
+		} else {
+			h.hdr.Control = nil
+			h.hdr.SetControllen(0)
+		}
+		h.hdr.Flags = 0
+	}
+	c.num = num
+	c.flags = flags
+	if err := c.rawConn.Read(c.readFn); err != nil {
+		return 0, err
+	}
+	if c.errno != 0 {
+		return 0, os.NewSyscallError("recvmmsg", c.errno)
+	}
+	for i := 0; i < c.numReceived; i++ {
+		h := &c.hdrs[i]
+		addr, err := c.parseAddr(i, h.hdr.Namelen)
+		if err != nil {
+			return i, err
+		}
+		ms[i].Addr = addr
+		ms[i].N = int(h.len)
+		ms[i].NN = int(h.hdr.Controllen)
+		ms[i].Flags = int(h.hdr.Flags)
+	}
+	return c.numReceived, nil
+}
+
+// parseAddr parses the socket address of the i-th message.
+// If it's the same address as the one of the previous message, the previous net.UDPAddr is returned.
+func (c *mmsgConn) parseAddr(i int, l uint32) (*net.UDPAddr, error) {
+	if l > unix.SizeofSockaddrInet6 {
+		l = unix.SizeofSockaddrInet6
+	}
+	b := c.names[i][:l]
+	if c.lastAddr != nil && l == c.lastLen && bytes.Equal(b, c.lastName[:l]) {
+		return c.lastAddr, nil
+	}
+	if len(b) < 2 {
+		return nil, errors.New("invalid address")
+	}
+	var addr *net.UDPAddr
+	switch binary.NativeEndian.Uint16(b[:2]) {
+	case unix.AF_INET:
+		if len(b) < unix.SizeofSockaddrInet4 {
+			return nil, errors.New("short address")
+		}
+		addr = &net.UDPAddr{IP: net.IP(b[4:8]), Port: int(binary.BigEndian.Uint16(b[2:4]))}
+	case unix.AF_INET6:
+		if len(b) < unix.SizeofSockaddrInet6 {
+			return nil, errors.New("short address")
+		}
+		addr = &net.UDPAddr{IP: net.IP(b[8:24]), Port: int(binary.BigEndian.Uint16(b[2:4]))}
+		if id := int(binary.NativeEndian.Uint32(b[24:28])); id > 0 {
+			if ifi, err := net.InterfaceByIndex(id); err == nil {
+				addr.Zone = ifi.Name
+			} else {
+				addr.Zone = strconv.Itoa(id)
+			}
+		}
+	default:
+		return nil, errors.New("invalid address family")
+	}
+	// The IP references c.names, which is overwritten by the next call to ReadBatch.
+	addr.IP = append(net.IP(nil), addr.IP...)
+	copy(c.lastName[:], b)
+	c.lastLen = l
+	c.lastAddr = addr
+	return addr, nil
+}
@@ -0,0 +1,177 @@
+//go:build linux && (amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x)
+
+package quic
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nxenon/xquic-go/internal/protocol"
+
+	"golang.org/x/net/ipv4"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("reading packets using recvmmsg", func() {
+	newMessages := func() []ipv4.Message {
+		msgs := make([]ipv4.Message, batchSize)
+		for i := range msgs {
+			msgs[i].Buffers = [][]byte{make([]byte, 100)}
+			msgs[i].OOB = make([]byte, oobBufferSize)
+		}
+		return msgs
+	}
+
+	newConns := func(network, addr string) (*net.UDPConn, batchConn) {
+		conn, err := net.ListenUDP(network, &net.UDPAddr{IP: net.ParseIP(addr)})
+		Expect(err).ToNot(HaveOccurred())
+		rawConn, err := conn.SyscallConn()
+		Expect(err).ToNot(HaveOccurred())
+		bc := newMmsgConn(rawConn)
+		Expect(bc).ToNot(BeNil())
+		return conn, bc
+	}
+
+	for _, v := range []struct{ network, addr string }{{"udp4", "127.0.0.1"}, {"udp6", "::1"}} {
+		network, addr := v.network, v.addr
+
+		It("reads a batch of packets, on "+network, func() {
+			conn, bc := newConns(network, addr)
+			defer conn.Close()
+			sender, err := net.ListenUDP(network, &net.UDPAddr{IP: net.ParseIP(addr)})
+			Expect(err).ToNot(HaveOccurred())
+			defer sender.Close()
+			for _, s := range []string{"foo", "foobar", "raboof"} {
+				_, err := sender.WriteTo([]byte(s), conn.LocalAddr())
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			msgs := newMessages()
+			var received []string
+			var addrs []net.Addr
+			Eventually(func() int {
+				n, err := bc.ReadBatch(msgs, 0)
+				Expect(err).ToNot(HaveOccurred())
+				for _, m := range msgs[:n] {
+					received = append(received, string(m.Buffers[0][:m.N]))
+					addrs = append(addrs, m.Addr)
+				}
+				return len(received)
+			}).Should(Equal(3))
+			Expect(received).To(Equal([]string{"foo", "foobar", "raboof"}))
+			for _, a := range addrs {
+				Expect(a).To(BeAssignableToTypeOf(&net.UDPAddr{}))
+				Expect(a.String()).To(Equal(sender.LocalAddr().String()))
+				// the address is reused for subsequent packets from the same peer
+				Expect(a).To(BeIdenticalTo(addrs[0]))
+			}
+		})
+	}
+
+	It("doesn't reuse the address for packets from different peers", func() {
+		conn, bc := newConns("udp4", "127.0.0.1")
+		defer conn.Close()
+		sender1, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		defer sender1.Close()
+		sender2, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		defer sender2.Close()
+
+		msgs := newMessages()
+		readAddr := func() net.Addr {
+			n, err := bc.ReadBatch(msgs, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(1))
+			return msgs[0].Addr
+		}
+		_, err = sender1.WriteTo([]byte("foo"), conn.LocalAddr())
+		Expect(err).ToNot(HaveOccurred())
+		addr1 := readAddr()
+		Expect(addr1.String()).To(Equal(sender1.LocalAddr().String()))
+		_, err = sender2.WriteTo([]byte("bar"), conn.LocalAddr())
+		Expect(err).ToNot(HaveOccurred())
+		addr2 := readAddr()
+		Expect(addr2.String()).To(Equal(sender2.LocalAddr().String()))
+		// the previously returned address is not modified
+		Expect(addr1.String()).To(Equal(sender1.LocalAddr().String()))
+	})
+
+	It("returns an error when the connection is closed", func() {
+		conn, bc := newConns("udp4", "127.0.0.1")
+		errChan := make(chan error, 1)
+		go func() {
+			_, err := bc.ReadBatch(newMessages(), 0)
+			errChan <- err
+		}()
+		Consistently(errChan, scaleDuration(20*time.Millisecond)).ShouldNot(Receive())
+		Expect(conn.Close()).To(Succeed())
+		Eventually(errChan).Should(Receive(MatchError(net.ErrClosed)))
+	})
+})
+
+// BenchmarkReadBatch compares reading batches of packets using recvmmsg directly
+// with ipv4.PacketConn.ReadBatch, which allocates a new net.UDPAddr for every packet.
+func BenchmarkReadBatch(b *testing.B) {
+	for _, v := range []struct {
+		name         string
+		newBatchConn func(*net.UDPConn) batchConn
+	}{
+		{
+			name: "recvmmsg",
+			newBatchConn: func(conn *net.UDPConn) batchConn {
+				rawConn, err := conn.SyscallConn()
+				if err != nil {
+					b.Fatal(err)
+				}
+				return newMmsgConn(rawConn)
+			},
+		},
+		{
+			name:         "x/net/ipv4",
+			newBatchConn: func(conn *net.UDPConn) batchConn { return ipv4.NewPacketConn(conn) },
+		},
+	} {
+		b.Run(v.name, func(b *testing.B) {
+			conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer conn.Close()
+			sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer sender.Close()
+			addr := conn.LocalAddr().(*net.UDPAddr).AddrPort()
+			bc := v.newBatchConn(conn)
+			msgs := make([]ipv4.Message, batchSize)
+			for i := range msgs {
+				msgs[i].Buffers = [][]byte{make([]byte, protocol.MaxPacketBufferSize)}
+				msgs[i].OOB = make([]byte, oobBufferSize)
+			}
+			packet := make([]byte, 1200)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < batchSize; j++ {
+					if _, err := sender.WriteToUDPAddrPort(packet, addr); err != nil {
+						b.Fatal(err)
+					}
+				}
+				for received := 0; received < batchSize; {
+					n, err := bc.ReadBatch(msgs, 0)
+					if err != nil {
+						b.Fatal(err)
+					}
+					received += n
+				}
+			}
+			b.ReportMetric(float64(batchSize), "packets/op")
+		})
+	}
+}
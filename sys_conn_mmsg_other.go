@@ -0,0 +1,9 @@
+//go:build (darwin || freebsd || linux) && !(linux && (amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x))
+
+package quic
+
+import "syscall"
+
+// newMmsgConn returns nil on this platform.
+// ipv4.PacketConn is used for reading batches of packets instead.
+func newMmsgConn(syscall.RawConn) batchConn { return nil }
@@ -11,6 +11,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -67,16 +68,28 @@ func isECNDisabled() bool {
 type oobConn struct {
 	OOBCapablePacketConn
 	batchConn batchConn
+	// Set if the underlying connection is a *net.UDPConn.
+	// In contrast to WriteMsgUDP, its WriteMsgUDPAddrPort method doesn't allocate when converting the address.
+	udpConn *net.UDPConn
 
 	readPos uint8
 	// Packets received from the kernel, but not yet returned by ReadPacket().
 	messages []ipv4.Message
 	buffers  [batchSize]*packetBuffer
 
+	// Packets split off a coalesced GRO datagram, but not yet returned by ReadPacket().
+	segments   []receivedPacket
+	segmentPos int
+
+	largePackets atomic.Bool // set when the connection might receive packets larger than protocol.MaxPacketBufferSize
+
 	cap connCapabilities
 }
 
-var _ rawConn = &oobConn{}
+var (
+	_ rawConn         = &oobConn{}
+	_ largePacketConn = &oobConn{}
+)
 
 func newConn(c OOBCapablePacketConn, supportsDF bool) (*oobConn, error) {
 	rawConn, err := c.SyscallConn()
@@ -126,11 +139,13 @@ func newConn(c OOBCapablePacketConn, supportsDF bool) (*oobConn, error) {
 	}
 
 	// Allows callers to pass in a connection that already satisfies batchConn interface
-	// to make use of the optimisation. Otherwise, ipv4.NewPacketConn would unwrap the file descriptor
-	// via SyscallConn(), and read it that way, which might not be what the caller wants.
+	// to make use of the optimisation. Otherwise, we unwrap the file descriptor via SyscallConn(),
+	// and read it that way (using recvmmsg directly where possible), which might not be what the caller wants.
 	var bc batchConn
 	if ibc, ok := c.(batchConn); ok {
 		bc = ibc
+	} else if mc := newMmsgConn(rawConn); mc != nil {
+		bc = mc
 	} else {
 		bc = ipv4.NewPacketConn(c)
 	}
@@ -148,24 +163,62 @@ func newConn(c OOBCapablePacketConn, supportsDF bool) (*oobConn, error) {
 		cap: connCapabilities{
 			DF:  supportsDF,
 			GSO: isGSOSupported(rawConn),
+			GRO: enableGRO(rawConn),
 			ECN: !isECNDisabled(),
 		},
 	}
+	// Only use the fast path for a plain *net.UDPConn.
+	// Wrappers might override WriteMsgUDP, and we need to respect that.
+	if uc, ok := c.(*net.UDPConn); ok {
+		oobConn.udpConn = uc
+	}
 	for i := 0; i < batchSize; i++ {
 		oobConn.messages[i].OOB = make([]byte, oobBufferSize)
 	}
+	if oobConn.cap.GRO {
+		utils.DefaultLogger.Debugf("Activating UDP GRO.")
+	}
 	return oobConn, nil
 }
 
-var invalidCmsgOnceV4, invalidCmsgOnceV6 sync.Once
+var invalidCmsgOnceV4, invalidCmsgOnceV6, invalidCmsgOnceGRO sync.Once
+
+// getReceiveBuffer gets a buffer to read a datagram into.
+// With GRO, the kernel coalesces up to 64 KiB of datagrams, so we need a buffer that can hold all of them.
+// Datagrams that weren't coalesced are copied into a smaller buffer after reading, see copyToPacketBuffer.
+func (c *oobConn) getReceiveBuffer() *packetBuffer {
+	if c.cap.GRO {
+		buffer := getGROBuffer()
+		buffer.Data = buffer.Data[:protocol.MaxGROBufferSize]
+		return buffer
+	}
+	if c.largePackets.Load() {
+		buffer := getLargePacketBuffer()
+		buffer.Data = buffer.Data[:protocol.MaxLargePacketBufferSize]
+		return buffer
+	}
+	buffer := getPacketBuffer()
+	buffer.Data = buffer.Data[:protocol.MaxPacketBufferSize]
+	return buffer
+}
 
 func (c *oobConn) ReadPacket() (receivedPacket, error) {
+	if c.segmentPos < len(c.segments) {
+		p := c.segments[c.segmentPos]
+		c.segments[c.segmentPos] = receivedPacket{}
+		c.segmentPos++
+		if c.segmentPos == len(c.segments) {
+			c.segments = c.segments[:0]
+			c.segmentPos = 0
+		}
+		return p, nil
+	}
+
 	if len(c.messages) == int(c.readPos) { // all messages read. Read the next batch of messages.
 		c.messages = c.messages[:batchSize]
 		// replace buffers data buffers up to the packet that has been consumed during the last ReadBatch call
 		for i := uint8(0); i < c.readPos; i++ {
-			buffer := getPacketBuffer()
-			buffer.Data = buffer.Data[:protocol.MaxPacketBufferSize]
+			buffer := c.getReceiveBuffer()
 			c.buffers[i] = buffer
 			c.messages[i].Buffers[0] = c.buffers[i].Data
 		}
@@ -189,6 +242,7 @@ func (c *oobConn) ReadPacket() (receivedPacket, error) {
 		data:       msg.Buffers[0][:msg.N],
 		buffer:     buffer,
 	}
+	var segmentSize int
 	for len(data) > 0 {
 		hdr, body, remainder, err := unix.ParseOneSocketControlMessage(data)
 		if err != nil {
@@ -231,13 +285,73 @@ func (c *oobConn) ReadPacket() (receivedPacket, error) {
 				}
 			}
 		}
+		if hdr.Level == unix.IPPROTO_UDP && hdr.Type == msgTypeUDPGRO {
+			// The segment size is an int.
+			if len(body) == 4 {
+				segmentSize = int(*(*int32)(unsafe.Pointer(&body[0])))
+			} else {
+				invalidCmsgOnceGRO.Do(func() {
+					log.Printf("Received invalid UDP GRO control message: %+x. "+
+						"This should never occur, please open a new issue and include details about the architecture.", body)
+				})
+			}
+		}
 		data = remainder
 	}
+	if segmentSize > 0 && len(p.data) > segmentSize {
+		return c.splitCoalesced(p, segmentSize, msg.Flags&unix.MSG_TRUNC > 0), nil
+	}
+	if c.cap.GRO && len(p.data) <= protocol.MaxLargePacketBufferSize {
+		return copyToPacketBuffer(p), nil
+	}
 	return p, nil
 }
 
+// copyToPacketBuffer copies a datagram that was received into a GRO buffer, but wasn't coalesced,
+// into a regular packet buffer, and puts the GRO buffer back into the pool right away.
+// Otherwise, every packet waiting to be handled would hold on to a 64 KiB buffer.
+func copyToPacketBuffer(p receivedPacket) receivedPacket {
+	buffer := getPacketBufferForSize(protocol.ByteCount(len(p.data)))
+	buffer.Data = append(buffer.Data, p.data...)
+	p.buffer.Release()
+	p.buffer = buffer
+	p.data = buffer.Data
+	return p
+}
+
+// splitCoalesced splits a datagram coalesced by GRO into its segments.
+// All segments have the same size, except for the last one, which might be shorter.
+// The segments are not copied, they reference the buffer of the coalesced datagram.
+// Since they might be handled by different connections, every segment uses its own packetBuffer,
+// and the buffer of the datagram is put back once all segments have been released.
+// If the datagram was truncated, the last segment is dropped.
+func (c *oobConn) splitCoalesced(p receivedPacket, segmentSize int, truncated bool) receivedPacket {
+	data := p.data
+	if truncated {
+		data = data[:len(data)-len(data)%segmentSize]
+	}
+	parent := p.buffer
+	parent.segments.Store(int32((len(data) + segmentSize - 1) / segmentSize))
+	var first receivedPacket
+	for i := 0; len(data) > 0; i++ {
+		size := min(segmentSize, len(data))
+		segment := p
+		segment.data = data[:size:size]
+		segment.buffer = getSegmentBuffer(parent, segment.data)
+		if i == 0 {
+			first = segment
+		} else {
+			c.segments = append(c.segments, segment)
+		}
+		data = data[size:]
+	}
+	return first
+}
+
+func (c *oobConn) enableLargePackets() { c.largePackets.Store(true) }
+
 // WritePacket writes a new packet.
-func (c *oobConn) WritePacket(b []byte, addr net.Addr, packetInfoOOB []byte, gsoSize uint16, ecn protocol.ECN) (int, error) {
+func (c *oobConn) WritePacket(b []byte, addr net.Addr, packetInfoOOB []byte, gsoSize uint16, ecn protocol.ECN, dscp uint8) (int, error) {
 	oob := packetInfoOOB
 	if gsoSize > 0 {
 		if !c.capabilities().GSO {
@@ -245,19 +359,30 @@ func (c *oobConn) WritePacket(b []byte, addr net.Addr, packetInfoOOB []byte, gso
 		}
 		oob = appendUDPSegmentSizeMsg(oob, gsoSize)
 	}
-	if ecn != protocol.ECNUnsupported {
-		if !c.capabilities().ECN {
-			panic("tried to send a ECN-marked packet although ECN is disabled")
+	if ecn != protocol.ECNUnsupported || dscp != 0 {
+		tos := dscp << 2
+		if ecn != protocol.ECNUnsupported {
+			if !c.capabilities().ECN {
+				panic("tried to send a ECN-marked packet although ECN is disabled")
+			}
+			tos |= ecn.ToHeaderBits()
 		}
 		if remoteUDPAddr, ok := addr.(*net.UDPAddr); ok {
 			if remoteUDPAddr.IP.To4() != nil {
-				oob = appendIPv4ECNMsg(oob, ecn)
+				oob = appendIPv4TOSMsg(oob, tos)
 			} else {
-				oob = appendIPv6ECNMsg(oob, ecn)
+				oob = appendIPv6TrafficClassMsg(oob, tos)
 			}
 		}
 	}
-	n, _, err := c.OOBCapablePacketConn.WriteMsgUDP(b, oob, addr.(*net.UDPAddr))
+	udpAddr := addr.(*net.UDPAddr)
+	if c.udpConn != nil {
+		if ap := udpAddr.AddrPort(); ap.Addr().IsValid() {
+			n, _, err := c.udpConn.WriteMsgUDPAddrPort(b, oob, ap)
+			return n, err
+		}
+	}
+	n, _, err := c.OOBCapablePacketConn.WriteMsgUDP(b, oob, udpAddr)
 	return n, err
 }
 
@@ -301,7 +426,9 @@ func (info *packetInfo) OOB() []byte {
 	return nil
 }
 
-func appendIPv4ECNMsg(b []byte, val protocol.ECN) []byte {
+// appendIPv4TOSMsg appends an IP_TOS control message.
+// The TOS byte contains the DSCP in the upper 6 bits, and the ECN codepoint in the lower 2 bits.
+func appendIPv4TOSMsg(b []byte, tos byte) []byte {
 	startLen := len(b)
 	b = append(b, make([]byte, unix.CmsgSpace(ecnIPv4DataLen))...)
 	h := (*unix.Cmsghdr)(unsafe.Pointer(&b[startLen]))
@@ -311,11 +438,13 @@ func appendIPv4ECNMsg(b []byte, val protocol.ECN) []byte {
 
 	// UnixRights uses the private `data` method, but I *think* this achieves the same goal.
 	offset := startLen + unix.CmsgSpace(0)
-	b[offset] = val.ToHeaderBits()
+	b[offset] = tos
 	return b
 }
 
-func appendIPv6ECNMsg(b []byte, val protocol.ECN) []byte {
+// appendIPv6TrafficClassMsg appends an IPV6_TCLASS control message.
+// The Traffic Class has the same layout as the IPv4 TOS byte.
+func appendIPv6TrafficClassMsg(b []byte, tc byte) []byte {
 	startLen := len(b)
 	const dataLen = 4
 	b = append(b, make([]byte, unix.CmsgSpace(dataLen))...)
@@ -326,6 +455,6 @@ func appendIPv6ECNMsg(b []byte, val protocol.ECN) []byte {
 
 	// UnixRights uses the private `data` method, but I *think* this achieves the same goal.
 	offset := startLen + unix.CmsgSpace(0)
-	b[offset] = val.ToHeaderBits()
+	b[offset] = tc
 	return b
 }
@@ -3,8 +3,11 @@
 package quic
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"time"
 
 	"golang.org/x/net/ipv4"
@@ -149,7 +152,7 @@ var _ = Describe("OOB Conn Test", func() {
 			defer c.Close()
 
 			for _, val := range []protocol.ECN{protocol.ECNNon, protocol.ECT1, protocol.ECT0, protocol.ECNCE} {
-				_, _, err = c.WriteMsgUDP([]byte("foobar"), appendIPv4ECNMsg([]byte{}, val), conn.LocalAddr().(*net.UDPAddr))
+				_, _, err = c.WriteMsgUDP([]byte("foobar"), appendIPv4TOSMsg([]byte{}, val.ToHeaderBits()), conn.LocalAddr().(*net.UDPAddr))
 				Expect(err).ToNot(HaveOccurred())
 				var p receivedPacket
 				Eventually(packetChan).Should(Receive(&p))
@@ -167,7 +170,7 @@ var _ = Describe("OOB Conn Test", func() {
 			defer c.Close()
 
 			for _, val := range []protocol.ECN{protocol.ECNNon, protocol.ECT1, protocol.ECT0, protocol.ECNCE} {
-				_, _, err = c.WriteMsgUDP([]byte("foobar"), appendIPv6ECNMsg([]byte{}, val), conn.LocalAddr().(*net.UDPAddr))
+				_, _, err = c.WriteMsgUDP([]byte("foobar"), appendIPv6TrafficClassMsg([]byte{}, val.ToHeaderBits()), conn.LocalAddr().(*net.UDPAddr))
 				Expect(err).ToNot(HaveOccurred())
 				var p receivedPacket
 				Eventually(packetChan).Should(Receive(&p))
@@ -175,6 +178,22 @@ var _ = Describe("OOB Conn Test", func() {
 				Expect(p.ecn).To(Equal(val))
 			}
 		})
+
+		It("reads the ECN codepoint from packets that have a DSCP set", func() {
+			conn, packetChan := runServer("udp4", "localhost:0")
+			defer conn.Close()
+
+			c, err := net.ListenUDP("udp4", nil)
+			Expect(err).ToNot(HaveOccurred())
+			defer c.Close()
+
+			_, _, err = c.WriteMsgUDP([]byte("foobar"), appendIPv4TOSMsg([]byte{}, 46<<2|protocol.ECT0.ToHeaderBits()), conn.LocalAddr().(*net.UDPAddr))
+			Expect(err).ToNot(HaveOccurred())
+			var p receivedPacket
+			Eventually(packetChan).Should(Receive(&p))
+			Expect(p.data).To(Equal([]byte("foobar")))
+			Expect(p.ecn).To(Equal(protocol.ECT0))
+		})
 	})
 
 	Context("Packet Info conn", func() {
@@ -260,11 +279,12 @@ var _ = Describe("OOB Conn Test", func() {
 		It("reads multiple messages in one batch", func() {
 			const numMsgRead = batchSize/2 + 1
 			var counter int
+			bufferSize := protocol.MaxPacketBufferSize
 			batchConn.EXPECT().ReadBatch(gomock.Any(), gomock.Any()).DoAndReturn(func(ms []ipv4.Message, flags int) (int, error) {
 				Expect(ms).To(HaveLen(batchSize))
 				for i := 0; i < numMsgRead; i++ {
 					Expect(ms[i].Buffers).To(HaveLen(1))
-					Expect(ms[i].Buffers[0]).To(HaveLen(protocol.MaxPacketBufferSize))
+					Expect(ms[i].Buffers[0]).To(HaveLen(bufferSize))
 					data := []byte(fmt.Sprintf("message %d", counter))
 					counter++
 					ms[i].Buffers[0] = data
@@ -280,6 +300,9 @@ var _ = Describe("OOB Conn Test", func() {
 			oobConn, err := newConn(udpConn, true)
 			Expect(err).ToNot(HaveOccurred())
 			oobConn.batchConn = batchConn
+			if oobConn.capabilities().GRO {
+				bufferSize = protocol.MaxGROBufferSize
+			}
 
 			for i := 0; i < batchSize+1; i++ {
 				p, err := oobConn.ReadPacket()
@@ -300,16 +323,59 @@ var _ = Describe("OOB Conn Test", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			oob := make([]byte, 0, 123)
-			oobConn.WritePacket([]byte("foobar"), addr, oob, 0, protocol.ECNCE)
+			oobConn.WritePacket([]byte("foobar"), addr, oob, 0, protocol.ECNCE, 0)
 			Expect(c.oobs).To(HaveLen(1))
 			oobMsg := c.oobs[0]
 			Expect(oobMsg).ToNot(BeEmpty())
 			Expect(oobMsg).To(HaveCap(cap(oob))) // check that it appended to oob
-			expected := appendIPv4ECNMsg([]byte{}, protocol.ECNCE)
+			expected := appendIPv4TOSMsg([]byte{}, protocol.ECNCE.ToHeaderBits())
 			Expect(oobMsg).To(Equal(expected))
 		})
 	})
 
+	Context("sending DSCP-marked packets", func() {
+		var (
+			c       *oobRecordingConn
+			oobConn *oobConn
+			addr    *net.UDPAddr
+		)
+
+		BeforeEach(func() {
+			var err error
+			addr, err = net.ResolveUDPAddr("udp", "localhost:0")
+			Expect(err).ToNot(HaveOccurred())
+			udpConn, err := net.ListenUDP("udp", addr)
+			Expect(err).ToNot(HaveOccurred())
+			c = &oobRecordingConn{UDPConn: udpConn}
+			oobConn, err = newConn(c, true)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("sets the DSCP", func() {
+			oobConn.WritePacket([]byte("foobar"), addr, nil, 0, protocol.ECNUnsupported, 46)
+			Expect(c.oobs).To(HaveLen(1))
+			Expect(c.oobs[0]).To(Equal(appendIPv4TOSMsg([]byte{}, 0b10111000)))
+		})
+
+		It("combines the DSCP with the ECN codepoint", func() {
+			oobConn.WritePacket([]byte("foobar"), addr, nil, 0, protocol.ECT0, 46)
+			Expect(c.oobs).To(HaveLen(1))
+			Expect(c.oobs[0]).To(Equal(appendIPv4TOSMsg([]byte{}, 0b10111010)))
+		})
+
+		It("uses the Traffic Class for IPv6", func() {
+			oobConn.WritePacket([]byte("foobar"), &net.UDPAddr{IP: net.IPv6loopback, Port: 1234}, nil, 0, protocol.ECNCE, 10)
+			Expect(c.oobs).To(HaveLen(1))
+			Expect(c.oobs[0]).To(Equal(appendIPv6TrafficClassMsg([]byte{}, 10<<2|0b11)))
+		})
+
+		It("doesn't add a control message if neither DSCP nor ECN are set", func() {
+			oobConn.WritePacket([]byte("foobar"), addr, nil, 0, protocol.ECNUnsupported, 0)
+			Expect(c.oobs).To(HaveLen(1))
+			Expect(c.oobs[0]).To(BeEmpty())
+		})
+	})
+
 	if platformSupportsGSO {
 		Context("GSO", func() {
 			It("appends the GSO control message", func() {
@@ -323,7 +389,7 @@ var _ = Describe("OOB Conn Test", func() {
 				Expect(oobConn.capabilities().GSO).To(BeTrue())
 
 				oob := make([]byte, 0, 123)
-				oobConn.WritePacket([]byte("foobar"), addr, oob, 3, protocol.ECNCE)
+				oobConn.WritePacket([]byte("foobar"), addr, oob, 3, protocol.ECNCE, 0)
 				Expect(c.oobs).To(HaveLen(1))
 				oobMsg := c.oobs[0]
 				Expect(oobMsg).ToNot(BeEmpty())
@@ -334,4 +400,162 @@ var _ = Describe("OOB Conn Test", func() {
 			})
 		})
 	}
+
+	if platformSupportsGRO {
+		Context("GRO", func() {
+			It("splits coalesced packets", func() {
+				conn, packetChan := runServer("udp4", "localhost:0")
+				defer conn.Close()
+
+				// Send a GSO datagram. The kernel passes it to the receiving socket without splitting it.
+				client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+				Expect(err).ToNot(HaveOccurred())
+				defer client.Close()
+				data := append(append(bytes.Repeat([]byte{'a'}, 100), bytes.Repeat([]byte{'b'}, 100)...), bytes.Repeat([]byte{'c'}, 50)...)
+				_, _, err = client.WriteMsgUDP(data, appendUDPSegmentSizeMsg(nil, 100), nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				for _, expected := range [][]byte{data[:100], data[100:200], data[200:]} {
+					var p receivedPacket
+					Eventually(packetChan).Should(Receive(&p))
+					Expect(p.data).To(Equal(expected))
+					Expect(p.remoteAddr).To(Equal(client.LocalAddr()))
+					p.buffer.Release()
+				}
+			})
+
+			It("drops the last segment of a truncated datagram", func() {
+				batchConn := NewMockBatchConn(mockCtrl)
+				data := make([]byte, 250)
+				for i := range data {
+					data[i] = byte(i / 100)
+				}
+				batchConn.EXPECT().ReadBatch(gomock.Any(), gomock.Any()).DoAndReturn(func(ms []ipv4.Message, flags int) (int, error) {
+					ms[0].N = copy(ms[0].Buffers[0], data)
+					oob := appendGROMsg(nil, 100)
+					ms[0].NN = copy(ms[0].OOB, oob)
+					ms[0].Flags = unix.MSG_TRUNC
+					return 1, nil
+				})
+				batchConn.EXPECT().ReadBatch(gomock.Any(), gomock.Any()).Return(0, errors.New("test done"))
+
+				udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn.Close()
+				oobConn, err := newConn(udpConn, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(oobConn.capabilities().GRO).To(BeTrue())
+				oobConn.batchConn = batchConn
+
+				for i := 0; i < 2; i++ {
+					p, err := oobConn.ReadPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.data).To(Equal(bytes.Repeat([]byte{byte(i)}, 100)))
+				}
+				_, err = oobConn.ReadPacket()
+				Expect(err).To(MatchError("test done"))
+			})
+
+			It("reads a full 64 KiB coalesced datagram, without copying the segments", func() {
+				const segmentSize = 1200
+				batchConn := NewMockBatchConn(mockCtrl)
+				var buf []byte
+				batchConn.EXPECT().ReadBatch(gomock.Any(), gomock.Any()).DoAndReturn(func(ms []ipv4.Message, flags int) (int, error) {
+					Expect(ms[0].Buffers[0]).To(HaveLen(protocol.MaxGROBufferSize))
+					buf = ms[0].Buffers[0]
+					for i := range buf {
+						buf[i] = byte(i / segmentSize)
+					}
+					ms[0].N = len(buf)
+					ms[0].NN = copy(ms[0].OOB, appendGROMsg(nil, segmentSize))
+					return 1, nil
+				})
+				batchConn.EXPECT().ReadBatch(gomock.Any(), gomock.Any()).Return(0, errors.New("test done"))
+
+				udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn.Close()
+				oobConn, err := newConn(udpConn, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(oobConn.capabilities().GRO).To(BeTrue())
+				oobConn.batchConn = batchConn
+
+				var packets []receivedPacket
+				for offset := 0; offset < protocol.MaxGROBufferSize; offset += segmentSize {
+					p, err := oobConn.ReadPacket()
+					Expect(err).ToNot(HaveOccurred())
+					size := min(segmentSize, protocol.MaxGROBufferSize-offset)
+					Expect(p.data).To(HaveLen(size))
+					Expect(p.data).To(Equal(bytes.Repeat([]byte{byte(offset / segmentSize)}, size)))
+					Expect(&p.data[0]).To(BeIdenticalTo(&buf[offset]))
+					packets = append(packets, p)
+				}
+				_, err = oobConn.ReadPacket()
+				Expect(err).To(MatchError("test done"))
+
+				parent := packets[0].buffer.parent
+				Expect(parent).ToNot(BeNil())
+				for i, p := range packets {
+					Expect(parent.segments.Load()).To(BeEquivalentTo(len(packets) - i))
+					p.buffer.Release()
+				}
+				Expect(parent.segments.Load()).To(BeZero())
+			})
+
+			It("copies datagrams that weren't coalesced into regular packet buffers", func() {
+				batchConn := NewMockBatchConn(mockCtrl)
+				batchConn.EXPECT().ReadBatch(gomock.Any(), gomock.Any()).DoAndReturn(func(ms []ipv4.Message, flags int) (int, error) {
+					Expect(ms[0].Buffers[0]).To(HaveLen(protocol.MaxGROBufferSize))
+					ms[0].N = copy(ms[0].Buffers[0], bytes.Repeat([]byte{'a'}, 1000))
+					// a single segment, smaller than the segment size
+					ms[0].NN = copy(ms[0].OOB, appendGROMsg(nil, 1200))
+					ms[1].N = copy(ms[1].Buffers[0], bytes.Repeat([]byte{'b'}, 1200))
+					ms[1].NN = 0
+					// a datagram that doesn't fit into a regular packet buffer
+					ms[2].N = copy(ms[2].Buffers[0], bytes.Repeat([]byte{'c'}, 2000))
+					ms[2].NN = 0
+					return 3, nil
+				})
+				batchConn.EXPECT().ReadBatch(gomock.Any(), gomock.Any()).Return(0, errors.New("test done"))
+
+				udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn.Close()
+				oobConn, err := newConn(udpConn, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(oobConn.capabilities().GRO).To(BeTrue())
+				oobConn.batchConn = batchConn
+
+				for _, expected := range []struct {
+					data     []byte
+					capacity protocol.ByteCount
+				}{
+					{data: bytes.Repeat([]byte{'a'}, 1000), capacity: protocol.MaxPacketBufferSize},
+					{data: bytes.Repeat([]byte{'b'}, 1200), capacity: protocol.MaxPacketBufferSize},
+					{data: bytes.Repeat([]byte{'c'}, 2000), capacity: protocol.MaxLargePacketBufferSize},
+				} {
+					p, err := oobConn.ReadPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.data).To(Equal(expected.data))
+					Expect(p.buffer.Cap()).To(Equal(expected.capacity))
+					Expect(p.buffer.parent).To(BeNil())
+					Expect(&p.data[0]).To(BeIdenticalTo(&p.buffer.Data[0]))
+					p.buffer.Release()
+				}
+				_, err = oobConn.ReadPacket()
+				Expect(err).To(MatchError("test done"))
+			})
+
+			It("doesn't enable GRO if disabled via the environment", func() {
+				os.Setenv("QUIC_GO_DISABLE_GRO", "true")
+				defer os.Unsetenv("QUIC_GO_DISABLE_GRO")
+				udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+				Expect(err).ToNot(HaveOccurred())
+				defer udpConn.Close()
+				oobConn, err := newConn(udpConn, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(oobConn.capabilities().GRO).To(BeFalse())
+			})
+		})
+	}
 })
@@ -29,4 +29,22 @@ var _ = Describe("Basic Conn Test", func() {
 		Expect(p.rcvTime).To(BeTemporally("~", time.Now(), scaleDuration(100*time.Millisecond)))
 		Expect(p.remoteAddr).To(Equal(addr))
 	})
+
+	It("reads large packets", func() {
+		c := NewMockPacketConn(mockCtrl)
+		addr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1234}
+		c.EXPECT().ReadFrom(gomock.Any()).DoAndReturn(func(b []byte) (int, net.Addr, error) {
+			Expect(b).To(HaveLen(protocol.MaxLargePacketBufferSize))
+			return copy(b, make([]byte, 9000)), addr, nil
+		})
+
+		conn, err := wrapConn(c)
+		Expect(err).ToNot(HaveOccurred())
+		maybeEnableLargePackets(conn, protocol.MaxPacketBufferSize) // no-op
+		Expect(conn.(*basicConn).largePackets.Load()).To(BeFalse())
+		maybeEnableLargePackets(conn, 9000)
+		p, err := conn.ReadPacket()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.data).To(HaveLen(9000))
+	})
 })
@@ -1,15 +1,18 @@
 package quic
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"net"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/nxenon/xquic-go/internal/flowcontrol"
 	"github.com/nxenon/xquic-go/internal/protocol"
 	"github.com/nxenon/xquic-go/internal/utils"
 	"github.com/nxenon/xquic-go/internal/wire"
@@ -80,11 +83,44 @@ type Transport struct {
 	// A Tracer traces events that don't belong to a single QUIC connection.
 	Tracer *logging.Tracer
 
+	// The Clock used by the connections of this Transport.
+	// It is used for timestamping received packets, for loss detection, pacing, and the idle and handshake timeouts.
+	// Using a virtual clock (together with an in-memory net.PacketConn) allows running connections
+	// deterministically and faster than real time, see the simnet package.
+	// If not set, the system clock is used.
+	Clock Clock
+
+	// ConnectionWorkers is the number of goroutines that drive the connections accepted by the Listener.
+	// By default, every connection runs on its own goroutine, and uses another goroutine to send packets.
+	// If set, connections are instead run as event-driven state machines on a fixed pool of goroutines:
+	// A connection only occupies a worker when it receives a packet, when one of its timers expires,
+	// when the application has data to send, or when it is closed.
+	// This significantly reduces the memory usage of servers with a large number of mostly idle connections.
+	// Packets are written synchronously by the workers, and callbacks (e.g. those of the tls.Config or
+	// the Tracer) block the worker, so they must return quickly.
+	// Connections established by Dial always use their own goroutines.
+	ConnectionWorkers int
+
+	// MaxReceiveMemory is the amount of memory (in bytes) that all connections of this Transport
+	// may use for their connection-level receive windows.
+	// The initial receive window of a new connection is always granted.
+	// When auto-tuning increases the receive window of a connection, the increase is drawn from this budget.
+	// Once more than 3/4 of the budget is used, window increases are halved, and fewer undecryptable
+	// and 0-RTT packets are queued. Once the budget is exhausted, receive windows are not increased any more.
+	// If not set, the memory usage of each connection is only limited by its MaxConnectionReceiveWindow.
+	// The accounting is exposed by MemoryStats.
+	MaxReceiveMemory uint64
+
 	handlerMap packetHandlerManager
 
-	mutex    sync.Mutex
-	initOnce sync.Once
-	initErr  error
+	memoryBudget *flowcontrol.MemoryBudget // set if MaxReceiveMemory is set
+
+	workerPool *workerPool // set if ConnectionWorkers is set
+
+	mutex       sync.Mutex
+	initOnce    sync.Once
+	initErr     error
+	initialized atomic.Bool // set once init completed successfully
 
 	// Set in init.
 	// If no ConnectionIDGenerator is set, this is the ConnectionIDLength.
@@ -104,6 +140,9 @@ type Transport struct {
 	closed      bool
 	createdConn bool
 	isSingleUse bool // was created for a single server or client, i.e. by calling quic.Listen or quic.Dial
+	// is part of a TransportGroup.
+	// All Transports of the group listen on the same address, and share the packet handler map.
+	isGroupMember bool
 
 	readingNonQUICPackets atomic.Bool
 	nonQUICPackets        chan receivedPacket
@@ -148,9 +187,18 @@ func (t *Transport) createServer(tlsConf *tls.Config, conf *Config, allow0RTT bo
 		return nil, errListenerAlreadySet
 	}
 	conf = populateServerConfig(conf)
+	conf.clock = t.Clock
 	if err := t.init(false); err != nil {
 		return nil, err
 	}
+	conf.memoryBudget = t.memoryBudget
+	if t.ConnectionWorkers > 0 {
+		if t.workerPool == nil {
+			t.workerPool = newWorkerPool(t.ConnectionWorkers, t.Clock)
+		}
+		conf.workerPool = t.workerPool
+	}
+	maybeEnableLargePackets(t.conn, conf.MaxPacketSize)
 	s := newServer(
 		t.conn,
 		t.handlerMap,
@@ -183,9 +231,12 @@ func (t *Transport) dial(ctx context.Context, addr net.Addr, host string, tlsCon
 		return nil, err
 	}
 	conf = populateConfig(conf)
+	conf.clock = t.Clock
 	if err := t.init(t.isSingleUse); err != nil {
 		return nil, err
 	}
+	conf.memoryBudget = t.memoryBudget
+	maybeEnableLargePackets(t.conn, conf.MaxPacketSize)
 	var onClose func()
 	if t.isSingleUse {
 		onClose = func() { t.Close() }
@@ -211,7 +262,9 @@ func (t *Transport) init(allowZeroLengthConnIDs bool) error {
 
 		t.logger = utils.DefaultLogger // TODO: make this configurable
 		t.conn = conn
-		t.handlerMap = newPacketHandlerMap(t.StatelessResetKey, t.enqueueClosePacket, t.logger)
+		if t.handlerMap == nil { // already set for members of a TransportGroup
+			t.handlerMap = newPacketHandlerMap(t.StatelessResetKey, t.enqueueClosePacket, t.logger)
+		}
 		t.listening = make(chan struct{})
 
 		t.closeQueue = make(chan closePacket, 4)
@@ -224,6 +277,9 @@ func (t *Transport) init(allowZeroLengthConnIDs bool) error {
 			}
 			t.TokenGeneratorKey = &key
 		}
+		if t.MaxReceiveMemory > 0 && t.memoryBudget == nil { // already set for members of a TransportGroup
+			t.memoryBudget = flowcontrol.NewMemoryBudget(protocol.ByteCount(t.MaxReceiveMemory))
+		}
 
 		if t.ConnectionIDGenerator != nil {
 			t.connIDGenerator = t.ConnectionIDGenerator
@@ -237,19 +293,78 @@ func (t *Transport) init(allowZeroLengthConnIDs bool) error {
 			t.connIDGenerator = &protocol.DefaultConnectionIDGenerator{ConnLen: t.connIDLen}
 		}
 
-		getMultiplexer().AddConn(t.Conn)
+		if !t.isGroupMember {
+			getMultiplexer().AddConn(t.Conn)
+		}
 		go t.listen(conn)
 		go t.runSendQueue()
+		t.initialized.Store(true)
 	})
 	return t.initErr
 }
 
+// Connections returns a snapshot of the connections handled by this Transport.
+// Connections are sorted by their connection IDs.
+// It returns nil if the Transport wasn't used to listen or dial yet.
+func (t *Transport) Connections() []ConnectionInfo {
+	if !t.initialized.Load() {
+		return nil
+	}
+
+	conns := make(map[quicConn][]ConnectionID)
+	t.handlerMap.Range(func(id protocol.ConnectionID, handler packetHandler) {
+		// Once a connection is closed, its connection IDs are routed to a closedLocalConn or closedRemoteConn.
+		if conn, ok := handler.(quicConn); ok {
+			conns[conn] = append(conns[conn], id)
+		}
+	})
+	infos := make([]ConnectionInfo, 0, len(conns))
+	for conn, connIDs := range conns {
+		infos = append(infos, newConnectionInfo(conn, connIDs))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return bytes.Compare(infos[i].ConnectionIDs[0].Bytes(), infos[j].ConnectionIDs[0].Bytes()) < 0
+	})
+	return infos
+}
+
+// MemoryStats is a snapshot of the accounting of a Transport's receive memory budget.
+type MemoryStats struct {
+	// Limit is the size of the memory budget, as configured by MaxReceiveMemory.
+	Limit uint64
+	// Reserved is the memory currently reserved for the receive windows of all connections.
+	Reserved uint64
+	// UnderPressure says if more than 3/4 of the budget are reserved.
+	UnderPressure bool
+	// WindowIncreasesLimited counts the receive window increases that were reduced or denied due to the budget.
+	WindowIncreasesLimited uint64
+	// PacketsDropped counts the undecryptable and 0-RTT packets that were dropped,
+	// because fewer packets are queued under memory pressure.
+	PacketsDropped uint64
+}
+
+// MemoryStats returns the accounting of the receive memory budget.
+// If MaxReceiveMemory is not set, or the Transport wasn't used to listen or dial yet,
+// it returns a zero value.
+func (t *Transport) MemoryStats() MemoryStats {
+	if !t.initialized.Load() || t.memoryBudget == nil {
+		return MemoryStats{}
+	}
+	return MemoryStats{
+		Limit:                  uint64(t.memoryBudget.Limit()),
+		Reserved:               uint64(t.memoryBudget.Reserved()),
+		UnderPressure:          t.memoryBudget.UnderPressure(),
+		WindowIncreasesLimited: t.memoryBudget.WindowIncreasesLimited(),
+		PacketsDropped:         t.memoryBudget.PacketsDropped(),
+	}
+}
+
 // WriteTo sends a packet on the underlying connection.
 func (t *Transport) WriteTo(b []byte, addr net.Addr) (int, error) {
 	if err := t.init(false); err != nil {
 		return 0, err
 	}
-	return t.conn.WritePacket(b, addr, nil, 0, protocol.ECNUnsupported)
+	return t.conn.WritePacket(b, addr, nil, 0, protocol.ECNUnsupported, 0)
 }
 
 func (t *Transport) enqueueClosePacket(p closePacket) {
@@ -267,7 +382,7 @@ func (t *Transport) runSendQueue() {
 		case <-t.listening:
 			return
 		case p := <-t.closeQueue:
-			t.conn.WritePacket(p.payload, p.addr, p.info.OOB(), 0, protocol.ECNUnsupported)
+			t.conn.WritePacket(p.payload, p.addr, p.info.OOB(), 0, protocol.ECNUnsupported, 0)
 		case p := <-t.statelessResetQueue:
 			t.sendStatelessReset(p)
 		}
@@ -323,6 +438,14 @@ func (t *Transport) close(e error) {
 	if t.server != nil {
 		t.server.close(e, false)
 	}
+	if t.workerPool != nil {
+		// All connections have been closed when the packet handler map was closed.
+		t.workerPool.close()
+	}
+	// The tracer of a TransportGroup is closed by the TransportGroup.
+	if t.Tracer != nil && t.Tracer.Close != nil && !t.isGroupMember {
+		t.Tracer.Close()
+	}
 	t.closed = true
 }
 
@@ -331,7 +454,9 @@ var setBufferWarningOnce sync.Once
 
 func (t *Transport) listen(conn rawConn) {
 	defer close(t.listening)
-	defer getMultiplexer().RemoveConn(t.Conn)
+	if !t.isGroupMember {
+		defer getMultiplexer().RemoveConn(t.Conn)
+	}
 
 	for {
 		p, err := conn.ReadPacket()
@@ -357,6 +482,9 @@ func (t *Transport) listen(conn rawConn) {
 			t.close(err)
 			return
 		}
+		if t.Clock != nil {
+			p.rcvTime = t.Clock.Now()
+		}
 		t.handlePacket(p)
 	}
 }
@@ -435,7 +563,7 @@ func (t *Transport) sendStatelessReset(p receivedPacket) {
 	rand.Read(data)
 	data[0] = (data[0] & 0x7f) | 0x40
 	data = append(data, token[:]...)
-	if _, err := t.conn.WritePacket(data, p.remoteAddr, p.info.OOB(), 0, protocol.ECNUnsupported); err != nil {
+	if _, err := t.conn.WritePacket(data, p.remoteAddr, p.info.OOB(), 0, protocol.ECNUnsupported, 0); err != nil {
 		t.logger.Debugf("Error sending Stateless Reset to %s: %s", p.remoteAddr, err)
 	}
 }
@@ -0,0 +1,383 @@
+package quic
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nxenon/xquic-go/internal/flowcontrol"
+	"github.com/nxenon/xquic-go/internal/protocol"
+	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/logging"
+)
+
+// maxTransportGroupSize is the maximum number of sockets of a TransportGroup.
+// The worker index is encoded in the first byte of the connection ID.
+const maxTransportGroupSize = 256
+
+var errTransportGroupClosed = errors.New("quic: transport group closed")
+
+// A TransportGroup opens multiple UDP sockets on the same address, using SO_REUSEPORT,
+// and runs a Transport on each of them.
+// This allows receiving packets on multiple CPU cores.
+//
+// All Transports share the same connection ID namespace: A packet is handed to the right connection,
+// no matter on which socket it is received.
+// Every connection is assigned to one of the Transports, and the index of that Transport (the worker index)
+// is encoded in the first byte of its connection IDs.
+// On Linux, the TransportGroup attaches a BPF program that makes the kernel deliver short header packets
+// to the socket of the Transport that the connection was assigned to.
+//
+// New connections are accepted by a single server, which distributes them across the Transports.
+//
+// SO_REUSEPORT is not supported on Windows.
+type TransportGroup struct {
+	// Addr is the UDP address to listen on.
+	Addr *net.UDPAddr
+
+	// NumSockets is the number of sockets.
+	// It can be any value between 1 and 256.
+	// If unset, the number of CPUs is used.
+	NumSockets int
+
+	// The length of the connection ID in bytes, including the byte that encodes the worker index.
+	// It can be any value between 4 and 20.
+	// If unset, a 4 byte connection ID will be used.
+	ConnectionIDLength int
+
+	// See Transport.StatelessResetKey.
+	StatelessResetKey *StatelessResetKey
+
+	// See Transport.TokenGeneratorKey.
+	TokenGeneratorKey *TokenGeneratorKey
+
+	// See Transport.MaxTokenAge.
+	MaxTokenAge time.Duration
+
+	// See Transport.DisableVersionNegotiationPackets.
+	DisableVersionNegotiationPackets bool
+
+	// A Tracer traces events that don't belong to a single QUIC connection.
+	Tracer *logging.Tracer
+
+	// See Transport.Clock.
+	Clock Clock
+
+	// See Transport.ConnectionWorkers.
+	// The connections accepted on all sockets are driven by a single pool of workers.
+	ConnectionWorkers int
+
+	// See Transport.MaxReceiveMemory.
+	// The budget is shared by the connections on all sockets.
+	MaxReceiveMemory uint64
+
+	initOnce sync.Once
+	initErr  error
+
+	transports   []*Transport
+	handlerMap   *packetHandlerMap
+	memoryBudget *flowcontrol.MemoryBudget // set if MaxReceiveMemory is set
+
+	nextDial   atomic.Uint32
+	nextServer atomic.Uint32
+
+	mutex      sync.Mutex
+	server     *baseServer
+	workerPool *workerPool // set if ConnectionWorkers is set
+	closed     bool
+
+	logger utils.Logger
+}
+
+func (g *TransportGroup) init() error {
+	g.initOnce.Do(func() {
+		g.initErr = g.initImpl()
+	})
+	return g.initErr
+}
+
+func (g *TransportGroup) initImpl() error {
+	if g.Addr == nil {
+		return errors.New("quic: TransportGroup.Addr not set")
+	}
+	numSockets := g.NumSockets
+	if numSockets == 0 {
+		numSockets = min(runtime.NumCPU(), maxTransportGroupSize)
+	}
+	if numSockets < 1 || numSockets > maxTransportGroupSize {
+		return fmt.Errorf("quic: invalid number of sockets: %d", numSockets)
+	}
+	connIDLen := g.ConnectionIDLength
+	if connIDLen == 0 {
+		connIDLen = protocol.DefaultConnectionIDLength
+	}
+	if connIDLen < 4 || connIDLen > protocol.MaxConnIDLen {
+		return fmt.Errorf("quic: invalid connection ID length: %d", connIDLen)
+	}
+	if g.TokenGeneratorKey == nil {
+		var key TokenGeneratorKey
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		g.TokenGeneratorKey = &key
+	}
+	g.logger = utils.DefaultLogger.WithPrefix("transport group")
+
+	conns := make([]net.PacketConn, 0, numSockets)
+	closeConns := func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}
+	addr := g.Addr
+	for i := 0; i < numSockets; i++ {
+		conn, err := listenReusePort(addr)
+		if err != nil {
+			closeConns()
+			return err
+		}
+		conns = append(conns, conn)
+		// If the port was chosen by the kernel, all other sockets need to use the same port.
+		addr = conn.LocalAddr().(*net.UDPAddr)
+	}
+	if err := attachSteeringProgram(conns[0], numSockets); err != nil {
+		// Steering is an optimization. Without it, packets are still handed over to the right connection.
+		g.logger.Debugf("Failed to attach steering program: %s", err)
+	}
+
+	g.transports = make([]*Transport, numSockets)
+	g.handlerMap = newPacketHandlerMap(g.StatelessResetKey, func(p closePacket) { g.transports[0].enqueueClosePacket(p) }, utils.DefaultLogger)
+	if g.MaxReceiveMemory > 0 {
+		g.memoryBudget = flowcontrol.NewMemoryBudget(protocol.ByteCount(g.MaxReceiveMemory))
+	}
+	for i := range g.transports {
+		g.transports[i] = &Transport{
+			Conn:                             conns[i],
+			ConnectionIDGenerator:            &workerConnIDGenerator{index: i, numWorkers: numSockets, connIDLen: connIDLen},
+			StatelessResetKey:                g.StatelessResetKey,
+			TokenGeneratorKey:                g.TokenGeneratorKey,
+			MaxTokenAge:                      g.MaxTokenAge,
+			DisableVersionNegotiationPackets: g.DisableVersionNegotiationPackets,
+			Tracer:                           g.Tracer,
+			Clock:                            g.Clock,
+			ConnectionWorkers:                g.ConnectionWorkers,
+			MaxReceiveMemory:                 g.MaxReceiveMemory,
+			handlerMap:                       g.handlerMap,
+			memoryBudget:                     g.memoryBudget,
+			createdConn:                      true,
+			isGroupMember:                    true,
+		}
+	}
+	for _, t := range g.transports {
+		if err := t.init(false); err != nil {
+			g.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// LocalAddr returns the local address that all sockets of the TransportGroup are bound to.
+func (g *TransportGroup) LocalAddr() (net.Addr, error) {
+	if err := g.init(); err != nil {
+		return nil, err
+	}
+	return g.transports[0].Conn.LocalAddr(), nil
+}
+
+// Listen starts listening for incoming QUIC connections.
+// There can only be a single listener on a TransportGroup.
+// Listen may only be called again after the current Listener was closed.
+func (g *TransportGroup) Listen(tlsConf *tls.Config, conf *Config) (*Listener, error) {
+	s, err := g.createServer(tlsConf, conf, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{baseServer: s}, nil
+}
+
+// ListenEarly starts listening for incoming QUIC connections.
+// There can only be a single listener on a TransportGroup.
+// Listen may only be called again after the current Listener was closed.
+func (g *TransportGroup) ListenEarly(tlsConf *tls.Config, conf *Config) (*EarlyListener, error) {
+	s, err := g.createServer(tlsConf, conf, true)
+	if err != nil {
+		return nil, err
+	}
+	return &EarlyListener{baseServer: s}, nil
+}
+
+func (g *TransportGroup) createServer(tlsConf *tls.Config, conf *Config, allow0RTT bool) (*baseServer, error) {
+	if tlsConf == nil {
+		return nil, errors.New("quic: tls.Config not set")
+	}
+	if err := validateConfig(conf); err != nil {
+		return nil, err
+	}
+	if err := g.init(); err != nil {
+		return nil, err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.closed {
+		return nil, errTransportGroupClosed
+	}
+	if g.server != nil {
+		return nil, errListenerAlreadySet
+	}
+	conf = populateServerConfig(conf)
+	conf.clock = g.Clock
+	conf.memoryBudget = g.memoryBudget
+	if g.ConnectionWorkers > 0 {
+		if g.workerPool == nil {
+			g.workerPool = newWorkerPool(g.ConnectionWorkers, g.Clock)
+		}
+		conf.workerPool = g.workerPool
+	}
+	for _, t := range g.transports {
+		maybeEnableLargePackets(t.conn, conf.MaxPacketSize)
+	}
+	// Retry and Version Negotiation packets are sent from the first socket.
+	// Since all sockets are bound to the same address, this doesn't make a difference for the client.
+	t := g.transports[0]
+	s := newServer(
+		t.conn,
+		g.handlerMap,
+		t.connIDGenerator,
+		tlsConf,
+		conf,
+		g.Tracer,
+		g.closeServer,
+		*g.TokenGeneratorKey,
+		g.MaxTokenAge,
+		g.DisableVersionNegotiationPackets,
+		allow0RTT,
+	)
+	s.assignWorker = g.assignWorker
+	for _, t := range g.transports {
+		t.mutex.Lock()
+		t.server = s
+		t.mutex.Unlock()
+	}
+	g.server = s
+	return s, nil
+}
+
+// assignWorker assigns new connections to the Transports in a round-robin fashion.
+func (g *TransportGroup) assignWorker() (rawConn, ConnectionIDGenerator) {
+	t := g.transports[int(g.nextServer.Add(1)-1)%len(g.transports)]
+	return t.conn, t.connIDGenerator
+}
+
+func (g *TransportGroup) closeServer() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.server = nil
+	for _, t := range g.transports {
+		t.mutex.Lock()
+		t.server = nil
+		t.mutex.Unlock()
+	}
+}
+
+// Dial dials a new connection to a remote host (not using 0-RTT).
+func (g *TransportGroup) Dial(ctx context.Context, addr net.Addr, tlsConf *tls.Config, conf *Config) (Connection, error) {
+	t, err := g.nextTransport()
+	if err != nil {
+		return nil, err
+	}
+	return t.Dial(ctx, addr, tlsConf, conf)
+}
+
+// DialEarly dials a new connection, attempting to use 0-RTT if possible.
+func (g *TransportGroup) DialEarly(ctx context.Context, addr net.Addr, tlsConf *tls.Config, conf *Config) (EarlyConnection, error) {
+	t, err := g.nextTransport()
+	if err != nil {
+		return nil, err
+	}
+	return t.DialEarly(ctx, addr, tlsConf, conf)
+}
+
+// nextTransport selects the Transport for a new outgoing connection in a round-robin fashion.
+func (g *TransportGroup) nextTransport() (*Transport, error) {
+	if err := g.init(); err != nil {
+		return nil, err
+	}
+	g.mutex.Lock()
+	closed := g.closed
+	g.mutex.Unlock()
+	if closed {
+		return nil, errTransportGroupClosed
+	}
+	return g.transports[int(g.nextDial.Add(1)-1)%len(g.transports)], nil
+}
+
+// Close closes all sockets of the TransportGroup, as well as all connections.
+func (g *TransportGroup) Close() error {
+	g.mutex.Lock()
+	if g.closed {
+		g.mutex.Unlock()
+		return nil
+	}
+	g.closed = true
+	workerPool := g.workerPool
+	g.mutex.Unlock()
+
+	var errs []error
+	for _, t := range g.transports {
+		if t == nil {
+			continue
+		}
+		if err := t.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// The worker pool and the tracer are shared by all Transports.
+	if workerPool != nil {
+		// All connections have been closed when the packet handler map was closed.
+		workerPool.close()
+	}
+	if g.Tracer != nil && g.Tracer.Close != nil {
+		g.Tracer.Close()
+	}
+	return errors.Join(errs...)
+}
+
+// A workerConnIDGenerator generates connection IDs that encode the worker index.
+// The worker index is the first byte of the connection ID modulo the number of workers.
+// The rest of the first byte, as well as all other bytes, are random.
+type workerConnIDGenerator struct {
+	index      int
+	numWorkers int
+	connIDLen  int
+}
+
+var _ ConnectionIDGenerator = &workerConnIDGenerator{}
+
+func (g *workerConnIDGenerator) GenerateConnectionID() (ConnectionID, error) {
+	b := make([]byte, g.connIDLen)
+	if _, err := rand.Read(b); err != nil {
+		return ConnectionID{}, err
+	}
+	// the number of values for the first byte that encode this worker index
+	n := (maxTransportGroupSize - g.index + g.numWorkers - 1) / g.numWorkers
+	b[0] = byte(g.index + g.numWorkers*(int(b[0])%n))
+	return protocol.ParseConnectionID(b), nil
+}
+
+func (g *workerConnIDGenerator) ConnectionIDLen() int {
+	return g.connIDLen
+}
+
+func workerIndex(connID ConnectionID, numWorkers int) int {
+	return int(connID.Bytes()[0]) % numWorkers
+}
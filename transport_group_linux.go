@@ -0,0 +1,66 @@
+//go:build linux
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func listenReusePort(addr *net.UDPAddr) (net.PacketConn, error) {
+	lc := net.ListenConfig{
+		Control: func(_, _ string, c syscall.RawConn) error {
+			var serr error
+			if err := c.Control(func(fd uintptr) {
+				serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			}); err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+	return lc.ListenPacket(context.Background(), "udp", addr.String())
+}
+
+// attachSteeringProgram attaches a classic BPF program to the SO_REUSEPORT group of the socket.
+// For short header packets, it selects the socket using the worker index encoded in the first byte
+// of the destination connection ID.
+// For long header packets, it returns an invalid index, which makes the kernel fall back to
+// selecting the socket using the hash of the 4-tuple.
+// The sockets are indexed in the order they were bound.
+func attachSteeringProgram(conn net.PacketConn, numSockets int) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.New("not a syscall.Conn")
+	}
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	// The program is run on the UDP payload.
+	filter := []unix.SockFilter{
+		// load the first byte of the packet
+		{Code: unix.BPF_LD | unix.BPF_B | unix.BPF_ABS, K: 0},
+		// check the Header Form bit: jump to the long header instruction for long header packets
+		{Code: unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K, K: 0x80, Jt: 3},
+		// load the first byte of the destination connection ID
+		{Code: unix.BPF_LD | unix.BPF_B | unix.BPF_ABS, K: 1},
+		// the worker index is the first byte modulo the number of sockets
+		{Code: unix.BPF_ALU | unix.BPF_MOD | unix.BPF_K, K: uint32(numSockets)},
+		{Code: unix.BPF_RET | unix.BPF_A},
+		// long header packet: return an invalid index
+		{Code: unix.BPF_RET | unix.BPF_K, K: 0xffffffff},
+	}
+	prog := &unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	var serr error
+	if err := rawConn.Control(func(fd uintptr) {
+		serr = unix.SetsockoptSockFprog(int(fd), unix.SOL_SOCKET, unix.SO_ATTACH_REUSEPORT_CBPF, prog)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
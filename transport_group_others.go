@@ -0,0 +1,14 @@
+//go:build !darwin && !linux && !freebsd
+
+package quic
+
+import (
+	"errors"
+	"net"
+)
+
+func listenReusePort(*net.UDPAddr) (net.PacketConn, error) {
+	return nil, errors.New("quic: SO_REUSEPORT is not supported on this platform")
+}
+
+func attachSteeringProgram(net.PacketConn, int) error { return nil }
@@ -0,0 +1,186 @@
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"runtime"
+	"time"
+
+	"github.com/nxenon/xquic-go/internal/flowcontrol"
+	"github.com/nxenon/xquic-go/internal/protocol"
+	"github.com/nxenon/xquic-go/internal/testdata"
+	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/logging"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport Group", func() {
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip("SO_REUSEPORT is not supported on Windows")
+		}
+	})
+
+	It("generates connection IDs that encode the worker index", func() {
+		for _, numWorkers := range []int{1, 3, 7, 256} {
+			for index := 0; index < numWorkers; index++ {
+				g := &workerConnIDGenerator{index: index, numWorkers: numWorkers, connIDLen: 8}
+				Expect(g.ConnectionIDLen()).To(Equal(8))
+				for i := 0; i < 10; i++ {
+					connID, err := g.GenerateConnectionID()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(connID.Len()).To(Equal(8))
+					Expect(workerIndex(connID, numWorkers)).To(Equal(index))
+				}
+			}
+		}
+	})
+
+	It("randomizes the first byte of the connection ID", func() {
+		g := &workerConnIDGenerator{index: 1, numWorkers: 4, connIDLen: 4}
+		firstBytes := make(map[byte]struct{})
+		for i := 0; i < 200; i++ {
+			connID, err := g.GenerateConnectionID()
+			Expect(err).ToNot(HaveOccurred())
+			firstBytes[connID.Bytes()[0]] = struct{}{}
+		}
+		Expect(len(firstBytes)).To(BeNumerically(">", 10))
+	})
+
+	It("rejects invalid configurations", func() {
+		_, err := (&TransportGroup{}).LocalAddr()
+		Expect(err).To(MatchError("quic: TransportGroup.Addr not set"))
+		_, err = (&TransportGroup{Addr: &net.UDPAddr{}, NumSockets: 257}).LocalAddr()
+		Expect(err).To(MatchError("quic: invalid number of sockets: 257"))
+		_, err = (&TransportGroup{Addr: &net.UDPAddr{}, ConnectionIDLength: 2}).LocalAddr()
+		Expect(err).To(MatchError("quic: invalid connection ID length: 2"))
+	})
+
+	It("opens multiple sockets on the same address, sharing the packet handler map", func() {
+		g := &TransportGroup{Addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, NumSockets: 4}
+		defer g.Close()
+		addr, err := g.LocalAddr()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(addr.(*net.UDPAddr).Port).ToNot(BeZero())
+		Expect(g.transports).To(HaveLen(4))
+		for i, t := range g.transports {
+			Expect(t.Conn.LocalAddr()).To(Equal(addr))
+			Expect(t.handlerMap).To(BeIdenticalTo(g.handlerMap))
+			Expect(t.connIDGenerator.(*workerConnIDGenerator).index).To(Equal(i))
+		}
+	})
+
+	It("passes the configuration to all Transports", func() {
+		type testClock struct {
+			utils.DefaultClock
+			id int
+		}
+		clock := &testClock{id: 42}
+		g := &TransportGroup{
+			Addr:              &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)},
+			NumSockets:        2,
+			Clock:             clock,
+			ConnectionWorkers: 3,
+			MaxReceiveMemory:  1 << 20,
+		}
+		defer g.Close()
+		_, err := g.LocalAddr()
+		Expect(err).ToNot(HaveOccurred())
+		for _, t := range g.transports {
+			Expect(t.Clock).To(BeIdenticalTo(clock))
+			Expect(t.ConnectionWorkers).To(Equal(3))
+			Expect(t.MemoryStats()).To(Equal(MemoryStats{Limit: 1 << 20}))
+		}
+		// the memory budget is shared by all Transports
+		account := g.memoryBudget.NewAccount()
+		flowcontrol.NewConnectionFlowController(800<<10, 1<<20, nil, nil, account, &utils.RTTStats{}, utils.DefaultLogger)
+		for _, t := range g.transports {
+			Expect(t.MemoryStats().Reserved).To(BeEquivalentTo(800 << 10))
+		}
+		account.Close()
+
+		ln, err := g.Listen(testdata.GetTLSConfig(), nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		Expect(g.server.config.clock).To(BeIdenticalTo(clock))
+		Expect(g.server.config.memoryBudget).To(BeIdenticalTo(g.memoryBudget))
+		Expect(g.server.config.workerPool).ToNot(BeNil())
+		Expect(g.server.config.workerPool.clock).To(BeIdenticalTo(clock))
+	})
+
+	It("closes the tracer once", func() {
+		var closed int
+		g := &TransportGroup{
+			Addr:       &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)},
+			NumSockets: 4,
+			Tracer:     &logging.Tracer{Close: func() { closed++ }},
+		}
+		_, err := g.LocalAddr()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(g.Close()).To(Succeed())
+		Expect(closed).To(Equal(1))
+	})
+
+	It("accepts and dials connections", func() {
+		server := &TransportGroup{Addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, NumSockets: 4}
+		defer server.Close()
+		tlsConf := testdata.GetTLSConfig()
+		tlsConf.NextProtos = []string{"group"}
+		ln, err := server.Listen(tlsConf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		_, err = server.Listen(tlsConf, nil)
+		Expect(err).To(MatchError(errListenerAlreadySet))
+
+		go func() {
+			defer GinkgoRecover()
+			for {
+				conn, err := ln.Accept(context.Background())
+				if err != nil {
+					return
+				}
+				go func() {
+					defer GinkgoRecover()
+					str, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					io.Copy(str, str)
+					str.Close()
+				}()
+			}
+		}()
+
+		client := &TransportGroup{Addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, NumSockets: 2}
+		defer client.Close()
+		clientTLSConf := &tls.Config{ServerName: "localhost", RootCAs: testdata.GetRootCA(), NextProtos: []string{"group"}}
+		const num = 8
+		for i := 0; i < num; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			conn, err := client.Dial(ctx, ln.Addr(), clientTLSConf, nil)
+			cancel()
+			Expect(err).ToNot(HaveOccurred())
+			str, err := conn.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+			data, err := io.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+		}
+
+		// connections are distributed across all workers
+		workers := make(map[int]int)
+		server.handlerMap.Range(func(connID protocol.ConnectionID, h packetHandler) {
+			if _, ok := h.(*connection); ok && connID.Len() == protocol.DefaultConnectionIDLength {
+				workers[workerIndex(connID, 4)]++
+			}
+		})
+		Expect(workers).To(HaveLen(4))
+	})
+})
@@ -0,0 +1,30 @@
+//go:build darwin || freebsd
+
+package quic
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func listenReusePort(addr *net.UDPAddr) (net.PacketConn, error) {
+	lc := net.ListenConfig{
+		Control: func(_, _ string, c syscall.RawConn) error {
+			var serr error
+			if err := c.Control(func(fd uintptr) {
+				serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			}); err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+	return lc.ListenPacket(context.Background(), "udp", addr.String())
+}
+
+// attachSteeringProgram is only supported on Linux.
+// Packets are still handed over to the right connection, no matter on which socket they are received.
+func attachSteeringProgram(net.PacketConn, int) error { return nil }
@@ -10,8 +10,10 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/nxenon/xquic-go/internal/flowcontrol"
 	mocklogging "github.com/nxenon/xquic-go/internal/mocks/logging"
 	"github.com/nxenon/xquic-go/internal/protocol"
+	"github.com/nxenon/xquic-go/internal/utils"
 	"github.com/nxenon/xquic-go/internal/wire"
 	"github.com/nxenon/xquic-go/logging"
 
@@ -122,6 +124,131 @@ var _ = Describe("Transport", func() {
 		tr.Close()
 	})
 
+	It("closes the tracer", func() {
+		packetChan := make(chan packetToRead)
+		var closed int
+		tr := &Transport{
+			Conn:   newMockPacketConn(packetChan),
+			Tracer: &logging.Tracer{Close: func() { closed++ }},
+		}
+		tr.init(true)
+		close(packetChan)
+		Expect(tr.Close()).To(Succeed())
+		Expect(closed).To(Equal(1))
+		// closing the Transport again doesn't close the tracer again
+		Expect(tr.Close()).To(Succeed())
+		Expect(closed).To(Equal(1))
+	})
+
+	It("lists connections", func() {
+		packetChan := make(chan packetToRead)
+		tr := &Transport{Conn: newMockPacketConn(packetChan)}
+		tr.init(true)
+		defer func() {
+			close(packetChan)
+			tr.Close()
+		}()
+
+		connID1 := protocol.ParseConnectionID([]byte{1, 2, 3, 4})
+		connID2 := protocol.ParseConnectionID([]byte{4, 3, 2, 1})
+		connID3 := protocol.ParseConnectionID([]byte{2, 2, 2, 2})
+		handshakeCtx, handshakeCancel := context.WithCancel(context.Background())
+		handshakeCancel()
+		conn1 := NewMockQUICConn(mockCtrl)
+		conn1.EXPECT().Context().Return(context.Background())
+		conn1.EXPECT().HandshakeComplete().Return(handshakeCtx.Done())
+		conn1.EXPECT().ConnectionState().Return(ConnectionState{
+			Version: protocol.Version2,
+			TLS:     tls.ConnectionState{NegotiatedProtocol: "h3"},
+		})
+		conn1.EXPECT().getPerspective().Return(protocol.PerspectiveServer)
+		conn1.EXPECT().LocalAddr().Return(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 443})
+		conn1.EXPECT().RemoteAddr().Return(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337})
+		conn1.EXPECT().connectionStats().Return(ConnectionStats{SmoothedRTT: time.Second, PacketsSent: 42})
+		conn1.EXPECT().destroy(gomock.Any()).AnyTimes()
+		conn2 := NewMockQUICConn(mockCtrl)
+		conn2.EXPECT().Context().Return(context.Background())
+		conn2.EXPECT().HandshakeComplete().Return(make(chan struct{}))
+		conn2.EXPECT().ConnectionState().Return(ConnectionState{Version: protocol.Version1})
+		conn2.EXPECT().getPerspective().Return(protocol.PerspectiveClient)
+		conn2.EXPECT().LocalAddr()
+		conn2.EXPECT().RemoteAddr()
+		conn2.EXPECT().connectionStats()
+		conn2.EXPECT().destroy(gomock.Any()).AnyTimes()
+		// closed connections are not listed
+		closedConn := NewMockPacketHandler(mockCtrl)
+		closedConn.EXPECT().destroy(gomock.Any()).AnyTimes()
+
+		phm := tr.handlerMap.(*packetHandlerMap)
+		Expect(phm.Add(connID1, conn1)).To(BeTrue())
+		Expect(phm.Add(connID2, conn1)).To(BeTrue())
+		Expect(phm.Add(connID3, conn2)).To(BeTrue())
+		Expect(phm.Add(protocol.ParseConnectionID([]byte{0, 0, 0, 0}), closedConn)).To(BeTrue())
+
+		conns := tr.Connections()
+		Expect(conns).To(HaveLen(2))
+		Expect(conns[0].Connection).To(Equal(conn1))
+		Expect(conns[0].ConnectionIDs).To(Equal([]ConnectionID{connID1, connID2}))
+		Expect(conns[0].Perspective).To(Equal(protocol.PerspectiveServer))
+		Expect(conns[0].Status).To(Equal(ConnectionStatusEstablished))
+		Expect(conns[0].Version).To(Equal(protocol.Version2))
+		Expect(conns[0].ALPN).To(Equal("h3"))
+		Expect(conns[0].RemoteAddr).To(Equal(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}))
+		Expect(conns[0].Stats.SmoothedRTT).To(Equal(time.Second))
+		Expect(conns[0].Stats.PacketsSent).To(BeEquivalentTo(42))
+		Expect(conns[1].Connection).To(Equal(conn2))
+		Expect(conns[1].ConnectionIDs).To(Equal([]ConnectionID{connID3}))
+		Expect(conns[1].Status).To(Equal(ConnectionStatusHandshaking))
+	})
+
+	It("doesn't initialize the transport when listing connections", func() {
+		tr := &Transport{Conn: newMockPacketConn(make(chan packetToRead))}
+		Expect(tr.Connections()).To(BeNil())
+		Expect(tr.handlerMap).To(BeNil())
+		Expect(tr.conn).To(BeNil())
+	})
+
+	It("reports memory stats", func() {
+		packetChan := make(chan packetToRead)
+		tr := &Transport{Conn: newMockPacketConn(packetChan), MaxReceiveMemory: 1 << 20}
+		tr.init(true)
+		defer func() {
+			close(packetChan)
+			tr.Close()
+		}()
+
+		Expect(tr.MemoryStats()).To(Equal(MemoryStats{Limit: 1 << 20}))
+		account := tr.memoryBudget.NewAccount()
+		flowcontrol.NewConnectionFlowController(800<<10, 1<<20, nil, nil, account, &utils.RTTStats{}, utils.DefaultLogger)
+		tr.memoryBudget.DroppedPacket()
+		Expect(tr.MemoryStats()).To(Equal(MemoryStats{
+			Limit:          1 << 20,
+			Reserved:       800 << 10,
+			UnderPressure:  true,
+			PacketsDropped: 1,
+		}))
+		account.Close()
+		Expect(tr.MemoryStats().Reserved).To(BeZero())
+	})
+
+	It("doesn't report memory stats if no memory budget is used", func() {
+		packetChan := make(chan packetToRead)
+		tr := &Transport{Conn: newMockPacketConn(packetChan)}
+		tr.init(true)
+		defer func() {
+			close(packetChan)
+			tr.Close()
+		}()
+		Expect(tr.MemoryStats()).To(BeZero())
+	})
+
+	It("doesn't initialize the transport when reporting memory stats", func() {
+		tr := &Transport{Conn: newMockPacketConn(make(chan packetToRead)), MaxReceiveMemory: 1 << 20}
+		Expect(tr.MemoryStats()).To(BeZero())
+		Expect(tr.memoryBudget).To(BeNil())
+		Expect(tr.conn).To(BeNil())
+	})
+
 	It("drops unparseable QUIC packets", func() {
 		addr := &net.UDPAddr{IP: net.IPv4(9, 8, 7, 6), Port: 1234}
 		packetChan := make(chan packetToRead)
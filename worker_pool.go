@@ -0,0 +1,299 @@
+package quic
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nxenon/xquic-go/internal/utils"
+	"github.com/nxenon/xquic-go/internal/utils/ringbuffer"
+)
+
+// A poolConn is a connection that is driven by a workerPool.
+type poolConn interface {
+	// runStep processes all events that occurred since the last call.
+	// It must not block. It returns true once the connection has been closed.
+	runStep() (done bool)
+}
+
+const (
+	poolConnIdle uint32 = iota
+	poolConnQueued
+	poolConnRunning
+	// the connection was scheduled while it was running, and needs to run again
+	poolConnRunningAndScheduled
+	poolConnDone
+)
+
+// maxPoolConnSteps is the number of run loop iterations a connection may use before it yields to other connections.
+const maxPoolConnSteps = 16
+
+// A workerPoolEntry is the state of a connection that is driven by a workerPool.
+type workerPoolEntry struct {
+	pool  *workerPool
+	conn  poolConn
+	state atomic.Uint32
+}
+
+// schedule schedules the connection to run on one of the workers.
+// It is called when an event occurs, e.g. when a packet is received, when a timer expires,
+// or when the application has data to send.
+// It is a no-op if the connection is already scheduled.
+func (e *workerPoolEntry) schedule() {
+	for {
+		switch e.state.Load() {
+		case poolConnIdle:
+			if e.state.CompareAndSwap(poolConnIdle, poolConnQueued) {
+				e.pool.enqueue(e)
+				return
+			}
+		case poolConnRunning:
+			if e.state.CompareAndSwap(poolConnRunning, poolConnRunningAndScheduled) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// A workerPool drives connections using a fixed number of goroutines.
+// Instead of running a goroutine (and a timer) per connection, connections are event-driven state machines:
+// A connection is only run on one of the workers when a packet arrives, when its timer expires,
+// when the application has data to send, or when the connection is closed.
+// A connection is never run on more than one worker at the same time.
+type workerPool struct {
+	clock utils.Clock
+
+	queueMutex sync.Mutex
+	queueCond  sync.Cond
+	queue      ringbuffer.RingBuffer[*workerPoolEntry]
+	closed     bool
+
+	// The timers of all connections are kept in a single heap,
+	// and serviced by a single goroutine.
+	timerMutex   sync.Mutex
+	timers       workerPoolTimerHeap
+	timerChanged chan struct{} // is signaled when the earliest deadline changes
+
+	closeChan chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newWorkerPool(numWorkers int, clock utils.Clock) *workerPool {
+	if clock == nil {
+		clock = utils.DefaultClock{}
+	}
+	p := &workerPool{
+		clock:        clock,
+		timerChanged: make(chan struct{}, 1),
+		closeChan:    make(chan struct{}),
+	}
+	p.queueCond.L = &p.queueMutex
+	p.wg.Add(numWorkers + 1)
+	for i := 0; i < numWorkers; i++ {
+		go p.runWorker()
+	}
+	go p.runTimers()
+	return p
+}
+
+// add adds a new connection to the pool, and schedules it to run.
+func (p *workerPool) add(e *workerPoolEntry) {
+	e.schedule()
+}
+
+func (p *workerPool) enqueue(e *workerPoolEntry) {
+	p.queueMutex.Lock()
+	p.queue.PushBack(e)
+	p.queueMutex.Unlock()
+	p.queueCond.Signal()
+}
+
+// dequeue blocks until a connection is scheduled.
+// It returns nil when the pool is closed.
+func (p *workerPool) dequeue() *workerPoolEntry {
+	p.queueMutex.Lock()
+	defer p.queueMutex.Unlock()
+	for p.queue.Empty() {
+		if p.closed {
+			return nil
+		}
+		p.queueCond.Wait()
+	}
+	return p.queue.PopFront()
+}
+
+func (p *workerPool) runWorker() {
+	defer p.wg.Done()
+	for {
+		e := p.dequeue()
+		if e == nil {
+			return
+		}
+		e.state.Store(poolConnRunning)
+		if done := e.conn.runStep(); done {
+			e.state.Store(poolConnDone)
+			continue
+		}
+		if !e.state.CompareAndSwap(poolConnRunning, poolConnIdle) {
+			// The connection was scheduled while it was running.
+			e.state.Store(poolConnQueued)
+			p.enqueue(e)
+		}
+	}
+}
+
+func (p *workerPool) runTimers() {
+	defer p.wg.Done()
+	timer := p.clock.NewTimer(time.Duration(math.MaxInt64))
+	defer timer.Stop()
+	var fired []*workerPoolTimer
+	for {
+		p.timerMutex.Lock()
+		now := p.clock.Now()
+		for len(p.timers) > 0 && !p.timers[0].deadline.After(now) {
+			t := heap.Pop(&p.timers).(*workerPoolTimer)
+			select {
+			case t.c <- now:
+			default:
+			}
+			fired = append(fired, t)
+		}
+		var next time.Time
+		if len(p.timers) > 0 {
+			next = p.timers[0].deadline
+		}
+		p.timerMutex.Unlock()
+
+		for i, t := range fired {
+			t.entry.schedule()
+			fired[i] = nil
+		}
+		fired = fired[:0]
+
+		if !timer.Stop() {
+			select {
+			case <-timer.Chan():
+			default:
+			}
+		}
+		if !next.IsZero() {
+			timer.Reset(next.Sub(now))
+		}
+		select {
+		case <-timer.Chan():
+		case <-p.timerChanged:
+		case <-p.closeChan:
+			return
+		}
+	}
+}
+
+// close stops all workers.
+// It must only be called after all connections have been closed.
+func (p *workerPool) close() {
+	p.queueMutex.Lock()
+	p.closed = true
+	p.queueMutex.Unlock()
+	p.queueCond.Broadcast()
+	close(p.closeChan)
+	p.wg.Wait()
+}
+
+// A workerPoolClock is the clock used by a connection that is driven by a workerPool.
+// Timers created by this clock don't use a separate runtime timer,
+// but are serviced by the worker pool.
+type workerPoolClock struct {
+	utils.Clock
+	entry *workerPoolEntry
+}
+
+var _ utils.Clock = &workerPoolClock{}
+
+func (c *workerPoolClock) NewTimer(d time.Duration) utils.ClockTimer {
+	t := &workerPoolTimer{
+		entry: c.entry,
+		c:     make(chan time.Time, 1),
+		index: -1,
+	}
+	t.Reset(d)
+	return t
+}
+
+// A workerPoolTimer behaves like a time.Timer.
+// When it fires, the connection is scheduled to run.
+type workerPoolTimer struct {
+	entry    *workerPoolEntry
+	c        chan time.Time
+	deadline time.Time
+	index    int // the index in the heap, -1 if the timer is not set
+}
+
+var _ utils.ClockTimer = &workerPoolTimer{}
+
+func (t *workerPoolTimer) Chan() <-chan time.Time { return t.c }
+
+func (t *workerPoolTimer) Reset(d time.Duration) bool {
+	p := t.entry.pool
+	p.timerMutex.Lock()
+	defer p.timerMutex.Unlock()
+
+	wasActive := t.index >= 0
+	t.deadline = p.clock.Now().Add(d)
+	if wasActive {
+		heap.Fix(&p.timers, t.index)
+	} else {
+		heap.Push(&p.timers, t)
+	}
+	if t.index == 0 {
+		select {
+		case p.timerChanged <- struct{}{}:
+		default:
+		}
+	}
+	return wasActive
+}
+
+func (t *workerPoolTimer) Stop() bool {
+	p := t.entry.pool
+	p.timerMutex.Lock()
+	defer p.timerMutex.Unlock()
+
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&p.timers, t.index)
+	return true
+}
+
+type workerPoolTimerHeap []*workerPoolTimer
+
+var _ heap.Interface = &workerPoolTimerHeap{}
+
+func (h workerPoolTimerHeap) Len() int           { return len(h) }
+func (h workerPoolTimerHeap) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
+
+func (h workerPoolTimerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *workerPoolTimerHeap) Push(x any) {
+	t := x.(*workerPoolTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *workerPoolTimerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
@@ -0,0 +1,149 @@
+package quic
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type testPoolConn struct {
+	entry   workerPoolEntry
+	steps   atomic.Int32
+	running atomic.Int32
+	onStep  func(steps int32) (done bool)
+}
+
+var _ poolConn = &testPoolConn{}
+
+func newTestPoolConn(pool *workerPool) *testPoolConn {
+	c := &testPoolConn{}
+	c.entry.pool = pool
+	c.entry.conn = c
+	return c
+}
+
+func (c *testPoolConn) runStep() bool {
+	if c.running.Add(1) != 1 {
+		Fail("connection is run concurrently")
+	}
+	defer c.running.Add(-1)
+	steps := c.steps.Add(1)
+	if c.onStep != nil {
+		return c.onStep(steps)
+	}
+	return false
+}
+
+var _ = Describe("Worker Pool", func() {
+	var pool *workerPool
+
+	BeforeEach(func() {
+		pool = newWorkerPool(4, nil)
+	})
+
+	AfterEach(func() {
+		pool.close()
+	})
+
+	It("runs connections when they are added", func() {
+		conns := make([]*testPoolConn, 10)
+		for i := range conns {
+			conns[i] = newTestPoolConn(pool)
+			pool.add(&conns[i].entry)
+		}
+		for _, c := range conns {
+			Eventually(c.steps.Load).Should(BeEquivalentTo(1))
+		}
+		for _, c := range conns {
+			Consistently(c.steps.Load, scaleDuration(20*time.Millisecond)).Should(BeEquivalentTo(1))
+		}
+	})
+
+	It("never runs a connection on multiple workers at the same time", func() {
+		c := newTestPoolConn(pool)
+		c.onStep = func(int32) bool {
+			time.Sleep(100 * time.Microsecond)
+			return false
+		}
+		pool.add(&c.entry)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					c.entry.schedule()
+				}
+			}()
+		}
+		wg.Wait()
+		Eventually(func() uint32 { return c.entry.state.Load() }).Should(Equal(poolConnIdle))
+		Expect(c.steps.Load()).To(BeNumerically(">", 1))
+	})
+
+	It("runs a connection again if it was scheduled while running", func() {
+		c := newTestPoolConn(pool)
+		c.onStep = func(steps int32) bool {
+			if steps == 1 {
+				c.entry.schedule()
+			}
+			return false
+		}
+		pool.add(&c.entry)
+		Eventually(c.steps.Load).Should(BeEquivalentTo(2))
+		Consistently(c.steps.Load, scaleDuration(20*time.Millisecond)).Should(BeEquivalentTo(2))
+	})
+
+	It("doesn't run connections that are done", func() {
+		c := newTestPoolConn(pool)
+		c.onStep = func(int32) bool { return true }
+		pool.add(&c.entry)
+		Eventually(c.steps.Load).Should(BeEquivalentTo(1))
+		c.entry.schedule()
+		Consistently(c.steps.Load, scaleDuration(20*time.Millisecond)).Should(BeEquivalentTo(1))
+	})
+
+	Context("timers", func() {
+		It("runs a connection when its timer fires", func() {
+			c := newTestPoolConn(pool)
+			clock := &workerPoolClock{Clock: pool.clock, entry: &c.entry}
+			start := time.Now()
+			t := clock.NewTimer(scaleDuration(10 * time.Millisecond))
+			Eventually(c.steps.Load).Should(BeEquivalentTo(1))
+			Expect(time.Since(start)).To(BeNumerically(">=", scaleDuration(10*time.Millisecond)))
+			Expect(t.Chan()).To(Receive())
+			// the timer only fires once
+			Consistently(c.steps.Load, scaleDuration(20*time.Millisecond)).Should(BeEquivalentTo(1))
+		})
+
+		It("fires timers in order", func() {
+			c1 := newTestPoolConn(pool)
+			c2 := newTestPoolConn(pool)
+			t1 := (&workerPoolClock{Clock: pool.clock, entry: &c1.entry}).NewTimer(scaleDuration(30 * time.Millisecond))
+			t2 := (&workerPoolClock{Clock: pool.clock, entry: &c2.entry}).NewTimer(scaleDuration(10 * time.Millisecond))
+			Eventually(t2.Chan()).Should(Receive())
+			Expect(t1.Chan()).ToNot(Receive())
+			Eventually(t1.Chan()).Should(Receive())
+		})
+
+		It("resets timers", func() {
+			c := newTestPoolConn(pool)
+			t := (&workerPoolClock{Clock: pool.clock, entry: &c.entry}).NewTimer(time.Hour)
+			Expect(t.Reset(scaleDuration(10 * time.Millisecond))).To(BeTrue())
+			Eventually(t.Chan()).Should(Receive())
+			Expect(t.Reset(time.Hour)).To(BeFalse())
+		})
+
+		It("stops timers", func() {
+			c := newTestPoolConn(pool)
+			t := (&workerPoolClock{Clock: pool.clock, entry: &c.entry}).NewTimer(scaleDuration(10 * time.Millisecond))
+			Expect(t.Stop()).To(BeTrue())
+			Expect(t.Stop()).To(BeFalse())
+			Consistently(t.Chan(), scaleDuration(30*time.Millisecond)).ShouldNot(Receive())
+			Expect(c.steps.Load()).To(BeZero())
+		})
+	})
+})